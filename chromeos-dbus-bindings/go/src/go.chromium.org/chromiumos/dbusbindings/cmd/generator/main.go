@@ -7,124 +7,213 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
-	"io/ioutil"
+	"fmt"
 	"log"
 	"os"
-	"path/filepath"
 
-	"go.chromium.org/chromiumos/dbusbindings/generate/adaptor"
-	"go.chromium.org/chromiumos/dbusbindings/generate/methodnames"
-	"go.chromium.org/chromiumos/dbusbindings/generate/proxy"
+	"go.chromium.org/chromiumos/dbusbindings/dbustype"
+	"go.chromium.org/chromiumos/dbusbindings/describe"
+	"go.chromium.org/chromiumos/dbusbindings/generator"
 	"go.chromium.org/chromiumos/dbusbindings/introspect"
+	"go.chromium.org/chromiumos/dbusbindings/lint"
+	"go.chromium.org/chromiumos/dbusbindings/repl"
 	"go.chromium.org/chromiumos/dbusbindings/serviceconfig"
 )
 
-func main() {
-	serviceConfigPath := flag.String("service-config", "", "the DBus service configuration file for the generator.")
-	methodNamesPath := flag.String("method-names", "", "the output header file with string constants for each method name")
-	adaptorPath := flag.String("adaptor", "", "the output header file name containing the DBus adaptor class")
-	proxyPath := flag.String("proxy", "", "the output header file name containing the DBus proxy class")
-	mockPath := flag.String("mock", "", "the output header file name containing the DBus gmock proxy class")
-	proxyPathForMocks := flag.String("proxy-path-for-mocks", "", "the path to the header file for proxy interface, relative to the mock output path")
-	flag.Parse()
-
-	var sc serviceconfig.Config
-	if *serviceConfigPath != "" {
-		c, err := serviceconfig.Load(*serviceConfigPath)
+// explain prints the human-readable descriptions of the D-Bus signatures.
+func explain(signatures []string) {
+	if len(signatures) == 0 {
+		log.Fatal("Usage: explain SIGNATURE...")
+	}
+	for _, s := range signatures {
+		d, err := dbustype.Describe(s)
 		if err != nil {
-			log.Fatalf("Failed to read config file %s: %v", *serviceConfigPath, err)
+			log.Fatalf("Failed to parse signature %q: %v\n", s, err)
 		}
-		sc = *c
+		fmt.Printf("%s: %s\n", s, d)
 	}
+}
 
-	var introspections []introspect.Introspection
-	for _, path := range flag.Args() {
-		b, err := ioutil.ReadFile(path)
+// lintFiles prints the findings of the linter in the interface files as a
+// JSON array, and exits with status 1 if there are any.
+func lintFiles(paths []string) {
+	if len(paths) == 0 {
+		log.Fatal("Usage: lint FILE...")
+	}
+	findings := []lint.Finding{}
+	for _, path := range paths {
+		i, err := introspect.ParseFile(path)
 		if err != nil {
-			log.Fatalf("Failed to read file %s: %v\n", path, err)
+			log.Fatalf("Failed to parse interface file %s: %v", path, err)
 		}
+		findings = append(findings, lint.Check(path, i.Flatten())...)
+	}
+	b, err := json.MarshalIndent(findings, "", "  ")
+	if err != nil {
+		log.Fatalf("Failed to marshal the findings: %v", err)
+	}
+	fmt.Println(string(b))
+	if len(findings) > 0 {
+		os.Exit(1)
+	}
+}
 
-		introspection, err := introspect.Parse(b)
+// runREPL runs the interactive shell calling the methods of the interfaces in
+// the interface files given in args, after the flags of the shell.
+func runREPL(args []string) {
+	fs := flag.NewFlagSet("repl", flag.ExitOnError)
+	configPath := fs.String("service-config", "", "the DBus service configuration file (JSON or YAML) giving the service name to call")
+	dest := fs.String("dest", "", "the service name to call, overriding the one in the service config")
+	session := fs.Bool("session", false, "call the methods on the session bus instead of the system bus")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		log.Fatal("Usage: repl [-service-config FILE] [-dest NAME] [-session] FILE...")
+	}
+	o := repl.Options{ServiceName: *dest, Session: *session}
+	if *configPath != "" && o.ServiceName == "" {
+		c, err := serviceconfig.Load(*configPath)
 		if err != nil {
-			log.Fatalf("Failed to parse interface file %s: %v\n", path, err)
+			log.Fatalf("Failed to load DBus service config: %v", err)
 		}
-
-		introspections = append(introspections, introspection)
+		o.ServiceName = c.ServiceName
 	}
-
-	if *methodNamesPath != "" {
-		f, err := os.Create(*methodNamesPath)
+	var introspects []introspect.Introspection
+	for _, path := range fs.Args() {
+		i, err := introspect.ParseFile(path)
 		if err != nil {
-			log.Fatalf("Failed to create file %s: %v\n", *methodNamesPath, err)
-		}
-		defer func() {
-			if err := f.Close(); err != nil {
-				log.Fatalf("Failed to close file %s: %v\n", *methodNamesPath, err)
-			}
-		}()
-
-		if err := methodnames.Generate(introspections, f); err != nil {
-			log.Fatalf("Failed to generate methodnames: %v\n", err)
+			log.Fatalf("Failed to parse interface file %s: %v", path, err)
 		}
+		introspects = append(introspects, i.Flatten()...)
 	}
+	fmt.Println(`Type "help" for the commands.`)
+	if err := repl.New(introspects, o).Run(os.Stdin, os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
 
-	if *adaptorPath != "" {
-		f, err := os.Create(*adaptorPath)
-		if err != nil {
-			log.Fatalf("Failed to create adaptor file %s: %v\n", *adaptorPath, err)
+// describeGenerator prints the JSON description of the annotations, the
+// service config keys and the output backends the generator supports.
+func describeGenerator() {
+	d := describe.Describe()
+	for i, b := range d.Backends {
+		if f := flag.Lookup(b.Flag); f != nil {
+			d.Backends[i].Description = f.Usage
 		}
-		defer func() {
-			if err := f.Close(); err != nil {
-				log.Fatalf("Failed to close file %s: %v\n", *adaptorPath, err)
-			}
-		}()
+	}
+	b, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		log.Fatalf("Failed to marshal the description: %v", err)
+	}
+	fmt.Println(string(b))
+}
 
-		if err := adaptor.Generate(introspections, f, *adaptorPath); err != nil {
-			log.Fatalf("Failed to generate adaptor: %v\n", err)
-		}
+// generate runs the generator with o, and writes the outputs.
+func generate(o generator.Options) error {
+	a, err := generator.Run(o)
+	if err != nil {
+		return err
 	}
+	return a.Write(o.Incremental)
+}
 
-	if *proxyPath != "" {
-		f, err := os.Create(*proxyPath)
-		if err != nil {
-			log.Fatalf("Failed to create proxy file %s: %v\n", *proxyPath, err)
-		}
-		defer func() {
-			if err := f.Close(); err != nil {
-				log.Fatalf("Failed to close file %s: %v\n", *proxyPath, err)
-			}
-		}()
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "explain" {
+		explain(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "lint" {
+		lintFiles(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "repl" {
+		runREPL(os.Args[2:])
+		return
+	}
 
-		if err := proxy.Generate(introspections, f, *proxyPath, sc); err != nil {
-			log.Fatalf("Failed to generate proxy: %v\n", err)
-		}
+	var o generator.Options
+	flag.StringVar(&o.ServiceConfigPath, "service-config", "", "the DBus service configuration file (JSON or YAML) for the generator.")
+	flag.StringVar(&o.ServicesPath, "services", "", "the JSON manifest listing the service configs, interface files and proxy outputs of several services, whose shared interfaces are generated once into shared_proxy")
+	flag.StringVar(&o.MethodNamesPath, "method-names", "", "the output header file with string constants for each method name")
+	flag.StringVar(&o.ConstantsPath, "constants", "", "the output dbus-constants.h style header file with string constants for interface, member and error names")
+	flag.StringVar(&o.MetadataPath, "metadata", "", "the output header file with constexpr tables describing the methods, signals and properties of each interface")
+	flag.StringVar(&o.FakeArgsPath, "fake-args", "", "the output header file with the helpers returning arbitrary valid \"in\" arguments of each method, for property-based tests")
+	flag.StringVar(&o.AdaptorPath, "adaptor", "", "the output header file name containing the DBus adaptor class")
+	flag.StringVar(&o.AdaptorDir, "adaptor-dir", "", "the output directory of the DBus adaptor headers split per interface, named as specified by output_files in the service config")
+	flag.StringVar(&o.FuzzerPath, "fuzzer", "", "the output header file name containing the libFuzzer harnesses calling the methods of the DBus adaptor interfaces")
+	flag.StringVar(&o.ProxyPath, "proxy", "", "the output header file name containing the DBus proxy class")
+	flag.StringVar(&o.ProxySourcePath, "proxy-source", "", "the output source file name defining the methods of the DBus proxy classes, which the -proxy output then only declares")
+	flag.StringVar(&o.MockPath, "mock", "", "the output header file name containing the DBus gmock proxy class")
+	flag.StringVar(&o.TestFixturePath, "test-fixture", "", "the output header file name containing the gtest fixtures running the DBus proxy classes on a mock bus")
+	flag.StringVar(&o.CompileTestsDir, "compile-tests", "", "the output directory of the source files calling every method of the -proxy classes, one per interface, to be compiled in the build")
+	flag.StringVar(&o.LoopbackPath, "loopback", "", "the output header file name containing the classes implementing the DBus proxy interfaces by the adaptors in-process, for the integration tests without a bus")
+	flag.StringVar(&o.PimplProxyPath, "pimpl-proxy", "", "the output header file name containing the pimpl proxy classes, which expose no libchrome, brillo or dbus types")
+	flag.StringVar(&o.PimplSourcePath, "pimpl-proxy-source", "", "the output source file name defining the pimpl proxy classes on top of the DBus proxy classes")
+	flag.StringVar(&o.TSPath, "ts", "", "the output TypeScript file containing the client stubs for web UIs")
+	flag.StringVar(&o.GDBusPath, "gdbus", "", "the output C header file name containing the GDBusProxy based client functions, for the components written in C")
+	flag.StringVar(&o.PythonPath, "python", "", "the output Python file containing the dbus-python based client classes, for test scripts")
+	flag.StringVar(&o.GRPCProtoPath, "grpc-proto", "", "the output .proto file containing the gRPC service definitions converted from the interfaces (experimental)")
+	flag.StringVar(&o.DocsPath, "docs", "", "the output API reference of the interfaces, in HTML if the file name ends with .html, or in Markdown otherwise")
+	flag.StringVar(&o.CLIExamplesPath, "cli-examples", "", "the output file with the gdbus call and dbus-send command lines calling each method with example arguments, for the documentation and debugging")
+	flag.StringVar(&o.PolicyPath, "policy", "", "the output D-Bus policy file of the service, configured by policy in the service config")
+	flag.StringVar(&o.ServiceFilePath, "service-file", "", "the output D-Bus service activation file of the service, configured by policy in the service config")
+	flag.StringVar(&o.ManifestPath, "manifest", "", "the output JSON file listing the other output files with the interfaces they contain, and the input files with their hashes, for the build rules")
+	flag.StringVar(&o.ProxyPathForMocks, "proxy-path-for-mocks", "", "the path to the header file for proxy interface, relative to the mock output path")
+	flag.BoolVar(&o.AbstractOnly, "abstract-only", false, "generate only the abstract proxy interfaces, which do not depend on dbus, into the -proxy output")
+	flag.BoolVar(&o.SignalSendersForTesting, "signal-senders-for-testing", false, "also generate into the -proxy output the Send<Signal>SignalForTesting functions, running the signal callbacks with the marshaled signals in tests")
+	flag.BoolVar(&o.CppModules, "cpp-modules", false, "experimental: generate into the -proxy output a C++20 module interface unit exporting the proxy classes instead of a header")
+	flag.BoolVar(&o.NoBrillo, "no-brillo", false, "generate into the -proxy output the proxies depending only on libchrome, for the consumers inside Chromium which cannot depend on brillo")
+	flag.BoolVar(&o.StrictAnnotations, "strict-annotations", false, "fail if annotations in the org.chromium.DBus namespace are unknown, e.g. misspelled, instead of warning")
+	flag.BoolVar(&o.StrictDirections, "strict-directions", false, "fail if method arguments have no direction, or signal arguments have one, instead of warning")
+	flag.BoolVar(&o.FixDirections, "fix-directions", false, "rewrite the input files adding direction=\"in\" to method arguments without direction, and removing the directions of signal arguments")
+	flag.BoolVar(&o.Incremental, "incremental", false, "embed the hash of the inputs into the outputs, and keep the output files untouched if their contents are unchanged; implies -reproducible")
+	flag.BoolVar(&o.Reproducible, "reproducible", false, "omit the generation time from the trailers of the outputs, so that they only depend on the inputs")
+	recordCommandLine := flag.Bool("record-command-line", false, "record the command line in the trailers of the outputs; it usually has paths depending on the build environment")
+	flag.StringVar(&o.InputRoot, "input-root", "", "the directory which the input paths recorded in the trailers of the outputs are relative to, instead of the current directory")
+	flag.StringVar(&o.ClangFormatPath, "clang-format", "", "the clang-format executable to format the C++ outputs with; the outputs are not formatted if empty")
+	flag.StringVar(&o.ClangFormatStyle, "clang-format-style", "", "the .clang-format style file to format the C++ outputs with, instead of the embedded Chromium based style")
+	watchMode := flag.Bool("watch", false, "keep running, and regenerate the outputs whenever the interface files or the service config change")
+	introspectMode := flag.Bool("introspect", false, "print the JSON description of the supported annotations, service config keys and output backends, and exit")
+	flag.Parse()
+	o.Inputs = flag.Args()
+	if *recordCommandLine {
+		o.CommandLine = os.Args
 	}
+	// The outputs are written right away, so they need not be held in memory.
+	o.Stream = true
 
-	if *mockPath != "" {
-		p := *proxyPathForMocks
-		if p == "" && *proxyPath != "" {
-			// -proxy-path-for-mock is not specified. Derive it from proxyPath.
-			d := filepath.Dir(*mockPath)
-			var err error
-			p, err = filepath.Rel(d, *proxyPath)
-			if err != nil {
-				log.Fatal("Failed to compute the relpath from mock to proxy: ", err)
-			}
-		}
+	if *introspectMode {
+		describeGenerator()
+		return
+	}
 
-		f, err := os.Create(*mockPath)
-		if err != nil {
-			log.Fatalf("Failed to create proxy mock file %s: %v\n", *mockPath, err)
+	if !*watchMode {
+		if err := generate(o); err != nil {
+			log.Fatal(err)
 		}
-		defer func() {
-			if err := f.Close(); err != nil {
-				log.Fatalf("Failed to close file %s: %v\n", *mockPath, err)
-			}
-		}()
+		return
+	}
 
-		if err := proxy.GenerateMock(introspections, f, *mockPath, p, sc); err != nil {
-			log.Fatalf("Failed to generate proxy mock: %v\n", err)
+	// The inputs given on the command line are watched until they can be
+	// resolved, e.g. after a syntax error in them is fixed.
+	paths := append([]string(nil), o.Inputs...)
+	for _, p := range []string{o.ServiceConfigPath, o.ServicesPath} {
+		if p != "" {
+			paths = append(paths, p)
 		}
 	}
+	// In the watch mode, errors are reported but do not terminate the
+	// generator, so that they can be fixed while it keeps running.
+	watch(nil, watchInterval, func() []string {
+		if err := generate(o); err != nil {
+			log.Printf("Error: %v", err)
+		} else {
+			log.Print("Generated the outputs")
+		}
+		if inputs, err := generator.Inputs(o); err == nil {
+			paths = inputs
+		}
+		return paths
+	})
 }
@@ -0,0 +1,74 @@
+// Copyright 2022 The ChromiumOS Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package main
+
+import (
+	"log"
+	"os"
+	"time"
+)
+
+// watchInterval is the interval at which the watched files are checked for
+// changes.
+const watchInterval = 500 * time.Millisecond
+
+// fileState is the part of the state of a file which changes when it is
+// written, removed or replaced.
+type fileState struct {
+	exists  bool
+	size    int64
+	modTime time.Time
+}
+
+// statFile returns the current state of the file at path.
+func statFile(path string) fileState {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return fileState{}
+	}
+	return fileState{exists: true, size: fi.Size(), modTime: fi.ModTime()}
+}
+
+// watch calls fn once, and then again every time any of the files at the
+// paths returned by its last call changes, so that files newly included by the
+// inputs are watched as well. It polls the files every interval, so that it
+// works on any file system including the ones mounted from the host, and it
+// returns when stop is closed. Changes in quick succession, e.g. by an editor
+// saving a file with a temporary file, are coalesced into a single call as long
+// as they are observed in the same poll.
+func watch(stop <-chan struct{}, interval time.Duration, fn func() []string) {
+	var paths []string
+	var states []fileState
+	update := func() {
+		next := fn()
+		if len(next) != len(paths) {
+			log.Printf("Watching %d files for changes", len(next))
+		}
+		paths = next
+		states = make([]fileState, len(paths))
+		for i, p := range paths {
+			states[i] = statFile(p)
+		}
+	}
+	update()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		changed := false
+		for i, p := range paths {
+			if s := statFile(p); s != states[i] {
+				changed = true
+			}
+		}
+		if changed {
+			update()
+		}
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright 2022 The ChromiumOS Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "watch_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "test.xml")
+	if err := ioutil.WriteFile(path, []byte("<node/>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	calls := make(chan struct{}, 10)
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		watch(stop, 10*time.Millisecond, func() []string {
+			calls <- struct{}{}
+			return []string{path}
+		})
+	}()
+
+	wait := func(what string) {
+		select {
+		case <-calls:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("watch did not call fn %s", what)
+		}
+	}
+	wait("initially")
+
+	// Nothing is called while the file is unchanged.
+	time.Sleep(50 * time.Millisecond)
+	select {
+	case <-calls:
+		t.Fatal("watch called fn while the file is unchanged")
+	default:
+	}
+
+	mtime := time.Now().Add(time.Hour)
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	wait("after the mtime changed")
+
+	close(stop)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("watch did not return after stop was closed")
+	}
+}
@@ -8,6 +8,7 @@
 package dbustype
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 )
@@ -41,6 +42,9 @@ type dbusType struct {
 	// If kind is dbusKindArray, the length of args must be 1.
 	// If kind is dbusKindVariantDict, the length of args must be 2.
 	args []dbusType
+	// If kind is dbusKindStruct and name is not empty, the struct is rendered
+	// as the named C++ type instead of std::tuple.
+	name string
 }
 
 // BaseType returns the C++ type corresponding to the D-Bus type.
@@ -79,6 +83,9 @@ func (d *dbusType) BaseType() string {
 	case dbusKindDict:
 		return fmt.Sprintf("std::map<%s, %s>", d.args[0].BaseType(), d.args[1].BaseType())
 	case dbusKindStruct:
+		if d.name != "" {
+			return d.name
+		}
 		var mems []string
 		for _, arg := range d.args {
 			mems = append(mems, arg.BaseType())
@@ -126,4 +133,275 @@ func (d *dbusType) OutArgType() string {
 	return fmt.Sprintf("%s*", d.BaseType())
 }
 
+// structType returns the struct type which is either d itself or the element type of d
+// if d is an array. It returns nil if there is no such struct type.
+func (d *dbusType) structType() *dbusType {
+	switch {
+	case d.kind == dbusKindStruct:
+		return d
+	case d.kind == dbusKindArray && d.args[0].kind == dbusKindStruct:
+		return &d.args[0]
+	}
+	return nil
+}
+
+// SetStructName makes the struct type, which is either d itself or the element type of d
+// if d is an array, be rendered as the C++ type name instead of std::tuple.
+func (d *dbusType) SetStructName(name string) error {
+	t := d.structType()
+	if t == nil {
+		return errors.New("neither a struct nor an array of structs")
+	}
+	t.name = name
+	return nil
+}
+
+// StructMemberTypes returns the C++ types of the members of the struct type, which is either
+// d itself or the element type of d if d is an array.
+func (d *dbusType) StructMemberTypes() ([]string, error) {
+	t := d.structType()
+	if t == nil {
+		return nil, errors.New("neither a struct nor an array of structs")
+	}
+	var ret []string
+	for _, arg := range t.args {
+		ret = append(ret, arg.BaseType())
+	}
+	return ret, nil
+}
+
+// cppHeaders adds the headers declaring the non-standard C++ types the D-Bus
+// type is mapped to into seen.
+func (d *dbusType) cppHeaders(seen map[string]bool) {
+	switch d.kind {
+	case dbusKindObjectPath:
+		seen["<dbus/object_path.h>"] = true
+	case dbusKindVariant:
+		seen["<brillo/any.h>"] = true
+	case dbusKindVariantDict:
+		// brillo/variant_dictionary.h provides brillo::Any for the values.
+		seen["<brillo/variant_dictionary.h>"] = true
+		return
+	case dbusKindFileDescriptor:
+		seen["<base/files/scoped_file.h>"] = true
+	}
+	for i := range d.args {
+		d.args[i].cppHeaders(seen)
+	}
+}
+
 // TODO(chromium:983008): define ValidPropertyType and CallbackArgType func.
+
+// describe returns a human-readable description of the D-Bus type.
+func (d *dbusType) describe() string {
+	switch d.kind {
+	case dbusKindBoolean:
+		return "boolean"
+	case dbusKindByte:
+		return "byte"
+	case dbusKindDouble:
+		return "double"
+	case dbusKindInt16:
+		return "int16"
+	case dbusKindInt32:
+		return "int32"
+	case dbusKindInt64:
+		return "int64"
+	case dbusKindUint16:
+		return "uint16"
+	case dbusKindUint32:
+		return "uint32"
+	case dbusKindUint64:
+		return "uint64"
+	case dbusKindObjectPath:
+		return "object path"
+	case dbusKindString:
+		return "string"
+	case dbusKindVariant:
+		return "variant"
+	case dbusKindVariantDict:
+		return "dict<string, variant>"
+	case dbusKindFileDescriptor:
+		return "file descriptor"
+	case dbusKindArray:
+		return "array of " + d.args[0].describe()
+	case dbusKindDict:
+		return fmt.Sprintf("dict<%s, %s>", d.args[0].describe(), d.args[1].describe())
+	case dbusKindStruct:
+		var mems []string
+		for _, arg := range d.args {
+			mems = append(mems, arg.describe())
+		}
+		return fmt.Sprintf("struct<%s>", strings.Join(mems, ", "))
+	}
+
+	return ""
+}
+
+// tsType returns the TypeScript type corresponding to the D-Bus type.
+// 64-bit integers are mapped to bigint so that no precision is lost.
+func (d *dbusType) tsType() string {
+	switch d.kind {
+	case dbusKindBoolean:
+		return "boolean"
+	case dbusKindByte, dbusKindDouble, dbusKindInt16, dbusKindInt32,
+		dbusKindUint16, dbusKindUint32, dbusKindFileDescriptor:
+		return "number"
+	case dbusKindInt64, dbusKindUint64:
+		return "bigint"
+	case dbusKindObjectPath, dbusKindString:
+		return "string"
+	case dbusKindVariant:
+		return "unknown"
+	case dbusKindVariantDict:
+		return "Record<string, unknown>"
+	case dbusKindArray:
+		if d.args[0].kind == dbusKindByte {
+			return "Uint8Array"
+		}
+		return d.args[0].tsType() + "[]"
+	case dbusKindDict:
+		key, value := d.args[0].tsType(), d.args[1].tsType()
+		if key == "string" {
+			return fmt.Sprintf("Record<string, %s>", value)
+		}
+		return fmt.Sprintf("Map<%s, %s>", key, value)
+	case dbusKindStruct:
+		var mems []string
+		for _, arg := range d.args {
+			mems = append(mems, arg.tsType())
+		}
+		return fmt.Sprintf("[%s]", strings.Join(mems, ", "))
+	}
+
+	return ""
+}
+
+// pythonType returns the Python type hint corresponding to the D-Bus type,
+// which the values returned by dbus-python are instances of.
+func (d *dbusType) pythonType() string {
+	switch d.kind {
+	case dbusKindBoolean:
+		return "bool"
+	case dbusKindByte, dbusKindInt16, dbusKindInt32, dbusKindInt64,
+		dbusKindUint16, dbusKindUint32, dbusKindUint64, dbusKindFileDescriptor:
+		return "int"
+	case dbusKindDouble:
+		return "float"
+	case dbusKindObjectPath, dbusKindString:
+		return "str"
+	case dbusKindVariant:
+		return "Any"
+	case dbusKindVariantDict:
+		return "Dict[str, Any]"
+	case dbusKindArray:
+		return fmt.Sprintf("List[%s]", d.args[0].pythonType())
+	case dbusKindDict:
+		return fmt.Sprintf("Dict[%s, %s]", d.args[0].pythonType(), d.args[1].pythonType())
+	case dbusKindStruct:
+		var mems []string
+		for _, arg := range d.args {
+			mems = append(mems, arg.pythonType())
+		}
+		return fmt.Sprintf("Tuple[%s]", strings.Join(mems, ", "))
+	}
+
+	return ""
+}
+
+// ProtoField is a field of a protobuf message.
+type ProtoField struct {
+	Type string
+	Name string
+}
+
+// ProtoMessage is a protobuf message defined for a D-Bus type which has no
+// protobuf counterpart, i.e. a struct or a container nested in a container.
+type ProtoMessage struct {
+	Name   string
+	Fields []ProtoField
+}
+
+// protoContainer tells whether the protobuf type t is a repeated or map type,
+// which cannot be an element of a repeated or map type.
+func protoContainer(t string) bool {
+	return strings.HasPrefix(t, "repeated ") || strings.HasPrefix(t, "map<")
+}
+
+// protoType returns the protobuf type corresponding to the D-Bus type.
+// The messages defined for structs and nested containers are appended to
+// msgs, named after name.
+func (d *dbusType) protoType(name string, msgs *[]ProtoMessage) (string, error) {
+	switch d.kind {
+	case dbusKindBoolean:
+		return "bool", nil
+	case dbusKindByte, dbusKindUint16, dbusKindUint32:
+		return "uint32", nil
+	case dbusKindInt16, dbusKindInt32:
+		return "int32", nil
+	case dbusKindInt64:
+		return "int64", nil
+	case dbusKindUint64:
+		return "uint64", nil
+	case dbusKindDouble:
+		return "double", nil
+	case dbusKindObjectPath, dbusKindString:
+		return "string", nil
+	case dbusKindVariant:
+		return "google.protobuf.Any", nil
+	case dbusKindVariantDict:
+		return "map<string, google.protobuf.Any>", nil
+	case dbusKindFileDescriptor:
+		return "", errors.New("file descriptors have no protobuf type")
+	case dbusKindArray:
+		if d.args[0].kind == dbusKindByte {
+			return "bytes", nil
+		}
+		elem, err := d.args[0].wrappedProtoType(name+"Item", msgs)
+		if err != nil {
+			return "", err
+		}
+		return "repeated " + elem, nil
+	case dbusKindDict:
+		if d.args[0].kind == dbusKindDouble {
+			return "", errors.New("double dict keys have no protobuf type")
+		}
+		key, err := d.args[0].protoType(name+"Key", msgs)
+		if err != nil {
+			return "", err
+		}
+		value, err := d.args[1].wrappedProtoType(name+"Value", msgs)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("map<%s, %s>", key, value), nil
+	case dbusKindStruct:
+		msg := ProtoMessage{Name: name}
+		for i, arg := range d.args {
+			t, err := arg.protoType(fmt.Sprintf("%sField%d", name, i+1), msgs)
+			if err != nil {
+				return "", err
+			}
+			msg.Fields = append(msg.Fields, ProtoField{Type: t, Name: fmt.Sprintf("field_%d", i+1)})
+		}
+		*msgs = append(*msgs, msg)
+		return name, nil
+	}
+
+	return "", fmt.Errorf("unknown kind %d", d.kind)
+}
+
+// wrappedProtoType is the same as protoType, except that repeated and map
+// types are wrapped by a message named name, so that they can be elements of
+// containers.
+func (d *dbusType) wrappedProtoType(name string, msgs *[]ProtoMessage) (string, error) {
+	t, err := d.protoType(name, msgs)
+	if err != nil {
+		return "", err
+	}
+	if !protoContainer(t) {
+		return t, nil
+	}
+	*msgs = append(*msgs, ProtoMessage{Name: name, Fields: []ProtoField{{Type: t, Name: "value"}}})
+	return name, nil
+}
@@ -5,6 +5,8 @@
 package dbustype_test
 
 import (
+	"math/rand"
+	"strings"
 	"testing"
 
 	"go.chromium.org/chromiumos/dbusbindings/dbustype"
@@ -26,6 +28,48 @@ func TestParseFailures(t *testing.T) {
 	}
 }
 
+func TestValidate(t *testing.T) {
+	for _, tc := range []string{
+		"", "i", "sv", "a{sv}", "a{oa{sa{sv}}}", "(ia(sg))", "a{hs}",
+		"aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaai",
+	} {
+		if err := dbustype.Validate(tc); err != nil {
+			t.Errorf("Validate(%q) got error, want nil: %v", tc, err)
+		}
+	}
+}
+
+func TestValidateFailures(t *testing.T) {
+	cases := []struct {
+		input  string
+		offset int
+		reason string
+	}{
+		{"a", 1, "missing array element type"},
+		{"(i", 0, "unbalanced '('"},
+		{"i)", 1, "unbalanced ')'"},
+		{"a}i{", 1, "unbalanced '}'"},
+		{"{ss}", 0, "dict entry outside an array"},
+		{"a{s", 1, "unbalanced '{'"},
+		{"a{}", 2, "missing dict key type"},
+		{"a{s}", 3, "missing dict value type"},
+		{"a{vs}", 2, "dict key type 'v' is not a basic type"},
+		{"ia{(i)s}", 3, "dict key type '(' is not a basic type"},
+		{"a{sis}", 4, "dict entry must have 2 types"},
+		{"i()", 1, "empty struct"},
+		{"(il)", 2, "unknown type code 'l'"},
+		{"aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaai", 32, "array nesting depth exceeds 32"},
+		{"(((((((((((((((((((((((((((((((((i)))))))))))))))))))))))))))))))))", 32, "struct nesting depth exceeds 32"},
+	}
+	for _, tc := range cases {
+		err := dbustype.Validate(tc.input)
+		want := &dbustype.SignatureError{Signature: tc.input, Offset: tc.offset, Reason: tc.reason}
+		if diff := cmp.Diff(err, error(want)); diff != "" {
+			t.Errorf("Validate(%q) failed\n(-got +want):\n%s", tc.input, diff)
+		}
+	}
+}
+
 func TestParseSuccesses(t *testing.T) {
 	cases := []struct {
 		input string
@@ -208,4 +252,479 @@ func TestOutArgTypes(t *testing.T) {
 	}
 }
 
+func TestSetStructName(t *testing.T) {
+	cases := []struct {
+		input       string
+		wantBase    string
+		wantMembers []string
+	}{
+		{"(ib)", "Flag", []string{"int32_t", "bool"}},
+		{"a(ib)", "std::vector<Flag>", []string{"int32_t", "bool"}},
+		{"((i)s)", "Flag", []string{"std::tuple<int32_t>", "std::string"}},
+	}
+
+	for _, tc := range cases {
+		typ, err := dbustype.Parse(tc.input)
+		if err != nil {
+			t.Fatalf("Parse(%q) got error, want nil: %v", tc.input, err)
+		}
+		if err := typ.SetStructName("Flag"); err != nil {
+			t.Fatalf("SetStructName for %q got error, want nil: %v", tc.input, err)
+		}
+		if diff := cmp.Diff(typ.BaseType(), tc.wantBase); diff != "" {
+			t.Errorf("getting the base type of named %q failed\n(-got +want):\n%s", tc.input, diff)
+		}
+		members, err := typ.StructMemberTypes()
+		if err != nil {
+			t.Fatalf("StructMemberTypes for %q got error, want nil: %v", tc.input, err)
+		}
+		if diff := cmp.Diff(members, tc.wantMembers); diff != "" {
+			t.Errorf("getting the member types of %q failed\n(-got +want):\n%s", tc.input, diff)
+		}
+	}
+
+	for _, input := range []string{"i", "ai", "aai", "a{sv}", "aa(ib)"} {
+		typ, err := dbustype.Parse(input)
+		if err != nil {
+			t.Fatalf("Parse(%q) got error, want nil: %v", input, err)
+		}
+		if err := typ.SetStructName("Flag"); err == nil {
+			t.Errorf("SetStructName for %q unexpectedly succeeded", input)
+		}
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	cases := []struct {
+		input string
+		want  string
+	}{
+		{"b", "boolean"},
+		{"h", "file descriptor"},
+		{"o", "object path"},
+		{"ay", "array of byte"},
+		{"a{sv}", "dict<string, variant>"},
+		{"aa{sv}", "array of dict<string, variant>"},
+		{"a{oa{sa{sv}}}", "dict<object path, dict<string, dict<string, variant>>>"},
+		{"a(sx)", "array of struct<string, int64>"},
+		{"su", "string, uint32"},
+	}
+
+	for _, tc := range cases {
+		got, err := dbustype.Describe(tc.input)
+		if err != nil {
+			t.Fatalf("Describe(%q) got error, want nil: %v", tc.input, err)
+		}
+		if diff := cmp.Diff(got, tc.want); diff != "" {
+			t.Errorf("describing %q failed\n(-got +want):\n%s", tc.input, diff)
+		}
+	}
+
+	for _, input := range []string{"", "a", "(i", "a{s}"} {
+		if _, err := dbustype.Describe(input); err == nil {
+			t.Errorf("Describe(%q) unexpectedly succeeded", input)
+		}
+	}
+}
+
+func TestCppHeaders(t *testing.T) {
+	cases := []struct {
+		input string
+		want  []string
+	}{
+		{"i", nil},
+		{"as", nil},
+		{"h", []string{"<base/files/scoped_file.h>"}},
+		{"ao", []string{"<dbus/object_path.h>"}},
+		{"a{sv}", []string{"<brillo/variant_dictionary.h>"}},
+		{"a{iv}", []string{"<brillo/any.h>"}},
+		{"(hv)", []string{"<base/files/scoped_file.h>", "<brillo/any.h>"}},
+		{"va{sv}", []string{"<brillo/any.h>", "<brillo/variant_dictionary.h>"}},
+	}
+
+	for _, tc := range cases {
+		got, err := dbustype.CppHeaders(tc.input)
+		if err != nil {
+			t.Fatalf("CppHeaders(%q) got error, want nil: %v", tc.input, err)
+		}
+		if diff := cmp.Diff(got, tc.want); diff != "" {
+			t.Errorf("headers of %q failed\n(-got +want):\n%s", tc.input, diff)
+		}
+	}
+
+	if _, err := dbustype.CppHeaders("a{s}"); err == nil {
+		t.Error("CppHeaders(\"a{s}\") unexpectedly succeeded")
+	}
+}
+
+func TestTSType(t *testing.T) {
+	cases := []struct {
+		input string
+		want  string
+	}{
+		{"b", "boolean"},
+		{"i", "number"},
+		{"t", "bigint"},
+		{"o", "string"},
+		{"v", "unknown"},
+		{"ay", "Uint8Array"},
+		{"as", "string[]"},
+		{"a{sv}", "Record<string, unknown>"},
+		{"a{sa{sv}}", "Record<string, Record<string, unknown>>"},
+		{"a{ia(sb)}", "Map<number, [string, boolean][]>"},
+		{"(xay)", "[bigint, Uint8Array]"},
+	}
+
+	for _, tc := range cases {
+		got, err := dbustype.TSType(tc.input)
+		if err != nil {
+			t.Fatalf("TSType(%q) got error, want nil: %v", tc.input, err)
+		}
+		if diff := cmp.Diff(got, tc.want); diff != "" {
+			t.Errorf("TSType(%q) failed\n(-got +want):\n%s", tc.input, diff)
+		}
+	}
+
+	for _, input := range []string{"", "si", "a{s}"} {
+		if _, err := dbustype.TSType(input); err == nil {
+			t.Errorf("TSType(%q) unexpectedly succeeded", input)
+		}
+	}
+}
+
+func TestPythonType(t *testing.T) {
+	cases := []struct {
+		input string
+		want  string
+	}{
+		{"b", "bool"},
+		{"t", "int"},
+		{"d", "float"},
+		{"o", "str"},
+		{"v", "Any"},
+		{"ay", "List[int]"},
+		{"a{sv}", "Dict[str, Any]"},
+		{"a{ia(sb)}", "Dict[int, List[Tuple[str, bool]]]"},
+		{"(xh)", "Tuple[int, int]"},
+	}
+
+	for _, tc := range cases {
+		got, err := dbustype.PythonType(tc.input)
+		if err != nil {
+			t.Fatalf("PythonType(%q) got error, want nil: %v", tc.input, err)
+		}
+		if diff := cmp.Diff(got, tc.want); diff != "" {
+			t.Errorf("PythonType(%q) failed\n(-got +want):\n%s", tc.input, diff)
+		}
+	}
+
+	for _, input := range []string{"", "si", "a{s}"} {
+		if _, err := dbustype.PythonType(input); err == nil {
+			t.Errorf("PythonType(%q) unexpectedly succeeded", input)
+		}
+	}
+}
+
+func TestProtoType(t *testing.T) {
+	cases := []struct {
+		input    string
+		want     string
+		wantMsgs []dbustype.ProtoMessage
+	}{
+		{"b", "bool", nil},
+		{"y", "uint32", nil},
+		{"n", "int32", nil},
+		{"t", "uint64", nil},
+		{"o", "string", nil},
+		{"v", "google.protobuf.Any", nil},
+		{"ay", "bytes", nil},
+		{"as", "repeated string", nil},
+		{"a{sv}", "map<string, google.protobuf.Any>", nil},
+		{"a{ia(sb)}", "map<int32, ArgValue>", []dbustype.ProtoMessage{{
+			Name: "ArgValueItem",
+			Fields: []dbustype.ProtoField{
+				{Type: "string", Name: "field_1"},
+				{Type: "bool", Name: "field_2"},
+			},
+		}, {
+			Name:   "ArgValue",
+			Fields: []dbustype.ProtoField{{Type: "repeated ArgValueItem", Name: "value"}},
+		}}},
+		{"(xay)", "Arg", []dbustype.ProtoMessage{{
+			Name: "Arg",
+			Fields: []dbustype.ProtoField{
+				{Type: "int64", Name: "field_1"},
+				{Type: "bytes", Name: "field_2"},
+			},
+		}}},
+	}
+
+	for _, tc := range cases {
+		got, msgs, err := dbustype.ProtoType(tc.input, "Arg")
+		if err != nil {
+			t.Fatalf("ProtoType(%q) got error, want nil: %v", tc.input, err)
+		}
+		if diff := cmp.Diff(got, tc.want); diff != "" {
+			t.Errorf("ProtoType(%q) failed\n(-got +want):\n%s", tc.input, diff)
+		}
+		if diff := cmp.Diff(msgs, tc.wantMsgs); diff != "" {
+			t.Errorf("ProtoType(%q) messages failed\n(-got +want):\n%s", tc.input, diff)
+		}
+	}
+
+	for _, input := range []string{"", "si", "h", "a{dv}"} {
+		if _, _, err := dbustype.ProtoType(input, "Arg"); err == nil {
+			t.Errorf("ProtoType(%q) unexpectedly succeeded", input)
+		}
+	}
+}
+
 // TODO(chromium:983008): Add tests for PropertyType.
+
+func TestAppendCode(t *testing.T) {
+	cases := []struct {
+		input string
+		want  string
+	}{
+		{"i", "writer.AppendInt32(in_arg);"},
+		{"h", "writer.AppendFileDescriptor(in_arg.get());"},
+		{"ao", `{
+  dbus::MessageWriter array_writer_1(nullptr);
+  writer.OpenArray("o", &array_writer_1);
+  for (const auto& element_1 : in_arg) {
+    array_writer_1.AppendObjectPath(element_1);
+  }
+  writer.CloseContainer(&array_writer_1);
+}`},
+		{"a{s(ib)}", `{
+  dbus::MessageWriter array_writer_1(nullptr);
+  writer.OpenArray("{s(ib)}", &array_writer_1);
+  for (const auto& element_1 : in_arg) {
+    dbus::MessageWriter entry_writer_1(nullptr);
+    array_writer_1.OpenDictEntry(&entry_writer_1);
+    entry_writer_1.AppendString(element_1.first);
+    {
+      dbus::MessageWriter struct_writer_2(nullptr);
+      entry_writer_1.OpenStruct(&struct_writer_2);
+      struct_writer_2.AppendInt32(std::get<0>(element_1.second));
+      struct_writer_2.AppendBool(std::get<1>(element_1.second));
+      entry_writer_1.CloseContainer(&struct_writer_2);
+    }
+    array_writer_1.CloseContainer(&entry_writer_1);
+  }
+  writer.CloseContainer(&array_writer_1);
+}`},
+	}
+
+	for _, tc := range cases {
+		got, err := dbustype.AppendCode(tc.input, "writer", "in_arg")
+		if err != nil {
+			t.Fatalf("AppendCode(%q) got error, want nil: %v", tc.input, err)
+		}
+		if diff := cmp.Diff(got, tc.want); diff != "" {
+			t.Errorf("AppendCode(%q) failed\n(-got +want):\n%s", tc.input, diff)
+		}
+	}
+
+	for _, input := range []string{"v", "a{sv}", "(iv)", "a{s}"} {
+		if _, err := dbustype.AppendCode(input, "writer", "in_arg"); err == nil {
+			t.Errorf("AppendCode(%q) unexpectedly succeeded", input)
+		}
+	}
+}
+
+func TestPopCode(t *testing.T) {
+	cases := []struct {
+		input string
+		want  string
+	}{
+		{"s", `if (!reader.PopString(out_arg))
+  return false;`},
+		{"(sh)", `{
+  dbus::MessageReader struct_reader_1(nullptr);
+  if (!reader.PopStruct(&struct_reader_1))
+    return false;
+  if (!struct_reader_1.PopString(&std::get<0>(*out_arg)))
+    return false;
+  if (!struct_reader_1.PopFileDescriptor(&std::get<1>(*out_arg)))
+    return false;
+}`},
+		{"a{ias}", `{
+  dbus::MessageReader array_reader_1(nullptr);
+  if (!reader.PopArray(&array_reader_1))
+    return false;
+  while (array_reader_1.HasMoreData()) {
+    dbus::MessageReader entry_reader_1(nullptr);
+    if (!array_reader_1.PopDictEntry(&entry_reader_1))
+      return false;
+    int32_t key_1{};
+    if (!entry_reader_1.PopInt32(&key_1))
+      return false;
+    std::vector<std::string> value_1{};
+    {
+      dbus::MessageReader array_reader_2(nullptr);
+      if (!entry_reader_1.PopArray(&array_reader_2))
+        return false;
+      while (array_reader_2.HasMoreData()) {
+        std::string element_2{};
+        if (!array_reader_2.PopString(&element_2))
+          return false;
+        value_1.push_back(std::move(element_2));
+      }
+    }
+    (*out_arg)[std::move(key_1)] = std::move(value_1);
+  }
+}`},
+	}
+
+	for _, tc := range cases {
+		got, err := dbustype.PopCode(tc.input, "reader", "out_arg")
+		if err != nil {
+			t.Fatalf("PopCode(%q) got error, want nil: %v", tc.input, err)
+		}
+		if diff := cmp.Diff(got, tc.want); diff != "" {
+			t.Errorf("PopCode(%q) failed\n(-got +want):\n%s", tc.input, diff)
+		}
+	}
+
+	for _, input := range []string{"v", "a{sv}", "av", "si"} {
+		if _, err := dbustype.PopCode(input, "reader", "out_arg"); err == nil {
+			t.Errorf("PopCode(%q) unexpectedly succeeded", input)
+		}
+	}
+}
+
+func TestRandomValueLiteral(t *testing.T) {
+	cases := []struct {
+		input  string
+		prefix string
+	}{
+		{"b", ""},
+		{"y", "uint8_t{"},
+		{"n", "int16_t{"},
+		{"x", "int64_t{"},
+		{"t", "uint64_t{"},
+		{"o", `dbus::ObjectPath{"/`},
+		{"s", `std::string{"`},
+		{"v", "brillo::Any{"},
+		{"a{sv}", "brillo::VariantDictionary{"},
+		{"ai", "std::vector<int32_t>{"},
+		{"a{sa(ib)}", "std::map<std::string, std::vector<std::tuple<int32_t, bool>>>{"},
+		{"(dsv)", "std::tuple<double, std::string, brillo::Any>{"},
+	}
+	for _, tc := range cases {
+		got, err := dbustype.RandomValueLiteral(tc.input, rand.New(rand.NewSource(1)))
+		if err != nil {
+			t.Errorf("RandomValueLiteral(%q) got error: %v", tc.input, err)
+			continue
+		}
+		if !strings.HasPrefix(got, tc.prefix) {
+			t.Errorf("RandomValueLiteral(%q) = %q, want prefix %q", tc.input, got, tc.prefix)
+		}
+		again, err := dbustype.RandomValueLiteral(tc.input, rand.New(rand.NewSource(1)))
+		if err != nil || again != got {
+			t.Errorf("RandomValueLiteral(%q) with the same seed = %q, want %q", tc.input, again, got)
+		}
+	}
+	for _, input := range []string{"h", "a(sh)", "ii", ""} {
+		if _, err := dbustype.RandomValueLiteral(input, rand.New(rand.NewSource(1))); err == nil {
+			t.Errorf("RandomValueLiteral(%q) unexpectedly succeeded", input)
+		}
+	}
+}
+
+func TestExamples(t *testing.T) {
+	cases := []struct {
+		input, gvariant, dbusSend string
+	}{
+		{"b", "true", "boolean:true"},
+		{"y", "1", "byte:1"},
+		{"t", "1", "uint64:1"},
+		{"d", "1.5", "double:1.5"},
+		{"o", "'/'", "objpath:/"},
+		{"s", "'example'", "string:example"},
+		{"v", "<1>", "variant:int32:1"},
+		{"as", "['example']", "array:string:example"},
+		{"a{si}", "{'example': 1}", "dict:string:int32:example,1"},
+		{"a{sv}", "{'example': <1>}", ""},
+		{"(is)", "(1, 'example')", ""},
+		{"(i)", "(1,)", ""},
+		{"aai", "[[1]]", ""},
+		{"h", "", ""},
+	}
+	for _, tc := range cases {
+		got, err := dbustype.GVariantExample(tc.input)
+		if tc.gvariant == "" {
+			if err == nil {
+				t.Errorf("GVariantExample(%q) unexpectedly succeeded", tc.input)
+			}
+		} else if err != nil || got != tc.gvariant {
+			t.Errorf("GVariantExample(%q) = %q, %v; want %q", tc.input, got, err, tc.gvariant)
+		}
+		got, err = dbustype.DBusSendExample(tc.input)
+		if tc.dbusSend == "" {
+			if err == nil {
+				t.Errorf("DBusSendExample(%q) unexpectedly succeeded", tc.input)
+			}
+		} else if err != nil || got != tc.dbusSend {
+			t.Errorf("DBusSendExample(%q) = %q, %v; want %q", tc.input, got, err, tc.dbusSend)
+		}
+	}
+}
+
+func TestParseGVariant(t *testing.T) {
+	cases := []struct {
+		sig, input, want string
+	}{
+		{"b", "false", "false"},
+		{"y", "0xff", "255"},
+		{"n", " -3 ", "-3"},
+		{"d", "2", "2.0"},
+		{"d", "-0.25", "-0.25"},
+		{"s", "example", "'example'"},
+		{"s", `"it's"`, `'it\'s'`},
+		{"o", "/org/chromium/Test", "'/org/chromium/Test'"},
+		{"v", "<1>", "<@i 1>"},
+		{"v", "<1.5>", "<@d 1.5>"},
+		{"v", "<'a b'>", "<@s 'a b'>"},
+		{"v", "<@x 1>", "<@x 1>"},
+		{"v", "<@as [a]>", "<@as ['a']>"},
+		{"as", "[a, 'b c']", "['a', 'b c']"},
+		{"as", "[]", "[]"},
+		{"a{si}", "{a: 1, b: 2}", "{'a': 1, 'b': 2}"},
+		{"a{sv}", "{a: <true>}", "{'a': <@b true>}"},
+		{"(is)", "(1, a)", "(1, 'a')"},
+		{"(i)", "(1)", "(1,)"},
+		{"aai", "[[1], []]", "[[1], []]"},
+	}
+	for _, tc := range cases {
+		got, err := dbustype.ParseGVariant(tc.sig, tc.input)
+		if err != nil || got != tc.want {
+			t.Errorf("ParseGVariant(%q, %q) = %q, %v; want %q", tc.sig, tc.input, got, err, tc.want)
+		}
+	}
+}
+
+func TestParseGVariantFailures(t *testing.T) {
+	cases := []struct {
+		sig, input string
+	}{
+		{"b", "1"},
+		{"y", "256"},
+		{"u", "-1"},
+		{"i", "1.5"},
+		{"o", "relative"},
+		{"s", "'unterminated"},
+		{"s", "a b"},
+		{"v", "<[1]>"},
+		{"as", "[a b]"},
+		{"a{si}", "{a 1}"},
+		{"(is)", "(1)"},
+		{"h", "0"},
+	}
+	for _, tc := range cases {
+		if got, err := dbustype.ParseGVariant(tc.sig, tc.input); err == nil {
+			t.Errorf("ParseGVariant(%q, %q) = %q; want an error", tc.sig, tc.input, got)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright 2022 The ChromiumOS Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package dbustype
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// gvariantExample returns an example value of the type in the GVariant text
+// format, which gdbus call parses against the introspected signature.
+func (d *dbusType) gvariantExample() (string, error) {
+	switch d.kind {
+	case dbusKindBoolean:
+		return "true", nil
+	case dbusKindByte, dbusKindInt16, dbusKindInt32, dbusKindInt64,
+		dbusKindUint16, dbusKindUint32, dbusKindUint64:
+		return "1", nil
+	case dbusKindDouble:
+		return "1.5", nil
+	case dbusKindObjectPath:
+		return "'/'", nil
+	case dbusKindString:
+		return "'example'", nil
+	case dbusKindVariant:
+		// The value in a variant needs its type, which defaults to int32.
+		return "<1>", nil
+	case dbusKindFileDescriptor:
+		return "", errors.New("file descriptors cannot be passed")
+	case dbusKindVariantDict:
+		return "{'example': <1>}", nil
+	case dbusKindArray:
+		e, err := d.args[0].gvariantExample()
+		if err != nil {
+			return "", err
+		}
+		return "[" + e + "]", nil
+	case dbusKindDict:
+		k, err := d.args[0].gvariantExample()
+		if err != nil {
+			return "", err
+		}
+		v, err := d.args[1].gvariantExample()
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("{%s: %s}", k, v), nil
+	case dbusKindStruct:
+		var mems []string
+		for i := range d.args {
+			m, err := d.args[i].gvariantExample()
+			if err != nil {
+				return "", err
+			}
+			mems = append(mems, m)
+		}
+		if len(mems) == 1 {
+			// A single-element tuple needs a trailing comma.
+			return "(" + mems[0] + ",)", nil
+		}
+		return "(" + strings.Join(mems, ", ") + ")", nil
+	}
+	return "", fmt.Errorf("unknown kind %d", d.kind)
+}
+
+// dbusSendBasic returns the type name and an example value of the basic
+// type as dbus-send takes them.
+func (d *dbusType) dbusSendBasic() (string, string, error) {
+	switch d.kind {
+	case dbusKindBoolean:
+		return "boolean", "true", nil
+	case dbusKindByte:
+		return "byte", "1", nil
+	case dbusKindDouble:
+		return "double", "1.5", nil
+	case dbusKindInt16:
+		return "int16", "1", nil
+	case dbusKindInt32:
+		return "int32", "1", nil
+	case dbusKindInt64:
+		return "int64", "1", nil
+	case dbusKindUint16:
+		return "uint16", "1", nil
+	case dbusKindUint32:
+		return "uint32", "1", nil
+	case dbusKindUint64:
+		return "uint64", "1", nil
+	case dbusKindObjectPath:
+		return "objpath", "/", nil
+	case dbusKindString:
+		return "string", "example", nil
+	}
+	return "", "", fmt.Errorf("dbus-send cannot pass %s in containers", d.describe())
+}
+
+// dbusSendExample returns an example argument of the type in the syntax of
+// dbus-send, e.g. "array:string:example". dbus-send supports only basic
+// types, variants, and arrays and dicts of basic types.
+func (d *dbusType) dbusSendExample() (string, error) {
+	switch d.kind {
+	case dbusKindVariant:
+		return "variant:int32:1", nil
+	case dbusKindArray:
+		t, v, err := d.args[0].dbusSendBasic()
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("array:%s:%s", t, v), nil
+	case dbusKindDict:
+		kt, kv, err := d.args[0].dbusSendBasic()
+		if err != nil {
+			return "", err
+		}
+		vt, vv, err := d.args[1].dbusSendBasic()
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("dict:%s:%s:%s,%s", kt, vt, kv, vv), nil
+	case dbusKindVariantDict, dbusKindStruct, dbusKindFileDescriptor:
+		return "", fmt.Errorf("dbus-send cannot pass %s", d.describe())
+	}
+	t, v, err := d.dbusSendBasic()
+	if err != nil {
+		return "", err
+	}
+	return t + ":" + v, nil
+}
+
+// GVariantExample returns an example value of the signature |s| in the
+// GVariant text format taken by gdbus call, e.g. "['example']" for "as".
+// File descriptors are not supported.
+// |s| needs to be a signature made up of a single complete type.
+func GVariantExample(s string) (string, error) {
+	t, err := Parse(s)
+	if err != nil {
+		return "", err
+	}
+	return t.gvariantExample()
+}
+
+// DBusSendExample returns an example argument of the signature |s| in the
+// syntax of dbus-send, e.g. "array:string:example" for "as". Structs, file
+// descriptors and nested containers are not supported, as dbus-send cannot
+// pass them.
+// |s| needs to be a signature made up of a single complete type.
+func DBusSendExample(s string) (string, error) {
+	t, err := Parse(s)
+	if err != nil {
+		return "", err
+	}
+	return t.dbusSendExample()
+}
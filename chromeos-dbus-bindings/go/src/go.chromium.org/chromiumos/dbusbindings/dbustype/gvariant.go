@@ -0,0 +1,330 @@
+// Copyright 2022 The ChromiumOS Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package dbustype
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// gvariantParser parses a value typed by hand, e.g. in the REPL, against its
+// D-Bus type. The syntax is the GVariant text format taken by gdbus call,
+// except that the strings and the object paths made of a single word may be
+// left unquoted.
+type gvariantParser struct {
+	s   string
+	pos int
+}
+
+// errorf returns an error at the current position.
+func (p *gvariantParser) errorf(format string, a ...interface{}) error {
+	return fmt.Errorf("at offset %d: %s", p.pos, fmt.Sprintf(format, a...))
+}
+
+// skipSpaces advances the position past white spaces.
+func (p *gvariantParser) skipSpaces() {
+	for p.pos < len(p.s) && strings.ContainsRune(" \t\n", rune(p.s[p.pos])) {
+		p.pos++
+	}
+}
+
+// consume advances the position past c if it is the next character after
+// white spaces, and returns whether it is.
+func (p *gvariantParser) consume(c byte) bool {
+	p.skipSpaces()
+	if p.pos < len(p.s) && p.s[p.pos] == c {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// expect advances the position past c, which must be the next character
+// after white spaces.
+func (p *gvariantParser) expect(c byte) error {
+	if !p.consume(c) {
+		return p.errorf("%q expected", c)
+	}
+	return nil
+}
+
+// isWordChar returns true if c can be a character of an unquoted word.
+func isWordChar(c byte) bool {
+	return !strings.ContainsRune(" \t\n'\"[](){}<>,:@", rune(c))
+}
+
+// word returns the next unquoted word.
+func (p *gvariantParser) word() (string, error) {
+	p.skipSpaces()
+	start := p.pos
+	for p.pos < len(p.s) && isWordChar(p.s[p.pos]) {
+		p.pos++
+	}
+	if start == p.pos {
+		return "", p.errorf("value expected")
+	}
+	return p.s[start:p.pos], nil
+}
+
+// str returns the next string, either quoted with ' or " with the escapes
+// of \ taken as is, or an unquoted word.
+func (p *gvariantParser) str() (string, error) {
+	p.skipSpaces()
+	if p.pos >= len(p.s) || (p.s[p.pos] != '\'' && p.s[p.pos] != '"') {
+		return p.word()
+	}
+	quote := p.s[p.pos]
+	p.pos++
+	var b strings.Builder
+	for p.pos < len(p.s) {
+		c := p.s[p.pos]
+		p.pos++
+		switch {
+		case c == quote:
+			return b.String(), nil
+		case c == '\\' && p.pos < len(p.s):
+			b.WriteByte(p.s[p.pos])
+			p.pos++
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", p.errorf("unterminated string")
+}
+
+// quote returns s single-quoted in the GVariant text format.
+func quote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return "'" + strings.ReplaceAll(s, "'", `\'`) + "'"
+}
+
+// inferType returns the type of the value of a variant given without its
+// type, i.e. bool for true and false, int32 and double for the numbers, and
+// string otherwise, in the spirit of gdbus call.
+func (p *gvariantParser) inferType() dbusType {
+	save := p.pos
+	defer func() { p.pos = save }()
+	w, err := p.word()
+	if err != nil {
+		return dbusType{kind: dbusKindString}
+	}
+	if w == "true" || w == "false" {
+		return dbusType{kind: dbusKindBoolean}
+	}
+	if _, err := strconv.ParseInt(w, 0, 32); err == nil {
+		return dbusType{kind: dbusKindInt32}
+	}
+	if _, err := strconv.ParseFloat(w, 64); err == nil {
+		return dbusType{kind: dbusKindDouble}
+	}
+	return dbusType{kind: dbusKindString}
+}
+
+// variant parses the value of a variant between < and >, typed either by a
+// leading @ followed by its signature, e.g. "<@x 1>", or inferred from the
+// value.
+func (p *gvariantParser) variant() (string, error) {
+	if err := p.expect('<'); err != nil {
+		return "", err
+	}
+	var t dbusType
+	if p.consume('@') {
+		start := p.pos
+		for p.pos < len(p.s) && !strings.ContainsRune(" \t\n", rune(p.s[p.pos])) {
+			p.pos++
+		}
+		var err error
+		if t, err = Parse(p.s[start:p.pos]); err != nil {
+			return "", err
+		}
+	} else {
+		p.skipSpaces()
+		if p.pos < len(p.s) && strings.ContainsRune("[({<", rune(p.s[p.pos])) {
+			return "", p.errorf("the type of a container in a variant must be given with @, e.g. <@as ['example']>")
+		}
+		t = p.inferType()
+	}
+	v, err := p.value(&t)
+	if err != nil {
+		return "", err
+	}
+	if err := p.expect('>'); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("<@%s %s>", t.signature(), v), nil
+}
+
+// value parses the next value of the type d, and returns it in the GVariant
+// text format.
+func (p *gvariantParser) value(d *dbusType) (string, error) {
+	switch d.kind {
+	case dbusKindBoolean:
+		w, err := p.word()
+		if err != nil {
+			return "", err
+		}
+		if w != "true" && w != "false" {
+			return "", p.errorf("boolean expected, got %q", w)
+		}
+		return w, nil
+	case dbusKindByte, dbusKindUint16, dbusKindUint32, dbusKindUint64:
+		w, err := p.word()
+		if err != nil {
+			return "", err
+		}
+		bits := map[dbusKind]int{dbusKindByte: 8, dbusKindUint16: 16, dbusKindUint32: 32, dbusKindUint64: 64}[d.kind]
+		v, err := strconv.ParseUint(w, 0, bits)
+		if err != nil {
+			return "", p.errorf("%s expected, got %q", d.describe(), w)
+		}
+		return strconv.FormatUint(v, 10), nil
+	case dbusKindInt16, dbusKindInt32, dbusKindInt64:
+		w, err := p.word()
+		if err != nil {
+			return "", err
+		}
+		bits := map[dbusKind]int{dbusKindInt16: 16, dbusKindInt32: 32, dbusKindInt64: 64}[d.kind]
+		v, err := strconv.ParseInt(w, 0, bits)
+		if err != nil {
+			return "", p.errorf("%s expected, got %q", d.describe(), w)
+		}
+		return strconv.FormatInt(v, 10), nil
+	case dbusKindDouble:
+		w, err := p.word()
+		if err != nil {
+			return "", err
+		}
+		v, err := strconv.ParseFloat(w, 64)
+		if err != nil {
+			return "", p.errorf("double expected, got %q", w)
+		}
+		ret := strconv.FormatFloat(v, 'g', -1, 64)
+		if !strings.ContainsAny(ret, ".eIN") {
+			// The decimal point keeps gdbus from taking the value as an
+			// integer.
+			ret += ".0"
+		}
+		return ret, nil
+	case dbusKindString:
+		s, err := p.str()
+		if err != nil {
+			return "", err
+		}
+		return quote(s), nil
+	case dbusKindObjectPath:
+		s, err := p.str()
+		if err != nil {
+			return "", err
+		}
+		if !strings.HasPrefix(s, "/") {
+			return "", p.errorf("object path expected, got %q", s)
+		}
+		return quote(s), nil
+	case dbusKindVariant:
+		return p.variant()
+	case dbusKindFileDescriptor:
+		return "", errors.New("file descriptors cannot be passed")
+	case dbusKindArray:
+		if err := p.expect('['); err != nil {
+			return "", err
+		}
+		var elems []string
+		for !p.consume(']') {
+			if len(elems) > 0 {
+				if err := p.expect(','); err != nil {
+					return "", err
+				}
+			}
+			e, err := p.value(&d.args[0])
+			if err != nil {
+				return "", err
+			}
+			elems = append(elems, e)
+		}
+		return "[" + strings.Join(elems, ", ") + "]", nil
+	case dbusKindDict, dbusKindVariantDict:
+		key, val := dbusType{kind: dbusKindString}, dbusType{kind: dbusKindVariant}
+		if d.kind == dbusKindDict {
+			key, val = d.args[0], d.args[1]
+		}
+		if err := p.expect('{'); err != nil {
+			return "", err
+		}
+		var entries []string
+		for !p.consume('}') {
+			if len(entries) > 0 {
+				if err := p.expect(','); err != nil {
+					return "", err
+				}
+			}
+			k, err := p.value(&key)
+			if err != nil {
+				return "", err
+			}
+			if err := p.expect(':'); err != nil {
+				return "", err
+			}
+			v, err := p.value(&val)
+			if err != nil {
+				return "", err
+			}
+			entries = append(entries, k+": "+v)
+		}
+		return "{" + strings.Join(entries, ", ") + "}", nil
+	case dbusKindStruct:
+		if err := p.expect('('); err != nil {
+			return "", err
+		}
+		var mems []string
+		for i := range d.args {
+			if i > 0 {
+				if err := p.expect(','); err != nil {
+					return "", err
+				}
+			}
+			m, err := p.value(&d.args[i])
+			if err != nil {
+				return "", err
+			}
+			mems = append(mems, m)
+		}
+		// A single-element tuple may have a trailing comma.
+		p.consume(',')
+		if err := p.expect(')'); err != nil {
+			return "", err
+		}
+		if len(mems) == 1 {
+			return "(" + mems[0] + ",)", nil
+		}
+		return "(" + strings.Join(mems, ", ") + ")", nil
+	}
+	return "", fmt.Errorf("unknown kind %d", d.kind)
+}
+
+// ParseGVariant parses the value |v| typed by hand against the signature |s|,
+// and returns it in the GVariant text format taken by gdbus call, e.g.
+// "['a', 'b c']" for "as" and "[a, 'b c']". The syntax is the GVariant text
+// format, except that single-word strings and object paths may be unquoted,
+// and that the values of variants are typed with @ or inferred, e.g. "<1>"
+// for an int32 and "<@x 1>" for an int64.
+// |s| needs to be a signature made up of a single complete type.
+func ParseGVariant(s, v string) (string, error) {
+	t, err := Parse(s)
+	if err != nil {
+		return "", err
+	}
+	p := &gvariantParser{s: v}
+	ret, err := p.value(&t)
+	if err != nil {
+		return "", err
+	}
+	p.skipSpaces()
+	if p.pos < len(p.s) {
+		return "", p.errorf("unexpected %q after the value", p.s[p.pos:])
+	}
+	return ret, nil
+}
@@ -0,0 +1,276 @@
+// Copyright 2022 The ChromiumOS Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package dbustype
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// messageMethodSuffixes are the suffixes of the dbus::MessageWriter::Append*
+// and dbus::MessageReader::Pop* methods for the basic types.
+var messageMethodSuffixes = map[dbusKind]string{
+	dbusKindBoolean:    "Bool",
+	dbusKindByte:       "Byte",
+	dbusKindDouble:     "Double",
+	dbusKindInt16:      "Int16",
+	dbusKindInt32:      "Int32",
+	dbusKindInt64:      "Int64",
+	dbusKindUint16:     "Uint16",
+	dbusKindUint32:     "Uint32",
+	dbusKindUint64:     "Uint64",
+	dbusKindObjectPath: "ObjectPath",
+	dbusKindString:     "String",
+}
+
+// signature returns the D-Bus signature of the type.
+func (d *dbusType) signature() string {
+	switch d.kind {
+	case dbusKindBoolean:
+		return "b"
+	case dbusKindByte:
+		return "y"
+	case dbusKindDouble:
+		return "d"
+	case dbusKindInt16:
+		return "n"
+	case dbusKindInt32:
+		return "i"
+	case dbusKindInt64:
+		return "x"
+	case dbusKindUint16:
+		return "q"
+	case dbusKindUint32:
+		return "u"
+	case dbusKindUint64:
+		return "t"
+	case dbusKindObjectPath:
+		return "o"
+	case dbusKindString:
+		return "s"
+	case dbusKindVariant:
+		return "v"
+	case dbusKindVariantDict:
+		return "a{sv}"
+	case dbusKindFileDescriptor:
+		return "h"
+	case dbusKindArray:
+		return "a" + d.args[0].signature()
+	case dbusKindDict:
+		return fmt.Sprintf("a{%s%s}", d.args[0].signature(), d.args[1].signature())
+	case dbusKindStruct:
+		var b strings.Builder
+		b.WriteByte('(')
+		for i := range d.args {
+			b.WriteString(d.args[i].signature())
+		}
+		b.WriteByte(')')
+		return b.String()
+	}
+
+	return ""
+}
+
+// codeWriter accumulates the lines of C++ statements.
+type codeWriter struct {
+	lines []string
+	depth int
+}
+
+func (w *codeWriter) printf(format string, a ...interface{}) {
+	w.lines = append(w.lines, strings.Repeat("  ", w.depth)+fmt.Sprintf(format, a...))
+}
+
+// pointee returns the C++ expression of the value pointed by the pointer
+// expression p, simplifying "&x" to "x".
+func pointee(p string) string {
+	if strings.HasPrefix(p, "&") && !strings.HasPrefix(p, "&*") {
+		return p[1:]
+	}
+	return "*" + p
+}
+
+// deref is pointee which can be followed by a subscript.
+func deref(p string) string {
+	if v := pointee(p); !strings.HasPrefix(v, "*") {
+		return v
+	}
+	return "(*" + p + ")"
+}
+
+// member returns the C++ expression accessing the member m of the value
+// pointed by the pointer expression p.
+func member(p, m string) string {
+	if v := pointee(p); !strings.HasPrefix(v, "*") {
+		return v + "." + m
+	}
+	return p + "->" + m
+}
+
+// appendCode outputs the statements appending the C++ value v to the
+// dbus::MessageWriter writer. n makes the names of the nested writers and
+// the loop variables unique.
+func (d *dbusType) appendCode(w *codeWriter, writer, v string, n int) error {
+	if m, ok := messageMethodSuffixes[d.kind]; ok {
+		w.printf("%s.Append%s(%s);", writer, m, v)
+		return nil
+	}
+	sub := strconv.Itoa(n)
+	switch d.kind {
+	case dbusKindFileDescriptor:
+		w.printf("%s.AppendFileDescriptor(%s.get());", writer, v)
+		return nil
+	case dbusKindArray, dbusKindDict:
+		elem := d.args[0].signature()
+		if d.kind == dbusKindDict {
+			elem = fmt.Sprintf("{%s%s}", d.args[0].signature(), d.args[1].signature())
+		}
+		w.printf("{")
+		w.depth++
+		w.printf("dbus::MessageWriter array_writer_%s(nullptr);", sub)
+		w.printf("%s.OpenArray(%q, &array_writer_%s);", writer, elem, sub)
+		w.printf("for (const auto& element_%s : %s) {", sub, v)
+		w.depth++
+		if d.kind == dbusKindArray {
+			if err := d.args[0].appendCode(w, "array_writer_"+sub, "element_"+sub, n+1); err != nil {
+				return err
+			}
+		} else {
+			w.printf("dbus::MessageWriter entry_writer_%s(nullptr);", sub)
+			w.printf("array_writer_%s.OpenDictEntry(&entry_writer_%s);", sub, sub)
+			if err := d.args[0].appendCode(w, "entry_writer_"+sub, "element_"+sub+".first", n+1); err != nil {
+				return err
+			}
+			if err := d.args[1].appendCode(w, "entry_writer_"+sub, "element_"+sub+".second", n+1); err != nil {
+				return err
+			}
+			w.printf("array_writer_%s.CloseContainer(&entry_writer_%s);", sub, sub)
+		}
+		w.depth--
+		w.printf("}")
+		w.printf("%s.CloseContainer(&array_writer_%s);", writer, sub)
+		w.depth--
+		w.printf("}")
+		return nil
+	case dbusKindStruct:
+		w.printf("{")
+		w.depth++
+		w.printf("dbus::MessageWriter struct_writer_%s(nullptr);", sub)
+		w.printf("%s.OpenStruct(&struct_writer_%s);", writer, sub)
+		for i := range d.args {
+			if err := d.args[i].appendCode(w, "struct_writer_"+sub, fmt.Sprintf("std::get<%d>(%s)", i, v), n+1); err != nil {
+				return err
+			}
+		}
+		w.printf("%s.CloseContainer(&struct_writer_%s);", writer, sub)
+		w.depth--
+		w.printf("}")
+		return nil
+	}
+	return fmt.Errorf("%s is not supported", d.describe())
+}
+
+// popCode outputs the statements popping the C++ value pointed by p from
+// the dbus::MessageReader reader, returning false if it fails. n makes the
+// names of the nested readers and the local variables unique.
+func (d *dbusType) popCode(w *codeWriter, reader, p string, n int) error {
+	if m, ok := messageMethodSuffixes[d.kind]; ok {
+		w.printf("if (!%s.Pop%s(%s))", reader, m, p)
+		w.printf("  return false;")
+		return nil
+	}
+	sub := strconv.Itoa(n)
+	switch d.kind {
+	case dbusKindFileDescriptor:
+		w.printf("if (!%s.PopFileDescriptor(%s))", reader, p)
+		w.printf("  return false;")
+		return nil
+	case dbusKindArray, dbusKindDict:
+		w.printf("{")
+		w.depth++
+		w.printf("dbus::MessageReader array_reader_%s(nullptr);", sub)
+		w.printf("if (!%s.PopArray(&array_reader_%s))", reader, sub)
+		w.printf("  return false;")
+		w.printf("while (array_reader_%s.HasMoreData()) {", sub)
+		w.depth++
+		if d.kind == dbusKindArray {
+			w.printf("%s element_%s{};", d.args[0].BaseType(), sub)
+			if err := d.args[0].popCode(w, "array_reader_"+sub, "&element_"+sub, n+1); err != nil {
+				return err
+			}
+			w.printf("%s(std::move(element_%s));", member(p, "push_back"), sub)
+		} else {
+			w.printf("dbus::MessageReader entry_reader_%s(nullptr);", sub)
+			w.printf("if (!array_reader_%s.PopDictEntry(&entry_reader_%s))", sub, sub)
+			w.printf("  return false;")
+			w.printf("%s key_%s{};", d.args[0].BaseType(), sub)
+			if err := d.args[0].popCode(w, "entry_reader_"+sub, "&key_"+sub, n+1); err != nil {
+				return err
+			}
+			w.printf("%s value_%s{};", d.args[1].BaseType(), sub)
+			if err := d.args[1].popCode(w, "entry_reader_"+sub, "&value_"+sub, n+1); err != nil {
+				return err
+			}
+			w.printf("%s[std::move(key_%s)] = std::move(value_%s);", deref(p), sub, sub)
+		}
+		w.depth--
+		w.printf("}")
+		w.depth--
+		w.printf("}")
+		return nil
+	case dbusKindStruct:
+		w.printf("{")
+		w.depth++
+		w.printf("dbus::MessageReader struct_reader_%s(nullptr);", sub)
+		w.printf("if (!%s.PopStruct(&struct_reader_%s))", reader, sub)
+		w.printf("  return false;")
+		for i := range d.args {
+			if err := d.args[i].popCode(w, "struct_reader_"+sub, fmt.Sprintf("&std::get<%d>(%s)", i, pointee(p)), n+1); err != nil {
+				return err
+			}
+		}
+		w.depth--
+		w.printf("}")
+		return nil
+	}
+	return fmt.Errorf("%s is not supported", d.describe())
+}
+
+// AppendCode returns the C++ statements appending the value v of the type of
+// the signature |s| to the dbus::MessageWriter named writer, using only the
+// libchrome API, i.e. without brillo::dbus_utils. The statements are
+// separated by newlines and not indented.
+// Variants are not supported as they are rendered as brillo::Any.
+// |s| needs to be a signature made up of a single complete type.
+func AppendCode(s, writer, v string) (string, error) {
+	t, err := Parse(s)
+	if err != nil {
+		return "", err
+	}
+	var w codeWriter
+	if err := t.appendCode(&w, writer, v, 1); err != nil {
+		return "", fmt.Errorf("cannot append %s: %v", s, err)
+	}
+	return strings.Join(w.lines, "\n"), nil
+}
+
+// PopCode returns the C++ statements popping the value of the type of the
+// signature |s| from the dbus::MessageReader named reader into the value
+// pointed by p, returning false from the enclosing function on failures.
+// Like AppendCode, it uses only the libchrome API, and variants are not
+// supported.
+// |s| needs to be a signature made up of a single complete type.
+func PopCode(s, reader, p string) (string, error) {
+	t, err := Parse(s)
+	if err != nil {
+		return "", err
+	}
+	var w codeWriter
+	if err := t.popCode(&w, reader, p, 1); err != nil {
+		return "", fmt.Errorf("cannot pop %s: %v", s, err)
+	}
+	return strings.Join(w.lines, "\n"), nil
+}
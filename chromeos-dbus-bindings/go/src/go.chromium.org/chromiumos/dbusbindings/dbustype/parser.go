@@ -7,6 +7,8 @@ package dbustype
 import (
 	"errors"
 	"fmt"
+	"sort"
+	"strings"
 )
 
 // Parse returns a DBusType corresponding to the signature |s|.
@@ -51,6 +53,142 @@ func parseSignature(s string, index int) ([]dbusType, error) {
 const maxArrayDepth = 32
 const maxStructDepth = 32
 
+// SignatureError reports an invalid signature found by Validate.
+type SignatureError struct {
+	Signature string
+	// Offset is the byte offset in Signature where the error is found.
+	Offset int
+	Reason string
+}
+
+func (e *SignatureError) Error() string {
+	return fmt.Sprintf("invalid signature %q at offset %d: %s", e.Signature, e.Offset, e.Reason)
+}
+
+// Validate returns a *SignatureError if |s| is not a valid signature made up
+// of zero or more single complete types according to the D-Bus
+// specification, e.g. if its parentheses or braces are unbalanced, a dict key
+// is not a basic type, or the containers are nested too deeply.
+// Unlike Parse, Validate accepts the types which have no C++ counterpart in
+// this package, such as the signature type "g".
+func Validate(s string) error {
+	if len(s) > maxSignatureLength {
+		return &SignatureError{s, maxSignatureLength, fmt.Sprintf("the length exceeds the maximum %d", maxSignatureLength)}
+	}
+	v := validator{sig: s}
+	for v.pos < len(s) {
+		if err := v.completeType(0, 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validator holds the signature being validated and the offset to read next.
+type validator struct {
+	sig string
+	pos int
+}
+
+func (v *validator) errorf(offset int, format string, a ...interface{}) error {
+	return &SignatureError{v.sig, offset, fmt.Sprintf(format, a...)}
+}
+
+// isBasicTypeCode returns true if c is the type code of a basic type, which
+// can be the key of a dict.
+func isBasicTypeCode(c byte) bool {
+	return strings.IndexByte("ybnqiuxtdsogh", c) >= 0
+}
+
+// completeType validates the single complete type at v.pos, and advances
+// v.pos past it.
+func (v *validator) completeType(arrayDepth, structDepth int) error {
+	if v.pos >= len(v.sig) {
+		return v.errorf(v.pos, "missing type code")
+	}
+	start := v.pos
+	switch c := v.sig[start]; {
+	case isBasicTypeCode(c) || c == 'v':
+		v.pos++
+		return nil
+	case c == 'a':
+		if arrayDepth++; arrayDepth > maxArrayDepth {
+			return v.errorf(start, "array nesting depth exceeds %d", maxArrayDepth)
+		}
+		v.pos++
+		if v.pos >= len(v.sig) {
+			return v.errorf(v.pos, "missing array element type")
+		}
+		if v.sig[v.pos] == '{' {
+			return v.dictEntry(arrayDepth, structDepth)
+		}
+		return v.completeType(arrayDepth, structDepth)
+	case c == '(':
+		if structDepth++; structDepth > maxStructDepth {
+			return v.errorf(start, "struct nesting depth exceeds %d", maxStructDepth)
+		}
+		v.pos++
+		if v.pos < len(v.sig) && v.sig[v.pos] == ')' {
+			return v.errorf(start, "empty struct")
+		}
+		for v.pos < len(v.sig) {
+			if v.sig[v.pos] == ')' {
+				v.pos++
+				return nil
+			}
+			if err := v.completeType(arrayDepth, structDepth); err != nil {
+				return err
+			}
+		}
+		return v.errorf(start, "unbalanced '('")
+	case c == ')':
+		return v.errorf(start, "unbalanced ')'")
+	case c == '{':
+		return v.errorf(start, "dict entry outside an array")
+	case c == '}':
+		return v.errorf(start, "unbalanced '}'")
+	default:
+		return v.errorf(start, "unknown type code %q", c)
+	}
+}
+
+// dictEntry validates the dict entry at v.pos, which follows 'a', and
+// advances v.pos past it. Like structs, dict entries count toward the struct
+// nesting depth.
+func (v *validator) dictEntry(arrayDepth, structDepth int) error {
+	start := v.pos
+	if structDepth++; structDepth > maxStructDepth {
+		return v.errorf(start, "struct nesting depth exceeds %d", maxStructDepth)
+	}
+	v.pos++
+	if v.pos >= len(v.sig) {
+		return v.errorf(start, "unbalanced '{'")
+	}
+	if c := v.sig[v.pos]; c == '}' {
+		return v.errorf(v.pos, "missing dict key type")
+	} else if !isBasicTypeCode(c) {
+		return v.errorf(v.pos, "dict key type %q is not a basic type", c)
+	}
+	v.pos++
+	if v.pos < len(v.sig) && v.sig[v.pos] == '}' {
+		return v.errorf(v.pos, "missing dict value type")
+	}
+	if v.pos >= len(v.sig) {
+		return v.errorf(start, "unbalanced '{'")
+	}
+	if err := v.completeType(arrayDepth, structDepth); err != nil {
+		return err
+	}
+	if v.pos >= len(v.sig) {
+		return v.errorf(start, "unbalanced '{'")
+	}
+	if v.sig[v.pos] != '}' {
+		return v.errorf(v.pos, "dict entry must have 2 types")
+	}
+	v.pos++
+	return nil
+}
+
 // parseCompleteType parses a single complete type which is a substring of the signature |s|
 // beginning from |index|, and returns a DBusType corresponding to the single complete type.
 // This function also returns the next index to see.
@@ -157,3 +295,81 @@ func parseCompleteType(s string, index int, arrayDepth int, structDepth int) (db
 		return dbusType{}, 0, fmt.Errorf("unexpected type code: %c (index: %d)", s[index], index)
 	}
 }
+
+// TSType returns the TypeScript type corresponding to the signature |s|,
+// e.g. "Record<string, unknown>[]" for "aa{sv}".
+// |s| needs to be a signature made up of a single complete type.
+func TSType(s string) (string, error) {
+	t, err := Parse(s)
+	if err != nil {
+		return "", err
+	}
+	return t.tsType(), nil
+}
+
+// PythonType returns the Python type hint corresponding to the signature |s|,
+// e.g. "List[Dict[str, Any]]" for "aa{sv}".
+// |s| needs to be a signature made up of a single complete type.
+func PythonType(s string) (string, error) {
+	t, err := Parse(s)
+	if err != nil {
+		return "", err
+	}
+	return t.pythonType(), nil
+}
+
+// ProtoType returns the protobuf field type corresponding to the signature |s|,
+// e.g. "repeated string" for "as". Structs and containers nested in containers
+// are rendered as messages, which are named after |name| and returned together.
+// |s| needs to be a signature made up of a single complete type.
+func ProtoType(s, name string) (string, []ProtoMessage, error) {
+	t, err := Parse(s)
+	if err != nil {
+		return "", nil, err
+	}
+	var msgs []ProtoMessage
+	ret, err := t.protoType(name, &msgs)
+	if err != nil {
+		return "", nil, err
+	}
+	return ret, msgs, nil
+}
+
+// CppHeaders returns the headers declaring the C++ types other than the
+// standard library ones which the signature |s| is mapped to, sorted,
+// e.g. ["<base/files/scoped_file.h>", "<brillo/any.h>"] for "(hv)".
+// |s| may be made up of multiple complete types.
+func CppHeaders(s string) ([]string, error) {
+	typs, err := parseSignature(s, 0)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	for _, t := range typs {
+		t.cppHeaders(seen)
+	}
+	var ret []string
+	for h := range seen {
+		ret = append(ret, h)
+	}
+	sort.Strings(ret)
+	return ret, nil
+}
+
+// Describe returns a human-readable description of the signature |s|,
+// e.g. "array of dict<string, variant>" for "aa{sv}".
+// If |s| is made up of multiple complete types, their descriptions are joined by commas.
+func Describe(s string) (string, error) {
+	typs, err := parseSignature(s, 0)
+	if err != nil {
+		return "", err
+	}
+	if len(typs) == 0 {
+		return "", errors.New("empty signature")
+	}
+	var ret []string
+	for _, t := range typs {
+		ret = append(ret, t.describe())
+	}
+	return strings.Join(ret, ", "), nil
+}
@@ -0,0 +1,177 @@
+// Copyright 2022 The ChromiumOS Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package dbustype
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+// maxRandomElements is the maximum number of the elements of the random
+// arrays and dictionaries.
+const maxRandomElements = 3
+
+// variantKinds are the kinds of the values held by the random variants.
+var variantKinds = []dbusKind{
+	dbusKindBoolean,
+	dbusKindByte,
+	dbusKindDouble,
+	dbusKindInt16,
+	dbusKindInt32,
+	dbusKindInt64,
+	dbusKindUint16,
+	dbusKindUint32,
+	dbusKindUint64,
+	dbusKindObjectPath,
+	dbusKindString,
+}
+
+// randomSign returns v or -v at random.
+func randomSign(r *rand.Rand, v int64) int64 {
+	if r.Intn(2) == 0 {
+		return -v
+	}
+	return v
+}
+
+// randomName returns a random non-empty lowercase identifier.
+func randomName(r *rand.Rand) string {
+	const letters = "abcdefghijklmnopqrstuvwxyz"
+	b := make([]byte, 1+r.Intn(8))
+	for i := range b {
+		b[i] = letters[r.Intn(len(letters))]
+	}
+	return string(b)
+}
+
+// randomString returns a random string of printable ASCII characters, quoted
+// as a C++ string literal.
+func randomString(r *rand.Rand) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for n := r.Intn(12); n > 0; n-- {
+		c := byte(' ' + r.Intn('~'-' '+1))
+		if c == '"' || c == '\\' || c == '?' {
+			// '?' is escaped to avoid trigraphs.
+			b.WriteByte('\\')
+		}
+		b.WriteByte(c)
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// randomValueLiteral returns a C++ expression of a random value of the type.
+// The integers are typed, e.g. int16_t{-7}, so that the variants hold the
+// exact types.
+func (d *dbusType) randomValueLiteral(r *rand.Rand) (string, error) {
+	switch d.kind {
+	case dbusKindBoolean:
+		return strconv.FormatBool(r.Intn(2) == 0), nil
+	case dbusKindByte:
+		return fmt.Sprintf("uint8_t{%d}", r.Intn(1<<8)), nil
+	case dbusKindDouble:
+		s := strconv.FormatFloat(r.NormFloat64()*1000, 'g', -1, 64)
+		if !strings.ContainsAny(s, ".e") {
+			s += ".0"
+		}
+		return s, nil
+	case dbusKindInt16:
+		return fmt.Sprintf("int16_t{%d}", randomSign(r, r.Int63n(1<<15))), nil
+	case dbusKindInt32:
+		return fmt.Sprintf("int32_t{%d}", randomSign(r, r.Int63n(1<<31))), nil
+	case dbusKindInt64:
+		return fmt.Sprintf("int64_t{%d}", randomSign(r, r.Int63())), nil
+	case dbusKindUint16:
+		return fmt.Sprintf("uint16_t{%d}", r.Intn(1<<16)), nil
+	case dbusKindUint32:
+		return fmt.Sprintf("uint32_t{%du}", r.Uint32()), nil
+	case dbusKindUint64:
+		return fmt.Sprintf("uint64_t{%dull}", r.Uint64()), nil
+	case dbusKindObjectPath:
+		var b strings.Builder
+		for n := r.Intn(4); n > 0; n-- {
+			b.WriteString("/" + randomName(r))
+		}
+		if b.Len() == 0 {
+			b.WriteString("/")
+		}
+		return fmt.Sprintf("dbus::ObjectPath{%q}", b.String()), nil
+	case dbusKindString:
+		return fmt.Sprintf("std::string{%s}", randomString(r)), nil
+	case dbusKindVariant:
+		t := dbusType{kind: variantKinds[r.Intn(len(variantKinds))]}
+		v, err := t.randomValueLiteral(r)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("brillo::Any{%s}", v), nil
+	case dbusKindFileDescriptor:
+		return "", errors.New("file descriptors are not supported")
+	case dbusKindVariantDict:
+		t := dbusType{kind: dbusKindDict, args: []dbusType{{kind: dbusKindString}, {kind: dbusKindVariant}}}
+		v, err := t.randomValueLiteral(r)
+		if err != nil {
+			return "", err
+		}
+		return "brillo::VariantDictionary" + strings.TrimPrefix(v, t.BaseType()), nil
+	case dbusKindDict:
+		var elems []string
+		for n := r.Intn(maxRandomElements + 1); n > 0; n-- {
+			k, err := d.args[0].randomValueLiteral(r)
+			if err != nil {
+				return "", err
+			}
+			v, err := d.args[1].randomValueLiteral(r)
+			if err != nil {
+				return "", err
+			}
+			elems = append(elems, fmt.Sprintf("{%s, %s}", k, v))
+		}
+		return fmt.Sprintf("%s{%s}", d.BaseType(), strings.Join(elems, ", ")), nil
+	case dbusKindArray:
+		var elems []string
+		for n := r.Intn(maxRandomElements + 1); n > 0; n-- {
+			e, err := d.args[0].randomValueLiteral(r)
+			if err != nil {
+				return "", err
+			}
+			elems = append(elems, e)
+		}
+		return fmt.Sprintf("%s{%s}", d.BaseType(), strings.Join(elems, ", ")), nil
+	case dbusKindStruct:
+		var mems []string
+		for i := range d.args {
+			m, err := d.args[i].randomValueLiteral(r)
+			if err != nil {
+				return "", err
+			}
+			mems = append(mems, m)
+		}
+		return fmt.Sprintf("%s{%s}", d.BaseType(), strings.Join(mems, ", ")), nil
+	}
+	return "", fmt.Errorf("unknown kind %d", d.kind)
+}
+
+// RandomValueLiteral returns a C++ expression of an arbitrary valid value of
+// the C++ type of the signature |s|, e.g.
+// `std::vector<int32_t>{int32_t{-12}, int32_t{5}}` for "ai", drawn from r.
+// It is meant for generating the inputs of property-based tests.
+// File descriptors are not supported as they cannot be made up.
+// |s| needs to be a signature made up of a single complete type.
+func RandomValueLiteral(s string, r *rand.Rand) (string, error) {
+	t, err := Parse(s)
+	if err != nil {
+		return "", err
+	}
+	ret, err := t.randomValueLiteral(r)
+	if err != nil {
+		return "", fmt.Errorf("cannot make a value of %s: %v", s, err)
+	}
+	return ret, nil
+}
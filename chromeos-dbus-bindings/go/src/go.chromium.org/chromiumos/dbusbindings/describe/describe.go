@@ -0,0 +1,200 @@
+// Copyright 2022 The ChromiumOS Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+// Package describe describes what the generator supports, i.e. the
+// annotations, the service config keys and the output backends, so that the
+// build tooling and the IDE plugins can validate the user configs against the
+// generator binary in use.
+package describe
+
+import (
+	"reflect"
+	"runtime/debug"
+	"strings"
+
+	"go.chromium.org/chromiumos/dbusbindings/serviceconfig"
+)
+
+// Annotation is an annotation supported in the interface files.
+type Annotation struct {
+	Name string `json:"name"`
+	// Elements are the elements the annotation can be put on, e.g. "method".
+	Elements []string `json:"elements"`
+	// Values are the allowed values, or empty if the value is free-form.
+	Values []string `json:"values,omitempty"`
+}
+
+// ConfigKey is a key of the service config.
+type ConfigKey struct {
+	Name string `json:"name"`
+	// Type is the JSON type of the value, i.e. "string", "integer",
+	// "boolean", "object" or "array".
+	Type string `json:"type"`
+	// Values are the allowed values, or empty if any value of Type is.
+	Values []string `json:"values,omitempty"`
+	// Keys are the keys of the object, or of the objects in the array.
+	Keys []ConfigKey `json:"keys,omitempty"`
+}
+
+// Backend is an output of the generator.
+type Backend struct {
+	// Flag is the command line flag specifying the output path.
+	Flag        string `json:"flag"`
+	Description string `json:"description,omitempty"`
+	// APILevels are the values of target_api_level the C++ outputs support.
+	APILevels    []string `json:"api_levels,omitempty"`
+	Experimental bool     `json:"experimental,omitempty"`
+}
+
+// Description describes the generator.
+type Description struct {
+	// Version is the module version of the generator binary, or "(devel)"
+	// if it is not built from a versioned module.
+	Version     string       `json:"version"`
+	Annotations []Annotation `json:"annotations"`
+	ConfigKeys  []ConfigKey  `json:"config_keys"`
+	Backends    []Backend    `json:"backends"`
+}
+
+var boolValues = []string{"true", "false"}
+
+// annotations are the supported annotations.
+var annotations = []Annotation{
+	{Name: "org.chromium.DBus.Interface.ProtobufIncludes", Elements: []string{"interface"}},
+	{Name: "org.chromium.DBus.Interface.LightweightProperties", Elements: []string{"interface"}, Values: boolValues},
+	{Name: "org.chromium.DBus.Interface.BatchPropertyChanges", Elements: []string{"interface"}, Values: boolValues},
+	{Name: "org.chromium.DBus.Method.Kind", Elements: []string{"method"}, Values: []string{"simple", "normal", "async", "raw"}},
+	{Name: "org.chromium.DBus.Method.Const", Elements: []string{"method"}, Values: boolValues},
+	{Name: "org.chromium.DBus.Method.IncludeDBusMessage", Elements: []string{"method"}, Values: boolValues},
+	{Name: "org.chromium.DBus.Method.ReturnsFDStream", Elements: []string{"method"}, Values: boolValues},
+	{Name: "org.chromium.DBus.Method.Errors", Elements: []string{"method"}},
+	{Name: "org.freedesktop.DBus.Method.NoReply", Elements: []string{"method"}, Values: boolValues},
+	{Name: "org.freedesktop.DBus.GLib.Async", Elements: []string{"method"}},
+	{Name: "org.chromium.DBus.Signal.Kind", Elements: []string{"signal"}, Values: []string{"normal", "raw"}},
+	{Name: "org.chromium.DBus.Property.CachePolicy", Elements: []string{"property"}, Values: []string{"cached", "fetch_once", "always"}},
+	{Name: "org.freedesktop.DBus.Property.EmitsChangedSignal", Elements: []string{"property"}, Values: []string{"true", "invalidates", "const", "false"}},
+	{Name: "org.chromium.DBus.Argument.ProtobufClass", Elements: []string{"argument", "signal argument"}},
+	{Name: "org.chromium.DBus.Struct.FieldNames", Elements: []string{"argument", "signal argument"}},
+	{Name: "org.chromium.DBus.Argument.EnumClass", Elements: []string{"argument", "signal argument"}},
+	{Name: "org.chromium.DBus.Argument.FlagsClass", Elements: []string{"argument", "signal argument"}},
+	{Name: "org.chromium.DBus.Argument.CppType", Elements: []string{"argument", "signal argument"}},
+	{Name: "org.chromium.DBus.Argument.DefaultValue", Elements: []string{"argument"}},
+	{Name: "org.chromium.DBus.Argument.VariantTypes", Elements: []string{"argument"}},
+	{Name: "org.chromium.DBus.Argument.Optional", Elements: []string{"argument"}, Values: boolValues},
+	{Name: "org.chromium.DBus.Argument.PassByMove", Elements: []string{"argument"}, Values: boolValues},
+	{Name: "org.chromium.DBus.Argument.VariableName", Elements: []string{"argument", "property"}},
+	{Name: "org.chromium.DBus.MinVersion", Elements: []string{"method", "signal", "property"}},
+	{Name: "org.chromium.DBus.Skip", Elements: []string{"method", "signal", "property"}, Values: boolValues},
+	{Name: "org.chromium.DBus.SkipAdaptor", Elements: []string{"method", "signal", "property"}, Values: boolValues},
+	{Name: "org.chromium.DBus.SkipProxy", Elements: []string{"method", "signal", "property"}, Values: boolValues},
+}
+
+// apiLevels are the values of target_api_level.
+var apiLevels = []string{
+	string(serviceconfig.APILevelLatest),
+	string(serviceconfig.APILevelLegacyHeaders),
+	string(serviceconfig.APILevelLegacyCallbacks),
+}
+
+// configValues are the allowed values of the config keys of the string types
+// enumerating them.
+var configValues = map[reflect.Type][]string{
+	reflect.TypeOf(serviceconfig.NamingStyle("")): {
+		string(serviceconfig.NamingStyleSnakeCase),
+		string(serviceconfig.NamingStyleCamelCase),
+	},
+	reflect.TypeOf(serviceconfig.APILevel("")): apiLevels,
+	reflect.TypeOf(serviceconfig.PropertyMode("")): {
+		string(serviceconfig.PropertyModeObjectManager),
+		string(serviceconfig.PropertyModePropertySet),
+	},
+}
+
+// backends are the outputs of the generator. The C++ outputs support the API
+// levels.
+var backends = []Backend{
+	{Flag: "method-names", APILevels: apiLevels},
+	{Flag: "constants", APILevels: apiLevels},
+	{Flag: "metadata", APILevels: apiLevels},
+	{Flag: "fake-args", APILevels: apiLevels},
+	{Flag: "adaptor", APILevels: apiLevels},
+	{Flag: "adaptor-dir", APILevels: apiLevels},
+	{Flag: "fuzzer", APILevels: apiLevels},
+	{Flag: "proxy", APILevels: apiLevels},
+	{Flag: "proxy-source", APILevels: apiLevels},
+	{Flag: "mock", APILevels: apiLevels},
+	{Flag: "test-fixture", APILevels: apiLevels},
+	{Flag: "compile-tests", APILevels: apiLevels},
+	{Flag: "loopback", APILevels: apiLevels},
+	{Flag: "pimpl-proxy", APILevels: apiLevels},
+	{Flag: "pimpl-proxy-source", APILevels: apiLevels},
+	{Flag: "ts"},
+	{Flag: "gdbus"},
+	{Flag: "python"},
+	{Flag: "grpc-proto", Experimental: true},
+	{Flag: "docs"},
+	{Flag: "cli-examples"},
+	{Flag: "policy"},
+	{Flag: "service-file"},
+	{Flag: "manifest"},
+}
+
+// jsonType returns the JSON type of the values of t.
+func jsonType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.String:
+		return "string"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Ptr:
+		return jsonType(t.Elem())
+	default:
+		return "object"
+	}
+}
+
+// makeConfigKeys returns the keys of the JSON objects of the struct type t,
+// given by the json tags of its fields.
+func makeConfigKeys(t reflect.Type) []ConfigKey {
+	var ret []ConfigKey
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		k := ConfigKey{Name: name, Type: jsonType(f.Type), Values: configValues[f.Type]}
+		elem := f.Type
+		for elem.Kind() == reflect.Ptr || elem.Kind() == reflect.Slice || elem.Kind() == reflect.Map {
+			elem = elem.Elem()
+		}
+		if elem.Kind() == reflect.Struct {
+			k.Keys = makeConfigKeys(elem)
+		}
+		ret = append(ret, k)
+	}
+	return ret
+}
+
+// Describe returns the description of the generator. The descriptions of the
+// backends are left empty, for the caller to fill with the usages of the flags.
+func Describe() Description {
+	version := "(devel)"
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		version = info.Main.Version
+	}
+	return Description{
+		Version:     version,
+		Annotations: append([]Annotation(nil), annotations...),
+		ConfigKeys:  makeConfigKeys(reflect.TypeOf(serviceconfig.Config{})),
+		Backends:    append([]Backend(nil), backends...),
+	}
+}
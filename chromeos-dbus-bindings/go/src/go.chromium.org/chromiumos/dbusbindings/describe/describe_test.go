@@ -0,0 +1,65 @@
+// Copyright 2022 The ChromiumOS Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package describe
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDescribeConfigKeys(t *testing.T) {
+	keys := make(map[string]ConfigKey)
+	for _, k := range Describe().ConfigKeys {
+		keys[k.Name] = k
+	}
+
+	cases := []ConfigKey{
+		{Name: "service_name", Type: "string"},
+		{Name: "proxy_factories", Type: "boolean"},
+		{Name: "target_version", Type: "integer"},
+		{Name: "method_timeouts", Type: "object"},
+		{Name: "naming_style", Type: "string", Values: []string{"snake_case", "camelCase"}},
+		{Name: "target_api_level", Type: "string", Values: []string{"latest", "legacy_headers", "legacy_callbacks"}},
+		{Name: "object_manager", Type: "object", Keys: []ConfigKey{
+			{Name: "name", Type: "string"},
+			{Name: "object_path", Type: "string"},
+		}},
+		{Name: "type_mappings", Type: "array", Keys: []ConfigKey{
+			{Name: "signature", Type: "string"},
+			{Name: "arg_name", Type: "string"},
+			{Name: "cpp_type", Type: "string"},
+			{Name: "header", Type: "string"},
+		}},
+		{Name: "interfaces", Type: "object", Keys: []ConfigKey{
+			{Name: "object_path", Type: "string"},
+			{Name: "properties", Type: "string", Values: []string{"object_manager", "property_set"}},
+			{Name: "disable_blocking_calls", Type: "boolean"},
+			{Name: "namespace", Type: "string"},
+		}},
+	}
+	for _, want := range cases {
+		if diff := cmp.Diff(keys[want.Name], want); diff != "" {
+			t.Errorf("Describe got unexpected %s key (-got +want):\n%s", want.Name, diff)
+		}
+	}
+}
+
+func TestDescribeUniqueNames(t *testing.T) {
+	d := Describe()
+	seen := make(map[string]bool)
+	for _, a := range d.Annotations {
+		if seen[a.Name] {
+			t.Errorf("Describe got duplicate annotation %s", a.Name)
+		}
+		seen[a.Name] = true
+	}
+	for _, b := range d.Backends {
+		if seen[b.Flag] {
+			t.Errorf("Describe got duplicate backend %s", b.Flag)
+		}
+		seen[b.Flag] = true
+	}
+}
@@ -11,21 +11,35 @@ import (
 
 	"go.chromium.org/chromiumos/dbusbindings/generate/genutil"
 	"go.chromium.org/chromiumos/dbusbindings/introspect"
+	"go.chromium.org/chromiumos/dbusbindings/serviceconfig"
 )
 
 type templateArgs struct {
-	Introspects []introspect.Introspection
-	HeaderGuard string
+	Introspects         []introspect.Introspection
+	HeaderGuard         string
+	TypeMappingIncludes []string
 }
 
 var funcMap = template.FuncMap{
-	"makeInterfaceName":       genutil.MakeInterfaceName,
-	"makeAdaptorName":         genutil.MakeAdaptorName,
-	"makeFullItfName":         genutil.MakeFullItfName,
-	"extractNameSpaces":       genutil.ExtractNameSpaces,
-	"formatComment":           genutil.FormatComment,
-	"makeMethodRetType":       makeMethodRetType,
-	"makeMethodParams":        makeMethodParams,
+	"batchesPropertyChanges":    batchesPropertyChanges,
+	"hasBatchedPropertyChanges": hasBatchedPropertyChanges,
+	"makeInterfaceName":         genutil.MakeInterfaceName,
+	"makeAdaptorName":           genutil.MakeAdaptorName,
+	"formatComment":             genutil.FormatComment,
+	"hasOptionalArgs":           genutil.HasOptionalArgs,
+	"usesTypeHeader":            genutil.UsesTypeHeader,
+	"makeMethodRetType":         makeMethodRetType,
+	"makeNamedEnums":            genutil.MakeNamedEnums,
+	"makeProtobufClasses":       genutil.MakeProtobufClasses,
+	"usesProtobuf":              genutil.UsesProtobuf,
+	"makeNamedStructs":          genutil.MakeNamedStructs,
+	"makeNamedFlags":            genutil.MakeNamedFlags,
+	"makeMethodParams":          makeMethodParams,
+	"makeMethodResponseName":    makeMethodResponseName,
+	"makeMethodResponseTypes":   makeMethodResponseTypes,
+	"isAsyncMethod": func(m introspect.Method) bool {
+		return m.Kind() == introspect.MethodKindAsync
+	},
 	"makeAddHandlerName":      makeAddHandlerName,
 	"makePropertyWriteAccess": makePropertyWriteAccess,
 	"makeVariableName":        genutil.MakeVariableName,
@@ -42,6 +56,9 @@ var funcMap = template.FuncMap{
 	},
 	"makeDBusSignalParams": makeDBusSignalParams,
 	"reverse":              genutil.Reverse,
+	"unnamedArgNames": func(m introspect.Method) []string {
+		return genutil.UnnamedArgNames(serviceconfig.NamingStyleSnakeCase, m)
+	},
 }
 
 const (
@@ -52,23 +69,66 @@ const (
 #ifndef {{.HeaderGuard}}
 #define {{.HeaderGuard}}
 #include <memory>
+{{- if hasOptionalArgs .Introspects}}
+#include <optional>
+{{- end}}
 #include <string>
 #include <tuple>
+{{- if usesProtobuf .Introspects}}
+#include <type_traits>
+{{- end}}
 #include <vector>
 
+{{if usesTypeHeader .Introspects "<base/files/scoped_file.h>" -}}
 #include <base/files/scoped_file.h>
+{{end -}}
+{{if hasBatchedPropertyChanges .Introspects -}}
+#include <base/functional/callback.h>
+#include <dbus/message.h>
+{{end -}}
 #include <dbus/object_path.h>
+{{- if hasBatchedPropertyChanges .Introspects}}
+#include <dbus/property.h>
+{{- end}}
+{{- if usesTypeHeader .Introspects "<brillo/any.h>"}}
 #include <brillo/any.h>
+{{- end}}
+{{- if hasBatchedPropertyChanges .Introspects}}
+#include <brillo/dbus/data_serialization.h>
+{{- end}}
 #include <brillo/dbus/dbus_object.h>
 #include <brillo/dbus/exported_object_manager.h>
+{{- if or (hasBatchedPropertyChanges .Introspects) (usesTypeHeader .Introspects "<brillo/variant_dictionary.h>")}}
 #include <brillo/variant_dictionary.h>
+{{- end}}
+{{- if usesProtobuf .Introspects}}
+#include <google/protobuf/message_lite.h>
+{{- end}}
+{{- with .TypeMappingIncludes}}
+{{range .}}
+#include {{.}}
+{{- end}}
+{{- end}}
+{{- if hasOptionalArgs .Introspects}}
+
+{{template "optional"}}
+{{- end}}
+{{- if usesProtobuf .Introspects}}
+
+{{template "protobufClassCheck"}}
+{{- end}}
 {{range $introspect := .Introspects}}{{range .Interfaces -}}
 {{$itfName := makeInterfaceName .Name -}}
 {{$className := makeAdaptorName .Name -}}
 {{$fullItfName := makeFullItfName .Name}}
-{{range extractNameSpaces .Name -}}
+{{template "namedEnums" makeNamedEnums .}}
+{{- template "namedStructs" makeNamedStructs .}}
+{{- template "namedFlags" makeNamedFlags .}}
+{{- range extractNameSpaces .Name -}}
 namespace {{.}} {
 {{end}}
+{{with makeProtobufClasses .}}{{template "protobufChecks" .}}
+{{end -}}
 // Interface definition for {{$fullItfName}}.
 {{formatComment .DocString 0 -}}
 class {{$itfName}} {
@@ -95,11 +155,24 @@ class {{$className}} {
   static dbus::ObjectPath GetObjectPath() {
     return dbus::ObjectPath{"{{$introspect.Name}}"};
   }
+
+  // Creates the DBusObject at GetObjectPath() on bus, managed by
+  // object_manager unless it is null, with this adaptor registered on it.
+  // The caller registers the object on the bus.
+  std::unique_ptr<brillo::dbus_utils::DBusObject> RegisterAtDefaultPath(
+      brillo::dbus_utils::ExportedObjectManager* object_manager,
+      const scoped_refptr<dbus::Bus>& bus) {
+    auto object = std::make_unique<brillo::dbus_utils::DBusObject>(
+        object_manager, bus, GetObjectPath());
+    RegisterWithDBusObject(object.get());
+    return object;
+  }
 {{end}}
 {{template "quotedIntrospectionForInterfaceTmpl" . -}}
 {{"\n "}}private:
 {{template "signalDataMembersTmpl" . -}}
 {{template "propertyDataMembersTmpl" . -}}
+{{template "propertyBatchingTmpl" . -}}
 {{if .Methods -}}
 {{"  "}}{{$itfName}}* interface_;  // Owned by container of this adapter.
 {{end -}}
@@ -114,7 +187,13 @@ class {{$className}} {
 	interfaceMethodsTmpl = `{{define "interfaceMethodsTmpl" -}}
 {{if .Methods}}{{"\n"}}{{end -}}
 {{range .Methods -}}
+{{if isAsyncMethod . -}}
+{{"  "}}using {{makeMethodResponseName .}} =
+      brillo::dbus_utils::DBusMethodResponse<
+{{- range $i, $t := makeMethodResponseTypes .}}{{if ne $i 0}}, {{end}}{{$t}}{{end}}>;
+{{end -}}
 {{formatComment .DocString 2 -}}
+{{with unnamedArgNames .}}{{"  "}}// The unnamed arguments are named {{range $i, $n := .}}{{if $i}}, {{end}}{{$n}}{{end}}.{{"\n"}}{{end -}}
 {{"  "}}virtual {{makeMethodRetType .}} {{.Name}}(
 {{- range $i, $arg := makeMethodParams .}}{{if ne $i 0}},{{end}}
       {{$arg -}}
@@ -127,6 +206,9 @@ class {{$className}} {
 {{"  "}}void RegisterWithDBusObject(brillo::dbus_utils::DBusObject* object) {
     brillo::dbus_utils::DBusInterface* itf =
         object->AddOrGetInterface("{{.Name}}");
+{{- if batchesPropertyChanges .}}
+    dbus_object_ = object;
+{{- end}}
 {{if .Methods}}{{"\n"}}{{end -}}
 {{$itfName := makeInterfaceName .Name -}}
 {{range .Methods -}}
@@ -154,6 +236,11 @@ class {{$className}} {
                             base::Unretained(this)));
 {{end -}}
 {{"    "}}itf->AddProperty({{.Name}}Name(), &{{$variableName}}_);
+{{- if batchesPropertyChanges $}}
+    {{$variableName}}_.SetUpdateCallback(
+        base::BindRepeating(&{{$adaptorName}}::OnPropertyUpdated,
+                            base::Unretained(this), {{.Name}}Name()));
+{{- end}}
 {{end -}}
 
 {{"  " -}} }
@@ -203,6 +290,16 @@ class {{$className}} {
   }
 {{end -}}
 {{end -}}
+{{if batchesPropertyChanges .}}
+  // Runs |update|, which may call the Set...() methods, and emits a single
+  // PropertiesChanged signal for all the properties it changes.
+  void SetPropertiesAtomically(base::OnceClosure update) {
+    batching_property_changes_ = true;
+    std::move(update).Run();
+    batching_property_changes_ = false;
+    SendPropertiesChangedSignal();
+  }
+{{end -}}
 {{end}}`
 
 	quotedIntrospectionForInterfaceTmpl = `{{define "quotedIntrospectionForInterfaceTmpl" -}}
@@ -247,12 +344,47 @@ class {{$className}} {
 {{"  "}}brillo::dbus_utils::ExportedProperty<{{makePropertyBaseTypeExtract . }}> {{$variableName}}_;
 {{end -}}
 {{if .Properties}}{{"\n"}}{{end -}}
+{{end}}`
+
+	propertyBatchingTmpl = `{{define "propertyBatchingTmpl" -}}
+{{if batchesPropertyChanges . -}}
+{{"  "}}void OnPropertyUpdated(
+      const char* name,
+      const brillo::dbus_utils::ExportedPropertyBase* property) {
+    changed_properties_[name] = property->GetValue();
+    if (!batching_property_changes_)
+      SendPropertiesChangedSignal();
+  }
+
+  void SendPropertiesChangedSignal() {
+    if (changed_properties_.empty())
+      return;
+    dbus::Signal signal(dbus::kPropertiesInterface, dbus::kPropertiesChanged);
+    dbus::MessageWriter writer(&signal);
+    brillo::dbus_utils::AppendValueToWriter(&writer, std::string{"{{.Name}}"});
+    brillo::dbus_utils::AppendValueToWriter(&writer, changed_properties_);
+    brillo::dbus_utils::AppendValueToWriter(&writer, std::vector<std::string>{});
+    dbus_object_->SendSignal(&signal);
+    changed_properties_.clear();
+  }
+
+  brillo::dbus_utils::DBusObject* dbus_object_ = nullptr;
+  bool batching_property_changes_ = false;
+  brillo::VariantDictionary changed_properties_;
+
+{{end -}}
 {{end}}`
 )
 
 // Generate prints an interface definition and an interface adaptor for each interface in introspects.
-func Generate(introspects []introspect.Introspection, f io.Writer, outputFilePath string) error {
-	tmpl, err := template.New("adaptor").Funcs(funcMap).Parse(templateText)
+// The namespaces of the classes are taken from config.NamespaceOverrides if specified.
+func Generate(introspects []introspect.Introspection, f io.Writer, outputFilePath string, config serviceconfig.Config) error {
+	f = genutil.NewAPILevelWriter(f, config.TargetAPILevel)
+	nsFuncs, err := genutil.MakeNameSpaceFuncs(introspects, config.NamespaceOverrides)
+	if err != nil {
+		return err
+	}
+	tmpl, err := template.New("adaptor").Funcs(funcMap).Funcs(nsFuncs).Parse(templateText)
 	if err != nil {
 		return err
 	}
@@ -278,7 +410,25 @@ func Generate(introspects []introspect.Introspection, f io.Writer, outputFilePat
 	if _, err = tmpl.Parse(propertyDataMembersTmpl); err != nil {
 		return err
 	}
+	if _, err = tmpl.Parse(propertyBatchingTmpl); err != nil {
+		return err
+	}
+	if _, err = tmpl.Parse(genutil.NamedStructsTemplate); err != nil {
+		return err
+	}
+	if _, err = tmpl.Parse(genutil.NamedEnumsTemplate); err != nil {
+		return err
+	}
+	if _, err = tmpl.Parse(genutil.NamedFlagsTemplate); err != nil {
+		return err
+	}
+	if _, err = tmpl.Parse(genutil.OptionalTemplate); err != nil {
+		return err
+	}
+	if _, err = tmpl.Parse(genutil.ProtobufChecksTemplate); err != nil {
+		return err
+	}
 
 	var headerGuard = genutil.GenerateHeaderGuard(outputFilePath)
-	return tmpl.Execute(f, templateArgs{introspects, headerGuard})
+	return tmpl.Execute(f, templateArgs{introspects, headerGuard, genutil.MakeTypeMappingIncludes(introspects, config.TypeMappings)})
 }
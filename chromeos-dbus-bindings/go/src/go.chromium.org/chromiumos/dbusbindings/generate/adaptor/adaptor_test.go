@@ -10,6 +10,7 @@ import (
 	"text/template"
 
 	"go.chromium.org/chromiumos/dbusbindings/introspect"
+	"go.chromium.org/chromiumos/dbusbindings/serviceconfig"
 
 	"github.com/google/go-cmp/cmp"
 )
@@ -23,19 +24,45 @@ const (
 #include <memory>
 #include <string>
 #include <tuple>
+#include <type_traits>
 #include <vector>
 
 #include <base/files/scoped_file.h>
 #include <dbus/object_path.h>
-#include <brillo/any.h>
 #include <brillo/dbus/dbus_object.h>
 #include <brillo/dbus/exported_object_manager.h>
 #include <brillo/variant_dictionary.h>
+#include <google/protobuf/message_lite.h>
+
+#ifndef CHROMEOS_DBUS_BINDINGS_DBUS_PROTOBUF_CLASS_CHECK_
+#define CHROMEOS_DBUS_BINDINGS_DBUS_PROTOBUF_CLASS_CHECK_
+namespace brillo {
+namespace dbus_utils {
+
+// Fails to compile unless T, given by an
+// org.chromium.DBus.Argument.ProtobufClass annotation, is a protobuf message.
+template <typename T>
+constexpr bool CheckProtobufClass() {
+  static_assert(std::is_base_of_v<google::protobuf::MessageLite, T>,
+                "The class given by org.chromium.DBus.Argument.ProtobufClass "
+                "is not a protobuf message; check the annotation and the "
+                "headers in org.chromium.DBus.Interface.ProtobufIncludes");
+  return true;
+}
+
+}  // namespace dbus_utils
+}  // namespace brillo
+#endif  // CHROMEOS_DBUS_BINDINGS_DBUS_PROTOBUF_CLASS_CHECK_
 
 namespace fi {
 namespace w1 {
 namespace wpa_supplicant1 {
 
+static_assert(
+    brillo::dbus_utils::CheckProtobufClass<PassMeProtosRequest>());
+static_assert(
+    brillo::dbus_utils::CheckProtobufClass<YetAnotherProto>());
+
 // Interface definition for fi::w1::wpa_supplicant1::Interface.
 // interface doc
 class InterfaceInterface {
@@ -45,9 +72,11 @@ class InterfaceInterface {
   virtual bool Scan(
       brillo::ErrorPtr* error,
       const std::vector<base::ScopedFD>& in_args) = 0;
+  using PassMeProtosMethodResponse =
+      brillo::dbus_utils::DBusMethodResponse<>;
   // method doc
   virtual void PassMeProtos(
-      std::unique_ptr<brillo::dbus_utils::DBusMethodResponse<>> response,
+      std::unique_ptr<PassMeProtosMethodResponse> response,
       const PassMeProtosRequest& in_request) = 0;
 };
 
@@ -108,6 +137,18 @@ class InterfaceAdaptor {
     return dbus::ObjectPath{"/org/chromium/Test"};
   }
 
+  // Creates the DBusObject at GetObjectPath() on bus, managed by
+  // object_manager unless it is null, with this adaptor registered on it.
+  // The caller registers the object on the bus.
+  std::unique_ptr<brillo::dbus_utils::DBusObject> RegisterAtDefaultPath(
+      brillo::dbus_utils::ExportedObjectManager* object_manager,
+      const scoped_refptr<dbus::Bus>& bus) {
+    auto object = std::make_unique<brillo::dbus_utils::DBusObject>(
+        object_manager, bus, GetObjectPath());
+    RegisterWithDBusObject(object.get());
+    return object;
+  }
+
   static const char* GetIntrospectionXml() {
     return
         "  <interface name=\"fi.w1.wpa_supplicant1.Interface\">\n"
@@ -191,10 +232,10 @@ func TestGenerateAdaptors(t *testing.T) {
 						Name:      "request",
 						Type:      "ay",
 						Direction: "in",
-						Annotation: introspect.Annotation{
+						Annotations: []introspect.Annotation{{
 							Name:  "org.chromium.DBus.Argument.ProtobufClass",
 							Value: "PassMeProtosRequest",
-						},
+						}},
 					},
 				},
 				Annotations: []introspect.Annotation{
@@ -213,10 +254,10 @@ func TestGenerateAdaptors(t *testing.T) {
 					{
 						Name: "BSSDetail1",
 						Type: "ay",
-						Annotation: introspect.Annotation{
+						Annotations: []introspect.Annotation{{
 							Name:  "org.chromium.DBus.Argument.ProtobufClass",
 							Value: "YetAnotherProto",
-						},
+						}},
 					}, {
 						Name: "BSSDetail2",
 						Type: "(ih)",
@@ -236,10 +277,10 @@ func TestGenerateAdaptors(t *testing.T) {
 				Type:      "u",
 				Access:    "read",
 				DocString: "\n        property doc\n      ",
-				Annotation: introspect.Annotation{
+				Annotations: []introspect.Annotation{{
 					Name:  "org.chromium.DBus.Argument.VariableName",
 					Value: "bluetooth_class",
-				},
+				}},
 			},
 		},
 		DocString: "\n      interface doc\n    ",
@@ -259,7 +300,7 @@ func TestGenerateAdaptors(t *testing.T) {
 	}
 
 	out := new(bytes.Buffer)
-	if err := Generate(introspections, out, "/tmp/adaptor.h"); err != nil {
+	if err := Generate(introspections, out, "/tmp/adaptor.h", serviceconfig.Config{}); err != nil {
 		t.Fatalf("Generate got error, want nil: %v", err)
 	}
 
@@ -268,6 +309,412 @@ func TestGenerateAdaptors(t *testing.T) {
 	}
 }
 
+func TestGenerateAdaptorsWithNamedStructs(t *testing.T) {
+	itf := introspect.Interface{
+		Name: "test.Interface",
+		Signals: []introspect.Signal{
+			{
+				Name: "Found",
+				Args: []introspect.SignalArg{
+					{
+						Name: "entries",
+						Type: "a(si)",
+						Annotations: []introspect.Annotation{{
+							Name:  "org.chromium.DBus.Struct.FieldNames",
+							Value: "Entry(name, value)",
+						}},
+					},
+				},
+			},
+		},
+	}
+
+	introspections := []introspect.Introspection{{
+		Interfaces: []introspect.Interface{itf},
+	}}
+
+	out := new(bytes.Buffer)
+	if err := Generate(introspections, out, "/tmp/adaptor.h", serviceconfig.Config{}); err != nil {
+		t.Fatalf("Generate got error, want nil: %v", err)
+	}
+
+	const want = `// Automatic generation of D-Bus interfaces:
+//  - test.Interface
+#ifndef ____CHROMEOS_DBUS_BINDING___TMP_ADAPTOR_H
+#define ____CHROMEOS_DBUS_BINDING___TMP_ADAPTOR_H
+#include <memory>
+#include <string>
+#include <tuple>
+#include <vector>
+
+#include <dbus/object_path.h>
+#include <brillo/dbus/dbus_object.h>
+#include <brillo/dbus/exported_object_manager.h>
+
+#ifndef ____CHROMEOS_DBUS_BINDING__STRUCT__TEST__ENTRY
+#define ____CHROMEOS_DBUS_BINDING__STRUCT__TEST__ENTRY
+namespace test {
+
+struct Entry {
+  std::string name;
+  int32_t value;
+};
+
+}  // namespace test
+
+namespace brillo {
+namespace dbus_utils {
+
+template <>
+struct DBusType<test::Entry> {
+  using Tuple = std::tuple<std::string, int32_t>;
+
+  inline static std::string GetSignature() { return "(si)"; }
+  inline static void Write(dbus::MessageWriter* writer,
+                           const test::Entry& value) {
+    DBusType<Tuple>::Write(writer, Tuple(value.name, value.value));
+  }
+  inline static bool Read(dbus::MessageReader* reader,
+                          test::Entry* value) {
+    Tuple tuple;
+    if (!DBusType<Tuple>::Read(reader, &tuple))
+      return false;
+    std::tie(value->name, value->value) = std::move(tuple);
+    return true;
+  }
+};
+
+}  // namespace dbus_utils
+}  // namespace brillo
+#endif  // ____CHROMEOS_DBUS_BINDING__STRUCT__TEST__ENTRY
+
+namespace test {
+
+// Interface definition for test::Interface.
+class InterfaceInterface {
+ public:
+  virtual ~InterfaceInterface() = default;
+};
+
+// Interface adaptor for test::Interface.
+class InterfaceAdaptor {
+ public:
+  InterfaceAdaptor(InterfaceInterface* /* interface */) {}
+  InterfaceAdaptor(const InterfaceAdaptor&) = delete;
+  InterfaceAdaptor& operator=(const InterfaceAdaptor&) = delete;
+
+  void RegisterWithDBusObject(brillo::dbus_utils::DBusObject* object) {
+    brillo::dbus_utils::DBusInterface* itf =
+        object->AddOrGetInterface("test.Interface");
+
+    signal_Found_ = itf->RegisterSignalOfType<SignalFoundType>("Found");
+  }
+
+  void SendFoundSignal(
+      const std::vector<Entry>& in_entries) {
+    auto signal = signal_Found_.lock();
+    if (signal)
+      signal->Send(in_entries);
+  }
+
+  static const char* GetIntrospectionXml() {
+    return
+        "  <interface name=\"test.Interface\">\n"
+        "    <signal name=\"Found\">\n"
+        "      <arg name=\"entries\" type=\"a(si)\"/>\n"
+        "    </signal>\n"
+        "  </interface>\n";
+  }
+
+ private:
+  using SignalFoundType = brillo::dbus_utils::DBusSignal<
+      std::vector<Entry> /*entries*/>;
+  std::weak_ptr<SignalFoundType> signal_Found_;
+
+};
+
+}  // namespace test
+#endif  // ____CHROMEOS_DBUS_BINDING___TMP_ADAPTOR_H
+`
+	if diff := cmp.Diff(out.String(), want); diff != "" {
+		t.Errorf("Generate failed (-got +want):\n%s", diff)
+	}
+}
+
+func TestGenerateAdaptorsWithOptional(t *testing.T) {
+	itf := introspect.Interface{
+		Name: "test.Interface",
+		Methods: []introspect.Method{
+			{
+				Name: "FindDevice",
+				Args: []introspect.MethodArg{
+					{Name: "name", Type: "s"},
+					{
+						Name:        "path",
+						Type:        "(bo)",
+						Direction:   "out",
+						Annotations: []introspect.Annotation{{Name: "org.chromium.DBus.Argument.Optional", Value: "true"}},
+					},
+				},
+			},
+		},
+	}
+
+	introspections := []introspect.Introspection{{
+		Interfaces: []introspect.Interface{itf},
+	}}
+
+	out := new(bytes.Buffer)
+	if err := Generate(introspections, out, "/tmp/adaptor.h", serviceconfig.Config{}); err != nil {
+		t.Fatalf("Generate got error, want nil: %v", err)
+	}
+
+	const want = `// Automatic generation of D-Bus interfaces:
+//  - test.Interface
+#ifndef ____CHROMEOS_DBUS_BINDING___TMP_ADAPTOR_H
+#define ____CHROMEOS_DBUS_BINDING___TMP_ADAPTOR_H
+#include <memory>
+#include <optional>
+#include <string>
+#include <tuple>
+#include <vector>
+
+#include <dbus/object_path.h>
+#include <brillo/dbus/dbus_object.h>
+#include <brillo/dbus/exported_object_manager.h>
+
+#ifndef CHROMEOS_DBUS_BINDINGS_DBUS_OPTIONAL_
+#define CHROMEOS_DBUS_BINDINGS_DBUS_OPTIONAL_
+namespace brillo {
+namespace dbus_utils {
+
+template <typename T>
+struct DBusType<std::optional<T>> {
+  using Tuple = std::tuple<bool, T>;
+
+  inline static std::string GetSignature() {
+    return DBusType<Tuple>::GetSignature();
+  }
+  inline static void Write(dbus::MessageWriter* writer,
+                           const std::optional<T>& value) {
+    DBusType<Tuple>::Write(writer, Tuple(value.has_value(), value.value_or(T())));
+  }
+  inline static bool Read(dbus::MessageReader* reader,
+                          std::optional<T>* value) {
+    Tuple tuple;
+    if (!DBusType<Tuple>::Read(reader, &tuple))
+      return false;
+    if (std::get<0>(tuple))
+      *value = std::move(std::get<1>(tuple));
+    else
+      value->reset();
+    return true;
+  }
+};
+
+}  // namespace dbus_utils
+}  // namespace brillo
+#endif  // CHROMEOS_DBUS_BINDINGS_DBUS_OPTIONAL_
+
+namespace test {
+
+// Interface definition for test::Interface.
+class InterfaceInterface {
+ public:
+  virtual ~InterfaceInterface() = default;
+
+  virtual bool FindDevice(
+      brillo::ErrorPtr* error,
+      const std::string& in_name,
+      std::optional<dbus::ObjectPath>* out_path) = 0;
+};
+
+// Interface adaptor for test::Interface.
+class InterfaceAdaptor {
+ public:
+  InterfaceAdaptor(InterfaceInterface* interface) : interface_(interface) {}
+  InterfaceAdaptor(const InterfaceAdaptor&) = delete;
+  InterfaceAdaptor& operator=(const InterfaceAdaptor&) = delete;
+
+  void RegisterWithDBusObject(brillo::dbus_utils::DBusObject* object) {
+    brillo::dbus_utils::DBusInterface* itf =
+        object->AddOrGetInterface("test.Interface");
+
+    itf->AddSimpleMethodHandlerWithError(
+        "FindDevice",
+        base::Unretained(interface_),
+        &InterfaceInterface::FindDevice);
+  }
+
+  static const char* GetIntrospectionXml() {
+    return
+        "  <interface name=\"test.Interface\">\n"
+        "    <method name=\"FindDevice\">\n"
+        "      <arg name=\"name\" type=\"s\" direction=\"in\"/>\n"
+        "      <arg name=\"path\" type=\"(bo)\" direction=\"out\"/>\n"
+        "    </method>\n"
+        "  </interface>\n";
+  }
+
+ private:
+  InterfaceInterface* interface_;  // Owned by container of this adapter.
+};
+
+}  // namespace test
+#endif  // ____CHROMEOS_DBUS_BINDING___TMP_ADAPTOR_H
+`
+	if diff := cmp.Diff(out.String(), want); diff != "" {
+		t.Errorf("Generate failed (-got +want):\n%s", diff)
+	}
+}
+
+func TestGenerateAdaptorsWithBatchedPropertyChanges(t *testing.T) {
+	itf := introspect.Interface{
+		Name: "test.Interface",
+		Annotations: []introspect.Annotation{
+			{Name: "org.chromium.DBus.Interface.BatchPropertyChanges", Value: "true"},
+		},
+		Properties: []introspect.Property{
+			{Name: "Width", Access: "read", Type: "u"},
+			{Name: "Height", Access: "readwrite", Type: "u"},
+		},
+	}
+
+	introspections := []introspect.Introspection{{
+		Interfaces: []introspect.Interface{itf},
+	}}
+
+	out := new(bytes.Buffer)
+	if err := Generate(introspections, out, "/tmp/adaptor.h", serviceconfig.Config{}); err != nil {
+		t.Fatalf("Generate got error, want nil: %v", err)
+	}
+
+	const want = `// Automatic generation of D-Bus interfaces:
+//  - test.Interface
+#ifndef ____CHROMEOS_DBUS_BINDING___TMP_ADAPTOR_H
+#define ____CHROMEOS_DBUS_BINDING___TMP_ADAPTOR_H
+#include <memory>
+#include <string>
+#include <tuple>
+#include <vector>
+
+#include <base/functional/callback.h>
+#include <dbus/message.h>
+#include <dbus/object_path.h>
+#include <dbus/property.h>
+#include <brillo/dbus/data_serialization.h>
+#include <brillo/dbus/dbus_object.h>
+#include <brillo/dbus/exported_object_manager.h>
+#include <brillo/variant_dictionary.h>
+
+namespace test {
+
+// Interface definition for test::Interface.
+class InterfaceInterface {
+ public:
+  virtual ~InterfaceInterface() = default;
+};
+
+// Interface adaptor for test::Interface.
+class InterfaceAdaptor {
+ public:
+  InterfaceAdaptor(InterfaceInterface* /* interface */) {}
+  InterfaceAdaptor(const InterfaceAdaptor&) = delete;
+  InterfaceAdaptor& operator=(const InterfaceAdaptor&) = delete;
+
+  void RegisterWithDBusObject(brillo::dbus_utils::DBusObject* object) {
+    brillo::dbus_utils::DBusInterface* itf =
+        object->AddOrGetInterface("test.Interface");
+    dbus_object_ = object;
+
+    itf->AddProperty(WidthName(), &width_);
+    width_.SetUpdateCallback(
+        base::BindRepeating(&InterfaceAdaptor::OnPropertyUpdated,
+                            base::Unretained(this), WidthName()));
+    height_.SetAccessMode(
+        brillo::dbus_utils::ExportedPropertyBase::Access::kReadWrite);
+    height_.SetValidator(
+        base::BindRepeating(&InterfaceAdaptor::ValidateHeight,
+                            base::Unretained(this)));
+    itf->AddProperty(HeightName(), &height_);
+    height_.SetUpdateCallback(
+        base::BindRepeating(&InterfaceAdaptor::OnPropertyUpdated,
+                            base::Unretained(this), HeightName()));
+  }
+
+  static const char* WidthName() { return "Width"; }
+  uint32_t GetWidth() const {
+    return width_.GetValue().Get<uint32_t>();
+  }
+  void SetWidth(uint32_t width) {
+    width_.SetValue(width);
+  }
+
+  static const char* HeightName() { return "Height"; }
+  uint32_t GetHeight() const {
+    return height_.GetValue().Get<uint32_t>();
+  }
+  void SetHeight(uint32_t height) {
+    height_.SetValue(height);
+  }
+  virtual bool ValidateHeight(
+      brillo::ErrorPtr* /*error*/, const uint32_t& /*value*/) {
+    return true;
+  }
+
+  // Runs |update|, which may call the Set...() methods, and emits a single
+  // PropertiesChanged signal for all the properties it changes.
+  void SetPropertiesAtomically(base::OnceClosure update) {
+    batching_property_changes_ = true;
+    std::move(update).Run();
+    batching_property_changes_ = false;
+    SendPropertiesChangedSignal();
+  }
+
+  static const char* GetIntrospectionXml() {
+    return
+        "  <interface name=\"test.Interface\">\n"
+        "  </interface>\n";
+  }
+
+ private:
+  brillo::dbus_utils::ExportedProperty<uint32_t> width_;
+  brillo::dbus_utils::ExportedProperty<uint32_t> height_;
+
+  void OnPropertyUpdated(
+      const char* name,
+      const brillo::dbus_utils::ExportedPropertyBase* property) {
+    changed_properties_[name] = property->GetValue();
+    if (!batching_property_changes_)
+      SendPropertiesChangedSignal();
+  }
+
+  void SendPropertiesChangedSignal() {
+    if (changed_properties_.empty())
+      return;
+    dbus::Signal signal(dbus::kPropertiesInterface, dbus::kPropertiesChanged);
+    dbus::MessageWriter writer(&signal);
+    brillo::dbus_utils::AppendValueToWriter(&writer, std::string{"test.Interface"});
+    brillo::dbus_utils::AppendValueToWriter(&writer, changed_properties_);
+    brillo::dbus_utils::AppendValueToWriter(&writer, std::vector<std::string>{});
+    dbus_object_->SendSignal(&signal);
+    changed_properties_.clear();
+  }
+
+  brillo::dbus_utils::DBusObject* dbus_object_ = nullptr;
+  bool batching_property_changes_ = false;
+  brillo::VariantDictionary changed_properties_;
+
+};
+
+}  // namespace test
+#endif  // ____CHROMEOS_DBUS_BINDING___TMP_ADAPTOR_H
+`
+	if diff := cmp.Diff(out.String(), want); diff != "" {
+		t.Errorf("Generate failed (-got +want):\n%s", diff)
+	}
+}
+
 func TestInterfaceMethodsTempl(t *testing.T) {
 	cases := []struct {
 		input introspect.Interface
@@ -334,6 +781,7 @@ func TestInterfaceMethodsTempl(t *testing.T) {
 				},
 			},
 			want: `
+  // The unnamed arguments are named in_2.
   virtual void methodWithArgs(
       int32_t in_n,
       const std::string& in_2) = 0;
@@ -569,10 +1017,10 @@ func TestSendSignalMethodsTmpl(t *testing.T) {
 							{
 								Name: "",
 								Type: "ay",
-								Annotation: introspect.Annotation{
+								Annotations: []introspect.Annotation{{
 									Name:  "org.chromium.DBus.Argument.ProtobufClass",
 									Value: "MyProto",
-								},
+								}},
 							},
 						},
 						DocString: "this is comment2",
@@ -758,10 +1206,10 @@ func TestSignalDataMembersTmpl(t *testing.T) {
 							{
 								Name: "",
 								Type: "ay",
-								Annotation: introspect.Annotation{
+								Annotations: []introspect.Annotation{{
 									Name:  "org.chromium.DBus.Argument.ProtobufClass",
 									Value: "MyProto",
-								},
+								}},
 							},
 						},
 					},
@@ -46,17 +46,9 @@ func makeMethodParams(method introspect.Method) ([]string, error) {
 			methodParams = append(methodParams, "dbus::Message* message")
 		}
 	case introspect.MethodKindAsync:
-		var outTypes []string
-		for _, arg := range outputArguments {
-			baseType, err := arg.BaseType()
-			if err != nil {
-				return nil, err
-			}
-			outTypes = append(outTypes, baseType)
-		}
-		param := fmt.Sprintf(
-			"std::unique_ptr<brillo::dbus_utils::DBusMethodResponse<%s>> response",
-			strings.Join(outTypes, ", "))
+		// The response type is aliased in the interface by
+		// makeMethodResponseTypes.
+		param := fmt.Sprintf("std::unique_ptr<%s> response", makeMethodResponseName(method))
 		methodParams = append(methodParams, param)
 		if method.IncludeDBusMessage() {
 			methodParams = append(methodParams, "dbus::Message* message")
@@ -70,7 +62,7 @@ func makeMethodParams(method introspect.Method) ([]string, error) {
 		outputArguments = nil
 	}
 
-	index := 1
+	numIn := len(method.InputArguments())
 	for _, c := range []struct {
 		args        []introspect.MethodArg
 		makeArgType func(*introspect.MethodArg) (string, error)
@@ -79,20 +71,50 @@ func makeMethodParams(method introspect.Method) ([]string, error) {
 		{inputArguments, (*introspect.MethodArg).InArgType, "in"},
 		{outputArguments, (*introspect.MethodArg).OutArgType, "out"},
 	} {
-		for _, arg := range c.args {
+		for i, arg := range c.args {
 			paramType, err := c.makeArgType(&arg)
 			if err != nil {
 				return nil, err
 			}
-			paramName := genutil.ArgName(c.prefix, arg.Name, index)
-			index++
-			methodParams = append(methodParams, fmt.Sprintf("%s %s", paramType, paramName))
+			paramName := genutil.ArgName(c.prefix, arg.Name, genutil.MethodArgIndex(c.prefix == "out", i, numIn))
+			param := fmt.Sprintf("%s %s", paramType, paramName)
+			// Default values can be given only if the input arguments are
+			// the trailing parameters.
+			if v := arg.DefaultValue(); v != "" && len(outputArguments) == 0 {
+				param += " = " + v
+			}
+			methodParams = append(methodParams, param)
 		}
 	}
 
 	return methodParams, nil
 }
 
+// makeMethodResponseName returns the name of the DBusMethodResponse type
+// alias of the async method.
+func makeMethodResponseName(method introspect.Method) string {
+	return method.Name + "MethodResponse"
+}
+
+// makeMethodResponseTypes returns the template arguments of the
+// DBusMethodResponse of the async method, which are the types of its "out"
+// arguments followed by their names in comments, in the same order as the
+// parameters of the success callback of the proxy.
+func makeMethodResponseTypes(method introspect.Method) ([]string, error) {
+	var ret []string
+	for _, arg := range method.OutputArguments() {
+		t, err := arg.BaseType()
+		if err != nil {
+			return nil, err
+		}
+		if arg.Name != "" {
+			t += fmt.Sprintf(" /*%s*/", arg.Name)
+		}
+		ret = append(ret, t)
+	}
+	return ret, nil
+}
+
 func makeAddHandlerName(method introspect.Method) string {
 	switch method.Kind() {
 	case introspect.MethodKindSimple:
@@ -167,3 +189,22 @@ func makeDBusSignalParams(signal introspect.Signal) ([]string, error) {
 	}
 	return params, nil
 }
+
+// batchesPropertyChanges returns true if the adaptor of itf can set its
+// properties at once with a single PropertiesChanged signal.
+func batchesPropertyChanges(itf introspect.Interface) bool {
+	return len(itf.Properties) > 0 && itf.BatchPropertyChanges()
+}
+
+// hasBatchedPropertyChanges returns true if any adaptor generated for
+// introspects batches its property changes.
+func hasBatchedPropertyChanges(introspects []introspect.Introspection) bool {
+	for _, i := range introspects {
+		for _, itf := range i.Interfaces {
+			if batchesPropertyChanges(itf) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -35,10 +35,10 @@ func TestMakeMethodRetType(t *testing.T) {
 					{Name: "onlyOutput",
 						Direction: "out",
 						Type:      "ay",
-						Annotation: introspect.Annotation{
+						Annotations: []introspect.Annotation{{
 							Name:  "org.chromium.DBus.Argument.ProtobufClass",
 							Value: "MyProtobufClass",
-						},
+						}},
 					},
 				},
 				Annotations: []introspect.Annotation{
@@ -124,17 +124,17 @@ func TestMakeMethodArgs(t *testing.T) {
 					{Name: "x2",
 						Direction: "out",
 						Type:      "ay",
-						Annotation: introspect.Annotation{
+						Annotations: []introspect.Annotation{{
 							Name:  "org.chromium.DBus.Argument.ProtobufClass",
 							Value: "MyProtobufClass",
-						},
+						}},
 					},
 				},
 				Annotations: []introspect.Annotation{
 					{Name: "org.chromium.DBus.Method.Kind", Value: "async"},
 				},
 			},
-			want: []string{"std::unique_ptr<brillo::dbus_utils::DBusMethodResponse<base::ScopedFD, MyProtobufClass>> response"},
+			want: []string{"std::unique_ptr<asyncMethodMethodResponse> response"},
 		}, {
 			input: introspect.Method{
 				Name: "asyncMethodWithNoArg",
@@ -142,7 +142,7 @@ func TestMakeMethodArgs(t *testing.T) {
 					{Name: "org.chromium.DBus.Method.Kind", Value: "async"},
 				},
 			},
-			want: []string{"std::unique_ptr<brillo::dbus_utils::DBusMethodResponse<>> response"},
+			want: []string{"std::unique_ptr<asyncMethodWithNoArgMethodResponse> response"},
 		}, {
 			input: introspect.Method{
 				Name: "asyncMethodIncludingMessage",
@@ -155,7 +155,7 @@ func TestMakeMethodArgs(t *testing.T) {
 				},
 			},
 			want: []string{
-				"std::unique_ptr<brillo::dbus_utils::DBusMethodResponse<int32_t>> response",
+				"std::unique_ptr<asyncMethodIncludingMessageMethodResponse> response",
 				"dbus::Message* message",
 			},
 		}, {
@@ -181,19 +181,19 @@ func TestMakeMethodArgs(t *testing.T) {
 					{Name: "",
 						Direction: "in",
 						Type:      "ay",
-						Annotation: introspect.Annotation{
+						Annotations: []introspect.Annotation{{
 							Name:  "org.chromium.DBus.Argument.ProtobufClass",
 							Value: "MyProtobufClass",
-						},
+						}},
 					},
 					{Name: "x3", Direction: "out", Type: "h"},
 					{Name: "",
 						Direction: "out",
 						Type:      "ay",
-						Annotation: introspect.Annotation{
+						Annotations: []introspect.Annotation{{
 							Name:  "org.chromium.DBus.Argument.ProtobufClass",
 							Value: "MyProtobufClass",
-						},
+						}},
 					},
 				},
 				Annotations: []introspect.Annotation{
@@ -203,6 +203,45 @@ func TestMakeMethodArgs(t *testing.T) {
 			want: []string{
 				"const base::ScopedFD& in_x1", "const MyProtobufClass& in_2", "base::ScopedFD* out_x3", "MyProtobufClass* out_4",
 			},
+		}, {
+			input: introspect.Method{
+				Name: "methodWithDefaultValues",
+				Args: []introspect.MethodArg{
+					{Name: "x1", Direction: "in", Type: "s"},
+					{Name: "x2",
+						Direction: "in",
+						Type:      "i",
+						Annotations: []introspect.Annotation{{
+							Name:  "org.chromium.DBus.Argument.DefaultValue",
+							Value: "10",
+						}},
+					},
+				},
+				Annotations: []introspect.Annotation{
+					{Name: "org.chromium.DBus.Method.Kind", Value: "async"},
+				},
+			},
+			want: []string{
+				"std::unique_ptr<methodWithDefaultValuesMethodResponse> response", "const std::string& in_x1", "int32_t in_x2 = 10",
+			},
+		}, {
+			input: introspect.Method{
+				Name: "methodWithIgnoredDefaultValues",
+				Args: []introspect.MethodArg{
+					{Name: "x1",
+						Direction: "in",
+						Type:      "i",
+						Annotations: []introspect.Annotation{{
+							Name:  "org.chromium.DBus.Argument.DefaultValue",
+							Value: "10",
+						}},
+					},
+					{Name: "x2", Direction: "out", Type: "i"},
+				},
+			},
+			want: []string{
+				"brillo::ErrorPtr* error", "int32_t in_x1", "int32_t* out_x2",
+			},
 		},
 	}
 	for _, tc := range cases {
@@ -231,10 +270,10 @@ func TestMakeSignalParams(t *testing.T) {
 					}, {
 						Name: "",
 						Type: "ay",
-						Annotation: introspect.Annotation{
+						Annotations: []introspect.Annotation{{
 							Name:  "org.chromium.DBus.Argument.ProtobufClass",
 							Value: "MyProto",
-						},
+						}},
 					},
 				},
 			},
@@ -262,6 +301,47 @@ func TestMakeSignalParams(t *testing.T) {
 	}
 }
 
+func TestMakeMethodResponseTypes(t *testing.T) {
+	m := introspect.Method{
+		Name: "GetStatus",
+		Args: []introspect.MethodArg{
+			{Name: "verbose", Direction: "in", Type: "b"},
+			{Name: "fd", Direction: "out", Type: "h"},
+			{
+				Direction: "out",
+				Type:      "ay",
+				Annotations: []introspect.Annotation{{
+					Name:  "org.chromium.DBus.Argument.ProtobufClass",
+					Value: "MyProto",
+				}},
+			},
+			{
+				Name:      "status",
+				Direction: "out",
+				Type:      "ay",
+				Annotations: []introspect.Annotation{{
+					Name:  "org.chromium.DBus.Argument.ProtobufClass",
+					Value: "StatusProto",
+				}},
+			},
+		},
+		Annotations: []introspect.Annotation{
+			{Name: "org.chromium.DBus.Method.Kind", Value: "async"},
+		},
+	}
+	if got, want := makeMethodResponseName(m), "GetStatusMethodResponse"; got != want {
+		t.Errorf("makeMethodResponseName got %q, want %q", got, want)
+	}
+	got, err := makeMethodResponseTypes(m)
+	if err != nil {
+		t.Fatalf("makeMethodResponseTypes got error, want nil: %v", err)
+	}
+	want := []string{"base::ScopedFD /*fd*/", "MyProto", "StatusProto /*status*/"}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("makeMethodResponseTypes failed (-got +want):\n%s", diff)
+	}
+}
+
 func TestMakeDBusSignalParams(t *testing.T) {
 	cases := []struct {
 		input introspect.Signal
@@ -277,10 +357,10 @@ func TestMakeDBusSignalParams(t *testing.T) {
 					}, {
 						Name: "",
 						Type: "ay",
-						Annotation: introspect.Annotation{
+						Annotations: []introspect.Annotation{{
 							Name:  "org.chromium.DBus.Argument.ProtobufClass",
 							Value: "MyProto",
-						},
+						}},
 					},
 				},
 			},
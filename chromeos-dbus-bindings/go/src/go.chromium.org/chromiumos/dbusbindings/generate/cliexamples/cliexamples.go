@@ -0,0 +1,150 @@
+// Copyright 2022 The ChromiumOS Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+// Package cliexamples outputs ready-to-run gdbus call and dbus-send command
+// lines calling the methods of the interfaces based on introspects, for the
+// documentation and debugging.
+package cliexamples
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+
+	"go.chromium.org/chromiumos/dbusbindings/dbustype"
+	"go.chromium.org/chromiumos/dbusbindings/generate/genutil"
+	"go.chromium.org/chromiumos/dbusbindings/introspect"
+	"go.chromium.org/chromiumos/dbusbindings/serviceconfig"
+)
+
+// The placeholders of the service name and the object path, which are left
+// to the shell variables if the service config or the node does not have them.
+const (
+	serviceNamePlaceholder = `"${SERVICE_NAME}"`
+	objectPathPlaceholder  = `"${OBJECT_PATH}"`
+)
+
+const templateText = `# Automatic generation of D-Bus command line examples for:
+{{- range .Introspects}}{{range .Interfaces}}
+#  - {{.Name}}
+{{- end}}{{end}}
+# The arguments are arbitrary values of their types.
+{{- range .Methods}}
+
+# {{.Interface}}.{{.Signature}}
+{{- if .Error}}
+# {{.Error}}.
+{{- else}}
+gdbus call --system --dest {{$.ServiceName}} --object-path {{.ObjectPath}} --method {{.Interface}}.{{.Name}}
+{{- range .GVariantArgs}} "{{.}}"{{end}}
+{{- if .DBusSendError}}
+# No dbus-send example: {{.DBusSendError}}.
+{{- else}}
+dbus-send --system --print-reply --dest={{$.ServiceName}} {{.ObjectPath}} {{.Interface}}.{{.Name}}
+{{- range .DBusSendArgs}} {{.}}{{end}}
+{{- end}}
+{{- end}}
+{{- end}}
+`
+
+// example is the command lines calling a method.
+type example struct {
+	Interface  string
+	Name       string
+	ObjectPath string
+	// Signature is the method name followed by its typed "in" arguments.
+	Signature string
+	// Error tells why the method cannot be called on the command line.
+	Error         string
+	GVariantArgs  []string
+	DBusSendArgs  []string
+	DBusSendError string
+}
+
+// makeExample returns the command lines calling m of itf on the node at
+// objectPath.
+func makeExample(itf introspect.Interface, m introspect.Method, objectPath string) (example, error) {
+	ret := example{Interface: itf.Name, Name: m.Name, ObjectPath: objectPath}
+	var params []string
+	for i, a := range m.InputArguments() {
+		name := a.Name
+		if name == "" {
+			name = genutil.ArgName("arg", "", i+1)
+		}
+		d, err := dbustype.Describe(string(a.Type))
+		if err != nil {
+			return example{}, fmt.Errorf("%s method %s argument: %v", m.Name, name, err)
+		}
+		if v, ok := a.Annotation("org.chromium.DBus.Argument.ProtobufClass"); ok {
+			// The empty serialization is the valid default message.
+			params = append(params, "protobuf "+v+" "+name)
+			ret.GVariantArgs = append(ret.GVariantArgs, "[]")
+			ret.DBusSendArgs = append(ret.DBusSendArgs, "array:byte:")
+			continue
+		}
+		params = append(params, d+" "+name)
+
+		g, err := dbustype.GVariantExample(string(a.Type))
+		if err != nil {
+			ret.Error = fmt.Sprintf("%s cannot be called on the command line: argument %s: %v", m.Name, name, err)
+			continue
+		}
+		ret.GVariantArgs = append(ret.GVariantArgs, g)
+		if ret.DBusSendError != "" {
+			continue
+		}
+		s, err := dbustype.DBusSendExample(string(a.Type))
+		if err != nil {
+			ret.DBusSendError = fmt.Sprintf("argument %s: %v", name, err)
+			continue
+		}
+		ret.DBusSendArgs = append(ret.DBusSendArgs, s)
+	}
+	ret.Signature = fmt.Sprintf("%s(%s)", m.Name, strings.Join(params, ", "))
+	return ret, nil
+}
+
+// Generate outputs, for each method of the interfaces in introspects, a
+// comment with its typed signature followed by the gdbus call and dbus-send
+// command lines calling it on the system bus with arbitrary arguments. The
+// service name is taken from config, and the object paths from the nodes.
+// The missing ones are left to the SERVICE_NAME and OBJECT_PATH shell
+// variables.
+func Generate(introspects []introspect.Introspection, f io.Writer, config serviceconfig.Config) error {
+	serviceName := serviceNamePlaceholder
+	if config.ServiceName != "" {
+		serviceName = config.ServiceName
+	}
+	var examples []example
+	for _, is := range introspects {
+		objectPath := objectPathPlaceholder
+		if is.Name != "" {
+			objectPath = is.Name
+		}
+		for _, itf := range is.Interfaces {
+			for _, m := range itf.Methods {
+				e, err := makeExample(itf, m, objectPath)
+				if err != nil {
+					return fmt.Errorf("%s interface: %v", itf.Name, err)
+				}
+				examples = append(examples, e)
+			}
+		}
+	}
+
+	tmpl, err := template.New("cliExamples").Parse(templateText)
+	if err != nil {
+		return err
+	}
+	return tmpl.Execute(f, struct {
+		Introspects []introspect.Introspection
+		ServiceName string
+		Methods     []example
+	}{
+		Introspects: introspects,
+		ServiceName: serviceName,
+		Methods:     examples,
+	})
+}
@@ -0,0 +1,98 @@
+// Copyright 2022 The ChromiumOS Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package cliexamples
+
+import (
+	"bytes"
+	"testing"
+
+	"go.chromium.org/chromiumos/dbusbindings/introspect"
+	"go.chromium.org/chromiumos/dbusbindings/serviceconfig"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGenerate(t *testing.T) {
+	introspections := []introspect.Introspection{{
+		Name: "/org/chromium/Frobinator",
+		Interfaces: []introspect.Interface{{
+			Name: "org.chromium.Frobinator",
+			Methods: []introspect.Method{
+				{
+					Name: "Frobinate",
+					Args: []introspect.MethodArg{
+						{Name: "foo", Type: "i"},
+						{Name: "names", Type: "as"},
+						{Name: "bar", Type: "s", Direction: "out"},
+					},
+				},
+				{
+					Name: "Reset",
+				},
+				{
+					Name: "Configure",
+					Args: []introspect.MethodArg{
+						{
+							Name:        "config",
+							Type:        "ay",
+							Annotations: []introspect.Annotation{{Name: "org.chromium.DBus.Argument.ProtobufClass", Value: "frobinator::Config"}},
+						},
+						{Name: "options", Type: "a{sv}"},
+					},
+				},
+				{
+					Name: "Attach",
+					Args: []introspect.MethodArg{
+						{Name: "fd", Type: "h"},
+					},
+				},
+			},
+		}},
+	}, {
+		Interfaces: []introspect.Interface{{
+			Name: "org.chromium.Device",
+			Methods: []introspect.Method{
+				{
+					Name: "Move",
+					Args: []introspect.MethodArg{
+						{Type: "(ii)"},
+					},
+				},
+			},
+		}},
+	}}
+
+	out := new(bytes.Buffer)
+	if err := Generate(introspections, out, serviceconfig.Config{ServiceName: "org.chromium.Frobinator"}); err != nil {
+		t.Fatalf("Generate got error, want nil: %v", err)
+	}
+	const want = `# Automatic generation of D-Bus command line examples for:
+#  - org.chromium.Frobinator
+#  - org.chromium.Device
+# The arguments are arbitrary values of their types.
+
+# org.chromium.Frobinator.Frobinate(int32 foo, array of string names)
+gdbus call --system --dest org.chromium.Frobinator --object-path /org/chromium/Frobinator --method org.chromium.Frobinator.Frobinate "1" "['example']"
+dbus-send --system --print-reply --dest=org.chromium.Frobinator /org/chromium/Frobinator org.chromium.Frobinator.Frobinate int32:1 array:string:example
+
+# org.chromium.Frobinator.Reset()
+gdbus call --system --dest org.chromium.Frobinator --object-path /org/chromium/Frobinator --method org.chromium.Frobinator.Reset
+dbus-send --system --print-reply --dest=org.chromium.Frobinator /org/chromium/Frobinator org.chromium.Frobinator.Reset
+
+# org.chromium.Frobinator.Configure(protobuf frobinator::Config config, dict<string, variant> options)
+gdbus call --system --dest org.chromium.Frobinator --object-path /org/chromium/Frobinator --method org.chromium.Frobinator.Configure "[]" "{'example': <1>}"
+# No dbus-send example: argument options: dbus-send cannot pass dict<string, variant>.
+
+# org.chromium.Frobinator.Attach(file descriptor fd)
+# Attach cannot be called on the command line: argument fd: file descriptors cannot be passed.
+
+# org.chromium.Device.Move(struct<int32, int32> arg_1)
+gdbus call --system --dest org.chromium.Frobinator --object-path "${OBJECT_PATH}" --method org.chromium.Device.Move "(1, 1)"
+# No dbus-send example: argument arg_1: dbus-send cannot pass struct<int32, int32>.
+`
+	if diff := cmp.Diff(out.String(), want); diff != "" {
+		t.Errorf("Generate failed (-got +want):\n%s", diff)
+	}
+}
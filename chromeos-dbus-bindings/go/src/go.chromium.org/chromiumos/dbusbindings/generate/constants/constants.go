@@ -0,0 +1,109 @@
+// Copyright 2022 The ChromiumOS Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+// Package constants outputs a dbus-constants.h style header based on introspects.
+package constants
+
+import (
+	"io"
+	"strings"
+	"text/template"
+
+	"go.chromium.org/chromiumos/dbusbindings/generate/genutil"
+	"go.chromium.org/chromiumos/dbusbindings/introspect"
+	"go.chromium.org/chromiumos/dbusbindings/serviceconfig"
+)
+
+var funcMap = template.FuncMap{
+	"makeErrorNames": makeErrorNames,
+	"makeTypeName":   genutil.MakeTypeName,
+	"reverse":        genutil.Reverse,
+	"split":          strings.Split,
+}
+
+const templateText = `// Automatic generation of D-Bus constants for:
+{{range .Introspects}}{{range .Interfaces -}}
+//  - {{.Name}}
+{{end}}{{end -}}
+#ifndef {{.HeaderGuard}}
+#define {{.HeaderGuard}}
+{{range $introspect := .Introspects}}{{range $itf := .Interfaces}}
+{{range split $itf.Name "." -}}
+namespace {{.}} {
+{{end -}}
+const char kInterfaceName[] = "{{$itf.Name}}";
+{{- if $.ServiceName}}
+const char kServiceName[] = "{{$.ServiceName}}";
+{{- end}}
+{{- if $introspect.Name}}
+const char kObjectPath[] = "{{$introspect.Name}}";
+{{- end}}
+{{- if $itf.Methods}}
+
+// Methods.
+{{- range $itf.Methods}}
+const char k{{.Name}}Method[] = "{{.Name}}";
+{{- end}}
+{{- end}}
+{{- if $itf.Signals}}
+
+// Signals.
+{{- range $itf.Signals}}
+const char k{{.Name}}Signal[] = "{{.Name}}";
+{{- end}}
+{{- end}}
+{{- if $itf.Properties}}
+
+// Properties.
+{{- range $itf.Properties}}
+const char k{{.Name}}Property[] = "{{.Name}}";
+{{- end}}
+{{- end}}
+{{- with makeErrorNames $itf}}
+
+// Errors.
+{{- range .}}
+const char k{{makeTypeName .}}Error[] = "{{.}}";
+{{- end}}
+{{- end}}
+{{range split $itf.Name "." | reverse -}}
+}  // namespace {{.}}
+{{end -}}
+{{end}}{{end}}
+#endif  // {{.HeaderGuard}}
+`
+
+// makeErrorNames returns the error names that the methods of itf may reply
+// with, without duplicates, in the order of their first appearance.
+func makeErrorNames(itf introspect.Interface) []string {
+	var ret []string
+	seen := make(map[string]bool)
+	for _, m := range itf.Methods {
+		for _, e := range m.Errors() {
+			if !seen[e] {
+				seen[e] = true
+				ret = append(ret, e)
+			}
+		}
+	}
+	return ret
+}
+
+// Generate prints the constants for the interfaces included in introspects.
+// outputFilePath is used to make a unique header guard.
+func Generate(introspects []introspect.Introspection, f io.Writer, outputFilePath string, config serviceconfig.Config) error {
+	tmpl, err := template.New("constants").Funcs(funcMap).Parse(templateText)
+	if err != nil {
+		return err
+	}
+	return tmpl.Execute(f, struct {
+		Introspects []introspect.Introspection
+		HeaderGuard string
+		ServiceName string
+	}{
+		Introspects: introspects,
+		HeaderGuard: genutil.GenerateHeaderGuard(outputFilePath),
+		ServiceName: config.ServiceName,
+	})
+}
@@ -0,0 +1,134 @@
+// Copyright 2022 The ChromiumOS Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package constants
+
+import (
+	"bytes"
+	"testing"
+
+	"go.chromium.org/chromiumos/dbusbindings/introspect"
+	"go.chromium.org/chromiumos/dbusbindings/serviceconfig"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGenerateConstants(t *testing.T) {
+	introspections := []introspect.Introspection{
+		{
+			Name: "/org/chromium/Test",
+			Interfaces: []introspect.Interface{
+				{
+					Name: "org.chromium.Test",
+					Methods: []introspect.Method{
+						{
+							Name: "Scan",
+							Annotations: []introspect.Annotation{
+								{
+									Name:  "org.chromium.DBus.Method.Errors",
+									Value: "org.chromium.Test.Error.Busy org.chromium.Test.Error.Failed",
+								},
+							},
+						}, {
+							Name: "Stop",
+							Annotations: []introspect.Annotation{
+								{
+									Name:  "org.chromium.DBus.Method.Errors",
+									Value: "org.chromium.Test.Error.Failed",
+								},
+							},
+						},
+					},
+					Signals: []introspect.Signal{
+						{Name: "ScanDone"},
+					},
+					Properties: []introspect.Property{
+						{Name: "Scanning", Type: "b", Access: "read"},
+					},
+				},
+			},
+		}, {
+			Interfaces: []introspect.Interface{
+				{Name: "org.chromium.Empty"},
+			},
+		},
+	}
+
+	sc := serviceconfig.Config{ServiceName: "org.chromium.TestService"}
+	out := new(bytes.Buffer)
+	if err := Generate(introspections, out, "/tmp/dbus-constants.h", sc); err != nil {
+		t.Fatalf("Generate got error, want nil: %v", err)
+	}
+
+	const want = `// Automatic generation of D-Bus constants for:
+//  - org.chromium.Test
+//  - org.chromium.Empty
+#ifndef ____CHROMEOS_DBUS_BINDING___TMP_DBUS_CONSTANTS_H
+#define ____CHROMEOS_DBUS_BINDING___TMP_DBUS_CONSTANTS_H
+
+namespace org {
+namespace chromium {
+namespace Test {
+const char kInterfaceName[] = "org.chromium.Test";
+const char kServiceName[] = "org.chromium.TestService";
+const char kObjectPath[] = "/org/chromium/Test";
+
+// Methods.
+const char kScanMethod[] = "Scan";
+const char kStopMethod[] = "Stop";
+
+// Signals.
+const char kScanDoneSignal[] = "ScanDone";
+
+// Properties.
+const char kScanningProperty[] = "Scanning";
+
+// Errors.
+const char kBusyError[] = "org.chromium.Test.Error.Busy";
+const char kFailedError[] = "org.chromium.Test.Error.Failed";
+}  // namespace Test
+}  // namespace chromium
+}  // namespace org
+
+namespace org {
+namespace chromium {
+namespace Empty {
+const char kInterfaceName[] = "org.chromium.Empty";
+const char kServiceName[] = "org.chromium.TestService";
+}  // namespace Empty
+}  // namespace chromium
+}  // namespace org
+
+#endif  // ____CHROMEOS_DBUS_BINDING___TMP_DBUS_CONSTANTS_H
+`
+
+	if diff := cmp.Diff(out.String(), want); diff != "" {
+		t.Errorf("Generate failed (-got +want):\n%s", diff)
+	}
+}
+
+func TestMakeErrorNames(t *testing.T) {
+	itf := introspect.Interface{
+		Name: "org.chromium.Test",
+		Methods: []introspect.Method{
+			{
+				Name: "A",
+				Annotations: []introspect.Annotation{
+					{Name: "org.chromium.DBus.Method.Errors", Value: "x.E2 x.E1"},
+				},
+			}, {
+				Name: "B",
+				Annotations: []introspect.Annotation{
+					{Name: "org.chromium.DBus.Method.Errors", Value: "x.E1 x.E3"},
+				},
+			}, {
+				Name: "C",
+			},
+		},
+	}
+	want := []string{"x.E2", "x.E1", "x.E3"}
+	if diff := cmp.Diff(makeErrorNames(itf), want); diff != "" {
+		t.Errorf("makeErrorNames failed (-got +want):\n%s", diff)
+	}
+}
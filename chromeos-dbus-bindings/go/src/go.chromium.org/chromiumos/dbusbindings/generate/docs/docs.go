@@ -0,0 +1,379 @@
+// Copyright 2022 The ChromiumOS Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+// Package docs outputs the API reference of the D-Bus interfaces based on
+// introspects, in Markdown or HTML, so that the documentation can be
+// published straight from the interface files.
+package docs
+
+import (
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"strings"
+	"text/template"
+
+	"go.chromium.org/chromiumos/dbusbindings/dbustype"
+	"go.chromium.org/chromiumos/dbusbindings/generate/genutil"
+	"go.chromium.org/chromiumos/dbusbindings/introspect"
+)
+
+// headerTemplateText is the comment at the top of both Markdown and HTML.
+// It is executed separately since html/template drops comments.
+const headerTemplateText = `<!-- Automatic generation of D-Bus API reference for:
+{{range .Interfaces}}  - {{.Name}}
+{{end}}-->
+`
+
+const markdownTemplateText = `
+# D-Bus API reference
+{{range .Interfaces}}
+## {{.Name}}
+{{- if .ObjectPath}}
+
+Object path: ` + "`{{.ObjectPath}}`" + `
+{{- end}}
+{{- if .Doc}}
+
+{{.Doc}}
+{{- end}}
+{{- template "annotations" .Annotations}}
+{{- if .Methods}}
+
+### Methods
+{{- range .Methods}}
+
+#### {{.Name}}
+
+` + "```" + `
+{{.Signature}}
+` + "```" + `
+{{- if .Doc}}
+
+{{.Doc}}
+{{- end}}
+{{- template "args" .}}
+{{- template "annotations" .Annotations}}
+{{- end}}
+{{- end}}
+{{- if .Signals}}
+
+### Signals
+{{- range .Signals}}
+
+#### {{.Name}}
+
+` + "```" + `
+{{.Signature}}
+` + "```" + `
+{{- if .Doc}}
+
+{{.Doc}}
+{{- end}}
+{{- template "args" .}}
+{{- template "annotations" .Annotations}}
+{{- end}}
+{{- end}}
+{{- if .Properties}}
+
+### Properties
+{{- range .Properties}}
+
+#### {{.Name}}
+
+` + "`{{.Type}}` (`{{.Signature}}`), {{.Access}}" + `
+{{- if .Doc}}
+
+{{.Doc}}
+{{- end}}
+{{- template "annotations" .Annotations}}
+{{- end}}
+{{- end}}
+{{end -}}
+
+{{- define "args"}}
+{{- if .Args}}
+
+| Argument |{{if .Directions}} Direction |{{end}} Type | Signature |
+|---|{{if .Directions}}---|{{end}}---|---|
+{{- range .Args}}
+| ` + "`{{.Name}}`" + ` |{{if $.Directions}} {{.Direction}} |{{end}} ` + "`{{.Type}}`" + ` | ` + "`{{.Signature}}`" + ` |
+{{- end}}
+{{- end}}
+{{- end}}
+
+{{- define "annotations"}}
+{{- if .}}
+
+Annotations:
+{{range .}}
+- ` + "`{{.Name}}`: `{{.Value}}`" + `
+{{- end}}
+{{- end}}
+{{- end}}`
+
+const htmlTemplateText = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>D-Bus API reference</title>
+<style>
+.doc { white-space: pre-line; }
+</style>
+</head>
+<body>
+<h1>D-Bus API reference</h1>
+{{- range .Interfaces}}
+<h2 id="{{.Name}}">{{.Name}}</h2>
+{{- if .ObjectPath}}
+<p>Object path: <code>{{.ObjectPath}}</code></p>
+{{- end}}
+{{- if .Doc}}
+<p class="doc">{{.Doc}}</p>
+{{- end}}
+{{- template "annotations" .Annotations}}
+{{- if .Methods}}
+<h3>Methods</h3>
+{{- range .Methods}}
+{{- template "member" .}}
+{{- end}}
+{{- end}}
+{{- if .Signals}}
+<h3>Signals</h3>
+{{- range .Signals}}
+{{- template "member" .}}
+{{- end}}
+{{- end}}
+{{- if .Properties}}
+<h3>Properties</h3>
+{{- range .Properties}}
+<h4>{{.Name}}</h4>
+<p><code>{{.Type}}</code> (<code>{{.Signature}}</code>), {{.Access}}</p>
+{{- if .Doc}}
+<p class="doc">{{.Doc}}</p>
+{{- end}}
+{{- template "annotations" .Annotations}}
+{{- end}}
+{{- end}}
+{{- end}}
+</body>
+</html>
+
+{{- define "member"}}
+<h4>{{.Name}}</h4>
+<pre>{{.Signature}}</pre>
+{{- if .Doc}}
+<p class="doc">{{.Doc}}</p>
+{{- end}}
+{{- if .Args}}
+<table>
+<tr><th>Argument</th>{{if .Directions}}<th>Direction</th>{{end}}<th>Type</th><th>Signature</th></tr>
+{{- range .Args}}
+<tr><td><code>{{.Name}}</code></td>{{if $.Directions}}<td>{{.Direction}}</td>{{end}}<td><code>{{.Type}}</code></td><td><code>{{.Signature}}</code></td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{- template "annotations" .Annotations}}
+{{- end}}
+
+{{- define "annotations"}}
+{{- if .}}
+<p>Annotations:</p>
+<ul>
+{{- range .}}
+<li><code>{{.Name}}</code>: <code>{{.Value}}</code></li>
+{{- end}}
+</ul>
+{{- end}}
+{{- end}}
+`
+
+// reference is the API reference of the interfaces.
+type reference struct {
+	Interfaces []interfaceDoc
+}
+
+// interfaceDoc is the reference of an interface.
+type interfaceDoc struct {
+	Name string
+	// ObjectPath is the path of the node implementing the interface, if
+	// the node is named.
+	ObjectPath  string
+	Doc         string
+	Annotations []introspect.Annotation
+	Methods     []memberDoc
+	Signals     []memberDoc
+	Properties  []propertyDoc
+}
+
+// memberDoc is the reference of a method or a signal.
+type memberDoc struct {
+	Name string
+	// Signature is the typed signature, e.g.
+	// "Ping(string request) -> (int32 count)".
+	Signature string
+	Doc       string
+	// Directions tells whether Args have directions, i.e. it is a method.
+	Directions  bool
+	Args        []argDoc
+	Annotations []introspect.Annotation
+}
+
+// argDoc is the reference of an argument.
+type argDoc struct {
+	Name      string
+	Direction string
+	// Type is the human-readable description of the type, or the protobuf
+	// class given by the org.chromium.DBus.Argument.ProtobufClass annotation.
+	Type      string
+	Signature string
+}
+
+// propertyDoc is the reference of a property.
+type propertyDoc struct {
+	Name        string
+	Type        string
+	Signature   string
+	Access      string
+	Doc         string
+	Annotations []introspect.Annotation
+}
+
+// makeDoc returns the doc string as a plain text.
+func makeDoc(d introspect.DocString) string {
+	return strings.Join(genutil.DocStringLines(d), "\n")
+}
+
+// makeArg returns the reference of the argument at index i. Unnamed
+// arguments are named after their indices.
+func makeArg(i int, name, direction, typ string, annotations []introspect.Annotation) (argDoc, error) {
+	if name == "" {
+		name = genutil.ArgName("arg", "", i+1)
+	}
+	ret := argDoc{Name: name, Direction: direction, Signature: typ}
+	for _, a := range annotations {
+		if a.Name == "org.chromium.DBus.Argument.ProtobufClass" {
+			ret.Type = "protobuf " + a.Value
+			return ret, nil
+		}
+	}
+	t, err := dbustype.Describe(typ)
+	if err != nil {
+		return argDoc{}, err
+	}
+	ret.Type = t
+	return ret, nil
+}
+
+// makeSignature returns the typed signature of the member named name taking
+// in and, if it is a method, returning out.
+func makeSignature(name string, in, out []argDoc, method bool) string {
+	format := func(args []argDoc) string {
+		var ret []string
+		for _, a := range args {
+			ret = append(ret, a.Type+" "+a.Name)
+		}
+		return strings.Join(ret, ", ")
+	}
+	ret := name + "(" + format(in) + ")"
+	if method {
+		ret += " -> (" + format(out) + ")"
+	}
+	return ret
+}
+
+// makeReference converts the interfaces in introspects into their
+// references.
+func makeReference(introspects []introspect.Introspection) (*reference, error) {
+	ret := &reference{}
+	for _, is := range introspects {
+		for _, itf := range is.Interfaces {
+			d := interfaceDoc{
+				Name:        itf.Name,
+				ObjectPath:  is.Name,
+				Doc:         makeDoc(itf.DocString),
+				Annotations: itf.Annotations,
+			}
+			for _, m := range itf.Methods {
+				md := memberDoc{Name: m.Name, Doc: makeDoc(m.DocString), Directions: true, Annotations: m.Annotations}
+				var in, out []argDoc
+				for i, a := range m.Args {
+					dir := a.Direction
+					if dir == "" {
+						dir = "in"
+					}
+					ad, err := makeArg(i, a.Name, dir, string(a.Type), a.Annotations)
+					if err != nil {
+						return nil, fmt.Errorf("%s interface: %s method: %v", itf.Name, m.Name, err)
+					}
+					if dir == "in" {
+						in = append(in, ad)
+					} else {
+						out = append(out, ad)
+					}
+					md.Args = append(md.Args, ad)
+				}
+				md.Signature = makeSignature(m.Name, in, out, true)
+				d.Methods = append(d.Methods, md)
+			}
+			for _, s := range itf.Signals {
+				sd := memberDoc{Name: s.Name, Doc: makeDoc(s.DocString), Annotations: s.Annotations}
+				for i, a := range s.Args {
+					ad, err := makeArg(i, a.Name, "", a.Type, a.Annotations)
+					if err != nil {
+						return nil, fmt.Errorf("%s interface: %s signal: %v", itf.Name, s.Name, err)
+					}
+					sd.Args = append(sd.Args, ad)
+				}
+				sd.Signature = makeSignature(s.Name, sd.Args, nil, false)
+				d.Signals = append(d.Signals, sd)
+			}
+			for _, p := range itf.Properties {
+				t, err := dbustype.Describe(p.Type)
+				if err != nil {
+					return nil, fmt.Errorf("%s interface: %s property: %v", itf.Name, p.Name, err)
+				}
+				pd := propertyDoc{Name: p.Name, Type: t, Signature: p.Type, Access: p.Access, Doc: makeDoc(p.DocString), Annotations: p.Annotations}
+				d.Properties = append(d.Properties, pd)
+			}
+			ret.Interfaces = append(ret.Interfaces, d)
+		}
+	}
+	return ret, nil
+}
+
+// The templates are parsed once, and executed by every Generate and
+// GenerateHTML call.
+var (
+	headerTemplate   = template.Must(template.New("header").Parse(headerTemplateText))
+	markdownTemplate = template.Must(template.New("markdown").Parse(markdownTemplateText))
+	htmlTemplate     = htmltemplate.Must(htmltemplate.New("html").Parse(htmlTemplateText))
+)
+
+// Generate outputs the API reference of the interfaces included in
+// introspects into f in Markdown. Each interface is a section listing its
+// methods and signals with their typed signatures and arguments, and its
+// properties, together with their doc strings and annotations.
+func Generate(introspects []introspect.Introspection, f io.Writer) error {
+	r, err := makeReference(introspects)
+	if err != nil {
+		return err
+	}
+	if err := headerTemplate.Execute(f, r); err != nil {
+		return err
+	}
+	return markdownTemplate.Execute(f, r)
+}
+
+// GenerateHTML is Generate which outputs a standalone HTML page instead.
+func GenerateHTML(introspects []introspect.Introspection, f io.Writer) error {
+	r, err := makeReference(introspects)
+	if err != nil {
+		return err
+	}
+	if err := headerTemplate.Execute(f, r); err != nil {
+		return err
+	}
+	return htmlTemplate.Execute(f, r)
+}
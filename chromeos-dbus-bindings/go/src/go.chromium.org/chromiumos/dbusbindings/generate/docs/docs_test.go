@@ -0,0 +1,225 @@
+// Copyright 2022 The ChromiumOS Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package docs
+
+import (
+	"bytes"
+	"testing"
+
+	"go.chromium.org/chromiumos/dbusbindings/introspect"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var introspections = []introspect.Introspection{{
+	Name: "/org/chromium/Frobinator",
+	Interfaces: []introspect.Interface{{
+		Name: "org.chromium.Frobinator",
+		DocString: `
+      Frobinates things.
+        Indented line.
+    `,
+		Annotations: []introspect.Annotation{
+			{Name: "org.freedesktop.DBus.Deprecated", Value: "true"},
+		},
+		Methods: []introspect.Method{
+			{
+				Name: "Frobinate",
+				Args: []introspect.MethodArg{
+					{Name: "foo", Type: "i"},
+					{Name: "config", Type: "ay", Direction: "in",
+						Annotations: []introspect.Annotation{{Name: "org.chromium.DBus.Argument.ProtobufClass", Value: "frobinator::Config"}}},
+					{Name: "bar", Type: "s", Direction: "out"},
+					{Type: "a(ou)", Direction: "out"},
+				},
+				Annotations: []introspect.Annotation{
+					{Name: "org.chromium.DBus.Method.Kind", Value: "async"},
+				},
+				DocString: "Frobinates the foo.",
+			},
+			{
+				Name: "Reset",
+			},
+		},
+		Signals: []introspect.Signal{
+			{
+				Name: "Frobinated",
+				Args: []introspect.SignalArg{
+					{Name: "count", Type: "u"},
+					{Type: "a{sv}"},
+				},
+				DocString: "Sent when <something> is frobinated.",
+			},
+		},
+		Properties: []introspect.Property{
+			{
+				Name:      "Level",
+				Type:      "x",
+				Access:    "readwrite",
+				DocString: "The level.",
+			},
+		},
+	}},
+}}
+
+func TestGenerate(t *testing.T) {
+	out := new(bytes.Buffer)
+	if err := Generate(introspections, out); err != nil {
+		t.Fatalf("Generate got error, want nil: %v", err)
+	}
+
+	const want = `<!-- Automatic generation of D-Bus API reference for:
+  - org.chromium.Frobinator
+-->
+
+# D-Bus API reference
+
+## org.chromium.Frobinator
+
+Object path: ` + "`" + `/org/chromium/Frobinator` + "`" + `
+
+Frobinates things.
+  Indented line.
+
+Annotations:
+
+- ` + "`" + `org.freedesktop.DBus.Deprecated` + "`" + `: ` + "`" + `true` + "`" + `
+
+### Methods
+
+#### Frobinate
+
+` + "`" + `` + "`" + `` + "`" + `
+Frobinate(int32 foo, protobuf frobinator::Config config) -> (string bar, array of struct<object path, uint32> arg_4)
+` + "`" + `` + "`" + `` + "`" + `
+
+Frobinates the foo.
+
+| Argument | Direction | Type | Signature |
+|---|---|---|---|
+| ` + "`" + `foo` + "`" + ` | in | ` + "`" + `int32` + "`" + ` | ` + "`" + `i` + "`" + ` |
+| ` + "`" + `config` + "`" + ` | in | ` + "`" + `protobuf frobinator::Config` + "`" + ` | ` + "`" + `ay` + "`" + ` |
+| ` + "`" + `bar` + "`" + ` | out | ` + "`" + `string` + "`" + ` | ` + "`" + `s` + "`" + ` |
+| ` + "`" + `arg_4` + "`" + ` | out | ` + "`" + `array of struct<object path, uint32>` + "`" + ` | ` + "`" + `a(ou)` + "`" + ` |
+
+Annotations:
+
+- ` + "`" + `org.chromium.DBus.Method.Kind` + "`" + `: ` + "`" + `async` + "`" + `
+
+#### Reset
+
+` + "`" + `` + "`" + `` + "`" + `
+Reset() -> ()
+` + "`" + `` + "`" + `` + "`" + `
+
+### Signals
+
+#### Frobinated
+
+` + "`" + `` + "`" + `` + "`" + `
+Frobinated(uint32 count, dict<string, variant> arg_2)
+` + "`" + `` + "`" + `` + "`" + `
+
+Sent when <something> is frobinated.
+
+| Argument | Type | Signature |
+|---|---|---|
+| ` + "`" + `count` + "`" + ` | ` + "`" + `uint32` + "`" + ` | ` + "`" + `u` + "`" + ` |
+| ` + "`" + `arg_2` + "`" + ` | ` + "`" + `dict<string, variant>` + "`" + ` | ` + "`" + `a{sv}` + "`" + ` |
+
+### Properties
+
+#### Level
+
+` + "`" + `int64` + "`" + ` (` + "`" + `x` + "`" + `), readwrite
+
+The level.
+`
+	if diff := cmp.Diff(out.String(), want); diff != "" {
+		t.Errorf("Generate failed (-got +want):\n%s", diff)
+	}
+}
+
+func TestGenerateHTML(t *testing.T) {
+	out := new(bytes.Buffer)
+	if err := GenerateHTML(introspections, out); err != nil {
+		t.Fatalf("GenerateHTML got error, want nil: %v", err)
+	}
+
+	const want = `<!-- Automatic generation of D-Bus API reference for:
+  - org.chromium.Frobinator
+-->
+<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>D-Bus API reference</title>
+<style>
+.doc { white-space: pre-line; }
+</style>
+</head>
+<body>
+<h1>D-Bus API reference</h1>
+<h2 id="org.chromium.Frobinator">org.chromium.Frobinator</h2>
+<p>Object path: <code>/org/chromium/Frobinator</code></p>
+<p class="doc">Frobinates things.
+  Indented line.</p>
+<p>Annotations:</p>
+<ul>
+<li><code>org.freedesktop.DBus.Deprecated</code>: <code>true</code></li>
+</ul>
+<h3>Methods</h3>
+<h4>Frobinate</h4>
+<pre>Frobinate(int32 foo, protobuf frobinator::Config config) -&gt; (string bar, array of struct&lt;object path, uint32&gt; arg_4)</pre>
+<p class="doc">Frobinates the foo.</p>
+<table>
+<tr><th>Argument</th><th>Direction</th><th>Type</th><th>Signature</th></tr>
+<tr><td><code>foo</code></td><td>in</td><td><code>int32</code></td><td><code>i</code></td></tr>
+<tr><td><code>config</code></td><td>in</td><td><code>protobuf frobinator::Config</code></td><td><code>ay</code></td></tr>
+<tr><td><code>bar</code></td><td>out</td><td><code>string</code></td><td><code>s</code></td></tr>
+<tr><td><code>arg_4</code></td><td>out</td><td><code>array of struct&lt;object path, uint32&gt;</code></td><td><code>a(ou)</code></td></tr>
+</table>
+<p>Annotations:</p>
+<ul>
+<li><code>org.chromium.DBus.Method.Kind</code>: <code>async</code></li>
+</ul>
+<h4>Reset</h4>
+<pre>Reset() -&gt; ()</pre>
+<h3>Signals</h3>
+<h4>Frobinated</h4>
+<pre>Frobinated(uint32 count, dict&lt;string, variant&gt; arg_2)</pre>
+<p class="doc">Sent when &lt;something&gt; is frobinated.</p>
+<table>
+<tr><th>Argument</th><th>Type</th><th>Signature</th></tr>
+<tr><td><code>count</code></td><td><code>uint32</code></td><td><code>u</code></td></tr>
+<tr><td><code>arg_2</code></td><td><code>dict&lt;string, variant&gt;</code></td><td><code>a{sv}</code></td></tr>
+</table>
+<h3>Properties</h3>
+<h4>Level</h4>
+<p><code>int64</code> (<code>x</code>), readwrite</p>
+<p class="doc">The level.</p>
+</body>
+</html>
+`
+	if diff := cmp.Diff(out.String(), want); diff != "" {
+		t.Errorf("GenerateHTML failed (-got +want):\n%s", diff)
+	}
+}
+
+func TestGenerateInvalidType(t *testing.T) {
+	introspections := []introspect.Introspection{{
+		Interfaces: []introspect.Interface{{
+			Name: "org.chromium.Frobinator",
+			Methods: []introspect.Method{{
+				Name: "Frobinate",
+				Args: []introspect.MethodArg{{Name: "foo", Type: "a"}},
+			}},
+		}},
+	}}
+
+	if err := Generate(introspections, new(bytes.Buffer)); err == nil {
+		t.Error("Generate got nil, want error")
+	}
+}
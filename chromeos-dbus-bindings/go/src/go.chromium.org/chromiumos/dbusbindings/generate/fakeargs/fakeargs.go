@@ -0,0 +1,207 @@
+// Copyright 2022 The ChromiumOS Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+// Package fakeargs outputs a header with the helpers returning arbitrary
+// valid "in" arguments of the methods of the interfaces based on
+// introspects, for the property-based tests of the services and clients.
+package fakeargs
+
+import (
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math/rand"
+	"strings"
+	"text/template"
+
+	"go.chromium.org/chromiumos/dbusbindings/dbustype"
+	"go.chromium.org/chromiumos/dbusbindings/generate/genutil"
+	"go.chromium.org/chromiumos/dbusbindings/introspect"
+)
+
+// numSamples is the number of the argument sets returned by each helper.
+const numSamples = 4
+
+var funcMap = template.FuncMap{
+	"join":    strings.Join,
+	"reverse": genutil.Reverse,
+	"split":   strings.Split,
+}
+
+const templateText = `// Automatic generation of D-Bus fake arguments for:
+{{range .Introspects}}{{range .Interfaces -}}
+//  - {{.Name}}
+{{end}}{{end -}}
+#ifndef {{.HeaderGuard}}
+#define {{.HeaderGuard}}
+#include <cstdint>
+#include <map>
+#include <string>
+#include <tuple>
+#include <vector>
+
+#include <brillo/any.h>
+#include <brillo/variant_dictionary.h>
+#include <dbus/object_path.h>
+{{- with .ProtobufIncludes}}
+{{range .}}
+#include {{.}}
+{{- end}}
+{{- end}}
+{{range .Interfaces}}
+{{range split .Name "." -}}
+namespace {{.}} {
+{{end}}
+{{- range .Methods}}
+{{- if .Skipped}}
+// {{.Name}}() takes file descriptors, which cannot be made up.
+{{else}}
+// Returns arbitrary valid "in" arguments of {{.Name}}(), one tuple per call.
+inline std::vector<std::tuple<{{join .Types ", "}}>> Make{{.Name}}FakeArgs() {
+  return {
+{{- range .Samples}}
+      std::make_tuple({{join . ", "}}),
+{{- end}}
+  };
+}
+{{end}}
+{{- end}}
+{{range split .Name "." | reverse -}}
+}  // namespace {{.}}
+{{end -}}
+{{end}}
+#endif  // {{.HeaderGuard}}
+`
+
+// fakeMethod is a method taking "in" arguments, with the samples of them.
+type fakeMethod struct {
+	Name string
+	// Skipped tells that the method takes file descriptors.
+	Skipped bool
+	// Types are the C++ types of the "in" arguments.
+	Types []string
+	// Samples are the C++ expressions of the arguments of each sample.
+	Samples [][]string
+}
+
+// fakeInterface is an interface with the methods taking "in" arguments.
+type fakeInterface struct {
+	Name    string
+	Methods []fakeMethod
+}
+
+// newRand returns a random source seeded by name, so that the samples of a
+// method do not change as the other methods are added or removed.
+func newRand(name string) *rand.Rand {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	return rand.New(rand.NewSource(int64(h.Sum64())))
+}
+
+// makeFakeMethod returns the samples of the "in" arguments of m of itf.
+// It returns false if m takes no "in" arguments.
+func makeFakeMethod(itf introspect.Interface, m introspect.Method) (fakeMethod, bool, error) {
+	args := m.InputArguments()
+	if len(args) == 0 {
+		return fakeMethod{}, false, nil
+	}
+	ret := fakeMethod{Name: m.Name}
+	for _, a := range args {
+		if strings.Contains(string(a.Type), "h") {
+			return fakeMethod{Name: m.Name, Skipped: true}, true, nil
+		}
+		t, err := a.BaseType()
+		if err != nil {
+			return fakeMethod{}, false, err
+		}
+		ret.Types = append(ret.Types, t)
+	}
+	r := newRand(itf.Name + "." + m.Name)
+	for i := 0; i < numSamples; i++ {
+		var sample []string
+		for j, a := range args {
+			t, err := dbustype.Parse(string(a.Type))
+			if err != nil {
+				return fakeMethod{}, false, err
+			}
+			if t.BaseType() != ret.Types[j] {
+				// Protobuf messages and the types given by annotations, e.g.
+				// enum classes, are default-constructed.
+				sample = append(sample, ret.Types[j]+"{}")
+				continue
+			}
+			v, err := dbustype.RandomValueLiteral(string(a.Type), r)
+			if err != nil {
+				return fakeMethod{}, false, fmt.Errorf("%s method %s argument: %v", m.Name, a.Name, err)
+			}
+			sample = append(sample, v)
+		}
+		ret.Samples = append(ret.Samples, sample)
+	}
+	return ret, true, nil
+}
+
+// makeProtobufIncludes returns the headers listed in the ProtobufIncludes
+// annotations of the interfaces using protobuf.
+func makeProtobufIncludes(introspects []introspect.Introspection) []string {
+	var ret []string
+	seen := make(map[string]bool)
+	for _, is := range introspects {
+		for _, itf := range is.Interfaces {
+			if !itf.UsesProtobuf() {
+				continue
+			}
+			for _, inc := range itf.ProtobufIncludes() {
+				if !strings.HasPrefix(inc, "<") && !strings.HasPrefix(inc, `"`) {
+					inc = fmt.Sprintf("%q", inc)
+				}
+				if !seen[inc] {
+					seen[inc] = true
+					ret = append(ret, inc)
+				}
+			}
+		}
+	}
+	return ret
+}
+
+// Generate outputs the header containing, for each method taking "in"
+// arguments of the interfaces in introspects, a Make<Method>FakeArgs()
+// function returning arbitrary valid tuples of them. The values are drawn
+// from a random source seeded by the method name, so that the output is
+// reproducible. outputFilePath is used to make a unique header guard.
+func Generate(introspects []introspect.Introspection, f io.Writer, outputFilePath string) error {
+	var itfs []fakeInterface
+	for _, is := range introspects {
+		for _, itf := range is.Interfaces {
+			fi := fakeInterface{Name: itf.Name}
+			for _, m := range itf.Methods {
+				fm, ok, err := makeFakeMethod(itf, m)
+				if err != nil {
+					return fmt.Errorf("%s interface: %v", itf.Name, err)
+				}
+				if ok {
+					fi.Methods = append(fi.Methods, fm)
+				}
+			}
+			itfs = append(itfs, fi)
+		}
+	}
+
+	tmpl, err := template.New("fakeargs").Funcs(funcMap).Parse(templateText)
+	if err != nil {
+		return err
+	}
+	return tmpl.Execute(f, struct {
+		Introspects      []introspect.Introspection
+		Interfaces       []fakeInterface
+		HeaderGuard      string
+		ProtobufIncludes []string
+	}{
+		Introspects:      introspects,
+		Interfaces:       itfs,
+		HeaderGuard:      genutil.GenerateHeaderGuard(outputFilePath),
+		ProtobufIncludes: makeProtobufIncludes(introspects),
+	})
+}
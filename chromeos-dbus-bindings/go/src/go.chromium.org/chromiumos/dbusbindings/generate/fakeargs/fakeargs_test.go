@@ -0,0 +1,112 @@
+// Copyright 2022 The ChromiumOS Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package fakeargs
+
+import (
+	"bytes"
+	"testing"
+
+	"go.chromium.org/chromiumos/dbusbindings/introspect"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGenerate(t *testing.T) {
+	introspections := []introspect.Introspection{{
+		Interfaces: []introspect.Interface{{
+			Name: "org.chromium.Frobinator",
+			Annotations: []introspect.Annotation{
+				{Name: "org.chromium.DBus.Interface.ProtobufIncludes", Value: "frobinator/proto_bindings/frobinator.pb.h"},
+			},
+			Methods: []introspect.Method{
+				{
+					Name: "Frobinate",
+					Args: []introspect.MethodArg{
+						{Name: "foo", Type: "i"},
+						{Name: "names", Type: "as"},
+						{Name: "bar", Type: "s", Direction: "out"},
+					},
+				},
+				{
+					Name: "Reset",
+				},
+				{
+					Name: "Configure",
+					Args: []introspect.MethodArg{
+						{
+							Name:        "config",
+							Type:        "ay",
+							Annotations: []introspect.Annotation{{Name: "org.chromium.DBus.Argument.ProtobufClass", Value: "frobinator::Config"}},
+						},
+						{Name: "options", Type: "a{sv}"},
+					},
+				},
+				{
+					Name: "Attach",
+					Args: []introspect.MethodArg{
+						{Name: "fd", Type: "h"},
+					},
+				},
+			},
+		}},
+	}}
+
+	out := new(bytes.Buffer)
+	if err := Generate(introspections, out, "/tmp/fake_args.h"); err != nil {
+		t.Fatalf("Generate got error, want nil: %v", err)
+	}
+
+	const want = `// Automatic generation of D-Bus fake arguments for:
+//  - org.chromium.Frobinator
+#ifndef ____CHROMEOS_DBUS_BINDING___TMP_FAKE_ARGS_H
+#define ____CHROMEOS_DBUS_BINDING___TMP_FAKE_ARGS_H
+#include <cstdint>
+#include <map>
+#include <string>
+#include <tuple>
+#include <vector>
+
+#include <brillo/any.h>
+#include <brillo/variant_dictionary.h>
+#include <dbus/object_path.h>
+
+#include "frobinator/proto_bindings/frobinator.pb.h"
+
+namespace org {
+namespace chromium {
+namespace Frobinator {
+
+// Returns arbitrary valid "in" arguments of Frobinate(), one tuple per call.
+inline std::vector<std::tuple<int32_t, std::vector<std::string>>> MakeFrobinateFakeArgs() {
+  return {
+      std::make_tuple(int32_t{-2051140757}, std::vector<std::string>{std::string{"[HtkMZbO##"}}),
+      std::make_tuple(int32_t{1108307005}, std::vector<std::string>{std::string{".-"}, std::string{"92>'BO&KN"}}),
+      std::make_tuple(int32_t{-1785594174}, std::vector<std::string>{std::string{""}, std::string{"%#A3I$h%\\"}, std::string{"eO2(9"}}),
+      std::make_tuple(int32_t{-1493961276}, std::vector<std::string>{std::string{"{Fy8/\""}, std::string{"PO$'0,gl6."}}),
+  };
+}
+
+// Returns arbitrary valid "in" arguments of Configure(), one tuple per call.
+inline std::vector<std::tuple<frobinator::Config, brillo::VariantDictionary>> MakeConfigureFakeArgs() {
+  return {
+      std::make_tuple(frobinator::Config{}, brillo::VariantDictionary{{std::string{"()6n!tAV~"}, brillo::Any{std::string{"*Z"}}}, {std::string{""}, brillo::Any{false}}, {std::string{"\?Kj];Jv="}, brillo::Any{int16_t{-4822}}}}),
+      std::make_tuple(frobinator::Config{}, brillo::VariantDictionary{}),
+      std::make_tuple(frobinator::Config{}, brillo::VariantDictionary{{std::string{")#-v"}, brillo::Any{uint64_t{13982239785025304702ull}}}, {std::string{""}, brillo::Any{false}}, {std::string{""}, brillo::Any{std::string{""}}}}),
+      std::make_tuple(frobinator::Config{}, brillo::VariantDictionary{}),
+  };
+}
+
+// Attach() takes file descriptors, which cannot be made up.
+
+}  // namespace Frobinator
+}  // namespace chromium
+}  // namespace org
+
+#endif  // ____CHROMEOS_DBUS_BINDING___TMP_FAKE_ARGS_H
+`
+	if diff := cmp.Diff(out.String(), want); diff != "" {
+		t.Errorf("Generate failed (-got +want):\n%s", diff)
+	}
+}
@@ -0,0 +1,352 @@
+// Copyright 2022 The ChromiumOS Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+// Package fuzzer outputs libFuzzer harnesses for the adaptors based on
+// introspects. The harnesses call the methods of the interfaces implemented
+// by the services with the arguments consumed from the fuzz input.
+package fuzzer
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"text/template"
+
+	"go.chromium.org/chromiumos/dbusbindings/generate/genutil"
+	"go.chromium.org/chromiumos/dbusbindings/introspect"
+	"go.chromium.org/chromiumos/dbusbindings/serviceconfig"
+)
+
+var funcMap = template.FuncMap{
+	"dec":               func(i int) int { return i - 1 },
+	"join":              strings.Join,
+	"makeFuzzMethods":   makeFuzzMethods,
+	"makeInterfaceName": genutil.MakeInterfaceName,
+	"repeat":            strings.Repeat,
+	"reverse":           genutil.Reverse,
+}
+
+const templateText = `// Automatic generation of D-Bus adaptor fuzzers for:
+{{range .Introspects}}{{range .Interfaces -}}
+//  - {{.Name}}
+{{end}}{{end -}}
+
+#ifndef {{.HeaderGuard}}
+#define {{.HeaderGuard}}
+#include <cstddef>
+#include <map>
+#include <memory>
+#include <optional>
+#include <string>
+#include <tuple>
+#include <type_traits>
+#include <utility>
+#include <vector>
+
+#include <base/functional/bind.h>
+#include <base/functional/callback_helpers.h>
+#include <brillo/dbus/dbus_method_response.h>
+#include <brillo/dbus/dbus_param_writer.h>
+#include <brillo/errors/error.h>
+#include <dbus/message.h>
+#include <dbus/object_path.h>
+#include <fuzzer/FuzzedDataProvider.h>
+
+#include "{{.AdaptorFilePath}}"
+
+#ifndef CHROMEOS_DBUS_BINDINGS_DBUS_FUZZ_VALUES_
+#define CHROMEOS_DBUS_BINDINGS_DBUS_FUZZ_VALUES_
+namespace chromeos_dbus_bindings {
+
+// The maximum number of elements of the arrays and dictionaries consumed
+// from the fuzz input.
+inline constexpr size_t kMaxFuzzElements = 16;
+
+template <typename T, typename = void>
+struct IsFuzzProtobuf : std::false_type {};
+
+template <typename T>
+struct IsFuzzProtobuf<T,
+                      std::void_t<decltype(std::declval<T&>().ParseFromString(
+                          std::declval<const std::string&>()))>>
+    : std::true_type {};
+
+// Consumes a value of the C++ type of a D-Bus argument from the fuzz input.
+// The values of brillo::Any, base::ScopedFD and the named structs are
+// default constructed.
+template <typename T>
+struct FuzzValue {
+  static T Consume(FuzzedDataProvider* provider) {
+    if constexpr (std::is_same_v<T, bool>) {
+      return provider->ConsumeBool();
+    } else if constexpr (std::is_enum_v<T>) {
+      return static_cast<T>(
+          provider->ConsumeIntegral<std::underlying_type_t<T>>());
+    } else if constexpr (std::is_integral_v<T>) {
+      return provider->ConsumeIntegral<T>();
+    } else if constexpr (std::is_floating_point_v<T>) {
+      return provider->ConsumeFloatingPoint<T>();
+    } else {
+      T value{};
+      if constexpr (IsFuzzProtobuf<T>::value) {
+        value.ParseFromString(provider->ConsumeRandomLengthString());
+      }
+      return value;
+    }
+  }
+};
+
+template <>
+struct FuzzValue<std::string> {
+  static std::string Consume(FuzzedDataProvider* provider) {
+    return provider->ConsumeRandomLengthString();
+  }
+};
+
+template <>
+struct FuzzValue<dbus::ObjectPath> {
+  static dbus::ObjectPath Consume(FuzzedDataProvider* provider) {
+    return dbus::ObjectPath(provider->ConsumeRandomLengthString());
+  }
+};
+
+template <typename T>
+struct FuzzValue<std::optional<T>> {
+  static std::optional<T> Consume(FuzzedDataProvider* provider) {
+    if (!provider->ConsumeBool())
+      return std::nullopt;
+    return FuzzValue<T>::Consume(provider);
+  }
+};
+
+template <typename T>
+struct FuzzValue<std::vector<T>> {
+  static std::vector<T> Consume(FuzzedDataProvider* provider) {
+    std::vector<T> values(
+        provider->ConsumeIntegralInRange<size_t>(0, kMaxFuzzElements));
+    for (auto& value : values)
+      value = FuzzValue<T>::Consume(provider);
+    return values;
+  }
+};
+
+template <typename K, typename V>
+struct FuzzValue<std::map<K, V>> {
+  static std::map<K, V> Consume(FuzzedDataProvider* provider) {
+    std::map<K, V> values;
+    size_t size = provider->ConsumeIntegralInRange<size_t>(0, kMaxFuzzElements);
+    for (size_t i = 0; i < size; ++i) {
+      K key = FuzzValue<K>::Consume(provider);
+      values.emplace(std::move(key), FuzzValue<V>::Consume(provider));
+    }
+    return values;
+  }
+};
+
+template <typename... Ts>
+struct FuzzValue<std::tuple<Ts...>> {
+  static std::tuple<Ts...> Consume(FuzzedDataProvider* provider) {
+    // The braced initializer consumes the elements in order.
+    return std::tuple<Ts...>{FuzzValue<Ts>::Consume(provider)...};
+  }
+};
+
+// Returns a method call of the method of the interface, which can be
+// replied to.
+inline std::unique_ptr<dbus::MethodCall> MakeFuzzMethodCall(
+    const std::string& interface_name, const std::string& method_name) {
+  auto method_call =
+      std::make_unique<dbus::MethodCall>(interface_name, method_name);
+  method_call->SetSerial(1);
+  return method_call;
+}
+
+}  // namespace chromeos_dbus_bindings
+#endif  // CHROMEOS_DBUS_BINDINGS_DBUS_FUZZ_VALUES_
+{{range .Introspects}}{{range $itf := .Interfaces}}
+{{- if .Methods}}
+{{- $itfName := makeInterfaceName .Name}}
+{{- $methods := makeFuzzMethods .}}
+{{range extractNameSpaces .Name -}}
+namespace {{.}} {
+{{end}}
+// Calls the methods of |impl| with the arguments consumed from |provider|
+// until the fuzz input runs out. The replies of the asynchronous and raw
+// methods are dropped.
+inline void Fuzz{{$itfName}}({{$itfName}}* impl,
+{{repeat " " (len $itfName)}}                 FuzzedDataProvider* provider) {
+  while (provider->remaining_bytes() > 0) {
+    switch (provider->ConsumeIntegralInRange<int>(0, {{len $methods | dec}})) {
+{{- range $i, $m := $methods}}
+      case {{$i}}: {
+{{- range .Ins}}
+        auto {{.Name}} =
+            chromeos_dbus_bindings::FuzzValue<{{.Type}}>::Consume(provider);
+{{- end}}
+{{- if .MethodCall}}
+        std::unique_ptr<dbus::MethodCall> method_call =
+            chromeos_dbus_bindings::MakeFuzzMethodCall("{{$itf.Name}}",
+                                                       "{{.Name}}");
+{{- end}}
+{{- if and .Raw .Ins}}
+        dbus::MessageWriter writer(method_call.get());
+        brillo::dbus_utils::DBusParamWriter::Append(
+            &writer{{range .Ins}}, {{.Name}}{{end}});
+{{- end}}
+{{- if .ResponseType}}
+        dbus::MethodCall* method_call_ptr = method_call.get();
+        auto response = std::make_unique<
+            brillo::dbus_utils::DBusMethodResponse<{{.ResponseType}}>>(
+            method_call_ptr,
+            base::BindOnce([](std::unique_ptr<dbus::MethodCall>,
+                              std::unique_ptr<dbus::Response>) {},
+                           std::move(method_call)));
+{{- end}}
+{{- if .Error}}
+        brillo::ErrorPtr error;
+{{- end}}
+{{- range .Outs}}
+        {{.Type}} {{.Name}};
+{{- end}}
+        impl->{{.Name}}({{join .Args ", "}});
+        break;
+      }
+{{- end}}
+    }
+  }
+}
+
+{{range extractNameSpaces .Name | reverse -}}
+}  // namespace {{.}}
+{{end}}
+{{- end}}
+{{- end}}{{end}}
+#endif  // {{.HeaderGuard}}
+`
+
+// param is a local variable holding an argument of a method.
+type param struct {
+	Type string
+	Name string
+}
+
+// fuzzMethod is a method called by the fuzzer.
+type fuzzMethod struct {
+	Name string
+	// Ins are the input arguments consumed from the fuzz input. They are
+	// written into the method call for the raw methods.
+	Ins []param
+	// Outs are the output arguments passed by pointers.
+	Outs []param
+	// MethodCall is set when the method needs a dbus::MethodCall.
+	MethodCall bool
+	// Raw is set for the raw methods, which take the method call.
+	Raw bool
+	// ResponseType is the list of the output types of the asynchronous
+	// methods, which take a DBusMethodResponse.
+	ResponseType string
+	// Error is set when the method takes a brillo::ErrorPtr.
+	Error bool
+	// Args are the arguments of the call of the method.
+	Args []string
+}
+
+// makeFuzzMethods returns the methods of itf called by the fuzzer. They take
+// the same parameters as the interface methods generated by the adaptor.
+func makeFuzzMethods(itf introspect.Interface) ([]fuzzMethod, error) {
+	var ret []fuzzMethod
+	for _, m := range itf.Methods {
+		fm := fuzzMethod{Name: m.Name}
+		ins := m.InputArguments()
+		outs := m.OutputArguments()
+		switch m.Kind() {
+		case introspect.MethodKindSimple:
+			if len(outs) == 1 {
+				// The only output argument is returned.
+				outs = nil
+			}
+		case introspect.MethodKindNormal:
+			fm.Error = true
+			fm.Args = append(fm.Args, "&error")
+			if m.IncludeDBusMessage() {
+				fm.MethodCall = true
+				fm.Args = append(fm.Args, "method_call.get()")
+			}
+		case introspect.MethodKindAsync:
+			var types []string
+			for _, a := range outs {
+				t, err := a.BaseType()
+				if err != nil {
+					return nil, err
+				}
+				types = append(types, t)
+			}
+			fm.MethodCall = true
+			fm.ResponseType = strings.Join(types, ", ")
+			fm.Args = append(fm.Args, "std::move(response)")
+			if m.IncludeDBusMessage() {
+				fm.Args = append(fm.Args, "method_call_ptr")
+			}
+			outs = nil
+		case introspect.MethodKindRaw:
+			fm.MethodCall = true
+			fm.Raw = true
+			fm.Args = append(fm.Args, "method_call.get()", "base::DoNothing()")
+			outs = nil
+		}
+
+		numIn := len(m.InputArguments())
+		for i, a := range ins {
+			t, err := a.BaseType()
+			if err != nil {
+				return nil, err
+			}
+			name := genutil.ArgName("in", a.Name, genutil.MethodArgIndex(false, i, numIn))
+			fm.Ins = append(fm.Ins, param{Type: t, Name: name})
+			if !fm.Raw {
+				fm.Args = append(fm.Args, "std::move("+name+")")
+			}
+		}
+		for i, a := range outs {
+			t, err := a.BaseType()
+			if err != nil {
+				return nil, err
+			}
+			name := genutil.ArgName("out", a.Name, genutil.MethodArgIndex(true, i, numIn))
+			fm.Outs = append(fm.Outs, param{Type: t, Name: name})
+			fm.Args = append(fm.Args, "&"+name)
+		}
+		ret = append(ret, fm)
+	}
+	return ret, nil
+}
+
+// Generate outputs the header file containing the fuzzers of the adaptors
+// into f. outputFilePath is used to make a unique header guard, and
+// adaptorFilePath is the path of the adaptor header to be included.
+// The interfaces without methods are skipped.
+func Generate(introspects []introspect.Introspection, f io.Writer, outputFilePath, adaptorFilePath string, config serviceconfig.Config) error {
+	f = genutil.NewAPILevelWriter(f, config.TargetAPILevel)
+	if adaptorFilePath == "" {
+		return errors.New("adaptor file path is not specified")
+	}
+	nsFuncs, err := genutil.MakeNameSpaceFuncs(introspects, config.NamespaceOverrides)
+	if err != nil {
+		return err
+	}
+	tmpl, err := template.New("fuzzer").Funcs(funcMap).Funcs(nsFuncs).Parse(templateText)
+	if err != nil {
+		return err
+	}
+
+	return tmpl.Execute(f, struct {
+		Introspects     []introspect.Introspection
+		HeaderGuard     string
+		AdaptorFilePath string
+	}{
+		Introspects:     introspects,
+		HeaderGuard:     genutil.GenerateHeaderGuard(outputFilePath),
+		AdaptorFilePath: adaptorFilePath,
+	})
+}
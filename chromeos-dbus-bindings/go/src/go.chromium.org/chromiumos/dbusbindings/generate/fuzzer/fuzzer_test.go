@@ -0,0 +1,283 @@
+// Copyright 2022 The ChromiumOS Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package fuzzer
+
+import (
+	"bytes"
+	"testing"
+
+	"go.chromium.org/chromiumos/dbusbindings/introspect"
+	"go.chromium.org/chromiumos/dbusbindings/serviceconfig"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGenerate(t *testing.T) {
+	introspections := []introspect.Introspection{{
+		Interfaces: []introspect.Interface{{
+			Name: "org.chromium.Test",
+			Methods: []introspect.Method{
+				{
+					Name: "Scan",
+					Args: []introspect.MethodArg{
+						{Name: "name", Type: "s"},
+						{Name: "options", Type: "a{sv}"},
+						{Name: "count", Type: "i", Direction: "out"},
+						{Name: "results", Type: "as", Direction: "out"},
+					},
+				}, {
+					Name: "GetCount",
+					Args: []introspect.MethodArg{
+						{Name: "count", Type: "u", Direction: "out"},
+					},
+					Annotations: []introspect.Annotation{
+						{Name: "org.chromium.DBus.Method.Kind", Value: "simple"},
+					},
+				}, {
+					Name: "Connect",
+					Args: []introspect.MethodArg{
+						{Name: "path", Type: "o"},
+						{Name: "fd", Type: "h"},
+						{Name: "id", Type: "x", Direction: "out"},
+					},
+					Annotations: []introspect.Annotation{
+						{Name: "org.chromium.DBus.Method.Kind", Value: "async"},
+						{Name: "org.chromium.DBus.Method.IncludeDBusMessage", Value: "true"},
+					},
+				}, {
+					Name: "Forward",
+					Args: []introspect.MethodArg{
+						{Name: "values", Type: "a(ib)"},
+					},
+					Annotations: []introspect.Annotation{
+						{Name: "org.chromium.DBus.Method.Kind", Value: "raw"},
+					},
+				},
+			},
+		}, {
+			Name: "org.chromium.NoMethods",
+			Signals: []introspect.Signal{{
+				Name: "Changed",
+			}},
+		}},
+	}}
+
+	out := new(bytes.Buffer)
+	if err := Generate(introspections, out, "/tmp/fuzzer.h", "adaptor.h", serviceconfig.Config{}); err != nil {
+		t.Fatalf("Generate got error, want nil: %v", err)
+	}
+
+	const want = `// Automatic generation of D-Bus adaptor fuzzers for:
+//  - org.chromium.Test
+//  - org.chromium.NoMethods
+#ifndef ____CHROMEOS_DBUS_BINDING___TMP_FUZZER_H
+#define ____CHROMEOS_DBUS_BINDING___TMP_FUZZER_H
+#include <cstddef>
+#include <map>
+#include <memory>
+#include <optional>
+#include <string>
+#include <tuple>
+#include <type_traits>
+#include <utility>
+#include <vector>
+
+#include <base/functional/bind.h>
+#include <base/functional/callback_helpers.h>
+#include <brillo/dbus/dbus_method_response.h>
+#include <brillo/dbus/dbus_param_writer.h>
+#include <brillo/errors/error.h>
+#include <dbus/message.h>
+#include <dbus/object_path.h>
+#include <fuzzer/FuzzedDataProvider.h>
+
+#include "adaptor.h"
+
+#ifndef CHROMEOS_DBUS_BINDINGS_DBUS_FUZZ_VALUES_
+#define CHROMEOS_DBUS_BINDINGS_DBUS_FUZZ_VALUES_
+namespace chromeos_dbus_bindings {
+
+// The maximum number of elements of the arrays and dictionaries consumed
+// from the fuzz input.
+inline constexpr size_t kMaxFuzzElements = 16;
+
+template <typename T, typename = void>
+struct IsFuzzProtobuf : std::false_type {};
+
+template <typename T>
+struct IsFuzzProtobuf<T,
+                      std::void_t<decltype(std::declval<T&>().ParseFromString(
+                          std::declval<const std::string&>()))>>
+    : std::true_type {};
+
+// Consumes a value of the C++ type of a D-Bus argument from the fuzz input.
+// The values of brillo::Any, base::ScopedFD and the named structs are
+// default constructed.
+template <typename T>
+struct FuzzValue {
+  static T Consume(FuzzedDataProvider* provider) {
+    if constexpr (std::is_same_v<T, bool>) {
+      return provider->ConsumeBool();
+    } else if constexpr (std::is_enum_v<T>) {
+      return static_cast<T>(
+          provider->ConsumeIntegral<std::underlying_type_t<T>>());
+    } else if constexpr (std::is_integral_v<T>) {
+      return provider->ConsumeIntegral<T>();
+    } else if constexpr (std::is_floating_point_v<T>) {
+      return provider->ConsumeFloatingPoint<T>();
+    } else {
+      T value{};
+      if constexpr (IsFuzzProtobuf<T>::value) {
+        value.ParseFromString(provider->ConsumeRandomLengthString());
+      }
+      return value;
+    }
+  }
+};
+
+template <>
+struct FuzzValue<std::string> {
+  static std::string Consume(FuzzedDataProvider* provider) {
+    return provider->ConsumeRandomLengthString();
+  }
+};
+
+template <>
+struct FuzzValue<dbus::ObjectPath> {
+  static dbus::ObjectPath Consume(FuzzedDataProvider* provider) {
+    return dbus::ObjectPath(provider->ConsumeRandomLengthString());
+  }
+};
+
+template <typename T>
+struct FuzzValue<std::optional<T>> {
+  static std::optional<T> Consume(FuzzedDataProvider* provider) {
+    if (!provider->ConsumeBool())
+      return std::nullopt;
+    return FuzzValue<T>::Consume(provider);
+  }
+};
+
+template <typename T>
+struct FuzzValue<std::vector<T>> {
+  static std::vector<T> Consume(FuzzedDataProvider* provider) {
+    std::vector<T> values(
+        provider->ConsumeIntegralInRange<size_t>(0, kMaxFuzzElements));
+    for (auto& value : values)
+      value = FuzzValue<T>::Consume(provider);
+    return values;
+  }
+};
+
+template <typename K, typename V>
+struct FuzzValue<std::map<K, V>> {
+  static std::map<K, V> Consume(FuzzedDataProvider* provider) {
+    std::map<K, V> values;
+    size_t size = provider->ConsumeIntegralInRange<size_t>(0, kMaxFuzzElements);
+    for (size_t i = 0; i < size; ++i) {
+      K key = FuzzValue<K>::Consume(provider);
+      values.emplace(std::move(key), FuzzValue<V>::Consume(provider));
+    }
+    return values;
+  }
+};
+
+template <typename... Ts>
+struct FuzzValue<std::tuple<Ts...>> {
+  static std::tuple<Ts...> Consume(FuzzedDataProvider* provider) {
+    // The braced initializer consumes the elements in order.
+    return std::tuple<Ts...>{FuzzValue<Ts>::Consume(provider)...};
+  }
+};
+
+// Returns a method call of the method of the interface, which can be
+// replied to.
+inline std::unique_ptr<dbus::MethodCall> MakeFuzzMethodCall(
+    const std::string& interface_name, const std::string& method_name) {
+  auto method_call =
+      std::make_unique<dbus::MethodCall>(interface_name, method_name);
+  method_call->SetSerial(1);
+  return method_call;
+}
+
+}  // namespace chromeos_dbus_bindings
+#endif  // CHROMEOS_DBUS_BINDINGS_DBUS_FUZZ_VALUES_
+
+namespace org {
+namespace chromium {
+
+// Calls the methods of |impl| with the arguments consumed from |provider|
+// until the fuzz input runs out. The replies of the asynchronous and raw
+// methods are dropped.
+inline void FuzzTestInterface(TestInterface* impl,
+                              FuzzedDataProvider* provider) {
+  while (provider->remaining_bytes() > 0) {
+    switch (provider->ConsumeIntegralInRange<int>(0, 3)) {
+      case 0: {
+        auto in_name =
+            chromeos_dbus_bindings::FuzzValue<std::string>::Consume(provider);
+        auto in_options =
+            chromeos_dbus_bindings::FuzzValue<brillo::VariantDictionary>::Consume(provider);
+        brillo::ErrorPtr error;
+        int32_t out_count;
+        std::vector<std::string> out_results;
+        impl->Scan(&error, std::move(in_name), std::move(in_options), &out_count, &out_results);
+        break;
+      }
+      case 1: {
+        impl->GetCount();
+        break;
+      }
+      case 2: {
+        auto in_path =
+            chromeos_dbus_bindings::FuzzValue<dbus::ObjectPath>::Consume(provider);
+        auto in_fd =
+            chromeos_dbus_bindings::FuzzValue<base::ScopedFD>::Consume(provider);
+        std::unique_ptr<dbus::MethodCall> method_call =
+            chromeos_dbus_bindings::MakeFuzzMethodCall("org.chromium.Test",
+                                                       "Connect");
+        dbus::MethodCall* method_call_ptr = method_call.get();
+        auto response = std::make_unique<
+            brillo::dbus_utils::DBusMethodResponse<int64_t>>(
+            method_call_ptr,
+            base::BindOnce([](std::unique_ptr<dbus::MethodCall>,
+                              std::unique_ptr<dbus::Response>) {},
+                           std::move(method_call)));
+        impl->Connect(std::move(response), method_call_ptr, std::move(in_path), std::move(in_fd));
+        break;
+      }
+      case 3: {
+        auto in_values =
+            chromeos_dbus_bindings::FuzzValue<std::vector<std::tuple<int32_t, bool>>>::Consume(provider);
+        std::unique_ptr<dbus::MethodCall> method_call =
+            chromeos_dbus_bindings::MakeFuzzMethodCall("org.chromium.Test",
+                                                       "Forward");
+        dbus::MessageWriter writer(method_call.get());
+        brillo::dbus_utils::DBusParamWriter::Append(
+            &writer, in_values);
+        impl->Forward(method_call.get(), base::DoNothing());
+        break;
+      }
+    }
+  }
+}
+
+}  // namespace chromium
+}  // namespace org
+
+#endif  // ____CHROMEOS_DBUS_BINDING___TMP_FUZZER_H
+`
+
+	if diff := cmp.Diff(out.String(), want); diff != "" {
+		t.Errorf("Generate failed (-got +want):\n%s", diff)
+	}
+}
+
+func TestGenerateWithoutAdaptorFilePath(t *testing.T) {
+	out := new(bytes.Buffer)
+	if err := Generate(nil, out, "/tmp/fuzzer.h", "", serviceconfig.Config{}); err == nil {
+		t.Error("Generate succeeded unexpectedly")
+	}
+}
@@ -0,0 +1,407 @@
+// Copyright 2022 The ChromiumOS Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+// Package gdbus outputs a C header with the GLib GDBusProxy based client
+// functions of the interfaces based on introspects, for the components
+// written in plain C, so that they do not hand-write the GVariant marshaling.
+package gdbus
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+
+	"go.chromium.org/chromiumos/dbusbindings/dbustype"
+	"go.chromium.org/chromiumos/dbusbindings/generate/genutil"
+	"go.chromium.org/chromiumos/dbusbindings/introspect"
+)
+
+var funcMap = template.FuncMap{
+	"formatComment": genutil.FormatComment,
+}
+
+const templateText = `// Automatic generation of D-Bus GDBus C client for:
+{{range .Introspects}}{{range .Interfaces -}}
+//  - {{.Name}}
+{{end}}{{end -}}
+#ifndef {{.HeaderGuard}}
+#define {{.HeaderGuard}}
+#include <gio/gio.h>
+
+G_BEGIN_DECLS
+{{range .Interfaces}}
+{{formatComment .DocString 0 -}}
+#define {{.Macro}}_INTERFACE_NAME "{{.Name}}"
+
+// Creates the proxy of the object at |object_path| owned by |name| on
+// |connection|. Returns NULL and sets |error| on failure.
+static inline GDBusProxy* {{.Prefix}}_proxy_new_sync(
+    GDBusConnection* connection,
+    const gchar* name,
+    const gchar* object_path,
+    GCancellable* cancellable,
+    GError** error) {
+  return g_dbus_proxy_new_sync(
+      connection, G_DBUS_PROXY_FLAGS_NONE, NULL, name, object_path,
+      {{.Macro}}_INTERFACE_NAME, cancellable, error);
+}
+{{- $itf := .}}
+{{- range .Methods}}
+{{- if .Skipped}}
+
+// {{.Name}}() takes or returns file descriptors, which are not supported.
+{{- else}}
+
+{{formatComment .DocString 0 -}}
+// Calls {{.Name}}() and blocks until it returns. Returns FALSE and sets
+// |error| on failure.
+static inline gboolean {{$itf.Prefix}}_call_{{.CName}}_sync(
+    GDBusProxy* proxy,
+{{- range .In}}
+    {{.InType}} {{.Name}},
+{{- end}}
+{{- range .Out}}
+    {{.OutType}} {{.Name}},
+{{- end}}
+    GCancellable* cancellable,
+    GError** error) {
+  GVariant* ret = g_dbus_proxy_call_sync(
+      proxy, "{{.Name}}",
+      {{template "params" .}},
+      G_DBUS_CALL_FLAGS_NONE, -1, cancellable, error);
+{{- template "results" .}}
+}
+
+// Calls {{.Name}}() and runs |callback| on completion, which is expected to
+// call {{$itf.Prefix}}_call_{{.CName}}_finish().
+static inline void {{$itf.Prefix}}_call_{{.CName}}(
+    GDBusProxy* proxy,
+{{- range .In}}
+    {{.InType}} {{.Name}},
+{{- end}}
+    GCancellable* cancellable,
+    GAsyncReadyCallback callback,
+    gpointer user_data) {
+  g_dbus_proxy_call(
+      proxy, "{{.Name}}",
+      {{template "params" .}},
+      G_DBUS_CALL_FLAGS_NONE, -1, cancellable, callback, user_data);
+}
+
+// Finishes the call started by
+// {{$itf.Prefix}}_call_{{.CName}}().
+// Returns FALSE and sets |error| on failure.
+static inline gboolean {{$itf.Prefix}}_call_{{.CName}}_finish(
+    GDBusProxy* proxy,
+{{- range .Out}}
+    {{.OutType}} {{.Name}},
+{{- end}}
+    GAsyncResult* res,
+    GError** error) {
+  GVariant* ret = g_dbus_proxy_call_finish(proxy, res, error);
+{{- template "results" .}}
+}
+{{- end}}
+{{- end}}
+{{- range .Properties}}
+{{- if .Readable}}
+
+// Gets the cached value of the {{.Name}} property. Returns FALSE if it is not
+// cached.
+static inline gboolean {{$itf.Prefix}}_get_{{.CName}}(
+    GDBusProxy* proxy,
+    {{.OutType}} value) {
+  GVariant* v = g_dbus_proxy_get_cached_property(proxy, "{{.Name}}");
+  if (!v)
+    return FALSE;
+  g_variant_get(v, "{{.Format}}", value);
+  g_variant_unref(v);
+  return TRUE;
+}
+{{- end}}
+{{- if .Writable}}
+
+// Sets the {{.Name}} property and blocks until it is set. Returns FALSE and
+// sets |error| on failure.
+static inline gboolean {{$itf.Prefix}}_set_{{.CName}}_sync(
+    GDBusProxy* proxy,
+    {{.InType}} value,
+    GCancellable* cancellable,
+    GError** error) {
+  GVariant* ret = g_dbus_proxy_call_sync(
+      proxy, "org.freedesktop.DBus.Properties.Set",
+      g_variant_new("(ssv)", {{$itf.Macro}}_INTERFACE_NAME, "{{.Name}}",
+                    g_variant_new("{{.Format}}", value)),
+      G_DBUS_CALL_FLAGS_NONE, -1, cancellable, error);
+  if (!ret)
+    return FALSE;
+  g_variant_unref(ret);
+  return TRUE;
+}
+{{- end}}
+{{- end}}
+{{- range .Signals}}
+{{- if .Skipped}}
+
+// {{.Name}} carries file descriptors, which are not supported.
+{{- else if .Out}}
+
+// Extracts the arguments of the {{.Name}} signal from |parameters| passed to
+// the "g-signal" handler of the proxy.
+static inline void {{$itf.Prefix}}_parse_{{.CName}}_signal(
+    GVariant* parameters
+{{- range .Out}},
+    {{.OutType}} {{.Name}}
+{{- end}}) {
+  g_variant_get(parameters, "{{.Format}}"{{range .Out}}, {{.Name}}{{end}});
+}
+{{- end}}
+{{- end}}
+{{end}}
+G_END_DECLS
+
+#endif  // {{.HeaderGuard}}
+{{- define "params"}}
+{{- if .In}}g_variant_new("{{.InFormat}}"{{range .In}}, {{.Name}}{{end}}){{else}}NULL{{end}}
+{{- end}}
+{{- define "results"}}
+  if (!ret)
+    return FALSE;
+{{- if .Out}}
+  g_variant_get(ret, "{{.OutFormat}}"{{range .Out}}, {{.Name}}{{end}});
+{{- end}}
+  g_variant_unref(ret);
+  return TRUE;
+{{- end}}
+`
+
+// cArg is an argument of a C function.
+type cArg struct {
+	Name string
+	// InType and OutType are the C types of the argument passed to and
+	// returned from D-Bus respectively.
+	InType, OutType string
+	// Format is the GVariant format string of the argument.
+	Format string
+}
+
+// cMethod is a D-Bus method with its C functions.
+type cMethod struct {
+	Name      string
+	CName     string
+	DocString introspect.DocString
+	// Skipped tells that the method takes or returns file descriptors.
+	Skipped             bool
+	In, Out             []cArg
+	InFormat, OutFormat string
+}
+
+// cProperty is a D-Bus property with its C accessors.
+type cProperty struct {
+	Name               string
+	CName              string
+	Readable, Writable bool
+	cArg
+}
+
+// cSignal is a D-Bus signal with its C parser.
+type cSignal struct {
+	Name  string
+	CName string
+	// Skipped tells that the signal carries file descriptors.
+	Skipped bool
+	Out     []cArg
+	Format  string
+}
+
+// cInterface is a D-Bus interface with its C functions.
+type cInterface struct {
+	Name      string
+	DocString introspect.DocString
+	// Prefix is the prefix of the functions, e.g. "org_chromium_frobinator",
+	// and Macro is the prefix of the macros, e.g. "ORG_CHROMIUM_FROBINATOR".
+	Prefix, Macro string
+	Methods       []cMethod
+	Properties    []cProperty
+	Signals       []cSignal
+}
+
+// basicTypes maps the D-Bus basic types to their C types.
+var basicTypes = map[string]string{
+	"b": "gboolean",
+	"y": "guchar",
+	"n": "gint16",
+	"q": "guint16",
+	"i": "gint32",
+	"u": "guint32",
+	"x": "gint64",
+	"t": "guint64",
+	"d": "gdouble",
+}
+
+// makeCName converts a D-Bus name, e.g. "org.chromium.FrobinatorV2", into a C
+// identifier, e.g. "org_chromium_frobinator_v2".
+func makeCName(name string) string {
+	var parts []string
+	for _, p := range strings.Split(name, ".") {
+		parts = append(parts, genutil.MakeVariableName(p))
+	}
+	return strings.Join(parts, "_")
+}
+
+// makeArg returns the C argument named name of the D-Bus type typ.
+// It returns false if typ contains file descriptors.
+func makeArg(name, typ string) (cArg, bool, error) {
+	if _, err := dbustype.Parse(typ); err != nil {
+		return cArg{}, false, err
+	}
+	if strings.Contains(typ, "h") {
+		return cArg{}, false, nil
+	}
+	ret := cArg{Name: name, Format: typ}
+	switch {
+	case basicTypes[typ] != "":
+		ret.InType = basicTypes[typ]
+		ret.OutType = basicTypes[typ] + "*"
+	case typ == "s" || typ == "o":
+		// The output strings are newly allocated, to be freed by g_free().
+		ret.InType = "const gchar*"
+		ret.OutType = "gchar**"
+	case typ == "v":
+		ret.InType = "GVariant*"
+		ret.OutType = "GVariant**"
+	default:
+		// The containers are passed as GVariant of the type.
+		ret.InType = "GVariant*"
+		ret.OutType = "GVariant**"
+		ret.Format = "@" + typ
+	}
+	return ret, true, nil
+}
+
+// makeFormat returns the GVariant format string of the tuple of args.
+func makeFormat(args []cArg) string {
+	var b strings.Builder
+	b.WriteString("(")
+	for _, a := range args {
+		b.WriteString(a.Format)
+	}
+	b.WriteString(")")
+	return b.String()
+}
+
+// makeMethod returns the C functions of m.
+func makeMethod(m introspect.Method) (cMethod, error) {
+	ret := cMethod{Name: m.Name, CName: makeCName(m.Name), DocString: m.DocString}
+	numIn := len(m.InputArguments())
+	for i, a := range m.InputArguments() {
+		arg, ok, err := makeArg(genutil.ArgName("in", a.Name, genutil.MethodArgIndex(false, i, numIn)), string(a.Type))
+		if err != nil {
+			return cMethod{}, fmt.Errorf("%s method %s argument: %v", m.Name, a.Name, err)
+		}
+		if !ok {
+			return cMethod{Name: m.Name, Skipped: true}, nil
+		}
+		ret.In = append(ret.In, arg)
+	}
+	for i, a := range m.OutputArguments() {
+		arg, ok, err := makeArg(genutil.ArgName("out", a.Name, genutil.MethodArgIndex(true, i, numIn)), string(a.Type))
+		if err != nil {
+			return cMethod{}, fmt.Errorf("%s method %s argument: %v", m.Name, a.Name, err)
+		}
+		if !ok {
+			return cMethod{Name: m.Name, Skipped: true}, nil
+		}
+		ret.Out = append(ret.Out, arg)
+	}
+	ret.InFormat = makeFormat(ret.In)
+	ret.OutFormat = makeFormat(ret.Out)
+	return ret, nil
+}
+
+// makeInterface returns the C functions of itf.
+func makeInterface(itf introspect.Interface) (cInterface, error) {
+	prefix := makeCName(itf.Name)
+	ret := cInterface{
+		Name:      itf.Name,
+		DocString: itf.DocString,
+		Prefix:    prefix,
+		Macro:     strings.ToUpper(prefix),
+	}
+	for _, m := range itf.Methods {
+		cm, err := makeMethod(m)
+		if err != nil {
+			return cInterface{}, err
+		}
+		ret.Methods = append(ret.Methods, cm)
+	}
+	for _, p := range itf.Properties {
+		arg, ok, err := makeArg("value", p.Type)
+		if err != nil {
+			return cInterface{}, fmt.Errorf("%s property: %v", p.Name, err)
+		}
+		if !ok {
+			continue
+		}
+		ret.Properties = append(ret.Properties, cProperty{
+			Name:     p.Name,
+			CName:    makeCName(p.Name),
+			Readable: p.Readable(),
+			Writable: p.Writable(),
+			cArg:     arg,
+		})
+	}
+	for _, s := range itf.Signals {
+		cs := cSignal{Name: s.Name, CName: makeCName(s.Name)}
+		for i, a := range s.Args {
+			arg, ok, err := makeArg(genutil.ArgName("out", a.Name, i+1), a.Type)
+			if err != nil {
+				return cInterface{}, fmt.Errorf("%s signal %s argument: %v", s.Name, a.Name, err)
+			}
+			if !ok {
+				cs = cSignal{Name: s.Name, Skipped: true}
+				break
+			}
+			cs.Out = append(cs.Out, arg)
+		}
+		cs.Format = makeFormat(cs.Out)
+		ret.Signals = append(ret.Signals, cs)
+	}
+	return ret, nil
+}
+
+// Generate outputs the C header with, for each interface in introspects,
+// a function creating its GDBusProxy, the synchronous and asynchronous
+// functions calling its methods with the typed arguments packed into
+// GVariant, the accessors of its properties, and the functions extracting
+// the arguments of its signals. Containers are passed as GVariant of their
+// types, and the members using file descriptors are skipped.
+// outputFilePath is used to make a unique header guard.
+func Generate(introspects []introspect.Introspection, f io.Writer, outputFilePath string) error {
+	var itfs []cInterface
+	for _, is := range introspects {
+		for _, itf := range is.Interfaces {
+			ci, err := makeInterface(itf)
+			if err != nil {
+				return fmt.Errorf("%s interface: %v", itf.Name, err)
+			}
+			itfs = append(itfs, ci)
+		}
+	}
+
+	tmpl, err := template.New("gdbus").Funcs(funcMap).Parse(templateText)
+	if err != nil {
+		return err
+	}
+	return tmpl.Execute(f, struct {
+		Introspects []introspect.Introspection
+		Interfaces  []cInterface
+		HeaderGuard string
+	}{
+		Introspects: introspects,
+		Interfaces:  itfs,
+		HeaderGuard: genutil.GenerateHeaderGuard(outputFilePath),
+	})
+}
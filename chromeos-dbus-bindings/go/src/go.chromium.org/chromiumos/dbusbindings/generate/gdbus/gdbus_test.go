@@ -0,0 +1,268 @@
+// Copyright 2022 The ChromiumOS Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package gdbus
+
+import (
+	"bytes"
+	"testing"
+
+	"go.chromium.org/chromiumos/dbusbindings/introspect"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGenerate(t *testing.T) {
+	introspections := []introspect.Introspection{{
+		Interfaces: []introspect.Interface{{
+			Name:      "org.chromium.FrobinatorV2",
+			DocString: "Frobinates things.",
+			Methods: []introspect.Method{
+				{
+					Name: "Frobinate",
+					Args: []introspect.MethodArg{
+						{Name: "foo", Type: "i"},
+						{Name: "name", Type: "s"},
+						{Name: "options", Type: "a{sv}"},
+						{Name: "bar", Type: "o", Direction: "out"},
+						{Type: "a(ou)", Direction: "out"},
+					},
+					DocString: "Frobinates the foo.",
+				},
+				{
+					Name: "Reset",
+				},
+				{
+					Name: "OpenDevice",
+					Args: []introspect.MethodArg{
+						{Name: "fd", Type: "h", Direction: "out"},
+					},
+				},
+			},
+			Properties: []introspect.Property{
+				{Name: "Level", Type: "u", Access: "readwrite"},
+				{Name: "Tags", Type: "as", Access: "read"},
+			},
+			Signals: []introspect.Signal{
+				{
+					Name: "Frobinated",
+					Args: []introspect.SignalArg{
+						{Name: "count", Type: "x"},
+						{Name: "value", Type: "v"},
+					},
+				},
+				{
+					Name: "Cleared",
+				},
+			},
+		}},
+	}}
+
+	out := new(bytes.Buffer)
+	if err := Generate(introspections, out, "/tmp/frobinator.h"); err != nil {
+		t.Fatalf("Generate got error, want nil: %v", err)
+	}
+
+	const want = `// Automatic generation of D-Bus GDBus C client for:
+//  - org.chromium.FrobinatorV2
+#ifndef ____CHROMEOS_DBUS_BINDING___TMP_FROBINATOR_H
+#define ____CHROMEOS_DBUS_BINDING___TMP_FROBINATOR_H
+#include <gio/gio.h>
+
+G_BEGIN_DECLS
+
+// Frobinates things.
+#define ORG_CHROMIUM_FROBINATOR_V2_INTERFACE_NAME "org.chromium.FrobinatorV2"
+
+// Creates the proxy of the object at |object_path| owned by |name| on
+// |connection|. Returns NULL and sets |error| on failure.
+static inline GDBusProxy* org_chromium_frobinator_v2_proxy_new_sync(
+    GDBusConnection* connection,
+    const gchar* name,
+    const gchar* object_path,
+    GCancellable* cancellable,
+    GError** error) {
+  return g_dbus_proxy_new_sync(
+      connection, G_DBUS_PROXY_FLAGS_NONE, NULL, name, object_path,
+      ORG_CHROMIUM_FROBINATOR_V2_INTERFACE_NAME, cancellable, error);
+}
+
+// Frobinates the foo.
+// Calls Frobinate() and blocks until it returns. Returns FALSE and sets
+// |error| on failure.
+static inline gboolean org_chromium_frobinator_v2_call_frobinate_sync(
+    GDBusProxy* proxy,
+    gint32 in_foo,
+    const gchar* in_name,
+    GVariant* in_options,
+    gchar** out_bar,
+    GVariant** out_5,
+    GCancellable* cancellable,
+    GError** error) {
+  GVariant* ret = g_dbus_proxy_call_sync(
+      proxy, "Frobinate",
+      g_variant_new("(is@a{sv})", in_foo, in_name, in_options),
+      G_DBUS_CALL_FLAGS_NONE, -1, cancellable, error);
+  if (!ret)
+    return FALSE;
+  g_variant_get(ret, "(o@a(ou))", out_bar, out_5);
+  g_variant_unref(ret);
+  return TRUE;
+}
+
+// Calls Frobinate() and runs |callback| on completion, which is expected to
+// call org_chromium_frobinator_v2_call_frobinate_finish().
+static inline void org_chromium_frobinator_v2_call_frobinate(
+    GDBusProxy* proxy,
+    gint32 in_foo,
+    const gchar* in_name,
+    GVariant* in_options,
+    GCancellable* cancellable,
+    GAsyncReadyCallback callback,
+    gpointer user_data) {
+  g_dbus_proxy_call(
+      proxy, "Frobinate",
+      g_variant_new("(is@a{sv})", in_foo, in_name, in_options),
+      G_DBUS_CALL_FLAGS_NONE, -1, cancellable, callback, user_data);
+}
+
+// Finishes the call started by
+// org_chromium_frobinator_v2_call_frobinate().
+// Returns FALSE and sets |error| on failure.
+static inline gboolean org_chromium_frobinator_v2_call_frobinate_finish(
+    GDBusProxy* proxy,
+    gchar** out_bar,
+    GVariant** out_5,
+    GAsyncResult* res,
+    GError** error) {
+  GVariant* ret = g_dbus_proxy_call_finish(proxy, res, error);
+  if (!ret)
+    return FALSE;
+  g_variant_get(ret, "(o@a(ou))", out_bar, out_5);
+  g_variant_unref(ret);
+  return TRUE;
+}
+
+// Calls Reset() and blocks until it returns. Returns FALSE and sets
+// |error| on failure.
+static inline gboolean org_chromium_frobinator_v2_call_reset_sync(
+    GDBusProxy* proxy,
+    GCancellable* cancellable,
+    GError** error) {
+  GVariant* ret = g_dbus_proxy_call_sync(
+      proxy, "Reset",
+      NULL,
+      G_DBUS_CALL_FLAGS_NONE, -1, cancellable, error);
+  if (!ret)
+    return FALSE;
+  g_variant_unref(ret);
+  return TRUE;
+}
+
+// Calls Reset() and runs |callback| on completion, which is expected to
+// call org_chromium_frobinator_v2_call_reset_finish().
+static inline void org_chromium_frobinator_v2_call_reset(
+    GDBusProxy* proxy,
+    GCancellable* cancellable,
+    GAsyncReadyCallback callback,
+    gpointer user_data) {
+  g_dbus_proxy_call(
+      proxy, "Reset",
+      NULL,
+      G_DBUS_CALL_FLAGS_NONE, -1, cancellable, callback, user_data);
+}
+
+// Finishes the call started by
+// org_chromium_frobinator_v2_call_reset().
+// Returns FALSE and sets |error| on failure.
+static inline gboolean org_chromium_frobinator_v2_call_reset_finish(
+    GDBusProxy* proxy,
+    GAsyncResult* res,
+    GError** error) {
+  GVariant* ret = g_dbus_proxy_call_finish(proxy, res, error);
+  if (!ret)
+    return FALSE;
+  g_variant_unref(ret);
+  return TRUE;
+}
+
+// OpenDevice() takes or returns file descriptors, which are not supported.
+
+// Gets the cached value of the Level property. Returns FALSE if it is not
+// cached.
+static inline gboolean org_chromium_frobinator_v2_get_level(
+    GDBusProxy* proxy,
+    guint32* value) {
+  GVariant* v = g_dbus_proxy_get_cached_property(proxy, "Level");
+  if (!v)
+    return FALSE;
+  g_variant_get(v, "u", value);
+  g_variant_unref(v);
+  return TRUE;
+}
+
+// Sets the Level property and blocks until it is set. Returns FALSE and
+// sets |error| on failure.
+static inline gboolean org_chromium_frobinator_v2_set_level_sync(
+    GDBusProxy* proxy,
+    guint32 value,
+    GCancellable* cancellable,
+    GError** error) {
+  GVariant* ret = g_dbus_proxy_call_sync(
+      proxy, "org.freedesktop.DBus.Properties.Set",
+      g_variant_new("(ssv)", ORG_CHROMIUM_FROBINATOR_V2_INTERFACE_NAME, "Level",
+                    g_variant_new("u", value)),
+      G_DBUS_CALL_FLAGS_NONE, -1, cancellable, error);
+  if (!ret)
+    return FALSE;
+  g_variant_unref(ret);
+  return TRUE;
+}
+
+// Gets the cached value of the Tags property. Returns FALSE if it is not
+// cached.
+static inline gboolean org_chromium_frobinator_v2_get_tags(
+    GDBusProxy* proxy,
+    GVariant** value) {
+  GVariant* v = g_dbus_proxy_get_cached_property(proxy, "Tags");
+  if (!v)
+    return FALSE;
+  g_variant_get(v, "@as", value);
+  g_variant_unref(v);
+  return TRUE;
+}
+
+// Extracts the arguments of the Frobinated signal from |parameters| passed to
+// the "g-signal" handler of the proxy.
+static inline void org_chromium_frobinator_v2_parse_frobinated_signal(
+    GVariant* parameters,
+    gint64* out_count,
+    GVariant** out_value) {
+  g_variant_get(parameters, "(xv)", out_count, out_value);
+}
+
+G_END_DECLS
+
+#endif  // ____CHROMEOS_DBUS_BINDING___TMP_FROBINATOR_H
+`
+	if diff := cmp.Diff(out.String(), want); diff != "" {
+		t.Errorf("Generate failed (-got +want):\n%s", diff)
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	introspections := []introspect.Introspection{{
+		Interfaces: []introspect.Interface{{
+			Name: "org.chromium.Frobinator",
+			Methods: []introspect.Method{{
+				Name: "Frobinate",
+				Args: []introspect.MethodArg{{Name: "foo", Type: "a{"}},
+			}},
+		}},
+	}}
+
+	if err := Generate(introspections, new(bytes.Buffer), "/tmp/frobinator.h"); err == nil {
+		t.Error("Generate got nil error, want error")
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright 2022 The ChromiumOS Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package genutil
+
+import (
+	"io"
+	"strings"
+
+	"go.chromium.org/chromiumos/dbusbindings/serviceconfig"
+)
+
+// legacyHeaders are the pairs of the latest callback headers and their paths
+// before they were moved to base/functional/.
+var legacyHeaders = []string{
+	"<base/functional/bind.h>", "<base/bind.h>",
+	"<base/functional/callback.h>", "<base/callback.h>",
+	"<base/functional/callback_forward.h>", "<base/callback_forward.h>",
+	"<base/functional/callback_helpers.h>", "<base/callback_helpers.h>",
+}
+
+// legacyCallbacks are the pairs of the latest callback APIs and their legacy
+// equivalents.
+var legacyCallbacks = []string{
+	"base::BindOnce(", "base::Bind(",
+	"base::BindRepeating(", "base::Bind(",
+	"base::OnceCallback<", "base::Callback<",
+	"base::RepeatingCallback<", "base::Callback<",
+	"base::OnceClosure", "base::Closure",
+	"base::RepeatingClosure", "base::Closure",
+}
+
+// apiLevelReplacers rewrite the generated code for each API level older than
+// serviceconfig.APILevelLatest.
+var apiLevelReplacers = map[serviceconfig.APILevel]*strings.Replacer{
+	serviceconfig.APILevelLegacyHeaders:   strings.NewReplacer(legacyHeaders...),
+	serviceconfig.APILevelLegacyCallbacks: strings.NewReplacer(append(append([]string(nil), legacyHeaders...), legacyCallbacks...)...),
+}
+
+// apiLevelWriter rewrites the code written to w with r.
+type apiLevelWriter struct {
+	w io.Writer
+	r *strings.Replacer
+}
+
+func (a *apiLevelWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(a.w, a.r.Replace(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// NewAPILevelWriter returns a writer rewriting the generated C++ code written
+// into it to use the libchrome APIs of level, and passing it to w. It returns
+// w itself for the latest APIs. Each write must contain whole identifiers,
+// which holds for the outputs of the templates, as they write each text and
+// each action result at once.
+func NewAPILevelWriter(w io.Writer, level serviceconfig.APILevel) io.Writer {
+	r, ok := apiLevelReplacers[level]
+	if !ok {
+		return w
+	}
+	return &apiLevelWriter{w: w, r: r}
+}
@@ -0,0 +1,59 @@
+// Copyright 2022 The ChromiumOS Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package genutil_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"go.chromium.org/chromiumos/dbusbindings/generate/genutil"
+	"go.chromium.org/chromiumos/dbusbindings/serviceconfig"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestNewAPILevelWriter(t *testing.T) {
+	const src = `#include <base/functional/bind.h>
+#include <base/functional/callback.h>
+  void Foo(base::OnceCallback<void(int)> callback, base::RepeatingClosure closure) {
+    Bar(base::BindOnce(&Baz), base::BindRepeating(&Qux));
+  }
+`
+	cases := []struct {
+		level serviceconfig.APILevel
+		want  string
+	}{
+		{level: "", want: src},
+		{level: serviceconfig.APILevelLatest, want: src},
+		{
+			level: serviceconfig.APILevelLegacyHeaders,
+			want: `#include <base/bind.h>
+#include <base/callback.h>
+  void Foo(base::OnceCallback<void(int)> callback, base::RepeatingClosure closure) {
+    Bar(base::BindOnce(&Baz), base::BindRepeating(&Qux));
+  }
+`,
+		}, {
+			level: serviceconfig.APILevelLegacyCallbacks,
+			want: `#include <base/bind.h>
+#include <base/callback.h>
+  void Foo(base::Callback<void(int)> callback, base::Closure closure) {
+    Bar(base::Bind(&Baz), base::Bind(&Qux));
+  }
+`,
+		},
+	}
+	for _, tc := range cases {
+		out := new(bytes.Buffer)
+		w := genutil.NewAPILevelWriter(out, tc.level)
+		if _, err := io.WriteString(w, src); err != nil {
+			t.Fatalf("Write for level %q got error, want nil: %v", tc.level, err)
+		}
+		if diff := cmp.Diff(out.String(), tc.want); diff != "" {
+			t.Errorf("Unexpected output for level %q (-got +want):\n%s", tc.level, diff)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2022 The ChromiumOS Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package genutil
+
+import (
+	"fmt"
+	"strings"
+
+	"go.chromium.org/chromiumos/dbusbindings/introspect"
+)
+
+// DuplicateMember is a method or property name declared by more than one
+// interface of the same node. A C++ class implementing or wrapping all the
+// interfaces of the object would have ambiguous members.
+type DuplicateMember struct {
+	// Node is the object path of the node, which is empty if not fixed.
+	Node string
+	// Kind is either "method" or "property".
+	Kind string
+	Name string
+	// Interfaces are the names of the interfaces declaring the member, in
+	// the order of their declarations.
+	Interfaces []string
+}
+
+func (d DuplicateMember) String() string {
+	node := d.Node
+	if node == "" {
+		node = "<unnamed node>"
+	}
+	return fmt.Sprintf("%s: %s %s is declared by interfaces %s", node, d.Kind, d.Name, strings.Join(d.Interfaces, ", "))
+}
+
+// FindDuplicateMembers returns the method and property names declared by
+// more than one interface of a node in introspects.
+func FindDuplicateMembers(introspects []introspect.Introspection) []DuplicateMember {
+	var ret []DuplicateMember
+	for _, is := range introspects {
+		for _, kind := range []string{"method", "property"} {
+			var names []string
+			itfs := make(map[string][]string)
+			for _, itf := range is.Interfaces {
+				var members []string
+				if kind == "method" {
+					for _, m := range itf.Methods {
+						members = append(members, m.Name)
+					}
+				} else {
+					for _, p := range itf.Properties {
+						members = append(members, p.Name)
+					}
+				}
+				for _, name := range members {
+					if _, ok := itfs[name]; !ok {
+						names = append(names, name)
+					}
+					itfs[name] = append(itfs[name], itf.Name)
+				}
+			}
+			for _, name := range names {
+				if len(itfs[name]) > 1 {
+					ret = append(ret, DuplicateMember{
+						Node:       is.Name,
+						Kind:       kind,
+						Name:       name,
+						Interfaces: itfs[name],
+					})
+				}
+			}
+		}
+	}
+	return ret
+}
+
+// MakeQualifiedTypeName returns the CamelCase name joining all the components
+// of the qualified name, e.g. "OrgChromiumFoo" for "org.chromium.Foo".
+func MakeQualifiedTypeName(introspectItfName string) string {
+	var ret strings.Builder
+	for _, s := range strings.Split(introspectItfName, ".") {
+		ret.WriteString(MakeCamelCaseName(s))
+	}
+	return ret.String()
+}
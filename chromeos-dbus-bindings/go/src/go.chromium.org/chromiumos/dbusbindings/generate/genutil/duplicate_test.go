@@ -0,0 +1,71 @@
+// Copyright 2022 The ChromiumOS Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package genutil_test
+
+import (
+	"testing"
+
+	"go.chromium.org/chromiumos/dbusbindings/generate/genutil"
+	"go.chromium.org/chromiumos/dbusbindings/introspect"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFindDuplicateMembers(t *testing.T) {
+	introspects := []introspect.Introspection{{
+		Name: "/org/chromium/Test",
+		Interfaces: []introspect.Interface{{
+			Name:       "org.chromium.Foo",
+			Methods:    []introspect.Method{{Name: "Reset"}, {Name: "Start"}},
+			Properties: []introspect.Property{{Name: "State", Type: "s"}},
+		}, {
+			Name:       "org.chromium.Bar",
+			Methods:    []introspect.Method{{Name: "Stop"}, {Name: "Reset"}},
+			Properties: []introspect.Property{{Name: "State", Type: "i"}},
+		}, {
+			Name:    "org.chromium.Baz",
+			Methods: []introspect.Method{{Name: "Reset"}},
+		}},
+	}, {
+		// The members of different nodes may share names.
+		Interfaces: []introspect.Interface{{
+			Name:    "org.chromium.Qux",
+			Methods: []introspect.Method{{Name: "Start"}},
+		}},
+	}}
+
+	got := genutil.FindDuplicateMembers(introspects)
+	want := []genutil.DuplicateMember{{
+		Node:       "/org/chromium/Test",
+		Kind:       "method",
+		Name:       "Reset",
+		Interfaces: []string{"org.chromium.Foo", "org.chromium.Bar", "org.chromium.Baz"},
+	}, {
+		Node:       "/org/chromium/Test",
+		Kind:       "property",
+		Name:       "State",
+		Interfaces: []string{"org.chromium.Foo", "org.chromium.Bar"},
+	}}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("FindDuplicateMembers got unexpected result (-got +want):\n%s", diff)
+	}
+
+	const wantString = "/org/chromium/Test: method Reset is declared by interfaces org.chromium.Foo, org.chromium.Bar, org.chromium.Baz"
+	if s := got[0].String(); s != wantString {
+		t.Errorf("String got %q, want %q", s, wantString)
+	}
+}
+
+func TestMakeQualifiedTypeName(t *testing.T) {
+	cases := map[string]string{
+		"org.chromium.Foo":                "OrgChromiumFoo",
+		"fi.w1.wpa_supplicant1.Interface": "FiW1WpaSupplicant1Interface",
+	}
+	for in, want := range cases {
+		if got := genutil.MakeQualifiedTypeName(in); got != want {
+			t.Errorf("MakeQualifiedTypeName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
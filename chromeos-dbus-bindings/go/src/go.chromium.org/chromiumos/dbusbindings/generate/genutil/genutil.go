@@ -7,11 +7,18 @@ package genutil
 
 import (
 	"fmt"
+	"html"
+	"path/filepath"
+	"reflect"
 	"regexp"
+	"strconv"
 	"strings"
+	"text/template"
 	"unicode"
 
+	"go.chromium.org/chromiumos/dbusbindings/dbustype"
 	"go.chromium.org/chromiumos/dbusbindings/introspect"
+	"go.chromium.org/chromiumos/dbusbindings/serviceconfig"
 )
 
 // GenerateHeaderGuard generates a string of a header guard.
@@ -30,9 +37,37 @@ func GenerateHeaderGuard(path string) string {
 	return strings.Map(mapping, s)
 }
 
+// GenerateModuleName generates a name of a C++20 module from the path of
+// the module interface unit. Each directory of the path without the
+// extension makes a dot separated component of the name.
+func GenerateModuleName(path string) string {
+	path = strings.TrimSuffix(path, filepath.Ext(path))
+	mapping := func(r rune) rune {
+		switch {
+		case unicode.IsLetter(r):
+			return unicode.ToLower(r)
+		case unicode.IsDigit(r):
+			return r
+		default:
+			return '_'
+		}
+	}
+	components := []string{"chromeos_dbus_binding"}
+	for _, c := range strings.Split(path, "/") {
+		if c == "" || c == "." || c == ".." {
+			continue
+		}
+		c = strings.Map(mapping, c)
+		if unicode.IsDigit(rune(c[0])) {
+			c = "_" + c
+		}
+		components = append(components, c)
+	}
+	return strings.Join(components, ".")
+}
+
 func makeNameWithSuffix(itfName, suffix string) string {
-	s := strings.Split(itfName, ".")
-	return s[len(s)-1] + suffix
+	return itfName[strings.LastIndexByte(itfName, '.')+1:] + suffix
 }
 
 // MakeInterfaceName makes a name of the class defining the interface.
@@ -77,8 +112,360 @@ func MakeFullItfName(introspectItfName string) string {
 
 // ExtractNameSpaces extract the namespace parts of the interface from the interface name.
 func ExtractNameSpaces(introspectItfName string) []string {
-	s := strings.Split(introspectItfName, ".")
-	return s[:len(s)-1]
+	// The slice is pre-sized to avoid growing it in strings.Split, as this is
+	// called for every interface, method and signal.
+	ret := make([]string, 0, strings.Count(introspectItfName, "."))
+	for {
+		i := strings.IndexByte(introspectItfName, '.')
+		if i < 0 {
+			return ret
+		}
+		ret = append(ret, introspectItfName[:i])
+		introspectItfName = introspectItfName[i+1:]
+	}
+}
+
+// MakeNameSpaces returns the C++ namespaces of the classes generated for the
+// D-Bus name. If overrides has the name, the namespaces are taken from its
+// value, e.g. "wpa::supplicant", instead of the components of the name.
+func MakeNameSpaces(overrides map[string]string, name string) []string {
+	if ns, ok := overrides[name]; ok {
+		return strings.Split(ns, "::")
+	}
+	return ExtractNameSpaces(name)
+}
+
+// ApplyDefaultNameSpace returns overrides extended to put the interfaces in
+// introspects without namespace parts in their names, e.g. "Manager", in the
+// namespace defaultNS, unless overrides has them. It fails if there are such
+// interfaces but defaultNS is empty, so that they are not generated into the
+// global namespace. overrides is not modified.
+func ApplyDefaultNameSpace(introspects []introspect.Introspection, overrides map[string]string, defaultNS string) (map[string]string, error) {
+	ret, copied := overrides, false
+	for _, i := range introspects {
+		for _, itf := range i.Interfaces {
+			if strings.Contains(itf.Name, ".") {
+				continue
+			}
+			if _, ok := ret[itf.Name]; ok {
+				continue
+			}
+			if defaultNS == "" {
+				return nil, fmt.Errorf("interface %s has no namespace; specify default_namespace in the service config", itf.Name)
+			}
+			if !copied {
+				ret = make(map[string]string, len(overrides)+1)
+				for k, v := range overrides {
+					ret[k] = v
+				}
+				copied = true
+			}
+			ret[itf.Name] = defaultNS
+		}
+	}
+	return ret, nil
+}
+
+// MakeFullName returns the fully qualified C++ name for the D-Bus name, with
+// the namespaces given by MakeNameSpaces.
+func MakeFullName(overrides map[string]string, name string) string {
+	if ns, ok := overrides[name]; ok {
+		return ns + "::" + MakeTypeName(name)
+	}
+	return MakeFullItfName(name)
+}
+
+// CheckNameSpaceCollisions returns an error if two interfaces in introspects
+// are mapped to the same C++ name with overrides.
+func CheckNameSpaceCollisions(introspects []introspect.Introspection, overrides map[string]string) error {
+	seen := make(map[string]string)
+	for _, i := range introspects {
+		for _, itf := range i.Interfaces {
+			full := MakeFullName(overrides, itf.Name)
+			if other, ok := seen[full]; ok && other != itf.Name {
+				return fmt.Errorf("interfaces %s and %s are both mapped to %s", other, itf.Name, full)
+			}
+			seen[full] = itf.Name
+		}
+	}
+	return nil
+}
+
+// NameSpaceFuncs returns the template functions deriving C++ names from D-Bus
+// names, i.e. extractNameSpaces, makeFullItfName, makeFullProxyName and
+// makeFullProxyInterfaceName, which take overrides into account.
+func NameSpaceFuncs(overrides map[string]string) template.FuncMap {
+	return template.FuncMap{
+		"extractNameSpaces": func(name string) []string {
+			return MakeNameSpaces(overrides, name)
+		},
+		"makeFullItfName": func(name string) string {
+			return MakeFullName(overrides, name)
+		},
+		"makeFullProxyName": func(name string) string {
+			return MakeFullName(overrides, name) + "Proxy"
+		},
+		"makeFullProxyInterfaceName": func(name string) string {
+			return MakeFullName(overrides, name) + "ProxyInterface"
+		},
+	}
+}
+
+// MakeNameSpaceFuncs returns NameSpaceFuncs(overrides) after checking that
+// the interfaces in introspects do not collide.
+func MakeNameSpaceFuncs(introspects []introspect.Introspection, overrides map[string]string) (template.FuncMap, error) {
+	if err := CheckNameSpaceCollisions(introspects, overrides); err != nil {
+		return nil, err
+	}
+	return NameSpaceFuncs(overrides), nil
+}
+
+// OutputFile is a header of the output split per interface, and the
+// introspections containing the interfaces generated into it.
+type OutputFile struct {
+	Name        string
+	Introspects []introspect.Introspection
+}
+
+// SplitOutputFiles distributes the interfaces in introspects among the
+// headers named by outputFiles, which maps interface names to file names.
+// The interfaces not listed there are put in "<interface name>.h".
+// The files are ordered by the first interface generated into them.
+func SplitOutputFiles(introspects []introspect.Introspection, outputFiles map[string]string) []OutputFile {
+	var ret []OutputFile
+	index := make(map[string]int)
+	for _, i := range introspects {
+		for _, itf := range i.Interfaces {
+			name, ok := outputFiles[itf.Name]
+			if !ok {
+				name = itf.Name + ".h"
+			}
+			n, ok := index[name]
+			if !ok {
+				n = len(ret)
+				index[name] = n
+				ret = append(ret, OutputFile{Name: name})
+			}
+			f := &ret[n]
+			// Interfaces of the same node are kept together.
+			if last := len(f.Introspects) - 1; last >= 0 && f.Introspects[last].Name == i.Name {
+				f.Introspects[last].Interfaces = append(f.Introspects[last].Interfaces, itf)
+				continue
+			}
+			f.Introspects = append(f.Introspects, introspect.Introspection{
+				Name:       i.Name,
+				Interfaces: []introspect.Interface{itf},
+			})
+		}
+	}
+	return ret
+}
+
+// OmitSkippedMembers returns a copy of introspects without the methods,
+// signals and properties annotated to be skipped from the generated code of
+// target, e.g. introspect.SkipTargetProxy. If target is empty, only the
+// members skipped from all the generated code are omitted.
+func OmitSkippedMembers(introspects []introspect.Introspection, target string) []introspect.Introspection {
+	ret := make([]introspect.Introspection, len(introspects))
+	for i, is := range introspects {
+		ret[i] = is
+		ret[i].Interfaces = make([]introspect.Interface, len(is.Interfaces))
+		for j, itf := range is.Interfaces {
+			var methods []introspect.Method
+			for _, m := range itf.Methods {
+				if !m.Skipped(target) {
+					methods = append(methods, m)
+				}
+			}
+			var signals []introspect.Signal
+			for _, s := range itf.Signals {
+				if !s.Skipped(target) {
+					signals = append(signals, s)
+				}
+			}
+			var properties []introspect.Property
+			for _, p := range itf.Properties {
+				if !p.Skipped(target) {
+					properties = append(properties, p)
+				}
+			}
+			itf.Methods, itf.Signals, itf.Properties = methods, signals, properties
+			ret[i].Interfaces[j] = itf
+		}
+	}
+	return ret
+}
+
+// OmitNewerMembers returns a copy of introspects without the methods,
+// signals and properties whose org.chromium.DBus.MinVersion annotation is
+// greater than version, i.e. which the version of the service API does not
+// have yet. If version is zero, introspects is returned as is.
+func OmitNewerMembers(introspects []introspect.Introspection, version int) []introspect.Introspection {
+	if version == 0 {
+		return introspects
+	}
+	ret := make([]introspect.Introspection, len(introspects))
+	for i, is := range introspects {
+		ret[i] = is
+		ret[i].Interfaces = make([]introspect.Interface, len(is.Interfaces))
+		for j, itf := range is.Interfaces {
+			var methods []introspect.Method
+			for _, m := range itf.Methods {
+				if m.MinVersion() <= version {
+					methods = append(methods, m)
+				}
+			}
+			var signals []introspect.Signal
+			for _, s := range itf.Signals {
+				if s.MinVersion() <= version {
+					signals = append(signals, s)
+				}
+			}
+			var properties []introspect.Property
+			for _, p := range itf.Properties {
+				if p.MinVersion() <= version {
+					properties = append(properties, p)
+				}
+			}
+			itf.Methods, itf.Signals, itf.Properties = methods, signals, properties
+			ret[i].Interfaces[j] = itf
+		}
+	}
+	return ret
+}
+
+// cppTypeAnnotation renders an argument as the custom C++ type given as its value.
+const cppTypeAnnotation = "org.chromium.DBus.Argument.CppType"
+
+// matchTypeMapping returns the first of mappings matching the argument of
+// type sig named name, or nil if none matches.
+func matchTypeMapping(mappings []serviceconfig.TypeMapping, sig, name string) *serviceconfig.TypeMapping {
+	for i, m := range mappings {
+		if (m.Signature == "" || m.Signature == sig) && (m.ArgName == "" || m.ArgName == name) {
+			return &mappings[i]
+		}
+	}
+	return nil
+}
+
+// ApplyTypeMappings returns a copy of introspects whose method and signal
+// arguments matching mappings are annotated with
+// org.chromium.DBus.Argument.CppType, so that they are rendered as the mapped
+// C++ types. The arguments which already have an annotation are left as is.
+func ApplyTypeMappings(introspects []introspect.Introspection, mappings []serviceconfig.TypeMapping) []introspect.Introspection {
+	if len(mappings) == 0 {
+		return introspects
+	}
+	ret := make([]introspect.Introspection, len(introspects))
+	for i, is := range introspects {
+		ret[i] = is
+		ret[i].Interfaces = make([]introspect.Interface, len(is.Interfaces))
+		for j, itf := range is.Interfaces {
+			methods := make([]introspect.Method, len(itf.Methods))
+			for k, m := range itf.Methods {
+				m.Args = append([]introspect.MethodArg(nil), m.Args...)
+				for l, a := range m.Args {
+					if _, ok := a.TypeAnnotation(); ok {
+						continue
+					}
+					if tm := matchTypeMapping(mappings, string(a.Type), a.Name); tm != nil {
+						m.Args[l].Annotations = append(append([]introspect.Annotation(nil), a.Annotations...),
+							introspect.Annotation{Name: cppTypeAnnotation, Value: tm.CppType})
+					}
+				}
+				methods[k] = m
+			}
+			signals := make([]introspect.Signal, len(itf.Signals))
+			for k, s := range itf.Signals {
+				s.Args = append([]introspect.SignalArg(nil), s.Args...)
+				for l, a := range s.Args {
+					if _, ok := a.TypeAnnotation(); ok {
+						continue
+					}
+					if tm := matchTypeMapping(mappings, a.Type, a.Name); tm != nil {
+						s.Args[l].Annotations = append(append([]introspect.Annotation(nil), a.Annotations...),
+							introspect.Annotation{Name: cppTypeAnnotation, Value: tm.CppType})
+					}
+				}
+				signals[k] = s
+			}
+			itf.Methods, itf.Signals = methods, signals
+			ret[i].Interfaces[j] = itf
+		}
+	}
+	return ret
+}
+
+// ApplyObjectPaths returns a copy of introspects where the interfaces whose
+// object paths are overridden in interfaces are moved into introspections
+// named with the paths, so that their proxies are created at them. Each moved
+// interface follows the introspection it is declared in.
+func ApplyObjectPaths(introspects []introspect.Introspection, interfaces map[string]serviceconfig.InterfaceConfig) []introspect.Introspection {
+	if len(interfaces) == 0 {
+		return introspects
+	}
+	var ret []introspect.Introspection
+	for _, is := range introspects {
+		kept := is
+		kept.Interfaces = nil
+		var moved []introspect.Introspection
+		for _, itf := range is.Interfaces {
+			if path := interfaces[itf.Name].ObjectPath; path != "" && path != is.Name {
+				moved = append(moved, introspect.Introspection{
+					Name:       path,
+					Interfaces: []introspect.Interface{itf},
+				})
+				continue
+			}
+			kept.Interfaces = append(kept.Interfaces, itf)
+		}
+		if len(kept.Interfaces) > 0 || len(moved) == 0 {
+			ret = append(ret, kept)
+		}
+		ret = append(ret, moved...)
+	}
+	return ret
+}
+
+// MakeTypeMappingIncludes returns the #include targets of the headers of the
+// mappings whose C++ types are used by the arguments in introspects, without
+// duplicates. Paths which are not enclosed by <> or "" are quoted.
+func MakeTypeMappingIncludes(introspects []introspect.Introspection, mappings []serviceconfig.TypeMapping) []string {
+	used := make(map[string]bool)
+	for _, i := range introspects {
+		for _, itf := range i.Interfaces {
+			for _, m := range itf.Methods {
+				for _, a := range m.Args {
+					if v, ok := a.Annotation(cppTypeAnnotation); ok {
+						used[v] = true
+					}
+				}
+			}
+			for _, s := range itf.Signals {
+				for _, a := range s.Args {
+					if v, ok := a.Annotation(cppTypeAnnotation); ok {
+						used[v] = true
+					}
+				}
+			}
+		}
+	}
+	var ret []string
+	seen := make(map[string]bool)
+	for _, m := range mappings {
+		inc := m.Header
+		if inc == "" || !used[m.CppType] {
+			continue
+		}
+		if !strings.HasPrefix(inc, "<") && !strings.HasPrefix(inc, `"`) {
+			inc = fmt.Sprintf("%q", inc)
+		}
+		if !seen[inc] {
+			seen[inc] = true
+			ret = append(ret, inc)
+		}
+	}
+	return ret
 }
 
 // Reverse overwrites the slice in reverse order.
@@ -104,11 +491,21 @@ func Nindent(i int, s string) string {
 
 var indentRE = regexp.MustCompile(`^[ \t]+`)
 
-// FormatComment removes extraneous white space, inserts a double slash and adds an indent of |indent| characters
-// to each line for the string.
-// This function tries to retain indentation in the comments to maintain the comment layout.
-func FormatComment(docString introspect.DocString, indent int) string {
-	lines := strings.Split(string(docString), "\n")
+// tpTagRE matches the escaped telepathy tags left in the doc strings, e.g.
+// "<tp:member-ref>" decoded from "&lt;tp:member-ref&gt;".
+var tpTagRE = regexp.MustCompile(`</?tp:[^>]*>`)
+
+// commentWidth is the column at which FormatComment wraps the lines.
+const commentWidth = 80
+
+// DocStringLines returns the lines of the doc string without the leading and
+// trailing empty lines, trailing white spaces, and the indent of the first
+// line, so that the indentation relative to the first line is retained.
+// The HTML entities left in the doc string, e.g. "&lt;" in XMLs escaping
+// them twice, are decoded, and the telepathy tags are stripped.
+func DocStringLines(docString introspect.DocString) []string {
+	text := tpTagRE.ReplaceAllString(html.UnescapeString(string(docString)), "")
+	lines := strings.Split(text, "\n")
 
 	for i, line := range lines {
 		lines[i] = strings.TrimRight(line, " \t")
@@ -132,21 +529,62 @@ func FormatComment(docString introspect.DocString, indent int) string {
 	if len(lines) > 0 {
 		trimPrefix = indentRE.FindString(lines[0])
 	}
+	for i, line := range lines {
+		if strings.HasPrefix(line, trimPrefix) {
+			lines[i] = line[len(trimPrefix):]
+		} else {
+			lines[i] = strings.TrimLeft(line, " \t")
+		}
+	}
+	return lines
+}
+
+// wrapLine splits line at the spaces into lines no longer than width, each
+// keeping the indent of line, or of the text of the bullet if line starts
+// with "- " or "* ". A word longer than width is not split.
+func wrapLine(line string, width int) []string {
+	if len(line) <= width {
+		return []string{line}
+	}
+	indent := indentRE.FindString(line)
+	var ret []string
+	cur := indent
+	for _, w := range strings.Fields(line) {
+		if strings.TrimSpace(cur) != "" && len(cur)+1+len(w) > width {
+			ret = append(ret, cur)
+			cur = indent
+		}
+		if strings.TrimSpace(cur) != "" {
+			cur += " "
+		}
+		cur += w
+		if len(ret) == 0 && (w == "-" || w == "*") && cur == indent+w {
+			indent += "  "
+		}
+	}
+	return append(ret, cur)
+}
 
+// FormatComment removes extraneous white space, inserts a double slash and adds an indent of |indent| characters
+// to each line for the string.
+// This function tries to retain indentation in the comments to maintain the comment layout.
+// The lines are wrapped at 80 columns.
+func FormatComment(docString introspect.DocString, indent int) string {
 	var ret strings.Builder
 	prefix := strings.Repeat(" ", indent) + "//"
-	for _, line := range lines {
-		ret.WriteString(prefix)
-		if line != "" {
+	width := commentWidth - len(prefix) - 1
+	for _, line := range DocStringLines(docString) {
+		if line == "" {
+			ret.WriteString(prefix)
+			ret.WriteRune('\n')
+			continue
+		}
+		for _, l := range wrapLine(line, width) {
+			ret.WriteString(prefix)
 			ret.WriteString(" ")
-			if strings.HasPrefix(line, trimPrefix) {
-				line = line[len(trimPrefix):]
-			} else {
-				line = strings.TrimLeft(line, " \t")
-			}
-			ret.WriteString(line)
+			ret.WriteString(l)
+			ret.WriteRune('\n')
 		}
-		ret.WriteRune('\n')
 	}
 	return ret.String()
 }
@@ -154,9 +592,55 @@ func FormatComment(docString introspect.DocString, indent int) string {
 // ArgName makes a name of a method argument.
 func ArgName(prefix, argName string, argIndex int) string {
 	if argName == "" {
-		return fmt.Sprintf("%s_%d", prefix, argIndex)
+		return prefix + "_" + strconv.Itoa(argIndex)
+	}
+	return prefix + "_" + argName
+}
+
+// ArgNameWithStyle makes a name of a method argument in the given naming style.
+// For NamingStyleCamelCase, the name is the prefix followed by the CamelCase
+// argument name, e.g. "inFooBar", or by the index if the argument is unnamed.
+func ArgNameWithStyle(style serviceconfig.NamingStyle, prefix, argName string, argIndex int) string {
+	if style != serviceconfig.NamingStyleCamelCase {
+		return ArgName(prefix, argName, argIndex)
+	}
+	if argName == "" {
+		return prefix + strconv.Itoa(argIndex)
+	}
+	return prefix + MakeCamelCaseName(argName)
+}
+
+// MethodArgIndex returns the index naming the unnamed argument which is the
+// i-th, counted from 0, of the input arguments of a method, or of its output
+// arguments if out is set, given the number numIn of its input arguments. The
+// input arguments are numbered from 1 and the output arguments after them,
+// e.g. in_1 and out_2 for a method taking an argument and returning one,
+// whatever their order in the XML, so that every backend names an argument
+// the same.
+func MethodArgIndex(out bool, i, numIn int) int {
+	if out {
+		return numIn + i + 1
+	}
+	return i + 1
+}
+
+// UnnamedArgNames returns the names given to the unnamed arguments of m in
+// the given naming style, in the order of the input and the output
+// arguments, for the generated code to tell them in comments.
+func UnnamedArgNames(style serviceconfig.NamingStyle, m introspect.Method) []string {
+	var ret []string
+	in := m.InputArguments()
+	for i, a := range in {
+		if a.Name == "" {
+			ret = append(ret, ArgNameWithStyle(style, "in", "", MethodArgIndex(false, i, len(in))))
+		}
 	}
-	return fmt.Sprintf("%s_%s", prefix, argName)
+	for i, a := range m.OutputArguments() {
+		if a.Name == "" {
+			ret = append(ret, ArgNameWithStyle(style, "out", "", MethodArgIndex(true, i, len(in))))
+		}
+	}
+	return ret
 }
 
 var insertRE = regexp.MustCompile(`([^A-Z])([A-Z])`)
@@ -171,3 +655,557 @@ func MakeVariableName(s string) string {
 	}
 	return strings.ToLower(insertRE.ReplaceAllStringFunc(camelCase, f))
 }
+
+// MakeCamelCaseName discards the namespace parts and converts the name to a CamelCase name.
+func MakeCamelCaseName(s string) string {
+	var ret strings.Builder
+	for _, w := range strings.Split(MakeVariableName(s), "_") {
+		if w == "" {
+			continue
+		}
+		ret.WriteString(strings.ToUpper(w[:1]))
+		ret.WriteString(w[1:])
+	}
+	return ret.String()
+}
+
+// MakeConstantName converts the member name to the CamelCase name used in the
+// names of its constants, e.g. "BurnFinished" for "burn_finished". Unlike
+// MakeCamelCaseName, the names already in CamelCase, e.g. "BSSRemoved", are
+// kept as is.
+func MakeConstantName(s string) string {
+	var ret strings.Builder
+	for _, w := range strings.Split(s, "_") {
+		if w == "" {
+			continue
+		}
+		ret.WriteString(strings.ToUpper(w[:1]))
+		ret.WriteString(w[1:])
+	}
+	return ret.String()
+}
+
+// CheckConstantNameCollisions returns an error if two members of the same kind
+// of an interface in introspects are mapped to the same constant name by
+// MakeConstantName, e.g. "scan" and "Scan", as the constants would collide.
+func CheckConstantNameCollisions(introspects []introspect.Introspection) error {
+	for _, i := range introspects {
+		for _, itf := range i.Interfaces {
+			var methods, signals, properties []string
+			for _, m := range itf.Methods {
+				methods = append(methods, m.Name)
+			}
+			for _, s := range itf.Signals {
+				signals = append(signals, s.Name)
+			}
+			for _, p := range itf.Properties {
+				properties = append(properties, p.Name)
+			}
+			for _, members := range []struct {
+				kind  string
+				names []string
+			}{
+				{"Method", methods},
+				{"Signal", signals},
+				{"Property", properties},
+			} {
+				seen := make(map[string]string)
+				for _, name := range members.names {
+					c := MakeConstantName(name)
+					if other, ok := seen[c]; ok && other != name {
+						return fmt.Errorf("interface %s: %s and %s are both mapped to the constant k%s%s",
+							itf.Name, other, name, c, members.kind)
+					}
+					seen[c] = name
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// NamedStruct represents a C++ struct generated for struct-typed arguments
+// having the org.chromium.DBus.Struct.FieldNames annotation.
+type NamedStruct struct {
+	introspect.StructDef
+	Namespaces  []string
+	FullName    string
+	HeaderGuard string
+}
+
+// MakeNamedStructs returns the named structs used by the method and signal arguments of
+// the interface, without duplicates. The structs are defined in the namespace of the interface.
+func MakeNamedStructs(itf introspect.Interface) ([]NamedStruct, error) {
+	var defs []*introspect.StructDef
+	for _, m := range itf.Methods {
+		for _, a := range m.Args {
+			d, err := a.StructDef()
+			if err != nil {
+				return nil, err
+			}
+			defs = append(defs, d)
+		}
+	}
+	for _, s := range itf.Signals {
+		for _, a := range s.Args {
+			d, err := a.StructDef()
+			if err != nil {
+				return nil, err
+			}
+			defs = append(defs, d)
+		}
+	}
+
+	var ret []NamedStruct
+	seen := make(map[string]*introspect.StructDef)
+	ns := ExtractNameSpaces(itf.Name)
+	for _, d := range defs {
+		if d == nil {
+			continue
+		}
+		if prev, ok := seen[d.Name]; ok {
+			if !reflect.DeepEqual(prev, d) {
+				return nil, fmt.Errorf("struct %s is defined differently in %s", d.Name, itf.Name)
+			}
+			continue
+		}
+		seen[d.Name] = d
+		fullName := strings.Join(append(append([]string{}, ns...), d.Name), "::")
+		ret = append(ret, NamedStruct{
+			StructDef:   *d,
+			Namespaces:  ExtractNameSpaces(itf.Name),
+			FullName:    fullName,
+			HeaderGuard: GenerateHeaderGuard("struct::" + fullName),
+		})
+	}
+	return ret, nil
+}
+
+// NamedEnum is an enum which the integer arguments of an interface are rendered as,
+// and the brillo::dbus_utils::DBusType specialization to (de)serialize it is generated for.
+type NamedEnum struct {
+	introspect.EnumDef
+	HeaderGuard string
+}
+
+// MakeNamedEnums returns the enums given by the org.chromium.DBus.Argument.EnumClass
+// annotations of the arguments of itf. Each enum is returned once.
+func MakeNamedEnums(itf introspect.Interface) ([]NamedEnum, error) {
+	var defs []*introspect.EnumDef
+	for _, m := range itf.Methods {
+		for _, a := range m.Args {
+			d, err := a.EnumDef()
+			if err != nil {
+				return nil, err
+			}
+			defs = append(defs, d)
+		}
+	}
+	for _, s := range itf.Signals {
+		for _, a := range s.Args {
+			d, err := a.EnumDef()
+			if err != nil {
+				return nil, err
+			}
+			defs = append(defs, d)
+		}
+	}
+
+	var ret []NamedEnum
+	seen := make(map[string]*introspect.EnumDef)
+	for _, d := range defs {
+		if d == nil {
+			continue
+		}
+		if prev, ok := seen[d.Name]; ok {
+			if *prev != *d {
+				return nil, fmt.Errorf("enum %s is mapped to different types in %s", d.Name, itf.Name)
+			}
+			continue
+		}
+		seen[d.Name] = d
+		ret = append(ret, NamedEnum{
+			EnumDef:     *d,
+			HeaderGuard: GenerateHeaderGuard("enum::" + d.Name),
+		})
+	}
+	return ret, nil
+}
+
+// NamedFlags is a flags enum generated for the unsigned integer arguments having the
+// org.chromium.DBus.Argument.FlagsClass annotation.
+type NamedFlags struct {
+	introspect.FlagsDef
+	Namespaces  []string
+	FullName    string
+	HeaderGuard string
+}
+
+// MakeNamedFlags returns the flags enums used by the method and signal arguments of
+// the interface, without duplicates. The enums are defined in the namespace of the interface.
+func MakeNamedFlags(itf introspect.Interface) ([]NamedFlags, error) {
+	var defs []*introspect.FlagsDef
+	for _, m := range itf.Methods {
+		for _, a := range m.Args {
+			d, err := a.FlagsDef()
+			if err != nil {
+				return nil, err
+			}
+			defs = append(defs, d)
+		}
+	}
+	for _, s := range itf.Signals {
+		for _, a := range s.Args {
+			d, err := a.FlagsDef()
+			if err != nil {
+				return nil, err
+			}
+			defs = append(defs, d)
+		}
+	}
+
+	var ret []NamedFlags
+	seen := make(map[string]*introspect.FlagsDef)
+	ns := ExtractNameSpaces(itf.Name)
+	for _, d := range defs {
+		if d == nil {
+			continue
+		}
+		if prev, ok := seen[d.Name]; ok {
+			if !reflect.DeepEqual(prev, d) {
+				return nil, fmt.Errorf("flags %s is defined differently in %s", d.Name, itf.Name)
+			}
+			continue
+		}
+		seen[d.Name] = d
+		fullName := strings.Join(append(append([]string{}, ns...), d.Name), "::")
+		ret = append(ret, NamedFlags{
+			FlagsDef:    *d,
+			Namespaces:  ns,
+			FullName:    fullName,
+			HeaderGuard: GenerateHeaderGuard("flags::" + fullName),
+		})
+	}
+	return ret, nil
+}
+
+// UsesProtobuf returns true if any interface in introspects uses protobuf
+// classes given by org.chromium.DBus.Argument.ProtobufClass.
+func UsesProtobuf(introspects []introspect.Introspection) bool {
+	for _, i := range introspects {
+		for _, itf := range i.Interfaces {
+			if itf.UsesProtobuf() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// MakeProtobufClasses returns the protobuf classes given by the
+// org.chromium.DBus.Argument.ProtobufClass annotations of the arguments of
+// itf. Each class is returned once.
+func MakeProtobufClasses(itf introspect.Interface) []string {
+	var ret []string
+	seen := make(map[string]bool)
+	add := func(a introspect.Annotation) {
+		if a.Name == "org.chromium.DBus.Argument.ProtobufClass" && !seen[a.Value] {
+			seen[a.Value] = true
+			ret = append(ret, a.Value)
+		}
+	}
+	for _, m := range itf.Methods {
+		for _, a := range m.Args {
+			for _, an := range a.Annotations {
+				add(an)
+			}
+		}
+	}
+	for _, s := range itf.Signals {
+		for _, a := range s.Args {
+			for _, an := range a.Annotations {
+				add(an)
+			}
+		}
+	}
+	return ret
+}
+
+// ProtobufChecksTemplate defines the "protobufClassCheck" template, which
+// outputs the brillo::dbus_utils::CheckProtobufClass helper once per file,
+// and the "protobufChecks" template, which outputs its checks that the
+// protobuf classes returned by MakeProtobufClasses are protobuf messages, so
+// that a misconfigured annotation fails with an understandable error instead
+// of errors deep in the serialization templates. The helper is guarded so
+// that adaptors and proxies can be included together.
+const ProtobufChecksTemplate = `{{define "protobufClassCheck" -}}
+#ifndef CHROMEOS_DBUS_BINDINGS_DBUS_PROTOBUF_CLASS_CHECK_
+#define CHROMEOS_DBUS_BINDINGS_DBUS_PROTOBUF_CLASS_CHECK_
+namespace brillo {
+namespace dbus_utils {
+
+// Fails to compile unless T, given by an
+// org.chromium.DBus.Argument.ProtobufClass annotation, is a protobuf message.
+template <typename T>
+constexpr bool CheckProtobufClass() {
+  static_assert(std::is_base_of_v<google::protobuf::MessageLite, T>,
+                "The class given by org.chromium.DBus.Argument.ProtobufClass "
+                "is not a protobuf message; check the annotation and the "
+                "headers in org.chromium.DBus.Interface.ProtobufIncludes");
+  return true;
+}
+
+}  // namespace dbus_utils
+}  // namespace brillo
+#endif  // CHROMEOS_DBUS_BINDINGS_DBUS_PROTOBUF_CLASS_CHECK_
+{{- end}}
+{{define "protobufChecks" -}}
+{{range .}}static_assert(
+    brillo::dbus_utils::CheckProtobufClass<{{.}}>());
+{{end}}
+{{- end}}`
+
+// NamedEnumsTemplate defines the "namedEnums" template, which outputs the
+// brillo::dbus_utils::DBusType specializations to (de)serialize []NamedEnum as their
+// underlying integers. The enums themselves are defined by the users.
+// The specializations are guarded so that adaptors and proxies can be included together.
+const NamedEnumsTemplate = `{{define "namedEnums" -}}
+{{range .}}#ifndef {{.HeaderGuard}}
+#define {{.HeaderGuard}}
+namespace brillo {
+namespace dbus_utils {
+
+template <>
+struct DBusType<{{.Name}}> {
+  inline static std::string GetSignature() { return "{{.Signature}}"; }
+  inline static void Write(dbus::MessageWriter* writer, {{.Name}} value) {
+    DBusType<{{.UnderlyingType}}>::Write(writer, static_cast<{{.UnderlyingType}}>(value));
+  }
+  inline static bool Read(dbus::MessageReader* reader, {{.Name}}* value) {
+    {{.UnderlyingType}} raw;
+    if (!DBusType<{{.UnderlyingType}}>::Read(reader, &raw))
+      return false;
+    *value = static_cast<{{.Name}}>(raw);
+    return true;
+  }
+};
+
+}  // namespace dbus_utils
+}  // namespace brillo
+#endif  // {{.HeaderGuard}}
+
+{{end}}
+{{- end}}`
+
+// NamedFlagsTemplate defines the "namedFlags" template, which outputs the definitions
+// of []NamedFlags with their bitwise operators, and the brillo::dbus_utils::DBusType
+// specializations to (de)serialize them as their underlying integers.
+// The definitions are guarded so that adaptors and proxies can be included together.
+const NamedFlagsTemplate = `{{define "namedFlags" -}}
+{{range .}}#ifndef {{.HeaderGuard}}
+#define {{.HeaderGuard}}
+{{range .Namespaces -}}
+namespace {{.}} {
+{{end}}
+enum class {{.Name}} : {{.UnderlyingType}} {
+{{- range .Values}}
+  {{.Name}} = {{printf "0x%x" .Value}},
+{{- end}}
+};
+
+inline constexpr {{.Name}} operator|({{.Name}} a, {{.Name}} b) {
+  return static_cast<{{.Name}}>(static_cast<{{.UnderlyingType}}>(a) | static_cast<{{.UnderlyingType}}>(b));
+}
+inline constexpr {{.Name}} operator&({{.Name}} a, {{.Name}} b) {
+  return static_cast<{{.Name}}>(static_cast<{{.UnderlyingType}}>(a) & static_cast<{{.UnderlyingType}}>(b));
+}
+inline constexpr {{.Name}} operator^({{.Name}} a, {{.Name}} b) {
+  return static_cast<{{.Name}}>(static_cast<{{.UnderlyingType}}>(a) ^ static_cast<{{.UnderlyingType}}>(b));
+}
+inline constexpr {{.Name}} operator~({{.Name}} a) {
+  return static_cast<{{.Name}}>(~static_cast<{{.UnderlyingType}}>(a));
+}
+inline {{.Name}}& operator|=({{.Name}}& a, {{.Name}} b) {
+  return a = a | b;
+}
+inline {{.Name}}& operator&=({{.Name}}& a, {{.Name}} b) {
+  return a = a & b;
+}
+inline {{.Name}}& operator^=({{.Name}}& a, {{.Name}} b) {
+  return a = a ^ b;
+}
+
+{{range reverse .Namespaces -}}
+}  // namespace {{.}}
+{{end}}
+namespace brillo {
+namespace dbus_utils {
+
+template <>
+struct DBusType<{{.FullName}}> {
+  inline static std::string GetSignature() { return "{{.Signature}}"; }
+  inline static void Write(dbus::MessageWriter* writer, {{.FullName}} value) {
+    DBusType<{{.UnderlyingType}}>::Write(writer, static_cast<{{.UnderlyingType}}>(value));
+  }
+  inline static bool Read(dbus::MessageReader* reader, {{.FullName}}* value) {
+    {{.UnderlyingType}} raw;
+    if (!DBusType<{{.UnderlyingType}}>::Read(reader, &raw))
+      return false;
+    *value = static_cast<{{.FullName}}>(raw);
+    return true;
+  }
+};
+
+}  // namespace dbus_utils
+}  // namespace brillo
+#endif  // {{.HeaderGuard}}
+
+{{end}}
+{{- end}}`
+
+// HasOptionalArgs returns true if any method argument in introspects is rendered
+// as std::optional by the org.chromium.DBus.Argument.Optional annotation.
+func HasOptionalArgs(introspects []introspect.Introspection) bool {
+	for _, i := range introspects {
+		for _, itf := range i.Interfaces {
+			for _, m := range itf.Methods {
+				for _, a := range m.Args {
+					if a.Optional() {
+						return true
+					}
+				}
+			}
+		}
+	}
+	return false
+}
+
+// UsesTypeHeader returns true if the type of any argument or property in
+// introspects is declared in header, e.g. "<brillo/any.h>" for the variants,
+// so that the generated files include only the headers they need.
+func UsesTypeHeader(introspects []introspect.Introspection, header string) bool {
+	uses := func(sig string) bool {
+		hs, err := dbustype.CppHeaders(sig)
+		if err != nil {
+			// Invalid types are reported when the arguments are rendered.
+			return false
+		}
+		for _, h := range hs {
+			if h == header {
+				return true
+			}
+		}
+		return false
+	}
+	for _, i := range introspects {
+		for _, itf := range i.Interfaces {
+			for _, m := range itf.Methods {
+				for _, a := range m.Args {
+					if uses(string(a.Type)) {
+						return true
+					}
+				}
+			}
+			for _, sig := range itf.Signals {
+				for _, a := range sig.Args {
+					if uses(a.Type) {
+						return true
+					}
+				}
+			}
+			for _, p := range itf.Properties {
+				if uses(p.Type) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// OptionalTemplate defines the "optional" template, which outputs the
+// brillo::dbus_utils::DBusType specialization to (de)serialize std::optional as a
+// struct of a presence flag and the value. The value of an absent optional is
+// default-constructed, and ignored on read.
+// The specialization is guarded so that adaptors and proxies can be included together.
+const OptionalTemplate = `{{define "optional" -}}
+#ifndef CHROMEOS_DBUS_BINDINGS_DBUS_OPTIONAL_
+#define CHROMEOS_DBUS_BINDINGS_DBUS_OPTIONAL_
+namespace brillo {
+namespace dbus_utils {
+
+template <typename T>
+struct DBusType<std::optional<T>> {
+  using Tuple = std::tuple<bool, T>;
+
+  inline static std::string GetSignature() {
+    return DBusType<Tuple>::GetSignature();
+  }
+  inline static void Write(dbus::MessageWriter* writer,
+                           const std::optional<T>& value) {
+    DBusType<Tuple>::Write(writer, Tuple(value.has_value(), value.value_or(T())));
+  }
+  inline static bool Read(dbus::MessageReader* reader,
+                          std::optional<T>* value) {
+    Tuple tuple;
+    if (!DBusType<Tuple>::Read(reader, &tuple))
+      return false;
+    if (std::get<0>(tuple))
+      *value = std::move(std::get<1>(tuple));
+    else
+      value->reset();
+    return true;
+  }
+};
+
+}  // namespace dbus_utils
+}  // namespace brillo
+#endif  // CHROMEOS_DBUS_BINDINGS_DBUS_OPTIONAL_
+{{- end}}`
+
+// NamedStructsTemplate defines the "namedStructs" template, which outputs the definitions
+// of []NamedStruct and the brillo::dbus_utils::DBusType specializations to (de)serialize them.
+// The definitions are guarded so that adaptors and proxies can be included together.
+const NamedStructsTemplate = `{{define "namedStructs" -}}
+{{range .}}#ifndef {{.HeaderGuard}}
+#define {{.HeaderGuard}}
+{{range .Namespaces -}}
+namespace {{.}} {
+{{end}}
+struct {{.Name}} {
+{{- range .Fields}}
+  {{.Type}} {{.Name}};
+{{- end}}
+};
+
+{{range reverse .Namespaces -}}
+}  // namespace {{.}}
+{{end}}
+namespace brillo {
+namespace dbus_utils {
+
+template <>
+struct DBusType<{{.FullName}}> {
+  using Tuple = std::tuple<{{range $i, $f := .Fields}}{{if $i}}, {{end}}{{$f.Type}}{{end}}>;
+
+  inline static std::string GetSignature() { return "{{.Signature}}"; }
+  inline static void Write(dbus::MessageWriter* writer,
+                           const {{.FullName}}& value) {
+    DBusType<Tuple>::Write(writer, Tuple({{range $i, $f := .Fields}}{{if $i}}, {{end}}value.{{$f.Name}}{{end}}));
+  }
+  inline static bool Read(dbus::MessageReader* reader,
+                          {{.FullName}}* value) {
+    Tuple tuple;
+    if (!DBusType<Tuple>::Read(reader, &tuple))
+      return false;
+    std::tie({{range $i, $f := .Fields}}{{if $i}}, {{end}}value->{{$f.Name}}{{end}}) = std::move(tuple);
+    return true;
+  }
+};
+
+}  // namespace dbus_utils
+}  // namespace brillo
+#endif  // {{.HeaderGuard}}
+
+{{end}}
+{{- end}}`
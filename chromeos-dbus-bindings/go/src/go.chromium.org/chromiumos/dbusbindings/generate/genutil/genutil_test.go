@@ -9,6 +9,7 @@ import (
 
 	"go.chromium.org/chromiumos/dbusbindings/generate/genutil"
 	"go.chromium.org/chromiumos/dbusbindings/introspect"
+	"go.chromium.org/chromiumos/dbusbindings/serviceconfig"
 
 	"github.com/google/go-cmp/cmp"
 )
@@ -21,6 +22,14 @@ func TestGenerateHeaderGuard(t *testing.T) {
 	}
 }
 
+func TestGenerateModuleName(t *testing.T) {
+	got := genutil.GenerateModuleName("/foo/3bar_BAZ/dbus-proxies.ixx")
+	want := "chromeos_dbus_binding.foo._3bar_baz.dbus_proxies"
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("GenerateModuleName diff (-got +want):\n%s", diff)
+	}
+}
+
 func TestMakeInterfaceName(t *testing.T) {
 	got := genutil.MakeInterfaceName("foo.bar.BazQux")
 	want := "BazQuxInterface"
@@ -70,6 +79,379 @@ func TestExtractNameSpaces(t *testing.T) {
 	}
 }
 
+func BenchmarkExtractNameSpaces(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		genutil.ExtractNameSpaces("fi.w1.wpa_supplicant1.Interface")
+	}
+}
+
+func TestMakeNameSpaces(t *testing.T) {
+	overrides := map[string]string{"fi.w1.wpa_supplicant1.Interface": "wpa::supplicant"}
+	cases := []struct {
+		input string
+		want  []string
+	}{
+		{input: "fi.w1.wpa_supplicant1.Interface", want: []string{"wpa", "supplicant"}},
+		{input: "fi.w1.wpa_supplicant1.BSS", want: []string{"fi", "w1", "wpa_supplicant1"}},
+	}
+
+	for _, tc := range cases {
+		got := genutil.MakeNameSpaces(overrides, tc.input)
+		if diff := cmp.Diff(got, tc.want); diff != "" {
+			t.Errorf("Wrong result in MakeNameSpaces(%q): diff (-got +want):\n%s", tc.input, diff)
+		}
+	}
+}
+
+func TestMakeFullName(t *testing.T) {
+	overrides := map[string]string{"fi.w1.wpa_supplicant1.Interface": "wpa::supplicant"}
+	if got, want := genutil.MakeFullName(overrides, "fi.w1.wpa_supplicant1.Interface"), "wpa::supplicant::Interface"; got != want {
+		t.Errorf("Wrong result in MakeFullName: got %q, want %q", got, want)
+	}
+	if got, want := genutil.MakeFullName(overrides, "fi.w1.wpa_supplicant1.BSS"), "fi::w1::wpa_supplicant1::BSS"; got != want {
+		t.Errorf("Wrong result in MakeFullName: got %q, want %q", got, want)
+	}
+}
+
+func BenchmarkMakeFullName(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		genutil.MakeFullName(nil, "fi.w1.wpa_supplicant1.Interface")
+	}
+}
+
+func TestApplyDefaultNameSpace(t *testing.T) {
+	introspects := []introspect.Introspection{{
+		Interfaces: []introspect.Interface{
+			{Name: "org.chromium.Test"},
+			{Name: "Manager"},
+			{Name: "Device"},
+		},
+	}}
+	overrides := map[string]string{"Device": "shill::device"}
+	got, err := genutil.ApplyDefaultNameSpace(introspects, overrides, "shill")
+	if err != nil {
+		t.Fatalf("ApplyDefaultNameSpace failed: %v", err)
+	}
+	want := map[string]string{"Manager": "shill", "Device": "shill::device"}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("ApplyDefaultNameSpace diff (-got +want):\n%s", diff)
+	}
+	if len(overrides) != 1 {
+		t.Errorf("ApplyDefaultNameSpace modified overrides: %v", overrides)
+	}
+	if got := genutil.MakeFullName(got, "Manager"); got != "shill::Manager" {
+		t.Errorf("Wrong result in MakeFullName: got %q, want %q", got, "shill::Manager")
+	}
+
+	if _, err := genutil.ApplyDefaultNameSpace(introspects, overrides, ""); err == nil {
+		t.Error("ApplyDefaultNameSpace unexpectedly succeeded without the default namespace")
+	}
+	introspects[0].Interfaces = introspects[0].Interfaces[:1]
+	if _, err := genutil.ApplyDefaultNameSpace(introspects, nil, ""); err != nil {
+		t.Errorf("ApplyDefaultNameSpace failed without interfaces needing the default namespace: %v", err)
+	}
+}
+
+func TestMakeNameSpaceFuncsCollision(t *testing.T) {
+	introspects := []introspect.Introspection{{
+		Interfaces: []introspect.Interface{
+			{Name: "fi.w1.wpa_supplicant1.Interface"},
+			{Name: "wpa.supplicant.Interface"},
+		},
+	}}
+	if _, err := genutil.MakeNameSpaceFuncs(introspects, nil); err != nil {
+		t.Errorf("MakeNameSpaceFuncs failed without overrides: %v", err)
+	}
+	overrides := map[string]string{"fi.w1.wpa_supplicant1.Interface": "wpa::supplicant"}
+	if _, err := genutil.MakeNameSpaceFuncs(introspects, overrides); err == nil {
+		t.Error("MakeNameSpaceFuncs unexpectedly succeeded for colliding names")
+	}
+}
+
+func TestSplitOutputFiles(t *testing.T) {
+	introspects := []introspect.Introspection{{
+		Name: "/org/chromium/Foo",
+		Interfaces: []introspect.Interface{
+			{Name: "org.chromium.Foo"},
+			{Name: "org.chromium.FooDebug"},
+		},
+	}, {
+		Name: "/org/chromium/Bar",
+		Interfaces: []introspect.Interface{
+			{Name: "org.chromium.Bar"},
+		},
+	}}
+	outputFiles := map[string]string{
+		"org.chromium.Foo":      "foo/dbus_adaptor.h",
+		"org.chromium.FooDebug": "foo/dbus_adaptor.h",
+	}
+
+	got := genutil.SplitOutputFiles(introspects, outputFiles)
+	want := []genutil.OutputFile{{
+		Name: "foo/dbus_adaptor.h",
+		Introspects: []introspect.Introspection{{
+			Name: "/org/chromium/Foo",
+			Interfaces: []introspect.Interface{
+				{Name: "org.chromium.Foo"},
+				{Name: "org.chromium.FooDebug"},
+			},
+		}},
+	}, {
+		Name: "org.chromium.Bar.h",
+		Introspects: []introspect.Introspection{{
+			Name: "/org/chromium/Bar",
+			Interfaces: []introspect.Interface{
+				{Name: "org.chromium.Bar"},
+			},
+		}},
+	}}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("SplitOutputFiles failed (-got +want):\n%s", diff)
+	}
+}
+
+func TestOmitSkippedMembers(t *testing.T) {
+	introspects := []introspect.Introspection{{
+		Name: "/org/chromium/Test",
+		Interfaces: []introspect.Interface{{
+			Name: "org.chromium.Test",
+			Methods: []introspect.Method{
+				{Name: "Kept"},
+				{
+					Name: "Skipped",
+					Annotations: []introspect.Annotation{
+						{Name: "org.chromium.DBus.Skip", Value: "true"},
+					},
+				},
+				{
+					Name: "AdaptorOnly",
+					Annotations: []introspect.Annotation{
+						{Name: "org.chromium.DBus.SkipProxy", Value: "true"},
+					},
+				},
+			},
+			Signals: []introspect.Signal{{
+				Name: "Changed",
+				Annotations: []introspect.Annotation{
+					{Name: "org.chromium.DBus.SkipAdaptor", Value: "true"},
+				},
+			}},
+			Properties: []introspect.Property{{
+				Name: "Debug",
+				Type: "s",
+				Annotations: []introspect.Annotation{
+					{Name: "org.chromium.DBus.Skip", Value: "true"},
+					{Name: "org.freedesktop.DBus.Property.EmitsChangedSignal", Value: "invalidates"},
+				},
+			}},
+		}},
+	}}
+
+	cases := []struct {
+		target  string
+		methods []string
+		signals []string
+	}{
+		{target: "", methods: []string{"Kept", "AdaptorOnly"}, signals: []string{"Changed"}},
+		{target: introspect.SkipTargetProxy, methods: []string{"Kept"}, signals: []string{"Changed"}},
+		{target: introspect.SkipTargetAdaptor, methods: []string{"Kept", "AdaptorOnly"}, signals: nil},
+	}
+	for _, tc := range cases {
+		got := genutil.OmitSkippedMembers(introspects, tc.target)
+		itf := got[0].Interfaces[0]
+		var methods, signals []string
+		for _, m := range itf.Methods {
+			methods = append(methods, m.Name)
+		}
+		for _, s := range itf.Signals {
+			signals = append(signals, s.Name)
+		}
+		if diff := cmp.Diff(methods, tc.methods); diff != "" {
+			t.Errorf("OmitSkippedMembers(%q) methods mismatch (-got +want):\n%s", tc.target, diff)
+		}
+		if diff := cmp.Diff(signals, tc.signals); diff != "" {
+			t.Errorf("OmitSkippedMembers(%q) signals mismatch (-got +want):\n%s", tc.target, diff)
+		}
+		if len(itf.Properties) != 0 {
+			t.Errorf("OmitSkippedMembers(%q) kept properties: %v", tc.target, itf.Properties)
+		}
+	}
+
+	// The input must be kept as is.
+	if n := len(introspects[0].Interfaces[0].Methods); n != 3 {
+		t.Errorf("OmitSkippedMembers modified the input: got %d methods, want 3", n)
+	}
+}
+
+func TestOmitNewerMembers(t *testing.T) {
+	minVersion := func(v string) introspect.Annotation {
+		return introspect.Annotation{Name: "org.chromium.DBus.MinVersion", Value: v}
+	}
+	introspects := []introspect.Introspection{{
+		Name: "/org/chromium/Test",
+		Interfaces: []introspect.Interface{{
+			Name: "org.chromium.Test",
+			Methods: []introspect.Method{
+				{Name: "Original"},
+				{Name: "AddedInV2", Annotations: []introspect.Annotation{minVersion("2")}},
+				{Name: "AddedInV3", Annotations: []introspect.Annotation{minVersion("3")}},
+			},
+			Signals: []introspect.Signal{{
+				Name:        "Changed",
+				Annotations: []introspect.Annotation{minVersion("3")},
+			}},
+			Properties: []introspect.Property{{
+				Name: "Level",
+				Type: "i",
+				Annotations: []introspect.Annotation{
+					{Name: "org.freedesktop.DBus.Property.EmitsChangedSignal", Value: "invalidates"},
+					minVersion("2"),
+				},
+			}},
+		}},
+	}}
+
+	cases := []struct {
+		version    int
+		methods    []string
+		signals    []string
+		properties []string
+	}{
+		{version: 0, methods: []string{"Original", "AddedInV2", "AddedInV3"}, signals: []string{"Changed"}, properties: []string{"Level"}},
+		{version: 1, methods: []string{"Original"}, signals: nil, properties: nil},
+		{version: 2, methods: []string{"Original", "AddedInV2"}, signals: nil, properties: []string{"Level"}},
+	}
+	for _, tc := range cases {
+		itf := genutil.OmitNewerMembers(introspects, tc.version)[0].Interfaces[0]
+		var methods, signals, properties []string
+		for _, m := range itf.Methods {
+			methods = append(methods, m.Name)
+		}
+		for _, s := range itf.Signals {
+			signals = append(signals, s.Name)
+		}
+		for _, p := range itf.Properties {
+			properties = append(properties, p.Name)
+		}
+		if diff := cmp.Diff(methods, tc.methods); diff != "" {
+			t.Errorf("OmitNewerMembers(%d) methods mismatch (-got +want):\n%s", tc.version, diff)
+		}
+		if diff := cmp.Diff(signals, tc.signals); diff != "" {
+			t.Errorf("OmitNewerMembers(%d) signals mismatch (-got +want):\n%s", tc.version, diff)
+		}
+		if diff := cmp.Diff(properties, tc.properties); diff != "" {
+			t.Errorf("OmitNewerMembers(%d) properties mismatch (-got +want):\n%s", tc.version, diff)
+		}
+	}
+}
+
+func TestApplyTypeMappings(t *testing.T) {
+	introspects := []introspect.Introspection{{
+		Name: "/org/chromium/Test",
+		Interfaces: []introspect.Interface{{
+			Name: "org.chromium.Test",
+			Methods: []introspect.Method{{
+				Name: "SetAddresses",
+				Args: []introspect.MethodArg{
+					{Name: "ip_addresses", Type: "au"},
+					{Name: "ports", Type: "au"},
+					{
+						Name:        "config",
+						Type:        "ay",
+						Annotations: []introspect.Annotation{{Name: "org.chromium.DBus.Argument.ProtobufClass", Value: "Config"}},
+					},
+				},
+			}},
+			Signals: []introspect.Signal{{
+				Name: "AddressesChanged",
+				Args: []introspect.SignalArg{{Name: "ip_addresses", Type: "au"}},
+			}},
+		}},
+	}}
+	mappings := []serviceconfig.TypeMapping{{
+		Signature: "au",
+		ArgName:   "ip_addresses",
+		CppType:   "std::vector<net_base::IPAddress>",
+		Header:    "net-base/ip_address.h",
+	}, {
+		ArgName: "config",
+		CppType: "Unused",
+		Header:  "<unused.h>",
+	}}
+
+	got := genutil.ApplyTypeMappings(introspects, mappings)
+	itf := got[0].Interfaces[0]
+	var types []string
+	for _, a := range itf.Methods[0].Args {
+		typ, err := a.BaseType()
+		if err != nil {
+			t.Fatalf("BaseType of %s failed: %v", a.Name, err)
+		}
+		types = append(types, typ)
+	}
+	typ, err := itf.Signals[0].Args[0].BaseType()
+	if err != nil {
+		t.Fatalf("BaseType of the signal argument failed: %v", err)
+	}
+	types = append(types, typ)
+	want := []string{"std::vector<net_base::IPAddress>", "std::vector<uint32_t>", "Config", "std::vector<net_base::IPAddress>"}
+	if diff := cmp.Diff(types, want); diff != "" {
+		t.Errorf("ApplyTypeMappings types mismatch (-got +want):\n%s", diff)
+	}
+	if a := introspects[0].Interfaces[0].Methods[0].Args[0]; len(a.Annotations) != 0 {
+		t.Errorf("ApplyTypeMappings modified the input: %v", a)
+	}
+
+	includes := genutil.MakeTypeMappingIncludes(got, mappings)
+	if diff := cmp.Diff(includes, []string{`"net-base/ip_address.h"`}); diff != "" {
+		t.Errorf("MakeTypeMappingIncludes mismatch (-got +want):\n%s", diff)
+	}
+}
+
+func TestApplyObjectPaths(t *testing.T) {
+	introspects := []introspect.Introspection{{
+		Name: "/org/chromium/Test",
+		Interfaces: []introspect.Interface{
+			{Name: "org.chromium.Test"},
+			{Name: "org.chromium.Test.Device"},
+		},
+	}, {
+		Name: "/org/chromium/Other",
+		Interfaces: []introspect.Interface{
+			{Name: "org.chromium.Other"},
+		},
+	}}
+	interfaces := map[string]serviceconfig.InterfaceConfig{
+		"org.chromium.Test.Device": {ObjectPath: "/org/chromium/Test/Device"},
+		"org.chromium.Other":       {ObjectPath: "/org/chromium/Other/0"},
+		"org.chromium.Test":        {Namespace: "test"},
+	}
+
+	got := genutil.ApplyObjectPaths(introspects, interfaces)
+	want := []introspect.Introspection{{
+		Name: "/org/chromium/Test",
+		Interfaces: []introspect.Interface{
+			{Name: "org.chromium.Test"},
+		},
+	}, {
+		Name: "/org/chromium/Test/Device",
+		Interfaces: []introspect.Interface{
+			{Name: "org.chromium.Test.Device"},
+		},
+	}, {
+		Name: "/org/chromium/Other/0",
+		Interfaces: []introspect.Interface{
+			{Name: "org.chromium.Other"},
+		},
+	}}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("ApplyObjectPaths mismatch (-got +want):\n%s", diff)
+	}
+	if len(introspects[0].Interfaces) != 2 {
+		t.Errorf("ApplyObjectPaths modified the input: %v", introspects[0])
+	}
+}
+
 func TestReverse(t *testing.T) {
 	cases := []struct {
 		input, want []string
@@ -142,6 +524,21 @@ func TestFormatComment(t *testing.T) {
   //     line2
   //   - bullet2
   // line3
+`,
+		}, {
+			indent: 2,
+			input:  "Returns &lt;devices&gt; &amp;amp; <tp:member-ref>networks</tp:member-ref>.",
+			want:   "  // Returns <devices> &amp; networks.\n",
+		}, {
+			indent: 4,
+			input: `
+Frobinates the foo with the bar, unless the baz is set, in which case the foo is left as it is.
+  - The qux is frobinated with the foo and the bar, and the result is returned in the quux.
+`,
+			want: `    // Frobinates the foo with the bar, unless the baz is set, in which case the
+    // foo is left as it is.
+    //   - The qux is frobinated with the foo and the bar, and the result is
+    //     returned in the quux.
 `,
 		},
 	}
@@ -173,6 +570,61 @@ func TestArgName(t *testing.T) {
 	}
 }
 
+func BenchmarkArgName(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		genutil.ArgName("in", "", i)
+		genutil.ArgName("out", "ret", i)
+	}
+}
+
+func TestArgNameWithStyle(t *testing.T) {
+	cases := []struct {
+		style                 serviceconfig.NamingStyle
+		prefix, argName, want string
+		argIndex              int
+	}{
+		{style: "", prefix: "in", argName: "", argIndex: 1, want: "in_1"},
+		{style: serviceconfig.NamingStyleSnakeCase, prefix: "out", argName: "ret", argIndex: 3, want: "out_ret"},
+		{style: serviceconfig.NamingStyleCamelCase, prefix: "in", argName: "", argIndex: 2, want: "in2"},
+		{style: serviceconfig.NamingStyleCamelCase, prefix: "out", argName: "ret_code", argIndex: 3, want: "outRetCode"},
+	}
+
+	for _, tc := range cases {
+		got := genutil.ArgNameWithStyle(tc.style, tc.prefix, tc.argName, tc.argIndex)
+		if got != tc.want {
+			t.Errorf("Wrong result in ArgNameWithStyle(%q, %q, %q, %d):\ngot %s, want %s",
+				tc.style, tc.prefix, tc.argName, tc.argIndex, got, tc.want)
+		}
+	}
+}
+
+func TestUnnamedArgNames(t *testing.T) {
+	// The output arguments are numbered after the input ones, whatever their
+	// order in the XML.
+	m := introspect.Method{
+		Name: "Scan",
+		Args: []introspect.MethodArg{
+			{Type: "i", Direction: "out"},
+			{Type: "s", Direction: "in"},
+			{Name: "flags", Type: "u", Direction: "in"},
+			{Type: "b", Direction: "out"},
+		},
+	}
+	cases := []struct {
+		style serviceconfig.NamingStyle
+		want  []string
+	}{
+		{style: serviceconfig.NamingStyleSnakeCase, want: []string{"in_1", "out_3", "out_4"}},
+		{style: serviceconfig.NamingStyleCamelCase, want: []string{"in1", "out3", "out4"}},
+	}
+	for _, tc := range cases {
+		got := genutil.UnnamedArgNames(tc.style, m)
+		if diff := cmp.Diff(got, tc.want); diff != "" {
+			t.Errorf("UnnamedArgNames(%q) failed (-got +want):\n%s", tc.style, diff)
+		}
+	}
+}
+
 func TestMakeVariableName(t *testing.T) {
 	cases := []struct {
 		input, want string
@@ -191,3 +643,302 @@ func TestMakeVariableName(t *testing.T) {
 		}
 	}
 }
+
+func TestMakeCamelCaseName(t *testing.T) {
+	cases := []struct {
+		input, want string
+	}{
+		{"foo.bar.FooBar", "FooBar"},
+		{"foo", "Foo"},
+		{"bluetooth_class", "BluetoothClass"},
+		{"fooBarBaz", "FooBarBaz"},
+		{"UUID", "Uuid"},
+	}
+
+	for _, tc := range cases {
+		got := genutil.MakeCamelCaseName(tc.input)
+		if got != tc.want {
+			t.Errorf("Wrong result in MakeCamelCaseName(%q):\ngot %s, want %s", tc.input, got, tc.want)
+		}
+	}
+}
+
+func TestMakeConstantName(t *testing.T) {
+	cases := []struct {
+		input, want string
+	}{
+		{"burn_finished", "BurnFinished"},
+		{"foo", "Foo"},
+		{"fooBar", "FooBar"},
+		{"BSSRemoved", "BSSRemoved"},
+		{"_private__name", "PrivateName"},
+	}
+
+	for _, tc := range cases {
+		got := genutil.MakeConstantName(tc.input)
+		if got != tc.want {
+			t.Errorf("Wrong result in MakeConstantName(%q):\ngot %s, want %s", tc.input, got, tc.want)
+		}
+	}
+}
+
+func TestCheckConstantNameCollisions(t *testing.T) {
+	makeIntrospects := func(itf introspect.Interface) []introspect.Introspection {
+		itf.Name = "org.chromium.Test"
+		return []introspect.Introspection{{Interfaces: []introspect.Interface{itf}}}
+	}
+	cases := []struct {
+		name string
+		itf  introspect.Interface
+		want string
+	}{
+		{
+			name: "distinct",
+			itf: introspect.Interface{
+				Methods: []introspect.Method{{Name: "Scan"}, {Name: "scan_all"}},
+				Signals: []introspect.Signal{{Name: "Scan"}},
+			},
+		},
+		{
+			name: "methods differing in case",
+			itf:  introspect.Interface{Methods: []introspect.Method{{Name: "Scan"}, {Name: "scan"}}},
+			want: "interface org.chromium.Test: Scan and scan are both mapped to the constant kScanMethod",
+		},
+		{
+			name: "signals differing in underscores",
+			itf:  introspect.Interface{Signals: []introspect.Signal{{Name: "foo_bar"}, {Name: "FooBar"}}},
+			want: "interface org.chromium.Test: foo_bar and FooBar are both mapped to the constant kFooBarSignal",
+		},
+		{
+			name: "properties",
+			itf:  introspect.Interface{Properties: []introspect.Property{{Name: "Level"}, {Name: "level"}}},
+			want: "interface org.chromium.Test: Level and level are both mapped to the constant kLevelProperty",
+		},
+	}
+	for _, tc := range cases {
+		err := genutil.CheckConstantNameCollisions(makeIntrospects(tc.itf))
+		if tc.want == "" {
+			if err != nil {
+				t.Errorf("CheckConstantNameCollisions(%s) got error, want nil: %v", tc.name, err)
+			}
+			continue
+		}
+		if err == nil || err.Error() != tc.want {
+			t.Errorf("CheckConstantNameCollisions(%s) got error %v, want %q", tc.name, err, tc.want)
+		}
+	}
+}
+
+func TestMakeNamedStructs(t *testing.T) {
+	fieldNames := func(v string) introspect.Annotation {
+		return introspect.Annotation{Name: "org.chromium.DBus.Struct.FieldNames", Value: v}
+	}
+	itf := introspect.Interface{
+		Name: "org.chromium.Test",
+		Methods: []introspect.Method{
+			{
+				Name: "Scan",
+				Args: []introspect.MethodArg{
+					{Name: "request", Type: "(si)", Annotations: []introspect.Annotation{fieldNames("Entry(name, value)")}},
+					{Name: "flags", Type: "(b)"},
+				},
+			},
+		},
+		Signals: []introspect.Signal{
+			{
+				Name: "Found",
+				Args: []introspect.SignalArg{
+					{Name: "entries", Type: "a(si)", Annotations: []introspect.Annotation{fieldNames("Entry(name, value)")}},
+				},
+			},
+		},
+	}
+
+	got, err := genutil.MakeNamedStructs(itf)
+	if err != nil {
+		t.Fatalf("MakeNamedStructs got error, want nil: %v", err)
+	}
+	want := []genutil.NamedStruct{{
+		StructDef: introspect.StructDef{
+			Name:      "Entry",
+			Signature: "(si)",
+			Fields: []introspect.StructField{
+				{Name: "name", Type: "std::string"},
+				{Name: "value", Type: "int32_t"},
+			},
+		},
+		Namespaces:  []string{"org", "chromium"},
+		FullName:    "org::chromium::Entry",
+		HeaderGuard: "____CHROMEOS_DBUS_BINDING__STRUCT__ORG__CHROMIUM__ENTRY",
+	}}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("MakeNamedStructs failed (-got +want):\n%s", diff)
+	}
+
+	itf.Signals[0].Args[0].Annotations = []introspect.Annotation{fieldNames("Entry(key, value)")}
+	if _, err := genutil.MakeNamedStructs(itf); err == nil {
+		t.Error("MakeNamedStructs with conflicting definitions unexpectedly succeeded")
+	}
+}
+
+func TestMakeNamedEnums(t *testing.T) {
+	enumClass := func(v string) introspect.Annotation {
+		return introspect.Annotation{Name: "org.chromium.DBus.Argument.EnumClass", Value: v}
+	}
+	itf := introspect.Interface{
+		Name: "org.chromium.Test",
+		Methods: []introspect.Method{
+			{
+				Name: "SetMode",
+				Args: []introspect.MethodArg{
+					{Name: "mode", Type: "i", Annotations: []introspect.Annotation{enumClass("test::Mode")}},
+					{Name: "level", Type: "i"},
+				},
+			},
+		},
+		Signals: []introspect.Signal{
+			{
+				Name: "ModeChanged",
+				Args: []introspect.SignalArg{
+					{Name: "mode", Type: "i", Annotations: []introspect.Annotation{enumClass("test::Mode")}},
+				},
+			},
+		},
+	}
+
+	got, err := genutil.MakeNamedEnums(itf)
+	if err != nil {
+		t.Fatalf("MakeNamedEnums got error, want nil: %v", err)
+	}
+	want := []genutil.NamedEnum{{
+		EnumDef:     introspect.EnumDef{Name: "test::Mode", Signature: "i", UnderlyingType: "int32_t"},
+		HeaderGuard: genutil.GenerateHeaderGuard("enum::test::Mode"),
+	}}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("MakeNamedEnums failed (-got +want):\n%s", diff)
+	}
+
+	// The same enum must have the same underlying type.
+	itf.Signals[0].Args[0].Type = "u"
+	if _, err := genutil.MakeNamedEnums(itf); err == nil {
+		t.Error("MakeNamedEnums unexpectedly succeeded")
+	}
+}
+
+func TestMakeNamedFlags(t *testing.T) {
+	flagsClass := func(v string) introspect.Annotation {
+		return introspect.Annotation{Name: "org.chromium.DBus.Argument.FlagsClass", Value: v}
+	}
+	itf := introspect.Interface{
+		Name: "org.chromium.Test",
+		Methods: []introspect.Method{
+			{
+				Name: "Scan",
+				Args: []introspect.MethodArg{
+					{Name: "options", Type: "u", Annotations: []introspect.Annotation{flagsClass("ScanOptions(kActive, kPassive)")}},
+					{Name: "count", Type: "u"},
+				},
+			},
+		},
+		Signals: []introspect.Signal{
+			{
+				Name: "ScanDone",
+				Args: []introspect.SignalArg{
+					{Name: "options", Type: "u", Annotations: []introspect.Annotation{flagsClass("ScanOptions(kActive, kPassive)")}},
+				},
+			},
+		},
+	}
+
+	got, err := genutil.MakeNamedFlags(itf)
+	if err != nil {
+		t.Fatalf("MakeNamedFlags got error, want nil: %v", err)
+	}
+	want := []genutil.NamedFlags{{
+		FlagsDef: introspect.FlagsDef{
+			Name:           "ScanOptions",
+			Signature:      "u",
+			UnderlyingType: "uint32_t",
+			Values: []introspect.FlagsValue{
+				{Name: "kActive", Value: 1},
+				{Name: "kPassive", Value: 2},
+			},
+		},
+		Namespaces:  []string{"org", "chromium"},
+		FullName:    "org::chromium::ScanOptions",
+		HeaderGuard: genutil.GenerateHeaderGuard("flags::org::chromium::ScanOptions"),
+	}}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("MakeNamedFlags failed (-got +want):\n%s", diff)
+	}
+
+	// The same flags must have the same values.
+	itf.Signals[0].Args[0].Annotations = []introspect.Annotation{flagsClass("ScanOptions(kPassive, kActive)")}
+	if _, err := genutil.MakeNamedFlags(itf); err == nil {
+		t.Error("MakeNamedFlags unexpectedly succeeded")
+	}
+}
+
+func TestMakeProtobufClasses(t *testing.T) {
+	protobufClass := func(v string) introspect.Annotation {
+		return introspect.Annotation{Name: "org.chromium.DBus.Argument.ProtobufClass", Value: v}
+	}
+	itf := introspect.Interface{
+		Name: "org.chromium.Test",
+		Methods: []introspect.Method{{
+			Name: "Frobinate",
+			Args: []introspect.MethodArg{
+				{Name: "request", Type: "ay", Annotations: []introspect.Annotation{protobufClass("test::Request")}},
+				{Name: "response", Type: "ay", Direction: "out", Annotations: []introspect.Annotation{protobufClass("test::Response")}},
+				{Name: "data", Type: "ay"},
+			},
+		}},
+		Signals: []introspect.Signal{{
+			Name: "Frobinated",
+			Args: []introspect.SignalArg{
+				{Name: "response", Type: "ay", Annotations: []introspect.Annotation{protobufClass("test::Response")}},
+			},
+		}},
+	}
+
+	got := genutil.MakeProtobufClasses(itf)
+	if diff := cmp.Diff(got, []string{"test::Request", "test::Response"}); diff != "" {
+		t.Errorf("MakeProtobufClasses failed (-got +want):\n%s", diff)
+	}
+	introspects := []introspect.Introspection{{Interfaces: []introspect.Interface{itf}}}
+	if !genutil.UsesProtobuf(introspects) {
+		t.Error("UsesProtobuf unexpectedly false")
+	}
+}
+
+func TestUsesTypeHeader(t *testing.T) {
+	introspects := []introspect.Introspection{{
+		Interfaces: []introspect.Interface{{
+			Name: "org.chromium.Test",
+			Methods: []introspect.Method{{
+				Name: "GetFd",
+				Args: []introspect.MethodArg{{Name: "fd", Type: "h", Direction: "out"}},
+			}},
+			Signals: []introspect.Signal{{
+				Name: "Changed",
+				Args: []introspect.SignalArg{{Name: "values", Type: "a{sv}"}},
+			}},
+			Properties: []introspect.Property{{Name: "Path", Type: "o", Access: "read"}},
+		}},
+	}}
+
+	cases := []struct {
+		header string
+		want   bool
+	}{
+		{"<base/files/scoped_file.h>", true},
+		{"<brillo/variant_dictionary.h>", true},
+		{"<dbus/object_path.h>", true},
+		{"<brillo/any.h>", false},
+	}
+	for _, tc := range cases {
+		if got := genutil.UsesTypeHeader(introspects, tc.header); got != tc.want {
+			t.Errorf("UsesTypeHeader(%q) = %t; want %t", tc.header, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+// Copyright 2022 The ChromiumOS Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package genutil
+
+import (
+	"fmt"
+	"strings"
+
+	"go.chromium.org/chromiumos/dbusbindings/introspect"
+)
+
+// reservedSuffix is appended to the identifiers colliding with the reserved ones.
+const reservedSuffix = "_"
+
+// reservedIdentifiers are the C++ keywords, the macros defined by the system
+// headers, and the names used by the generated code, which the identifiers
+// derived from the introspection must not collide with.
+var reservedIdentifiers = map[string]bool{
+	// C++ keywords.
+	"alignas": true, "alignof": true, "and": true, "and_eq": true, "asm": true,
+	"auto": true, "bitand": true, "bitor": true, "bool": true, "break": true,
+	"case": true, "catch": true, "char": true, "char8_t": true, "char16_t": true,
+	"char32_t": true, "class": true, "compl": true, "concept": true, "const": true,
+	"consteval": true, "constexpr": true, "constinit": true, "const_cast": true,
+	"continue": true, "co_await": true, "co_return": true, "co_yield": true,
+	"decltype": true, "default": true, "delete": true, "do": true, "double": true,
+	"dynamic_cast": true, "else": true, "enum": true, "explicit": true,
+	"export": true, "extern": true, "false": true, "float": true, "for": true,
+	"friend": true, "goto": true, "if": true, "inline": true, "int": true,
+	"long": true, "mutable": true, "namespace": true, "new": true,
+	"noexcept": true, "not": true, "not_eq": true, "nullptr": true,
+	"operator": true, "or": true, "or_eq": true, "private": true,
+	"protected": true, "public": true, "register": true,
+	"reinterpret_cast": true, "requires": true, "return": true, "short": true,
+	"signed": true, "sizeof": true, "static": true, "static_assert": true,
+	"static_cast": true, "struct": true, "switch": true, "template": true,
+	"this": true, "thread_local": true, "throw": true, "true": true, "try": true,
+	"typedef": true, "typeid": true, "typename": true, "union": true,
+	"unsigned": true, "using": true, "virtual": true, "void": true,
+	"volatile": true, "wchar_t": true, "while": true, "xor": true, "xor_eq": true,
+	// Macros of the system headers.
+	"major": true, "minor": true, "makedev": true, "errno": true, "assert": true,
+	"stdin": true, "stdout": true, "stderr": true,
+	// Names used by the generated code.
+	"error": true, "callback": true, "timeout_ms": true, "success_callback": true,
+	"error_callback": true, "signal_callback": true, "on_connected_callback": true,
+	"response": true, "message": true, "method_call": true, "sender": true,
+	"bus": true, "object_path": true, "service_name": true, "property_set": true,
+}
+
+// IsReservedIdentifier returns true if s must not be used as an identifier
+// in the generated code.
+func IsReservedIdentifier(s string) bool {
+	return reservedIdentifiers[s]
+}
+
+// RenameReservedIdentifiers returns a copy of introspects where the
+// identifiers colliding with the reserved ones are suffixed by "_", and the
+// warnings describing the renames.
+// The renamed identifiers are the accessor names of the properties, which are
+// renamed through the org.chromium.DBus.Argument.VariableName annotation, and
+// the struct field names given by the org.chromium.DBus.Struct.FieldNames
+// annotation. Argument names need no renames, as they are always prefixed in
+// the generated code. Method names cannot be renamed without changing the
+// D-Bus API, so only warnings are returned for them.
+func RenameReservedIdentifiers(introspects []introspect.Introspection) ([]introspect.Introspection, []string, error) {
+	var warnings []string
+	ret := make([]introspect.Introspection, len(introspects))
+	for i, is := range introspects {
+		ret[i] = is
+		ret[i].Interfaces = append([]introspect.Interface(nil), is.Interfaces...)
+		for j, itf := range is.Interfaces {
+			renamed := &ret[i].Interfaces[j]
+
+			renamed.Methods = append([]introspect.Method(nil), itf.Methods...)
+			for k, m := range itf.Methods {
+				if IsReservedIdentifier(m.Name) {
+					warnings = append(warnings, fmt.Sprintf("%s.%s: method name is a reserved C++ identifier", itf.Name, m.Name))
+				}
+				renamed.Methods[k].Args = append([]introspect.MethodArg(nil), m.Args...)
+				for l, a := range m.Args {
+					def, err := a.StructDef()
+					if err != nil {
+						return nil, nil, err
+					}
+					if v, ok := renameStructFields(def); ok {
+						warnings = append(warnings, fmt.Sprintf("%s.%s: struct fields are renamed to %s", itf.Name, m.Name, v))
+						a.Annotations = setAnnotationValue(a.Annotations, "org.chromium.DBus.Struct.FieldNames", v)
+					}
+					renamed.Methods[k].Args[l] = a
+				}
+			}
+
+			renamed.Signals = append([]introspect.Signal(nil), itf.Signals...)
+			for k, s := range itf.Signals {
+				renamed.Signals[k].Args = append([]introspect.SignalArg(nil), s.Args...)
+				for l, a := range s.Args {
+					def, err := a.StructDef()
+					if err != nil {
+						return nil, nil, err
+					}
+					if v, ok := renameStructFields(def); ok {
+						warnings = append(warnings, fmt.Sprintf("%s.%s: struct fields are renamed to %s", itf.Name, s.Name, v))
+						a.Annotations = setAnnotationValue(a.Annotations, "org.chromium.DBus.Struct.FieldNames", v)
+					}
+					renamed.Signals[k].Args[l] = a
+				}
+			}
+
+			renamed.Properties = append([]introspect.Property(nil), itf.Properties...)
+			for k, p := range itf.Properties {
+				if name := MakeVariableName(p.VariableName()); IsReservedIdentifier(name) {
+					// The other annotations of the property are kept.
+					var annotations []introspect.Annotation
+					for _, a := range p.Annotations {
+						if a.Name != "org.chromium.DBus.Argument.VariableName" {
+							annotations = append(annotations, a)
+						}
+					}
+					p.Annotations = append(annotations, introspect.Annotation{
+						Name:  "org.chromium.DBus.Argument.VariableName",
+						Value: name + reservedSuffix,
+					})
+					warnings = append(warnings, fmt.Sprintf("%s.%s: property variable name is renamed to %s", itf.Name, p.Name, p.VariableName()))
+				}
+				renamed.Properties[k] = p
+			}
+		}
+	}
+	return ret, warnings, nil
+}
+
+// renameStructFields returns the value of the org.chromium.DBus.Struct.FieldNames
+// annotation for def with the reserved field names renamed, and whether any
+// field is renamed.
+func renameStructFields(def *introspect.StructDef) (string, bool) {
+	if def == nil {
+		return "", false
+	}
+	renamed := false
+	var fields []string
+	for _, f := range def.Fields {
+		name := f.Name
+		if IsReservedIdentifier(name) {
+			name += reservedSuffix
+			renamed = true
+		}
+		fields = append(fields, name)
+	}
+	return fmt.Sprintf("%s(%s)", def.Name, strings.Join(fields, ", ")), renamed
+}
+
+// setAnnotationValue returns a copy of annotations where the annotation named
+// name has value.
+func setAnnotationValue(annotations []introspect.Annotation, name, value string) []introspect.Annotation {
+	ret := append([]introspect.Annotation(nil), annotations...)
+	for i := range ret {
+		if ret[i].Name == name {
+			ret[i].Value = value
+		}
+	}
+	return ret
+}
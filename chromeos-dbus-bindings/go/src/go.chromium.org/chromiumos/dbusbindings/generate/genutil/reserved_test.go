@@ -0,0 +1,128 @@
+// Copyright 2022 The ChromiumOS Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package genutil_test
+
+import (
+	"testing"
+
+	"go.chromium.org/chromiumos/dbusbindings/generate/genutil"
+	"go.chromium.org/chromiumos/dbusbindings/introspect"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestIsReservedIdentifier(t *testing.T) {
+	cases := []struct {
+		input string
+		want  bool
+	}{
+		{input: "class", want: true},
+		{input: "major", want: true},
+		{input: "timeout_ms", want: true},
+		{input: "name", want: false},
+		{input: "Class", want: false},
+	}
+	for _, tc := range cases {
+		if got := genutil.IsReservedIdentifier(tc.input); got != tc.want {
+			t.Errorf("IsReservedIdentifier(%q) got %t, want %t", tc.input, got, tc.want)
+		}
+	}
+}
+
+func TestRenameReservedIdentifiers(t *testing.T) {
+	introspects := []introspect.Introspection{{
+		Interfaces: []introspect.Interface{{
+			Name: "org.chromium.Test",
+			Methods: []introspect.Method{{
+				Name: "delete",
+				Args: []introspect.MethodArg{{
+					Name: "device",
+					Type: "(uu)",
+					Annotations: []introspect.Annotation{{
+						Name:  "org.chromium.DBus.Struct.FieldNames",
+						Value: "Device(major, minor)",
+					}},
+				}},
+			}},
+			Signals: []introspect.Signal{{
+				Name: "Changed",
+				Args: []introspect.SignalArg{{
+					Name: "value",
+					Type: "(si)",
+					Annotations: []introspect.Annotation{{
+						Name:  "org.chromium.DBus.Struct.FieldNames",
+						Value: "Value(name, error)",
+					}},
+				}},
+			}},
+			Properties: []introspect.Property{
+				{Name: "Error", Type: "s"},
+				{Name: "Count", Type: "i"},
+			},
+		}},
+	}}
+
+	got, warnings, err := genutil.RenameReservedIdentifiers(introspects)
+	if err != nil {
+		t.Fatalf("RenameReservedIdentifiers failed: %v", err)
+	}
+
+	want := []introspect.Introspection{{
+		Interfaces: []introspect.Interface{{
+			Name: "org.chromium.Test",
+			Methods: []introspect.Method{{
+				Name: "delete",
+				Args: []introspect.MethodArg{{
+					Name: "device",
+					Type: "(uu)",
+					Annotations: []introspect.Annotation{{
+						Name:  "org.chromium.DBus.Struct.FieldNames",
+						Value: "Device(major_, minor_)",
+					}},
+				}},
+			}},
+			Signals: []introspect.Signal{{
+				Name: "Changed",
+				Args: []introspect.SignalArg{{
+					Name: "value",
+					Type: "(si)",
+					Annotations: []introspect.Annotation{{
+						Name:  "org.chromium.DBus.Struct.FieldNames",
+						Value: "Value(name, error_)",
+					}},
+				}},
+			}},
+			Properties: []introspect.Property{
+				{
+					Name: "Error",
+					Type: "s",
+					Annotations: []introspect.Annotation{{
+						Name:  "org.chromium.DBus.Argument.VariableName",
+						Value: "error_",
+					}},
+				},
+				{Name: "Count", Type: "i"},
+			},
+		}},
+	}}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("RenameReservedIdentifiers failed (-got +want):\n%s", diff)
+	}
+
+	wantWarnings := []string{
+		"org.chromium.Test.delete: method name is a reserved C++ identifier",
+		"org.chromium.Test.delete: struct fields are renamed to Device(major_, minor_)",
+		"org.chromium.Test.Changed: struct fields are renamed to Value(name, error_)",
+		"org.chromium.Test.Error: property variable name is renamed to error_",
+	}
+	if diff := cmp.Diff(warnings, wantWarnings); diff != "" {
+		t.Errorf("RenameReservedIdentifiers warnings mismatch (-got +want):\n%s", diff)
+	}
+
+	// The input must be kept as is.
+	if v := introspects[0].Interfaces[0].Methods[0].Args[0].Annotations[0].Value; v != "Device(major, minor)" {
+		t.Errorf("RenameReservedIdentifiers modified the input: got %q", v)
+	}
+}
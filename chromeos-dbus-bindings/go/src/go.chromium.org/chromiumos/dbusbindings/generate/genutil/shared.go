@@ -0,0 +1,68 @@
+// Copyright 2022 The ChromiumOS Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package genutil
+
+import (
+	"fmt"
+	"reflect"
+
+	"go.chromium.org/chromiumos/dbusbindings/introspect"
+)
+
+// SplitSharedInterfaces splits the introspections of several services, given
+// by services, into the interfaces used by more than one service and the
+// remaining ones of each service. The shared interfaces are returned once, in
+// the order of their first use. It returns an error if the definitions of a
+// shared interface, including the name of its node, differ between services.
+func SplitSharedInterfaces(services [][]introspect.Introspection) ([]introspect.Introspection, [][]introspect.Introspection, error) {
+	type use struct {
+		node     string
+		itf      introspect.Interface
+		services map[int]bool
+	}
+	var names []string
+	uses := make(map[string]*use)
+	for i, introspects := range services {
+		for _, is := range introspects {
+			for _, itf := range is.Interfaces {
+				u, ok := uses[itf.Name]
+				if !ok {
+					names = append(names, itf.Name)
+					uses[itf.Name] = &use{node: is.Name, itf: itf, services: map[int]bool{i: true}}
+					continue
+				}
+				if u.node != is.Name || !reflect.DeepEqual(u.itf, itf) {
+					return nil, nil, fmt.Errorf("interface %s has different definitions between services", itf.Name)
+				}
+				u.services[i] = true
+			}
+		}
+	}
+
+	var shared []introspect.Introspection
+	for _, name := range names {
+		if u := uses[name]; len(u.services) > 1 {
+			shared = append(shared, introspect.Introspection{Name: u.node, Interfaces: []introspect.Interface{u.itf}})
+		}
+	}
+
+	rest := make([][]introspect.Introspection, len(services))
+	for i, introspects := range services {
+		for _, is := range introspects {
+			var itfs []introspect.Interface
+			for _, itf := range is.Interfaces {
+				if len(uses[itf.Name].services) == 1 {
+					itfs = append(itfs, itf)
+				}
+			}
+			if len(itfs) == 0 {
+				continue
+			}
+			is.Interfaces = itfs
+			rest[i] = append(rest[i], is)
+		}
+	}
+	return shared, rest, nil
+}
@@ -0,0 +1,55 @@
+// Copyright 2022 The ChromiumOS Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package genutil_test
+
+import (
+	"testing"
+
+	"go.chromium.org/chromiumos/dbusbindings/generate/genutil"
+	"go.chromium.org/chromiumos/dbusbindings/introspect"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSplitSharedInterfaces(t *testing.T) {
+	common := introspect.Interface{
+		Name:    "org.chromium.Common",
+		Methods: []introspect.Method{{Name: "Ping"}},
+	}
+	foo := introspect.Interface{Name: "org.chromium.Foo"}
+	bar := introspect.Interface{Name: "org.chromium.Bar"}
+	services := [][]introspect.Introspection{
+		{{Name: "/org/chromium/Common", Interfaces: []introspect.Interface{common}}, {Interfaces: []introspect.Interface{foo}}},
+		{{Interfaces: []introspect.Interface{bar}}, {Name: "/org/chromium/Common", Interfaces: []introspect.Interface{common}}},
+	}
+
+	shared, rest, err := genutil.SplitSharedInterfaces(services)
+	if err != nil {
+		t.Fatalf("SplitSharedInterfaces got error, want nil: %v", err)
+	}
+	wantShared := []introspect.Introspection{
+		{Name: "/org/chromium/Common", Interfaces: []introspect.Interface{common}},
+	}
+	if diff := cmp.Diff(shared, wantShared); diff != "" {
+		t.Errorf("SplitSharedInterfaces got unexpected shared interfaces (-got +want):\n%s", diff)
+	}
+	wantRest := [][]introspect.Introspection{
+		{{Interfaces: []introspect.Interface{foo}}},
+		{{Interfaces: []introspect.Interface{bar}}},
+	}
+	if diff := cmp.Diff(rest, wantRest); diff != "" {
+		t.Errorf("SplitSharedInterfaces got unexpected service interfaces (-got +want):\n%s", diff)
+	}
+}
+
+func TestSplitSharedInterfacesMismatch(t *testing.T) {
+	services := [][]introspect.Introspection{
+		{{Interfaces: []introspect.Interface{{Name: "org.chromium.Common", Methods: []introspect.Method{{Name: "Ping"}}}}}},
+		{{Interfaces: []introspect.Interface{{Name: "org.chromium.Common"}}}},
+	}
+	if _, _, err := genutil.SplitSharedInterfaces(services); err == nil {
+		t.Error("SplitSharedInterfaces succeeded unexpectedly")
+	}
+}
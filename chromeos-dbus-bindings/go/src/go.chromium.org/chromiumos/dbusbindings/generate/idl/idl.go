@@ -0,0 +1,186 @@
+// Copyright 2022 The ChromiumOS Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+// Package idl outputs gRPC service definitions in the protobuf language based
+// on introspects, for daemons migrating from D-Bus to gRPC.
+// The backend is experimental, and the definitions are meant as a starting
+// point to be kept in sync with the D-Bus interfaces.
+package idl
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/template"
+
+	"go.chromium.org/chromiumos/dbusbindings/dbustype"
+	"go.chromium.org/chromiumos/dbusbindings/generate/genutil"
+	"go.chromium.org/chromiumos/dbusbindings/introspect"
+)
+
+var funcMap = template.FuncMap{
+	"formatComment": genutil.FormatComment,
+	"inc":           func(i int) int { return i + 1 },
+}
+
+const templateText = `// Automatic generation of gRPC service definitions for:
+{{range .Introspects}}{{range .Interfaces -}}
+//  - {{.Name}}
+{{end}}{{end}}
+syntax = "proto3";
+{{- if .Package}}
+
+package {{.Package}};
+{{- end}}
+{{- with .Imports}}
+{{range .}}
+import "{{.}}";
+{{- end}}
+{{- end}}
+{{range .Services}}
+{{formatComment .DocString 0 -}}
+service {{.Name}} {
+{{- range $i, $rpc := .RPCs}}
+{{- if $i}}{{"\n"}}{{end}}
+{{formatComment .DocString 2 -}}
+{{"  "}}rpc {{.Name}}({{.Request}}) returns ({{.Response}});
+{{- end}}
+}
+{{end}}
+{{- range .Messages}}
+{{- if .Fields}}
+message {{.Name}} {
+{{- range $i, $f := .Fields}}
+  {{.Type}} {{.Name}} = {{inc $i}};
+{{- end}}
+}
+{{- else}}
+message {{.Name}} {}
+{{- end}}
+{{end -}}
+`
+
+// service is a gRPC service generated for a D-Bus interface.
+type service struct {
+	Name      string
+	DocString introspect.DocString
+	RPCs      []rpc
+}
+
+// rpc is a gRPC method generated for a D-Bus method.
+type rpc struct {
+	Name              string
+	DocString         introspect.DocString
+	Request, Response string
+}
+
+// protoFile is the contents of the generated .proto file.
+type protoFile struct {
+	Introspects []introspect.Introspection
+	Package     string
+	Imports     []string
+	Services    []service
+	Messages    []dbustype.ProtoMessage
+}
+
+// makeMessage returns the message named name whose fields are args, followed
+// by the messages defined for the types of the fields.
+func makeMessage(name string, args []introspect.MethodArg) ([]dbustype.ProtoMessage, error) {
+	msg := dbustype.ProtoMessage{Name: name}
+	var nested []dbustype.ProtoMessage
+	for i, a := range args {
+		fieldName := a.Name
+		if fieldName == "" {
+			fieldName = fmt.Sprintf("arg_%d", i+1)
+		}
+		var t string
+		if v, ok := a.Annotation("org.chromium.DBus.Argument.ProtobufClass"); ok {
+			t = strings.ReplaceAll(strings.TrimPrefix(v, "::"), "::", ".")
+		} else {
+			var msgs []dbustype.ProtoMessage
+			var err error
+			t, msgs, err = dbustype.ProtoType(string(a.Type), name+genutil.MakeCamelCaseName(fieldName))
+			if err != nil {
+				return nil, fmt.Errorf("%s argument: %v", fieldName, err)
+			}
+			nested = append(nested, msgs...)
+		}
+		msg.Fields = append(msg.Fields, dbustype.ProtoField{Type: t, Name: fieldName})
+	}
+	return append([]dbustype.ProtoMessage{msg}, nested...), nil
+}
+
+// makeProtoFile converts the interfaces in introspects into gRPC services.
+// All the interfaces must be in the same package, derived from the
+// interface names, and their method names must be unique, as the request and
+// response messages are named after them.
+func makeProtoFile(introspects []introspect.Introspection) (*protoFile, error) {
+	ret := &protoFile{Introspects: introspects}
+	pkgSet := false
+	imports := make(map[string]bool)
+	defined := make(map[string]string)
+	for _, is := range introspects {
+		for _, itf := range is.Interfaces {
+			pkg := strings.Join(genutil.ExtractNameSpaces(itf.Name), ".")
+			if !pkgSet {
+				ret.Package, pkgSet = pkg, true
+			} else if pkg != ret.Package {
+				return nil, fmt.Errorf("%s interface: package %q differs from %q", itf.Name, pkg, ret.Package)
+			}
+			for _, inc := range itf.ProtobufIncludes() {
+				imports[strings.TrimSuffix(inc, ".pb.h")+".proto"] = true
+			}
+
+			svc := service{Name: genutil.MakeTypeName(itf.Name), DocString: itf.DocString}
+			for _, m := range itf.Methods {
+				r := rpc{Name: m.Name, DocString: m.DocString, Request: m.Name + "Request", Response: m.Name + "Response"}
+				for _, part := range []struct {
+					name string
+					args []introspect.MethodArg
+				}{{r.Request, m.InputArguments()}, {r.Response, m.OutputArguments()}} {
+					msgs, err := makeMessage(part.name, part.args)
+					if err != nil {
+						return nil, fmt.Errorf("%s interface: %s method: %v", itf.Name, m.Name, err)
+					}
+					for _, msg := range msgs {
+						if prev, ok := defined[msg.Name]; ok {
+							return nil, fmt.Errorf("%s interface: %s method: message %s is already defined for %s", itf.Name, m.Name, msg.Name, prev)
+						}
+						defined[msg.Name] = itf.Name + "." + m.Name
+						for _, f := range msg.Fields {
+							if strings.Contains(f.Type, "google.protobuf.Any") {
+								imports["google/protobuf/any.proto"] = true
+							}
+						}
+					}
+					ret.Messages = append(ret.Messages, msgs...)
+				}
+				svc.RPCs = append(svc.RPCs, r)
+			}
+			ret.Services = append(ret.Services, svc)
+		}
+	}
+	for imp := range imports {
+		ret.Imports = append(ret.Imports, imp)
+	}
+	sort.Strings(ret.Imports)
+	return ret, nil
+}
+
+// Generate outputs the gRPC service definitions for the interfaces included in
+// introspects into f. Each interface is converted into a service, and each of
+// its methods into an rpc taking a request message made of the input
+// arguments and returning a response message made of the output arguments.
+func Generate(introspects []introspect.Introspection, f io.Writer) error {
+	p, err := makeProtoFile(introspects)
+	if err != nil {
+		return err
+	}
+	tmpl, err := template.New("idl").Funcs(funcMap).Parse(templateText)
+	if err != nil {
+		return err
+	}
+	return tmpl.Execute(f, p)
+}
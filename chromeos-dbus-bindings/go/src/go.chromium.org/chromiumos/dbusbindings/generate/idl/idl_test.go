@@ -0,0 +1,156 @@
+// Copyright 2022 The ChromiumOS Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package idl
+
+import (
+	"bytes"
+	"testing"
+
+	"go.chromium.org/chromiumos/dbusbindings/introspect"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGenerate(t *testing.T) {
+	introspections := []introspect.Introspection{{
+		Interfaces: []introspect.Interface{{
+			Name:      "org.chromium.Frobinator",
+			DocString: "Frobinates things.",
+			Annotations: []introspect.Annotation{
+				{Name: "org.chromium.DBus.Interface.ProtobufIncludes", Value: "frobinator/proto_bindings/frobinator.pb.h"},
+			},
+			Methods: []introspect.Method{
+				{
+					Name: "Frobinate",
+					Args: []introspect.MethodArg{
+						{Name: "foo", Type: "i"},
+						{Name: "options", Type: "a{sv}"},
+						{Name: "bar", Type: "s", Direction: "out"},
+						{Type: "a(ou)", Direction: "out"},
+					},
+					DocString: "Frobinates the foo.",
+				},
+				{
+					Name: "Reset",
+				},
+				{
+					Name: "Configure",
+					Args: []introspect.MethodArg{
+						{
+							Name:        "config",
+							Type:        "ay",
+							Annotations: []introspect.Annotation{{Name: "org.chromium.DBus.Argument.ProtobufClass", Value: "frobinator::Config"}},
+						},
+						{Name: "groups", Type: "aas", Direction: "out"},
+					},
+				},
+			},
+		}},
+	}}
+
+	out := new(bytes.Buffer)
+	if err := Generate(introspections, out); err != nil {
+		t.Fatalf("Generate got error, want nil: %v", err)
+	}
+
+	const want = `// Automatic generation of gRPC service definitions for:
+//  - org.chromium.Frobinator
+
+syntax = "proto3";
+
+package org.chromium;
+
+import "frobinator/proto_bindings/frobinator.proto";
+import "google/protobuf/any.proto";
+
+// Frobinates things.
+service Frobinator {
+  // Frobinates the foo.
+  rpc Frobinate(FrobinateRequest) returns (FrobinateResponse);
+
+  rpc Reset(ResetRequest) returns (ResetResponse);
+
+  rpc Configure(ConfigureRequest) returns (ConfigureResponse);
+}
+
+message FrobinateRequest {
+  int32 foo = 1;
+  map<string, google.protobuf.Any> options = 2;
+}
+
+message FrobinateResponse {
+  string bar = 1;
+  repeated FrobinateResponseArg2Item arg_2 = 2;
+}
+
+message FrobinateResponseArg2Item {
+  string field_1 = 1;
+  uint32 field_2 = 2;
+}
+
+message ResetRequest {}
+
+message ResetResponse {}
+
+message ConfigureRequest {
+  frobinator.Config config = 1;
+}
+
+message ConfigureResponse {
+  repeated ConfigureResponseGroupsItem groups = 1;
+}
+
+message ConfigureResponseGroupsItem {
+  repeated string value = 1;
+}
+`
+	if diff := cmp.Diff(out.String(), want); diff != "" {
+		t.Errorf("Generate failed (-got +want):\n%s", diff)
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	cases := []struct {
+		name       string
+		interfaces []introspect.Interface
+		want       string
+	}{{
+		name: "file descriptor",
+		interfaces: []introspect.Interface{{
+			Name: "org.chromium.Test",
+			Methods: []introspect.Method{{
+				Name: "Open",
+				Args: []introspect.MethodArg{{Name: "fd", Type: "h", Direction: "out"}},
+			}},
+		}},
+		want: "org.chromium.Test interface: Open method: fd argument: file descriptors have no protobuf type",
+	}, {
+		name: "different packages",
+		interfaces: []introspect.Interface{
+			{Name: "org.chromium.Test"},
+			{Name: "org.freedesktop.Test"},
+		},
+		want: `org.freedesktop.Test interface: package "org.freedesktop" differs from "org.chromium"`,
+	}, {
+		name: "duplicated messages",
+		interfaces: []introspect.Interface{
+			{Name: "org.chromium.Test1", Methods: []introspect.Method{{Name: "Reset"}}},
+			{Name: "org.chromium.Test2", Methods: []introspect.Method{{Name: "Reset"}}},
+		},
+		want: "org.chromium.Test2 interface: Reset method: message ResetRequest is already defined for org.chromium.Test1.Reset",
+	}}
+
+	for _, tc := range cases {
+		introspections := []introspect.Introspection{{Interfaces: tc.interfaces}}
+		err := Generate(introspections, new(bytes.Buffer))
+		if err == nil {
+			t.Errorf("%s: Generate unexpectedly succeeded", tc.name)
+			continue
+		}
+		if err.Error() != tc.want {
+			t.Errorf("%s: Generate err mismatch: got %q, want %q", tc.name, err, tc.want)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+// Copyright 2022 The ChromiumOS Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+// Package metadata outputs a header with constexpr tables describing the
+// methods, signals and properties of the interfaces, so that services and
+// fuzzers can iterate them at compile time instead of parsing the
+// introspection XML at runtime.
+package metadata
+
+import (
+	"io"
+	"strings"
+	"text/template"
+
+	"go.chromium.org/chromiumos/dbusbindings/generate/genutil"
+	"go.chromium.org/chromiumos/dbusbindings/introspect"
+)
+
+var funcMap = template.FuncMap{
+	"methodArgDirection": methodArgDirection,
+	"reverse":            genutil.Reverse,
+	"split":              strings.Split,
+}
+
+const templateText = `// Automatic generation of D-Bus introspection metadata for:
+{{range .Introspects}}{{range .Interfaces -}}
+//  - {{.Name}}
+{{end}}{{end -}}
+#ifndef {{.HeaderGuard}}
+#define {{.HeaderGuard}}
+#include <cstddef>
+
+#ifndef CHROMEOS_DBUS_BINDINGS_DBUS_METADATA_
+#define CHROMEOS_DBUS_BINDINGS_DBUS_METADATA_
+namespace chromeos_dbus_bindings {
+
+enum class DBusArgDirection { kIn, kOut };
+
+struct DBusArgMetadata {
+  const char* name;
+  const char* signature;
+  DBusArgDirection direction;
+};
+
+struct DBusMethodMetadata {
+  const char* name;
+  const char* in_signature;
+  const char* out_signature;
+  const DBusArgMetadata* args;
+  size_t num_args;
+};
+
+struct DBusSignalMetadata {
+  const char* name;
+  const DBusArgMetadata* args;
+  size_t num_args;
+};
+
+struct DBusPropertyMetadata {
+  const char* name;
+  const char* signature;
+  const char* access;
+};
+
+struct DBusInterfaceMetadata {
+  const char* name;
+  const DBusMethodMetadata* methods;
+  size_t num_methods;
+  const DBusSignalMetadata* signals;
+  size_t num_signals;
+  const DBusPropertyMetadata* properties;
+  size_t num_properties;
+};
+
+}  // namespace chromeos_dbus_bindings
+#endif  // CHROMEOS_DBUS_BINDINGS_DBUS_METADATA_
+{{range .Introspects}}{{range $itf := .Interfaces}}
+{{range split $itf.Name "." -}}
+namespace {{.}} {
+{{end}}
+{{- range $itf.Methods}}{{if .Args}}
+inline constexpr chromeos_dbus_bindings::DBusArgMetadata k{{.Name}}MethodArgs[] = {
+{{- range .Args}}
+    {"{{.Name}}", "{{.Type}}", chromeos_dbus_bindings::DBusArgDirection::{{methodArgDirection .}}},
+{{- end}}
+};
+{{end}}{{end}}
+{{- range $itf.Signals}}{{if .Args}}
+inline constexpr chromeos_dbus_bindings::DBusArgMetadata k{{.Name}}SignalArgs[] = {
+{{- range .Args}}
+    {"{{.Name}}", "{{.Type}}", chromeos_dbus_bindings::DBusArgDirection::kOut},
+{{- end}}
+};
+{{end}}{{end}}
+{{- if $itf.Methods}}
+inline constexpr chromeos_dbus_bindings::DBusMethodMetadata kMethodsMetadata[] = {
+{{- range $itf.Methods}}
+    {"{{.Name}}", "{{.InputSignature}}", "{{.OutputSignature}}",
+     {{if .Args}}k{{.Name}}MethodArgs, {{len .Args}}{{else}}nullptr, 0{{end}}},
+{{- end}}
+};
+{{end}}
+{{- if $itf.Signals}}
+inline constexpr chromeos_dbus_bindings::DBusSignalMetadata kSignalsMetadata[] = {
+{{- range $itf.Signals}}
+    {"{{.Name}}", {{if .Args}}k{{.Name}}SignalArgs, {{len .Args}}{{else}}nullptr, 0{{end}}},
+{{- end}}
+};
+{{end}}
+{{- if $itf.Properties}}
+inline constexpr chromeos_dbus_bindings::DBusPropertyMetadata kPropertiesMetadata[] = {
+{{- range $itf.Properties}}
+    {"{{.Name}}", "{{.Type}}", "{{.Access}}"},
+{{- end}}
+};
+{{end}}
+inline constexpr chromeos_dbus_bindings::DBusInterfaceMetadata kInterfaceMetadata = {
+    "{{$itf.Name}}",
+    {{if $itf.Methods}}kMethodsMetadata, {{len $itf.Methods}}{{else}}nullptr, 0{{end}},
+    {{if $itf.Signals}}kSignalsMetadata, {{len $itf.Signals}}{{else}}nullptr, 0{{end}},
+    {{if $itf.Properties}}kPropertiesMetadata, {{len $itf.Properties}}{{else}}nullptr, 0{{end}},
+};
+
+{{range split $itf.Name "." | reverse -}}
+}  // namespace {{.}}
+{{end -}}
+{{end}}{{end}}
+#endif  // {{.HeaderGuard}}
+`
+
+// methodArgDirection returns the DBusArgDirection enumerator of a.
+func methodArgDirection(a introspect.MethodArg) string {
+	if a.Direction == "out" {
+		return "kOut"
+	}
+	return "kIn"
+}
+
+// Generate prints the metadata tables for the interfaces included in
+// introspects. outputFilePath is used to make a unique header guard.
+func Generate(introspects []introspect.Introspection, f io.Writer, outputFilePath string) error {
+	tmpl, err := template.New("metadata").Funcs(funcMap).Parse(templateText)
+	if err != nil {
+		return err
+	}
+	return tmpl.Execute(f, struct {
+		Introspects []introspect.Introspection
+		HeaderGuard string
+	}{
+		Introspects: introspects,
+		HeaderGuard: genutil.GenerateHeaderGuard(outputFilePath),
+	})
+}
@@ -0,0 +1,169 @@
+// Copyright 2022 The ChromiumOS Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package metadata
+
+import (
+	"bytes"
+	"testing"
+
+	"go.chromium.org/chromiumos/dbusbindings/introspect"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGenerateMetadata(t *testing.T) {
+	introspections := []introspect.Introspection{
+		{
+			Name: "/org/chromium/Test",
+			Interfaces: []introspect.Interface{
+				{
+					Name: "org.chromium.Test",
+					Methods: []introspect.Method{
+						{
+							Name: "Scan",
+							Args: []introspect.MethodArg{
+								{Name: "timeout", Type: "i"},
+								{Name: "found", Type: "as", Direction: "out"},
+							},
+						},
+						{Name: "Stop"},
+					},
+					Signals: []introspect.Signal{
+						{
+							Name: "ScanDone",
+							Args: []introspect.SignalArg{
+								{Name: "success", Type: "b"},
+							},
+						},
+					},
+					Properties: []introspect.Property{
+						{Name: "Scanning", Type: "b", Access: "read"},
+					},
+				},
+			},
+		}, {
+			Interfaces: []introspect.Interface{
+				{Name: "org.chromium.Empty"},
+			},
+		},
+	}
+
+	out := new(bytes.Buffer)
+	if err := Generate(introspections, out, "/tmp/dbus-metadata.h"); err != nil {
+		t.Fatalf("Generate got error, want nil: %v", err)
+	}
+
+	const want = `// Automatic generation of D-Bus introspection metadata for:
+//  - org.chromium.Test
+//  - org.chromium.Empty
+#ifndef ____CHROMEOS_DBUS_BINDING___TMP_DBUS_METADATA_H
+#define ____CHROMEOS_DBUS_BINDING___TMP_DBUS_METADATA_H
+#include <cstddef>
+
+#ifndef CHROMEOS_DBUS_BINDINGS_DBUS_METADATA_
+#define CHROMEOS_DBUS_BINDINGS_DBUS_METADATA_
+namespace chromeos_dbus_bindings {
+
+enum class DBusArgDirection { kIn, kOut };
+
+struct DBusArgMetadata {
+  const char* name;
+  const char* signature;
+  DBusArgDirection direction;
+};
+
+struct DBusMethodMetadata {
+  const char* name;
+  const char* in_signature;
+  const char* out_signature;
+  const DBusArgMetadata* args;
+  size_t num_args;
+};
+
+struct DBusSignalMetadata {
+  const char* name;
+  const DBusArgMetadata* args;
+  size_t num_args;
+};
+
+struct DBusPropertyMetadata {
+  const char* name;
+  const char* signature;
+  const char* access;
+};
+
+struct DBusInterfaceMetadata {
+  const char* name;
+  const DBusMethodMetadata* methods;
+  size_t num_methods;
+  const DBusSignalMetadata* signals;
+  size_t num_signals;
+  const DBusPropertyMetadata* properties;
+  size_t num_properties;
+};
+
+}  // namespace chromeos_dbus_bindings
+#endif  // CHROMEOS_DBUS_BINDINGS_DBUS_METADATA_
+
+namespace org {
+namespace chromium {
+namespace Test {
+
+inline constexpr chromeos_dbus_bindings::DBusArgMetadata kScanMethodArgs[] = {
+    {"timeout", "i", chromeos_dbus_bindings::DBusArgDirection::kIn},
+    {"found", "as", chromeos_dbus_bindings::DBusArgDirection::kOut},
+};
+
+inline constexpr chromeos_dbus_bindings::DBusArgMetadata kScanDoneSignalArgs[] = {
+    {"success", "b", chromeos_dbus_bindings::DBusArgDirection::kOut},
+};
+
+inline constexpr chromeos_dbus_bindings::DBusMethodMetadata kMethodsMetadata[] = {
+    {"Scan", "i", "as",
+     kScanMethodArgs, 2},
+    {"Stop", "", "",
+     nullptr, 0},
+};
+
+inline constexpr chromeos_dbus_bindings::DBusSignalMetadata kSignalsMetadata[] = {
+    {"ScanDone", kScanDoneSignalArgs, 1},
+};
+
+inline constexpr chromeos_dbus_bindings::DBusPropertyMetadata kPropertiesMetadata[] = {
+    {"Scanning", "b", "read"},
+};
+
+inline constexpr chromeos_dbus_bindings::DBusInterfaceMetadata kInterfaceMetadata = {
+    "org.chromium.Test",
+    kMethodsMetadata, 2,
+    kSignalsMetadata, 1,
+    kPropertiesMetadata, 1,
+};
+
+}  // namespace Test
+}  // namespace chromium
+}  // namespace org
+
+namespace org {
+namespace chromium {
+namespace Empty {
+
+inline constexpr chromeos_dbus_bindings::DBusInterfaceMetadata kInterfaceMetadata = {
+    "org.chromium.Empty",
+    nullptr, 0,
+    nullptr, 0,
+    nullptr, 0,
+};
+
+}  // namespace Empty
+}  // namespace chromium
+}  // namespace org
+
+#endif  // ____CHROMEOS_DBUS_BINDING___TMP_DBUS_METADATA_H
+`
+	if diff := cmp.Diff(out.String(), want); diff != "" {
+		t.Errorf("Generate failed (-got +want):\n%s", diff)
+	}
+}
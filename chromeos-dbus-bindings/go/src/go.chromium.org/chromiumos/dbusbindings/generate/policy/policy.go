@@ -0,0 +1,153 @@
+// Copyright 2022 The ChromiumOS Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+// Package policy outputs the D-Bus policy and the D-Bus service activation
+// file of a service based on introspects, so that the allowed methods are
+// kept in sync with the interfaces.
+package policy
+
+import (
+	"errors"
+	"io"
+	"text/template"
+
+	"go.chromium.org/chromiumos/dbusbindings/introspect"
+	"go.chromium.org/chromiumos/dbusbindings/serviceconfig"
+)
+
+// defaultExec is the Exec of the services which are not activatable.
+const defaultExec = "/bin/false"
+
+const policyTemplateText = `<!DOCTYPE busconfig PUBLIC "-//freedesktop//DTD D-BUS Bus Configuration 1.0//EN"
+  "http://www.freedesktop.org/standards/dbus/1.0/busconfig.dtd">
+<!--
+  Automatic generation of D-Bus policy for:
+{{- range .Introspects}}{{range .Interfaces}}
+  - {{.Name}}
+{{- end}}{{end}}
+-->
+<busconfig>
+  <policy user="{{.User}}">
+    <allow own="{{.ServiceName}}" />
+  </policy>
+{{- range .ClientUsers}}
+  <policy user="{{.}}">
+{{- range $.Members}}
+    <allow send_destination="{{$.ServiceName}}"
+           send_interface="{{.Interface}}"
+           send_member="{{.Member}}" />
+{{- end}}
+  </policy>
+{{- end}}
+  <policy context="default">
+    <deny send_destination="{{.ServiceName}}" />
+  </policy>
+</busconfig>
+`
+
+const serviceTemplateText = `[D-BUS Service]
+Name={{.ServiceName}}
+Exec={{.Exec}}
+User={{.User}}
+{{- if .SystemdService}}
+SystemdService={{.SystemdService}}
+{{- end}}
+`
+
+// member is a D-Bus method which the clients are allowed to call.
+type member struct {
+	Interface string
+	Member    string
+}
+
+// makeMembers returns the methods of introspects, followed by the methods of
+// the standard interfaces needed to access the properties and the objects.
+func makeMembers(introspects []introspect.Introspection, hasObjectManager bool) []member {
+	var ret []member
+	hasProperties, hasWritableProperties := false, false
+	for _, is := range introspects {
+		for _, itf := range is.Interfaces {
+			for _, m := range itf.Methods {
+				ret = append(ret, member{Interface: itf.Name, Member: m.Name})
+			}
+			for _, p := range itf.Properties {
+				hasProperties = true
+				if p.Access == "readwrite" || p.Access == "write" {
+					hasWritableProperties = true
+				}
+			}
+		}
+	}
+	if hasProperties {
+		ret = append(ret,
+			member{Interface: "org.freedesktop.DBus.Properties", Member: "Get"},
+			member{Interface: "org.freedesktop.DBus.Properties", Member: "GetAll"})
+	}
+	if hasWritableProperties {
+		ret = append(ret, member{Interface: "org.freedesktop.DBus.Properties", Member: "Set"})
+	}
+	if hasObjectManager {
+		ret = append(ret, member{Interface: "org.freedesktop.DBus.ObjectManager", Member: "GetManagedObjects"})
+	}
+	return ret
+}
+
+// Generate outputs the busconfig policy XML of the service into f, which
+// allows the service user to own the service name and the client users to
+// call the methods of introspects.
+func Generate(introspects []introspect.Introspection, f io.Writer, config serviceconfig.Config) error {
+	if config.Policy == nil {
+		return errors.New("policy is not configured")
+	}
+	tmpl, err := template.New("policy").Parse(policyTemplateText)
+	if err != nil {
+		return err
+	}
+
+	clientUsers := config.Policy.ClientUsers
+	if len(clientUsers) == 0 {
+		clientUsers = []string{"root"}
+	}
+	return tmpl.Execute(f, struct {
+		Introspects []introspect.Introspection
+		ServiceName string
+		User        string
+		ClientUsers []string
+		Members     []member
+	}{
+		Introspects: introspects,
+		ServiceName: config.ServiceName,
+		User:        config.Policy.User,
+		ClientUsers: clientUsers,
+		Members:     makeMembers(introspects, config.ObjectManager != nil),
+	})
+}
+
+// GenerateService outputs the D-Bus service activation file of the service
+// into f.
+func GenerateService(f io.Writer, config serviceconfig.Config) error {
+	if config.Policy == nil {
+		return errors.New("policy is not configured")
+	}
+	tmpl, err := template.New("service").Parse(serviceTemplateText)
+	if err != nil {
+		return err
+	}
+
+	exec := config.Policy.Exec
+	if exec == "" {
+		exec = defaultExec
+	}
+	return tmpl.Execute(f, struct {
+		ServiceName    string
+		Exec           string
+		User           string
+		SystemdService string
+	}{
+		ServiceName:    config.ServiceName,
+		Exec:           exec,
+		User:           config.Policy.User,
+		SystemdService: config.Policy.SystemdService,
+	})
+}
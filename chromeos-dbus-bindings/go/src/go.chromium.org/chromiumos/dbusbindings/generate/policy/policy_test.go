@@ -0,0 +1,141 @@
+// Copyright 2022 The ChromiumOS Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package policy
+
+import (
+	"bytes"
+	"testing"
+
+	"go.chromium.org/chromiumos/dbusbindings/introspect"
+	"go.chromium.org/chromiumos/dbusbindings/serviceconfig"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGenerate(t *testing.T) {
+	introspections := []introspect.Introspection{{
+		Name: "/org/chromium/Test",
+		Interfaces: []introspect.Interface{{
+			Name: "org.chromium.Test",
+			Methods: []introspect.Method{
+				{Name: "Ping"},
+				{Name: "GetStatus"},
+			},
+			Properties: []introspect.Property{
+				{Name: "Level", Type: "i", Access: "read"},
+			},
+		}},
+	}}
+	sc := serviceconfig.Config{
+		ServiceName: "org.chromium.TestService",
+		Policy: &serviceconfig.PolicyConfig{
+			User:        "testd",
+			ClientUsers: []string{"root", "chronos"},
+		},
+	}
+
+	out := new(bytes.Buffer)
+	if err := Generate(introspections, out, sc); err != nil {
+		t.Fatalf("Generate got error, want nil: %v", err)
+	}
+
+	const want = `<!DOCTYPE busconfig PUBLIC "-//freedesktop//DTD D-BUS Bus Configuration 1.0//EN"
+  "http://www.freedesktop.org/standards/dbus/1.0/busconfig.dtd">
+<!--
+  Automatic generation of D-Bus policy for:
+  - org.chromium.Test
+-->
+<busconfig>
+  <policy user="testd">
+    <allow own="org.chromium.TestService" />
+  </policy>
+  <policy user="root">
+    <allow send_destination="org.chromium.TestService"
+           send_interface="org.chromium.Test"
+           send_member="Ping" />
+    <allow send_destination="org.chromium.TestService"
+           send_interface="org.chromium.Test"
+           send_member="GetStatus" />
+    <allow send_destination="org.chromium.TestService"
+           send_interface="org.freedesktop.DBus.Properties"
+           send_member="Get" />
+    <allow send_destination="org.chromium.TestService"
+           send_interface="org.freedesktop.DBus.Properties"
+           send_member="GetAll" />
+  </policy>
+  <policy user="chronos">
+    <allow send_destination="org.chromium.TestService"
+           send_interface="org.chromium.Test"
+           send_member="Ping" />
+    <allow send_destination="org.chromium.TestService"
+           send_interface="org.chromium.Test"
+           send_member="GetStatus" />
+    <allow send_destination="org.chromium.TestService"
+           send_interface="org.freedesktop.DBus.Properties"
+           send_member="Get" />
+    <allow send_destination="org.chromium.TestService"
+           send_interface="org.freedesktop.DBus.Properties"
+           send_member="GetAll" />
+  </policy>
+  <policy context="default">
+    <deny send_destination="org.chromium.TestService" />
+  </policy>
+</busconfig>
+`
+	if diff := cmp.Diff(out.String(), want); diff != "" {
+		t.Errorf("Generate failed (-got +want):\n%s", diff)
+	}
+}
+
+func TestGenerateWithoutPolicy(t *testing.T) {
+	if err := Generate(nil, new(bytes.Buffer), serviceconfig.Config{}); err == nil {
+		t.Error("Generate succeeded unexpectedly")
+	}
+	if err := GenerateService(new(bytes.Buffer), serviceconfig.Config{}); err == nil {
+		t.Error("GenerateService succeeded unexpectedly")
+	}
+}
+
+func TestGenerateService(t *testing.T) {
+	cases := []struct {
+		policy serviceconfig.PolicyConfig
+		want   string
+	}{
+		{
+			policy: serviceconfig.PolicyConfig{User: "testd"},
+			want: `[D-BUS Service]
+Name=org.chromium.TestService
+Exec=/bin/false
+User=testd
+`,
+		}, {
+			policy: serviceconfig.PolicyConfig{
+				User:           "testd",
+				Exec:           "/usr/bin/testd",
+				SystemdService: "testd.service",
+			},
+			want: `[D-BUS Service]
+Name=org.chromium.TestService
+Exec=/usr/bin/testd
+User=testd
+SystemdService=testd.service
+`,
+		},
+	}
+	for _, tc := range cases {
+		policy := tc.policy
+		sc := serviceconfig.Config{
+			ServiceName: "org.chromium.TestService",
+			Policy:      &policy,
+		}
+		out := new(bytes.Buffer)
+		if err := GenerateService(out, sc); err != nil {
+			t.Fatalf("GenerateService got error, want nil: %v", err)
+		}
+		if diff := cmp.Diff(out.String(), tc.want); diff != "" {
+			t.Errorf("GenerateService failed (-got +want):\n%s", diff)
+		}
+	}
+}
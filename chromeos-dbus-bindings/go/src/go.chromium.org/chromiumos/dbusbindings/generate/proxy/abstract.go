@@ -0,0 +1,159 @@
+// Copyright 2022 The ChromiumOS Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package proxy
+
+import (
+	"io"
+
+	"go.chromium.org/chromiumos/dbusbindings/generate/genutil"
+	"go.chromium.org/chromiumos/dbusbindings/introspect"
+	"go.chromium.org/chromiumos/dbusbindings/serviceconfig"
+)
+
+const abstractTemplateText = `// Automatic generation of D-Bus proxy interfaces:
+{{range .Introspects}}{{range .Interfaces -}}
+//  - {{.Name}}
+{{end}}{{end -}}
+
+#ifndef {{.HeaderGuard}}
+#define {{.HeaderGuard}}
+{{- if .UseCoroutines}}
+#include <coroutine>
+#include <memory>
+{{- end}}
+{{- if hasOptionalArgs .Introspects}}
+#include <optional>
+{{- end}}
+#include <string>
+{{- if .UseCoroutines}}
+#include <tuple>
+{{- end}}
+{{- if or .UseCoroutines (usesProtobuf .Introspects)}}
+#include <type_traits>
+{{- end}}
+{{- if .UseCoroutines}}
+#include <utility>
+{{- end}}
+{{- if hasVariantTypes .Introspects}}
+#include <variant>
+{{- end}}
+#include <vector>
+
+{{if hasFDStream .Introspects -}}
+#include <base/files/file_util.h>
+{{end -}}
+{{if or (hasFDStream .Introspects) (usesTypeHeader .Introspects "<base/files/scoped_file.h>") -}}
+#include <base/files/scoped_file.h>
+{{end -}}
+{{if or .UseCoroutines (hasSignals .Introspects) -}}
+#include <base/functional/bind.h>
+{{end -}}
+#include <base/functional/callback.h>
+{{- if hasVariantTypes .Introspects}}
+#include <base/location.h>
+{{- end}}
+{{- if hasSignals .Introspects}}
+#include <base/memory/weak_ptr.h>
+{{- end}}
+{{- if .ExpectedResults}}
+#include <base/types/expected.h>
+{{- end}}
+{{- if or (hasVariantTypes .Introspects) (usesTypeHeader .Introspects "<brillo/any.h>")}}
+#include <brillo/any.h>
+{{- end}}
+{{- if or (hasNamedStructs .Introspects) (hasNamedFlags .Introspects) (hasOptionalArgs .Introspects)}}
+#include <brillo/dbus/data_serialization.h>
+{{- end}}
+#include <brillo/errors/error.h>
+{{- if or (hasMethodErrors .Introspects) (hasVariantTypes .Introspects)}}
+#include <brillo/errors/error_codes.h>
+{{- end}}
+{{- if usesTypeHeader .Introspects "<brillo/variant_dictionary.h>"}}
+#include <brillo/variant_dictionary.h>
+{{- end}}
+{{- if usesProtobuf .Introspects}}
+#include <google/protobuf/message_lite.h>
+{{- end}}
+{{- with makeProtobufIncludes .Introspects}}
+{{range .}}
+#include {{.}}
+{{- end}}
+{{- end}}
+{{- with .TypeMappingIncludes}}
+{{range .}}
+#include {{.}}
+{{- end}}
+{{- end}}
+
+namespace dbus {
+class ObjectPath;
+class ObjectProxy;
+{{- if hasRawSignals .Introspects}}
+class Signal;
+{{- end}}
+}  // namespace dbus
+{{- if hasOptionalArgs .Introspects}}
+
+{{template "optional"}}
+{{- end}}
+{{- if usesProtobuf .Introspects}}
+
+{{template "protobufClassCheck"}}
+{{- end}}
+{{- if hasVariantTypes .Introspects}}
+
+{{template "variantTypes"}}
+{{- end}}
+{{- if .UseCoroutines}}
+
+{{template "awaitable"}}
+{{- end}}
+{{range .Introspects}}{{range .Interfaces}}{{$settings := interfaceSettings .Name}}
+{{template "proxyInterface" (makeProxyInterfaceArgs . $settings.ObjectManagerName $.NamingStyle $.UseCoroutines $.MoveProtobufResponses $.ExpectedResults $settings.DisableBlockingCalls true nil) }}
+{{- end}}{{end}}
+#endif  // {{.HeaderGuard}}
+`
+
+// abstractTemplates is parsed once, and cloned by every GenerateAbstract call.
+var abstractTemplates = mustParseTemplates("abstract", funcMap,
+	abstractTemplateText,
+	proxyInterfaceTemplate,
+	awaitableTemplate,
+	variantTypesTemplate,
+	genutil.NamedStructsTemplate,
+	genutil.NamedEnumsTemplate,
+	genutil.NamedFlagsTemplate,
+	genutil.OptionalTemplate,
+	genutil.ProtobufChecksTemplate)
+
+// GenerateAbstract outputs the header file containing only the abstract proxy
+// interfaces into f. The header does not depend on the dbus library, so that
+// components can depend on the API of a service without depending on D-Bus.
+// outputFilePath is used to make a unique header guard.
+func GenerateAbstract(introspects []introspect.Introspection, f io.Writer, outputFilePath string, config serviceconfig.Config) error {
+	f = genutil.NewAPILevelWriter(f, config.TargetAPILevel)
+	tmpl, err := cloneTemplates(abstractTemplates, introspects, config)
+	if err != nil {
+		return err
+	}
+
+	return tmpl.Execute(f, struct {
+		Introspects           []introspect.Introspection
+		HeaderGuard           string
+		NamingStyle           serviceconfig.NamingStyle
+		UseCoroutines         bool
+		MoveProtobufResponses bool
+		ExpectedResults       bool
+		TypeMappingIncludes   []string
+	}{
+		Introspects:           introspects,
+		HeaderGuard:           genutil.GenerateHeaderGuard(outputFilePath),
+		NamingStyle:           config.NamingStyle,
+		UseCoroutines:         config.UseCoroutines,
+		MoveProtobufResponses: config.MoveProtobufResponses,
+		ExpectedResults:       config.ExpectedResults,
+		TypeMappingIncludes:   genutil.MakeTypeMappingIncludes(introspects, config.TypeMappings),
+	})
+}
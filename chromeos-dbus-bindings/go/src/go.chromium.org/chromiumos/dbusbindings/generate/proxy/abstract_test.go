@@ -0,0 +1,132 @@
+// Copyright 2022 The ChromiumOS Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package proxy
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"go.chromium.org/chromiumos/dbusbindings/introspect"
+	"go.chromium.org/chromiumos/dbusbindings/serviceconfig"
+)
+
+func TestGenerateAbstract(t *testing.T) {
+	introspections := []introspect.Introspection{{
+		Name: "/org/chromium/Test",
+		Interfaces: []introspect.Interface{{
+			Name: "org.chromium.Test",
+			Methods: []introspect.Method{
+				{
+					Name: "GetPath",
+					Args: []introspect.MethodArg{
+						{Name: "name", Type: "s"},
+						{Name: "path", Type: "o", Direction: "out"},
+					},
+				},
+			},
+			Signals: []introspect.Signal{
+				{Name: "Changed", Args: []introspect.SignalArg{{Name: "count", Type: "i"}}},
+			},
+			Properties: []introspect.Property{
+				{Name: "Count", Type: "i", Access: "read"},
+			},
+		}},
+	}}
+
+	out := new(bytes.Buffer)
+	if err := GenerateAbstract(introspections, out, "/tmp/proxy-interfaces.h", serviceconfig.Config{}); err != nil {
+		t.Fatalf("GenerateAbstract got error, want nil: %v", err)
+	}
+
+	const want = `// Automatic generation of D-Bus proxy interfaces:
+//  - org.chromium.Test
+#ifndef ____CHROMEOS_DBUS_BINDING___TMP_PROXY_INTERFACES_H
+#define ____CHROMEOS_DBUS_BINDING___TMP_PROXY_INTERFACES_H
+#include <string>
+#include <vector>
+
+#include <base/functional/bind.h>
+#include <base/functional/callback.h>
+#include <base/memory/weak_ptr.h>
+#include <brillo/errors/error.h>
+
+namespace dbus {
+class ObjectPath;
+class ObjectProxy;
+}  // namespace dbus
+
+namespace org {
+namespace chromium {
+
+// Abstract interface proxy for org::chromium::Test.
+class TestProxyInterface {
+ public:
+  static constexpr char kInterfaceName[] = "org.chromium.Test";
+  static constexpr char kGetPathMethod[] = "GetPath";
+  static constexpr char kGetPathMethodInSignature[] = "s";
+  static constexpr char kGetPathMethodOutSignature[] = "o";
+  static constexpr char kChangedSignal[] = "Changed";
+  static constexpr char kChangedSignalSignature[] = "i";
+  static constexpr char kCountProperty[] = "Count";
+  static constexpr char kCountPropertySignature[] = "i";
+
+  using ChangedSignalCallback =
+      base::RepeatingCallback<void(int32_t /*count*/)>;
+
+  virtual ~TestProxyInterface() = default;
+
+  virtual bool GetPath(
+      const std::string& in_name,
+      dbus::ObjectPath* out_path,
+      brillo::ErrorPtr* error,
+      int timeout_ms = -1 /*dbus::ObjectProxy::TIMEOUT_USE_DEFAULT*/) = 0;
+
+  virtual void GetPathAsync(
+      const std::string& in_name,
+      base::OnceCallback<void(const dbus::ObjectPath& /*path*/)> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = -1 /*dbus::ObjectProxy::TIMEOUT_USE_DEFAULT*/) = 0;
+
+  virtual void RegisterChangedSignalHandler(
+      const base::RepeatingCallback<void(int32_t)>& signal_callback,
+      base::OnceCallback<void(const std::string&, const std::string&, bool)> on_connected_callback) = 0;
+
+  // Registers |method| of |target| as the handler of the Changed signal.
+  // The signals delivered after |target| is invalidated are dropped.
+  template <typename T>
+  void RegisterChangedSignalHandlerWeak(
+      base::WeakPtr<T> target,
+      void (T::*method)(int32_t),
+      base::OnceCallback<void(const std::string&, const std::string&, bool)> on_connected_callback) {
+    RegisterChangedSignalHandler(
+        base::BindRepeating(method, std::move(target)),
+        std::move(on_connected_callback));
+  }
+
+  static const char* CountName() { return "Count"; }
+  virtual int32_t count() const = 0;
+  virtual bool is_count_valid() const = 0;
+  virtual void SetCountChangedCallback(
+      const base::RepeatingCallback<void(int32_t)>& callback) = 0;
+
+  virtual const dbus::ObjectPath& GetObjectPath() const = 0;
+  virtual dbus::ObjectProxy* GetObjectProxy() const = 0;
+
+  virtual void InitializeProperties(
+      const base::RepeatingCallback<void(TestProxyInterface*, const std::string&)>& callback) = 0;
+};
+
+}  // namespace chromium
+}  // namespace org
+
+#endif  // ____CHROMEOS_DBUS_BINDING___TMP_PROXY_INTERFACES_H
+`
+
+	if diff := cmp.Diff(out.String(), want); diff != "" {
+		t.Errorf("GenerateAbstract failed (-got +want):\n%s", diff)
+	}
+}
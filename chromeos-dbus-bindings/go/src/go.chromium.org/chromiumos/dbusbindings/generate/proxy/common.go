@@ -6,24 +6,75 @@ package proxy
 
 import (
 	"go.chromium.org/chromiumos/dbusbindings/introspect"
+	"go.chromium.org/chromiumos/dbusbindings/serviceconfig"
 )
 
 const proxyInterfaceTemplate = `{{define "proxyInterface" -}}
 {{- with .Itf -}}
-{{range extractNameSpaces .Name -}}
+{{template "namedEnums" makeNamedEnums .}}
+{{- template "namedStructs" makeNamedStructs .}}
+{{- template "namedFlags" makeNamedFlags .}}
+{{- range extractNameSpaces .Name -}}
 namespace {{.}} {
 {{end}}
+{{with makeProtobufClasses .}}{{template "protobufChecks" .}}
+{{end -}}
 // Abstract interface proxy for {{makeFullItfName .Name}}.
 {{formatComment .DocString 0 -}}
 {{- $itfName := makeProxyName .Name | printf "%sInterface" -}}
 class {{$itfName}} {
  public:
-  virtual ~{{$itfName}}() = default;
+  static constexpr char kInterfaceName[] = "{{.Name}}";
 {{- range .Methods}}
-{{- $inParams := makeMethodParams 0 .InputArguments -}}
-{{- $outParams := makeMethodParams (len .InputArguments) .OutputArguments}}
+  static constexpr char k{{makeConstantName .Name}}Method[] = "{{.Name}}";
+  static constexpr char k{{makeConstantName .Name}}MethodInSignature[] = "{{.InputSignature}}";
+  static constexpr char k{{makeConstantName .Name}}MethodOutSignature[] = "{{.OutputSignature}}";
+{{- end}}
+{{- range .Signals}}
+  static constexpr char k{{makeConstantName .Name}}Signal[] = "{{.Name}}";
+  static constexpr char k{{makeConstantName .Name}}SignalSignature[] = "{{.Signature}}";
+{{- end}}
+{{- range .Properties}}
+  static constexpr char k{{makeConstantName .Name}}Property[] = "{{.Name}}";
+  static constexpr char k{{makeConstantName .Name}}PropertySignature[] = "{{.Type}}";
+{{- end}}
+{{- if .Signals}}
+{{range .Signals}}
+  using {{.Name}}SignalCallback =
+      {{- makeSignalCallbackAlias $.NamingStyle . | nindent 6}};
+{{- end}}
+{{- end}}
+
+  virtual ~{{$itfName}}() = default;
+{{- with $.Factory}}
+
+  using Factory = base::RepeatingCallback<std::unique_ptr<{{$itfName}}>(
+      const scoped_refptr<dbus::Bus>&{{range .Params}}, {{.Type}}{{end}})>;
+
+  // Creates the proxy, or runs the factory set by SetFactoryForTesting() if
+  // any, so that the callers do not depend on the concrete proxy class.
+  static std::unique_ptr<{{$itfName}}> Create(
+      const scoped_refptr<dbus::Bus>& bus
+{{- range .Params}},
+      {{.Type}} {{.Name}}
+{{- end}});
+
+  // Makes Create() return the result of |factory|, e.g. a mock, until it is
+  // reset with a null callback.
+  static void SetFactoryForTesting(Factory factory) {
+    GetFactoryForTesting() = std::move(factory);
+  }
+{{- end}}
+{{- range $method := .Methods}}
+{{- $inParams := makeMethodParams $.NamingStyle 0 .InputArguments -}}
+{{- $outParams := makeMethodParams $.NamingStyle (len .InputArguments) .OutputArguments}}
 
 {{formatComment .DocString 2 -}}
+{{if not $.DisableBlockingCalls -}}
+{{range makeArgComments $.NamingStyle false .}}{{"  "}}// {{.}}{{"\n"}}{{end -}}
+{{end -}}
+{{with unnamedArgNames $.NamingStyle .}}{{"  "}}// The unnamed arguments are named {{range $i, $n := .}}{{if $i}}, {{end}}{{$n}}{{end}}.{{"\n"}}{{end -}}
+{{if not $.DisableBlockingCalls -}}
 {{"  "}}virtual bool {{.Name}}(
 {{- range $inParams }}
       {{.Type}} {{.Name}},
@@ -32,39 +83,254 @@ class {{$itfName}} {
       {{.Type}} {{.Name}},
 {{- end}}
       brillo::ErrorPtr* error,
-      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
+      int timeout_ms = {{$.DefaultTimeout}}) = 0;
 
 {{formatComment .DocString 2 -}}
+{{end -}}
+{{range makeArgComments $.NamingStyle true .}}{{"  "}}// {{.}}{{"\n"}}{{end -}}
 {{"  "}}virtual void {{.Name}}Async(
 {{- range $inParams}}
       {{.Type}} {{.Name}},
 {{- end}}
-      {{makeMethodCallbackType .OutputArguments}} success_callback,
+      {{makeMethodCallbackType $.NamingStyle $.MoveProtobufResponses .OutputArguments}} success_callback,
       base::OnceCallback<void(brillo::Error*)> error_callback,
-      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
+      int timeout_ms = {{$.DefaultTimeout}}) = 0;
+{{- range makeDefaultArgOverloads $.NamingStyle .}}
+{{- if not $.DisableBlockingCalls}}
+
+  // Calls {{$method.Name}}() with the default values of the omitted arguments.
+  bool {{$method.Name}}(
+{{- range .InParams}}
+      {{.Type}} {{.Name}},
+{{- end}}
+{{- range $outParams}}
+      {{.Type}} {{.Name}},
+{{- end}}
+      brillo::ErrorPtr* error,
+      int timeout_ms = {{$.DefaultTimeout}}) {
+    return {{$method.Name}}({{range .Forwards}}{{.}}, {{end}}{{range $outParams}}{{.Name}}, {{end}}error, timeout_ms);
+  }
+{{- end}}
+
+  // Calls {{$method.Name}}Async() with the default values of the omitted arguments.
+  void {{$method.Name}}Async(
+{{- range .InParams}}
+      {{.Type}} {{.Name}},
+{{- end}}
+      {{makeMethodCallbackType $.NamingStyle $.MoveProtobufResponses $method.OutputArguments}} success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = {{$.DefaultTimeout}}) {
+    {{$method.Name}}Async({{range .Forwards}}{{.}}, {{end}}std::move(success_callback),
+          {{repeat " " (len $method.Name)}}std::move(error_callback), timeout_ms);
+  }
+{{- end}}
+{{- with makeMoveOverload $.NamingStyle .}}
+{{- if not $.DisableBlockingCalls}}
+
+  // Calls {{$method.Name}}() with the containers moved from, so that they are
+  // released once the call is sent.
+  bool {{$method.Name}}(
+{{- range .InParams}}
+      {{.Type}} {{.Name}},
+{{- end}}
+{{- range $outParams}}
+      {{.Type}} {{.Name}},
+{{- end}}
+      brillo::ErrorPtr* error,
+      int timeout_ms = {{$.DefaultTimeout}}) {
+{{- range .Locals}}
+    {{.Type}} {{.Name}} = std::move({{.Param}});
+{{- end}}
+    return {{$method.Name}}({{range .Forwards}}{{.}}, {{end}}{{range $outParams}}{{.Name}}, {{end}}error, timeout_ms);
+  }
+{{- end}}
+
+  // Calls {{$method.Name}}Async() with the containers moved from, so that they
+  // are released once the call is sent.
+  void {{$method.Name}}Async(
+{{- range .InParams}}
+      {{.Type}} {{.Name}},
+{{- end}}
+      {{makeMethodCallbackType $.NamingStyle $.MoveProtobufResponses $method.OutputArguments}} success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = {{$.DefaultTimeout}}) {
+{{- range .Locals}}
+    {{.Type}} {{.Name}} = std::move({{.Param}});
+{{- end}}
+    {{$method.Name}}Async({{range .Forwards}}{{.}}, {{end}}std::move(success_callback),
+          {{repeat " " (len $method.Name)}}std::move(error_callback), timeout_ms);
+  }
+{{- end}}
+{{- with and (not $.DisableBlockingCalls) (makeVariantOverload $.NamingStyle .)}}
+
+  // Calls {{$method.Name}}() with the variant arguments held in std::variant.
+  // Fails if an output variant argument holds none of the annotated types.
+  bool {{$method.Name}}(
+{{- range .Params}}
+      {{.Type}} {{.Name}},
+{{- end}}
+      brillo::ErrorPtr* error,
+      int timeout_ms = {{$.DefaultTimeout}}) {
+{{- range .Locals}}
+    brillo::Any {{.}};
+{{- end}}
+{{- if .Conversions}}
+    if (!{{$method.Name}}({{range .Forwards}}{{.}}, {{end}}error, timeout_ms))
+      return false;
+    return {{range $i, $c := .Conversions}}{{if $i}} &&
+           {{end}}chromeos_dbus_bindings::AnyToVariant({{.Local}}, {{.Out}}, error){{end}};
+{{- else}}
+    return {{$method.Name}}({{range .Forwards}}{{.}}, {{end}}error, timeout_ms);
+{{- end}}
+  }
+{{- end}}
+{{- if $.ExpectedResults}}
+{{- with makeExpectedResult .}}
+
+  // Calls {{$method.Name}}() and returns its output argument or the error.
+  {{.Type}} {{$method.Name}}(
+{{- range $inParams}}
+      {{.Type}} {{.Name}},
+{{- end}}
+      int timeout_ms = {{$.DefaultTimeout}}) {
+    {{.ValueType}} result;
+    brillo::ErrorPtr error;
+    if (!{{$method.Name}}({{range $inParams}}{{.Name}}, {{end}}&result, &error, timeout_ms))
+      return base::unexpected(std::move(error));
+    return result;
+  }
+{{- end}}
+{{- end}}
+{{- if $.UseCoroutines}}
+{{- $awaitableType := makeAwaitableType $.MoveProtobufResponses .OutputArguments}}
+
+  // Calls {{.Name}}Async() and resumes the awaiting coroutine on completion.
+  // The result holds either the output arguments or the error.
+  {{$awaitableType}} {{.Name}}Await(
+{{- range $inParams}}
+      {{.Type}} {{.Name}},
+{{- end}}
+      int timeout_ms = {{$.DefaultTimeout}}) {
+    {{$awaitableType}} awaitable;
+    {{.Name}}Async({{range $inParams}}{{.Name}}, {{end}}awaitable.GetSuccessCallback(),
+          {{repeat " " (len .Name)}}awaitable.GetErrorCallback(), timeout_ms);
+    return awaitable;
+  }
+{{- end}}
+{{- if and .ReturnsFDStream (not $.DisableBlockingCalls)}}
+
+  // Calls {{.Name}}() and returns the file descriptor to read the stream from
+  // with ReadStreamChunk(), or an invalid one on failure.
+  base::ScopedFD {{.Name}}Stream(
+{{- range $inParams}}
+      {{.Type}} {{.Name}},
+{{- end}}
+      brillo::ErrorPtr* error,
+      int timeout_ms = {{$.DefaultTimeout}}) {
+    base::ScopedFD fd;
+    if (!{{.Name}}({{range $inParams}}{{.Name}}, {{end}}&fd, error, timeout_ms))
+      return base::ScopedFD();
+    return fd;
+  }
+{{- end}}
+{{- with makeMethodErrors .}}
+
+  // Errors which {{$.Itf.Name}}.{{$method.Name}} may fail with.
+  enum class {{$method.Name}}Error {
+    kUnknown,
+{{- range .}}
+    {{.Enumerator}},
+{{- end}}
+  };
+
+  // Returns the {{$method.Name}}Error corresponding to |error|, or kUnknown if
+  // |error| is not a D-Bus error listed above.
+  static {{$method.Name}}Error Parse{{$method.Name}}Error(const brillo::Error* error) {
+    if (!error || error->GetDomain() != brillo::errors::dbus::kDomain)
+      return {{$method.Name}}Error::kUnknown;
+{{- range .}}
+    if (error->GetCode() == "{{.Name}}")
+      return {{$method.Name}}Error::{{.Enumerator}};
+{{- end}}
+    return {{$method.Name}}Error::kUnknown;
+  }
+{{- end}}
+{{- end}}
+{{- if and (interfaceHasFDStream .) (not $.DisableBlockingCalls)}}
+
+  // Reads a chunk of the stream returned by the *Stream() methods. Each chunk
+  // is prefixed by its size as a uint32_t in host byte order.
+  // Returns false at the end of the stream or on failure.
+  static bool ReadStreamChunk(int fd, std::string* chunk) {
+    uint32_t size;
+    if (!base::ReadFromFD(fd, reinterpret_cast<char*>(&size), sizeof(size)))
+      return false;
+    chunk->resize(size);
+    return base::ReadFromFD(fd, chunk->data(), size);
+  }
 {{- end}}
 {{- range .Signals}}
 
   virtual void Register{{.Name}}SignalHandler(
-      {{- makeSignalCallbackType .Args | nindent 6}} signal_callback,
-      dbus::ObjectProxy::OnConnectedCallback on_connected_callback) = 0;
+      {{- makeSignalCallbackType . | nindent 6}} signal_callback,
+      {{$.OnConnectedCallbackType}} on_connected_callback) = 0;
+
+  // Registers |method| of |target| as the handler of the {{.Name}} signal.
+  // The signals delivered after |target| is invalidated are dropped.
+  template <typename T>
+  void Register{{.Name}}SignalHandlerWeak(
+      base::WeakPtr<T> target,
+      void (T::*method)({{makeSignalParamTypes .}}),
+      {{$.OnConnectedCallbackType}} on_connected_callback) {
+    Register{{.Name}}SignalHandler(
+        base::BindRepeating(method, std::move(target)),
+        std::move(on_connected_callback));
+  }
 {{- end}}
 {{- if .Properties}}{{"\n"}}{{end}}
 {{- range .Properties}}
-{{- $name := makePropertyVariableName . | makeVariableName -}}
+{{- $accessors := makePropertyAccessors $.NamingStyle . -}}
 {{- $type := makeProxyInArgTypeProxy . }}
+{{- if isPropertyPolled .}}
+  // Polling only: {{.Name}} does not emit PropertiesChanged, so the changes of
+  // its value are not notified.
+{{- end}}
   static const char* {{.Name}}Name() { return "{{.Name}}"; }
-  virtual {{$type}} {{$name}}() const = 0;
-  virtual bool is_{{$name}}_valid() const = 0;
-{{- if eq .Access "readwrite"}}
-  virtual void set_{{$name}}({{$type}} value,
-                   {{repeat " " (len $name)}} base::OnceCallback<void(bool)> callback) = 0;
+{{- if hasPropertySet $.Itf}}
+{{- if .Readable}}
+  virtual {{$type}} {{$accessors.Getter}}() const = 0;
+  virtual bool {{$accessors.Validator}}() const = 0;
+{{- if isPropertyVariantDict .}}
+  // Returns in |value| the entry of {{.Name}} keyed by |key|.
+  // Returns false if the property is not valid, has no such entry, or the
+  // entry holds another type than T.
+  template <typename T>
+  bool Get{{.Name}}Entry(const std::string& key, T* value) const {
+    if (!{{$accessors.Validator}}())
+      return false;
+    const brillo::VariantDictionary& dict = {{$accessors.Getter}}();
+    auto it = dict.find(key);
+    if (it == dict.end() || !it->second.IsTypeCompatible<T>())
+      return false;
+    *value = it->second.Get<T>();
+    return true;
+  }
+{{- end}}
+{{- end}}
+{{- if .Writable}}
+  virtual void {{$accessors.Setter}}({{$type}} value,
+               {{repeat " " (len $accessors.Setter)}} base::OnceCallback<void(bool)> callback) = 0;
+{{- end}}
+{{- end}}
+{{- if hasPropertyChangedCallback .}}
+  virtual void {{$accessors.ChangedCallbackSetter}}(
+      const base::RepeatingCallback<void({{$type}})>& callback) = 0;
 {{- end}}
 {{- end}}
 
   virtual const dbus::ObjectPath& GetObjectPath() const = 0;
   virtual dbus::ObjectProxy* GetObjectProxy() const = 0;
-{{- if .Properties}}
+{{- if hasPropertySet .}}
 {{if $.ObjectManagerName}}
   virtual void SetPropertyChangedCallback(
       const base::RepeatingCallback<void({{$itfName}}*, const std::string&)>& callback) = 0;
@@ -72,6 +338,20 @@ class {{$itfName}} {
   virtual void InitializeProperties(
       const base::RepeatingCallback<void({{$itfName}}*, const std::string&)>& callback) = 0;
 {{- end}}
+{{- else if hasLightweightProperties .}}
+
+  // Connects to the PropertiesChanged signal to run the property changed
+  // callbacks. The property values are not cached.
+  virtual void ConnectPropertiesChangedSignal(
+      {{$.OnConnectedCallbackType}} on_connected_callback) = 0;
+{{- end}}
+{{- if $.Factory}}
+
+ private:
+  static Factory& GetFactoryForTesting() {
+    static base::NoDestructor<Factory> factory;
+    return *factory;
+  }
 {{- end}}
 };
 
@@ -81,11 +361,133 @@ class {{$itfName}} {
 {{- end}}
 {{- end}}`
 
+// methodTimeoutTemplate replaces the default timeout of a method call with
+// the one configured by method_timeouts in the service config, if any.
+// It takes the configured timeout in milliseconds, which is 0 if none.
+const methodTimeoutTemplate = `{{define "methodTimeout"}}
+{{- if .}}
+    if (timeout_ms == dbus::ObjectProxy::TIMEOUT_USE_DEFAULT)
+      timeout_ms = {{.}};
+{{- end}}
+{{- end}}`
+
 type proxyInterfaceArgs struct {
 	Itf               introspect.Interface
 	ObjectManagerName string
+	NamingStyle       serviceconfig.NamingStyle
+	UseCoroutines     bool
+	// MoveProtobufResponses is set when the success callbacks take the
+	// ownership of protobuf output arguments.
+	MoveProtobufResponses bool
+	// ExpectedResults is set when the methods with a single output argument
+	// get blocking overloads returning base::expected.
+	ExpectedResults bool
+	// DisableBlockingCalls is set when only the asynchronous methods are
+	// generated.
+	DisableBlockingCalls bool
+	// AbstractOnly is set when the interface is generated without the
+	// concrete proxy, in which case the dbus headers are not included.
+	AbstractOnly bool
+	// Factory is the Create() factory of the interface, which is defined
+	// after the concrete proxy, if any.
+	Factory *proxyFactory
+}
+
+func makeProxyInterfaceArgs(itf introspect.Interface, omName string, style serviceconfig.NamingStyle, useCoroutines, moveProtos, expectedResults, disableBlocking, abstractOnly bool, factory *proxyFactory) proxyInterfaceArgs {
+	return proxyInterfaceArgs{
+		Itf:                   itf,
+		ObjectManagerName:     omName,
+		NamingStyle:           style,
+		UseCoroutines:         useCoroutines,
+		MoveProtobufResponses: moveProtos,
+		ExpectedResults:       expectedResults,
+		DisableBlockingCalls:  disableBlocking,
+		AbstractOnly:          abstractOnly,
+		Factory:               factory,
+	}
 }
 
-func makeProxyInterfaceArgs(itf introspect.Interface, omName string) proxyInterfaceArgs {
-	return proxyInterfaceArgs{Itf: itf, ObjectManagerName: omName}
+// DefaultTimeout returns the default value of the timeout_ms parameters.
+func (a proxyInterfaceArgs) DefaultTimeout() string {
+	if a.AbstractOnly {
+		// dbus::ObjectProxy is not available without dbus headers.
+		return "-1 /*dbus::ObjectProxy::TIMEOUT_USE_DEFAULT*/"
+	}
+	return "dbus::ObjectProxy::TIMEOUT_USE_DEFAULT"
 }
+
+// OnConnectedCallbackType returns the type of the callback passed to signal
+// handler registrations.
+func (a proxyInterfaceArgs) OnConnectedCallbackType() string {
+	if a.AbstractOnly {
+		// Same as dbus::ObjectProxy::OnConnectedCallback.
+		return "base::OnceCallback<void(const std::string&, const std::string&, bool)>"
+	}
+	return "dbus::ObjectProxy::OnConnectedCallback"
+}
+
+// awaitableTemplate defines the awaitable type returned by the *Await()
+// methods. It is guarded so that multiple generated headers can define it.
+const awaitableTemplate = `{{define "awaitable" -}}
+#ifndef CHROMEOS_DBUS_BINDINGS_DBUS_AWAITABLE_
+#define CHROMEOS_DBUS_BINDINGS_DBUS_AWAITABLE_
+namespace chromeos_dbus_bindings {
+
+// Result of an awaited D-Bus method call. |error| is set if the call failed,
+// otherwise |value| holds the output arguments.
+template <typename... Ts>
+struct DBusResult {
+  brillo::ErrorPtr error;
+  std::tuple<std::decay_t<Ts>...> value;
+};
+
+// Awaitable returned by the *Await() methods of the proxies. The method call
+// is started before the awaitable is returned, and the awaiting coroutine is
+// resumed when the call completes.
+template <typename... Ts>
+class DBusAwaitable {
+ public:
+  DBusAwaitable() : state_(std::make_shared<State>()) {}
+
+  base::OnceCallback<void(Ts...)> GetSuccessCallback() {
+    return base::BindOnce(&DBusAwaitable::OnSuccess, state_);
+  }
+  base::OnceCallback<void(brillo::Error*)> GetErrorCallback() {
+    return base::BindOnce(&DBusAwaitable::OnError, state_);
+  }
+
+  bool await_ready() const noexcept { return state_->done; }
+  void await_suspend(std::coroutine_handle<> handle) {
+    state_->handle = handle;
+  }
+  DBusResult<Ts...> await_resume() { return std::move(state_->result); }
+
+ private:
+  struct State {
+    bool done = false;
+    std::coroutine_handle<> handle;
+    DBusResult<Ts...> result;
+  };
+
+  static void OnSuccess(const std::shared_ptr<State>& state, Ts... values) {
+    state->result.value =
+        std::tuple<std::decay_t<Ts>...>(std::forward<Ts>(values)...);
+    Complete(state);
+  }
+  static void OnError(const std::shared_ptr<State>& state,
+                      brillo::Error* error) {
+    state->result.error = error->Clone();
+    Complete(state);
+  }
+  static void Complete(const std::shared_ptr<State>& state) {
+    state->done = true;
+    if (state->handle)
+      state->handle.resume();
+  }
+
+  std::shared_ptr<State> state_;
+};
+
+}  // namespace chromeos_dbus_bindings
+#endif  // CHROMEOS_DBUS_BINDINGS_DBUS_AWAITABLE_
+{{- end}}`
@@ -0,0 +1,144 @@
+// Copyright 2022 The ChromiumOS Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package proxy
+
+import (
+	"errors"
+	"io"
+
+	"go.chromium.org/chromiumos/dbusbindings/generate/genutil"
+	"go.chromium.org/chromiumos/dbusbindings/introspect"
+	"go.chromium.org/chromiumos/dbusbindings/serviceconfig"
+)
+
+// compileTestTemplateText generates a source file which only needs to
+// compile: it instantiates a function template calling every method of the
+// proxies, so that the build catches the signatures the generator got wrong.
+const compileTestTemplateText = `// Automatic generation of D-Bus proxy compile tests for:
+{{range .Introspects}}{{range .Interfaces -}}
+//  - {{.Name}}
+{{end}}{{end -}}
+
+#include <type_traits>
+
+#include <base/functional/callback_helpers.h>
+#include <brillo/errors/error.h>
+#include <dbus/object_proxy.h>
+
+#include "{{.ProxyFilePath}}"
+{{range .Introspects}}{{range .Interfaces}}
+{{- $proxyName := makeProxyName .Name}}
+{{- $settings := interfaceSettings .Name}}
+{{- $itfName := printf "%sInterface" $proxyName}}
+{{range extractNameSpaces .Name -}}
+namespace {{.}} {
+{{end}}
+static_assert(!std::is_abstract_v<{{$proxyName}}>,
+              "{{$proxyName}} must implement {{$itfName}}");
+
+// Calls the methods of the proxy with default-constructed arguments. It is
+// only instantiated to be compiled, and never run.
+template <typename Proxy>
+void CompileTest{{$proxyName}}(Proxy& proxy) {
+{{- range .Methods}}
+{{- $method := .}}
+{{- with makeCompileTestCall $.NamingStyle .}}
+  {
+{{- range .InLocals}}
+    {{.Type}} {{.Name}}{};
+{{- end}}
+{{- if not $settings.DisableBlockingCalls}}
+{{- range .OutLocals}}
+    {{.Type}} {{.Name}}{};
+{{- end}}
+    brillo::ErrorPtr error;
+    proxy.{{$method.Name}}({{range .BlockingArgs}}{{.}}, {{end}}&error,
+           {{repeat " " (len $method.Name)}}dbus::ObjectProxy::TIMEOUT_USE_DEFAULT);
+{{- end}}
+    proxy.{{$method.Name}}Async({{range .AsyncArgs}}{{.}}, {{end}}base::DoNothing(),
+                {{repeat " " (len $method.Name)}}base::DoNothing(),
+                {{repeat " " (len $method.Name)}}dbus::ObjectProxy::TIMEOUT_USE_DEFAULT);
+  }
+{{- end}}
+{{- end}}
+}
+
+template void CompileTest{{$proxyName}}({{$itfName}}& proxy);
+template void CompileTest{{$proxyName}}({{$proxyName}}& proxy);
+
+{{range extractNameSpaces .Name | reverse -}}
+}  // namespace {{.}}
+{{end}}
+{{- end}}{{end -}}
+`
+
+// compileTestTemplates is parsed once, and cloned by every
+// GenerateCompileTest call.
+var compileTestTemplates = mustParseTemplates("compileTest", funcMap, compileTestTemplateText)
+
+// compileTestCall is the arguments of the calls of a method in a compile
+// test.
+type compileTestCall struct {
+	// InLocals and OutLocals are the default-constructed variables passed
+	// to the calls as the input and the output arguments respectively.
+	InLocals, OutLocals []param
+	// BlockingArgs and AsyncArgs are the arguments of the blocking and the
+	// asynchronous calls preceding the error and the callbacks respectively.
+	BlockingArgs []string
+	AsyncArgs    []string
+}
+
+// makeCompileTestCall returns the arguments of the calls of the method m.
+// The arguments are variables of the exact base types, so that the calls
+// are not ambiguous with the overloads taking other types, e.g. the variant
+// overloads.
+func makeCompileTestCall(style serviceconfig.NamingStyle, m introspect.Method) (compileTestCall, error) {
+	var ret compileTestCall
+	for i, a := range m.InputArguments() {
+		t, err := a.BaseType()
+		if err != nil {
+			return compileTestCall{}, err
+		}
+		name := genutil.ArgNameWithStyle(style, "in", a.Name, genutil.MethodArgIndex(false, i, 0))
+		ret.InLocals = append(ret.InLocals, param{t, name})
+		ret.BlockingArgs = append(ret.BlockingArgs, name)
+		ret.AsyncArgs = append(ret.AsyncArgs, name)
+	}
+	numIn := len(m.InputArguments())
+	for i, a := range m.OutputArguments() {
+		t, err := a.BaseType()
+		if err != nil {
+			return compileTestCall{}, err
+		}
+		name := genutil.ArgNameWithStyle(style, "out", a.Name, genutil.MethodArgIndex(true, i, numIn))
+		ret.OutLocals = append(ret.OutLocals, param{t, name})
+		ret.BlockingArgs = append(ret.BlockingArgs, "&"+name)
+	}
+	return ret, nil
+}
+
+// GenerateCompileTest outputs the source file compiling the calls of every
+// method of the proxies generated into the header at proxyFilePath into f.
+// Compiling it in the build catches the generator regressions producing
+// uncompilable signatures.
+func GenerateCompileTest(introspects []introspect.Introspection, f io.Writer, proxyFilePath string, config serviceconfig.Config) error {
+	f = genutil.NewAPILevelWriter(f, config.TargetAPILevel)
+	if proxyFilePath == "" {
+		return errors.New("proxy file path is not specified")
+	}
+	tmpl, err := cloneTemplates(compileTestTemplates, introspects, config)
+	if err != nil {
+		return err
+	}
+	return tmpl.Execute(f, struct {
+		Introspects   []introspect.Introspection
+		ProxyFilePath string
+		NamingStyle   serviceconfig.NamingStyle
+	}{
+		Introspects:   introspects,
+		ProxyFilePath: proxyFilePath,
+		NamingStyle:   config.NamingStyle,
+	})
+}
@@ -0,0 +1,171 @@
+// Copyright 2022 The ChromiumOS Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package proxy
+
+import (
+	"bytes"
+	"testing"
+
+	"go.chromium.org/chromiumos/dbusbindings/introspect"
+	"go.chromium.org/chromiumos/dbusbindings/serviceconfig"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var compileTestIntrospections = []introspect.Introspection{{
+	Name: "/org/chromium/Test",
+	Interfaces: []introspect.Interface{{
+		Name: "org.chromium.Test",
+		Methods: []introspect.Method{
+			{
+				Name: "Frobinate",
+				Args: []introspect.MethodArg{
+					{Name: "foo", Type: "i"},
+					{Type: "s"},
+					{Name: "bar", Type: "a{sv}", Direction: "out"},
+				},
+			},
+			{
+				Name: "Reset",
+			},
+			{
+				Name: "Notify",
+				Args: []introspect.MethodArg{
+					{Name: "message", Type: "s"},
+				},
+				Annotations: []introspect.Annotation{
+					{Name: "org.freedesktop.DBus.Method.NoReply", Value: "true"},
+				},
+			},
+		},
+	}},
+}}
+
+func TestGenerateCompileTest(t *testing.T) {
+	out := new(bytes.Buffer)
+	if err := GenerateCompileTest(compileTestIntrospections, out, "../proxy.h", serviceconfig.Config{}); err != nil {
+		t.Fatalf("GenerateCompileTest got error, want nil: %v", err)
+	}
+
+	const want = `// Automatic generation of D-Bus proxy compile tests for:
+//  - org.chromium.Test
+#include <type_traits>
+
+#include <base/functional/callback_helpers.h>
+#include <brillo/errors/error.h>
+#include <dbus/object_proxy.h>
+
+#include "../proxy.h"
+
+namespace org {
+namespace chromium {
+
+static_assert(!std::is_abstract_v<TestProxy>,
+              "TestProxy must implement TestProxyInterface");
+
+// Calls the methods of the proxy with default-constructed arguments. It is
+// only instantiated to be compiled, and never run.
+template <typename Proxy>
+void CompileTestTestProxy(Proxy& proxy) {
+  {
+    int32_t in_foo{};
+    std::string in_2{};
+    brillo::VariantDictionary out_bar{};
+    brillo::ErrorPtr error;
+    proxy.Frobinate(in_foo, in_2, &out_bar, &error,
+                    dbus::ObjectProxy::TIMEOUT_USE_DEFAULT);
+    proxy.FrobinateAsync(in_foo, in_2, base::DoNothing(),
+                         base::DoNothing(),
+                         dbus::ObjectProxy::TIMEOUT_USE_DEFAULT);
+  }
+  {
+    brillo::ErrorPtr error;
+    proxy.Reset(&error,
+                dbus::ObjectProxy::TIMEOUT_USE_DEFAULT);
+    proxy.ResetAsync(base::DoNothing(),
+                     base::DoNothing(),
+                     dbus::ObjectProxy::TIMEOUT_USE_DEFAULT);
+  }
+  {
+    std::string in_message{};
+    brillo::ErrorPtr error;
+    proxy.Notify(in_message, &error,
+                 dbus::ObjectProxy::TIMEOUT_USE_DEFAULT);
+    proxy.NotifyAsync(in_message, base::DoNothing(),
+                      base::DoNothing(),
+                      dbus::ObjectProxy::TIMEOUT_USE_DEFAULT);
+  }
+}
+
+template void CompileTestTestProxy(TestProxyInterface& proxy);
+template void CompileTestTestProxy(TestProxy& proxy);
+
+}  // namespace chromium
+}  // namespace org
+`
+
+	if diff := cmp.Diff(out.String(), want); diff != "" {
+		t.Errorf("GenerateCompileTest failed (-got +want):\n%s", diff)
+	}
+}
+
+func TestGenerateCompileTestWithoutBlockingCalls(t *testing.T) {
+	out := new(bytes.Buffer)
+	sc := serviceconfig.Config{DisableBlockingCalls: true}
+	if err := GenerateCompileTest(compileTestIntrospections, out, "../proxy.h", sc); err != nil {
+		t.Fatalf("GenerateCompileTest got error, want nil: %v", err)
+	}
+
+	const want = `// Automatic generation of D-Bus proxy compile tests for:
+//  - org.chromium.Test
+#include <type_traits>
+
+#include <base/functional/callback_helpers.h>
+#include <brillo/errors/error.h>
+#include <dbus/object_proxy.h>
+
+#include "../proxy.h"
+
+namespace org {
+namespace chromium {
+
+static_assert(!std::is_abstract_v<TestProxy>,
+              "TestProxy must implement TestProxyInterface");
+
+// Calls the methods of the proxy with default-constructed arguments. It is
+// only instantiated to be compiled, and never run.
+template <typename Proxy>
+void CompileTestTestProxy(Proxy& proxy) {
+  {
+    int32_t in_foo{};
+    std::string in_2{};
+    proxy.FrobinateAsync(in_foo, in_2, base::DoNothing(),
+                         base::DoNothing(),
+                         dbus::ObjectProxy::TIMEOUT_USE_DEFAULT);
+  }
+  {
+    proxy.ResetAsync(base::DoNothing(),
+                     base::DoNothing(),
+                     dbus::ObjectProxy::TIMEOUT_USE_DEFAULT);
+  }
+  {
+    std::string in_message{};
+    proxy.NotifyAsync(in_message, base::DoNothing(),
+                      base::DoNothing(),
+                      dbus::ObjectProxy::TIMEOUT_USE_DEFAULT);
+  }
+}
+
+template void CompileTestTestProxy(TestProxyInterface& proxy);
+template void CompileTestTestProxy(TestProxy& proxy);
+
+}  // namespace chromium
+}  // namespace org
+`
+
+	if diff := cmp.Diff(out.String(), want); diff != "" {
+		t.Errorf("GenerateCompileTest failed (-got +want):\n%s", diff)
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright 2022 The ChromiumOS Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package proxy
+
+// credentialsTemplate defines the helper behind the GetConnectionCredentials
+// methods of the proxies. It is guarded, as every proxy header generated with
+// credential_queries contains it.
+const credentialsTemplate = `{{define "credentials" -}}
+#ifndef CHROMEOS_DBUS_BINDINGS_DBUS_CONNECTION_CREDENTIALS_
+#define CHROMEOS_DBUS_BINDINGS_DBUS_CONNECTION_CREDENTIALS_
+namespace chromeos_dbus_bindings {
+
+// Credentials of the connection owning a service name, as reported by the
+// bus.
+struct ConnectionCredentials {
+  uint32_t unix_user_id;
+  uint32_t process_id;
+};
+
+// Queries the bus for the credentials of the owner of a service name with
+// GetConnectionUnixUser and GetConnectionUnixProcessID. The callback is run
+// once, with std::nullopt if either query fails.
+class ConnectionCredentialsQuery {
+ public:
+  using Callback =
+      base::OnceCallback<void(std::optional<ConnectionCredentials>)>;
+
+  static void Start(const scoped_refptr<dbus::Bus>& bus,
+                    const std::string& service_name,
+                    Callback callback) {
+    auto state = std::make_shared<State>(State{
+        bus->GetObjectProxy("org.freedesktop.DBus",
+                            dbus::ObjectPath("/org/freedesktop/DBus")),
+        service_name, std::move(callback), 0});
+    brillo::dbus_utils::CallMethod(
+        state->bus_proxy, "org.freedesktop.DBus", "GetConnectionUnixUser",
+        base::BindOnce(&ConnectionCredentialsQuery::OnUnixUser, state),
+        base::BindOnce(&ConnectionCredentialsQuery::OnError, state),
+        state->service_name);
+  }
+
+ private:
+  struct State {
+    dbus::ObjectProxy* bus_proxy;
+    std::string service_name;
+    Callback callback;
+    uint32_t unix_user_id;
+  };
+
+  static void OnUnixUser(const std::shared_ptr<State>& state,
+                         uint32_t unix_user_id) {
+    state->unix_user_id = unix_user_id;
+    brillo::dbus_utils::CallMethod(
+        state->bus_proxy, "org.freedesktop.DBus", "GetConnectionUnixProcessID",
+        base::BindOnce(&ConnectionCredentialsQuery::OnProcessID, state),
+        base::BindOnce(&ConnectionCredentialsQuery::OnError, state),
+        state->service_name);
+  }
+  static void OnProcessID(const std::shared_ptr<State>& state,
+                          uint32_t process_id) {
+    std::move(state->callback)
+        .Run(ConnectionCredentials{state->unix_user_id, process_id});
+  }
+  static void OnError(const std::shared_ptr<State>& state,
+                      brillo::Error* error) {
+    LOG(ERROR) << "Failed to get the credentials of " << state->service_name
+               << ": " << (error ? error->GetMessage() : "unknown error");
+    std::move(state->callback).Run(std::nullopt);
+  }
+};
+
+inline void GetConnectionCredentials(
+    const scoped_refptr<dbus::Bus>& bus,
+    const std::string& service_name,
+    ConnectionCredentialsQuery::Callback callback) {
+  ConnectionCredentialsQuery::Start(bus, service_name, std::move(callback));
+}
+
+}  // namespace chromeos_dbus_bindings
+#endif  // CHROMEOS_DBUS_BINDINGS_DBUS_CONNECTION_CREDENTIALS_
+{{- end}}`
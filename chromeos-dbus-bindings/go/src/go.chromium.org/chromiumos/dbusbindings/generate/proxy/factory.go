@@ -0,0 +1,110 @@
+// Copyright 2022 The ChromiumOS Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package proxy
+
+import (
+	"go.chromium.org/chromiumos/dbusbindings/generate/genutil"
+	"go.chromium.org/chromiumos/dbusbindings/introspect"
+	"go.chromium.org/chromiumos/dbusbindings/serviceconfig"
+)
+
+const clientFactoryTemplate = `{{define "clientFactory"}}
+{{- range extractNameSpaces .ClientFactoryName}}
+namespace {{.}} {
+{{- end}}
+{{- $className := makeTypeName .ClientFactoryName}}
+{{- $proxies := makeClientFactoryProxies .Introspects}}
+
+// Creates the proxies of the interfaces provided by {{.ServiceName}}
+// at their well-known object paths.
+class {{$className}} {
+ public:
+  // Connects to the system bus and creates the proxies.
+  // Returns nullptr if the connection fails.
+  static std::unique_ptr<{{$className}}> CreateOnSystemBus() {
+    return Create(dbus::Bus::SYSTEM);
+  }
+
+  // Connects to the session bus and creates the proxies.
+  // Returns nullptr if the connection fails.
+  static std::unique_ptr<{{$className}}> CreateOnSessionBus() {
+    return Create(dbus::Bus::SESSION);
+  }
+
+  explicit {{$className}}(const scoped_refptr<dbus::Bus>& bus)
+      : bus_{bus}
+{{- range $proxies}},
+        {{.Name}}_{std::make_unique<{{.ProxyType}}>(bus_)}
+{{- end}} {
+{{- range $proxies}}
+{{- if .HasPropertySet}}
+    {{.Name}}_->InitializeProperties(base::DoNothing());
+{{- end}}
+{{- end}}
+  }
+
+  {{$className}}(const {{$className}}&) = delete;
+  {{$className}}& operator=(const {{$className}}&) = delete;
+
+  const scoped_refptr<dbus::Bus>& bus() const { return bus_; }
+{{- range $proxies}}
+  {{.InterfaceType}}* {{.Name}}() const { return {{.Name}}_.get(); }
+{{- end}}
+
+ private:
+  static std::unique_ptr<{{$className}}> Create(dbus::Bus::BusType bus_type) {
+    dbus::Bus::Options options;
+    options.bus_type = bus_type;
+    auto bus = base::MakeRefCounted<dbus::Bus>(options);
+    if (!bus->Connect())
+      return nullptr;
+    return std::make_unique<{{$className}}>(bus);
+  }
+
+  scoped_refptr<dbus::Bus> bus_;
+{{- range $proxies}}
+  std::unique_ptr<{{.ProxyType}}> {{.Name}}_;
+{{- end}}
+};
+
+{{range extractNameSpaces .ClientFactoryName | reverse -}}
+}  // namespace {{.}}
+{{end}}
+{{- end}}`
+
+// clientFactoryProxy is a proxy owned by the client factory.
+type clientFactoryProxy struct {
+	// Name is the name of the accessor, and the data member with the "_" suffix.
+	Name           string
+	ProxyType      string
+	InterfaceType  string
+	HasPropertySet bool
+}
+
+// makeClientFactoryProxies returns the proxies the client factory creates, which are
+// the ones of the interfaces at well-known object paths. The proxies of the interfaces
+// with properties are created by the object manager instead if it handles them
+// in config.
+func makeClientFactoryProxies(introspects []introspect.Introspection, config serviceconfig.Config) []clientFactoryProxy {
+	overrides := config.NamespaceOverrides
+	var ret []clientFactoryProxy
+	for _, i := range introspects {
+		if i.Name == "" {
+			continue
+		}
+		for _, itf := range i.Interfaces {
+			if makeInterfaceSettings(config, itf.Name).ObjectManagerName != "" && len(itf.Properties) > 0 {
+				continue
+			}
+			ret = append(ret, clientFactoryProxy{
+				Name:           genutil.MakeVariableName(itf.Name) + "_proxy",
+				ProxyType:      genutil.MakeFullName(overrides, itf.Name) + "Proxy",
+				InterfaceType:  genutil.MakeFullName(overrides, itf.Name) + "ProxyInterface",
+				HasPropertySet: hasPropertySet(itf),
+			})
+		}
+	}
+	return ret
+}
@@ -0,0 +1,479 @@
+// Copyright 2022 The ChromiumOS Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package proxy
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"go.chromium.org/chromiumos/dbusbindings/introspect"
+	"go.chromium.org/chromiumos/dbusbindings/serviceconfig"
+)
+
+func TestGenerateProxiesWithClientFactory(t *testing.T) {
+	introspections := []introspect.Introspection{
+		{
+			Name: "/org/chromium/Frobinator",
+			Interfaces: []introspect.Interface{
+				{
+					Name:    "org.chromium.Frobinator",
+					Methods: []introspect.Method{{Name: "Frobinate"}},
+				}, {
+					Name: "org.chromium.FrobinatorSettings",
+					Properties: []introspect.Property{
+						{Name: "Level", Type: "i", Access: "read"},
+					},
+				},
+			},
+		}, {
+			// Not created by the factory since the object path is unknown.
+			Interfaces: []introspect.Interface{
+				{Name: "org.chromium.Frob"},
+			},
+		},
+	}
+
+	sc := serviceconfig.Config{
+		ServiceName:   "org.chromium.Frobinator",
+		ClientFactory: &serviceconfig.ClientFactoryConfig{Name: "org.chromium.FrobinatorClientFactory"},
+	}
+	out := new(bytes.Buffer)
+	if err := Generate(introspections, out, "/tmp/proxy.h", sc); err != nil {
+		t.Fatalf("Generate got error, want nil: %v", err)
+	}
+
+	const want = `// Automatic generation of D-Bus interfaces:
+//  - org.chromium.Frobinator
+//  - org.chromium.FrobinatorSettings
+//  - org.chromium.Frob
+#ifndef ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+#define ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+#include <memory>
+#include <string>
+#include <vector>
+
+#include <base/functional/bind.h>
+#include <base/functional/callback.h>
+#include <base/functional/callback_helpers.h>
+#include <base/logging.h>
+#include <base/memory/ref_counted.h>
+#include <brillo/dbus/dbus_method_invoker.h>
+#include <brillo/dbus/dbus_property.h>
+#include <brillo/dbus/dbus_signal_handler.h>
+#include <brillo/errors/error.h>
+#include <dbus/bus.h>
+#include <dbus/message.h>
+#include <dbus/object_manager.h>
+#include <dbus/object_path.h>
+#include <dbus/object_proxy.h>
+
+namespace org {
+namespace chromium {
+
+// Abstract interface proxy for org::chromium::Frobinator.
+class FrobinatorProxyInterface {
+ public:
+  static constexpr char kInterfaceName[] = "org.chromium.Frobinator";
+  static constexpr char kFrobinateMethod[] = "Frobinate";
+  static constexpr char kFrobinateMethodInSignature[] = "";
+  static constexpr char kFrobinateMethodOutSignature[] = "";
+
+  virtual ~FrobinatorProxyInterface() = default;
+
+  virtual bool Frobinate(
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
+
+  virtual void FrobinateAsync(
+      base::OnceCallback<void()> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
+
+  virtual const dbus::ObjectPath& GetObjectPath() const = 0;
+  virtual dbus::ObjectProxy* GetObjectProxy() const = 0;
+};
+
+}  // namespace chromium
+}  // namespace org
+
+namespace org {
+namespace chromium {
+
+// Interface proxy for org::chromium::Frobinator.
+class FrobinatorProxy final : public FrobinatorProxyInterface {
+ public:
+  FrobinatorProxy(const scoped_refptr<dbus::Bus>& bus) :
+      bus_{bus},
+      dbus_object_proxy_{
+          bus_->GetObjectProxy(service_name_, object_path_)} {
+  }
+
+  FrobinatorProxy(const FrobinatorProxy&) = delete;
+  FrobinatorProxy& operator=(const FrobinatorProxy&) = delete;
+
+  ~FrobinatorProxy() override {
+  }
+
+  void ReleaseObjectProxy(base::OnceClosure callback) {
+    bus_->RemoveObjectProxy(service_name_, object_path_, std::move(callback));
+  }
+
+  const dbus::ObjectPath& GetObjectPath() const override {
+    return object_path_;
+  }
+
+  dbus::ObjectProxy* GetObjectProxy() const override {
+    return dbus_object_proxy_;
+  }
+
+  bool Frobinate(
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override {
+    auto response = brillo::dbus_utils::CallMethodAndBlockWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "org.chromium.Frobinator",
+        "Frobinate",
+        error);
+    return response && brillo::dbus_utils::ExtractMethodCallResults(
+        response.get(), error);
+  }
+
+  void FrobinateAsync(
+      base::OnceCallback<void()> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override {
+    brillo::dbus_utils::CallMethodWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "org.chromium.Frobinator",
+        "Frobinate",
+        std::move(success_callback),
+        std::move(error_callback));
+  }
+
+ private:
+  scoped_refptr<dbus::Bus> bus_;
+  const std::string service_name_{"org.chromium.Frobinator"};
+  const dbus::ObjectPath object_path_{"/org/chromium/Frobinator"};
+  dbus::ObjectProxy* dbus_object_proxy_;
+
+};
+
+}  // namespace chromium
+}  // namespace org
+
+namespace org {
+namespace chromium {
+
+// Abstract interface proxy for org::chromium::FrobinatorSettings.
+class FrobinatorSettingsProxyInterface {
+ public:
+  static constexpr char kInterfaceName[] = "org.chromium.FrobinatorSettings";
+  static constexpr char kLevelProperty[] = "Level";
+  static constexpr char kLevelPropertySignature[] = "i";
+
+  virtual ~FrobinatorSettingsProxyInterface() = default;
+
+  static const char* LevelName() { return "Level"; }
+  virtual int32_t level() const = 0;
+  virtual bool is_level_valid() const = 0;
+  virtual void SetLevelChangedCallback(
+      const base::RepeatingCallback<void(int32_t)>& callback) = 0;
+
+  virtual const dbus::ObjectPath& GetObjectPath() const = 0;
+  virtual dbus::ObjectProxy* GetObjectProxy() const = 0;
+
+  virtual void InitializeProperties(
+      const base::RepeatingCallback<void(FrobinatorSettingsProxyInterface*, const std::string&)>& callback) = 0;
+};
+
+}  // namespace chromium
+}  // namespace org
+
+namespace org {
+namespace chromium {
+
+// Interface proxy for org::chromium::FrobinatorSettings.
+class FrobinatorSettingsProxy final : public FrobinatorSettingsProxyInterface {
+ public:
+  class PropertySet : public dbus::PropertySet {
+   public:
+    PropertySet(dbus::ObjectProxy* object_proxy,
+                const PropertyChangedCallback& callback)
+        : dbus::PropertySet{object_proxy,
+                            "org.chromium.FrobinatorSettings",
+                            callback} {
+      RegisterProperty(LevelName(), &level);
+    }
+    PropertySet(const PropertySet&) = delete;
+    PropertySet& operator=(const PropertySet&) = delete;
+
+    brillo::dbus_utils::Property<int32_t> level;
+
+  };
+
+  FrobinatorSettingsProxy(const scoped_refptr<dbus::Bus>& bus) :
+      bus_{bus},
+      dbus_object_proxy_{
+          bus_->GetObjectProxy(service_name_, object_path_)} {
+  }
+
+  // How the constructor initializes the properties.
+  enum class InitializePropertiesPolicy {
+    // Creates the property set and connects the PropertiesChanged signal,
+    // leaving the values to be fetched on demand.
+    kLazy,
+    // Does what InitializeProperties() does, i.e. also fetches all the values.
+    kEager,
+    // Leaves the properties uninitialized, as the other constructor does.
+    kNone,
+  };
+
+  FrobinatorSettingsProxy(
+      const scoped_refptr<dbus::Bus>& bus,
+      InitializePropertiesPolicy policy,
+      const base::RepeatingCallback<void(FrobinatorSettingsProxyInterface*, const std::string&)>& callback) :
+          FrobinatorSettingsProxy(bus) {
+    switch (policy) {
+      case InitializePropertiesPolicy::kLazy:
+        on_property_changed_ = callback;
+        property_set_.reset(
+            new PropertySet(dbus_object_proxy_,
+                            base::BindRepeating(&FrobinatorSettingsProxy::OnPropertyChanged,
+                                                base::Unretained(this))));
+        property_set_->ConnectSignals();
+        break;
+      case InitializePropertiesPolicy::kEager:
+        InitializeProperties(callback);
+        break;
+      case InitializePropertiesPolicy::kNone:
+        break;
+    }
+  }
+
+  FrobinatorSettingsProxy(const FrobinatorSettingsProxy&) = delete;
+  FrobinatorSettingsProxy& operator=(const FrobinatorSettingsProxy&) = delete;
+
+  ~FrobinatorSettingsProxy() override {
+  }
+
+  void ReleaseObjectProxy(base::OnceClosure callback) {
+    bus_->RemoveObjectProxy(service_name_, object_path_, std::move(callback));
+  }
+
+  const dbus::ObjectPath& GetObjectPath() const override {
+    return object_path_;
+  }
+
+  dbus::ObjectProxy* GetObjectProxy() const override {
+    return dbus_object_proxy_;
+  }
+
+  void InitializeProperties(
+      const base::RepeatingCallback<void(FrobinatorSettingsProxyInterface*, const std::string&)>& callback) override {
+    on_property_changed_ = callback;
+    property_set_.reset(
+        new PropertySet(dbus_object_proxy_,
+                        base::BindRepeating(&FrobinatorSettingsProxy::OnPropertyChanged,
+                                            base::Unretained(this))));
+    property_set_->ConnectSignals();
+    property_set_->GetAll();
+  }
+
+  const PropertySet* GetProperties() const { return &(*property_set_); }
+  PropertySet* GetProperties() { return &(*property_set_); }
+
+  int32_t level() const override {
+    return property_set_->level.value();
+  }
+
+  bool is_level_valid() const override {
+    return property_set_->level.is_valid();
+  }
+
+  void SetLevelChangedCallback(
+      const base::RepeatingCallback<void(int32_t)>& callback) override {
+    on_level_changed_ = callback;
+  }
+
+ private:
+  void OnPropertyChanged(const std::string& property_name) {
+    if (property_name == LevelName() && !on_level_changed_.is_null())
+      on_level_changed_.Run(property_set_->level.value());
+    if (!on_property_changed_.is_null())
+      on_property_changed_.Run(this, property_name);
+  }
+
+  scoped_refptr<dbus::Bus> bus_;
+  const std::string service_name_{"org.chromium.Frobinator"};
+  const dbus::ObjectPath object_path_{"/org/chromium/Frobinator"};
+  base::RepeatingCallback<void(FrobinatorSettingsProxyInterface*, const std::string&)> on_property_changed_;
+  base::RepeatingCallback<void(int32_t)> on_level_changed_;
+  dbus::ObjectProxy* dbus_object_proxy_;
+  std::unique_ptr<PropertySet> property_set_;
+
+};
+
+}  // namespace chromium
+}  // namespace org
+
+namespace org {
+namespace chromium {
+
+// Abstract interface proxy for org::chromium::Frob.
+class FrobProxyInterface {
+ public:
+  static constexpr char kInterfaceName[] = "org.chromium.Frob";
+
+  virtual ~FrobProxyInterface() = default;
+
+  virtual const dbus::ObjectPath& GetObjectPath() const = 0;
+  virtual dbus::ObjectProxy* GetObjectProxy() const = 0;
+};
+
+}  // namespace chromium
+}  // namespace org
+
+namespace org {
+namespace chromium {
+
+// Interface proxy for org::chromium::Frob.
+class FrobProxy final : public FrobProxyInterface {
+ public:
+  FrobProxy(
+      const scoped_refptr<dbus::Bus>& bus,
+      const dbus::ObjectPath& object_path) :
+          bus_{bus},
+          object_path_{object_path},
+          dbus_object_proxy_{
+              bus_->GetObjectProxy(service_name_, object_path_)} {
+  }
+
+  FrobProxy(const FrobProxy&) = delete;
+  FrobProxy& operator=(const FrobProxy&) = delete;
+
+  ~FrobProxy() override {
+  }
+
+  void ReleaseObjectProxy(base::OnceClosure callback) {
+    bus_->RemoveObjectProxy(service_name_, object_path_, std::move(callback));
+  }
+
+  const dbus::ObjectPath& GetObjectPath() const override {
+    return object_path_;
+  }
+
+  dbus::ObjectProxy* GetObjectProxy() const override {
+    return dbus_object_proxy_;
+  }
+
+ private:
+  scoped_refptr<dbus::Bus> bus_;
+  const std::string service_name_{"org.chromium.Frobinator"};
+  dbus::ObjectPath object_path_;
+  dbus::ObjectProxy* dbus_object_proxy_;
+
+};
+
+}  // namespace chromium
+}  // namespace org
+
+namespace org {
+namespace chromium {
+
+// Creates the proxies of the interfaces provided by org.chromium.Frobinator
+// at their well-known object paths.
+class FrobinatorClientFactory {
+ public:
+  // Connects to the system bus and creates the proxies.
+  // Returns nullptr if the connection fails.
+  static std::unique_ptr<FrobinatorClientFactory> CreateOnSystemBus() {
+    return Create(dbus::Bus::SYSTEM);
+  }
+
+  // Connects to the session bus and creates the proxies.
+  // Returns nullptr if the connection fails.
+  static std::unique_ptr<FrobinatorClientFactory> CreateOnSessionBus() {
+    return Create(dbus::Bus::SESSION);
+  }
+
+  explicit FrobinatorClientFactory(const scoped_refptr<dbus::Bus>& bus)
+      : bus_{bus},
+        frobinator_proxy_{std::make_unique<org::chromium::FrobinatorProxy>(bus_)},
+        frobinator_settings_proxy_{std::make_unique<org::chromium::FrobinatorSettingsProxy>(bus_)} {
+    frobinator_settings_proxy_->InitializeProperties(base::DoNothing());
+  }
+
+  FrobinatorClientFactory(const FrobinatorClientFactory&) = delete;
+  FrobinatorClientFactory& operator=(const FrobinatorClientFactory&) = delete;
+
+  const scoped_refptr<dbus::Bus>& bus() const { return bus_; }
+  org::chromium::FrobinatorProxyInterface* frobinator_proxy() const { return frobinator_proxy_.get(); }
+  org::chromium::FrobinatorSettingsProxyInterface* frobinator_settings_proxy() const { return frobinator_settings_proxy_.get(); }
+
+ private:
+  static std::unique_ptr<FrobinatorClientFactory> Create(dbus::Bus::BusType bus_type) {
+    dbus::Bus::Options options;
+    options.bus_type = bus_type;
+    auto bus = base::MakeRefCounted<dbus::Bus>(options);
+    if (!bus->Connect())
+      return nullptr;
+    return std::make_unique<FrobinatorClientFactory>(bus);
+  }
+
+  scoped_refptr<dbus::Bus> bus_;
+  std::unique_ptr<org::chromium::FrobinatorProxy> frobinator_proxy_;
+  std::unique_ptr<org::chromium::FrobinatorSettingsProxy> frobinator_settings_proxy_;
+};
+
+}  // namespace chromium
+}  // namespace org
+
+#endif  // ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+`
+	if diff := cmp.Diff(out.String(), want); diff != "" {
+		t.Errorf("Generate failed (-got +want):\n%s", diff)
+	}
+}
+
+func TestMakeClientFactoryProxies(t *testing.T) {
+	introspections := []introspect.Introspection{{
+		Name: "/org/chromium/Test",
+		Interfaces: []introspect.Interface{
+			{Name: "org.chromium.Test"},
+			{
+				Name:       "org.chromium.TestManaged",
+				Properties: []introspect.Property{{Name: "P", Type: "i", Access: "read"}},
+			},
+			{
+				Name:       "org.chromium.TestUnmanaged",
+				Properties: []introspect.Property{{Name: "P", Type: "i", Access: "read"}},
+			},
+		},
+	}}
+
+	got := makeClientFactoryProxies(introspections, serviceconfig.Config{
+		ObjectManager: &serviceconfig.ObjectManagerConfig{Name: "org.chromium.Test.ObjectManager"},
+		Interfaces: map[string]serviceconfig.InterfaceConfig{
+			"org.chromium.TestUnmanaged": {Properties: serviceconfig.PropertyModePropertySet},
+		},
+	})
+	want := []clientFactoryProxy{{
+		Name:          "test_proxy",
+		ProxyType:     "org::chromium::TestProxy",
+		InterfaceType: "org::chromium::TestProxyInterface",
+	}, {
+		Name:           "test_unmanaged_proxy",
+		ProxyType:      "org::chromium::TestUnmanagedProxy",
+		InterfaceType:  "org::chromium::TestUnmanagedProxyInterface",
+		HasPropertySet: true,
+	}}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("makeClientFactoryProxies failed (-got +want):\n%s", diff)
+	}
+}
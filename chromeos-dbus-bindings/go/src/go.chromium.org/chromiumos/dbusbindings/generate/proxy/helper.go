@@ -8,15 +8,17 @@ import (
 	"fmt"
 	"strings"
 
+	"go.chromium.org/chromiumos/dbusbindings/dbustype"
 	"go.chromium.org/chromiumos/dbusbindings/generate/genutil"
 	"go.chromium.org/chromiumos/dbusbindings/introspect"
+	"go.chromium.org/chromiumos/dbusbindings/serviceconfig"
 )
 
 type param struct {
 	Type, Name string
 }
 
-func makeMethodParams(offset int, args []introspect.MethodArg) ([]param, error) {
+func makeMethodParams(style serviceconfig.NamingStyle, offset int, args []introspect.MethodArg) ([]param, error) {
 	var ret []param
 	for i, a := range args {
 		argType, prefix := a.InArgType, "in"
@@ -28,32 +30,273 @@ func makeMethodParams(offset int, args []introspect.MethodArg) ([]param, error)
 			return nil, err
 		}
 		// The number-suffix is 1-indexed.
-		ret = append(ret, param{t, genutil.ArgName(prefix, a.Name, i+offset+1)})
+		ret = append(ret, param{t, genutil.ArgNameWithStyle(style, prefix, a.Name, i+offset+1)})
 	}
 
 	return ret, nil
 }
 
-func makeMethodCallbackType(args []introspect.MethodArg) (string, error) {
+// defaultArgOverload is an overload of a proxy method omitting trailing input
+// arguments which have default values.
+type defaultArgOverload struct {
+	// InParams are the input parameters the overload takes.
+	InParams []param
+	// Forwards are the input arguments passed to the method, i.e. the names
+	// of InParams followed by the default values of the omitted arguments.
+	Forwards []string
+}
+
+// makeDefaultArgOverloads returns the overloads of the method m, one for each
+// number of omitted arguments, starting from the overload omitting only the
+// last argument.
+func makeDefaultArgOverloads(style serviceconfig.NamingStyle, m introspect.Method) ([]defaultArgOverload, error) {
+	args := m.InputArguments()
+	params, err := makeMethodParams(style, 0, args)
+	if err != nil {
+		return nil, err
+	}
+	var ret []defaultArgOverload
+	for n := len(args) - 1; n >= 0 && args[n].DefaultValue() != ""; n-- {
+		var forwards []string
+		for i, a := range args {
+			if i < n {
+				forwards = append(forwards, params[i].Name)
+			} else {
+				forwards = append(forwards, a.DefaultValue())
+			}
+		}
+		ret = append(ret, defaultArgOverload{InParams: params[:n], Forwards: forwards})
+	}
+	return ret, nil
+}
+
+// hasDefaultValues returns true if any input argument of m has a default value.
+func hasDefaultValues(m introspect.Method) bool {
+	for _, a := range m.InputArguments() {
+		if a.DefaultValue() != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// moveOverload is an overload of a proxy method taking the input arguments
+// annotated with org.chromium.DBus.Argument.PassByMove by rvalue reference.
+type moveOverload struct {
+	// InParams are the input parameters the overload takes.
+	InParams []param
+	// Locals are the local variables which the arguments taken by rvalue
+	// reference are moved into, so that the payloads are released once the
+	// call is sent. Passing them as lvalues calls the virtual method rather
+	// than the overload again.
+	Locals []moveLocal
+	// Forwards are the input arguments passed to the method.
+	Forwards []string
+}
+
+// moveLocal is a local variable of a moveOverload.
+type moveLocal struct {
+	Type, Name string
+	// Param is the name of the parameter moved into the variable.
+	Param string
+}
+
+// makeMoveOverload returns the overload of the method m taking the arguments
+// annotated with org.chromium.DBus.Argument.PassByMove by rvalue reference,
+// or nil if none is.
+func makeMoveOverload(style serviceconfig.NamingStyle, m introspect.Method) (*moveOverload, error) {
+	if !hasMoveOverload(m) {
+		return nil, nil
+	}
+	args := m.InputArguments()
+	params, err := makeMethodParams(style, 0, args)
+	if err != nil {
+		return nil, err
+	}
+	ret := &moveOverload{}
+	for i, a := range args {
+		p := params[i]
+		if !a.PassByMove() {
+			ret.InParams = append(ret.InParams, p)
+			ret.Forwards = append(ret.Forwards, p.Name)
+			continue
+		}
+		// The containers are passed by const reference.
+		t := strings.TrimSuffix(strings.TrimPrefix(p.Type, "const "), "&")
+		local := p.Name + "_moved"
+		ret.InParams = append(ret.InParams, param{t + "&&", p.Name})
+		ret.Locals = append(ret.Locals, moveLocal{Type: t, Name: local, Param: p.Name})
+		ret.Forwards = append(ret.Forwards, local)
+	}
+	return ret, nil
+}
+
+// hasMoveOverload returns true if any input argument of m is annotated with
+// org.chromium.DBus.Argument.PassByMove.
+func hasMoveOverload(m introspect.Method) bool {
+	for _, a := range m.InputArguments() {
+		if a.PassByMove() {
+			return true
+		}
+	}
+	return false
+}
+
+// hasAsyncOverloads returns true if the proxy interface defines non-virtual
+// overloads of both the blocking and the async methods of m, which need to
+// be unhidden in the derived classes.
+func hasAsyncOverloads(m introspect.Method) bool {
+	return hasDefaultValues(m) || hasMoveOverload(m)
+}
+
+// expectedResult is the result of the blocking overload of a method
+// returning base::expected.
+type expectedResult struct {
+	// Type is the return type of the overload.
+	Type string
+	// ValueType is the type of the output argument of the method.
+	ValueType string
+}
+
+// makeExpectedResult returns the result of the blocking overload of m
+// returning its output argument or the error as base::expected, or nil if m
+// does not have exactly one output argument.
+func makeExpectedResult(m introspect.Method) (*expectedResult, error) {
+	out := m.OutputArguments()
+	if len(out) != 1 {
+		return nil, nil
+	}
+	t, err := out[0].OutArgType()
+	if err != nil {
+		return nil, err
+	}
+	t = strings.TrimSuffix(t, "*")
+	return &expectedResult{
+		Type:      fmt.Sprintf("base::expected<%s, brillo::ErrorPtr>", t),
+		ValueType: t,
+	}, nil
+}
+
+// hasInterfaceOverloads returns true if the proxy interface defines
+// non-virtual overloads of the blocking method m, which need to be unhidden
+// in the derived classes.
+func hasInterfaceOverloads(m introspect.Method, expectedResults bool) bool {
+	return hasVariantOverload(m) || (expectedResults && len(m.OutputArguments()) == 1)
+}
+
+// makeArgComments returns the comments describing the D-Bus types of the arguments of
+// the method whose C++ types are hard to read, i.e. those containing structs or dicts other
+// than brillo::VariantDictionary, and the types which the variant arguments may carry.
+// If async is true, the output arguments are named as the parameters of the success
+// callback of the asynchronous method.
+func makeArgComments(style serviceconfig.NamingStyle, async bool, m introspect.Method) ([]string, error) {
+	var ret []string
+	in := m.InputArguments()
+	for _, args := range []struct {
+		prefix string
+		offset int
+		args   []introspect.MethodArg
+	}{
+		{"in", 0, in},
+		{"out", len(in), m.OutputArguments()},
+	} {
+		for i, a := range args.args {
+			if types, closed := a.VariantTypes(); len(types) > 0 {
+				var ds []string
+				for _, t := range types {
+					d, err := dbustype.Describe(t)
+					if err != nil {
+						return nil, err
+					}
+					ds = append(ds, d)
+				}
+				if !closed {
+					ds = append(ds, "...")
+				}
+				name := makeArgCommentName(style, async, args.prefix, a.Name, i, args.offset)
+				ret = append(ret, fmt.Sprintf("%s: variant of %s", name, strings.Join(ds, ", ")))
+				continue
+			}
+			// Annotated arguments are rendered as named C++ types.
+			if _, ok := a.TypeAnnotation(); ok {
+				continue
+			}
+			sig := string(a.Type)
+			if !strings.ContainsAny(strings.ReplaceAll(sig, "a{sv}", ""), "({") {
+				continue
+			}
+			d, err := dbustype.Describe(sig)
+			if err != nil {
+				return nil, err
+			}
+			name := makeArgCommentName(style, async, args.prefix, a.Name, i, args.offset)
+			ret = append(ret, fmt.Sprintf("%s: %s", name, d))
+		}
+	}
+	return ret, nil
+}
+
+// makeArgCommentName returns the name of the i-th argument with the prefix
+// used in the comments made by makeArgComments.
+func makeArgCommentName(style serviceconfig.NamingStyle, async bool, prefix, name string, i, offset int) string {
+	if !async || prefix != "out" {
+		return genutil.ArgNameWithStyle(style, prefix, name, genutil.MethodArgIndex(prefix == "out", i, offset))
+	}
+	// The output arguments are passed to the success callback, which names
+	// them as makeMethodCallbackType does.
+	if name == "" {
+		return fmt.Sprintf("success_callback argument %d", i+1)
+	}
+	return makeCommentName(style, name)
+}
+
+// makeCallbackArgType returns the type of the success callback parameter for
+// the output argument a. If moveProtos is set, protobuf messages are passed
+// as rvalue references, so that the callback can take them without a copy.
+func makeCallbackArgType(a introspect.MethodArg, moveProtos bool) (string, error) {
+	if v, ok := a.Annotation("org.chromium.DBus.Argument.ProtobufClass"); ok && moveProtos {
+		return v + "&&", nil
+	}
+	return a.CallbackType()
+}
+
+func makeMethodCallbackType(style serviceconfig.NamingStyle, moveProtos bool, args []introspect.MethodArg) (string, error) {
 	var params []string
 	for _, a := range args {
-		t, err := a.CallbackType()
+		t, err := makeCallbackArgType(a, moveProtos)
 		if err != nil {
 			return "", err
 		}
 		if a.Name == "" {
 			params = append(params, t)
 		} else {
-			params = append(params, fmt.Sprintf("%s /*%s*/", t, a.Name))
+			params = append(params, fmt.Sprintf("%s /*%s*/", t, makeCommentName(style, a.Name)))
 		}
 	}
 	return fmt.Sprintf("base::OnceCallback<void(%s)>", strings.Join(params, ", ")), nil
 
 }
 
-func makeMockMethodParams(args []introspect.MethodArg) ([]param, error) {
-	var ret []param
+// makeAwaitableType returns the type of the awaitable returned by the *Await()
+// method, whose template arguments are the parameters of the success callback.
+func makeAwaitableType(moveProtos bool, args []introspect.MethodArg) (string, error) {
+	var params []string
 	for _, a := range args {
+		t, err := makeCallbackArgType(a, moveProtos)
+		if err != nil {
+			return "", err
+		}
+		params = append(params, t)
+	}
+	return fmt.Sprintf("chromeos_dbus_bindings::DBusAwaitable<%s>", strings.Join(params, ", ")), nil
+}
+
+// makeMockMethodParams returns the parameters of the MOCK_METHODs for args,
+// following the input arguments numbering offset, whose names are given in
+// comments. The unnamed arguments get the names of the proxy parameters.
+func makeMockMethodParams(style serviceconfig.NamingStyle, offset int, args []introspect.MethodArg) ([]param, error) {
+	var ret []param
+	for i, a := range args {
 		argType, prefix := a.InArgType, "in"
 		if a.Direction == "out" {
 			argType, prefix = a.OutArgType, "out"
@@ -62,36 +305,282 @@ func makeMockMethodParams(args []introspect.MethodArg) ([]param, error) {
 		if err != nil {
 			return nil, err
 		}
-		if a.Name == "" {
-			ret = append(ret, param{t, ""})
-		} else {
-			ret = append(ret, param{t, fmt.Sprintf("/*%s_%s*/", prefix, a.Name)})
-		}
+		name := genutil.ArgNameWithStyle(style, prefix, a.Name, genutil.MethodArgIndex(a.Direction == "out", i, offset))
+		ret = append(ret, param{t, fmt.Sprintf("/*%s*/", name)})
 	}
 
 	return ret, nil
 }
 
+// makeCommentName returns the argument name used in parameter comments.
+func makeCommentName(style serviceconfig.NamingStyle, name string) string {
+	if style != serviceconfig.NamingStyleCamelCase {
+		return name
+	}
+	c := genutil.MakeCamelCaseName(name)
+	if c == "" {
+		return name
+	}
+	return strings.ToLower(c[:1]) + c[1:]
+}
+
+// propertyAccessors holds the names of the generated accessors of a property.
+type propertyAccessors struct {
+	Getter, Validator, Setter string
+	// ChangedCallbackSetter is always CamelCase like SetPropertyChangedCallback.
+	ChangedCallbackSetter string
+}
+
+func makePropertyAccessors(style serviceconfig.NamingStyle, p *introspect.Property) propertyAccessors {
+	name := genutil.MakeVariableName(p.VariableName())
+	c := genutil.MakeCamelCaseName(name)
+	changed := "Set" + c + "ChangedCallback"
+	if style == serviceconfig.NamingStyleCamelCase {
+		return propertyAccessors{Getter: c, Validator: "Is" + c + "Valid", Setter: "Set" + c, ChangedCallbackSetter: changed}
+	}
+	return propertyAccessors{Getter: name, Validator: "is_" + name + "_valid", Setter: "set_" + name, ChangedCallbackSetter: changed}
+}
+
 // Returns stringified C++ type for signal callback.
-func makeSignalCallbackType(args []introspect.SignalArg) (string, error) {
-	if len(args) == 0 {
+func makeSignalCallbackType(s introspect.Signal) (string, error) {
+	if s.Kind() == introspect.SignalKindRaw {
+		return signalCallbackTypePrefix + "dbus::Signal*)>&", nil
+	}
+	if len(s.Args) == 0 {
+		return "base::RepeatingClosure", nil
+	}
+
+	// The builder is pre-sized for the prefix, the separators and short
+	// types, so that it rarely grows.
+	var b strings.Builder
+	b.Grow(len(signalCallbackTypePrefix) + len(s.Args)*(len(signalCallbackTypeSep)+32))
+	b.WriteString(signalCallbackTypePrefix)
+	for i, a := range s.Args {
+		t, err := a.CallbackType()
+		if err != nil {
+			return "", err
+		}
+		if i > 0 {
+			b.WriteString(signalCallbackTypeSep)
+		}
+		b.WriteString(t)
+	}
+	b.WriteString(")>&")
+	return b.String(), nil
+}
+
+// makeSignalParamTypes returns the comma-separated C++ parameter types of the
+// handler of s, i.e. those of makeSignalCallbackType on a single line.
+func makeSignalParamTypes(s introspect.Signal) (string, error) {
+	if s.Kind() == introspect.SignalKindRaw {
+		return "dbus::Signal*", nil
+	}
+	var types []string
+	for _, a := range s.Args {
+		t, err := a.CallbackType()
+		if err != nil {
+			return "", err
+		}
+		types = append(types, t)
+	}
+	return strings.Join(types, ", "), nil
+}
+
+// The prefixes of the signal callback types, and the separators of their
+// parameters aligning them after the prefixes. They are computed once, as
+// the helpers are called for every signal.
+const (
+	signalCallbackTypePrefix  = "const base::RepeatingCallback<void("
+	signalCallbackAliasPrefix = "base::RepeatingCallback<void("
+)
+
+var (
+	signalCallbackTypeSep  = ",\n" + strings.Repeat(" ", len(signalCallbackTypePrefix))
+	signalCallbackAliasSep = ",\n" + strings.Repeat(" ", len(signalCallbackAliasPrefix))
+)
+
+// makeSignalCallbackAlias returns the C++ type of the signal callback to be
+// aliased in the interface class. Unlike makeSignalCallbackType, the argument
+// names are kept as comments.
+// Both take the raw dbus::Signal for the signals of SignalKindRaw.
+func makeSignalCallbackAlias(style serviceconfig.NamingStyle, s introspect.Signal) (string, error) {
+	if s.Kind() == introspect.SignalKindRaw {
+		return signalCallbackAliasPrefix + "dbus::Signal* /*signal*/)>", nil
+	}
+	if len(s.Args) == 0 {
 		return "base::RepeatingClosure", nil
 	}
 
 	var lines []string
-	for _, a := range args {
+	for _, a := range s.Args {
 		line, err := a.CallbackType()
 		if err != nil {
 			return "", err
 		}
+		if a.Name != "" {
+			line = fmt.Sprintf("%s /*%s*/", line, makeCommentName(style, a.Name))
+		}
 		lines = append(lines, line)
 	}
-	const (
-		prefix = "const base::RepeatingCallback<void("
-		suffix = ")>&"
-	)
-	indent := strings.Repeat(" ", len(prefix))
-	return fmt.Sprintf("%s%s%s", prefix, strings.Join(lines, ",\n"+indent), suffix), nil
+	return signalCallbackAliasPrefix + strings.Join(lines, signalCallbackAliasSep) + ")>", nil
+}
+
+// hasPropertySet returns true if the proxy of itf tracks its properties
+// with dbus::PropertySet.
+func hasPropertySet(itf introspect.Interface) bool {
+	return len(itf.Properties) > 0 && !itf.LightweightProperties()
+}
+
+// isPropertyFetchedOnce returns true if the proxy getter of p blocks to fetch
+// the value when it is not cached yet.
+func isPropertyFetchedOnce(p *introspect.Property) bool {
+	return p.CachePolicy() == introspect.PropertyCachePolicyFetchOnce
+}
+
+// isPropertyFetchedAlways returns true if the proxy getter of p blocks to
+// fetch the value on every call.
+func isPropertyFetchedAlways(p *introspect.Property) bool {
+	return p.CachePolicy() == introspect.PropertyCachePolicyAlways
+}
+
+// isPropertyConst returns true if the value of p never changes, so that the
+// proxy fetches it once instead of tracking it with the property set.
+func isPropertyConst(p *introspect.Property) bool {
+	return p.EmitsChangedSignal() == introspect.PropertyEmitsChangedSignalConst
+}
+
+// isPropertyInvalidated returns true if the changes of p are notified without
+// the new value, so that the proxy needs to fetch it.
+func isPropertyInvalidated(p *introspect.Property) bool {
+	return p.EmitsChangedSignal() == introspect.PropertyEmitsChangedSignalInvalidates
+}
+
+// isPropertyPolled returns true if the changes of p are not notified, so that
+// its value can be observed only by polling.
+func isPropertyPolled(p *introspect.Property) bool {
+	return p.EmitsChangedSignal() == introspect.PropertyEmitsChangedSignalFalse
+}
+
+// isPropertyVariantDict returns true if p is a brillo::VariantDictionary,
+// whose entries the proxy interface has a typed getter of.
+func isPropertyVariantDict(p *introspect.Property) bool {
+	return p.Type == "a{sv}"
+}
+
+// hasPropertyChangedCallback returns true if the proxy has the typed callback
+// for the changes of p, i.e. p is readable and may change.
+func hasPropertyChangedCallback(p *introspect.Property) bool {
+	return p.Readable() && !isPropertyConst(p)
+}
+
+// hasPropertySetPropertiesWith returns true if any property tracked by a
+// property set in introspects satisfies pred.
+func hasPropertySetPropertiesWith(introspects []introspect.Introspection, pred func(*introspect.Property) bool) bool {
+	for _, i := range introspects {
+		for _, itf := range i.Interfaces {
+			if !hasPropertySet(itf) {
+				continue
+			}
+			for j := range itf.Properties {
+				if pred(&itf.Properties[j]) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// hasConstProperties returns true if any proxy in introspects fetches a const
+// property.
+func hasConstProperties(introspects []introspect.Introspection) bool {
+	return hasPropertySetPropertiesWith(introspects, isPropertyConst)
+}
+
+// hasInvalidatedProperties returns true if any proxy in introspects refetches
+// an invalidated property.
+func hasInvalidatedProperties(introspects []introspect.Introspection) bool {
+	return hasPropertySetPropertiesWith(introspects, isPropertyInvalidated)
+}
+
+// hasLightweightProperties returns true if the proxy of itf tracks its
+// properties by connecting to the PropertiesChanged signal directly.
+func hasLightweightProperties(itf introspect.Interface) bool {
+	return len(itf.Properties) > 0 && itf.LightweightProperties()
+}
+
+// anyLightweightProperties returns true if the proxy of any interface in
+// introspects has lightweight properties.
+func anyLightweightProperties(introspects []introspect.Introspection) bool {
+	for _, i := range introspects {
+		for _, itf := range i.Interfaces {
+			if hasLightweightProperties(itf) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// interfaceSettings are the settings of the code generated for an interface
+// which the service config can override per interface.
+type interfaceSettings struct {
+	ObjectManagerName    string
+	DisableBlockingCalls bool
+}
+
+// makeInterfaceSettings returns the settings of the interface named name in
+// config.
+func makeInterfaceSettings(config serviceconfig.Config, name string) interfaceSettings {
+	c := config.ForInterface(name)
+	ret := interfaceSettings{DisableBlockingCalls: c.DisableBlockingCalls}
+	if c.ObjectManager != nil {
+		ret.ObjectManagerName = c.ObjectManager.Name
+	}
+	return ret
+}
+
+// managedIntrospects returns a copy of introspects containing only the
+// interfaces whose properties are fetched through the object manager of
+// config.
+func managedIntrospects(introspects []introspect.Introspection, config serviceconfig.Config) []introspect.Introspection {
+	ret := make([]introspect.Introspection, len(introspects))
+	for i, is := range introspects {
+		ret[i] = is
+		ret[i].Interfaces = nil
+		for _, itf := range is.Interfaces {
+			if makeInterfaceSettings(config, itf.Name).ObjectManagerName != "" {
+				ret[i].Interfaces = append(ret[i].Interfaces, itf)
+			}
+		}
+	}
+	return ret
+}
+
+// blockingCallsDisabled returns true if the blocking calls are disabled for
+// any interface in introspects.
+func blockingCallsDisabled(introspects []introspect.Introspection, config serviceconfig.Config) bool {
+	for _, is := range introspects {
+		for _, itf := range is.Interfaces {
+			if makeInterfaceSettings(config, itf.Name).DisableBlockingCalls {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// checkLightweightProperties returns an error if an interface has lightweight
+// properties while the properties are managed by the object manager omName.
+func checkLightweightProperties(iss []introspect.Introspection, omName string) error {
+	for _, is := range iss {
+		for _, itf := range is.Interfaces {
+			if itf.LightweightProperties() {
+				return fmt.Errorf("interface %s: lightweight properties cannot be used with object manager %s", itf.Name, omName)
+			}
+		}
+	}
+	return nil
 }
 
 // extractInterfacesWithProperties returns an array of Interfaces that have Properties.
@@ -106,3 +595,242 @@ func extractInterfacesWithProperties(iss []introspect.Introspection) []introspec
 	}
 	return ret
 }
+
+// methodError is a D-Bus error name which a method may reply with, and its
+// enumerator in the generated error enum of the method.
+type methodError struct {
+	Name       string
+	Enumerator string
+}
+
+// makeObjectManagerTypeNames maps the names of the interfaces in introspects
+// to the type names used by the accessors of the object manager proxy. They
+// are the last components of the interface names, unless several interfaces
+// share it, in which case the qualified names are used so that the accessors,
+// e.g. GetFooProxy(), do not collide.
+func makeObjectManagerTypeNames(iss []introspect.Introspection) map[string]string {
+	count := make(map[string]int)
+	for _, is := range iss {
+		for _, itf := range is.Interfaces {
+			count[genutil.MakeTypeName(itf.Name)]++
+		}
+	}
+	ret := make(map[string]string)
+	for _, is := range iss {
+		for _, itf := range is.Interfaces {
+			name := genutil.MakeTypeName(itf.Name)
+			if count[name] > 1 {
+				name = genutil.MakeQualifiedTypeName(itf.Name)
+			}
+			ret[itf.Name] = name
+		}
+	}
+	return ret
+}
+
+// makeMethodErrors returns the errors listed in the Errors annotation of m.
+func makeMethodErrors(m introspect.Method) []methodError {
+	var ret []methodError
+	for _, e := range m.Errors() {
+		ret = append(ret, methodError{Name: e, Enumerator: "k" + genutil.MakeTypeName(e)})
+	}
+	return ret
+}
+
+// hasMethodErrors returns true if any method in introspects lists its errors.
+func hasMethodErrors(introspects []introspect.Introspection) bool {
+	for _, i := range introspects {
+		for _, itf := range i.Interfaces {
+			for _, m := range itf.Methods {
+				if len(m.Errors()) > 0 {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// hasSignals returns true if any interface in introspects has signals.
+func hasSignals(introspects []introspect.Introspection) bool {
+	for _, i := range introspects {
+		for _, itf := range i.Interfaces {
+			if len(itf.Signals) > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// makeSignalSenderParams returns the parameters of the function sending s for
+// testing, which are the arguments of s as passed by the service.
+func makeSignalSenderParams(style serviceconfig.NamingStyle, s introspect.Signal) ([]param, error) {
+	var ret []param
+	for i, a := range s.Args {
+		t, err := a.InArgType()
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, param{t, genutil.ArgNameWithStyle(style, "in", a.Name, i+1)})
+	}
+	return ret, nil
+}
+
+// checkSignalSenderCollisions returns an error if the functions sending the
+// signals for testing collide, i.e. two interfaces in the same C++ namespace
+// have signals with the same name.
+func checkSignalSenderCollisions(iss []introspect.Introspection, overrides map[string]string) error {
+	seen := make(map[string]string)
+	for _, is := range iss {
+		for _, itf := range is.Interfaces {
+			ns := strings.Join(genutil.MakeNameSpaces(overrides, itf.Name), "::")
+			for _, s := range itf.Signals {
+				key := ns + "::" + s.Name
+				if other, ok := seen[key]; ok && other != itf.Name {
+					return fmt.Errorf("signal %s of interfaces %s and %s: Send%sSignalForTesting collides in namespace %s", s.Name, other, itf.Name, s.Name, ns)
+				}
+				seen[key] = itf.Name
+			}
+		}
+	}
+	return nil
+}
+
+// isRawSignal returns true if the handler of s takes the raw dbus::Signal.
+func isRawSignal(s introspect.Signal) bool {
+	return s.Kind() == introspect.SignalKindRaw
+}
+
+// hasRawSignals returns true if any signal in introspects is handled raw.
+func hasRawSignals(introspects []introspect.Introspection) bool {
+	for _, i := range introspects {
+		for _, itf := range i.Interfaces {
+			for _, s := range itf.Signals {
+				if isRawSignal(s) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// hasNamedStructs returns true if any interface in introspects has arguments
+// rendered as named structs.
+func hasNamedStructs(introspects []introspect.Introspection) (bool, error) {
+	for _, i := range introspects {
+		for _, itf := range i.Interfaces {
+			structs, err := genutil.MakeNamedStructs(itf)
+			if err != nil {
+				return false, err
+			}
+			if len(structs) > 0 {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// hasNamedFlags returns true if any interface in introspects has arguments
+// rendered as flags enums.
+func hasNamedFlags(introspects []introspect.Introspection) (bool, error) {
+	for _, i := range introspects {
+		for _, itf := range i.Interfaces {
+			flags, err := genutil.MakeNamedFlags(itf)
+			if err != nil {
+				return false, err
+			}
+			if len(flags) > 0 {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// interfaceHasFDStream returns true if any method of itf returns a file descriptor stream.
+func interfaceHasFDStream(itf introspect.Interface) bool {
+	for _, m := range itf.Methods {
+		if m.ReturnsFDStream() {
+			return true
+		}
+	}
+	return false
+}
+
+// hasFDStream returns true if any method in introspects returns a file descriptor stream.
+func hasFDStream(introspects []introspect.Introspection) bool {
+	for _, i := range introspects {
+		for _, itf := range i.Interfaces {
+			if interfaceHasFDStream(itf) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// hasNoReplyMethods returns true if any method in introspects has no reply.
+func hasNoReplyMethods(introspects []introspect.Introspection) bool {
+	for _, i := range introspects {
+		for _, itf := range i.Interfaces {
+			for _, m := range itf.Methods {
+				if m.NoReply() {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// proxyFactory is the Create() factory of an abstract proxy interface.
+type proxyFactory struct {
+	// Params are the parameters of Create() following the bus, which are
+	// passed to the proxy constructor.
+	Params []param
+}
+
+// makeProxyFactory returns the factory of the abstract proxy interface of itf
+// in is, or nil if factories are not enabled. The proxies created by the
+// object manager get no factory, as they are not created by the clients.
+func makeProxyFactory(enabled bool, serviceName, omName string, is introspect.Introspection, itf introspect.Interface) *proxyFactory {
+	if !enabled || (omName != "" && len(itf.Properties) > 0) {
+		return nil
+	}
+	ret := &proxyFactory{}
+	if serviceName == "" {
+		ret.Params = append(ret.Params, param{Type: "const std::string&", Name: "service_name"})
+	}
+	if is.Name == "" {
+		ret.Params = append(ret.Params, param{Type: "const dbus::ObjectPath&", Name: "object_path"})
+	}
+	return ret
+}
+
+// makeProtobufIncludes returns the #include targets of the headers listed in the
+// ProtobufIncludes annotations of the interfaces which use protobuf classes, without duplicates.
+// Paths which are not enclosed by <> or "" are quoted.
+func makeProtobufIncludes(introspects []introspect.Introspection) []string {
+	var ret []string
+	seen := make(map[string]bool)
+	for _, i := range introspects {
+		for _, itf := range i.Interfaces {
+			if !itf.UsesProtobuf() {
+				continue
+			}
+			for _, inc := range itf.ProtobufIncludes() {
+				if !strings.HasPrefix(inc, "<") && !strings.HasPrefix(inc, `"`) {
+					inc = fmt.Sprintf("%q", inc)
+				}
+				if !seen[inc] {
+					seen[inc] = true
+					ret = append(ret, inc)
+				}
+			}
+		}
+	}
+	return ret
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/google/go-cmp/cmp"
 
 	"go.chromium.org/chromiumos/dbusbindings/introspect"
+	"go.chromium.org/chromiumos/dbusbindings/serviceconfig"
 )
 
 func TestMakeMethodParams(t *testing.T) {
@@ -88,7 +89,7 @@ func TestMakeMethodParams(t *testing.T) {
 	}}
 
 	for _, tc := range cases {
-		got, err := makeMethodParams(tc.offset, tc.args)
+		got, err := makeMethodParams("", tc.offset, tc.args)
 		if err != nil {
 			t.Errorf("Unexpected method params format error: %v", err)
 		} else if diff := cmp.Diff(got, tc.want); diff != "" {
@@ -99,8 +100,9 @@ func TestMakeMethodParams(t *testing.T) {
 
 func TestMakeMockMethodParams(t *testing.T) {
 	cases := []struct {
-		args []introspect.MethodArg
-		want []param
+		offset int
+		args   []introspect.MethodArg
+		want   []param
 	}{{
 		args: []introspect.MethodArg{{
 			Name: "iarg1", Type: "i",
@@ -127,11 +129,11 @@ func TestMakeMockMethodParams(t *testing.T) {
 			Type: "i",
 		}},
 		want: []param{
-			{"int32_t", ""},
+			{"int32_t", "/*in_1*/"},
 			{"int32_t", "/*in_iarg2*/"},
-			{"int32_t", ""},
+			{"int32_t", "/*in_3*/"},
 			{"int32_t", "/*in_iarg4*/"},
-			{"int32_t", ""},
+			{"int32_t", "/*in_5*/"},
 		},
 	}, {
 		args: []introspect.MethodArg{{
@@ -147,6 +149,7 @@ func TestMakeMockMethodParams(t *testing.T) {
 			{"dbus::ObjectPath*", "/*out_oarg3*/"},
 		},
 	}, {
+		offset: 2,
 		args: []introspect.MethodArg{{
 			Type: "i", Direction: "out",
 		}, {
@@ -159,16 +162,16 @@ func TestMakeMockMethodParams(t *testing.T) {
 			Type: "i", Direction: "out",
 		}},
 		want: []param{
-			{"int32_t*", ""},
+			{"int32_t*", "/*out_3*/"},
 			{"int32_t*", "/*out_oarg2*/"},
-			{"int32_t*", ""},
+			{"int32_t*", "/*out_5*/"},
 			{"int32_t*", "/*out_oarg4*/"},
-			{"int32_t*", ""},
+			{"int32_t*", "/*out_7*/"},
 		},
 	}}
 
 	for _, tc := range cases {
-		got, err := makeMockMethodParams(tc.args)
+		got, err := makeMockMethodParams("", tc.offset, tc.args)
 		if err != nil {
 			t.Errorf("Unexpected method params format error: %v", err)
 		} else if diff := cmp.Diff(got, tc.want); diff != "" {
@@ -177,10 +180,58 @@ func TestMakeMockMethodParams(t *testing.T) {
 	}
 }
 
+func TestMakeArgComments(t *testing.T) {
+	m := introspect.Method{
+		Name: "Scan",
+		Args: []introspect.MethodArg{
+			{Name: "options", Type: "a{sv}"},
+			{Name: "filters", Type: "a{sa{sv}}"},
+			{Type: "(ib)"},
+			{Name: "devices", Type: "a(os)", Direction: "out"},
+			{Name: "count", Type: "i", Direction: "out"},
+		},
+	}
+	got, err := makeArgComments("", false, m)
+	if err != nil {
+		t.Fatalf("makeArgComments got error, want nil: %v", err)
+	}
+	want := []string{
+		"in_filters: dict<string, dict<string, variant>>",
+		"in_3: struct<int32, boolean>",
+		"out_devices: array of struct<object path, string>",
+	}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("makeArgComments failed (-got +want):\n%s", diff)
+	}
+
+	m.Args = append(m.Args, introspect.MethodArg{Type: "a{sa{sv}}", Direction: "out"})
+	got, err = makeArgComments("", true, m)
+	if err != nil {
+		t.Fatalf("makeArgComments got error, want nil: %v", err)
+	}
+	want = []string{
+		"in_filters: dict<string, dict<string, variant>>",
+		"in_3: struct<int32, boolean>",
+		"devices: array of struct<object path, string>",
+		"success_callback argument 3: dict<string, dict<string, variant>>",
+	}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("makeArgComments for async failed (-got +want):\n%s", diff)
+	}
+}
+
 func TestMakeMethodCallbackType(t *testing.T) {
+	protoArg := introspect.MethodArg{
+		Name: "response", Type: "ay", Direction: "out",
+		Annotations: []introspect.Annotation{{
+			Name:  "org.chromium.DBus.Argument.ProtobufClass",
+			Value: "ResponseProto",
+		}},
+	}
 	cases := []struct {
-		args []introspect.MethodArg
-		want string
+		args       []introspect.MethodArg
+		moveProtos bool
+		want       string
 	}{{
 		args: []introspect.MethodArg{},
 		want: "base::OnceCallback<void()>",
@@ -200,10 +251,20 @@ func TestMakeMethodCallbackType(t *testing.T) {
 		want: ("base::OnceCallback<void(int32_t /*arg1*/, " +
 			"int64_t /*arg2*/, " +
 			"const std::tuple<std::string, base::ScopedFD>& /*arg3*/)>"),
+	}, {
+		args: []introspect.MethodArg{protoArg},
+		want: "base::OnceCallback<void(const ResponseProto& /*response*/)>",
+	}, {
+		args: []introspect.MethodArg{protoArg, {
+			Name: "data", Type: "ay", Direction: "out",
+		}},
+		moveProtos: true,
+		want: ("base::OnceCallback<void(ResponseProto&& /*response*/, " +
+			"const std::vector<uint8_t>& /*data*/)>"),
 	}}
 
 	for _, tc := range cases {
-		got, err := makeMethodCallbackType(tc.args)
+		got, err := makeMethodCallbackType("", tc.moveProtos, tc.args)
 		if err != nil {
 			t.Errorf("Unexpected method callback type format error: %v", err)
 		} else if got != tc.want {
@@ -212,10 +273,46 @@ func TestMakeMethodCallbackType(t *testing.T) {
 	}
 }
 
+func TestMakeMoveOverloadWithProtobufClass(t *testing.T) {
+	m := introspect.Method{
+		Name: "Upload",
+		Args: []introspect.MethodArg{{
+			Name: "request", Type: "ay",
+			Annotations: []introspect.Annotation{
+				{Name: "org.chromium.DBus.Argument.ProtobufClass", Value: "RequestProto"},
+				{Name: "org.chromium.DBus.Argument.PassByMove", Value: "true"},
+			},
+		}, {
+			Name: "ids", Type: "ai",
+			Annotations: []introspect.Annotation{
+				{Name: "org.chromium.DBus.Argument.PassByMove", Value: "true"},
+				{Name: "org.chromium.DBus.Argument.DefaultValue", Value: "{}"},
+			},
+		}},
+	}
+	got, err := makeMoveOverload("", m)
+	if err != nil {
+		t.Fatalf("makeMoveOverload got error, want nil: %v", err)
+	}
+	want := &moveOverload{
+		InParams: []param{{"RequestProto&&", "in_request"}, {"std::vector<int32_t>&&", "in_ids"}},
+		Locals: []moveLocal{
+			{Type: "RequestProto", Name: "in_request_moved", Param: "in_request"},
+			{Type: "std::vector<int32_t>", Name: "in_ids_moved", Param: "in_ids"},
+		},
+		Forwards: []string{"in_request_moved", "in_ids_moved"},
+	}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("makeMoveOverload failed (-got +want):\n%s", diff)
+	}
+}
+
 func TestMakeSignalCallbackType(t *testing.T) {
+	rawKind := []introspect.Annotation{{Name: "org.chromium.DBus.Signal.Kind", Value: "raw"}}
 	cases := []struct {
-		args []introspect.SignalArg
-		want string
+		args        []introspect.SignalArg
+		annotations []introspect.Annotation
+		want        string
 	}{{
 		args: []introspect.SignalArg{},
 		want: "base::RepeatingClosure",
@@ -235,10 +332,16 @@ func TestMakeSignalCallbackType(t *testing.T) {
 		want: ("const base::RepeatingCallback<void(int32_t,\n" +
 			"                                   int64_t,\n" +
 			"                                   const std::tuple<std::string, base::ScopedFD>&)>&"),
+	}, {
+		args: []introspect.SignalArg{{
+			Type: "i",
+		}},
+		annotations: rawKind,
+		want:        "const base::RepeatingCallback<void(dbus::Signal*)>&",
 	}}
 
 	for _, tc := range cases {
-		got, err := makeSignalCallbackType(tc.args)
+		got, err := makeSignalCallbackType(introspect.Signal{Args: tc.args, Annotations: tc.annotations})
 		if err != nil {
 			t.Errorf("Unexpected signal callback type format error: %v", err)
 		} else if got != tc.want {
@@ -246,3 +349,143 @@ func TestMakeSignalCallbackType(t *testing.T) {
 		}
 	}
 }
+
+func BenchmarkMakeSignalCallbackType(b *testing.B) {
+	s := introspect.Signal{Args: []introspect.SignalArg{
+		{Name: "path", Type: "o"},
+		{Name: "properties", Type: "a{sv}"},
+		{Name: "count", Type: "i"},
+	}}
+	for i := 0; i < b.N; i++ {
+		if _, err := makeSignalCallbackType(s); err != nil {
+			b.Fatalf("makeSignalCallbackType got error, want nil: %v", err)
+		}
+	}
+}
+
+func TestMakeSignalCallbackAlias(t *testing.T) {
+	cases := []struct {
+		style       serviceconfig.NamingStyle
+		args        []introspect.SignalArg
+		annotations []introspect.Annotation
+		want        string
+	}{{
+		args: []introspect.SignalArg{},
+		want: "base::RepeatingClosure",
+	}, {
+		args: []introspect.SignalArg{{
+			Name: "bss_path", Type: "o",
+		}, {
+			Type: "a{sv}",
+		}},
+		want: ("base::RepeatingCallback<void(const dbus::ObjectPath& /*bss_path*/,\n" +
+			"                             const brillo::VariantDictionary&)>"),
+	}, {
+		style: serviceconfig.NamingStyleCamelCase,
+		args: []introspect.SignalArg{{
+			Name: "bss_path", Type: "o",
+		}},
+		want: "base::RepeatingCallback<void(const dbus::ObjectPath& /*bssPath*/)>",
+	}, {
+		args: []introspect.SignalArg{{
+			Name: "bss_path", Type: "o",
+		}},
+		annotations: []introspect.Annotation{{Name: "org.chromium.DBus.Signal.Kind", Value: "raw"}},
+		want:        "base::RepeatingCallback<void(dbus::Signal* /*signal*/)>",
+	}}
+
+	for _, tc := range cases {
+		got, err := makeSignalCallbackAlias(tc.style, introspect.Signal{Args: tc.args, Annotations: tc.annotations})
+		if err != nil {
+			t.Errorf("Unexpected signal callback alias format error: %v", err)
+		} else if got != tc.want {
+			t.Errorf("Unexpected signal callback alias format: got %v, want %v", got, tc.want)
+		}
+	}
+}
+
+func TestMakePropertyAccessors(t *testing.T) {
+	cases := []struct {
+		style serviceconfig.NamingStyle
+		prop  introspect.Property
+		want  propertyAccessors
+	}{{
+		style: "",
+		prop:  introspect.Property{Name: "ScanInterval"},
+		want:  propertyAccessors{"scan_interval", "is_scan_interval_valid", "set_scan_interval", "SetScanIntervalChangedCallback"},
+	}, {
+		style: serviceconfig.NamingStyleCamelCase,
+		prop:  introspect.Property{Name: "ScanInterval"},
+		want:  propertyAccessors{"ScanInterval", "IsScanIntervalValid", "SetScanInterval", "SetScanIntervalChangedCallback"},
+	}, {
+		style: serviceconfig.NamingStyleCamelCase,
+		prop: introspect.Property{
+			Name: "Class",
+			Annotations: []introspect.Annotation{{
+				Name:  "org.chromium.DBus.Argument.VariableName",
+				Value: "bluetooth_class",
+			}},
+		},
+		want: propertyAccessors{"BluetoothClass", "IsBluetoothClassValid", "SetBluetoothClass", "SetBluetoothClassChangedCallback"},
+	}}
+
+	for _, tc := range cases {
+		got := makePropertyAccessors(tc.style, &tc.prop)
+		if diff := cmp.Diff(got, tc.want); diff != "" {
+			t.Errorf("makePropertyAccessors(%q, %q) failed (-got +want):\n%s", tc.style, tc.prop.Name, diff)
+		}
+	}
+}
+
+func TestMakeMethodCallbackTypeCamelCase(t *testing.T) {
+	args := []introspect.MethodArg{
+		{Name: "scan_result", Type: "i"},
+		{Type: "s"},
+	}
+	got, err := makeMethodCallbackType(serviceconfig.NamingStyleCamelCase, false, args)
+	if err != nil {
+		t.Fatalf("Unexpected method callback type format error: %v", err)
+	}
+	const want = "base::OnceCallback<void(int32_t /*scanResult*/, const std::string&)>"
+	if got != want {
+		t.Errorf("Unexpected method callback type format: got %v, want %v", got, want)
+	}
+}
+
+func TestMakeProtobufIncludes(t *testing.T) {
+	protoArg := introspect.MethodArg{
+		Type: "ay",
+		Annotations: []introspect.Annotation{{
+			Name:  "org.chromium.DBus.Argument.ProtobufClass",
+			Value: "Proto",
+		}},
+	}
+	includes := func(v string) []introspect.Annotation {
+		return []introspect.Annotation{{Name: "org.chromium.DBus.Interface.ProtobufIncludes", Value: v}}
+	}
+	introspects := []introspect.Introspection{{
+		Interfaces: []introspect.Interface{
+			{
+				Name:        "a.A",
+				Methods:     []introspect.Method{{Name: "F", Args: []introspect.MethodArg{protoArg}}},
+				Annotations: includes("a/a.pb.h <b/b.pb.h>"),
+			}, {
+				// Not included since no protobuf class is used.
+				Name:        "c.C",
+				Annotations: includes("c/c.pb.h"),
+			},
+		},
+	}, {
+		Interfaces: []introspect.Interface{
+			{
+				Name:        "d.D",
+				Methods:     []introspect.Method{{Name: "F", Args: []introspect.MethodArg{protoArg}}},
+				Annotations: includes(`"a/a.pb.h", d/d.pb.h`),
+			},
+		},
+	}}
+	want := []string{`"a/a.pb.h"`, "<b/b.pb.h>", `"d/d.pb.h"`}
+	if diff := cmp.Diff(makeProtobufIncludes(introspects), want); diff != "" {
+		t.Errorf("makeProtobufIncludes failed (-got +want):\n%s", diff)
+	}
+}
@@ -0,0 +1,597 @@
+// Copyright 2022 The ChromiumOS Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+
+	"go.chromium.org/chromiumos/dbusbindings/generate/genutil"
+	"go.chromium.org/chromiumos/dbusbindings/introspect"
+	"go.chromium.org/chromiumos/dbusbindings/serviceconfig"
+)
+
+const loopbackTemplateText = `// Automatic generation of D-Bus in-process loopbacks for:
+{{range .Introspects}}{{range .Interfaces -}}
+//  - {{.Name}}
+{{end}}{{end -}}
+
+#ifndef {{.HeaderGuard}}
+#define {{.HeaderGuard}}
+#include <memory>
+#include <string>
+#include <utility>
+#include <vector>
+
+#include <base/functional/bind.h>
+#include <base/functional/callback.h>
+#include <base/run_loop.h>
+#include <brillo/dbus/dbus_method_invoker.h>
+#include <brillo/dbus/dbus_method_response.h>
+#include <brillo/dbus/dbus_param_writer.h>
+#include <brillo/dbus/utils.h>
+#include <brillo/errors/error.h>
+#include <dbus/message.h>
+#include <dbus/object_path.h>
+
+#include "{{.AdaptorFilePath}}"
+#include "{{.ProxyFilePath}}"
+
+#ifndef CHROMEOS_DBUS_BINDINGS_DBUS_LOOPBACK_
+#define CHROMEOS_DBUS_BINDINGS_DBUS_LOOPBACK_
+namespace chromeos_dbus_bindings {
+
+// Returns a method call of the method of the interface carrying |args|,
+// which can be replied to.
+template <typename... Args>
+std::unique_ptr<dbus::MethodCall> MakeLoopbackMethodCall(
+    const std::string& interface_name,
+    const std::string& method_name,
+    const Args&... args) {
+  auto method_call =
+      std::make_unique<dbus::MethodCall>(interface_name, method_name);
+  method_call->SetSerial(1);
+  dbus::MessageWriter writer(method_call.get());
+  brillo::dbus_utils::DBusParamWriter::Append(&writer, args...);
+  return method_call;
+}
+
+// Replies to |method_call| with the response carrying |args|.
+template <typename... Args>
+void SendLoopbackResponse(dbus::MethodCall* method_call,
+                          brillo::dbus_utils::ResponseSender sender,
+                          const Args&... args) {
+  std::unique_ptr<dbus::Response> response =
+      dbus::Response::FromMethodCall(method_call);
+  dbus::MessageWriter writer(response.get());
+  brillo::dbus_utils::DBusParamWriter::Append(&writer, args...);
+  std::move(sender).Run(std::move(response));
+}
+
+// Returns |sender| owning |method_call| until the response is sent, as the
+// asynchronous and raw methods may reply after returning.
+inline brillo::dbus_utils::ResponseSender BindLoopbackMethodCall(
+    std::unique_ptr<dbus::MethodCall> method_call,
+    brillo::dbus_utils::ResponseSender sender) {
+  return base::BindOnce(
+      [](std::unique_ptr<dbus::MethodCall>,
+         brillo::dbus_utils::ResponseSender sender,
+         std::unique_ptr<dbus::Response> response) {
+        std::move(sender).Run(std::move(response));
+      },
+      std::move(method_call), std::move(sender));
+}
+
+// Receives the response of a blocking method call. Wait() runs a RunLoop
+// until the response is sent if the method did not reply synchronously.
+class LoopbackResponseWaiter {
+ public:
+  brillo::dbus_utils::ResponseSender GetSender() {
+    return base::BindOnce(&LoopbackResponseWaiter::OnResponse,
+                          base::Unretained(this));
+  }
+
+  std::unique_ptr<dbus::Response> Wait() {
+    if (!replied_)
+      run_loop_.Run();
+    return std::move(response_);
+  }
+
+ private:
+  void OnResponse(std::unique_ptr<dbus::Response> response) {
+    response_ = std::move(response);
+    replied_ = true;
+    run_loop_.Quit();
+  }
+
+  base::RunLoop run_loop_;
+  bool replied_ = false;
+  std::unique_ptr<dbus::Response> response_;
+};
+
+}  // namespace chromeos_dbus_bindings
+#endif  // CHROMEOS_DBUS_BINDINGS_DBUS_LOOPBACK_
+{{range $introspect := .Introspects}}{{range $itf := .Interfaces}}
+{{- $itfName := makeProxyInterfaceName .Name}}
+{{- $implName := makeInterfaceName .Name}}
+{{- $settings := interfaceSettings .Name}}
+{{- $adaptorName := makeAdaptorName .Name}}
+{{- $loopbackName := makeProxyName .Name | printf "%sLoopback"}}
+{{range extractNameSpaces .Name -}}
+namespace {{.}} {
+{{end}}
+// Implements {{$itfName}} by calling the methods of |impl| and
+// accessing the properties of |adaptor| in-process, without a bus. The
+// arguments are marshaled through dbus::Message as they are on the bus, so
+// that the daemon logic can be tested against the client code.
+// The signals sent by the adaptor are not delivered, call the Emit*Signal()
+// methods instead. Likewise, only the property changes made through the
+// loopback are notified.
+class {{$loopbackName}} : public {{$itfName}} {
+ public:
+  {{$loopbackName}}({{$implName}}* {{if not .Methods}}/* {{end}}impl{{if not .Methods}} */{{end}}, {{$adaptorName}}* {{if not .Properties}}/* {{end}}adaptor{{if not .Properties}} */{{end}})
+{{- if or .Methods .Properties}}
+      : {{if .Methods}}impl_(impl){{end}}{{if and .Methods .Properties}}, {{end}}{{if .Properties}}adaptor_(adaptor){{end}} {}
+{{- else}} {}
+{{- end}}
+  {{$loopbackName}}(const {{$loopbackName}}&) = delete;
+  {{$loopbackName}}& operator=(const {{$loopbackName}}&) = delete;
+{{- range .Methods}}
+{{- if hasAsyncOverloads .}}
+
+  {{if not $settings.DisableBlockingCalls}}using {{$itfName}}::{{.Name}};
+  {{end}}using {{$itfName}}::{{.Name}}Async;
+{{- else if and (not $settings.DisableBlockingCalls) (hasInterfaceOverloads . $.ExpectedResults)}}
+
+  using {{$itfName}}::{{.Name}};
+{{- end}}
+{{- end}}
+{{- range .Methods}}
+{{- $inParams := makeMethodParams $.NamingStyle 0 .InputArguments}}
+{{- $outParams := makeMethodParams $.NamingStyle (len .InputArguments) .OutputArguments}}
+{{- if not $settings.DisableBlockingCalls}}
+
+  bool {{.Name}}(
+{{- range $inParams}}
+      {{.Type}} {{.Name}},
+{{- end}}
+{{- range $outParams}}
+      {{.Type}} {{.Name}},
+{{- end}}
+      brillo::ErrorPtr* error,
+      int /*timeout_ms*/) override {
+    chromeos_dbus_bindings::LoopbackResponseWaiter waiter;
+    Call{{.Name}}(
+        chromeos_dbus_bindings::MakeLoopbackMethodCall(
+            kInterfaceName, k{{makeConstantName .Name}}Method{{range $inParams}}, {{.Name}}{{end}}),
+        waiter.GetSender());
+    std::unique_ptr<dbus::Response> response = waiter.Wait();
+    return brillo::dbus_utils::ExtractMethodCallResults(
+        response.get(), error{{range $outParams}}, {{.Name}}{{end}});
+  }
+{{- end}}
+
+  void {{.Name}}Async(
+{{- range $inParams}}
+      {{.Type}} {{.Name}},
+{{- end}}
+      {{makeMethodCallbackType $.NamingStyle $.MoveProtobufResponses .OutputArguments}} success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int /*timeout_ms*/) override {
+    Call{{.Name}}(
+        chromeos_dbus_bindings::MakeLoopbackMethodCall(
+            kInterfaceName, k{{makeConstantName .Name}}Method{{range $inParams}}, {{.Name}}{{end}}),
+        base::BindOnce(&{{$loopbackName}}::On{{.Name}}Response,
+                       std::move(success_callback), std::move(error_callback)));
+  }
+{{- end}}
+{{- range .Signals}}
+
+  void Register{{.Name}}SignalHandler(
+      {{- makeSignalCallbackType . | nindent 6}} signal_callback,
+      dbus::ObjectProxy::OnConnectedCallback on_connected_callback) override {
+    signal_{{.Name}}_callbacks_.push_back(signal_callback);
+    std::move(on_connected_callback).Run(kInterfaceName, k{{makeConstantName .Name}}Signal, true);
+  }
+
+  // Delivers the {{.Name}} signal to the registered handlers.
+  void Emit{{.Name}}Signal(
+{{- range $i, $p := makeLoopbackSignalParams .}}{{if $i}},{{end}}
+      {{$p.Type}} {{$p.Name}}
+{{- end}}) {
+{{- if or .Args (isRawSignal .)}}
+    dbus::Signal signal(kInterfaceName, k{{makeConstantName .Name}}Signal);
+{{- end}}
+{{- with makeLoopbackSignalParams .}}
+    dbus::MessageWriter writer(&signal);
+    brillo::dbus_utils::DBusParamWriter::Append(
+        &writer{{range .}}, {{.Name}}{{end}});
+{{- end}}
+{{- if isRawSignal .}}
+    for (const auto& callback : signal_{{.Name}}_callbacks_)
+      callback.Run(&signal);
+{{- else}}
+{{- with makeLoopbackSignalArgs .}}
+    dbus::MessageReader reader(&signal);
+{{- range .}}
+    {{.Type}} {{.Name}};
+{{- end}}
+    if (!brillo::dbus_utils::ExtractMessageParameters(
+            &reader, nullptr{{range .}}, &{{.Name}}{{end}}))
+      return;
+{{- end}}
+    for (const auto& callback : signal_{{.Name}}_callbacks_)
+      callback.Run({{range $i, $a := makeLoopbackSignalArgs .}}{{if $i}}, {{end}}{{.Name}}{{end}});
+{{- end}}
+  }
+{{- end}}
+{{- if .Properties}}{{"\n"}}{{end}}
+{{- range .Properties}}
+{{- $accessors := makePropertyAccessors $.NamingStyle . -}}
+{{- $type := makeProxyInArgTypeProxy . }}
+{{- $variableName := makePropertyVariableName . | makeVariableName}}
+{{- if hasPropertySet $itf}}
+{{- if .Readable}}
+  {{$type}} {{$accessors.Getter}}() const override {
+    {{$variableName}}_ = adaptor_->Get{{.Name}}();
+    return {{$variableName}}_;
+  }
+  bool {{$accessors.Validator}}() const override { return true; }
+{{- end}}
+{{- if .Writable}}
+  void {{$accessors.Setter}}({{$type}} value,
+       {{repeat " " (len $accessors.Setter)}} base::OnceCallback<void(bool)> callback) override {
+    brillo::ErrorPtr error;
+    if (!adaptor_->Validate{{.Name}}(&error, value)) {
+      std::move(callback).Run(false);
+      return;
+    }
+    adaptor_->Set{{.Name}}(value);
+    std::move(callback).Run(true);
+{{- if hasPropertyChangedCallback .}}
+    if (!{{$variableName}}_changed_callback_.is_null())
+      {{$variableName}}_changed_callback_.Run(value);
+{{- end}}
+    if (!property_changed_callback_.is_null())
+      property_changed_callback_.Run(this, {{.Name}}Name());
+  }
+{{- end}}
+{{- end}}
+{{- if hasPropertyChangedCallback .}}
+  void {{$accessors.ChangedCallbackSetter}}(
+      const base::RepeatingCallback<void({{$type}})>& callback) override {
+    {{$variableName}}_changed_callback_ = callback;
+  }
+{{- end}}
+{{- end}}
+
+  const dbus::ObjectPath& GetObjectPath() const override {
+    return object_path_;
+  }
+  dbus::ObjectProxy* GetObjectProxy() const override { return nullptr; }
+{{- if hasPropertySet .}}
+
+  void {{if $settings.ObjectManagerName}}SetPropertyChangedCallback{{else}}InitializeProperties{{end}}(
+      const base::RepeatingCallback<void({{$itfName}}*, const std::string&)>& callback) override {
+    property_changed_callback_ = callback;
+  }
+{{- else if hasLightweightProperties .}}
+
+  void ConnectPropertiesChangedSignal(
+      dbus::ObjectProxy::OnConnectedCallback on_connected_callback) override {
+    std::move(on_connected_callback).Run(kInterfaceName, "PropertiesChanged", true);
+  }
+{{- end}}
+
+ private:
+{{- range makeLoopbackMethods .}}
+  // Serves the call of {{.Name}} by |impl_| as the adaptor does.
+  void Call{{.Name}}(
+      std::unique_ptr<dbus::MethodCall> method_call,
+      brillo::dbus_utils::ResponseSender sender) {
+{{- if .Raw}}
+    dbus::MethodCall* method_call_ptr = method_call.get();
+    brillo::dbus_utils::ResponseSender response_sender =
+        chromeos_dbus_bindings::BindLoopbackMethodCall(std::move(method_call),
+                                                        std::move(sender));
+    impl_->{{.Name}}(method_call_ptr, std::move(response_sender));
+{{- else}}
+{{- if .Ins}}
+    dbus::MessageReader reader(method_call.get());
+{{- range .Ins}}
+    {{.Type}} {{.Name}};
+{{- end}}
+{{- end}}
+{{- if or .Ins .Error}}
+    brillo::ErrorPtr error;
+{{- end}}
+{{- if .Ins}}
+    if (!brillo::dbus_utils::ExtractMessageParameters(
+            &reader, &error{{range .Ins}}, &{{.Name}}{{end}})) {
+      std::move(sender).Run(
+          brillo::dbus_utils::GetDBusError(method_call.get(), error.get()));
+      return;
+    }
+{{- end}}
+{{- if .Async}}
+    dbus::MethodCall* method_call_ptr = method_call.get();
+    auto response = std::make_unique<
+        brillo::dbus_utils::DBusMethodResponse<{{.ResponseType}}>>(
+        method_call_ptr, chromeos_dbus_bindings::BindLoopbackMethodCall(
+                             std::move(method_call), std::move(sender)));
+    impl_->{{.Name}}({{join .Args ", "}});
+{{- else}}
+{{- if .Returned}}
+    {{(index .Outs 0).Type}} {{(index .Outs 0).Name}} = impl_->{{.Name}}({{join .Args ", "}});
+{{- else}}
+{{- range .Outs}}
+    {{.Type}} {{.Name}}{};
+{{- end}}
+{{- if .Error}}
+    if (!impl_->{{.Name}}({{join .Args ", "}})) {
+      std::move(sender).Run(
+          brillo::dbus_utils::GetDBusError(method_call.get(), error.get()));
+      return;
+    }
+{{- else}}
+    impl_->{{.Name}}({{join .Args ", "}});
+{{- end}}
+{{- end}}
+    chromeos_dbus_bindings::SendLoopbackResponse(
+        method_call.get(), std::move(sender){{range .Outs}}, {{.Name}}{{end}});
+{{- end}}
+{{- end}}
+  }
+
+  static void On{{.Name}}Response(
+      {{makeMethodCallbackType $.NamingStyle $.MoveProtobufResponses .Method.OutputArguments}} success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      std::unique_ptr<dbus::Response> response) {
+    brillo::ErrorPtr error;
+{{- range .Outs}}
+    {{.Type}} {{.Name}}{};
+{{- end}}
+    if (!brillo::dbus_utils::ExtractMethodCallResults(
+            response.get(), &error{{range .Outs}}, &{{.Name}}{{end}})) {
+      std::move(error_callback).Run(error.get());
+      return;
+    }
+    std::move(success_callback).Run({{range $i, $o := .Outs}}{{if $i}}, {{end}}std::move({{.Name}}){{end}});
+  }
+{{end}}
+{{- if .Methods}}
+  {{$implName}}* impl_;
+{{- end}}
+{{- if .Properties}}
+  {{$adaptorName}}* adaptor_;
+{{- end}}
+  dbus::ObjectPath object_path_{"{{or $introspect.Name "/"}}"};
+{{- range .Signals}}
+  std::vector<{{.Name}}SignalCallback> signal_{{.Name}}_callbacks_;
+{{- end}}
+{{- range .Properties}}
+{{- $variableName := makePropertyVariableName . | makeVariableName}}
+{{- if and (hasPropertySet $itf) .Readable}}
+  mutable {{makePropertyBaseTypeExtract .}} {{$variableName}}_;
+{{- end}}
+{{- if hasPropertyChangedCallback .}}
+  base::RepeatingCallback<void({{makeProxyInArgTypeProxy .}})> {{$variableName}}_changed_callback_;
+{{- end}}
+{{- end}}
+{{- if hasPropertySet .}}
+  base::RepeatingCallback<void({{$itfName}}*, const std::string&)>
+      property_changed_callback_;
+{{- end}}
+};
+
+{{range extractNameSpaces .Name | reverse -}}
+}  // namespace {{.}}
+{{end}}
+{{- end}}{{end}}
+#endif  // {{.HeaderGuard}}
+`
+
+// loopbackTemplates is parsed once, and cloned by every GenerateLoopback call.
+var loopbackTemplates = mustParseTemplates("loopback", makeLoopbackFuncMap(),
+	loopbackTemplateText)
+
+// makeLoopbackFuncMap returns funcMap extended with the functions specific to
+// the loopback template.
+func makeLoopbackFuncMap() template.FuncMap {
+	loopbackFuncMap := make(template.FuncMap)
+	for k, v := range funcMap {
+		loopbackFuncMap[k] = v
+	}
+	loopbackFuncMap["join"] = strings.Join
+	loopbackFuncMap["makeAdaptorName"] = genutil.MakeAdaptorName
+	loopbackFuncMap["makeInterfaceName"] = genutil.MakeInterfaceName
+	loopbackFuncMap["makeLoopbackMethods"] = makeLoopbackMethods
+	loopbackFuncMap["makeLoopbackSignalArgs"] = makeLoopbackSignalArgs
+	loopbackFuncMap["makeLoopbackSignalParams"] = makeLoopbackSignalParams
+	return loopbackFuncMap
+}
+
+// loopbackMethod is a method of an interface served by the loopback.
+type loopbackMethod struct {
+	Method introspect.Method
+	Name   string
+	// Ins are the input arguments read from the method call.
+	Ins []param
+	// Outs are the output arguments written into the response.
+	Outs []param
+	// Returned is set when the only output argument is returned by the
+	// method.
+	Returned bool
+	// Error is set when the method takes a brillo::ErrorPtr.
+	Error bool
+	// Async is set for the asynchronous methods, which take a
+	// DBusMethodResponse of ResponseType.
+	Async        bool
+	ResponseType string
+	// Raw is set for the raw methods, which take the method call.
+	Raw bool
+	// Args are the arguments of the call of the method of the interface
+	// implemented by the service.
+	Args []string
+}
+
+// makeLoopbackMethods returns the methods of itf served by the loopback.
+// They are called with the same parameters as by the adaptor.
+func makeLoopbackMethods(itf introspect.Interface) ([]loopbackMethod, error) {
+	var ret []loopbackMethod
+	for _, m := range itf.Methods {
+		lm := loopbackMethod{Method: m, Name: m.Name}
+		numIn := len(m.InputArguments())
+		for i, a := range m.InputArguments() {
+			t, err := a.BaseType()
+			if err != nil {
+				return nil, err
+			}
+			lm.Ins = append(lm.Ins, param{t, genutil.ArgName("in", a.Name, genutil.MethodArgIndex(false, i, numIn))})
+		}
+		for i, a := range m.OutputArguments() {
+			t, err := a.BaseType()
+			if err != nil {
+				return nil, err
+			}
+			lm.Outs = append(lm.Outs, param{t, genutil.ArgName("out", a.Name, genutil.MethodArgIndex(true, i, numIn))})
+		}
+
+		outs := lm.Outs
+		switch m.Kind() {
+		case introspect.MethodKindSimple:
+			if len(outs) == 1 {
+				lm.Returned = true
+				outs = nil
+			}
+		case introspect.MethodKindNormal:
+			lm.Error = true
+			lm.Args = append(lm.Args, "&error")
+			if m.IncludeDBusMessage() {
+				lm.Args = append(lm.Args, "method_call.get()")
+			}
+		case introspect.MethodKindAsync:
+			var types []string
+			for _, o := range outs {
+				types = append(types, o.Type)
+			}
+			lm.Async = true
+			lm.ResponseType = strings.Join(types, ", ")
+			lm.Args = append(lm.Args, "std::move(response)")
+			if m.IncludeDBusMessage() {
+				lm.Args = append(lm.Args, "method_call_ptr")
+			}
+			outs = nil
+		case introspect.MethodKindRaw:
+			lm.Raw = true
+			ret = append(ret, lm)
+			continue
+		}
+		for _, in := range lm.Ins {
+			lm.Args = append(lm.Args, in.Name)
+		}
+		for _, out := range outs {
+			lm.Args = append(lm.Args, "&"+out.Name)
+		}
+		ret = append(ret, lm)
+	}
+	return ret, nil
+}
+
+// makeLoopbackSignalParams returns the parameters of the Emit*Signal()
+// method of s, which are those of the Send*Signal() method of the adaptor.
+func makeLoopbackSignalParams(s introspect.Signal) ([]param, error) {
+	var ret []param
+	for i, a := range s.Args {
+		t, err := a.InArgType()
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, param{t, genutil.ArgName("in", a.Name, i+1)})
+	}
+	return ret, nil
+}
+
+// makeLoopbackSignalArgs returns the local variables holding the arguments
+// of s read from the signal, which are passed to the handlers.
+func makeLoopbackSignalArgs(s introspect.Signal) ([]param, error) {
+	var ret []param
+	for i, a := range s.Args {
+		t, err := a.BaseType()
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, param{t, genutil.ArgName("arg", a.Name, i+1)})
+	}
+	return ret, nil
+}
+
+// checkLoopbackMembers returns an error if a member of the proxies in
+// introspects is skipped for the adaptor, so that the loopback cannot serve
+// it.
+func checkLoopbackMembers(introspects []introspect.Introspection) error {
+	for _, i := range introspects {
+		for _, itf := range i.Interfaces {
+			for _, m := range itf.Methods {
+				if m.Skipped(introspect.SkipTargetAdaptor) {
+					return fmt.Errorf("method %s.%s is skipped for the adaptor", itf.Name, m.Name)
+				}
+			}
+			for _, s := range itf.Signals {
+				if s.Skipped(introspect.SkipTargetAdaptor) {
+					return fmt.Errorf("signal %s.%s is skipped for the adaptor", itf.Name, s.Name)
+				}
+			}
+			for _, p := range itf.Properties {
+				if p.Skipped(introspect.SkipTargetAdaptor) {
+					return fmt.Errorf("property %s.%s is skipped for the adaptor", itf.Name, p.Name)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// GenerateLoopback outputs the header file containing the loopbacks, which
+// implement the proxy interfaces by the adaptors in-process, into f.
+// outputFilePath is used to make a unique header guard, and adaptorFilePath
+// and proxyFilePath are the paths of the headers to be included.
+func GenerateLoopback(introspects []introspect.Introspection, f io.Writer, outputFilePath, adaptorFilePath, proxyFilePath string, config serviceconfig.Config) error {
+	f = genutil.NewAPILevelWriter(f, config.TargetAPILevel)
+	if adaptorFilePath == "" {
+		return errors.New("adaptor file path is not specified")
+	}
+	if proxyFilePath == "" {
+		return errors.New("proxy file path is not specified")
+	}
+	if err := checkLoopbackMembers(introspects); err != nil {
+		return err
+	}
+	tmpl, err := cloneTemplates(loopbackTemplates, introspects, config)
+	if err != nil {
+		return err
+	}
+
+	return tmpl.Execute(f, struct {
+		Introspects           []introspect.Introspection
+		HeaderGuard           string
+		AdaptorFilePath       string
+		ProxyFilePath         string
+		NamingStyle           serviceconfig.NamingStyle
+		MoveProtobufResponses bool
+		ExpectedResults       bool
+	}{
+		Introspects:           introspects,
+		HeaderGuard:           genutil.GenerateHeaderGuard(outputFilePath),
+		AdaptorFilePath:       adaptorFilePath,
+		ProxyFilePath:         proxyFilePath,
+		NamingStyle:           config.NamingStyle,
+		MoveProtobufResponses: config.MoveProtobufResponses,
+		ExpectedResults:       config.ExpectedResults,
+	})
+}
@@ -0,0 +1,547 @@
+// Copyright 2022 The ChromiumOS Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package proxy
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"go.chromium.org/chromiumos/dbusbindings/introspect"
+	"go.chromium.org/chromiumos/dbusbindings/serviceconfig"
+)
+
+func TestGenerateLoopback(t *testing.T) {
+	introspections := []introspect.Introspection{{
+		Name: "/org/chromium/Test",
+		Interfaces: []introspect.Interface{{
+			Name: "org.chromium.Test",
+			Methods: []introspect.Method{
+				{
+					Name: "Scan",
+					Args: []introspect.MethodArg{
+						{Name: "name", Type: "s"},
+						{Name: "count", Type: "i", Direction: "out"},
+						{Name: "results", Type: "as", Direction: "out"},
+					},
+				}, {
+					Name: "GetCount",
+					Args: []introspect.MethodArg{
+						{Name: "count", Type: "u", Direction: "out"},
+					},
+					Annotations: []introspect.Annotation{
+						{Name: "org.chromium.DBus.Method.Kind", Value: "simple"},
+					},
+				}, {
+					Name: "Connect",
+					Args: []introspect.MethodArg{
+						{Name: "address", Type: "s"},
+						{Name: "id", Type: "x", Direction: "out"},
+					},
+					Annotations: []introspect.Annotation{
+						{Name: "org.chromium.DBus.Method.Kind", Value: "async"},
+					},
+				}, {
+					Name: "Dump",
+					Args: []introspect.MethodArg{
+						{Name: "level", Type: "i"},
+					},
+					Annotations: []introspect.Annotation{
+						{Name: "org.chromium.DBus.Method.Kind", Value: "raw"},
+					},
+				},
+			},
+			Signals: []introspect.Signal{
+				{Name: "Changed", Args: []introspect.SignalArg{{Name: "count", Type: "i"}}},
+				{Name: "Reset"},
+			},
+			Properties: []introspect.Property{
+				{Name: "Count", Type: "i", Access: "read"},
+				{Name: "Label", Type: "s", Access: "readwrite"},
+			},
+		}},
+	}}
+
+	out := new(bytes.Buffer)
+	if err := GenerateLoopback(introspections, out, "/tmp/loopback.h", "adaptor.h", "proxy.h", serviceconfig.Config{}); err != nil {
+		t.Fatalf("GenerateLoopback got error, want nil: %v", err)
+	}
+
+	const want = `// Automatic generation of D-Bus in-process loopbacks for:
+//  - org.chromium.Test
+#ifndef ____CHROMEOS_DBUS_BINDING___TMP_LOOPBACK_H
+#define ____CHROMEOS_DBUS_BINDING___TMP_LOOPBACK_H
+#include <memory>
+#include <string>
+#include <utility>
+#include <vector>
+
+#include <base/functional/bind.h>
+#include <base/functional/callback.h>
+#include <base/run_loop.h>
+#include <brillo/dbus/dbus_method_invoker.h>
+#include <brillo/dbus/dbus_method_response.h>
+#include <brillo/dbus/dbus_param_writer.h>
+#include <brillo/dbus/utils.h>
+#include <brillo/errors/error.h>
+#include <dbus/message.h>
+#include <dbus/object_path.h>
+
+#include "adaptor.h"
+#include "proxy.h"
+
+#ifndef CHROMEOS_DBUS_BINDINGS_DBUS_LOOPBACK_
+#define CHROMEOS_DBUS_BINDINGS_DBUS_LOOPBACK_
+namespace chromeos_dbus_bindings {
+
+// Returns a method call of the method of the interface carrying |args|,
+// which can be replied to.
+template <typename... Args>
+std::unique_ptr<dbus::MethodCall> MakeLoopbackMethodCall(
+    const std::string& interface_name,
+    const std::string& method_name,
+    const Args&... args) {
+  auto method_call =
+      std::make_unique<dbus::MethodCall>(interface_name, method_name);
+  method_call->SetSerial(1);
+  dbus::MessageWriter writer(method_call.get());
+  brillo::dbus_utils::DBusParamWriter::Append(&writer, args...);
+  return method_call;
+}
+
+// Replies to |method_call| with the response carrying |args|.
+template <typename... Args>
+void SendLoopbackResponse(dbus::MethodCall* method_call,
+                          brillo::dbus_utils::ResponseSender sender,
+                          const Args&... args) {
+  std::unique_ptr<dbus::Response> response =
+      dbus::Response::FromMethodCall(method_call);
+  dbus::MessageWriter writer(response.get());
+  brillo::dbus_utils::DBusParamWriter::Append(&writer, args...);
+  std::move(sender).Run(std::move(response));
+}
+
+// Returns |sender| owning |method_call| until the response is sent, as the
+// asynchronous and raw methods may reply after returning.
+inline brillo::dbus_utils::ResponseSender BindLoopbackMethodCall(
+    std::unique_ptr<dbus::MethodCall> method_call,
+    brillo::dbus_utils::ResponseSender sender) {
+  return base::BindOnce(
+      [](std::unique_ptr<dbus::MethodCall>,
+         brillo::dbus_utils::ResponseSender sender,
+         std::unique_ptr<dbus::Response> response) {
+        std::move(sender).Run(std::move(response));
+      },
+      std::move(method_call), std::move(sender));
+}
+
+// Receives the response of a blocking method call. Wait() runs a RunLoop
+// until the response is sent if the method did not reply synchronously.
+class LoopbackResponseWaiter {
+ public:
+  brillo::dbus_utils::ResponseSender GetSender() {
+    return base::BindOnce(&LoopbackResponseWaiter::OnResponse,
+                          base::Unretained(this));
+  }
+
+  std::unique_ptr<dbus::Response> Wait() {
+    if (!replied_)
+      run_loop_.Run();
+    return std::move(response_);
+  }
+
+ private:
+  void OnResponse(std::unique_ptr<dbus::Response> response) {
+    response_ = std::move(response);
+    replied_ = true;
+    run_loop_.Quit();
+  }
+
+  base::RunLoop run_loop_;
+  bool replied_ = false;
+  std::unique_ptr<dbus::Response> response_;
+};
+
+}  // namespace chromeos_dbus_bindings
+#endif  // CHROMEOS_DBUS_BINDINGS_DBUS_LOOPBACK_
+
+namespace org {
+namespace chromium {
+
+// Implements TestProxyInterface by calling the methods of |impl| and
+// accessing the properties of |adaptor| in-process, without a bus. The
+// arguments are marshaled through dbus::Message as they are on the bus, so
+// that the daemon logic can be tested against the client code.
+// The signals sent by the adaptor are not delivered, call the Emit*Signal()
+// methods instead. Likewise, only the property changes made through the
+// loopback are notified.
+class TestProxyLoopback : public TestProxyInterface {
+ public:
+  TestProxyLoopback(TestInterface* impl, TestAdaptor* adaptor)
+      : impl_(impl), adaptor_(adaptor) {}
+  TestProxyLoopback(const TestProxyLoopback&) = delete;
+  TestProxyLoopback& operator=(const TestProxyLoopback&) = delete;
+
+  bool Scan(
+      const std::string& in_name,
+      int32_t* out_count,
+      std::vector<std::string>* out_results,
+      brillo::ErrorPtr* error,
+      int /*timeout_ms*/) override {
+    chromeos_dbus_bindings::LoopbackResponseWaiter waiter;
+    CallScan(
+        chromeos_dbus_bindings::MakeLoopbackMethodCall(
+            kInterfaceName, kScanMethod, in_name),
+        waiter.GetSender());
+    std::unique_ptr<dbus::Response> response = waiter.Wait();
+    return brillo::dbus_utils::ExtractMethodCallResults(
+        response.get(), error, out_count, out_results);
+  }
+
+  void ScanAsync(
+      const std::string& in_name,
+      base::OnceCallback<void(int32_t /*count*/, const std::vector<std::string>& /*results*/)> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int /*timeout_ms*/) override {
+    CallScan(
+        chromeos_dbus_bindings::MakeLoopbackMethodCall(
+            kInterfaceName, kScanMethod, in_name),
+        base::BindOnce(&TestProxyLoopback::OnScanResponse,
+                       std::move(success_callback), std::move(error_callback)));
+  }
+
+  bool GetCount(
+      uint32_t* out_count,
+      brillo::ErrorPtr* error,
+      int /*timeout_ms*/) override {
+    chromeos_dbus_bindings::LoopbackResponseWaiter waiter;
+    CallGetCount(
+        chromeos_dbus_bindings::MakeLoopbackMethodCall(
+            kInterfaceName, kGetCountMethod),
+        waiter.GetSender());
+    std::unique_ptr<dbus::Response> response = waiter.Wait();
+    return brillo::dbus_utils::ExtractMethodCallResults(
+        response.get(), error, out_count);
+  }
+
+  void GetCountAsync(
+      base::OnceCallback<void(uint32_t /*count*/)> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int /*timeout_ms*/) override {
+    CallGetCount(
+        chromeos_dbus_bindings::MakeLoopbackMethodCall(
+            kInterfaceName, kGetCountMethod),
+        base::BindOnce(&TestProxyLoopback::OnGetCountResponse,
+                       std::move(success_callback), std::move(error_callback)));
+  }
+
+  bool Connect(
+      const std::string& in_address,
+      int64_t* out_id,
+      brillo::ErrorPtr* error,
+      int /*timeout_ms*/) override {
+    chromeos_dbus_bindings::LoopbackResponseWaiter waiter;
+    CallConnect(
+        chromeos_dbus_bindings::MakeLoopbackMethodCall(
+            kInterfaceName, kConnectMethod, in_address),
+        waiter.GetSender());
+    std::unique_ptr<dbus::Response> response = waiter.Wait();
+    return brillo::dbus_utils::ExtractMethodCallResults(
+        response.get(), error, out_id);
+  }
+
+  void ConnectAsync(
+      const std::string& in_address,
+      base::OnceCallback<void(int64_t /*id*/)> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int /*timeout_ms*/) override {
+    CallConnect(
+        chromeos_dbus_bindings::MakeLoopbackMethodCall(
+            kInterfaceName, kConnectMethod, in_address),
+        base::BindOnce(&TestProxyLoopback::OnConnectResponse,
+                       std::move(success_callback), std::move(error_callback)));
+  }
+
+  bool Dump(
+      int32_t in_level,
+      brillo::ErrorPtr* error,
+      int /*timeout_ms*/) override {
+    chromeos_dbus_bindings::LoopbackResponseWaiter waiter;
+    CallDump(
+        chromeos_dbus_bindings::MakeLoopbackMethodCall(
+            kInterfaceName, kDumpMethod, in_level),
+        waiter.GetSender());
+    std::unique_ptr<dbus::Response> response = waiter.Wait();
+    return brillo::dbus_utils::ExtractMethodCallResults(
+        response.get(), error);
+  }
+
+  void DumpAsync(
+      int32_t in_level,
+      base::OnceCallback<void()> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int /*timeout_ms*/) override {
+    CallDump(
+        chromeos_dbus_bindings::MakeLoopbackMethodCall(
+            kInterfaceName, kDumpMethod, in_level),
+        base::BindOnce(&TestProxyLoopback::OnDumpResponse,
+                       std::move(success_callback), std::move(error_callback)));
+  }
+
+  void RegisterChangedSignalHandler(
+      const base::RepeatingCallback<void(int32_t)>& signal_callback,
+      dbus::ObjectProxy::OnConnectedCallback on_connected_callback) override {
+    signal_Changed_callbacks_.push_back(signal_callback);
+    std::move(on_connected_callback).Run(kInterfaceName, kChangedSignal, true);
+  }
+
+  // Delivers the Changed signal to the registered handlers.
+  void EmitChangedSignal(
+      int32_t in_count) {
+    dbus::Signal signal(kInterfaceName, kChangedSignal);
+    dbus::MessageWriter writer(&signal);
+    brillo::dbus_utils::DBusParamWriter::Append(
+        &writer, in_count);
+    dbus::MessageReader reader(&signal);
+    int32_t arg_count;
+    if (!brillo::dbus_utils::ExtractMessageParameters(
+            &reader, nullptr, &arg_count))
+      return;
+    for (const auto& callback : signal_Changed_callbacks_)
+      callback.Run(arg_count);
+  }
+
+  void RegisterResetSignalHandler(
+      base::RepeatingClosure signal_callback,
+      dbus::ObjectProxy::OnConnectedCallback on_connected_callback) override {
+    signal_Reset_callbacks_.push_back(signal_callback);
+    std::move(on_connected_callback).Run(kInterfaceName, kResetSignal, true);
+  }
+
+  // Delivers the Reset signal to the registered handlers.
+  void EmitResetSignal() {
+    for (const auto& callback : signal_Reset_callbacks_)
+      callback.Run();
+  }
+
+  int32_t count() const override {
+    count_ = adaptor_->GetCount();
+    return count_;
+  }
+  bool is_count_valid() const override { return true; }
+  void SetCountChangedCallback(
+      const base::RepeatingCallback<void(int32_t)>& callback) override {
+    count_changed_callback_ = callback;
+  }
+  const std::string& label() const override {
+    label_ = adaptor_->GetLabel();
+    return label_;
+  }
+  bool is_label_valid() const override { return true; }
+  void set_label(const std::string& value,
+                 base::OnceCallback<void(bool)> callback) override {
+    brillo::ErrorPtr error;
+    if (!adaptor_->ValidateLabel(&error, value)) {
+      std::move(callback).Run(false);
+      return;
+    }
+    adaptor_->SetLabel(value);
+    std::move(callback).Run(true);
+    if (!label_changed_callback_.is_null())
+      label_changed_callback_.Run(value);
+    if (!property_changed_callback_.is_null())
+      property_changed_callback_.Run(this, LabelName());
+  }
+  void SetLabelChangedCallback(
+      const base::RepeatingCallback<void(const std::string&)>& callback) override {
+    label_changed_callback_ = callback;
+  }
+
+  const dbus::ObjectPath& GetObjectPath() const override {
+    return object_path_;
+  }
+  dbus::ObjectProxy* GetObjectProxy() const override { return nullptr; }
+
+  void InitializeProperties(
+      const base::RepeatingCallback<void(TestProxyInterface*, const std::string&)>& callback) override {
+    property_changed_callback_ = callback;
+  }
+
+ private:
+  // Serves the call of Scan by |impl_| as the adaptor does.
+  void CallScan(
+      std::unique_ptr<dbus::MethodCall> method_call,
+      brillo::dbus_utils::ResponseSender sender) {
+    dbus::MessageReader reader(method_call.get());
+    std::string in_name;
+    brillo::ErrorPtr error;
+    if (!brillo::dbus_utils::ExtractMessageParameters(
+            &reader, &error, &in_name)) {
+      std::move(sender).Run(
+          brillo::dbus_utils::GetDBusError(method_call.get(), error.get()));
+      return;
+    }
+    int32_t out_count{};
+    std::vector<std::string> out_results{};
+    if (!impl_->Scan(&error, in_name, &out_count, &out_results)) {
+      std::move(sender).Run(
+          brillo::dbus_utils::GetDBusError(method_call.get(), error.get()));
+      return;
+    }
+    chromeos_dbus_bindings::SendLoopbackResponse(
+        method_call.get(), std::move(sender), out_count, out_results);
+  }
+
+  static void OnScanResponse(
+      base::OnceCallback<void(int32_t /*count*/, const std::vector<std::string>& /*results*/)> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      std::unique_ptr<dbus::Response> response) {
+    brillo::ErrorPtr error;
+    int32_t out_count{};
+    std::vector<std::string> out_results{};
+    if (!brillo::dbus_utils::ExtractMethodCallResults(
+            response.get(), &error, &out_count, &out_results)) {
+      std::move(error_callback).Run(error.get());
+      return;
+    }
+    std::move(success_callback).Run(std::move(out_count), std::move(out_results));
+  }
+
+  // Serves the call of GetCount by |impl_| as the adaptor does.
+  void CallGetCount(
+      std::unique_ptr<dbus::MethodCall> method_call,
+      brillo::dbus_utils::ResponseSender sender) {
+    uint32_t out_count = impl_->GetCount();
+    chromeos_dbus_bindings::SendLoopbackResponse(
+        method_call.get(), std::move(sender), out_count);
+  }
+
+  static void OnGetCountResponse(
+      base::OnceCallback<void(uint32_t /*count*/)> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      std::unique_ptr<dbus::Response> response) {
+    brillo::ErrorPtr error;
+    uint32_t out_count{};
+    if (!brillo::dbus_utils::ExtractMethodCallResults(
+            response.get(), &error, &out_count)) {
+      std::move(error_callback).Run(error.get());
+      return;
+    }
+    std::move(success_callback).Run(std::move(out_count));
+  }
+
+  // Serves the call of Connect by |impl_| as the adaptor does.
+  void CallConnect(
+      std::unique_ptr<dbus::MethodCall> method_call,
+      brillo::dbus_utils::ResponseSender sender) {
+    dbus::MessageReader reader(method_call.get());
+    std::string in_address;
+    brillo::ErrorPtr error;
+    if (!brillo::dbus_utils::ExtractMessageParameters(
+            &reader, &error, &in_address)) {
+      std::move(sender).Run(
+          brillo::dbus_utils::GetDBusError(method_call.get(), error.get()));
+      return;
+    }
+    dbus::MethodCall* method_call_ptr = method_call.get();
+    auto response = std::make_unique<
+        brillo::dbus_utils::DBusMethodResponse<int64_t>>(
+        method_call_ptr, chromeos_dbus_bindings::BindLoopbackMethodCall(
+                             std::move(method_call), std::move(sender)));
+    impl_->Connect(std::move(response), in_address);
+  }
+
+  static void OnConnectResponse(
+      base::OnceCallback<void(int64_t /*id*/)> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      std::unique_ptr<dbus::Response> response) {
+    brillo::ErrorPtr error;
+    int64_t out_id{};
+    if (!brillo::dbus_utils::ExtractMethodCallResults(
+            response.get(), &error, &out_id)) {
+      std::move(error_callback).Run(error.get());
+      return;
+    }
+    std::move(success_callback).Run(std::move(out_id));
+  }
+
+  // Serves the call of Dump by |impl_| as the adaptor does.
+  void CallDump(
+      std::unique_ptr<dbus::MethodCall> method_call,
+      brillo::dbus_utils::ResponseSender sender) {
+    dbus::MethodCall* method_call_ptr = method_call.get();
+    brillo::dbus_utils::ResponseSender response_sender =
+        chromeos_dbus_bindings::BindLoopbackMethodCall(std::move(method_call),
+                                                        std::move(sender));
+    impl_->Dump(method_call_ptr, std::move(response_sender));
+  }
+
+  static void OnDumpResponse(
+      base::OnceCallback<void()> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      std::unique_ptr<dbus::Response> response) {
+    brillo::ErrorPtr error;
+    if (!brillo::dbus_utils::ExtractMethodCallResults(
+            response.get(), &error)) {
+      std::move(error_callback).Run(error.get());
+      return;
+    }
+    std::move(success_callback).Run();
+  }
+
+  TestInterface* impl_;
+  TestAdaptor* adaptor_;
+  dbus::ObjectPath object_path_{"/org/chromium/Test"};
+  std::vector<ChangedSignalCallback> signal_Changed_callbacks_;
+  std::vector<ResetSignalCallback> signal_Reset_callbacks_;
+  mutable int32_t count_;
+  base::RepeatingCallback<void(int32_t)> count_changed_callback_;
+  mutable std::string label_;
+  base::RepeatingCallback<void(const std::string&)> label_changed_callback_;
+  base::RepeatingCallback<void(TestProxyInterface*, const std::string&)>
+      property_changed_callback_;
+};
+
+}  // namespace chromium
+}  // namespace org
+
+#endif  // ____CHROMEOS_DBUS_BINDING___TMP_LOOPBACK_H
+`
+	if diff := cmp.Diff(out.String(), want); diff != "" {
+		t.Errorf("GenerateLoopback failed (-got +want):\n%s", diff)
+	}
+}
+
+func TestGenerateLoopbackWithoutFilePaths(t *testing.T) {
+	for _, tc := range []struct {
+		adaptor, proxy string
+	}{
+		{"", "proxy.h"},
+		{"adaptor.h", ""},
+	} {
+		if err := GenerateLoopback(nil, new(bytes.Buffer), "/tmp/loopback.h", tc.adaptor, tc.proxy, serviceconfig.Config{}); err == nil {
+			t.Errorf("GenerateLoopback(%q, %q) succeeded, want error", tc.adaptor, tc.proxy)
+		}
+	}
+}
+
+func TestGenerateLoopbackWithMemberSkippedForAdaptor(t *testing.T) {
+	introspections := []introspect.Introspection{{
+		Interfaces: []introspect.Interface{{
+			Name: "org.chromium.Test",
+			Methods: []introspect.Method{{
+				Name: "Ping",
+				Annotations: []introspect.Annotation{
+					{Name: "org.chromium.DBus.SkipAdaptor", Value: "true"},
+				},
+			}},
+		}},
+	}}
+
+	if err := GenerateLoopback(introspections, new(bytes.Buffer), "/tmp/loopback.h", "adaptor.h", "proxy.h", serviceconfig.Config{}); err == nil {
+		t.Error("GenerateLoopback succeeded, want error")
+	}
+}
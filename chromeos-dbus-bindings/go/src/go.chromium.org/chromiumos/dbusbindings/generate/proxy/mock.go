@@ -22,24 +22,99 @@ const mockTemplateText = `// Automatic generation of D-Bus interface mock proxie
 
 #ifndef {{.HeaderGuard}}
 #define {{.HeaderGuard}}
+{{- if and (not $.ProxyFilePath) .UseCoroutines}}
+#include <coroutine>
+#include <memory>
+{{- end}}
+{{- if and (not $.ProxyFilePath) (hasOptionalArgs .Introspects)}}
+#include <optional>
+{{- end}}
 #include <string>
+{{- if and (not $.ProxyFilePath) .UseCoroutines}}
+#include <tuple>
+{{- end}}
+{{- if and (not $.ProxyFilePath) (or .UseCoroutines (usesProtobuf .Introspects))}}
+#include <type_traits>
+{{- end}}
+{{- if and (not $.ProxyFilePath) .UseCoroutines}}
+#include <utility>
+{{- end}}
+{{- if and (not $.ProxyFilePath) (hasVariantTypes .Introspects)}}
+#include <variant>
+{{- end}}
 #include <vector>
 
+{{if and (not $.ProxyFilePath) (hasFDStream .Introspects) -}}
+#include <base/files/file_util.h>
+{{end -}}
+{{if usesTypeHeader .Introspects "<base/files/scoped_file.h>" -}}
+#include <base/files/scoped_file.h>
+{{end -}}
+{{if and (not $.ProxyFilePath) (or .UseCoroutines (hasSignals .Introspects)) -}}
+#include <base/functional/bind.h>
+{{end -}}
 #include <base/functional/callback_forward.h>
+{{- if and (not $.ProxyFilePath) (hasVariantTypes .Introspects)}}
+#include <base/location.h>
+{{- end}}
 #include <base/logging.h>
+{{- if and (not $.ProxyFilePath) (hasSignals .Introspects)}}
+#include <base/memory/weak_ptr.h>
+{{- end}}
+{{- if and (not $.ProxyFilePath) .ExpectedResults}}
+#include <base/types/expected.h>
+{{- end}}
+{{- if or (hasVariantTypes .Introspects) (usesTypeHeader .Introspects "<brillo/any.h>")}}
 #include <brillo/any.h>
+{{- end}}
 #include <brillo/errors/error.h>
+{{- if and (not $.ProxyFilePath) (or (hasMethodErrors .Introspects) (hasVariantTypes .Introspects))}}
+#include <brillo/errors/error_codes.h>
+{{- end}}
+{{- if usesTypeHeader .Introspects "<brillo/variant_dictionary.h>"}}
 #include <brillo/variant_dictionary.h>
+{{- end}}
 #include <gmock/gmock.h>
+{{- if and (not $.ProxyFilePath) (usesProtobuf .Introspects)}}
+#include <google/protobuf/message_lite.h>
+{{- end}}
 {{- if $.ProxyFilePath}}
 
 #include "{{$.ProxyFilePath}}"
+{{- else}}
+{{- with makeProtobufIncludes .Introspects}}
+{{range .}}
+#include {{.}}
+{{- end}}
+{{- end}}
+{{- with .TypeMappingIncludes}}
+{{range .}}
+#include {{.}}
+{{- end}}
+{{- end}}
+{{- if hasOptionalArgs .Introspects}}
+
+{{template "optional"}}
+{{- end}}
+{{- if usesProtobuf .Introspects}}
+
+{{template "protobufClassCheck"}}
+{{- end}}
+{{- if hasVariantTypes .Introspects}}
+
+{{template "variantTypes"}}
+{{- end}}
+{{- if .UseCoroutines}}
+
+{{template "awaitable"}}
+{{- end}}
 {{- end}}
 {{range $introspect := .Introspects}}{{range $itf := .Interfaces -}}
 {{- $itfName := makeProxyInterfaceName .Name -}}
+{{- $settings := interfaceSettings .Name}}
 
 {{- if (not $.ProxyFilePath)}}
-{{template "proxyInterface" (makeProxyInterfaceArgs . $.ObjectManagerName) }}
+{{template "proxyInterface" (makeProxyInterfaceArgs . $settings.ObjectManagerName $.NamingStyle $.UseCoroutines $.MoveProtobufResponses $.ExpectedResults $settings.DisableBlockingCalls false nil) }}
 {{- end}}
 {{range extractNameSpaces .Name -}}
 namespace {{.}} {
@@ -52,9 +127,20 @@ class {{$mockName}} : public {{$itfName}} {
   {{$mockName}}(const {{$mockName}}&) = delete;
   {{$mockName}}& operator=(const {{$mockName}}&) = delete;
 {{- range .Methods}}
-{{- $inParams := makeMockMethodParams .InputArguments}}
-{{- $outParams := makeMockMethodParams .OutputArguments}}
+{{- if hasAsyncOverloads .}}
+
+  {{if not $settings.DisableBlockingCalls}}using {{$itfName}}::{{.Name}};
+  {{end}}using {{$itfName}}::{{.Name}}Async;
+{{- else if and (not $settings.DisableBlockingCalls) (hasInterfaceOverloads . $.ExpectedResults)}}
 
+  using {{$itfName}}::{{.Name}};
+{{- end}}
+{{- end}}
+{{- range .Methods}}
+{{- $inParams := makeMockMethodParams $.NamingStyle 0 .InputArguments}}
+{{- $outParams := makeMockMethodParams $.NamingStyle (len .InputArguments) .OutputArguments}}
+{{/* blank line separator */}}
+{{- if not $settings.DisableBlockingCalls}}
   MOCK_METHOD(bool,
               {{.Name}},
               ({{- range $inParams}}{{maybeWrap .Type}}{{if .Name}} {{.Name}}{{end}},
@@ -64,11 +150,12 @@ class {{$mockName}} : public {{$itfName}} {
                brillo::ErrorPtr* /*error*/,
                int /*timeout_ms*/),
               (override));
+{{- end}}
   MOCK_METHOD(void,
               {{.Name}}Async,
               ({{- range $inParams}}{{maybeWrap .Type}}{{if .Name}} {{.Name}}{{end}},
                {{end -}}
-               {{- makeMethodCallbackType .OutputArguments | maybeWrap}} /*success_callback*/,
+               {{- makeMethodCallbackType $.NamingStyle $.MoveProtobufResponses .OutputArguments | maybeWrap}} /*success_callback*/,
                base::OnceCallback<void(brillo::Error*)> /*error_callback*/,
                int /*timeout_ms*/),
               (override));
@@ -79,35 +166,46 @@ class {{$mockName}} : public {{$itfName}} {
   {{/* TODO(b/288402584): get rid of DoRegister* function */ -}}
   void Register{{.Name}}SignalHandler(
     {{- /* TODO(crbug.com/983008): fix the indent to meet style guide. */ -}}
-    {{- makeSignalCallbackType .Args | nindent 4}} signal_callback,
+    {{- makeSignalCallbackType . | nindent 4}} signal_callback,
     dbus::ObjectProxy::OnConnectedCallback on_connected_callback) override {
     DoRegister{{.Name}}SignalHandler(signal_callback, &on_connected_callback);
   }
   MOCK_METHOD(void,
               DoRegister{{.Name}}SignalHandler,
-              ({{makeSignalCallbackType .Args | nindent 15 | trimLeft " \n"}} /*signal_callback*/,
+              ({{makeSignalCallbackType . | nindent 15 | trimLeft " \n"}} /*signal_callback*/,
                dbus::ObjectProxy::OnConnectedCallback* /*on_connected_callback*/));
 {{- end}}
 
 {{- range .Properties}}
-{{- $name := makePropertyVariableName . | makeVariableName -}}
+{{- $accessors := makePropertyAccessors $.NamingStyle . -}}
 {{- $type := makeProxyInArgTypeProxy . }}
-
-  MOCK_METHOD({{$type}}, {{$name}}, (), (const, override));
-  MOCK_METHOD(bool, is_{{$name}}_valid, (), (const, override));
-
-{{- if eq .Access "readwrite"}}
+{{- if hasPropertySet $itf}}
+{{/* blank line separator */}}
+{{- if .Readable}}
+  MOCK_METHOD({{$type}}, {{$accessors.Getter}}, (), (const, override));
+  MOCK_METHOD(bool, {{$accessors.Validator}}, (), (const, override));
+{{- end}}
+{{- if .Writable}}
   MOCK_METHOD(void,
-              set_{{$name}},
+              {{$accessors.Setter}},
               ({{maybeWrap $type}}, base::OnceCallback<void(bool)>),
               (override));
 {{- end}}
+{{- else if hasPropertyChangedCallback .}}
+{{/* blank line separator */}}
+{{- end}}
+{{- if hasPropertyChangedCallback .}}
+  MOCK_METHOD(void,
+              {{$accessors.ChangedCallbackSetter}},
+              ((const base::RepeatingCallback<void({{$type}})>&)),
+              (override));
+{{- end}}
 {{- end}}
 
   MOCK_METHOD(const dbus::ObjectPath&, GetObjectPath, (), (const, override));
   MOCK_METHOD(dbus::ObjectProxy*, GetObjectProxy, (), (const, override));
-{{- if .Properties}}
-{{- if $.ObjectManagerName }}
+{{- if hasPropertySet .}}
+{{- if $settings.ObjectManagerName }}
 
   MOCK_METHOD(void,
               SetPropertyChangedCallback,
@@ -122,6 +220,12 @@ class {{$mockName}} : public {{$itfName}} {
                                                    const std::string&)>&)),
               (override));
 {{- end}}
+{{- else if hasLightweightProperties .}}
+
+  MOCK_METHOD(void,
+              ConnectPropertiesChangedSignal,
+              (dbus::ObjectProxy::OnConnectedCallback),
+              (override));
 {{- end}}
 };
 {{range extractNameSpaces .Name | reverse -}}
@@ -132,9 +236,21 @@ class {{$mockName}} : public {{$itfName}} {
 #endif  // {{.HeaderGuard}}
 `
 
-// GenerateMock outputs the header file containing gmock proxy interfaces into f.
-// outputFilePath is used to make a unique header guard.
-func GenerateMock(introspects []introspect.Introspection, f io.Writer, outputFilePath string, proxyFilePath string, config serviceconfig.Config) error {
+// mockTemplates is parsed once, and cloned by every GenerateMock call.
+var mockTemplates = mustParseTemplates("mock", makeMockFuncMap(),
+	mockTemplateText,
+	proxyInterfaceTemplate,
+	awaitableTemplate,
+	variantTypesTemplate,
+	genutil.NamedStructsTemplate,
+	genutil.NamedEnumsTemplate,
+	genutil.NamedFlagsTemplate,
+	genutil.OptionalTemplate,
+	genutil.ProtobufChecksTemplate)
+
+// makeMockFuncMap returns funcMap extended with the functions specific to
+// the mock template.
+func makeMockFuncMap() template.FuncMap {
 	mockFuncMap := make(template.FuncMap)
 	for k, v := range funcMap {
 		mockFuncMap[k] = v
@@ -149,32 +265,38 @@ func GenerateMock(introspects []introspect.Introspection, f io.Writer, outputFil
 		// Wrap with a pair of parens. Also, tweak the indent.
 		return fmt.Sprintf("(%s)", strings.ReplaceAll(typ, "\n", "\n "))
 	}
-	tmpl, err := template.New("mock").Funcs(mockFuncMap).Parse(mockTemplateText)
-	if err != nil {
-		return err
-	}
+	return mockFuncMap
+}
 
-	if _, err := tmpl.Parse(proxyInterfaceTemplate); err != nil {
+// GenerateMock outputs the header file containing gmock proxy interfaces into f.
+// outputFilePath is used to make a unique header guard.
+func GenerateMock(introspects []introspect.Introspection, f io.Writer, outputFilePath string, proxyFilePath string, config serviceconfig.Config) error {
+	f = genutil.NewAPILevelWriter(f, config.TargetAPILevel)
+	tmpl, err := cloneTemplates(mockTemplates, introspects, config)
+	if err != nil {
 		return err
 	}
 
-	var omName string
-	if config.ObjectManager != nil {
-		omName = config.ObjectManager.Name
-	}
-
 	headerGuard := genutil.GenerateHeaderGuard(outputFilePath)
 	return tmpl.Execute(f, struct {
-		Introspects       []introspect.Introspection
-		HeaderGuard       string
-		ProxyFilePath     string
-		ServiceName       string
-		ObjectManagerName string
+		Introspects           []introspect.Introspection
+		HeaderGuard           string
+		ProxyFilePath         string
+		ServiceName           string
+		NamingStyle           serviceconfig.NamingStyle
+		UseCoroutines         bool
+		MoveProtobufResponses bool
+		ExpectedResults       bool
+		TypeMappingIncludes   []string
 	}{
-		Introspects:       introspects,
-		HeaderGuard:       headerGuard,
-		ProxyFilePath:     proxyFilePath,
-		ServiceName:       config.ServiceName,
-		ObjectManagerName: omName,
+		Introspects:           introspects,
+		HeaderGuard:           headerGuard,
+		ProxyFilePath:         proxyFilePath,
+		ServiceName:           config.ServiceName,
+		NamingStyle:           config.NamingStyle,
+		UseCoroutines:         config.UseCoroutines,
+		MoveProtobufResponses: config.MoveProtobufResponses,
+		ExpectedResults:       config.ExpectedResults,
+		TypeMappingIncludes:   genutil.MakeTypeMappingIncludes(introspects, config.TypeMappings),
 	})
 }
@@ -33,10 +33,10 @@ func TestGenerateMockProxies(t *testing.T) {
 						Name:      "request",
 						Type:      "ay",
 						Direction: "in",
-						Annotation: introspect.Annotation{
+						Annotations: []introspect.Annotation{{
 							Name:  "org.chromium.DBus.Argument.ProtobufClass",
 							Value: "PassMeProtosRequest",
-						},
+						}},
 					},
 				},
 				Annotations: []introspect.Annotation{
@@ -55,10 +55,10 @@ func TestGenerateMockProxies(t *testing.T) {
 					{
 						Name: "BSSDetail1",
 						Type: "ay",
-						Annotation: introspect.Annotation{
+						Annotations: []introspect.Annotation{{
 							Name:  "org.chromium.DBus.Argument.ProtobufClass",
 							Value: "YetAnotherProto",
-						},
+						}},
 					}, {
 						Name: "BSSDetail2",
 						Type: "(ih)",
@@ -78,10 +78,10 @@ func TestGenerateMockProxies(t *testing.T) {
 				Type:      "u",
 				Access:    "read",
 				DocString: "\n        property doc\n      ",
-				Annotation: introspect.Annotation{
+				Annotations: []introspect.Annotation{{
 					Name:  "org.chromium.DBus.Argument.VariableName",
 					Value: "bluetooth_class",
-				},
+				}},
 			},
 		},
 		DocString: "\n      interface doc\n    ",
@@ -116,23 +116,70 @@ func TestGenerateMockProxies(t *testing.T) {
 #ifndef ____CHROMEOS_DBUS_BINDING___TMP_MOCK_H
 #define ____CHROMEOS_DBUS_BINDING___TMP_MOCK_H
 #include <string>
+#include <type_traits>
 #include <vector>
 
+#include <base/files/scoped_file.h>
+#include <base/functional/bind.h>
 #include <base/functional/callback_forward.h>
 #include <base/logging.h>
-#include <brillo/any.h>
+#include <base/memory/weak_ptr.h>
 #include <brillo/errors/error.h>
 #include <brillo/variant_dictionary.h>
 #include <gmock/gmock.h>
+#include <google/protobuf/message_lite.h>
+
+#ifndef CHROMEOS_DBUS_BINDINGS_DBUS_PROTOBUF_CLASS_CHECK_
+#define CHROMEOS_DBUS_BINDINGS_DBUS_PROTOBUF_CLASS_CHECK_
+namespace brillo {
+namespace dbus_utils {
+
+// Fails to compile unless T, given by an
+// org.chromium.DBus.Argument.ProtobufClass annotation, is a protobuf message.
+template <typename T>
+constexpr bool CheckProtobufClass() {
+  static_assert(std::is_base_of_v<google::protobuf::MessageLite, T>,
+                "The class given by org.chromium.DBus.Argument.ProtobufClass "
+                "is not a protobuf message; check the annotation and the "
+                "headers in org.chromium.DBus.Interface.ProtobufIncludes");
+  return true;
+}
+
+}  // namespace dbus_utils
+}  // namespace brillo
+#endif  // CHROMEOS_DBUS_BINDINGS_DBUS_PROTOBUF_CLASS_CHECK_
 
 namespace fi {
 namespace w1 {
 namespace wpa_supplicant1 {
 
+static_assert(
+    brillo::dbus_utils::CheckProtobufClass<PassMeProtosRequest>());
+static_assert(
+    brillo::dbus_utils::CheckProtobufClass<YetAnotherProto>());
+
 // Abstract interface proxy for fi::w1::wpa_supplicant1::Interface.
 // interface doc
 class InterfaceProxyInterface {
  public:
+  static constexpr char kInterfaceName[] = "fi.w1.wpa_supplicant1.Interface";
+  static constexpr char kScanMethod[] = "Scan";
+  static constexpr char kScanMethodInSignature[] = "ah";
+  static constexpr char kScanMethodOutSignature[] = "";
+  static constexpr char kPassMeProtosMethod[] = "PassMeProtos";
+  static constexpr char kPassMeProtosMethodInSignature[] = "ay";
+  static constexpr char kPassMeProtosMethodOutSignature[] = "";
+  static constexpr char kBSSRemovedSignal[] = "BSSRemoved";
+  static constexpr char kBSSRemovedSignalSignature[] = "ay(ih)";
+  static constexpr char kCapabilitiesProperty[] = "Capabilities";
+  static constexpr char kCapabilitiesPropertySignature[] = "a{sv}";
+  static constexpr char kClassProperty[] = "Class";
+  static constexpr char kClassPropertySignature[] = "u";
+
+  using BSSRemovedSignalCallback =
+      base::RepeatingCallback<void(const YetAnotherProto& /*BSSDetail1*/,
+                                   const std::tuple<int32_t, base::ScopedFD>& /*BSSDetail2*/)>;
+
   virtual ~InterfaceProxyInterface() = default;
 
   virtual bool Scan(
@@ -164,12 +211,42 @@ class InterfaceProxyInterface {
                                          const std::tuple<int32_t, base::ScopedFD>&)>& signal_callback,
       dbus::ObjectProxy::OnConnectedCallback on_connected_callback) = 0;
 
+  // Registers |method| of |target| as the handler of the BSSRemoved signal.
+  // The signals delivered after |target| is invalidated are dropped.
+  template <typename T>
+  void RegisterBSSRemovedSignalHandlerWeak(
+      base::WeakPtr<T> target,
+      void (T::*method)(const YetAnotherProto&, const std::tuple<int32_t, base::ScopedFD>&),
+      dbus::ObjectProxy::OnConnectedCallback on_connected_callback) {
+    RegisterBSSRemovedSignalHandler(
+        base::BindRepeating(method, std::move(target)),
+        std::move(on_connected_callback));
+  }
+
   static const char* CapabilitiesName() { return "Capabilities"; }
   virtual const brillo::VariantDictionary& capabilities() const = 0;
   virtual bool is_capabilities_valid() const = 0;
+  // Returns in |value| the entry of Capabilities keyed by |key|.
+  // Returns false if the property is not valid, has no such entry, or the
+  // entry holds another type than T.
+  template <typename T>
+  bool GetCapabilitiesEntry(const std::string& key, T* value) const {
+    if (!is_capabilities_valid())
+      return false;
+    const brillo::VariantDictionary& dict = capabilities();
+    auto it = dict.find(key);
+    if (it == dict.end() || !it->second.IsTypeCompatible<T>())
+      return false;
+    *value = it->second.Get<T>();
+    return true;
+  }
+  virtual void SetCapabilitiesChangedCallback(
+      const base::RepeatingCallback<void(const brillo::VariantDictionary&)>& callback) = 0;
   static const char* ClassName() { return "Class"; }
   virtual uint32_t bluetooth_class() const = 0;
   virtual bool is_bluetooth_class_valid() const = 0;
+  virtual void SetBluetoothClassChangedCallback(
+      const base::RepeatingCallback<void(uint32_t)>& callback) = 0;
 
   virtual const dbus::ObjectPath& GetObjectPath() const = 0;
   virtual dbus::ObjectProxy* GetObjectProxy() const = 0;
@@ -235,9 +312,17 @@ class InterfaceProxyMock : public InterfaceProxyInterface {
 
   MOCK_METHOD(const brillo::VariantDictionary&, capabilities, (), (const, override));
   MOCK_METHOD(bool, is_capabilities_valid, (), (const, override));
+  MOCK_METHOD(void,
+              SetCapabilitiesChangedCallback,
+              ((const base::RepeatingCallback<void(const brillo::VariantDictionary&)>&)),
+              (override));
 
   MOCK_METHOD(uint32_t, bluetooth_class, (), (const, override));
   MOCK_METHOD(bool, is_bluetooth_class_valid, (), (const, override));
+  MOCK_METHOD(void,
+              SetBluetoothClassChangedCallback,
+              ((const base::RepeatingCallback<void(uint32_t)>&)),
+              (override));
 
   MOCK_METHOD(const dbus::ObjectPath&, GetObjectPath, (), (const, override));
   MOCK_METHOD(dbus::ObjectProxy*, GetObjectProxy, (), (const, override));
@@ -256,6 +341,8 @@ class InterfaceProxyMock : public InterfaceProxyInterface {
 // Abstract interface proxy for EmptyInterface.
 class EmptyInterfaceProxyInterface {
  public:
+  static constexpr char kInterfaceName[] = "EmptyInterface";
+
   virtual ~EmptyInterfaceProxyInterface() = default;
 
   virtual const dbus::ObjectPath& GetObjectPath() const = 0;
@@ -307,15 +394,15 @@ func TestGenerateMockProxiesEmpty(t *testing.T) {
 
 #include <base/functional/callback_forward.h>
 #include <base/logging.h>
-#include <brillo/any.h>
 #include <brillo/errors/error.h>
-#include <brillo/variant_dictionary.h>
 #include <gmock/gmock.h>
 
 
 // Abstract interface proxy for EmptyInterface.
 class EmptyInterfaceProxyInterface {
  public:
+  static constexpr char kInterfaceName[] = "EmptyInterface";
+
   virtual ~EmptyInterfaceProxyInterface() = default;
 
   virtual const dbus::ObjectPath& GetObjectPath() const = 0;
@@ -367,9 +454,7 @@ func TestGenerateMockProxiesWithProxyPath(t *testing.T) {
 
 #include <base/functional/callback_forward.h>
 #include <base/logging.h>
-#include <brillo/any.h>
 #include <brillo/errors/error.h>
-#include <brillo/variant_dictionary.h>
 #include <gmock/gmock.h>
 
 #include "../proxy.h"
@@ -409,10 +494,10 @@ func TestGenerateMockProxiesWithMethods(t *testing.T) {
 				{
 					Name: "iprotoArg",
 					Type: "ay",
-					Annotation: introspect.Annotation{
+					Annotations: []introspect.Annotation{{
 						Name:  "org.chromium.DBus.Argument.ProtobufClass",
 						Value: "RequestProto",
-					},
+					}},
 				},
 			},
 		}, {
@@ -425,10 +510,10 @@ func TestGenerateMockProxiesWithMethods(t *testing.T) {
 					Name:      "oprotoArg",
 					Type:      "ay",
 					Direction: "out",
-					Annotation: introspect.Annotation{
+					Annotations: []introspect.Annotation{{
 						Name:  "org.chromium.DBus.Argument.ProtobufClass",
 						Value: "ResponseProto",
-					},
+					}},
 				},
 			},
 		}, {
@@ -517,11 +602,10 @@ func TestGenerateMockProxiesWithMethods(t *testing.T) {
 #include <string>
 #include <vector>
 
+#include <base/files/scoped_file.h>
 #include <base/functional/callback_forward.h>
 #include <base/logging.h>
-#include <brillo/any.h>
 #include <brillo/errors/error.h>
-#include <brillo/variant_dictionary.h>
 #include <gmock/gmock.h>
 
 #include "../proxy.h"
@@ -748,10 +832,10 @@ func TestGenerateMockProxiesWithSignals(t *testing.T) {
 					{
 						Name: "sarg1_1",
 						Type: "ay",
-						Annotation: introspect.Annotation{
+						Annotations: []introspect.Annotation{{
 							Name:  "org.chromium.DBus.Argument.ProtobufClass",
 							Value: "YetAnotherProto",
-						},
+						}},
 					}, {
 						Name: "sarg1_2",
 						Type: "(ih)",
@@ -792,11 +876,10 @@ func TestGenerateMockProxiesWithSignals(t *testing.T) {
 #include <string>
 #include <vector>
 
+#include <base/files/scoped_file.h>
 #include <base/functional/callback_forward.h>
 #include <base/logging.h>
-#include <brillo/any.h>
 #include <brillo/errors/error.h>
-#include <brillo/variant_dictionary.h>
 #include <gmock/gmock.h>
 
 #include "../proxy.h"
@@ -861,6 +944,12 @@ func TestGenerateMockProxiesWithProperties(t *testing.T) {
 				Access:    "readwrite",
 				DocString: "\n        property doc\n      ",
 			},
+			{
+				Name:      "WriteonlyProperty",
+				Type:      "a{sv}",
+				Access:    "write",
+				DocString: "\n        property doc\n      ",
+			},
 		},
 	}
 
@@ -883,7 +972,6 @@ func TestGenerateMockProxiesWithProperties(t *testing.T) {
 
 #include <base/functional/callback_forward.h>
 #include <base/logging.h>
-#include <brillo/any.h>
 #include <brillo/errors/error.h>
 #include <brillo/variant_dictionary.h>
 #include <gmock/gmock.h>
@@ -900,6 +988,10 @@ class EmptyInterfaceProxyMock : public EmptyInterfaceProxyInterface {
 
   MOCK_METHOD(const brillo::VariantDictionary&, readonly_property, (), (const, override));
   MOCK_METHOD(bool, is_readonly_property_valid, (), (const, override));
+  MOCK_METHOD(void,
+              SetReadonlyPropertyChangedCallback,
+              ((const base::RepeatingCallback<void(const brillo::VariantDictionary&)>&)),
+              (override));
 
   MOCK_METHOD(const brillo::VariantDictionary&, writable_property, (), (const, override));
   MOCK_METHOD(bool, is_writable_property_valid, (), (const, override));
@@ -907,6 +999,15 @@ class EmptyInterfaceProxyMock : public EmptyInterfaceProxyInterface {
               set_writable_property,
               (const brillo::VariantDictionary&, base::OnceCallback<void(bool)>),
               (override));
+  MOCK_METHOD(void,
+              SetWritablePropertyChangedCallback,
+              ((const base::RepeatingCallback<void(const brillo::VariantDictionary&)>&)),
+              (override));
+
+  MOCK_METHOD(void,
+              set_writeonly_property,
+              (const brillo::VariantDictionary&, base::OnceCallback<void(bool)>),
+              (override));
 
   MOCK_METHOD(const dbus::ObjectPath&, GetObjectPath, (), (const, override));
   MOCK_METHOD(dbus::ObjectProxy*, GetObjectProxy, (), (const, override));
@@ -968,7 +1069,6 @@ func TestGenerateMockProxiesWithPropertiesAndObjectManager(t *testing.T) {
 
 #include <base/functional/callback_forward.h>
 #include <base/logging.h>
-#include <brillo/any.h>
 #include <brillo/errors/error.h>
 #include <brillo/variant_dictionary.h>
 #include <gmock/gmock.h>
@@ -985,6 +1085,10 @@ class EmptyInterfaceProxyMock : public EmptyInterfaceProxyInterface {
 
   MOCK_METHOD(const brillo::VariantDictionary&, readonly_property, (), (const, override));
   MOCK_METHOD(bool, is_readonly_property_valid, (), (const, override));
+  MOCK_METHOD(void,
+              SetReadonlyPropertyChangedCallback,
+              ((const base::RepeatingCallback<void(const brillo::VariantDictionary&)>&)),
+              (override));
 
   MOCK_METHOD(const brillo::VariantDictionary&, writable_property, (), (const, override));
   MOCK_METHOD(bool, is_writable_property_valid, (), (const, override));
@@ -992,6 +1096,10 @@ class EmptyInterfaceProxyMock : public EmptyInterfaceProxyInterface {
               set_writable_property,
               (const brillo::VariantDictionary&, base::OnceCallback<void(bool)>),
               (override));
+  MOCK_METHOD(void,
+              SetWritablePropertyChangedCallback,
+              ((const base::RepeatingCallback<void(const brillo::VariantDictionary&)>&)),
+              (override));
 
   MOCK_METHOD(const dbus::ObjectPath&, GetObjectPath, (), (const, override));
   MOCK_METHOD(dbus::ObjectProxy*, GetObjectProxy, (), (const, override));
@@ -1010,3 +1118,235 @@ class EmptyInterfaceProxyMock : public EmptyInterfaceProxyInterface {
 		t.Errorf("Generate failed (-got +want):\n%s", diff)
 	}
 }
+
+func TestGenerateMockProxiesWithLightweightProperties(t *testing.T) {
+	introspections := []introspect.Introspection{{
+		Interfaces: []introspect.Interface{{
+			Name: "org.chromium.Test",
+			Properties: []introspect.Property{
+				{Name: "Label", Type: "s", Access: "readwrite"},
+			},
+			Annotations: []introspect.Annotation{
+				{Name: "org.chromium.DBus.Interface.LightweightProperties", Value: "true"},
+			},
+		}},
+	}}
+
+	out := new(bytes.Buffer)
+	if err := GenerateMock(introspections, out, "/tmp/mock.h", "", serviceconfig.Config{}); err != nil {
+		t.Fatalf("GenerateMock got error, want nil: %v", err)
+	}
+
+	const want = `// Automatic generation of D-Bus interface mock proxies for:
+//  - org.chromium.Test
+#ifndef ____CHROMEOS_DBUS_BINDING___TMP_MOCK_H
+#define ____CHROMEOS_DBUS_BINDING___TMP_MOCK_H
+#include <string>
+#include <vector>
+
+#include <base/functional/callback_forward.h>
+#include <base/logging.h>
+#include <brillo/errors/error.h>
+#include <gmock/gmock.h>
+
+namespace org {
+namespace chromium {
+
+// Abstract interface proxy for org::chromium::Test.
+class TestProxyInterface {
+ public:
+  static constexpr char kInterfaceName[] = "org.chromium.Test";
+  static constexpr char kLabelProperty[] = "Label";
+  static constexpr char kLabelPropertySignature[] = "s";
+
+  virtual ~TestProxyInterface() = default;
+
+  static const char* LabelName() { return "Label"; }
+  virtual void SetLabelChangedCallback(
+      const base::RepeatingCallback<void(const std::string&)>& callback) = 0;
+
+  virtual const dbus::ObjectPath& GetObjectPath() const = 0;
+  virtual dbus::ObjectProxy* GetObjectProxy() const = 0;
+
+  // Connects to the PropertiesChanged signal to run the property changed
+  // callbacks. The property values are not cached.
+  virtual void ConnectPropertiesChangedSignal(
+      dbus::ObjectProxy::OnConnectedCallback on_connected_callback) = 0;
+};
+
+}  // namespace chromium
+}  // namespace org
+
+namespace org {
+namespace chromium {
+
+// Mock object for TestProxyInterface.
+class TestProxyMock : public TestProxyInterface {
+ public:
+  TestProxyMock() = default;
+  TestProxyMock(const TestProxyMock&) = delete;
+  TestProxyMock& operator=(const TestProxyMock&) = delete;
+
+  MOCK_METHOD(void,
+              SetLabelChangedCallback,
+              ((const base::RepeatingCallback<void(const std::string&)>&)),
+              (override));
+
+  MOCK_METHOD(const dbus::ObjectPath&, GetObjectPath, (), (const, override));
+  MOCK_METHOD(dbus::ObjectProxy*, GetObjectProxy, (), (const, override));
+
+  MOCK_METHOD(void,
+              ConnectPropertiesChangedSignal,
+              (dbus::ObjectProxy::OnConnectedCallback),
+              (override));
+};
+}  // namespace chromium
+}  // namespace org
+
+#endif  // ____CHROMEOS_DBUS_BINDING___TMP_MOCK_H
+`
+	if diff := cmp.Diff(out.String(), want); diff != "" {
+		t.Errorf("GenerateMock failed (-got +want):\n%s", diff)
+	}
+}
+
+func TestGenerateMockProxiesWithoutBlockingCalls(t *testing.T) {
+	introspections := []introspect.Introspection{{
+		Interfaces: []introspect.Interface{{
+			Name: "org.chromium.Test",
+			Methods: []introspect.Method{{
+				Name: "Scan",
+				Args: []introspect.MethodArg{
+					{Name: "name", Type: "s"},
+					{Name: "count", Type: "i", Direction: "out"},
+				},
+			}},
+		}},
+	}}
+
+	sc := serviceconfig.Config{DisableBlockingCalls: true}
+	out := new(bytes.Buffer)
+	if err := GenerateMock(introspections, out, "/tmp/mock.h", "../proxy.h", sc); err != nil {
+		t.Fatalf("Generate got error, want nil: %v", err)
+	}
+
+	const want = `// Automatic generation of D-Bus interface mock proxies for:
+//  - org.chromium.Test
+#ifndef ____CHROMEOS_DBUS_BINDING___TMP_MOCK_H
+#define ____CHROMEOS_DBUS_BINDING___TMP_MOCK_H
+#include <string>
+#include <vector>
+
+#include <base/functional/callback_forward.h>
+#include <base/logging.h>
+#include <brillo/errors/error.h>
+#include <gmock/gmock.h>
+
+#include "../proxy.h"
+
+namespace org {
+namespace chromium {
+
+// Mock object for TestProxyInterface.
+class TestProxyMock : public TestProxyInterface {
+ public:
+  TestProxyMock() = default;
+  TestProxyMock(const TestProxyMock&) = delete;
+  TestProxyMock& operator=(const TestProxyMock&) = delete;
+
+  MOCK_METHOD(void,
+              ScanAsync,
+              (const std::string& /*in_name*/,
+               base::OnceCallback<void(int32_t /*count*/)> /*success_callback*/,
+               base::OnceCallback<void(brillo::Error*)> /*error_callback*/,
+               int /*timeout_ms*/),
+              (override));
+
+  MOCK_METHOD(const dbus::ObjectPath&, GetObjectPath, (), (const, override));
+  MOCK_METHOD(dbus::ObjectProxy*, GetObjectProxy, (), (const, override));
+};
+}  // namespace chromium
+}  // namespace org
+
+#endif  // ____CHROMEOS_DBUS_BINDING___TMP_MOCK_H
+`
+
+	if diff := cmp.Diff(out.String(), want); diff != "" {
+		t.Errorf("Generate failed (-got +want):\n%s", diff)
+	}
+}
+
+func TestGenerateMockProxiesWithUnnamedArgs(t *testing.T) {
+	introspections := []introspect.Introspection{{
+		Name: "/org/chromium/Test",
+		Interfaces: []introspect.Interface{{
+			Name: "org.chromium.Test",
+			Methods: []introspect.Method{{
+				Name: "Scan",
+				Args: []introspect.MethodArg{
+					{Type: "i", Direction: "out"},
+					{Type: "s"},
+					{Name: "flags", Type: "u"},
+				},
+			}},
+		}},
+	}}
+
+	out := new(bytes.Buffer)
+	if err := GenerateMock(introspections, out, "/tmp/mock.h", "../proxy.h", serviceconfig.Config{}); err != nil {
+		t.Fatalf("Generate got error, want nil: %v", err)
+	}
+
+	const want = `// Automatic generation of D-Bus interface mock proxies for:
+//  - org.chromium.Test
+#ifndef ____CHROMEOS_DBUS_BINDING___TMP_MOCK_H
+#define ____CHROMEOS_DBUS_BINDING___TMP_MOCK_H
+#include <string>
+#include <vector>
+
+#include <base/functional/callback_forward.h>
+#include <base/logging.h>
+#include <brillo/errors/error.h>
+#include <gmock/gmock.h>
+
+#include "../proxy.h"
+
+namespace org {
+namespace chromium {
+
+// Mock object for TestProxyInterface.
+class TestProxyMock : public TestProxyInterface {
+ public:
+  TestProxyMock() = default;
+  TestProxyMock(const TestProxyMock&) = delete;
+  TestProxyMock& operator=(const TestProxyMock&) = delete;
+
+  MOCK_METHOD(bool,
+              Scan,
+              (const std::string& /*in_1*/,
+               uint32_t /*in_flags*/,
+               int32_t* /*out_3*/,
+               brillo::ErrorPtr* /*error*/,
+               int /*timeout_ms*/),
+              (override));
+  MOCK_METHOD(void,
+              ScanAsync,
+              (const std::string& /*in_1*/,
+               uint32_t /*in_flags*/,
+               base::OnceCallback<void(int32_t)> /*success_callback*/,
+               base::OnceCallback<void(brillo::Error*)> /*error_callback*/,
+               int /*timeout_ms*/),
+              (override));
+
+  MOCK_METHOD(const dbus::ObjectPath&, GetObjectPath, (), (const, override));
+  MOCK_METHOD(dbus::ObjectProxy*, GetObjectProxy, (), (const, override));
+};
+}  // namespace chromium
+}  // namespace org
+
+#endif  // ____CHROMEOS_DBUS_BINDING___TMP_MOCK_H
+`
+	if diff := cmp.Diff(out.String(), want); diff != "" {
+		t.Errorf("Generate failed (-got +want):\n%s", diff)
+	}
+}
@@ -0,0 +1,401 @@
+// Copyright 2022 The ChromiumOS Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package proxy
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"go.chromium.org/chromiumos/dbusbindings/dbustype"
+	"go.chromium.org/chromiumos/dbusbindings/generate/genutil"
+	"go.chromium.org/chromiumos/dbusbindings/introspect"
+	"go.chromium.org/chromiumos/dbusbindings/serviceconfig"
+)
+
+// noBrilloTemplateText generates the proxies for the consumers inside
+// Chromium, which have libchrome but cannot depend on brillo. The arguments
+// are marshaled by the code generated inline with dbus::MessageWriter and
+// dbus::MessageReader instead of brillo::dbus_utils.
+const noBrilloTemplateText = `// Automatic generation of D-Bus proxies without brillo for:
+{{range .Introspects}}{{range .Interfaces -}}
+//  - {{.Name}}
+{{end}}{{end -}}
+
+#ifndef {{.HeaderGuard}}
+#define {{.HeaderGuard}}
+#include <map>
+#include <memory>
+#include <string>
+#include <tuple>
+#include <utility>
+#include <vector>
+
+#include <base/files/scoped_file.h>
+#include <base/functional/bind.h>
+#include <base/functional/callback.h>
+#include <base/memory/ref_counted.h>
+#include <dbus/bus.h>
+#include <dbus/message.h>
+#include <dbus/object_path.h>
+#include <dbus/object_proxy.h>
+{{- with makeProtobufIncludes .Introspects}}
+{{range .}}
+#include {{.}}
+{{- end}}
+{{- end}}
+{{range $introspect := .Introspects}}{{range $itf := .Interfaces}}
+{{- $proxyName := makeProxyName .Name}}
+{{range extractNameSpaces .Name -}}
+namespace {{.}} {
+{{end}}
+{{formatComment .DocString 0 -}}
+// Proxy for {{.Name}} using only the libchrome D-Bus API.
+// The error callbacks are run with nullptr if the responses cannot be
+// parsed. The properties are not supported.
+class {{$proxyName}} {
+ public:
+  static constexpr char kInterfaceName[] = "{{.Name}}";
+{{if and $.ServiceName $introspect.Name}}
+  explicit {{$proxyName}}(const scoped_refptr<dbus::Bus>& bus)
+      : bus_{bus},
+        dbus_object_proxy_{bus_->GetObjectProxy(service_name_, object_path_)} {}
+{{- else}}
+  {{$proxyName}}(const scoped_refptr<dbus::Bus>& bus
+{{- if not $.ServiceName}},
+   {{repeat " " (len $proxyName)}}const std::string& service_name
+{{- end}}
+{{- if not $introspect.Name}},
+   {{repeat " " (len $proxyName)}}const dbus::ObjectPath& object_path
+{{- end}})
+      : bus_{bus},
+{{- if not $.ServiceName}}
+        service_name_{service_name},
+{{- end}}
+{{- if not $introspect.Name}}
+        object_path_{object_path},
+{{- end}}
+        dbus_object_proxy_{bus_->GetObjectProxy(service_name_, object_path_)} {}
+{{- end}}
+
+  {{$proxyName}}(const {{$proxyName}}&) = delete;
+  {{$proxyName}}& operator=(const {{$proxyName}}&) = delete;
+
+  const dbus::ObjectPath& GetObjectPath() const { return object_path_; }
+
+  dbus::ObjectProxy* GetObjectProxy() const { return dbus_object_proxy_; }
+{{- range .Methods}}
+{{- $method := .}}
+{{- with makeNoBrilloMethod $.NamingStyle $.MoveProtobufResponses .}}
+
+{{formatComment $method.DocString 2 -}}
+{{"  "}}void {{$method.Name}}Async(
+{{- range .InParams}}
+      {{.Type}} {{.Name}},
+{{- end}}
+      {{.CallbackType}} success_callback,
+      base::OnceCallback<void(dbus::ErrorResponse*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) {
+{{- template "methodTimeout" index $.MethodTimeouts (printf "%s.%s" $itf.Name $method.Name)}}
+    dbus::MethodCall method_call(kInterfaceName, "{{$method.Name}}");
+{{- if .AppendCode}}
+    dbus::MessageWriter writer(&method_call);
+{{- nindent 4 .AppendCode}}
+{{- end}}
+    dbus_object_proxy_->CallMethodWithErrorResponse(
+        &method_call, timeout_ms,
+        base::BindOnce(&{{$proxyName}}::On{{$method.Name}}Response,
+                       std::move(success_callback), std::move(error_callback)));
+  }
+{{- end}}
+{{- end}}
+{{- range .Signals}}
+{{- $signal := .}}
+{{- with makeNoBrilloSignal $.NamingStyle .}}
+
+{{formatComment $signal.DocString 2 -}}
+{{"  "}}void Register{{$signal.Name}}SignalHandler(
+      {{- makeSignalCallbackType $signal | nindent 6}} signal_callback,
+      dbus::ObjectProxy::OnConnectedCallback on_connected_callback) {
+    dbus_object_proxy_->ConnectToSignal(
+        kInterfaceName, "{{$signal.Name}}",
+{{- if .Raw}}
+        signal_callback,
+{{- else}}
+        base::BindRepeating(&{{$proxyName}}::On{{$signal.Name}}Signal,
+                            signal_callback),
+{{- end}}
+        std::move(on_connected_callback));
+  }
+{{- end}}
+{{- end}}
+
+ private:
+{{- range .Methods}}
+{{- $method := .}}
+{{- with makeNoBrilloMethod $.NamingStyle $.MoveProtobufResponses .}}
+  static void On{{$method.Name}}Response(
+      {{.CallbackType}} success_callback,
+      base::OnceCallback<void(dbus::ErrorResponse*)> error_callback,
+      dbus::Response* response,
+      dbus::ErrorResponse* error_response) {
+    if (!response) {
+      std::move(error_callback).Run(error_response);
+      return;
+    }
+{{- range .OutParams}}
+    {{.Type}} {{.Name}}{};
+{{- end}}
+{{- if .OutParams}}
+    if (!Pop{{$method.Name}}Response(response{{range .OutParams}}, &{{.Name}}{{end}})) {
+      std::move(error_callback).Run(nullptr);
+      return;
+    }
+{{- end}}
+    std::move(success_callback).Run({{range $i, $p := .OutParams}}{{if $i}}, {{end}}std::move({{$p.Name}}){{end}});
+  }
+{{- if .OutParams}}
+
+  static bool Pop{{$method.Name}}Response(
+      dbus::Response* response
+{{- range .OutParams}},
+      {{.Type}}* {{.Name}}
+{{- end}}) {
+    dbus::MessageReader reader(response);
+{{- nindent 4 .PopCode}}
+    return true;
+  }
+{{- end}}
+{{/* blank line separator */}}
+{{- end}}
+{{- end}}
+{{- range .Signals}}
+{{- $signal := .}}
+{{- with makeNoBrilloSignal $.NamingStyle .}}
+{{- if not .Raw}}
+  static void On{{$signal.Name}}Signal(
+      {{- makeSignalCallbackType $signal | nindent 6}} signal_callback,
+      dbus::Signal* {{if not .Params}}/* {{end}}signal{{if not .Params}} */{{end}}) {
+{{- range .Params}}
+    {{.Type}} {{.Name}}{};
+{{- end}}
+{{- if .Params}}
+    if (!Pop{{$signal.Name}}Signal(signal{{range .Params}}, &{{.Name}}{{end}}))
+      return;
+{{- end}}
+    signal_callback.Run({{range $i, $p := .Params}}{{if $i}}, {{end}}std::move({{$p.Name}}){{end}});
+  }
+{{- if .Params}}
+
+  static bool Pop{{$signal.Name}}Signal(
+      dbus::Signal* signal
+{{- range .Params}},
+      {{.Type}}* {{.Name}}
+{{- end}}) {
+    dbus::MessageReader reader(signal);
+{{- nindent 4 .PopCode}}
+    return true;
+  }
+{{- end}}
+{{/* blank line separator */}}
+{{- end}}
+{{- end}}
+{{- end}}
+  scoped_refptr<dbus::Bus> bus_;
+{{- if $.ServiceName}}
+  const std::string service_name_{"{{$.ServiceName}}"};
+{{- else}}
+  std::string service_name_;
+{{- end}}
+{{- if $introspect.Name}}
+  const dbus::ObjectPath object_path_{"{{$introspect.Name}}"};
+{{- else}}
+  dbus::ObjectPath object_path_;
+{{- end}}
+  dbus::ObjectProxy* dbus_object_proxy_;
+};
+
+{{range extractNameSpaces .Name | reverse -}}
+}  // namespace {{.}}
+{{end}}
+{{- end}}{{end}}
+#endif  // {{.HeaderGuard}}
+`
+
+// noBrilloTemplates is parsed once, and cloned by every GenerateNoBrillo
+// call.
+var noBrilloTemplates = mustParseTemplates("noBrillo", funcMap, noBrilloTemplateText, methodTimeoutTemplate)
+
+// noBrilloMethod is a method of a proxy generated without brillo.
+type noBrilloMethod struct {
+	InParams []param
+	// OutParams are the local variables the output arguments are popped
+	// into, and passed to the success callback.
+	OutParams    []param
+	CallbackType string
+	// AppendCode and PopCode are the statements appending the input
+	// arguments to the method call, and popping the output arguments from
+	// the response respectively.
+	AppendCode, PopCode string
+}
+
+// noBrilloSignal is a signal of a proxy generated without brillo.
+type noBrilloSignal struct {
+	// Raw tells whether the signal callback takes the dbus::Signal as is.
+	Raw bool
+	// Params are the local variables the arguments are popped into, and
+	// passed to the signal callback.
+	Params  []param
+	PopCode string
+}
+
+// checkNoBrilloAnnotations returns an error if any of the annotations of the
+// argument name changes the C++ type in a way which needs brillo to marshal.
+func checkNoBrilloAnnotations(name string, annotations []introspect.Annotation) error {
+	for _, a := range annotations {
+		switch a.Name {
+		case "org.chromium.DBus.Argument.ProtobufClass", "org.chromium.DBus.Argument.DefaultValue",
+			"org.chromium.DBus.Argument.PassByMove":
+			continue
+		}
+		return fmt.Errorf("argument %s: annotation %s is not supported without brillo", name, a.Name)
+	}
+	return nil
+}
+
+// hasProtobufClass returns true if annotations have the
+// org.chromium.DBus.Argument.ProtobufClass annotation.
+func hasProtobufClass(annotations []introspect.Annotation) bool {
+	for _, a := range annotations {
+		if a.Name == "org.chromium.DBus.Argument.ProtobufClass" {
+			return true
+		}
+	}
+	return false
+}
+
+// noBrilloAppendCode returns the statements appending the value v of the
+// D-Bus type typ, rendered as a protobuf message if proto is set, to the
+// dbus::MessageWriter named writer.
+func noBrilloAppendCode(typ string, proto bool, v string) (string, error) {
+	if proto {
+		return fmt.Sprintf("writer.AppendProtoAsArrayOfBytes(%s);", v), nil
+	}
+	return dbustype.AppendCode(typ, "writer", v)
+}
+
+// noBrilloPopCode returns the statements popping the value of the D-Bus type
+// typ, rendered as a protobuf message if proto is set, from the
+// dbus::MessageReader named reader into the value pointed by p.
+func noBrilloPopCode(typ string, proto bool, p string) (string, error) {
+	if proto {
+		return fmt.Sprintf("if (!reader.PopArrayOfBytesAsProto(%s))\n  return false;", p), nil
+	}
+	return dbustype.PopCode(typ, "reader", p)
+}
+
+// makeNoBrilloMethod returns the parameters and the marshaling code of the
+// method m.
+func makeNoBrilloMethod(style serviceconfig.NamingStyle, moveProtos bool, m introspect.Method) (noBrilloMethod, error) {
+	var ret noBrilloMethod
+	var appends, pops []string
+	for i, a := range m.InputArguments() {
+		if err := checkNoBrilloAnnotations(a.Name, a.Annotations); err != nil {
+			return noBrilloMethod{}, fmt.Errorf("method %s: %v", m.Name, err)
+		}
+		t, err := a.InArgType()
+		if err != nil {
+			return noBrilloMethod{}, err
+		}
+		name := genutil.ArgNameWithStyle(style, "in", a.Name, genutil.MethodArgIndex(false, i, 0))
+		code, err := noBrilloAppendCode(string(a.Type), hasProtobufClass(a.Annotations), name)
+		if err != nil {
+			return noBrilloMethod{}, fmt.Errorf("method %s: %v", m.Name, err)
+		}
+		ret.InParams = append(ret.InParams, param{t, name})
+		appends = append(appends, code)
+	}
+	numIn := len(m.InputArguments())
+	for i, a := range m.OutputArguments() {
+		if err := checkNoBrilloAnnotations(a.Name, a.Annotations); err != nil {
+			return noBrilloMethod{}, fmt.Errorf("method %s: %v", m.Name, err)
+		}
+		t, err := a.BaseType()
+		if err != nil {
+			return noBrilloMethod{}, err
+		}
+		name := genutil.ArgNameWithStyle(style, "out", a.Name, genutil.MethodArgIndex(true, i, numIn))
+		code, err := noBrilloPopCode(string(a.Type), hasProtobufClass(a.Annotations), name)
+		if err != nil {
+			return noBrilloMethod{}, fmt.Errorf("method %s: %v", m.Name, err)
+		}
+		ret.OutParams = append(ret.OutParams, param{t, name})
+		pops = append(pops, code)
+	}
+	t, err := makeMethodCallbackType(style, moveProtos, m.OutputArguments())
+	if err != nil {
+		return noBrilloMethod{}, err
+	}
+	ret.CallbackType = t
+	ret.AppendCode = strings.Join(appends, "\n")
+	ret.PopCode = strings.Join(pops, "\n")
+	return ret, nil
+}
+
+// makeNoBrilloSignal returns the parameters and the marshaling code of the
+// signal s.
+func makeNoBrilloSignal(style serviceconfig.NamingStyle, s introspect.Signal) (noBrilloSignal, error) {
+	if s.Kind() == introspect.SignalKindRaw {
+		return noBrilloSignal{Raw: true}, nil
+	}
+	var ret noBrilloSignal
+	var pops []string
+	for i, a := range s.Args {
+		if err := checkNoBrilloAnnotations(a.Name, a.Annotations); err != nil {
+			return noBrilloSignal{}, fmt.Errorf("signal %s: %v", s.Name, err)
+		}
+		t, err := a.BaseType()
+		if err != nil {
+			return noBrilloSignal{}, err
+		}
+		name := genutil.ArgNameWithStyle(style, "arg", a.Name, i+1)
+		code, err := noBrilloPopCode(a.Type, hasProtobufClass(a.Annotations), name)
+		if err != nil {
+			return noBrilloSignal{}, fmt.Errorf("signal %s: %v", s.Name, err)
+		}
+		ret.Params = append(ret.Params, param{t, name})
+		pops = append(pops, code)
+	}
+	ret.PopCode = strings.Join(pops, "\n")
+	return ret, nil
+}
+
+// GenerateNoBrillo outputs the proxies of introspects which depend only on
+// libchrome, i.e. the dbus/ and base/ APIs, into f, for the consumers inside
+// Chromium which cannot depend on brillo. The proxies only have the
+// asynchronous methods and the signal handler registrations, and the
+// arguments must not be variants, which are rendered as brillo::Any.
+func GenerateNoBrillo(introspects []introspect.Introspection, f io.Writer, outputFilePath string, config serviceconfig.Config) error {
+	f = genutil.NewAPILevelWriter(f, config.TargetAPILevel)
+	tmpl, err := cloneTemplates(noBrilloTemplates, introspects, config)
+	if err != nil {
+		return err
+	}
+	return tmpl.Execute(f, struct {
+		Introspects           []introspect.Introspection
+		HeaderGuard           string
+		ServiceName           string
+		NamingStyle           serviceconfig.NamingStyle
+		MoveProtobufResponses bool
+		MethodTimeouts        map[string]int
+	}{
+		Introspects:           introspects,
+		HeaderGuard:           genutil.GenerateHeaderGuard(outputFilePath),
+		ServiceName:           config.ServiceName,
+		NamingStyle:           config.NamingStyle,
+		MoveProtobufResponses: config.MoveProtobufResponses,
+		MethodTimeouts:        config.MethodTimeouts,
+	})
+}
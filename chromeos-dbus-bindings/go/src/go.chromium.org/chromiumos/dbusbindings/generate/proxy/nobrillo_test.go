@@ -0,0 +1,301 @@
+// Copyright 2022 The ChromiumOS Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package proxy
+
+import (
+	"bytes"
+	"testing"
+
+	"go.chromium.org/chromiumos/dbusbindings/introspect"
+	"go.chromium.org/chromiumos/dbusbindings/serviceconfig"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGenerateNoBrillo(t *testing.T) {
+	introspections := []introspect.Introspection{{
+		Name: "/org/chromium/Test",
+		Interfaces: []introspect.Interface{{
+			Name: "org.chromium.Test",
+			Methods: []introspect.Method{
+				{
+					Name:      "Scan",
+					DocString: "Scans the devices.",
+					Args: []introspect.MethodArg{
+						{Name: "name", Type: "s"},
+						{Name: "filter", Type: "a{sx}"},
+						{Name: "count", Type: "i", Direction: "out"},
+						{Name: "results", Type: "a(oh)", Direction: "out"},
+					},
+				}, {
+					Name: "Update",
+					Args: []introspect.MethodArg{
+						{
+							Name: "request",
+							Type: "ay",
+							Annotations: []introspect.Annotation{{
+								Name:  "org.chromium.DBus.Argument.ProtobufClass",
+								Value: "test::UpdateRequest",
+							}},
+						},
+					},
+				},
+			},
+			Signals: []introspect.Signal{
+				{Name: "Changed", Args: []introspect.SignalArg{{Name: "paths", Type: "ao"}}},
+				{Name: "Reset"},
+			},
+		}},
+	}}
+
+	out := new(bytes.Buffer)
+	if err := GenerateNoBrillo(introspections, out, "/tmp/proxy.h", serviceconfig.Config{}); err != nil {
+		t.Fatalf("GenerateNoBrillo got error, want nil: %v", err)
+	}
+
+	const want = `// Automatic generation of D-Bus proxies without brillo for:
+//  - org.chromium.Test
+#ifndef ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+#define ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+#include <map>
+#include <memory>
+#include <string>
+#include <tuple>
+#include <utility>
+#include <vector>
+
+#include <base/files/scoped_file.h>
+#include <base/functional/bind.h>
+#include <base/functional/callback.h>
+#include <base/memory/ref_counted.h>
+#include <dbus/bus.h>
+#include <dbus/message.h>
+#include <dbus/object_path.h>
+#include <dbus/object_proxy.h>
+
+namespace org {
+namespace chromium {
+
+// Proxy for org.chromium.Test using only the libchrome D-Bus API.
+// The error callbacks are run with nullptr if the responses cannot be
+// parsed. The properties are not supported.
+class TestProxy {
+ public:
+  static constexpr char kInterfaceName[] = "org.chromium.Test";
+
+  TestProxy(const scoped_refptr<dbus::Bus>& bus,
+            const std::string& service_name)
+      : bus_{bus},
+        service_name_{service_name},
+        dbus_object_proxy_{bus_->GetObjectProxy(service_name_, object_path_)} {}
+
+  TestProxy(const TestProxy&) = delete;
+  TestProxy& operator=(const TestProxy&) = delete;
+
+  const dbus::ObjectPath& GetObjectPath() const { return object_path_; }
+
+  dbus::ObjectProxy* GetObjectProxy() const { return dbus_object_proxy_; }
+
+  // Scans the devices.
+  void ScanAsync(
+      const std::string& in_name,
+      const std::map<std::string, int64_t>& in_filter,
+      base::OnceCallback<void(int32_t /*count*/, const std::vector<std::tuple<dbus::ObjectPath, base::ScopedFD>>& /*results*/)> success_callback,
+      base::OnceCallback<void(dbus::ErrorResponse*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) {
+    dbus::MethodCall method_call(kInterfaceName, "Scan");
+    dbus::MessageWriter writer(&method_call);
+    writer.AppendString(in_name);
+    {
+      dbus::MessageWriter array_writer_1(nullptr);
+      writer.OpenArray("{sx}", &array_writer_1);
+      for (const auto& element_1 : in_filter) {
+        dbus::MessageWriter entry_writer_1(nullptr);
+        array_writer_1.OpenDictEntry(&entry_writer_1);
+        entry_writer_1.AppendString(element_1.first);
+        entry_writer_1.AppendInt64(element_1.second);
+        array_writer_1.CloseContainer(&entry_writer_1);
+      }
+      writer.CloseContainer(&array_writer_1);
+    }
+    dbus_object_proxy_->CallMethodWithErrorResponse(
+        &method_call, timeout_ms,
+        base::BindOnce(&TestProxy::OnScanResponse,
+                       std::move(success_callback), std::move(error_callback)));
+  }
+
+  void UpdateAsync(
+      const test::UpdateRequest& in_request,
+      base::OnceCallback<void()> success_callback,
+      base::OnceCallback<void(dbus::ErrorResponse*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) {
+    dbus::MethodCall method_call(kInterfaceName, "Update");
+    dbus::MessageWriter writer(&method_call);
+    writer.AppendProtoAsArrayOfBytes(in_request);
+    dbus_object_proxy_->CallMethodWithErrorResponse(
+        &method_call, timeout_ms,
+        base::BindOnce(&TestProxy::OnUpdateResponse,
+                       std::move(success_callback), std::move(error_callback)));
+  }
+
+  void RegisterChangedSignalHandler(
+      const base::RepeatingCallback<void(const std::vector<dbus::ObjectPath>&)>& signal_callback,
+      dbus::ObjectProxy::OnConnectedCallback on_connected_callback) {
+    dbus_object_proxy_->ConnectToSignal(
+        kInterfaceName, "Changed",
+        base::BindRepeating(&TestProxy::OnChangedSignal,
+                            signal_callback),
+        std::move(on_connected_callback));
+  }
+
+  void RegisterResetSignalHandler(
+      base::RepeatingClosure signal_callback,
+      dbus::ObjectProxy::OnConnectedCallback on_connected_callback) {
+    dbus_object_proxy_->ConnectToSignal(
+        kInterfaceName, "Reset",
+        base::BindRepeating(&TestProxy::OnResetSignal,
+                            signal_callback),
+        std::move(on_connected_callback));
+  }
+
+ private:
+  static void OnScanResponse(
+      base::OnceCallback<void(int32_t /*count*/, const std::vector<std::tuple<dbus::ObjectPath, base::ScopedFD>>& /*results*/)> success_callback,
+      base::OnceCallback<void(dbus::ErrorResponse*)> error_callback,
+      dbus::Response* response,
+      dbus::ErrorResponse* error_response) {
+    if (!response) {
+      std::move(error_callback).Run(error_response);
+      return;
+    }
+    int32_t out_count{};
+    std::vector<std::tuple<dbus::ObjectPath, base::ScopedFD>> out_results{};
+    if (!PopScanResponse(response, &out_count, &out_results)) {
+      std::move(error_callback).Run(nullptr);
+      return;
+    }
+    std::move(success_callback).Run(std::move(out_count), std::move(out_results));
+  }
+
+  static bool PopScanResponse(
+      dbus::Response* response,
+      int32_t* out_count,
+      std::vector<std::tuple<dbus::ObjectPath, base::ScopedFD>>* out_results) {
+    dbus::MessageReader reader(response);
+    if (!reader.PopInt32(out_count))
+      return false;
+    {
+      dbus::MessageReader array_reader_1(nullptr);
+      if (!reader.PopArray(&array_reader_1))
+        return false;
+      while (array_reader_1.HasMoreData()) {
+        std::tuple<dbus::ObjectPath, base::ScopedFD> element_1{};
+        {
+          dbus::MessageReader struct_reader_2(nullptr);
+          if (!array_reader_1.PopStruct(&struct_reader_2))
+            return false;
+          if (!struct_reader_2.PopObjectPath(&std::get<0>(element_1)))
+            return false;
+          if (!struct_reader_2.PopFileDescriptor(&std::get<1>(element_1)))
+            return false;
+        }
+        out_results->push_back(std::move(element_1));
+      }
+    }
+    return true;
+  }
+
+  static void OnUpdateResponse(
+      base::OnceCallback<void()> success_callback,
+      base::OnceCallback<void(dbus::ErrorResponse*)> error_callback,
+      dbus::Response* response,
+      dbus::ErrorResponse* error_response) {
+    if (!response) {
+      std::move(error_callback).Run(error_response);
+      return;
+    }
+    std::move(success_callback).Run();
+  }
+
+  static void OnChangedSignal(
+      const base::RepeatingCallback<void(const std::vector<dbus::ObjectPath>&)>& signal_callback,
+      dbus::Signal* signal) {
+    std::vector<dbus::ObjectPath> arg_paths{};
+    if (!PopChangedSignal(signal, &arg_paths))
+      return;
+    signal_callback.Run(std::move(arg_paths));
+  }
+
+  static bool PopChangedSignal(
+      dbus::Signal* signal,
+      std::vector<dbus::ObjectPath>* arg_paths) {
+    dbus::MessageReader reader(signal);
+    {
+      dbus::MessageReader array_reader_1(nullptr);
+      if (!reader.PopArray(&array_reader_1))
+        return false;
+      while (array_reader_1.HasMoreData()) {
+        dbus::ObjectPath element_1{};
+        if (!array_reader_1.PopObjectPath(&element_1))
+          return false;
+        arg_paths->push_back(std::move(element_1));
+      }
+    }
+    return true;
+  }
+
+  static void OnResetSignal(
+      base::RepeatingClosure signal_callback,
+      dbus::Signal* /* signal */) {
+    signal_callback.Run();
+  }
+
+  scoped_refptr<dbus::Bus> bus_;
+  std::string service_name_;
+  const dbus::ObjectPath object_path_{"/org/chromium/Test"};
+  dbus::ObjectProxy* dbus_object_proxy_;
+};
+
+}  // namespace chromium
+}  // namespace org
+
+#endif  // ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+`
+
+	if diff := cmp.Diff(out.String(), want); diff != "" {
+		t.Errorf("GenerateNoBrillo failed (-got +want):\n%s", diff)
+	}
+}
+
+func TestGenerateNoBrilloUnsupportedTypes(t *testing.T) {
+	cases := []introspect.Method{{
+		Name: "GetVariant",
+		Args: []introspect.MethodArg{{Name: "value", Type: "v", Direction: "out"}},
+	}, {
+		Name: "SetProperties",
+		Args: []introspect.MethodArg{{Name: "properties", Type: "a{sv}"}},
+	}, {
+		Name: "SetMode",
+		Args: []introspect.MethodArg{{
+			Name: "mode",
+			Type: "i",
+			Annotations: []introspect.Annotation{{
+				Name:  "org.chromium.DBus.Argument.EnumClass",
+				Value: "Mode(kOff, kOn)",
+			}},
+		}},
+	}}
+	for _, m := range cases {
+		introspections := []introspect.Introspection{{
+			Interfaces: []introspect.Interface{{
+				Name:    "org.chromium.Test",
+				Methods: []introspect.Method{m},
+			}},
+		}}
+		if err := GenerateNoBrillo(introspections, new(bytes.Buffer), "/tmp/proxy.h", serviceconfig.Config{}); err == nil {
+			t.Errorf("GenerateNoBrillo with method %s succeeded, want error", m.Name)
+		}
+	}
+}
@@ -0,0 +1,363 @@
+// Copyright 2022 The ChromiumOS Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package proxy
+
+import (
+	"errors"
+	"io"
+	"regexp"
+
+	"go.chromium.org/chromiumos/dbusbindings/generate/genutil"
+	"go.chromium.org/chromiumos/dbusbindings/introspect"
+	"go.chromium.org/chromiumos/dbusbindings/serviceconfig"
+)
+
+// pimplHeaderTemplateText generates the public header of the ...PimplProxy
+// classes. It must not include any libchrome, brillo or dbus header, so that
+// the classes can be exported from a shared library with a stable ABI.
+const pimplHeaderTemplateText = `// Automatic generation of D-Bus pimpl proxies for:
+{{range .Introspects}}{{range .Interfaces -}}
+//  - {{.Name}}
+{{end}}{{end -}}
+
+#ifndef {{.HeaderGuard}}
+#define {{.HeaderGuard}}
+#include <cstdint>
+#include <map>
+#include <memory>
+{{- if hasOptionalArgs .Introspects}}
+#include <optional>
+{{- end}}
+#include <string>
+#include <tuple>
+#include <vector>
+{{- with makeProtobufIncludes .Introspects}}
+{{range .}}
+#include {{.}}
+{{- end}}
+{{- end}}
+{{range $introspect := .Introspects}}{{range $itf := .Interfaces}}
+{{- $className := printf "%sPimplProxy" (makeTypeName .Name)}}
+{{- $ctorParams := makePimplConstructorParams $.ServiceName $introspect.Name}}
+{{- $methods := makePimplMethods $.NamingStyle .}}
+{{range extractNameSpaces .Name -}}
+namespace {{.}} {
+{{end}}
+// Proxy for {{makeFullItfName .Name}} hiding the D-Bus implementation, so that
+// it can be exported from a shared library. It connects to the system bus, and
+// must be used on the thread it is created on.
+class {{$className}} {
+ public:
+{{- if not $ctorParams}}
+  {{$className}}();
+{{- else if eq (len $ctorParams) 1}}
+  explicit {{$className}}({{index $ctorParams 0}});
+{{- else}}
+  {{$className}}(
+{{- range $i, $p := $ctorParams}}{{if $i}},{{end}}
+      {{$p}}
+{{- end}});
+{{- end}}
+  {{$className}}(const {{$className}}&) = delete;
+  {{$className}}& operator=(const {{$className}}&) = delete;
+  ~{{$className}}();
+{{- range $methods.Methods}}
+
+{{formatComment .Method.DocString 2 -}}
+{{"  "}}bool {{.Method.Name}}(
+{{- range .InParams}}
+      {{.Type}} {{.Name}},
+{{- end}}
+{{- range .OutParams}}
+      {{.Type}} {{.Name}},
+{{- end}}
+      std::string* error_message = nullptr);
+{{- end}}
+{{- with $methods.Unavailable}}
+
+  // The following methods are not available, as their arguments are rendered
+  // with libchrome, brillo or dbus types:
+{{- range .}}
+  //  - {{.}}
+{{- end}}
+{{- end}}
+
+ private:
+  struct Impl;
+  std::unique_ptr<Impl> impl_;
+};
+
+{{range extractNameSpaces .Name | reverse -}}
+}  // namespace {{.}}
+{{end}}
+{{- end}}{{end}}
+#endif  // {{.HeaderGuard}}
+`
+
+// pimplSourceTemplateText generates the definitions of the ...PimplProxy
+// classes, which forward the calls to the ...Proxy classes.
+const pimplSourceTemplateText = `// Automatic generation of D-Bus pimpl proxies for:
+{{range .Introspects}}{{range .Interfaces -}}
+//  - {{.Name}}
+{{end}}{{end -}}
+
+#include "{{.HeaderFilePath}}"
+
+#include <memory>
+#include <string>
+#include <utility>
+
+#include <base/check.h>
+{{- if and .ObjectManagerName (extractInterfacesWithProperties .Introspects)}}
+#include <base/functional/callback_helpers.h>
+{{- end}}
+#include <base/memory/ref_counted.h>
+#include <brillo/errors/error.h>
+#include <dbus/bus.h>
+#include <dbus/object_path.h>
+
+#include "{{.ProxyFilePath}}"
+
+namespace {
+
+scoped_refptr<dbus::Bus> ConnectToSystemBus() {
+  dbus::Bus::Options options;
+  options.bus_type = dbus::Bus::SYSTEM;
+  auto bus = base::MakeRefCounted<dbus::Bus>(std::move(options));
+  CHECK(bus->Connect()) << "Failed to connect to the system bus";
+  return bus;
+}
+
+void SetErrorMessage(const brillo::Error* error, std::string* error_message) {
+  if (error && error_message)
+    *error_message = error->GetMessage();
+}
+
+}  // namespace
+{{range $introspect := .Introspects}}{{range $itf := .Interfaces}}
+{{- $className := printf "%sPimplProxy" (makeTypeName .Name)}}
+{{- $proxyName := makeProxyName .Name}}
+{{- $ctorParams := makePimplConstructorParams $.ServiceName $introspect.Name}}
+{{- $methods := makePimplMethods $.NamingStyle .}}
+{{- $serviceName := or (and $.ServiceName (printf "%q" $.ServiceName)) "service_name"}}
+{{- $objectPath := or (and $introspect.Name (printf "dbus::ObjectPath{%q}" $introspect.Name)) "dbus::ObjectPath{object_path}"}}
+{{- $propertySet := and (interfaceSettings .Name).ObjectManagerName .Properties}}
+{{range extractNameSpaces .Name -}}
+namespace {{.}} {
+{{end}}
+struct {{$className}}::Impl {
+  {{if not $ctorParams}}explicit {{end}}Impl(scoped_refptr<dbus::Bus> bus_in
+{{- range $ctorParams}},
+       {{.}}
+{{- end}})
+      : bus{std::move(bus_in)},
+{{- if $propertySet}}
+        property_set{bus->GetObjectProxy({{$serviceName}},
+                                         {{$objectPath}}),
+                     base::DoNothing()},
+{{- end}}
+        proxy{bus
+{{- if not $.ServiceName}}, service_name{{end}}
+{{- if not $introspect.Name}}, dbus::ObjectPath{object_path}{{end}}
+{{- if $propertySet}}, &property_set{{end}}} {}
+
+  ~Impl() { bus->ShutdownAndBlock(); }
+
+  scoped_refptr<dbus::Bus> bus;
+{{- if $propertySet}}
+  {{$proxyName}}::PropertySet property_set;
+{{- end}}
+  {{$proxyName}} proxy;
+};
+
+{{$className}}::{{$className}}(
+{{- range $i, $p := $ctorParams}}{{if $i}},{{end}}
+    {{$p}}
+{{- end}})
+    : impl_{std::make_unique<Impl>(ConnectToSystemBus()
+{{- if not $.ServiceName}}, service_name{{end}}
+{{- if not $introspect.Name}}, object_path{{end}})} {}
+
+{{$className}}::~{{$className}}() = default;
+{{- range $methods.Methods}}
+
+bool {{$className}}::{{.Method.Name}}(
+{{- range .InParams}}
+    {{.Type}} {{.Name}},
+{{- end}}
+{{- range .OutParams}}
+    {{.Type}} {{.Name}},
+{{- end}}
+    std::string* error_message) {
+  brillo::ErrorPtr error;
+  if (impl_->proxy.{{.Method.Name}}(
+{{- range .InParams}}{{.Name}}, {{end}}
+{{- range .OutParams}}{{.Name}}, {{end}}&error))
+    return true;
+  SetErrorMessage(error.get(), error_message);
+  return false;
+}
+{{- end}}
+
+{{range extractNameSpaces .Name | reverse -}}
+}  // namespace {{.}}
+{{end}}
+{{- end}}{{end -}}
+`
+
+// pimplHeaderTemplates and pimplSourceTemplates are parsed once, and cloned
+// by every GeneratePimplHeader and GeneratePimplSource call respectively.
+var (
+	pimplHeaderTemplates = mustParseTemplates("pimplHeader", funcMap, pimplHeaderTemplateText)
+	pimplSourceTemplates = mustParseTemplates("pimplSource", funcMap, pimplSourceTemplateText)
+)
+
+// pimplUnstableTypeRE matches the C++ types which must not appear in the
+// public header of the ...PimplProxy classes.
+var pimplUnstableTypeRE = regexp.MustCompile(`\b(base|brillo|dbus)::`)
+
+// pimplMethod is a method exposed by a ...PimplProxy class.
+type pimplMethod struct {
+	Method              introspect.Method
+	InParams, OutParams []param
+}
+
+// pimplMethods are the methods of an interface split by whether its
+// ...PimplProxy class can expose them.
+type pimplMethods struct {
+	Methods []pimplMethod
+	// Unavailable are the names of the methods which are not exposed.
+	Unavailable []string
+}
+
+// makePimplMethods returns the methods of itf split by whether their
+// arguments can be passed through the public header of a ...PimplProxy
+// class. The named structs and enums are defined in the proxy header, and
+// the types from libchrome, brillo and dbus are not ABI-stable, so the
+// methods using them are not exposed.
+func makePimplMethods(style serviceconfig.NamingStyle, itf introspect.Interface) (pimplMethods, error) {
+	var ret pimplMethods
+	for _, m := range itf.Methods {
+		in, err := makeMethodParams(style, 0, m.InputArguments())
+		if err != nil {
+			return pimplMethods{}, err
+		}
+		out, err := makeMethodParams(style, len(m.InputArguments()), m.OutputArguments())
+		if err != nil {
+			return pimplMethods{}, err
+		}
+		if isPimplStable(m, append(in, out...)) {
+			ret.Methods = append(ret.Methods, pimplMethod{Method: m, InParams: in, OutParams: out})
+		} else {
+			ret.Unavailable = append(ret.Unavailable, m.Name)
+		}
+	}
+	return ret, nil
+}
+
+// isPimplStable returns true if the method m, taking params, can be exposed
+// by a ...PimplProxy class.
+func isPimplStable(m introspect.Method, params []param) bool {
+	for _, a := range m.Args {
+		t, _ := a.TypeAnnotation()
+		switch t.Name {
+		case "org.chromium.DBus.Struct.FieldNames", "org.chromium.DBus.Argument.EnumClass",
+			"org.chromium.DBus.Argument.FlagsClass", "org.chromium.DBus.Argument.CppType":
+			return false
+		}
+	}
+	for _, p := range params {
+		if pimplUnstableTypeRE.MatchString(p.Type) {
+			return false
+		}
+	}
+	return true
+}
+
+// makePimplConstructorParams returns the parameters of the constructor of a
+// ...PimplProxy class, which takes the service name and the object path
+// unless they are given by the service config and the introspection.
+func makePimplConstructorParams(serviceName, objectPath string) []string {
+	var ret []string
+	if serviceName == "" {
+		ret = append(ret, "const std::string& service_name")
+	}
+	if objectPath == "" {
+		ret = append(ret, "const std::string& object_path")
+	}
+	return ret
+}
+
+// errPimplBlockingCalls is returned when the pimpl proxies are generated with
+// disable_blocking_calls, as their methods forward to the blocking ones.
+var errPimplBlockingCalls = errors.New("pimpl proxies need the blocking calls; remove disable_blocking_calls from the service config")
+
+// GeneratePimplHeader outputs the public header containing the ...PimplProxy
+// classes into f. The header does not depend on libchrome, brillo nor dbus,
+// so that the classes can be exported from a shared library.
+// outputFilePath is used to make a unique header guard.
+func GeneratePimplHeader(introspects []introspect.Introspection, f io.Writer, outputFilePath string, config serviceconfig.Config) error {
+	f = genutil.NewAPILevelWriter(f, config.TargetAPILevel)
+	if blockingCallsDisabled(introspects, config) {
+		return errPimplBlockingCalls
+	}
+	tmpl, err := cloneTemplates(pimplHeaderTemplates, introspects, config)
+	if err != nil {
+		return err
+	}
+	return tmpl.Execute(f, struct {
+		Introspects []introspect.Introspection
+		HeaderGuard string
+		ServiceName string
+		NamingStyle serviceconfig.NamingStyle
+	}{
+		Introspects: introspects,
+		HeaderGuard: genutil.GenerateHeaderGuard(outputFilePath),
+		ServiceName: config.ServiceName,
+		NamingStyle: config.NamingStyle,
+	})
+}
+
+// GeneratePimplSource outputs the source file defining the ...PimplProxy
+// classes declared in the header at headerFilePath into f. The classes
+// forward the calls to the proxies generated into the header at
+// proxyFilePath.
+func GeneratePimplSource(introspects []introspect.Introspection, f io.Writer, headerFilePath, proxyFilePath string, config serviceconfig.Config) error {
+	f = genutil.NewAPILevelWriter(f, config.TargetAPILevel)
+	if headerFilePath == "" {
+		return errors.New("pimpl proxy header file path is not specified")
+	}
+	if proxyFilePath == "" {
+		return errors.New("proxy file path is not specified")
+	}
+	if blockingCallsDisabled(introspects, config) {
+		return errPimplBlockingCalls
+	}
+	tmpl, err := cloneTemplates(pimplSourceTemplates, introspects, config)
+	if err != nil {
+		return err
+	}
+
+	var omName string
+	if config.ObjectManager != nil {
+		omName = config.ObjectManager.Name
+	}
+
+	return tmpl.Execute(f, struct {
+		Introspects       []introspect.Introspection
+		HeaderFilePath    string
+		ProxyFilePath     string
+		ServiceName       string
+		ObjectManagerName string
+		NamingStyle       serviceconfig.NamingStyle
+	}{
+		Introspects:       introspects,
+		HeaderFilePath:    headerFilePath,
+		ProxyFilePath:     proxyFilePath,
+		ServiceName:       config.ServiceName,
+		ObjectManagerName: omName,
+		NamingStyle:       config.NamingStyle,
+	})
+}
@@ -0,0 +1,305 @@
+// Copyright 2022 The ChromiumOS Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package proxy
+
+import (
+	"bytes"
+	"testing"
+
+	"go.chromium.org/chromiumos/dbusbindings/introspect"
+	"go.chromium.org/chromiumos/dbusbindings/serviceconfig"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var pimplIntrospections = []introspect.Introspection{{
+	Interfaces: []introspect.Interface{{
+		Name: "org.chromium.Test",
+		Methods: []introspect.Method{
+			{
+				Name: "Frobinate",
+				Args: []introspect.MethodArg{
+					{Name: "foo", Type: "i"},
+					{Name: "bar", Type: "s", Direction: "out"},
+				},
+				DocString: "Frobinates the foo.",
+			},
+			{
+				Name: "GetStats",
+				Args: []introspect.MethodArg{
+					{Name: "stats", Type: "a{su}", Direction: "out"},
+				},
+			},
+			{
+				Name: "GetDevice",
+				Args: []introspect.MethodArg{
+					{Name: "path", Type: "o", Direction: "out"},
+				},
+			},
+		},
+	}},
+}}
+
+func TestGeneratePimplHeader(t *testing.T) {
+	out := new(bytes.Buffer)
+	if err := GeneratePimplHeader(pimplIntrospections, out, "/tmp/pimpl.h", serviceconfig.Config{}); err != nil {
+		t.Fatalf("GeneratePimplHeader got error, want nil: %v", err)
+	}
+
+	const want = `// Automatic generation of D-Bus pimpl proxies for:
+//  - org.chromium.Test
+#ifndef ____CHROMEOS_DBUS_BINDING___TMP_PIMPL_H
+#define ____CHROMEOS_DBUS_BINDING___TMP_PIMPL_H
+#include <cstdint>
+#include <map>
+#include <memory>
+#include <string>
+#include <tuple>
+#include <vector>
+
+namespace org {
+namespace chromium {
+
+// Proxy for org::chromium::Test hiding the D-Bus implementation, so that
+// it can be exported from a shared library. It connects to the system bus, and
+// must be used on the thread it is created on.
+class TestPimplProxy {
+ public:
+  TestPimplProxy(
+      const std::string& service_name,
+      const std::string& object_path);
+  TestPimplProxy(const TestPimplProxy&) = delete;
+  TestPimplProxy& operator=(const TestPimplProxy&) = delete;
+  ~TestPimplProxy();
+
+  // Frobinates the foo.
+  bool Frobinate(
+      int32_t in_foo,
+      std::string* out_bar,
+      std::string* error_message = nullptr);
+
+  bool GetStats(
+      std::map<std::string, uint32_t>* out_stats,
+      std::string* error_message = nullptr);
+
+  // The following methods are not available, as their arguments are rendered
+  // with libchrome, brillo or dbus types:
+  //  - GetDevice
+
+ private:
+  struct Impl;
+  std::unique_ptr<Impl> impl_;
+};
+
+}  // namespace chromium
+}  // namespace org
+
+#endif  // ____CHROMEOS_DBUS_BINDING___TMP_PIMPL_H
+`
+	if diff := cmp.Diff(out.String(), want); diff != "" {
+		t.Errorf("GeneratePimplHeader failed (-got +want):\n%s", diff)
+	}
+}
+
+func TestGeneratePimplSource(t *testing.T) {
+	out := new(bytes.Buffer)
+	if err := GeneratePimplSource(pimplIntrospections, out, "test/pimpl.h", "test/proxy.h", serviceconfig.Config{}); err != nil {
+		t.Fatalf("GeneratePimplSource got error, want nil: %v", err)
+	}
+
+	const want = `// Automatic generation of D-Bus pimpl proxies for:
+//  - org.chromium.Test
+#include "test/pimpl.h"
+
+#include <memory>
+#include <string>
+#include <utility>
+
+#include <base/check.h>
+#include <base/memory/ref_counted.h>
+#include <brillo/errors/error.h>
+#include <dbus/bus.h>
+#include <dbus/object_path.h>
+
+#include "test/proxy.h"
+
+namespace {
+
+scoped_refptr<dbus::Bus> ConnectToSystemBus() {
+  dbus::Bus::Options options;
+  options.bus_type = dbus::Bus::SYSTEM;
+  auto bus = base::MakeRefCounted<dbus::Bus>(std::move(options));
+  CHECK(bus->Connect()) << "Failed to connect to the system bus";
+  return bus;
+}
+
+void SetErrorMessage(const brillo::Error* error, std::string* error_message) {
+  if (error && error_message)
+    *error_message = error->GetMessage();
+}
+
+}  // namespace
+
+namespace org {
+namespace chromium {
+
+struct TestPimplProxy::Impl {
+  Impl(scoped_refptr<dbus::Bus> bus_in,
+       const std::string& service_name,
+       const std::string& object_path)
+      : bus{std::move(bus_in)},
+        proxy{bus, service_name, dbus::ObjectPath{object_path}} {}
+
+  ~Impl() { bus->ShutdownAndBlock(); }
+
+  scoped_refptr<dbus::Bus> bus;
+  TestProxy proxy;
+};
+
+TestPimplProxy::TestPimplProxy(
+    const std::string& service_name,
+    const std::string& object_path)
+    : impl_{std::make_unique<Impl>(ConnectToSystemBus(), service_name, object_path)} {}
+
+TestPimplProxy::~TestPimplProxy() = default;
+
+bool TestPimplProxy::Frobinate(
+    int32_t in_foo,
+    std::string* out_bar,
+    std::string* error_message) {
+  brillo::ErrorPtr error;
+  if (impl_->proxy.Frobinate(in_foo, out_bar, &error))
+    return true;
+  SetErrorMessage(error.get(), error_message);
+  return false;
+}
+
+bool TestPimplProxy::GetStats(
+    std::map<std::string, uint32_t>* out_stats,
+    std::string* error_message) {
+  brillo::ErrorPtr error;
+  if (impl_->proxy.GetStats(out_stats, &error))
+    return true;
+  SetErrorMessage(error.get(), error_message);
+  return false;
+}
+
+}  // namespace chromium
+}  // namespace org
+`
+	if diff := cmp.Diff(out.String(), want); diff != "" {
+		t.Errorf("GeneratePimplSource failed (-got +want):\n%s", diff)
+	}
+}
+
+func TestGeneratePimplSourceWithServiceName(t *testing.T) {
+	introspections := []introspect.Introspection{{
+		Name: "/org/chromium/Test",
+		Interfaces: []introspect.Interface{{
+			Name: "org.chromium.Test",
+			Methods: []introspect.Method{{
+				Name: "Reset",
+			}},
+			Properties: []introspect.Property{{
+				Name: "Enabled", Type: "b", Access: "read",
+			}},
+		}},
+	}}
+	config := serviceconfig.Config{
+		ServiceName: "org.chromium.TestService",
+		ObjectManager: &serviceconfig.ObjectManagerConfig{
+			Name: "org.chromium.TestService.ObjectManager",
+		},
+	}
+
+	out := new(bytes.Buffer)
+	if err := GeneratePimplSource(introspections, out, "test/pimpl.h", "test/proxy.h", config); err != nil {
+		t.Fatalf("GeneratePimplSource got error, want nil: %v", err)
+	}
+
+	const want = `// Automatic generation of D-Bus pimpl proxies for:
+//  - org.chromium.Test
+#include "test/pimpl.h"
+
+#include <memory>
+#include <string>
+#include <utility>
+
+#include <base/check.h>
+#include <base/functional/callback_helpers.h>
+#include <base/memory/ref_counted.h>
+#include <brillo/errors/error.h>
+#include <dbus/bus.h>
+#include <dbus/object_path.h>
+
+#include "test/proxy.h"
+
+namespace {
+
+scoped_refptr<dbus::Bus> ConnectToSystemBus() {
+  dbus::Bus::Options options;
+  options.bus_type = dbus::Bus::SYSTEM;
+  auto bus = base::MakeRefCounted<dbus::Bus>(std::move(options));
+  CHECK(bus->Connect()) << "Failed to connect to the system bus";
+  return bus;
+}
+
+void SetErrorMessage(const brillo::Error* error, std::string* error_message) {
+  if (error && error_message)
+    *error_message = error->GetMessage();
+}
+
+}  // namespace
+
+namespace org {
+namespace chromium {
+
+struct TestPimplProxy::Impl {
+  explicit Impl(scoped_refptr<dbus::Bus> bus_in)
+      : bus{std::move(bus_in)},
+        property_set{bus->GetObjectProxy("org.chromium.TestService",
+                                         dbus::ObjectPath{"/org/chromium/Test"}),
+                     base::DoNothing()},
+        proxy{bus, &property_set} {}
+
+  ~Impl() { bus->ShutdownAndBlock(); }
+
+  scoped_refptr<dbus::Bus> bus;
+  TestProxy::PropertySet property_set;
+  TestProxy proxy;
+};
+
+TestPimplProxy::TestPimplProxy()
+    : impl_{std::make_unique<Impl>(ConnectToSystemBus())} {}
+
+TestPimplProxy::~TestPimplProxy() = default;
+
+bool TestPimplProxy::Reset(
+    std::string* error_message) {
+  brillo::ErrorPtr error;
+  if (impl_->proxy.Reset(&error))
+    return true;
+  SetErrorMessage(error.get(), error_message);
+  return false;
+}
+
+}  // namespace chromium
+}  // namespace org
+`
+	if diff := cmp.Diff(out.String(), want); diff != "" {
+		t.Errorf("GeneratePimplSource failed (-got +want):\n%s", diff)
+	}
+}
+
+func TestGeneratePimplWithoutBlockingCalls(t *testing.T) {
+	sc := serviceconfig.Config{DisableBlockingCalls: true}
+	out := new(bytes.Buffer)
+	if err := GeneratePimplHeader(pimplIntrospections, out, "/tmp/pimpl.h", sc); err != errPimplBlockingCalls {
+		t.Errorf("GeneratePimplHeader with disable_blocking_calls got error %v, want %v", err, errPimplBlockingCalls)
+	}
+	if err := GeneratePimplSource(pimplIntrospections, out, "pimpl.h", "proxy.h", sc); err != errPimplBlockingCalls {
+		t.Errorf("GeneratePimplSource with disable_blocking_calls got error %v, want %v", err, errPimplBlockingCalls)
+	}
+}
@@ -6,6 +6,7 @@
 package proxy
 
 import (
+	"errors"
 	"io"
 	"strings"
 	"text/template"
@@ -18,17 +19,69 @@ import (
 var funcMap = template.FuncMap{
 	"add":                             func(a, b int) int { return a + b },
 	"extractInterfacesWithProperties": extractInterfacesWithProperties,
-	"extractNameSpaces":               genutil.ExtractNameSpaces,
 	"formatComment":                   genutil.FormatComment,
-	"makeFullItfName":                 genutil.MakeFullItfName,
-	"makeFullProxyName":               genutil.MakeFullProxyName,
-	"makeFullProxyInterfaceName":      genutil.MakeFullProxyInterfaceName,
+	"hasAsyncOverloads":               hasAsyncOverloads,
+	"hasDefaultValues":                hasDefaultValues,
+	"hasFileDescriptorInput":          hasFileDescriptorInput,
+	"hasInterfaceOverloads":           hasInterfaceOverloads,
+	"anyLightweightProperties":        anyLightweightProperties,
+	"hasFDStream":                     hasFDStream,
+	"hasNoReplyMethods":               hasNoReplyMethods,
+	"hasMethodErrors":                 hasMethodErrors,
+	"hasConstProperties":              hasConstProperties,
+	"hasInvalidatedProperties":        hasInvalidatedProperties,
+	"hasLightweightProperties":        hasLightweightProperties,
+	"hasNamedStructs":                 hasNamedStructs,
+	"hasNamedFlags":                   hasNamedFlags,
+	"hasOptionalArgs":                 genutil.HasOptionalArgs,
+	"hasPropertyChangedCallback":      hasPropertyChangedCallback,
+	"hasPropertySet":                  hasPropertySet,
+	"hasRawSignals":                   hasRawSignals,
+	"hasSignals":                      hasSignals,
+	"isPropertyFetchedAlways":         isPropertyFetchedAlways,
+	"isPropertyConst":                 isPropertyConst,
+	"isPropertyFetchedOnce":           isPropertyFetchedOnce,
+	"isPropertyInvalidated":           isPropertyInvalidated,
+	"isPropertyPolled":                isPropertyPolled,
+	"isPropertyVariantDict":           isPropertyVariantDict,
+	"hasVariantTypes":                 hasVariantTypes,
+	"usesProtobuf":                    genutil.UsesProtobuf,
+	"usesTypeHeader":                  genutil.UsesTypeHeader,
+	"interfaceHasFDStream":            interfaceHasFDStream,
+	"isRawSignal":                     isRawSignal,
+	"makeArgComments":                 makeArgComments,
+	"unnamedArgNames":                 genutil.UnnamedArgNames,
+	"makeConstantName":                genutil.MakeConstantName,
+	"makeAwaitableType":               makeAwaitableType,
+	"makeCompileTestCall":             makeCompileTestCall,
+	"makeDefaultArgOverloads":         makeDefaultArgOverloads,
+	"makeMoveOverload":                makeMoveOverload,
+	"makeExpectedResult":              makeExpectedResult,
 	"makeMethodParams":                makeMethodParams,
 	"makeMethodCallbackType":          makeMethodCallbackType,
+	"makeMethodErrors":                makeMethodErrors,
 	"makeMockMethodParams":            makeMockMethodParams,
+	"makeNoBrilloMethod":              makeNoBrilloMethod,
+	"makeNoBrilloSignal":              makeNoBrilloSignal,
+	"makeNamedEnums":                  genutil.MakeNamedEnums,
+	"makeObjectManagerTypeNames":      makeObjectManagerTypeNames,
+	"makeNamedStructs":                genutil.MakeNamedStructs,
+	"makeNamedFlags":                  genutil.MakeNamedFlags,
+	"makePimplConstructorParams":      makePimplConstructorParams,
+	"makePimplMethods":                makePimplMethods,
+	"makeProtobufClasses":             genutil.MakeProtobufClasses,
+	"makeProtobufIncludes":            makeProtobufIncludes,
 	"makeProxyInterfaceArgs":          makeProxyInterfaceArgs,
-	"makeProxyInterfaceName":          genutil.MakeProxyInterfaceName,
-	"makeProxyName":                   genutil.MakeProxyName,
+	"makeProxyFactory":                makeProxyFactory,
+	"makeMethodBodyArgs":              makeMethodBodyArgs,
+	"outdent":                         outdent,
+	// include is bound to the cloned templates by bindInclude.
+	"include": func(string, interface{}) (string, error) {
+		return "", errors.New("include is not bound")
+	},
+	"makePropertyAccessors":  makePropertyAccessors,
+	"makeProxyInterfaceName": genutil.MakeProxyInterfaceName,
+	"makeProxyName":          genutil.MakeProxyName,
 	"makePropertyVariableName": func(p *introspect.Property) string {
 		return p.VariableName()
 	},
@@ -38,10 +91,15 @@ var funcMap = template.FuncMap{
 	"makeProxyInArgTypeProxy": func(p *introspect.Property) (string, error) {
 		return p.InArgType()
 	},
-	"makeSignalCallbackType": makeSignalCallbackType,
-	"makeTypeName":           genutil.MakeTypeName,
-	"makeVariableName":       genutil.MakeVariableName,
-	"nindent":                genutil.Nindent,
+	"makeSignalCallbackAlias": makeSignalCallbackAlias,
+	"makeSignalCallbackType":  makeSignalCallbackType,
+	"makeSignalParamTypes":    makeSignalParamTypes,
+	"makeSignalSenderParams":  makeSignalSenderParams,
+	"makeTypeName":            genutil.MakeTypeName,
+	"makeVariableName":        genutil.MakeVariableName,
+	"makeVariantChecks":       makeVariantChecks,
+	"makeVariantOverload":     makeVariantOverload,
+	"nindent":                 genutil.Nindent,
 	"trimLeft": func(cutset, s string) string {
 		// Swap the args to fit with template's context.
 		return strings.TrimLeft(s, cutset)
@@ -50,34 +108,218 @@ var funcMap = template.FuncMap{
 	"reverse": genutil.Reverse,
 }
 
+// instrumentationTemplate defines the helper logging the proxy method calls
+// when instrument_proxies is set. It is guarded so that multiple generated
+// headers can define it.
+const instrumentationTemplate = `{{define "instrumentation" -}}
+#ifndef CHROMEOS_DBUS_BINDINGS_DBUS_INSTRUMENTATION_
+#define CHROMEOS_DBUS_BINDINGS_DBUS_INSTRUMENTATION_
+namespace chromeos_dbus_bindings {
+
+// Logs the result of the proxy method call |method_name| started at
+// |start_time|, together with its duration.
+inline void LogMethodCall(const char* method_name,
+                          base::TimeTicks start_time,
+                          bool success) {
+  VLOG(1) << "D-Bus method call " << method_name
+          << (success ? " succeeded" : " failed") << " in "
+          << (base::TimeTicks::Now() - start_time);
+}
+
+}  // namespace chromeos_dbus_bindings
+#endif  // CHROMEOS_DBUS_BINDINGS_DBUS_INSTRUMENTATION_
+{{- end}}`
+
+// metricsTemplate defines the interface receiving the results and the
+// latencies of the proxy method calls when report_metrics is set. It is
+// guarded so that multiple generated headers can define it.
+const metricsTemplate = `{{define "metrics" -}}
+#ifndef CHROMEOS_DBUS_BINDINGS_DBUS_METRICS_
+#define CHROMEOS_DBUS_BINDINGS_DBUS_METRICS_
+namespace chromeos_dbus_bindings {
+
+// Receives the result of each proxy method call, e.g. to report it to UMA.
+class MetricsRecorder {
+ public:
+  virtual ~MetricsRecorder() = default;
+
+  // Called when the proxy method call |method_name| completes.
+  virtual void RecordMethodCall(const char* method_name,
+                                bool success,
+                                base::TimeDelta latency) = 0;
+};
+
+// Records the result of the proxy method call |method_name| started at
+// |start_time| into |recorder|, if any.
+inline void RecordMethodCall(MetricsRecorder* recorder,
+                             const char* method_name,
+                             base::TimeTicks start_time,
+                             bool success) {
+  if (recorder) {
+    recorder->RecordMethodCall(method_name, success,
+                               base::TimeTicks::Now() - start_time);
+  }
+}
+
+}  // namespace chromeos_dbus_bindings
+#endif  // CHROMEOS_DBUS_BINDINGS_DBUS_METRICS_
+{{- end}}`
+
 const (
-	templateText = `// Automatic generation of D-Bus interfaces:
+	proxyHeaderTemplate = `{{define "proxyHeader" -}}// Automatic generation of D-Bus interfaces:
 {{range .Introspects}}{{range .Interfaces -}}
 //  - {{.Name}}
 {{end}}{{end -}}
 
-#ifndef {{.HeaderGuard}}
-#define {{.HeaderGuard}}
+{{if .ModuleName}}module;{{else}}#ifndef {{.HeaderGuard}}
+#define {{.HeaderGuard}}{{end}}
+{{- if .ResilientProxy}}
+#include <algorithm>
+{{- end}}
+{{- if .UseCoroutines}}
+#include <coroutine>
+{{- end}}
 #include <memory>
+{{- if or (hasOptionalArgs .Introspects) .ObjectManagerName .CredentialQueries}}
+#include <optional>
+{{- end}}
 #include <string>
+{{- if .UseCoroutines}}
+#include <tuple>
+{{- end}}
+{{- if or .UseCoroutines (usesProtobuf .Introspects)}}
+#include <type_traits>
+{{- end}}
+{{- if .UseCoroutines}}
+#include <utility>
+{{- end}}
+{{- if hasVariantTypes .Introspects}}
+#include <variant>
+{{- end}}
 #include <vector>
 
+{{if hasFDStream .Introspects -}}
+#include <base/files/file_util.h>
+{{end -}}
+{{if or (hasFDStream .Introspects) (usesTypeHeader .Introspects "<base/files/scoped_file.h>") -}}
 #include <base/files/scoped_file.h>
+{{end -}}
 #include <base/functional/bind.h>
 #include <base/functional/callback.h>
+{{- if or .ClientFactoryName (hasInvalidatedProperties .Introspects) (hasNoReplyMethods .Introspects)}}
+#include <base/functional/callback_helpers.h>
+{{- end}}
+{{- if .ResilientProxy}}
+#include <base/functional/function_ref.h>
+{{- end}}
+{{- if or .ResilientProxy .ObjectManagerName (hasVariantTypes .Introspects)}}
+#include <base/location.h>
+{{- end}}
 #include <base/logging.h>
 #include <base/memory/ref_counted.h>
+{{- if .ProxyFactories}}
+#include <base/no_destructor.h>
+{{- end}}
+{{- if or (hasSignals .Introspects) .RestartCallbacks .QueueingProxy}}
+#include <base/memory/weak_ptr.h>
+{{- end}}
+{{- if .SequenceCheckers}}
+#include <base/sequence_checker.h>
+{{- end}}
+{{- if or .ResilientProxy .ObjectManagerName}}
+#include <base/task/sequenced_task_runner.h>
+{{- end}}
+{{- if .ResilientProxy}}
+#include <base/threading/platform_thread.h>
+{{- end}}
+{{- if or .ResilientProxy .ObjectManagerName .InstrumentProxies .ReportMetrics}}
+#include <base/time/time.h>
+{{- end}}
+{{- if .InstrumentProxies}}
+#include <base/trace_event/trace_event.h>
+{{- end}}
+{{- if .ExpectedResults}}
+#include <base/types/expected.h>
+{{- end}}
+{{- if or (hasVariantTypes .Introspects) (hasConstProperties .Introspects) (usesTypeHeader .Introspects "<brillo/any.h>")}}
 #include <brillo/any.h>
+{{- end}}
 #include <brillo/dbus/dbus_method_invoker.h>
+{{- if and .SignalSenders (hasSignals .Introspects)}}
+#include <brillo/dbus/dbus_param_writer.h>
+{{- end}}
 #include <brillo/dbus/dbus_property.h>
 #include <brillo/dbus/dbus_signal_handler.h>
 #include <brillo/errors/error.h>
+{{- if or (hasMethodErrors .Introspects) .ResilientProxy (hasVariantTypes .Introspects)}}
+#include <brillo/errors/error_codes.h>
+{{- end}}
+{{- if or .ObjectManagerName (anyLightweightProperties .Introspects) (usesTypeHeader .Introspects "<brillo/variant_dictionary.h>")}}
 #include <brillo/variant_dictionary.h>
+{{- end}}
 #include <dbus/bus.h>
 #include <dbus/message.h>
 #include <dbus/object_manager.h>
 #include <dbus/object_path.h>
 #include <dbus/object_proxy.h>
+{{- if hasConstProperties .Introspects}}
+#include <dbus/property.h>
+{{- end}}
+{{- if usesProtobuf .Introspects}}
+#include <google/protobuf/message_lite.h>
+{{- end}}
+{{- with makeProtobufIncludes .Introspects}}
+{{range .}}
+#include {{.}}
+{{- end}}
+{{- end}}
+{{- with .TypeMappingIncludes}}
+{{range .}}
+#include {{.}}
+{{- end}}
+{{- end}}
+{{- if .SharedProxyFilePath}}
+
+#include "{{.SharedProxyFilePath}}"
+{{- end}}
+{{- if .ModuleName}}
+
+export module {{.ModuleName}};
+
+export {
+{{- end}}
+{{- if hasOptionalArgs .Introspects}}
+
+{{template "optional"}}
+{{- end}}
+{{- if usesProtobuf .Introspects}}
+
+{{template "protobufClassCheck"}}
+{{- end}}
+{{- if hasVariantTypes .Introspects}}
+
+{{template "variantTypes"}}
+{{- end}}
+{{- if .UseCoroutines}}
+
+{{template "awaitable"}}
+{{- end}}
+{{- if .ResilientProxy}}
+
+{{template "retry"}}
+{{- end}}
+{{- if .CredentialQueries}}
+
+{{template "credentials"}}
+{{- end}}
+{{- if .InstrumentProxies}}
+
+{{template "instrumentation"}}
+{{- end}}
+{{- if .ReportMetrics}}
+
+{{template "metrics"}}
+{{- end}}
 {{if .ObjectManagerName}}
 {{range extractNameSpaces .ObjectManagerName -}}
 namespace {{.}} {
@@ -86,10 +328,12 @@ class {{makeProxyName .ObjectManagerName}};
 {{range extractNameSpaces .ObjectManagerName | reverse -}}
 }  // namespace {{.}}
 {{end}}
-{{- end}}
-{{- range $introspect := .Introspects}}{{range $itf := .Interfaces -}}
+{{- end}}{{end}}`
+
+	proxyTemplate = `{{define "proxy"}}{{$introspect := .Introspect}}{{with $itf := .Itf -}}
 {{- $itfName := makeProxyInterfaceName .Name}}
-{{template "proxyInterface" (makeProxyInterfaceArgs . $.ObjectManagerName) }}
+{{- $factory := makeProxyFactory $.ProxyFactories $.ServiceName $.ObjectManagerName $introspect .}}
+{{template "proxyInterface" (makeProxyInterfaceArgs . $.ObjectManagerName $.NamingStyle $.UseCoroutines $.MoveProtobufResponses $.ExpectedResults $.DisableBlockingCalls false $factory) }}
 {{range extractNameSpaces .Name -}}
 namespace {{.}} {
 {{end}}
@@ -98,7 +342,7 @@ namespace {{.}} {
 {{- $proxyName := makeProxyName .Name -}}
 class {{$proxyName}} final : public {{$itfName}} {
  public:
-{{- if (or $.ObjectManagerName .Properties) }}
+{{- if (or $.ObjectManagerName (hasPropertySet .)) }}
   class PropertySet : public dbus::PropertySet {
    public:
     PropertySet(dbus::ObjectProxy* object_proxy,
@@ -107,22 +351,26 @@ class {{$proxyName}} final : public {{$itfName}} {
                             "{{.Name}}",
                             callback} {
 {{- range .Properties}}
+{{- if not (isPropertyConst .)}}
 {{- $name := makePropertyVariableName . | makeVariableName}}
       RegisterProperty({{.Name}}Name(), &{{$name}});
+{{- end}}
 {{- end}}
     }
     PropertySet(const PropertySet&) = delete;
     PropertySet& operator=(const PropertySet&) = delete;
 {{range .Properties}}
+{{- if not (isPropertyConst .)}}
 {{- $name := makePropertyVariableName . | makeVariableName}}
     brillo::dbus_utils::Property<{{makePropertyBaseTypeExtract .}}> {{$name}};
+{{- end}}
 {{- end}}
 
   };
 {{end}}
 
 {{- /* TODO(crbug.com/983008): Simplify the format into Chromium style. */ -}}
-{{- if and $.ServiceName $introspect.Name (or (not $.ObjectManagerName) (not .Properties))}}
+{{- if and $.ServiceName $introspect.Name (or (not $.ObjectManagerName) (not .Properties)) (not $.ReportMetrics)}}
   {{$proxyName}}(const scoped_refptr<dbus::Bus>& bus) :
       bus_{bus},
       dbus_object_proxy_{
@@ -139,6 +387,9 @@ class {{$proxyName}} final : public {{$itfName}} {
 {{- end}}
 {{- if and $.ObjectManagerName .Properties}},
       PropertySet* property_set
+{{- end}}
+{{- if $.ReportMetrics}},
+      chromeos_dbus_bindings::MetricsRecorder* metrics_recorder = nullptr
 {{- end}}) :
           bus_{bus},
 {{- if not $.ServiceName}}
@@ -149,11 +400,61 @@ class {{$proxyName}} final : public {{$itfName}} {
 {{- end}}
 {{- if and $.ObjectManagerName .Properties}}
           property_set_{property_set},
+{{- end}}
+{{- if $.ReportMetrics}}
+          metrics_recorder_{metrics_recorder},
 {{- end}}
           dbus_object_proxy_{
               bus_->GetObjectProxy(service_name_, object_path_)} {
   }
 {{- end}}
+{{- if and (hasPropertySet .) (not $.ObjectManagerName)}}
+
+  // How the constructor initializes the properties.
+  enum class InitializePropertiesPolicy {
+    // Creates the property set and connects the PropertiesChanged signal,
+    // leaving the values to be fetched on demand.
+    kLazy,
+    // Does what InitializeProperties() does, i.e. also fetches all the values.
+    kEager,
+    // Leaves the properties uninitialized, as the other constructor does.
+    kNone,
+  };
+
+  {{$proxyName}}(
+      const scoped_refptr<dbus::Bus>& bus,
+{{- if not $.ServiceName}}
+      const std::string& service_name,
+{{- end}}
+{{- if not $introspect.Name}}
+      const dbus::ObjectPath& object_path,
+{{- end}}
+      InitializePropertiesPolicy policy,
+      const base::RepeatingCallback<void({{$itfName}}*, const std::string&)>& callback
+{{- if $.ReportMetrics}},
+      chromeos_dbus_bindings::MetricsRecorder* metrics_recorder = nullptr
+{{- end}}) :
+          {{$proxyName}}(bus
+{{- if not $.ServiceName}}, service_name{{end}}
+{{- if not $introspect.Name}}, object_path{{end}}
+{{- if $.ReportMetrics}}, metrics_recorder{{end}}) {
+    switch (policy) {
+      case InitializePropertiesPolicy::kLazy:
+        on_property_changed_ = callback;
+        property_set_.reset(
+            new PropertySet(dbus_object_proxy_,
+                            base::BindRepeating(&{{$proxyName}}::OnPropertyChanged,
+                                                base::Unretained(this))));
+        property_set_->ConnectSignals();
+        break;
+      case InitializePropertiesPolicy::kEager:
+        InitializeProperties(callback);
+        break;
+      case InitializePropertiesPolicy::kNone:
+        break;
+    }
+  }
+{{- end}}
 
   {{$proxyName}}(const {{$proxyName}}&) = delete;
   {{$proxyName}}& operator=(const {{$proxyName}}&) = delete;
@@ -163,14 +464,25 @@ class {{$proxyName}} final : public {{$itfName}} {
 {{- range .Signals}}
 
   void Register{{.Name}}SignalHandler(
-      {{- makeSignalCallbackType .Args | nindent 6}} signal_callback,
+      {{- makeSignalCallbackType . | nindent 6}} signal_callback,
       dbus::ObjectProxy::OnConnectedCallback on_connected_callback) override {
+{{- if $.SequenceCheckers}}
+    DCHECK_CALLED_ON_VALID_SEQUENCE(sequence_checker_);
+{{- end}}
+{{- if isRawSignal .}}
+    dbus_object_proxy_->ConnectToSignal(
+        "{{$itf.Name}}",
+        "{{.Name}}",
+        signal_callback,
+        std::move(on_connected_callback));
+{{- else}}
     brillo::dbus_utils::ConnectToSignal(
         dbus_object_proxy_,
         "{{$itf.Name}}",
         "{{.Name}}",
         signal_callback,
         std::move(on_connected_callback));
+{{- end}}
   }
 {{- end}}
 
@@ -185,19 +497,70 @@ class {{$proxyName}} final : public {{$itfName}} {
   dbus::ObjectProxy* GetObjectProxy() const override {
     return dbus_object_proxy_;
   }
+{{- if $.RestartCallbacks}}
 
-{{- if .Properties}}
+  // Runs |callback| once the service becomes available, with false if
+  // waiting for it times out.
+  void SetServiceAvailableCallback(
+      dbus::ObjectProxy::WaitForServiceToBeAvailableCallback callback) {
+{{- if $.SequenceCheckers}}
+    DCHECK_CALLED_ON_VALID_SEQUENCE(sequence_checker_);
+{{- end}}
+    dbus_object_proxy_->WaitForServiceToBeAvailable(std::move(callback));
+  }
+
+  // Runs |callback| with the old and the new owners of the service name
+  // whenever it changes, i.e. when the service stops or restarts. The signal
+  // handlers stay connected across the restarts.
+{{- if and (hasPropertySet .) (not $.ObjectManagerName)}}
+  // The properties are fetched again when the service restarts.
+{{- end}}
+  void SetNameOwnerChangedCallback(
+      const base::RepeatingCallback<void(const std::string&, const std::string&)>& callback) {
+{{- if $.SequenceCheckers}}
+    DCHECK_CALLED_ON_VALID_SEQUENCE(sequence_checker_);
+{{- end}}
+    dbus_object_proxy_->SetNameOwnerChangedCallback(
+        base::BindRepeating(&{{$proxyName}}::OnNameOwnerChanged,
+                            weak_ptr_factory_.GetWeakPtr(), callback));
+  }
+{{- end}}
+{{- if $.CredentialQueries}}
+
+  // Runs |callback| with the Unix user ID and the process ID of the current
+  // owner of the service name, or with std::nullopt if the bus cannot tell,
+  // e.g. when the service is not running.
+  void GetConnectionCredentials(
+      base::OnceCallback<void(std::optional<chromeos_dbus_bindings::ConnectionCredentials>)> callback) {
+{{- if $.SequenceCheckers}}
+    DCHECK_CALLED_ON_VALID_SEQUENCE(sequence_checker_);
+{{- end}}
+    chromeos_dbus_bindings::GetConnectionCredentials(bus_, service_name_,
+                                                     std::move(callback));
+  }
+{{- end}}
+
+{{- if hasPropertySet .}}
 {{if $.ObjectManagerName}}
   void SetPropertyChangedCallback(
       const base::RepeatingCallback<void({{$itfName}}*, const std::string&)>& callback) override {
+{{- if $.SequenceCheckers}}
+    DCHECK_CALLED_ON_VALID_SEQUENCE(sequence_checker_);
+{{- end}}
     on_property_changed_ = callback;
   }
 {{- else}}
   void InitializeProperties(
       const base::RepeatingCallback<void({{$itfName}}*, const std::string&)>& callback) override {
+{{- if $.SequenceCheckers}}
+    DCHECK_CALLED_ON_VALID_SEQUENCE(sequence_checker_);
+{{- end}}
 {{- /* TODO(crbug.com/983008): Use std::make_unique. */}}
+    on_property_changed_ = callback;
     property_set_.reset(
-        new PropertySet(dbus_object_proxy_, base::BindRepeating(callback, this)));
+        new PropertySet(dbus_object_proxy_,
+                        base::BindRepeating(&{{$proxyName}}::OnPropertyChanged,
+                                            base::Unretained(this))));
     property_set_->ConnectSignals();
     property_set_->GetAll();
   }
@@ -205,12 +568,29 @@ class {{$proxyName}} final : public {{$itfName}} {
 
   const PropertySet* GetProperties() const { return &(*property_set_); }
   PropertySet* GetProperties() { return &(*property_set_); }
+{{- else if hasLightweightProperties .}}
+
+  void ConnectPropertiesChangedSignal(
+      dbus::ObjectProxy::OnConnectedCallback on_connected_callback) override {
+{{- if $.SequenceCheckers}}
+    DCHECK_CALLED_ON_VALID_SEQUENCE(sequence_checker_);
+{{- end}}
+    brillo::dbus_utils::ConnectToSignal(
+        dbus_object_proxy_,
+        "org.freedesktop.DBus.Properties",
+        "PropertiesChanged",
+        base::BindRepeating(&{{$proxyName}}::OnPropertiesChanged,
+                            base::Unretained(this)),
+        std::move(on_connected_callback));
+  }
 {{- end}}
 
 {{- range .Methods}}
-{{- $inParams := makeMethodParams 0 .InputArguments -}}
-{{- $outParams := makeMethodParams (len .InputArguments) .OutputArguments}}
+{{- $inParams := makeMethodParams $.NamingStyle 0 .InputArguments -}}
+{{- $outParams := makeMethodParams $.NamingStyle (len .InputArguments) .OutputArguments}}
+{{- $body := makeMethodBodyArgs $ . $inParams $outParams}}
 
+{{if not $.DisableBlockingCalls -}}
 {{formatComment .DocString 2 -}}
 {{"  "}}bool {{.Name}}(
 {{- range $inParams }}
@@ -220,69 +600,186 @@ class {{$proxyName}} final : public {{$itfName}} {
       {{.Type}} {{.Name}},
 {{- end}}
       brillo::ErrorPtr* error,
-      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override {
-    auto response = brillo::dbus_utils::CallMethodAndBlockWithTimeout(
-        timeout_ms,
-        dbus_object_proxy_,
-        "{{$itf.Name}}",
-        "{{.Name}}",
-        error
-{{- range $inParams }},
-        {{.Name}}
-{{- end}});
-    return response && brillo::dbus_utils::ExtractMethodCallResults(
-        response.get(), error{{range $i, $param := $outParams}}, {{.Name}}{{end}});
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override
+{{- if $.SplitSource}};{{else}} {
+{{- template "blockingMethodBody" $body}}
   }
+{{- end}}
 
+{{end -}}
 {{formatComment .DocString 2 -}}
 {{"  "}}void {{.Name}}Async(
 {{- range $inParams}}
       {{.Type}} {{.Name}},
 {{- end}}
-      {{makeMethodCallbackType .OutputArguments}} success_callback,
+      {{makeMethodCallbackType $.NamingStyle $.MoveProtobufResponses .OutputArguments}} success_callback,
       base::OnceCallback<void(brillo::Error*)> error_callback,
-      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override {
-    brillo::dbus_utils::CallMethodWithTimeout(
-        timeout_ms,
-        dbus_object_proxy_,
-        "{{$itf.Name}}",
-        "{{.Name}}",
-        std::move(success_callback),
-        std::move(error_callback)
-{{- range $inParams}},
-        {{.Name}}
-{{- end}});
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override
+{{- if $.SplitSource}};{{else}} {
+{{- template "asyncMethodBody" $body}}
+  }
+{{- end}}
+{{- if and .IncludeDBusMessage (not .NoReply) (not $.DisableBlockingCalls)}}
+
+  // Calls {{.Name}}() and returns the response message, e.g. to inspect its
+  // sender, or nullptr on failure. The output arguments can be extracted with
+  // brillo::dbus_utils::ExtractMethodCallResults().
+  std::unique_ptr<dbus::Response> {{.Name}}WithMessage(
+{{- range $inParams }}
+      {{.Type}} {{.Name}},
+{{- end}}
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT)
+{{- if $.SplitSource}};{{else}} {
+{{- template "withMessageMethodBody" $body}}
   }
+{{- end}}
+{{- end}}
 
 {{- end}}
 
 {{- range .Properties}}
 {{- $name := makePropertyVariableName . | makeVariableName -}}
+{{- $accessors := makePropertyAccessors $.NamingStyle . -}}
 {{- $type := makeProxyInArgTypeProxy . }}
+{{- if hasPropertySet $itf}}
+{{- if isPropertyConst .}}
+{{- $baseType := makePropertyBaseTypeExtract .}}
+
+  {{$type}} {{$accessors.Getter}}() const override {
+    // {{.Name}} never changes, so it is fetched once instead of being
+    // tracked by the property set.
+    if (!{{$name}}_fetched_) {
+      brillo::ErrorPtr error;
+      auto response = brillo::dbus_utils::CallMethodAndBlock(
+          dbus_object_proxy_, dbus::kPropertiesInterface, dbus::kPropertiesGet,
+          &error, std::string(kInterfaceName), std::string({{.Name}}Name()));
+      brillo::Any value;
+      if (response &&
+          brillo::dbus_utils::ExtractMethodCallResults(response.get(), &error, &value) &&
+          value.IsTypeCompatible<{{$baseType}}>()) {
+        {{$name}}_value_ = value.Get<{{$baseType}}>();
+        {{$name}}_fetched_ = true;
+      }
+    }
+    return {{$name}}_value_;
+  }
+
+  bool {{$accessors.Validator}}() const override {
+    return {{$name}}_fetched_;
+  }
+{{- else if .Readable}}
 
-  {{$type}} {{$name}}() const override {
+  {{$type}} {{$accessors.Getter}}() const override {
+{{- if isPropertyFetchedAlways .}}
+    property_set_->{{$name}}.GetAndBlock();
+{{- else if isPropertyFetchedOnce .}}
+    if (!property_set_->{{$name}}.is_valid())
+      property_set_->{{$name}}.GetAndBlock();
+{{- end}}
     return property_set_->{{$name}}.value();
   }
 
-  bool is_{{$name}}_valid() const override {
+  bool {{$accessors.Validator}}() const override {
     return property_set_->{{$name}}.is_valid();
   }
-{{- if eq .Access "readwrite"}}
+{{- end}}
+{{- if .Writable}}
 
-  void set_{{$name}}({{$type}} value,
-           {{repeat " " (len $name)}} base::OnceCallback<void(bool)> callback) override {
+  void {{$accessors.Setter}}({{$type}} value,
+       {{repeat " " (len $accessors.Setter)}} base::OnceCallback<void(bool)> callback) override {
     property_set_->{{$name}}.Set(value, std::move(callback));
   }
 {{- end}}
+{{- end}}
+{{- if hasPropertyChangedCallback .}}
+
+  void {{$accessors.ChangedCallbackSetter}}(
+      const base::RepeatingCallback<void({{$type}})>& callback) override {
+{{- if $.SequenceCheckers}}
+    DCHECK_CALLED_ON_VALID_SEQUENCE(sequence_checker_);
+{{- end}}
+    on_{{$name}}_changed_ = callback;
+  }
+{{- end}}
+{{- end}}
+{{- range .Methods}}
+{{- if hasAsyncOverloads .}}
+{{if hasDefaultValues .}}
+  // Unhide the overloads omitting the arguments with default values.
+{{- else}}
+  // Unhide the overloads taking the arguments by move.
+{{- end}}
+  {{if not $.DisableBlockingCalls}}using {{$itfName}}::{{.Name}};
+  {{end}}using {{$itfName}}::{{.Name}}Async;
+{{- else if and (not $.DisableBlockingCalls) (hasInterfaceOverloads . $.ExpectedResults)}}
+
+  // Unhide the overloads defined by the interface.
+  using {{$itfName}}::{{.Name}};
+{{- end}}
 {{- end}}
 
  private:
-{{- if and $.ObjectManagerName .Properties}}
+{{- if hasPropertySet .}}
   void OnPropertyChanged(const std::string& property_name) {
+{{- range .Properties}}
+{{- if hasPropertyChangedCallback .}}
+{{- $name := makePropertyVariableName . | makeVariableName}}
+{{- if isPropertyInvalidated .}}
+    if (property_name == {{.Name}}Name()) {
+      if (!property_set_->{{$name}}.is_valid()) {
+        // The change was notified without the value. Fetching it notifies
+        // the change again with the value.
+        property_set_->{{$name}}.Get(base::DoNothing());
+      } else if (!on_{{$name}}_changed_.is_null()) {
+        on_{{$name}}_changed_.Run(property_set_->{{$name}}.value());
+      }
+    }
+{{- else}}
+    if (property_name == {{.Name}}Name() && !on_{{$name}}_changed_.is_null())
+      on_{{$name}}_changed_.Run(property_set_->{{$name}}.value());
+{{- end}}
+{{- end}}
+{{- end}}
     if (!on_property_changed_.is_null())
       on_property_changed_.Run(this, property_name);
   }
 {{/* blank line separator */}}
+{{- else if hasLightweightProperties .}}
+  void OnPropertiesChanged(
+      const std::string& interface_name,
+      const brillo::VariantDictionary& changed_properties,
+      const std::vector<std::string>& /* invalidated_properties */) {
+    if (interface_name != kInterfaceName)
+      return;
+    for (const auto& [name, value] : changed_properties) {
+{{- range .Properties}}
+{{- if hasPropertyChangedCallback .}}
+{{- $name := makePropertyVariableName . | makeVariableName}}
+{{- $type := makePropertyBaseTypeExtract .}}
+      if (name == {{.Name}}Name() && !on_{{$name}}_changed_.is_null() &&
+          value.IsTypeCompatible<{{$type}}>())
+        on_{{$name}}_changed_.Run(value.Get<{{$type}}>());
+{{- end}}
+{{- end}}
+    }
+  }
+{{/* blank line separator */}}
+{{- end}}
+{{- if $.RestartCallbacks}}
+  void OnNameOwnerChanged(
+      const base::RepeatingCallback<void(const std::string&, const std::string&)>& callback,
+      const std::string& old_owner,
+      const std::string& new_owner) {
+{{- if and (hasPropertySet .) (not $.ObjectManagerName)}}
+    // The restarted service does not notify the changes from the values
+    // cached before.
+    if (!new_owner.empty() && property_set_)
+      property_set_->GetAll();
+{{- end}}
+    callback.Run(old_owner, new_owner);
+  }
+{{/* blank line separator */}}
 {{- end}}
   scoped_refptr<dbus::Bus> bus_;
 {{- if $.ServiceName}}
@@ -298,27 +795,267 @@ class {{$proxyName}} final : public {{$itfName}} {
 {{- end}}
 {{- if and $.ObjectManagerName .Properties}}
   PropertySet* property_set_;
+{{- end}}
+{{- if hasPropertySet .}}
+{{- if $.SequenceCheckers}}
+  // Guarded by sequence_checker_.
+{{- end}}
   base::RepeatingCallback<void({{$itfName}}*, const std::string&)> on_property_changed_;
+{{- end}}
+{{- range .Properties}}
+{{- $name := makePropertyVariableName . | makeVariableName}}
+{{- if hasPropertyChangedCallback .}}
+{{- if $.SequenceCheckers}}
+  // Guarded by sequence_checker_.
+{{- end}}
+  base::RepeatingCallback<void({{makeProxyInArgTypeProxy .}})> on_{{$name}}_changed_;
+{{- else if and (isPropertyConst .) (hasPropertySet $itf)}}
+  mutable {{makePropertyBaseTypeExtract .}} {{$name}}_value_{};
+  mutable bool {{$name}}_fetched_ = false;
+{{- end}}
+{{- end}}
+{{- if $.ReportMetrics}}
+  chromeos_dbus_bindings::MetricsRecorder* metrics_recorder_;
 {{- end}}
   dbus::ObjectProxy* dbus_object_proxy_;
-{{- if and (not $.ObjectManagerName) .Properties}}
+{{- if and (not $.ObjectManagerName) (hasPropertySet .)}}
+{{- if $.SequenceCheckers}}
+  // Guarded by sequence_checker_.
+{{- end}}
   std::unique_ptr<PropertySet> property_set_;
+{{- end}}
+{{- if $.SequenceCheckers}}
+  // The proxy must be used on the sequence it is created on, as brillo D-Bus
+  // calls and signal handlers are not thread-safe.
+  SEQUENCE_CHECKER(sequence_checker_);
+{{- end}}
+{{- if $.RestartCallbacks}}
+  base::WeakPtrFactory<{{$proxyName}}> weak_ptr_factory_{this};
 {{- end}}{{"\n"}}
 {{- if and $.ObjectManagerName .Properties}}
   friend class {{makeFullProxyName $.ObjectManagerName}};
 {{- end}}
 };
+{{- with $factory}}
+
+inline std::unique_ptr<{{$itfName}}> {{$itfName}}::Create(
+    const scoped_refptr<dbus::Bus>& bus
+{{- range .Params}},
+    {{.Type}} {{.Name}}
+{{- end}}) {
+  if (GetFactoryForTesting())
+    return GetFactoryForTesting().Run(bus{{range .Params}}, {{.Name}}{{end}});
+  return std::make_unique<{{$proxyName}}>(bus{{range .Params}}, {{.Name}}{{end}});
+}
+{{- end}}
+{{- if $.SignalSenders}}
+{{- range .Signals}}
+{{- $params := makeSignalSenderParams $.NamingStyle .}}
+
+// Runs |signal_callback| with the {{.Name}} signal carrying the arguments, as
+// if it were sent by the service. For testing only, e.g. with the callback
+// captured from dbus::MockObjectProxy::DoConnectToSignal.
+inline void Send{{.Name}}SignalForTesting(
+    const dbus::ObjectProxy::SignalCallback& signal_callback
+{{- range $params}},
+    {{.Type}} {{.Name}}
+{{- end}}) {
+  dbus::Signal signal({{$itfName}}::kInterfaceName,
+                      {{$itfName}}::k{{makeConstantName .Name}}Signal);
+{{- if $params}}
+  dbus::MessageWriter writer(&signal);
+  brillo::dbus_utils::DBusParamWriter::Append(
+      &writer{{range $params}}, {{.Name}}{{end}});
+{{- end}}
+  signal_callback.Run(&signal);
+}
+{{- end}}
+{{- end}}
 
 {{range extractNameSpaces .Name | reverse -}}
 }  // namespace {{.}}
 {{end}}
-{{- end}}{{end}}
-{{- if .ObjectManagerName }}
+{{- end}}{{end}}`
+
+	// blockingMethodBodyTemplate, asyncMethodBodyTemplate and
+	// withMessageMethodBodyTemplate generate the bodies of the proxy methods,
+	// which are defined either in the class or in the source file.
+	blockingMethodBodyTemplate = `{{define "blockingMethodBody"}}
+{{- $inParams := .InParams}}
+{{- $outParams := .OutParams}}
+{{- with .Method}}
+{{- if $.SequenceCheckers}}
+    DCHECK_CALLED_ON_VALID_SEQUENCE(sequence_checker_);
+{{- end}}
+{{- template "methodTimeout" index $.MethodTimeouts (printf "%s.%s" $.Itf.Name .Name)}}
+{{- if $.ValidateVariantTypes}}
+{{- range makeVariantChecks $.NamingStyle .}}
+    if (!chromeos_dbus_bindings::AnyHoldsOneOf<{{.Types}}>({{.Name}})) {
+      brillo::Error::AddTo(error, FROM_HERE, brillo::errors::dbus::kDomain,
+                           "org.freedesktop.DBus.Error.InvalidArgs",
+                           "Unexpected variant type of {{.Name}}");
+      return false;
+    }
+{{- end}}
+{{- end}}
+{{- if $.InstrumentProxies}}
+    TRACE_EVENT0("dbus", "{{$.Itf.Name}}.{{.Name}}");
+{{- end}}
+{{- if .NoReply}}
+    brillo::dbus_utils::CallMethodWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "{{$.Itf.Name}}",
+        "{{.Name}}",
+        base::OnceCallback<void()>(base::DoNothing()),
+        base::DoNothing()
+{{- range $inParams }},
+        {{.Name}}
+{{- end}});
+    return true;
+{{- else}}
+{{- if or $.InstrumentProxies $.ReportMetrics}}
+    const base::TimeTicks start_time = base::TimeTicks::Now();
+{{- end}}
+    auto response = brillo::dbus_utils::CallMethodAndBlockWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "{{$.Itf.Name}}",
+        "{{.Name}}",
+        error
+{{- range $inParams }},
+        {{.Name}}
+{{- end}});
+{{- if or $.InstrumentProxies $.ReportMetrics}}
+    const bool success = response && brillo::dbus_utils::ExtractMethodCallResults(
+        response.get(), error{{range $i, $param := $outParams}}, {{.Name}}{{end}});
+{{- if $.InstrumentProxies}}
+    chromeos_dbus_bindings::LogMethodCall("{{$.Itf.Name}}.{{.Name}}", start_time, success);
+{{- end}}
+{{- if $.ReportMetrics}}
+    chromeos_dbus_bindings::RecordMethodCall(metrics_recorder_, "{{$.Itf.Name}}.{{.Name}}", start_time, success);
+{{- end}}
+    return success;
+{{- else}}
+    return response && brillo::dbus_utils::ExtractMethodCallResults(
+        response.get(), error{{range $i, $param := $outParams}}, {{.Name}}{{end}});
+{{- end}}
+{{- end}}
+{{- end}}
+{{- end}}`
+
+	asyncMethodBodyTemplate = `{{define "asyncMethodBody"}}
+{{- $inParams := .InParams}}
+{{- $outParams := .OutParams}}
+{{- with .Method}}
+{{- if $.SequenceCheckers}}
+    DCHECK_CALLED_ON_VALID_SEQUENCE(sequence_checker_);
+{{- end}}
+{{- template "methodTimeout" index $.MethodTimeouts (printf "%s.%s" $.Itf.Name .Name)}}
+{{- if $.ValidateVariantTypes}}
+{{- range makeVariantChecks $.NamingStyle .}}
+    if (!chromeos_dbus_bindings::AnyHoldsOneOf<{{.Types}}>({{.Name}})) {
+      auto error = brillo::Error::Create(
+          FROM_HERE, brillo::errors::dbus::kDomain,
+          "org.freedesktop.DBus.Error.InvalidArgs",
+          "Unexpected variant type of {{.Name}}");
+      std::move(error_callback).Run(error.get());
+      return;
+    }
+{{- end}}
+{{- end}}
+{{- if $.InstrumentProxies}}
+    TRACE_EVENT0("dbus", "{{$.Itf.Name}}.{{.Name}}Async");
+{{- end}}
+{{- if .NoReply}}
+    brillo::dbus_utils::CallMethodWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "{{$.Itf.Name}}",
+        "{{.Name}}",
+        base::OnceCallback<void()>(base::DoNothing()),
+        base::DoNothing()
+{{- range $inParams }},
+        {{.Name}}
+{{- end}});
+    std::move(success_callback).Run();
+{{- else}}
+{{- if or $.InstrumentProxies $.ReportMetrics}}
+    const base::TimeTicks start_time = base::TimeTicks::Now();
+{{- end}}
+    brillo::dbus_utils::CallMethodWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "{{$.Itf.Name}}",
+        "{{.Name}}",
+        std::move(success_callback)
+{{- if $.InstrumentProxies}}.Then(base::BindOnce(
+            &chromeos_dbus_bindings::LogMethodCall, "{{$.Itf.Name}}.{{.Name}}Async", start_time, true))
+{{- end}}
+{{- if $.ReportMetrics}}.Then(base::BindOnce(
+            &chromeos_dbus_bindings::RecordMethodCall, base::Unretained(metrics_recorder_), "{{$.Itf.Name}}.{{.Name}}Async", start_time, true))
+{{- end}},
+        std::move(error_callback)
+{{- if $.InstrumentProxies}}.Then(base::BindOnce(
+            &chromeos_dbus_bindings::LogMethodCall, "{{$.Itf.Name}}.{{.Name}}Async", start_time, false))
+{{- end}}
+{{- if $.ReportMetrics}}.Then(base::BindOnce(
+            &chromeos_dbus_bindings::RecordMethodCall, base::Unretained(metrics_recorder_), "{{$.Itf.Name}}.{{.Name}}Async", start_time, false))
+{{- end}}
+{{- range $inParams}},
+        {{.Name}}
+{{- end}});
+{{- end}}
+{{- end}}
+{{- end}}`
+
+	withMessageMethodBodyTemplate = `{{define "withMessageMethodBody"}}
+{{- $inParams := .InParams}}
+{{- $outParams := .OutParams}}
+{{- with .Method}}
+{{- template "methodTimeout" index $.MethodTimeouts (printf "%s.%s" $.Itf.Name .Name)}}
+{{- if or $.InstrumentProxies $.ReportMetrics}}
+{{- if $.InstrumentProxies}}
+    TRACE_EVENT0("dbus", "{{$.Itf.Name}}.{{.Name}}WithMessage");
+{{- end}}
+    const base::TimeTicks start_time = base::TimeTicks::Now();
+    auto response = brillo::dbus_utils::CallMethodAndBlockWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "{{$.Itf.Name}}",
+        "{{.Name}}",
+        error
+{{- range $inParams }},
+        {{.Name}}
+{{- end}});
+{{- if $.InstrumentProxies}}
+    chromeos_dbus_bindings::LogMethodCall("{{$.Itf.Name}}.{{.Name}}WithMessage", start_time, response != nullptr);
+{{- end}}
+{{- if $.ReportMetrics}}
+    chromeos_dbus_bindings::RecordMethodCall(metrics_recorder_, "{{$.Itf.Name}}.{{.Name}}WithMessage", start_time, response != nullptr);
+{{- end}}
+    return response;
+{{- else}}
+    return brillo::dbus_utils::CallMethodAndBlockWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "{{$.Itf.Name}}",
+        "{{.Name}}",
+        error
+{{- range $inParams }},
+        {{.Name}}
+{{- end}});
+{{- end}}
+{{- end}}
+{{- end}}`
+
+	objectManagerTemplate = `{{define "objectManager"}}
 {{- range extractNameSpaces .ObjectManagerName}}
 namespace {{.}} {
 {{- end}}
 
 {{ $className := makeProxyName .ObjectManagerName -}}
+{{ $typeNames := makeObjectManagerTypeNames .Introspects -}}
 class {{$className}} : public dbus::ObjectManager::Interface {
  public:
   {{$className}}(const scoped_refptr<dbus::Bus>& bus
@@ -353,12 +1090,85 @@ class {{$className}} : public dbus::ObjectManager::Interface {
   dbus::ObjectManager* GetObjectManagerProxy() const {
     return dbus_object_manager_;
   }
+{{- range .Introspects}}{{range .Interfaces}}
+{{- $typeName := index $typeNames .Name}}
+
+  // The readable properties of {{.Name}}, as returned by
+  // GetManagedObjects().
+  struct {{$typeName}}Properties {
+{{- range .Properties}}
+{{- if .Readable}}
+    {{makePropertyBaseTypeExtract .}} {{makePropertyVariableName . | makeVariableName}}{};
+{{- end}}
+{{- end}}
+  };
+{{- end}}{{end}}
+
+  // The known interfaces implemented by an object, as returned by
+  // GetManagedObjects(). An interface is set if the object implements it.
+  struct ManagedObject {
+{{- range .Introspects}}{{range .Interfaces}}
+{{- $typeName := index $typeNames .Name}}
+    std::optional<{{$typeName}}Properties> {{makeVariableName $typeName}};
+{{- end}}{{end}}
+  };
+
+  // Calls org.freedesktop.DBus.ObjectManager.GetManagedObjects and converts
+  // the result into |objects| keyed by the object paths. The interfaces
+  // unknown to this proxy are ignored, and the missing properties are left
+  // default-initialized.
+  bool GetManagedObjects(
+      std::map<dbus::ObjectPath, ManagedObject>* objects,
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) {
+    std::map<dbus::ObjectPath,
+             std::map<std::string, brillo::VariantDictionary>> managed_objects;
+    auto response = brillo::dbus_utils::CallMethodAndBlockWithTimeout(
+        timeout_ms,
+        bus_->GetObjectProxy(
+{{- if .ServiceName}}"{{.ServiceName}}"{{else}}service_name_{{end}},
+                             dbus::ObjectPath{"{{.ObjectManagerPath}}"}),
+        "org.freedesktop.DBus.ObjectManager",
+        "GetManagedObjects",
+        error);
+    if (!response || !brillo::dbus_utils::ExtractMethodCallResults(
+            response.get(), error, &managed_objects)) {
+      return false;
+    }
+    objects->clear();
+    for (const auto& object : managed_objects) {
+      ManagedObject& managed_object = (*objects)[object.first];
+      for (const auto& itf : object.second) {
+{{- range .Introspects}}{{range .Interfaces}}
+{{- $typeName := index $typeNames .Name}}
+{{- $varName := makeVariableName $typeName}}
+        if (itf.first == "{{.Name}}") {
+{{- if .Properties}}
+          {{$typeName}}Properties& properties =
+              managed_object.{{$varName}}.emplace();
+{{- range .Properties}}
+{{- if .Readable}}
+          properties.{{makePropertyVariableName . | makeVariableName}} =
+              brillo::GetVariantValueOrDefault<{{makePropertyBaseTypeExtract .}}>(
+                  itf.second, "{{.Name}}");
+{{- end}}
+{{- end}}
+{{- else}}
+          managed_object.{{$varName}}.emplace();
+{{- end}}
+          continue;
+        }
+{{- end}}{{end}}
+      }
+    }
+    return true;
+  }
 {{range $introspect := .Introspects}}{{range $itf := .Interfaces}}
-{{- $typeName := makeTypeName .Name}}
-{{- $varName := makeVariableName .Name }}
+{{- $typeName := index $typeNames .Name}}
+{{- $varName := makeVariableName $typeName }}
 {{- $instancesName := printf "%s_instances_" $varName }}
 {{- $fullItfName := makeFullProxyInterfaceName .Name }}
-{{- $proxyName := makeProxyName .Name }}
+{{- $proxyName := printf "%sProxy" $typeName }}
 {{- if $introspect.Name }}
   {{- /* We have a fixed path, so the object could be considered a "singleton". */}}
   {{- /* Skip the object_path parameter and return the first available instance. */}}
@@ -392,8 +1202,83 @@ class {{$className}} : public dbus::ObjectManager::Interface {
       const base::RepeatingCallback<void(const dbus::ObjectPath&)>& callback) {
     on_{{$varName}}_removed_ = callback;
   }
+{{- if $introspect.Name }}
+  // Runs |callback| with the proxy once the object is added, or with nullptr
+  // if it is not added within |timeout|. |callback| is run immediately if
+  // the object already exists.
+  void WaitFor{{$proxyName}}(
+      base::TimeDelta timeout,
+      base::OnceCallback<void({{$fullItfName}}*)> callback) {
+    auto* proxy = Get{{$proxyName}}();
+{{- else}}
+  // Runs |callback| with the proxy once the object at |object_path| is
+  // added, or with nullptr if it is not added within |timeout|. |callback|
+  // is run immediately if the object already exists.
+  void WaitFor{{$proxyName}}(
+      const dbus::ObjectPath& object_path,
+      base::TimeDelta timeout,
+      base::OnceCallback<void({{$fullItfName}}*)> callback) {
+    auto* proxy = Get{{$proxyName}}(object_path);
+{{- end}}
+    if (proxy) {
+      std::move(callback).Run(proxy);
+      return;
+    }
+    int id = next_waiter_id_++;
+    {{$varName}}_waiters_.push_back(
+        {id, {{if $introspect.Name}}dbus::ObjectPath(){{else}}object_path{{end}}, std::move(callback)});
+    base::SequencedTaskRunner::GetCurrentDefault()->PostDelayedTask(
+        FROM_HERE,
+        base::BindOnce(&{{$className}}::On{{$typeName}}WaitTimeout,
+                       weak_ptr_factory_.GetWeakPtr(), id),
+        timeout);
+  }
 {{end}}{{end}}
  private:
+  // A pending WaitFor...Proxy() call.
+  template <typename T>
+  struct Waiter {
+    int id;
+    dbus::ObjectPath object_path;
+    base::OnceCallback<void(T*)> callback;
+  };
+
+  // Removes the waiter |id| from |waiters| and runs its callback with
+  // nullptr, unless it has been run already.
+  template <typename T>
+  static void TimeOutWaiter(std::vector<Waiter<T>>* waiters, int id) {
+    for (auto it = waiters->begin(); it != waiters->end(); ++it) {
+      if (it->id == id) {
+        auto callback = std::move(it->callback);
+        waiters->erase(it);
+        std::move(callback).Run(nullptr);
+        return;
+      }
+    }
+  }
+
+  // Runs the callbacks of the waiters in |waiters| for the object at
+  // |object_path|, or of all of them if |any_path| is true, with |proxy|.
+  template <typename T>
+  static void NotifyWaiters(std::vector<Waiter<T>>* waiters,
+                            const dbus::ObjectPath& object_path,
+                            bool any_path,
+                            T* proxy) {
+    std::vector<Waiter<T>> pending;
+    pending.swap(*waiters);
+    for (auto& waiter : pending) {
+      if (any_path || waiter.object_path == object_path)
+        std::move(waiter.callback).Run(proxy);
+      else
+        waiters->push_back(std::move(waiter));
+    }
+  }
+{{range .Introspects}}{{range .Interfaces}}
+{{- $typeName := index $typeNames .Name}}
+  void On{{$typeName}}WaitTimeout(int id) {
+    TimeOutWaiter(&{{makeVariableName $typeName}}_waiters_, id);
+  }
+{{end}}{{end}}
 {{- $itfsWithProps := extractInterfacesWithProperties .Introspects -}}
 {{- if $itfsWithProps }}
   void OnPropertyChanged(const dbus::ObjectPath& object_path,
@@ -401,7 +1286,7 @@ class {{$className}} : public dbus::ObjectManager::Interface {
                          const std::string& property_name) {
 {{- range $itfsWithProps }}
     if (interface_name == "{{.Name}}") {
-{{- $instancesName := makeVariableName .Name | printf "%s_instances_" }}
+{{- $instancesName := index $typeNames .Name | makeVariableName | printf "%s_instances_" }}
       auto p = {{$instancesName}}.find(object_path);
       if (p == {{$instancesName}}.end())
         return;
@@ -421,7 +1306,7 @@ class {{$className}} : public dbus::ObjectManager::Interface {
       const std::string& interface_name) override {
 {{- range $introspect := .Introspects}}{{range $itf := .Interfaces}}
 {{- $fullProxyName := makeFullProxyName .Name}}
-{{- $varName := makeVariableName .Name}}
+{{- $varName := index $typeNames .Name | makeVariableName}}
     if (interface_name == "{{.Name}}") {
 {{- if .Properties }}
       auto property_set =
@@ -437,6 +1322,9 @@ class {{$className}} : public dbus::ObjectManager::Interface {
       auto p = {{$varName}}_instances_.emplace(object_path, std::move({{$varName}}_proxy));
       if (!on_{{$varName}}_added_.is_null())
         on_{{$varName}}_added_.Run(p.first->second.get());
+      NotifyWaiters<{{makeFullProxyInterfaceName .Name}}>(
+          &{{$varName}}_waiters_, object_path, {{if $introspect.Name}}true{{else}}false{{end}},
+          p.first->second.get());
       return;
     }
 {{- end}}{{end}}
@@ -446,7 +1334,7 @@ class {{$className}} : public dbus::ObjectManager::Interface {
       const dbus::ObjectPath& object_path,
       const std::string& interface_name) override {
 {{- range $introspect := .Introspects}}{{range $itf := .Interfaces}}
-{{- $varName := makeVariableName .Name}}
+{{- $varName := index $typeNames .Name | makeVariableName}}
     if (interface_name == "{{.Name}}") {
       auto p = {{$varName}}_instances_.find(object_path);
       if (p != {{$varName}}_instances_.end()) {
@@ -486,31 +1374,193 @@ class {{$className}} : public dbus::ObjectManager::Interface {
   dbus::ObjectManager* dbus_object_manager_;
 {{- range $introspect := .Introspects}}{{range $itf := .Interfaces}}
 {{- $fullProxyName := makeFullProxyName .Name}}
-{{- $varName := makeVariableName .Name}}
+{{- $varName := index $typeNames .Name | makeVariableName}}
   std::map<dbus::ObjectPath,
            std::unique_ptr<{{$fullProxyName}}>> {{$varName}}_instances_;
   base::RepeatingCallback<void({{$fullProxyName}}Interface*)> on_{{$varName}}_added_;
   base::RepeatingCallback<void(const dbus::ObjectPath&)> on_{{$varName}}_removed_;
+  std::vector<Waiter<{{$fullProxyName}}Interface>> {{$varName}}_waiters_;
 {{- end}}{{end}}
+  int next_waiter_id_ = 0;
   base::WeakPtrFactory<{{$className}}> weak_ptr_factory_{this};
 };
 {{range extractNameSpaces .ObjectManagerName | reverse }}
 }  // namespace {{.}}
 {{- end}}
-{{end}}
-#endif  // {{.HeaderGuard}}
-`
+{{end}}`
+
+	proxyFooterTemplate = `{{define "proxyFooter"}}
+{{if .ModuleName}}}  // export{{else}}#endif  // {{.HeaderGuard}}{{end}}
+{{end}}`
 )
 
+// proxyTemplates is parsed once, and cloned by every Generate call.
+var proxyTemplates = mustParseTemplates("proxy", funcMap,
+	proxyHeaderTemplate,
+	proxyTemplate,
+	blockingMethodBodyTemplate,
+	asyncMethodBodyTemplate,
+	withMessageMethodBodyTemplate,
+	objectManagerTemplate,
+	clientFactoryTemplate,
+	retryTemplate,
+	credentialsTemplate,
+	resilientProxyTemplate,
+	queueingProxyTemplate,
+	instrumentationTemplate,
+	metricsTemplate,
+	proxyFooterTemplate,
+	proxyInterfaceTemplate,
+	methodTimeoutTemplate,
+	awaitableTemplate,
+	variantTypesTemplate,
+	genutil.NamedStructsTemplate,
+	genutil.NamedEnumsTemplate,
+	genutil.NamedFlagsTemplate,
+	genutil.OptionalTemplate,
+	genutil.ProtobufChecksTemplate)
+
+// mustParseTemplates parses texts into a template with funcs and the default
+// namespace functions. It panics on failure, as texts are the constant
+// templates of this package.
+func mustParseTemplates(name string, funcs template.FuncMap, texts ...string) *template.Template {
+	tmpl := template.New(name).Funcs(funcs).Funcs(genutil.NameSpaceFuncs(nil)).Funcs(configFuncs(serviceconfig.Config{}))
+	for _, t := range texts {
+		template.Must(tmpl.Parse(t))
+	}
+	return tmpl
+}
+
+// configFuncs returns the template functions applying the per-interface
+// settings of config.
+func configFuncs(config serviceconfig.Config) template.FuncMap {
+	return template.FuncMap{
+		"interfaceSettings": func(name string) interfaceSettings {
+			return makeInterfaceSettings(config, name)
+		},
+		"makeClientFactoryProxies": func(introspects []introspect.Introspection) []clientFactoryProxy {
+			return makeClientFactoryProxies(introspects, config)
+		},
+	}
+}
+
+// cloneTemplates returns a copy of tmpl whose namespace functions apply the
+// namespace overrides of config, and whose other configuration dependent
+// functions apply config. Parsing the templates is much more costly than
+// cloning them, so it is done only once per process.
+func cloneTemplates(tmpl *template.Template, introspects []introspect.Introspection, config serviceconfig.Config) (*template.Template, error) {
+	if err := genutil.CheckNameSpaceCollisions(introspects, config.NamespaceOverrides); err != nil {
+		return nil, err
+	}
+	if err := genutil.CheckConstantNameCollisions(introspects); err != nil {
+		return nil, err
+	}
+	if config.ObjectManager != nil {
+		if err := checkLightweightProperties(managedIntrospects(introspects, config), config.ObjectManager.Name); err != nil {
+			return nil, err
+		}
+	}
+	ret, err := tmpl.Clone()
+	if err != nil {
+		return nil, err
+	}
+	return ret.Funcs(genutil.NameSpaceFuncs(config.NamespaceOverrides)).Funcs(configFuncs(config)), nil
+}
+
+// proxyArgs is the data passed to the "proxy" template, which generates
+// the classes for a single interface.
+type proxyArgs struct {
+	Introspect            introspect.Introspection
+	Itf                   introspect.Interface
+	ServiceName           string
+	ObjectManagerName     string
+	NamingStyle           serviceconfig.NamingStyle
+	UseCoroutines         bool
+	MoveProtobufResponses bool
+	InstrumentProxies     bool
+	ReportMetrics         bool
+	SequenceCheckers      bool
+	RestartCallbacks      bool
+	CredentialQueries     bool
+	MethodTimeouts        map[string]int
+	ProxyFactories        bool
+	ValidateVariantTypes  bool
+	ExpectedResults       bool
+	DisableBlockingCalls  bool
+	SignalSenders         bool
+	// SplitSource is set when the methods are defined in the source file
+	// output by GenerateSource, so that the class only declares them.
+	SplitSource bool
+}
+
+// makeProxyArgs returns the data passed to the "proxy" template for itf in is.
+func makeProxyArgs(is introspect.Introspection, itf introspect.Interface, config serviceconfig.Config, signalSenders, splitSource bool) proxyArgs {
+	settings := makeInterfaceSettings(config, itf.Name)
+	return proxyArgs{
+		Introspect:            is,
+		Itf:                   itf,
+		ServiceName:           config.ServiceName,
+		ObjectManagerName:     settings.ObjectManagerName,
+		NamingStyle:           config.NamingStyle,
+		UseCoroutines:         config.UseCoroutines,
+		MoveProtobufResponses: config.MoveProtobufResponses,
+		InstrumentProxies:     config.InstrumentProxies,
+		ReportMetrics:         config.ReportMetrics,
+		SequenceCheckers:      config.SequenceCheckers,
+		RestartCallbacks:      config.RestartCallbacks,
+		CredentialQueries:     config.CredentialQueries,
+		MethodTimeouts:        config.MethodTimeouts,
+		ProxyFactories:        config.ProxyFactories,
+		ValidateVariantTypes:  config.ValidateVariantTypes,
+		ExpectedResults:       config.ExpectedResults,
+		DisableBlockingCalls:  settings.DisableBlockingCalls,
+		SignalSenders:         signalSenders,
+		SplitSource:           splitSource,
+	}
+}
+
 // Generate outputs the header file containing proxy interfaces into f.
 // outputFilePath is used to make a unique header guard.
+// The header is streamed into f one interface at a time, so the output for
+// a large set of interfaces is never held in memory as a whole.
 func Generate(introspects []introspect.Introspection, f io.Writer, outputFilePath string, config serviceconfig.Config) error {
-	tmpl, err := template.New("proxy").Funcs(funcMap).Parse(templateText)
-	if err != nil {
+	return generate(introspects, f, outputFilePath, "", false, false, false, config)
+}
+
+// GenerateModule is Generate which outputs a C++20 module interface unit
+// exporting the proxy classes instead of a header. The module name is made
+// from outputFilePath.
+// This is experimental, and requires the consumers to have module support.
+func GenerateModule(introspects []introspect.Introspection, f io.Writer, outputFilePath string, config serviceconfig.Config) error {
+	return generate(introspects, f, outputFilePath, "", false, true, false, config)
+}
+
+// GenerateWithSignalSenders is Generate which also outputs the
+// Send<Signal>SignalForTesting functions, running a signal callback with the
+// marshaled dbus::Signal, for the tests driving the callbacks connected
+// through dbus::MockObjectProxy.
+func GenerateWithSignalSenders(introspects []introspect.Introspection, f io.Writer, outputFilePath string, config serviceconfig.Config) error {
+	if err := checkSignalSenderCollisions(introspects, config.NamespaceOverrides); err != nil {
 		return err
 	}
+	return generate(introspects, f, outputFilePath, "", true, false, false, config)
+}
 
-	if _, err := tmpl.Parse(proxyInterfaceTemplate); err != nil {
+// GenerateWithSharedProxies is Generate for a service using interfaces shared
+// with other services, whose proxies are generated once into the header at
+// sharedProxyFilePath. introspects must not contain the shared interfaces,
+// and the header is included instead.
+func GenerateWithSharedProxies(introspects []introspect.Introspection, f io.Writer, outputFilePath, sharedProxyFilePath string, config serviceconfig.Config) error {
+	if sharedProxyFilePath == "" {
+		return errors.New("shared proxy file path is not specified")
+	}
+	return generate(introspects, f, outputFilePath, sharedProxyFilePath, false, false, false, config)
+}
+
+func generate(introspects []introspect.Introspection, f io.Writer, outputFilePath, sharedProxyFilePath string, signalSenders, cppModule, splitSource bool, config serviceconfig.Config) error {
+	f = genutil.NewAPILevelWriter(f, config.TargetAPILevel)
+	tmpl, err := cloneTemplates(proxyTemplates, introspects, config)
+	if err != nil {
 		return err
 	}
 
@@ -519,19 +1569,113 @@ func Generate(introspects []introspect.Introspection, f io.Writer, outputFilePat
 		omName = config.ObjectManager.Name
 		omPath = config.ObjectManager.ObjectPath
 	}
+	var cfName string
+	if config.ClientFactory != nil {
+		cfName = config.ClientFactory.Name
+	}
 
 	headerGuard := genutil.GenerateHeaderGuard(outputFilePath)
-	return tmpl.Execute(f, struct {
-		Introspects       []introspect.Introspection
-		HeaderGuard       string
-		ServiceName       string
-		ObjectManagerName string
-		ObjectManagerPath string
+	var moduleName string
+	if cppModule {
+		moduleName = genutil.GenerateModuleName(outputFilePath)
+	}
+	args := struct {
+		Introspects           []introspect.Introspection
+		HeaderGuard           string
+		ModuleName            string
+		ServiceName           string
+		ObjectManagerName     string
+		ObjectManagerPath     string
+		ClientFactoryName     string
+		NamingStyle           serviceconfig.NamingStyle
+		NamespaceOverrides    map[string]string
+		UseCoroutines         bool
+		MoveProtobufResponses bool
+		InstrumentProxies     bool
+		ReportMetrics         bool
+		SequenceCheckers      bool
+		RestartCallbacks      bool
+		CredentialQueries     bool
+		MethodTimeouts        map[string]int
+		ProxyFactories        bool
+		ExpectedResults       bool
+		DisableBlockingCalls  bool
+		ResilientProxy        *serviceconfig.ResilientProxyConfig
+		QueueingProxy         bool
+		SharedProxyFilePath   string
+		TypeMappingIncludes   []string
+		SignalSenders         bool
 	}{
-		Introspects:       introspects,
-		HeaderGuard:       headerGuard,
-		ServiceName:       config.ServiceName,
-		ObjectManagerName: omName,
-		ObjectManagerPath: omPath,
-	})
+		Introspects:           introspects,
+		HeaderGuard:           headerGuard,
+		ModuleName:            moduleName,
+		ServiceName:           config.ServiceName,
+		ObjectManagerName:     omName,
+		ObjectManagerPath:     omPath,
+		ClientFactoryName:     cfName,
+		NamingStyle:           config.NamingStyle,
+		NamespaceOverrides:    config.NamespaceOverrides,
+		UseCoroutines:         config.UseCoroutines,
+		MoveProtobufResponses: config.MoveProtobufResponses,
+		InstrumentProxies:     config.InstrumentProxies,
+		ReportMetrics:         config.ReportMetrics,
+		SequenceCheckers:      config.SequenceCheckers,
+		RestartCallbacks:      config.RestartCallbacks,
+		CredentialQueries:     config.CredentialQueries,
+		MethodTimeouts:        config.MethodTimeouts,
+		ProxyFactories:        config.ProxyFactories,
+		ExpectedResults:       config.ExpectedResults,
+		DisableBlockingCalls:  config.DisableBlockingCalls,
+		ResilientProxy:        config.ResilientProxy,
+		QueueingProxy:         config.QueueingProxy,
+		SharedProxyFilePath:   sharedProxyFilePath,
+		TypeMappingIncludes:   genutil.MakeTypeMappingIncludes(introspects, config.TypeMappings),
+		SignalSenders:         signalSenders,
+	}
+
+	if err := tmpl.ExecuteTemplate(f, "proxyHeader", args); err != nil {
+		return err
+	}
+	for _, is := range introspects {
+		for _, itf := range is.Interfaces {
+			if err := tmpl.ExecuteTemplate(f, "proxy", makeProxyArgs(is, itf, config, signalSenders, splitSource)); err != nil {
+				return err
+			}
+			if config.ResilientProxy != nil {
+				if err := tmpl.ExecuteTemplate(f, "resilientProxy", resilientProxyArgs{
+					Itf:                   itf,
+					NamingStyle:           config.NamingStyle,
+					MoveProtobufResponses: config.MoveProtobufResponses,
+					DisableBlockingCalls:  makeInterfaceSettings(config, itf.Name).DisableBlockingCalls,
+					Policy:                config.ResilientProxy,
+				}); err != nil {
+					return err
+				}
+			}
+			if config.QueueingProxy {
+				if err := tmpl.ExecuteTemplate(f, "queueingProxy", queueingProxyArgs{
+					Itf:                   itf,
+					NamingStyle:           config.NamingStyle,
+					MoveProtobufResponses: config.MoveProtobufResponses,
+				}); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	if omName != "" {
+		// The object manager only handles the interfaces whose properties
+		// are not fetched by their proxies themselves.
+		omArgs := args
+		omArgs.Introspects = managedIntrospects(introspects, config)
+		if err := tmpl.ExecuteTemplate(f, "objectManager", omArgs); err != nil {
+			return err
+		}
+	}
+	if cfName != "" {
+		if err := tmpl.ExecuteTemplate(f, "clientFactory", args); err != nil {
+			return err
+		}
+	}
+	return tmpl.ExecuteTemplate(f, "proxyFooter", args)
 }
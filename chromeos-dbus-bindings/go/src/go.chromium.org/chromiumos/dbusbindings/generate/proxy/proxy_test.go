@@ -6,8 +6,12 @@ package proxy
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
 	"testing"
 
+	"go.chromium.org/chromiumos/dbusbindings/generate/genutil"
 	"go.chromium.org/chromiumos/dbusbindings/introspect"
 	"go.chromium.org/chromiumos/dbusbindings/serviceconfig"
 
@@ -33,10 +37,10 @@ func TestGenerateProxies(t *testing.T) {
 						Name:      "request",
 						Type:      "ay",
 						Direction: "in",
-						Annotation: introspect.Annotation{
+						Annotations: []introspect.Annotation{{
 							Name:  "org.chromium.DBus.Argument.ProtobufClass",
 							Value: "PassMeProtosRequest",
-						},
+						}},
 					},
 				},
 				Annotations: []introspect.Annotation{
@@ -55,10 +59,10 @@ func TestGenerateProxies(t *testing.T) {
 					{
 						Name: "BSSDetail1",
 						Type: "ay",
-						Annotation: introspect.Annotation{
+						Annotations: []introspect.Annotation{{
 							Name:  "org.chromium.DBus.Argument.ProtobufClass",
 							Value: "YetAnotherProto",
-						},
+						}},
 					}, {
 						Name: "BSSDetail2",
 						Type: "(ih)",
@@ -78,10 +82,10 @@ func TestGenerateProxies(t *testing.T) {
 				Type:      "u",
 				Access:    "read",
 				DocString: "\n        property doc\n      ",
-				Annotation: introspect.Annotation{
+				Annotations: []introspect.Annotation{{
 					Name:  "org.chromium.DBus.Argument.VariableName",
 					Value: "bluetooth_class",
-				},
+				}},
 			},
 		},
 		DocString: "\n      interface doc\n    ",
@@ -116,15 +120,20 @@ func TestGenerateProxies(t *testing.T) {
 #ifndef ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
 #define ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
 #include <memory>
+#include <optional>
 #include <string>
+#include <type_traits>
 #include <vector>
 
 #include <base/files/scoped_file.h>
 #include <base/functional/bind.h>
 #include <base/functional/callback.h>
+#include <base/location.h>
 #include <base/logging.h>
 #include <base/memory/ref_counted.h>
-#include <brillo/any.h>
+#include <base/memory/weak_ptr.h>
+#include <base/task/sequenced_task_runner.h>
+#include <base/time/time.h>
 #include <brillo/dbus/dbus_method_invoker.h>
 #include <brillo/dbus/dbus_property.h>
 #include <brillo/dbus/dbus_signal_handler.h>
@@ -135,6 +144,27 @@ func TestGenerateProxies(t *testing.T) {
 #include <dbus/object_manager.h>
 #include <dbus/object_path.h>
 #include <dbus/object_proxy.h>
+#include <google/protobuf/message_lite.h>
+
+#ifndef CHROMEOS_DBUS_BINDINGS_DBUS_PROTOBUF_CLASS_CHECK_
+#define CHROMEOS_DBUS_BINDINGS_DBUS_PROTOBUF_CLASS_CHECK_
+namespace brillo {
+namespace dbus_utils {
+
+// Fails to compile unless T, given by an
+// org.chromium.DBus.Argument.ProtobufClass annotation, is a protobuf message.
+template <typename T>
+constexpr bool CheckProtobufClass() {
+  static_assert(std::is_base_of_v<google::protobuf::MessageLite, T>,
+                "The class given by org.chromium.DBus.Argument.ProtobufClass "
+                "is not a protobuf message; check the annotation and the "
+                "headers in org.chromium.DBus.Interface.ProtobufIncludes");
+  return true;
+}
+
+}  // namespace dbus_utils
+}  // namespace brillo
+#endif  // CHROMEOS_DBUS_BINDINGS_DBUS_PROTOBUF_CLASS_CHECK_
 
 namespace foo {
 namespace bar {
@@ -146,10 +176,33 @@ namespace fi {
 namespace w1 {
 namespace wpa_supplicant1 {
 
+static_assert(
+    brillo::dbus_utils::CheckProtobufClass<PassMeProtosRequest>());
+static_assert(
+    brillo::dbus_utils::CheckProtobufClass<YetAnotherProto>());
+
 // Abstract interface proxy for fi::w1::wpa_supplicant1::Interface.
 // interface doc
 class InterfaceProxyInterface {
  public:
+  static constexpr char kInterfaceName[] = "fi.w1.wpa_supplicant1.Interface";
+  static constexpr char kScanMethod[] = "Scan";
+  static constexpr char kScanMethodInSignature[] = "ah";
+  static constexpr char kScanMethodOutSignature[] = "";
+  static constexpr char kPassMeProtosMethod[] = "PassMeProtos";
+  static constexpr char kPassMeProtosMethodInSignature[] = "ay";
+  static constexpr char kPassMeProtosMethodOutSignature[] = "";
+  static constexpr char kBSSRemovedSignal[] = "BSSRemoved";
+  static constexpr char kBSSRemovedSignalSignature[] = "ay(ih)";
+  static constexpr char kCapabilitiesProperty[] = "Capabilities";
+  static constexpr char kCapabilitiesPropertySignature[] = "a{sv}";
+  static constexpr char kClassProperty[] = "Class";
+  static constexpr char kClassPropertySignature[] = "u";
+
+  using BSSRemovedSignalCallback =
+      base::RepeatingCallback<void(const YetAnotherProto& /*BSSDetail1*/,
+                                   const std::tuple<int32_t, base::ScopedFD>& /*BSSDetail2*/)>;
+
   virtual ~InterfaceProxyInterface() = default;
 
   virtual bool Scan(
@@ -181,12 +234,42 @@ class InterfaceProxyInterface {
                                          const std::tuple<int32_t, base::ScopedFD>&)>& signal_callback,
       dbus::ObjectProxy::OnConnectedCallback on_connected_callback) = 0;
 
+  // Registers |method| of |target| as the handler of the BSSRemoved signal.
+  // The signals delivered after |target| is invalidated are dropped.
+  template <typename T>
+  void RegisterBSSRemovedSignalHandlerWeak(
+      base::WeakPtr<T> target,
+      void (T::*method)(const YetAnotherProto&, const std::tuple<int32_t, base::ScopedFD>&),
+      dbus::ObjectProxy::OnConnectedCallback on_connected_callback) {
+    RegisterBSSRemovedSignalHandler(
+        base::BindRepeating(method, std::move(target)),
+        std::move(on_connected_callback));
+  }
+
   static const char* CapabilitiesName() { return "Capabilities"; }
   virtual const brillo::VariantDictionary& capabilities() const = 0;
   virtual bool is_capabilities_valid() const = 0;
+  // Returns in |value| the entry of Capabilities keyed by |key|.
+  // Returns false if the property is not valid, has no such entry, or the
+  // entry holds another type than T.
+  template <typename T>
+  bool GetCapabilitiesEntry(const std::string& key, T* value) const {
+    if (!is_capabilities_valid())
+      return false;
+    const brillo::VariantDictionary& dict = capabilities();
+    auto it = dict.find(key);
+    if (it == dict.end() || !it->second.IsTypeCompatible<T>())
+      return false;
+    *value = it->second.Get<T>();
+    return true;
+  }
+  virtual void SetCapabilitiesChangedCallback(
+      const base::RepeatingCallback<void(const brillo::VariantDictionary&)>& callback) = 0;
   static const char* ClassName() { return "Class"; }
   virtual uint32_t bluetooth_class() const = 0;
   virtual bool is_bluetooth_class_valid() const = 0;
+  virtual void SetBluetoothClassChangedCallback(
+      const base::RepeatingCallback<void(uint32_t)>& callback) = 0;
 
   virtual const dbus::ObjectPath& GetObjectPath() const = 0;
   virtual dbus::ObjectProxy* GetObjectProxy() const = 0;
@@ -344,6 +427,11 @@ class InterfaceProxy final : public InterfaceProxyInterface {
     return property_set_->capabilities.is_valid();
   }
 
+  void SetCapabilitiesChangedCallback(
+      const base::RepeatingCallback<void(const brillo::VariantDictionary&)>& callback) override {
+    on_capabilities_changed_ = callback;
+  }
+
   uint32_t bluetooth_class() const override {
     return property_set_->bluetooth_class.value();
   }
@@ -352,8 +440,17 @@ class InterfaceProxy final : public InterfaceProxyInterface {
     return property_set_->bluetooth_class.is_valid();
   }
 
+  void SetBluetoothClassChangedCallback(
+      const base::RepeatingCallback<void(uint32_t)>& callback) override {
+    on_bluetooth_class_changed_ = callback;
+  }
+
  private:
   void OnPropertyChanged(const std::string& property_name) {
+    if (property_name == CapabilitiesName() && !on_capabilities_changed_.is_null())
+      on_capabilities_changed_.Run(property_set_->capabilities.value());
+    if (property_name == ClassName() && !on_bluetooth_class_changed_.is_null())
+      on_bluetooth_class_changed_.Run(property_set_->bluetooth_class.value());
     if (!on_property_changed_.is_null())
       on_property_changed_.Run(this, property_name);
   }
@@ -363,6 +460,8 @@ class InterfaceProxy final : public InterfaceProxyInterface {
   const dbus::ObjectPath object_path_{"/org/chromium/Test"};
   PropertySet* property_set_;
   base::RepeatingCallback<void(InterfaceProxyInterface*, const std::string&)> on_property_changed_;
+  base::RepeatingCallback<void(const brillo::VariantDictionary&)> on_capabilities_changed_;
+  base::RepeatingCallback<void(uint32_t)> on_bluetooth_class_changed_;
   dbus::ObjectProxy* dbus_object_proxy_;
 
   friend class foo::bar::ObjectManagerProxy;
@@ -376,6 +475,8 @@ class InterfaceProxy final : public InterfaceProxyInterface {
 // Abstract interface proxy for EmptyInterface.
 class EmptyInterfaceProxyInterface {
  public:
+  static constexpr char kInterfaceName[] = "EmptyInterface";
+
   virtual ~EmptyInterfaceProxyInterface() = default;
 
   virtual const dbus::ObjectPath& GetObjectPath() const = 0;
@@ -467,6 +568,70 @@ class ObjectManagerProxy : public dbus::ObjectManager::Interface {
     return dbus_object_manager_;
   }
 
+  // The readable properties of fi.w1.wpa_supplicant1.Interface, as returned by
+  // GetManagedObjects().
+  struct InterfaceProperties {
+    brillo::VariantDictionary capabilities{};
+    uint32_t bluetooth_class{};
+  };
+
+  // The readable properties of EmptyInterface, as returned by
+  // GetManagedObjects().
+  struct EmptyInterfaceProperties {
+  };
+
+  // The known interfaces implemented by an object, as returned by
+  // GetManagedObjects(). An interface is set if the object implements it.
+  struct ManagedObject {
+    std::optional<InterfaceProperties> interface;
+    std::optional<EmptyInterfaceProperties> empty_interface;
+  };
+
+  // Calls org.freedesktop.DBus.ObjectManager.GetManagedObjects and converts
+  // the result into |objects| keyed by the object paths. The interfaces
+  // unknown to this proxy are ignored, and the missing properties are left
+  // default-initialized.
+  bool GetManagedObjects(
+      std::map<dbus::ObjectPath, ManagedObject>* objects,
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) {
+    std::map<dbus::ObjectPath,
+             std::map<std::string, brillo::VariantDictionary>> managed_objects;
+    auto response = brillo::dbus_utils::CallMethodAndBlockWithTimeout(
+        timeout_ms,
+        bus_->GetObjectProxy(service_name_,
+                             dbus::ObjectPath{""}),
+        "org.freedesktop.DBus.ObjectManager",
+        "GetManagedObjects",
+        error);
+    if (!response || !brillo::dbus_utils::ExtractMethodCallResults(
+            response.get(), error, &managed_objects)) {
+      return false;
+    }
+    objects->clear();
+    for (const auto& object : managed_objects) {
+      ManagedObject& managed_object = (*objects)[object.first];
+      for (const auto& itf : object.second) {
+        if (itf.first == "fi.w1.wpa_supplicant1.Interface") {
+          InterfaceProperties& properties =
+              managed_object.interface.emplace();
+          properties.capabilities =
+              brillo::GetVariantValueOrDefault<brillo::VariantDictionary>(
+                  itf.second, "Capabilities");
+          properties.bluetooth_class =
+              brillo::GetVariantValueOrDefault<uint32_t>(
+                  itf.second, "Class");
+          continue;
+        }
+        if (itf.first == "EmptyInterface") {
+          managed_object.empty_interface.emplace();
+          continue;
+        }
+      }
+    }
+    return true;
+  }
+
   fi::w1::wpa_supplicant1::InterfaceProxyInterface* GetInterfaceProxy() {
     if (interface_instances_.empty())
       return nullptr;
@@ -487,6 +652,26 @@ class ObjectManagerProxy : public dbus::ObjectManager::Interface {
       const base::RepeatingCallback<void(const dbus::ObjectPath&)>& callback) {
     on_interface_removed_ = callback;
   }
+  // Runs |callback| with the proxy once the object is added, or with nullptr
+  // if it is not added within |timeout|. |callback| is run immediately if
+  // the object already exists.
+  void WaitForInterfaceProxy(
+      base::TimeDelta timeout,
+      base::OnceCallback<void(fi::w1::wpa_supplicant1::InterfaceProxyInterface*)> callback) {
+    auto* proxy = GetInterfaceProxy();
+    if (proxy) {
+      std::move(callback).Run(proxy);
+      return;
+    }
+    int id = next_waiter_id_++;
+    interface_waiters_.push_back(
+        {id, dbus::ObjectPath(), std::move(callback)});
+    base::SequencedTaskRunner::GetCurrentDefault()->PostDelayedTask(
+        FROM_HERE,
+        base::BindOnce(&ObjectManagerProxy::OnInterfaceWaitTimeout,
+                       weak_ptr_factory_.GetWeakPtr(), id),
+        timeout);
+  }
 
   EmptyInterfaceProxyInterface* GetEmptyInterfaceProxy(
       const dbus::ObjectPath& object_path) {
@@ -510,8 +695,76 @@ class ObjectManagerProxy : public dbus::ObjectManager::Interface {
       const base::RepeatingCallback<void(const dbus::ObjectPath&)>& callback) {
     on_empty_interface_removed_ = callback;
   }
+  // Runs |callback| with the proxy once the object at |object_path| is
+  // added, or with nullptr if it is not added within |timeout|. |callback|
+  // is run immediately if the object already exists.
+  void WaitForEmptyInterfaceProxy(
+      const dbus::ObjectPath& object_path,
+      base::TimeDelta timeout,
+      base::OnceCallback<void(EmptyInterfaceProxyInterface*)> callback) {
+    auto* proxy = GetEmptyInterfaceProxy(object_path);
+    if (proxy) {
+      std::move(callback).Run(proxy);
+      return;
+    }
+    int id = next_waiter_id_++;
+    empty_interface_waiters_.push_back(
+        {id, object_path, std::move(callback)});
+    base::SequencedTaskRunner::GetCurrentDefault()->PostDelayedTask(
+        FROM_HERE,
+        base::BindOnce(&ObjectManagerProxy::OnEmptyInterfaceWaitTimeout,
+                       weak_ptr_factory_.GetWeakPtr(), id),
+        timeout);
+  }
 
  private:
+  // A pending WaitFor...Proxy() call.
+  template <typename T>
+  struct Waiter {
+    int id;
+    dbus::ObjectPath object_path;
+    base::OnceCallback<void(T*)> callback;
+  };
+
+  // Removes the waiter |id| from |waiters| and runs its callback with
+  // nullptr, unless it has been run already.
+  template <typename T>
+  static void TimeOutWaiter(std::vector<Waiter<T>>* waiters, int id) {
+    for (auto it = waiters->begin(); it != waiters->end(); ++it) {
+      if (it->id == id) {
+        auto callback = std::move(it->callback);
+        waiters->erase(it);
+        std::move(callback).Run(nullptr);
+        return;
+      }
+    }
+  }
+
+  // Runs the callbacks of the waiters in |waiters| for the object at
+  // |object_path|, or of all of them if |any_path| is true, with |proxy|.
+  template <typename T>
+  static void NotifyWaiters(std::vector<Waiter<T>>* waiters,
+                            const dbus::ObjectPath& object_path,
+                            bool any_path,
+                            T* proxy) {
+    std::vector<Waiter<T>> pending;
+    pending.swap(*waiters);
+    for (auto& waiter : pending) {
+      if (any_path || waiter.object_path == object_path)
+        std::move(waiter.callback).Run(proxy);
+      else
+        waiters->push_back(std::move(waiter));
+    }
+  }
+
+  void OnInterfaceWaitTimeout(int id) {
+    TimeOutWaiter(&interface_waiters_, id);
+  }
+
+  void OnEmptyInterfaceWaitTimeout(int id) {
+    TimeOutWaiter(&empty_interface_waiters_, id);
+  }
+
   void OnPropertyChanged(const dbus::ObjectPath& object_path,
                          const std::string& interface_name,
                          const std::string& property_name) {
@@ -537,6 +790,9 @@ class ObjectManagerProxy : public dbus::ObjectManager::Interface {
       auto p = interface_instances_.emplace(object_path, std::move(interface_proxy));
       if (!on_interface_added_.is_null())
         on_interface_added_.Run(p.first->second.get());
+      NotifyWaiters<fi::w1::wpa_supplicant1::InterfaceProxyInterface>(
+          &interface_waiters_, object_path, true,
+          p.first->second.get());
       return;
     }
     if (interface_name == "EmptyInterface") {
@@ -546,6 +802,9 @@ class ObjectManagerProxy : public dbus::ObjectManager::Interface {
       auto p = empty_interface_instances_.emplace(object_path, std::move(empty_interface_proxy));
       if (!on_empty_interface_added_.is_null())
         on_empty_interface_added_.Run(p.first->second.get());
+      NotifyWaiters<EmptyInterfaceProxyInterface>(
+          &empty_interface_waiters_, object_path, false,
+          p.first->second.get());
       return;
     }
   }
@@ -607,10 +866,13 @@ class ObjectManagerProxy : public dbus::ObjectManager::Interface {
            std::unique_ptr<fi::w1::wpa_supplicant1::InterfaceProxy>> interface_instances_;
   base::RepeatingCallback<void(fi::w1::wpa_supplicant1::InterfaceProxyInterface*)> on_interface_added_;
   base::RepeatingCallback<void(const dbus::ObjectPath&)> on_interface_removed_;
+  std::vector<Waiter<fi::w1::wpa_supplicant1::InterfaceProxyInterface>> interface_waiters_;
   std::map<dbus::ObjectPath,
            std::unique_ptr<EmptyInterfaceProxy>> empty_interface_instances_;
   base::RepeatingCallback<void(EmptyInterfaceProxyInterface*)> on_empty_interface_added_;
   base::RepeatingCallback<void(const dbus::ObjectPath&)> on_empty_interface_removed_;
+  std::vector<Waiter<EmptyInterfaceProxyInterface>> empty_interface_waiters_;
+  int next_waiter_id_ = 0;
   base::WeakPtrFactory<ObjectManagerProxy> weak_ptr_factory_{this};
 };
 
@@ -625,6 +887,42 @@ class ObjectManagerProxy : public dbus::ObjectManager::Interface {
 	}
 }
 
+// limitedWriter fails all writes once more than limit bytes are written.
+type limitedWriter struct {
+	written, limit int
+}
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	w.written += len(p)
+	if w.written > w.limit {
+		return 0, errors.New("limit exceeded")
+	}
+	return len(p), nil
+}
+
+func TestGenerateProxiesStreamsOutput(t *testing.T) {
+	var itfs []introspect.Interface
+	for _, name := range []string{"test.Itf1", "test.Itf2", "test.Itf3"} {
+		itfs = append(itfs, introspect.Interface{Name: name})
+	}
+	introspections := []introspect.Introspection{{Interfaces: itfs}}
+
+	full := new(bytes.Buffer)
+	if err := Generate(introspections, full, "/tmp/proxy.h", serviceconfig.Config{}); err != nil {
+		t.Fatalf("Generate got error, want nil: %v", err)
+	}
+
+	// The output is written piece by piece, so a failing writer must stop
+	// the generation before the whole header is produced.
+	w := &limitedWriter{limit: full.Len() / 2}
+	if err := Generate(introspections, w, "/tmp/proxy.h", serviceconfig.Config{}); err == nil {
+		t.Fatal("Generate unexpectedly succeeded with a failing writer")
+	}
+	if w.written >= full.Len() {
+		t.Errorf("Generate wrote %d bytes after the writer failed, want less than %d", w.written, full.Len())
+	}
+}
+
 func TestGenerateProxiesEmpty(t *testing.T) {
 	emptyItf := introspect.Interface{
 		Name: "test.EmptyInterface",
@@ -648,17 +946,14 @@ func TestGenerateProxiesEmpty(t *testing.T) {
 #include <string>
 #include <vector>
 
-#include <base/files/scoped_file.h>
 #include <base/functional/bind.h>
 #include <base/functional/callback.h>
 #include <base/logging.h>
 #include <base/memory/ref_counted.h>
-#include <brillo/any.h>
 #include <brillo/dbus/dbus_method_invoker.h>
 #include <brillo/dbus/dbus_property.h>
 #include <brillo/dbus/dbus_signal_handler.h>
 #include <brillo/errors/error.h>
-#include <brillo/variant_dictionary.h>
 #include <dbus/bus.h>
 #include <dbus/message.h>
 #include <dbus/object_manager.h>
@@ -670,6 +965,8 @@ namespace test {
 // Abstract interface proxy for test::EmptyInterface.
 class EmptyInterfaceProxyInterface {
  public:
+  static constexpr char kInterfaceName[] = "test.EmptyInterface";
+
   virtual ~EmptyInterfaceProxyInterface() = default;
 
   virtual const dbus::ObjectPath& GetObjectPath() const = 0;
@@ -755,17 +1052,14 @@ func TestGenerateProxiesWithServiceName(t *testing.T) {
 #include <string>
 #include <vector>
 
-#include <base/files/scoped_file.h>
 #include <base/functional/bind.h>
 #include <base/functional/callback.h>
 #include <base/logging.h>
 #include <base/memory/ref_counted.h>
-#include <brillo/any.h>
 #include <brillo/dbus/dbus_method_invoker.h>
 #include <brillo/dbus/dbus_property.h>
 #include <brillo/dbus/dbus_signal_handler.h>
 #include <brillo/errors/error.h>
-#include <brillo/variant_dictionary.h>
 #include <dbus/bus.h>
 #include <dbus/message.h>
 #include <dbus/object_manager.h>
@@ -777,6 +1071,8 @@ namespace test {
 // Abstract interface proxy for test::EmptyInterface.
 class EmptyInterfaceProxyInterface {
  public:
+  static constexpr char kInterfaceName[] = "test.EmptyInterface";
+
   virtual ~EmptyInterfaceProxyInterface() = default;
 
   virtual const dbus::ObjectPath& GetObjectPath() const = 0;
@@ -859,17 +1155,14 @@ func TestGenerateProxiesWithNodeName(t *testing.T) {
 #include <string>
 #include <vector>
 
-#include <base/files/scoped_file.h>
 #include <base/functional/bind.h>
 #include <base/functional/callback.h>
 #include <base/logging.h>
 #include <base/memory/ref_counted.h>
-#include <brillo/any.h>
 #include <brillo/dbus/dbus_method_invoker.h>
 #include <brillo/dbus/dbus_property.h>
 #include <brillo/dbus/dbus_signal_handler.h>
 #include <brillo/errors/error.h>
-#include <brillo/variant_dictionary.h>
 #include <dbus/bus.h>
 #include <dbus/message.h>
 #include <dbus/object_manager.h>
@@ -881,6 +1174,8 @@ namespace test {
 // Abstract interface proxy for test::EmptyInterface.
 class EmptyInterfaceProxyInterface {
  public:
+  static constexpr char kInterfaceName[] = "test.EmptyInterface";
+
   virtual ~EmptyInterfaceProxyInterface() = default;
 
   virtual const dbus::ObjectPath& GetObjectPath() const = 0;
@@ -954,10 +1249,10 @@ func TestGenerateProxiesWithMethods(t *testing.T) {
 				{
 					Name: "iprotoArg",
 					Type: "ay",
-					Annotation: introspect.Annotation{
+					Annotations: []introspect.Annotation{{
 						Name:  "org.chromium.DBus.Argument.ProtobufClass",
 						Value: "RequestProto",
-					},
+					}},
 				},
 			},
 		}, {
@@ -970,10 +1265,10 @@ func TestGenerateProxiesWithMethods(t *testing.T) {
 					Name:      "oprotoArg",
 					Type:      "ay",
 					Direction: "out",
-					Annotation: introspect.Annotation{
+					Annotations: []introspect.Annotation{{
 						Name:  "org.chromium.DBus.Argument.ProtobufClass",
 						Value: "ResponseProto",
-					},
+					}},
 				},
 			},
 		}, {
@@ -1014,6 +1309,7 @@ func TestGenerateProxiesWithMethods(t *testing.T) {
 #define ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
 #include <memory>
 #include <string>
+#include <type_traits>
 #include <vector>
 
 #include <base/files/scoped_file.h>
@@ -1021,23 +1317,67 @@ func TestGenerateProxiesWithMethods(t *testing.T) {
 #include <base/functional/callback.h>
 #include <base/logging.h>
 #include <base/memory/ref_counted.h>
-#include <brillo/any.h>
 #include <brillo/dbus/dbus_method_invoker.h>
 #include <brillo/dbus/dbus_property.h>
 #include <brillo/dbus/dbus_signal_handler.h>
 #include <brillo/errors/error.h>
-#include <brillo/variant_dictionary.h>
 #include <dbus/bus.h>
 #include <dbus/message.h>
 #include <dbus/object_manager.h>
 #include <dbus/object_path.h>
 #include <dbus/object_proxy.h>
+#include <google/protobuf/message_lite.h>
+
+#ifndef CHROMEOS_DBUS_BINDINGS_DBUS_PROTOBUF_CLASS_CHECK_
+#define CHROMEOS_DBUS_BINDINGS_DBUS_PROTOBUF_CLASS_CHECK_
+namespace brillo {
+namespace dbus_utils {
+
+// Fails to compile unless T, given by an
+// org.chromium.DBus.Argument.ProtobufClass annotation, is a protobuf message.
+template <typename T>
+constexpr bool CheckProtobufClass() {
+  static_assert(std::is_base_of_v<google::protobuf::MessageLite, T>,
+                "The class given by org.chromium.DBus.Argument.ProtobufClass "
+                "is not a protobuf message; check the annotation and the "
+                "headers in org.chromium.DBus.Interface.ProtobufIncludes");
+  return true;
+}
+
+}  // namespace dbus_utils
+}  // namespace brillo
+#endif  // CHROMEOS_DBUS_BINDINGS_DBUS_PROTOBUF_CLASS_CHECK_
 
 namespace test {
 
+static_assert(
+    brillo::dbus_utils::CheckProtobufClass<RequestProto>());
+static_assert(
+    brillo::dbus_utils::CheckProtobufClass<ResponseProto>());
+
 // Abstract interface proxy for test::EmptyInterface.
 class EmptyInterfaceProxyInterface {
  public:
+  static constexpr char kInterfaceName[] = "test.EmptyInterface";
+  static constexpr char kMethodNoArgMethod[] = "MethodNoArg";
+  static constexpr char kMethodNoArgMethodInSignature[] = "";
+  static constexpr char kMethodNoArgMethodOutSignature[] = "";
+  static constexpr char kMethodWithInArgsMethod[] = "MethodWithInArgs";
+  static constexpr char kMethodWithInArgsMethodInSignature[] = "xay(ih)ay";
+  static constexpr char kMethodWithInArgsMethodOutSignature[] = "";
+  static constexpr char kMethodWithOutArgsMethod[] = "MethodWithOutArgs";
+  static constexpr char kMethodWithOutArgsMethodInSignature[] = "";
+  static constexpr char kMethodWithOutArgsMethodOutSignature[] = "xay(ih)ay";
+  static constexpr char kMethodWithBothArgsMethod[] = "MethodWithBothArgs";
+  static constexpr char kMethodWithBothArgsMethodInSignature[] = "xay";
+  static constexpr char kMethodWithBothArgsMethodOutSignature[] = "qd";
+  static constexpr char kMethodWithMixedArgsMethod[] = "MethodWithMixedArgs";
+  static constexpr char kMethodWithMixedArgsMethodInSignature[] = "xay";
+  static constexpr char kMethodWithMixedArgsMethodOutSignature[] = "qd";
+  static constexpr char kMethodWithDocMethod[] = "MethodWithDoc";
+  static constexpr char kMethodWithDocMethodInSignature[] = "";
+  static constexpr char kMethodWithDocMethodOutSignature[] = "";
+
   virtual ~EmptyInterfaceProxyInterface() = default;
 
   virtual bool MethodNoArg(
@@ -1049,6 +1389,7 @@ class EmptyInterfaceProxyInterface {
       base::OnceCallback<void(brillo::Error*)> error_callback,
       int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
 
+  // in_iarg3: struct<int32, file descriptor>
   virtual bool MethodWithInArgs(
       int64_t in_iarg1,
       const std::vector<uint8_t>& in_iarg2,
@@ -1057,6 +1398,7 @@ class EmptyInterfaceProxyInterface {
       brillo::ErrorPtr* error,
       int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
 
+  // in_iarg3: struct<int32, file descriptor>
   virtual void MethodWithInArgsAsync(
       int64_t in_iarg1,
       const std::vector<uint8_t>& in_iarg2,
@@ -1066,6 +1408,7 @@ class EmptyInterfaceProxyInterface {
       base::OnceCallback<void(brillo::Error*)> error_callback,
       int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
 
+  // out_oarg3: struct<int32, file descriptor>
   virtual bool MethodWithOutArgs(
       int64_t* out_oarg1,
       std::vector<uint8_t>* out_oarg2,
@@ -1074,6 +1417,7 @@ class EmptyInterfaceProxyInterface {
       brillo::ErrorPtr* error,
       int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
 
+  // oarg3: struct<int32, file descriptor>
   virtual void MethodWithOutArgsAsync(
       base::OnceCallback<void(int64_t /*oarg1*/, const std::vector<uint8_t>& /*oarg2*/, const std::tuple<int32_t, base::ScopedFD>& /*oarg3*/, const ResponseProto& /*oprotoArg*/)> success_callback,
       base::OnceCallback<void(brillo::Error*)> error_callback,
@@ -1386,10 +1730,10 @@ func TestGenerateProxiesWithSignals(t *testing.T) {
 					{
 						Name: "sarg1_1",
 						Type: "ay",
-						Annotation: introspect.Annotation{
+						Annotations: []introspect.Annotation{{
 							Name:  "org.chromium.DBus.Argument.ProtobufClass",
 							Value: "YetAnotherProto",
-						},
+						}},
 					}, {
 						Name: "sarg1_2",
 						Type: "(ih)",
@@ -1429,6 +1773,7 @@ func TestGenerateProxiesWithSignals(t *testing.T) {
 #define ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
 #include <memory>
 #include <string>
+#include <type_traits>
 #include <vector>
 
 #include <base/files/scoped_file.h>
@@ -1436,23 +1781,59 @@ func TestGenerateProxiesWithSignals(t *testing.T) {
 #include <base/functional/callback.h>
 #include <base/logging.h>
 #include <base/memory/ref_counted.h>
-#include <brillo/any.h>
+#include <base/memory/weak_ptr.h>
 #include <brillo/dbus/dbus_method_invoker.h>
 #include <brillo/dbus/dbus_property.h>
 #include <brillo/dbus/dbus_signal_handler.h>
 #include <brillo/errors/error.h>
-#include <brillo/variant_dictionary.h>
 #include <dbus/bus.h>
 #include <dbus/message.h>
 #include <dbus/object_manager.h>
 #include <dbus/object_path.h>
 #include <dbus/object_proxy.h>
+#include <google/protobuf/message_lite.h>
+
+#ifndef CHROMEOS_DBUS_BINDINGS_DBUS_PROTOBUF_CLASS_CHECK_
+#define CHROMEOS_DBUS_BINDINGS_DBUS_PROTOBUF_CLASS_CHECK_
+namespace brillo {
+namespace dbus_utils {
+
+// Fails to compile unless T, given by an
+// org.chromium.DBus.Argument.ProtobufClass annotation, is a protobuf message.
+template <typename T>
+constexpr bool CheckProtobufClass() {
+  static_assert(std::is_base_of_v<google::protobuf::MessageLite, T>,
+                "The class given by org.chromium.DBus.Argument.ProtobufClass "
+                "is not a protobuf message; check the annotation and the "
+                "headers in org.chromium.DBus.Interface.ProtobufIncludes");
+  return true;
+}
+
+}  // namespace dbus_utils
+}  // namespace brillo
+#endif  // CHROMEOS_DBUS_BINDINGS_DBUS_PROTOBUF_CLASS_CHECK_
 
 namespace test {
 
+static_assert(
+    brillo::dbus_utils::CheckProtobufClass<YetAnotherProto>());
+
 // Abstract interface proxy for test::EmptyInterface.
 class EmptyInterfaceProxyInterface {
  public:
+  static constexpr char kInterfaceName[] = "test.EmptyInterface";
+  static constexpr char kSignal1Signal[] = "Signal1";
+  static constexpr char kSignal1SignalSignature[] = "ay(ih)";
+  static constexpr char kSignal2Signal[] = "Signal2";
+  static constexpr char kSignal2SignalSignature[] = "ayi";
+
+  using Signal1SignalCallback =
+      base::RepeatingCallback<void(const YetAnotherProto& /*sarg1_1*/,
+                                   const std::tuple<int32_t, base::ScopedFD>& /*sarg1_2*/)>;
+  using Signal2SignalCallback =
+      base::RepeatingCallback<void(const std::vector<uint8_t>& /*sarg2_1*/,
+                                   int32_t /*sarg2_2*/)>;
+
   virtual ~EmptyInterfaceProxyInterface() = default;
 
   virtual void RegisterSignal1SignalHandler(
@@ -1460,11 +1841,35 @@ class EmptyInterfaceProxyInterface {
                                          const std::tuple<int32_t, base::ScopedFD>&)>& signal_callback,
       dbus::ObjectProxy::OnConnectedCallback on_connected_callback) = 0;
 
+  // Registers |method| of |target| as the handler of the Signal1 signal.
+  // The signals delivered after |target| is invalidated are dropped.
+  template <typename T>
+  void RegisterSignal1SignalHandlerWeak(
+      base::WeakPtr<T> target,
+      void (T::*method)(const YetAnotherProto&, const std::tuple<int32_t, base::ScopedFD>&),
+      dbus::ObjectProxy::OnConnectedCallback on_connected_callback) {
+    RegisterSignal1SignalHandler(
+        base::BindRepeating(method, std::move(target)),
+        std::move(on_connected_callback));
+  }
+
   virtual void RegisterSignal2SignalHandler(
       const base::RepeatingCallback<void(const std::vector<uint8_t>&,
                                          int32_t)>& signal_callback,
       dbus::ObjectProxy::OnConnectedCallback on_connected_callback) = 0;
 
+  // Registers |method| of |target| as the handler of the Signal2 signal.
+  // The signals delivered after |target| is invalidated are dropped.
+  template <typename T>
+  void RegisterSignal2SignalHandlerWeak(
+      base::WeakPtr<T> target,
+      void (T::*method)(const std::vector<uint8_t>&, int32_t),
+      dbus::ObjectProxy::OnConnectedCallback on_connected_callback) {
+    RegisterSignal2SignalHandler(
+        base::BindRepeating(method, std::move(target)),
+        std::move(on_connected_callback));
+  }
+
   virtual const dbus::ObjectPath& GetObjectPath() const = 0;
   virtual dbus::ObjectProxy* GetObjectProxy() const = 0;
 };
@@ -1584,12 +1989,10 @@ func TestGenerateProxiesWithProperties(t *testing.T) {
 #include <string>
 #include <vector>
 
-#include <base/files/scoped_file.h>
 #include <base/functional/bind.h>
 #include <base/functional/callback.h>
 #include <base/logging.h>
 #include <base/memory/ref_counted.h>
-#include <brillo/any.h>
 #include <brillo/dbus/dbus_method_invoker.h>
 #include <brillo/dbus/dbus_property.h>
 #include <brillo/dbus/dbus_signal_handler.h>
@@ -1606,16 +2009,54 @@ namespace test {
 // Abstract interface proxy for test::EmptyInterface.
 class EmptyInterfaceProxyInterface {
  public:
+  static constexpr char kInterfaceName[] = "test.EmptyInterface";
+  static constexpr char kReadonlyPropertyProperty[] = "ReadonlyProperty";
+  static constexpr char kReadonlyPropertyPropertySignature[] = "a{sv}";
+  static constexpr char kWritablePropertyProperty[] = "WritableProperty";
+  static constexpr char kWritablePropertyPropertySignature[] = "a{sv}";
+
   virtual ~EmptyInterfaceProxyInterface() = default;
 
   static const char* ReadonlyPropertyName() { return "ReadonlyProperty"; }
   virtual const brillo::VariantDictionary& readonly_property() const = 0;
   virtual bool is_readonly_property_valid() const = 0;
+  // Returns in |value| the entry of ReadonlyProperty keyed by |key|.
+  // Returns false if the property is not valid, has no such entry, or the
+  // entry holds another type than T.
+  template <typename T>
+  bool GetReadonlyPropertyEntry(const std::string& key, T* value) const {
+    if (!is_readonly_property_valid())
+      return false;
+    const brillo::VariantDictionary& dict = readonly_property();
+    auto it = dict.find(key);
+    if (it == dict.end() || !it->second.IsTypeCompatible<T>())
+      return false;
+    *value = it->second.Get<T>();
+    return true;
+  }
+  virtual void SetReadonlyPropertyChangedCallback(
+      const base::RepeatingCallback<void(const brillo::VariantDictionary&)>& callback) = 0;
   static const char* WritablePropertyName() { return "WritableProperty"; }
   virtual const brillo::VariantDictionary& writable_property() const = 0;
   virtual bool is_writable_property_valid() const = 0;
+  // Returns in |value| the entry of WritableProperty keyed by |key|.
+  // Returns false if the property is not valid, has no such entry, or the
+  // entry holds another type than T.
+  template <typename T>
+  bool GetWritablePropertyEntry(const std::string& key, T* value) const {
+    if (!is_writable_property_valid())
+      return false;
+    const brillo::VariantDictionary& dict = writable_property();
+    auto it = dict.find(key);
+    if (it == dict.end() || !it->second.IsTypeCompatible<T>())
+      return false;
+    *value = it->second.Get<T>();
+    return true;
+  }
   virtual void set_writable_property(const brillo::VariantDictionary& value,
                                      base::OnceCallback<void(bool)> callback) = 0;
+  virtual void SetWritablePropertyChangedCallback(
+      const base::RepeatingCallback<void(const brillo::VariantDictionary&)>& callback) = 0;
 
   virtual const dbus::ObjectPath& GetObjectPath() const = 0;
   virtual dbus::ObjectProxy* GetObjectProxy() const = 0;
@@ -1660,6 +2101,41 @@ class EmptyInterfaceProxy final : public EmptyInterfaceProxyInterface {
               bus_->GetObjectProxy(service_name_, object_path_)} {
   }
 
+  // How the constructor initializes the properties.
+  enum class InitializePropertiesPolicy {
+    // Creates the property set and connects the PropertiesChanged signal,
+    // leaving the values to be fetched on demand.
+    kLazy,
+    // Does what InitializeProperties() does, i.e. also fetches all the values.
+    kEager,
+    // Leaves the properties uninitialized, as the other constructor does.
+    kNone,
+  };
+
+  EmptyInterfaceProxy(
+      const scoped_refptr<dbus::Bus>& bus,
+      const std::string& service_name,
+      const dbus::ObjectPath& object_path,
+      InitializePropertiesPolicy policy,
+      const base::RepeatingCallback<void(EmptyInterfaceProxyInterface*, const std::string&)>& callback) :
+          EmptyInterfaceProxy(bus, service_name, object_path) {
+    switch (policy) {
+      case InitializePropertiesPolicy::kLazy:
+        on_property_changed_ = callback;
+        property_set_.reset(
+            new PropertySet(dbus_object_proxy_,
+                            base::BindRepeating(&EmptyInterfaceProxy::OnPropertyChanged,
+                                                base::Unretained(this))));
+        property_set_->ConnectSignals();
+        break;
+      case InitializePropertiesPolicy::kEager:
+        InitializeProperties(callback);
+        break;
+      case InitializePropertiesPolicy::kNone:
+        break;
+    }
+  }
+
   EmptyInterfaceProxy(const EmptyInterfaceProxy&) = delete;
   EmptyInterfaceProxy& operator=(const EmptyInterfaceProxy&) = delete;
 
@@ -1680,8 +2156,11 @@ class EmptyInterfaceProxy final : public EmptyInterfaceProxyInterface {
 
   void InitializeProperties(
       const base::RepeatingCallback<void(EmptyInterfaceProxyInterface*, const std::string&)>& callback) override {
+    on_property_changed_ = callback;
     property_set_.reset(
-        new PropertySet(dbus_object_proxy_, base::BindRepeating(callback, this)));
+        new PropertySet(dbus_object_proxy_,
+                        base::BindRepeating(&EmptyInterfaceProxy::OnPropertyChanged,
+                                            base::Unretained(this))));
     property_set_->ConnectSignals();
     property_set_->GetAll();
   }
@@ -1697,6 +2176,11 @@ class EmptyInterfaceProxy final : public EmptyInterfaceProxyInterface {
     return property_set_->readonly_property.is_valid();
   }
 
+  void SetReadonlyPropertyChangedCallback(
+      const base::RepeatingCallback<void(const brillo::VariantDictionary&)>& callback) override {
+    on_readonly_property_changed_ = callback;
+  }
+
   const brillo::VariantDictionary& writable_property() const override {
     return property_set_->writable_property.value();
   }
@@ -1710,10 +2194,27 @@ class EmptyInterfaceProxy final : public EmptyInterfaceProxyInterface {
     property_set_->writable_property.Set(value, std::move(callback));
   }
 
+  void SetWritablePropertyChangedCallback(
+      const base::RepeatingCallback<void(const brillo::VariantDictionary&)>& callback) override {
+    on_writable_property_changed_ = callback;
+  }
+
  private:
+  void OnPropertyChanged(const std::string& property_name) {
+    if (property_name == ReadonlyPropertyName() && !on_readonly_property_changed_.is_null())
+      on_readonly_property_changed_.Run(property_set_->readonly_property.value());
+    if (property_name == WritablePropertyName() && !on_writable_property_changed_.is_null())
+      on_writable_property_changed_.Run(property_set_->writable_property.value());
+    if (!on_property_changed_.is_null())
+      on_property_changed_.Run(this, property_name);
+  }
+
   scoped_refptr<dbus::Bus> bus_;
   std::string service_name_;
   dbus::ObjectPath object_path_;
+  base::RepeatingCallback<void(EmptyInterfaceProxyInterface*, const std::string&)> on_property_changed_;
+  base::RepeatingCallback<void(const brillo::VariantDictionary&)> on_readonly_property_changed_;
+  base::RepeatingCallback<void(const brillo::VariantDictionary&)> on_writable_property_changed_;
   dbus::ObjectProxy* dbus_object_proxy_;
   std::unique_ptr<PropertySet> property_set_;
 
@@ -1753,15 +2254,17 @@ func TestGenerateProxiesWithObjectManager(t *testing.T) {
 #ifndef ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
 #define ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
 #include <memory>
+#include <optional>
 #include <string>
 #include <vector>
 
-#include <base/files/scoped_file.h>
 #include <base/functional/bind.h>
 #include <base/functional/callback.h>
+#include <base/location.h>
 #include <base/logging.h>
 #include <base/memory/ref_counted.h>
-#include <brillo/any.h>
+#include <base/task/sequenced_task_runner.h>
+#include <base/time/time.h>
 #include <brillo/dbus/dbus_method_invoker.h>
 #include <brillo/dbus/dbus_property.h>
 #include <brillo/dbus/dbus_signal_handler.h>
@@ -1782,6 +2285,8 @@ namespace test {
 // Abstract interface proxy for test::EmptyInterface.
 class EmptyInterfaceProxyInterface {
  public:
+  static constexpr char kInterfaceName[] = "test.EmptyInterface";
+
   virtual ~EmptyInterfaceProxyInterface() = default;
 
   virtual const dbus::ObjectPath& GetObjectPath() const = 0;
@@ -1873,6 +2378,51 @@ class ObjectManagerProxy : public dbus::ObjectManager::Interface {
     return dbus_object_manager_;
   }
 
+  // The readable properties of test.EmptyInterface, as returned by
+  // GetManagedObjects().
+  struct EmptyInterfaceProperties {
+  };
+
+  // The known interfaces implemented by an object, as returned by
+  // GetManagedObjects(). An interface is set if the object implements it.
+  struct ManagedObject {
+    std::optional<EmptyInterfaceProperties> empty_interface;
+  };
+
+  // Calls org.freedesktop.DBus.ObjectManager.GetManagedObjects and converts
+  // the result into |objects| keyed by the object paths. The interfaces
+  // unknown to this proxy are ignored, and the missing properties are left
+  // default-initialized.
+  bool GetManagedObjects(
+      std::map<dbus::ObjectPath, ManagedObject>* objects,
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) {
+    std::map<dbus::ObjectPath,
+             std::map<std::string, brillo::VariantDictionary>> managed_objects;
+    auto response = brillo::dbus_utils::CallMethodAndBlockWithTimeout(
+        timeout_ms,
+        bus_->GetObjectProxy(service_name_,
+                             dbus::ObjectPath{""}),
+        "org.freedesktop.DBus.ObjectManager",
+        "GetManagedObjects",
+        error);
+    if (!response || !brillo::dbus_utils::ExtractMethodCallResults(
+            response.get(), error, &managed_objects)) {
+      return false;
+    }
+    objects->clear();
+    for (const auto& object : managed_objects) {
+      ManagedObject& managed_object = (*objects)[object.first];
+      for (const auto& itf : object.second) {
+        if (itf.first == "test.EmptyInterface") {
+          managed_object.empty_interface.emplace();
+          continue;
+        }
+      }
+    }
+    return true;
+  }
+
   test::EmptyInterfaceProxyInterface* GetEmptyInterfaceProxy(
       const dbus::ObjectPath& object_path) {
     auto p = empty_interface_instances_.find(object_path);
@@ -1895,8 +2445,72 @@ class ObjectManagerProxy : public dbus::ObjectManager::Interface {
       const base::RepeatingCallback<void(const dbus::ObjectPath&)>& callback) {
     on_empty_interface_removed_ = callback;
   }
+  // Runs |callback| with the proxy once the object at |object_path| is
+  // added, or with nullptr if it is not added within |timeout|. |callback|
+  // is run immediately if the object already exists.
+  void WaitForEmptyInterfaceProxy(
+      const dbus::ObjectPath& object_path,
+      base::TimeDelta timeout,
+      base::OnceCallback<void(test::EmptyInterfaceProxyInterface*)> callback) {
+    auto* proxy = GetEmptyInterfaceProxy(object_path);
+    if (proxy) {
+      std::move(callback).Run(proxy);
+      return;
+    }
+    int id = next_waiter_id_++;
+    empty_interface_waiters_.push_back(
+        {id, object_path, std::move(callback)});
+    base::SequencedTaskRunner::GetCurrentDefault()->PostDelayedTask(
+        FROM_HERE,
+        base::BindOnce(&ObjectManagerProxy::OnEmptyInterfaceWaitTimeout,
+                       weak_ptr_factory_.GetWeakPtr(), id),
+        timeout);
+  }
 
  private:
+  // A pending WaitFor...Proxy() call.
+  template <typename T>
+  struct Waiter {
+    int id;
+    dbus::ObjectPath object_path;
+    base::OnceCallback<void(T*)> callback;
+  };
+
+  // Removes the waiter |id| from |waiters| and runs its callback with
+  // nullptr, unless it has been run already.
+  template <typename T>
+  static void TimeOutWaiter(std::vector<Waiter<T>>* waiters, int id) {
+    for (auto it = waiters->begin(); it != waiters->end(); ++it) {
+      if (it->id == id) {
+        auto callback = std::move(it->callback);
+        waiters->erase(it);
+        std::move(callback).Run(nullptr);
+        return;
+      }
+    }
+  }
+
+  // Runs the callbacks of the waiters in |waiters| for the object at
+  // |object_path|, or of all of them if |any_path| is true, with |proxy|.
+  template <typename T>
+  static void NotifyWaiters(std::vector<Waiter<T>>* waiters,
+                            const dbus::ObjectPath& object_path,
+                            bool any_path,
+                            T* proxy) {
+    std::vector<Waiter<T>> pending;
+    pending.swap(*waiters);
+    for (auto& waiter : pending) {
+      if (any_path || waiter.object_path == object_path)
+        std::move(waiter.callback).Run(proxy);
+      else
+        waiters->push_back(std::move(waiter));
+    }
+  }
+
+  void OnEmptyInterfaceWaitTimeout(int id) {
+    TimeOutWaiter(&empty_interface_waiters_, id);
+  }
+
   void OnPropertyChanged(const dbus::ObjectPath& /* object_path */,
                          const std::string& /* interface_name */,
                          const std::string& /* property_name */) {}
@@ -1911,6 +2525,9 @@ class ObjectManagerProxy : public dbus::ObjectManager::Interface {
       auto p = empty_interface_instances_.emplace(object_path, std::move(empty_interface_proxy));
       if (!on_empty_interface_added_.is_null())
         on_empty_interface_added_.Run(p.first->second.get());
+      NotifyWaiters<test::EmptyInterfaceProxyInterface>(
+          &empty_interface_waiters_, object_path, false,
+          p.first->second.get());
       return;
     }
   }
@@ -1954,6 +2571,8 @@ class ObjectManagerProxy : public dbus::ObjectManager::Interface {
            std::unique_ptr<test::EmptyInterfaceProxy>> empty_interface_instances_;
   base::RepeatingCallback<void(test::EmptyInterfaceProxyInterface*)> on_empty_interface_added_;
   base::RepeatingCallback<void(const dbus::ObjectPath&)> on_empty_interface_removed_;
+  std::vector<Waiter<test::EmptyInterfaceProxyInterface>> empty_interface_waiters_;
+  int next_waiter_id_ = 0;
   base::WeakPtrFactory<ObjectManagerProxy> weak_ptr_factory_{this};
 };
 
@@ -1992,15 +2611,17 @@ func TestGenerateProxiesWithObjectManagerAndServiceName(t *testing.T) {
 #ifndef ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
 #define ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
 #include <memory>
+#include <optional>
 #include <string>
 #include <vector>
 
-#include <base/files/scoped_file.h>
 #include <base/functional/bind.h>
 #include <base/functional/callback.h>
+#include <base/location.h>
 #include <base/logging.h>
 #include <base/memory/ref_counted.h>
-#include <brillo/any.h>
+#include <base/task/sequenced_task_runner.h>
+#include <base/time/time.h>
 #include <brillo/dbus/dbus_method_invoker.h>
 #include <brillo/dbus/dbus_property.h>
 #include <brillo/dbus/dbus_signal_handler.h>
@@ -2021,6 +2642,8 @@ namespace test {
 // Abstract interface proxy for test::EmptyInterface.
 class EmptyInterfaceProxyInterface {
  public:
+  static constexpr char kInterfaceName[] = "test.EmptyInterface";
+
   virtual ~EmptyInterfaceProxyInterface() = default;
 
   virtual const dbus::ObjectPath& GetObjectPath() const = 0;
@@ -2108,6 +2731,51 @@ class ObjectManagerProxy : public dbus::ObjectManager::Interface {
     return dbus_object_manager_;
   }
 
+  // The readable properties of test.EmptyInterface, as returned by
+  // GetManagedObjects().
+  struct EmptyInterfaceProperties {
+  };
+
+  // The known interfaces implemented by an object, as returned by
+  // GetManagedObjects(). An interface is set if the object implements it.
+  struct ManagedObject {
+    std::optional<EmptyInterfaceProperties> empty_interface;
+  };
+
+  // Calls org.freedesktop.DBus.ObjectManager.GetManagedObjects and converts
+  // the result into |objects| keyed by the object paths. The interfaces
+  // unknown to this proxy are ignored, and the missing properties are left
+  // default-initialized.
+  bool GetManagedObjects(
+      std::map<dbus::ObjectPath, ManagedObject>* objects,
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) {
+    std::map<dbus::ObjectPath,
+             std::map<std::string, brillo::VariantDictionary>> managed_objects;
+    auto response = brillo::dbus_utils::CallMethodAndBlockWithTimeout(
+        timeout_ms,
+        bus_->GetObjectProxy("test.service.Name",
+                             dbus::ObjectPath{""}),
+        "org.freedesktop.DBus.ObjectManager",
+        "GetManagedObjects",
+        error);
+    if (!response || !brillo::dbus_utils::ExtractMethodCallResults(
+            response.get(), error, &managed_objects)) {
+      return false;
+    }
+    objects->clear();
+    for (const auto& object : managed_objects) {
+      ManagedObject& managed_object = (*objects)[object.first];
+      for (const auto& itf : object.second) {
+        if (itf.first == "test.EmptyInterface") {
+          managed_object.empty_interface.emplace();
+          continue;
+        }
+      }
+    }
+    return true;
+  }
+
   test::EmptyInterfaceProxyInterface* GetEmptyInterfaceProxy(
       const dbus::ObjectPath& object_path) {
     auto p = empty_interface_instances_.find(object_path);
@@ -2130,8 +2798,72 @@ class ObjectManagerProxy : public dbus::ObjectManager::Interface {
       const base::RepeatingCallback<void(const dbus::ObjectPath&)>& callback) {
     on_empty_interface_removed_ = callback;
   }
+  // Runs |callback| with the proxy once the object at |object_path| is
+  // added, or with nullptr if it is not added within |timeout|. |callback|
+  // is run immediately if the object already exists.
+  void WaitForEmptyInterfaceProxy(
+      const dbus::ObjectPath& object_path,
+      base::TimeDelta timeout,
+      base::OnceCallback<void(test::EmptyInterfaceProxyInterface*)> callback) {
+    auto* proxy = GetEmptyInterfaceProxy(object_path);
+    if (proxy) {
+      std::move(callback).Run(proxy);
+      return;
+    }
+    int id = next_waiter_id_++;
+    empty_interface_waiters_.push_back(
+        {id, object_path, std::move(callback)});
+    base::SequencedTaskRunner::GetCurrentDefault()->PostDelayedTask(
+        FROM_HERE,
+        base::BindOnce(&ObjectManagerProxy::OnEmptyInterfaceWaitTimeout,
+                       weak_ptr_factory_.GetWeakPtr(), id),
+        timeout);
+  }
 
  private:
+  // A pending WaitFor...Proxy() call.
+  template <typename T>
+  struct Waiter {
+    int id;
+    dbus::ObjectPath object_path;
+    base::OnceCallback<void(T*)> callback;
+  };
+
+  // Removes the waiter |id| from |waiters| and runs its callback with
+  // nullptr, unless it has been run already.
+  template <typename T>
+  static void TimeOutWaiter(std::vector<Waiter<T>>* waiters, int id) {
+    for (auto it = waiters->begin(); it != waiters->end(); ++it) {
+      if (it->id == id) {
+        auto callback = std::move(it->callback);
+        waiters->erase(it);
+        std::move(callback).Run(nullptr);
+        return;
+      }
+    }
+  }
+
+  // Runs the callbacks of the waiters in |waiters| for the object at
+  // |object_path|, or of all of them if |any_path| is true, with |proxy|.
+  template <typename T>
+  static void NotifyWaiters(std::vector<Waiter<T>>* waiters,
+                            const dbus::ObjectPath& object_path,
+                            bool any_path,
+                            T* proxy) {
+    std::vector<Waiter<T>> pending;
+    pending.swap(*waiters);
+    for (auto& waiter : pending) {
+      if (any_path || waiter.object_path == object_path)
+        std::move(waiter.callback).Run(proxy);
+      else
+        waiters->push_back(std::move(waiter));
+    }
+  }
+
+  void OnEmptyInterfaceWaitTimeout(int id) {
+    TimeOutWaiter(&empty_interface_waiters_, id);
+  }
+
   void OnPropertyChanged(const dbus::ObjectPath& /* object_path */,
                          const std::string& /* interface_name */,
                          const std::string& /* property_name */) {}
@@ -2146,6 +2878,9 @@ class ObjectManagerProxy : public dbus::ObjectManager::Interface {
       auto p = empty_interface_instances_.emplace(object_path, std::move(empty_interface_proxy));
       if (!on_empty_interface_added_.is_null())
         on_empty_interface_added_.Run(p.first->second.get());
+      NotifyWaiters<test::EmptyInterfaceProxyInterface>(
+          &empty_interface_waiters_, object_path, false,
+          p.first->second.get());
       return;
     }
   }
@@ -2188,6 +2923,8 @@ class ObjectManagerProxy : public dbus::ObjectManager::Interface {
            std::unique_ptr<test::EmptyInterfaceProxy>> empty_interface_instances_;
   base::RepeatingCallback<void(test::EmptyInterfaceProxyInterface*)> on_empty_interface_added_;
   base::RepeatingCallback<void(const dbus::ObjectPath&)> on_empty_interface_removed_;
+  std::vector<Waiter<test::EmptyInterfaceProxyInterface>> empty_interface_waiters_;
+  int next_waiter_id_ = 0;
   base::WeakPtrFactory<ObjectManagerProxy> weak_ptr_factory_{this};
 };
 
@@ -2233,15 +2970,17 @@ func TestGenerateProxiesWithPropertiesAndObjectManager(t *testing.T) {
 #ifndef ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
 #define ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
 #include <memory>
+#include <optional>
 #include <string>
 #include <vector>
 
-#include <base/files/scoped_file.h>
 #include <base/functional/bind.h>
 #include <base/functional/callback.h>
+#include <base/location.h>
 #include <base/logging.h>
 #include <base/memory/ref_counted.h>
-#include <brillo/any.h>
+#include <base/task/sequenced_task_runner.h>
+#include <base/time/time.h>
 #include <brillo/dbus/dbus_method_invoker.h>
 #include <brillo/dbus/dbus_property.h>
 #include <brillo/dbus/dbus_signal_handler.h>
@@ -2262,11 +3001,31 @@ namespace test {
 // Abstract interface proxy for test::EmptyInterface.
 class EmptyInterfaceProxyInterface {
  public:
+  static constexpr char kInterfaceName[] = "test.EmptyInterface";
+  static constexpr char kCapabilitiesProperty[] = "Capabilities";
+  static constexpr char kCapabilitiesPropertySignature[] = "a{sv}";
+
   virtual ~EmptyInterfaceProxyInterface() = default;
 
   static const char* CapabilitiesName() { return "Capabilities"; }
   virtual const brillo::VariantDictionary& capabilities() const = 0;
   virtual bool is_capabilities_valid() const = 0;
+  // Returns in |value| the entry of Capabilities keyed by |key|.
+  // Returns false if the property is not valid, has no such entry, or the
+  // entry holds another type than T.
+  template <typename T>
+  bool GetCapabilitiesEntry(const std::string& key, T* value) const {
+    if (!is_capabilities_valid())
+      return false;
+    const brillo::VariantDictionary& dict = capabilities();
+    auto it = dict.find(key);
+    if (it == dict.end() || !it->second.IsTypeCompatible<T>())
+      return false;
+    *value = it->second.Get<T>();
+    return true;
+  }
+  virtual void SetCapabilitiesChangedCallback(
+      const base::RepeatingCallback<void(const brillo::VariantDictionary&)>& callback) = 0;
 
   virtual const dbus::ObjectPath& GetObjectPath() const = 0;
   virtual dbus::ObjectProxy* GetObjectProxy() const = 0;
@@ -2345,8 +3104,15 @@ class EmptyInterfaceProxy final : public EmptyInterfaceProxyInterface {
     return property_set_->capabilities.is_valid();
   }
 
+  void SetCapabilitiesChangedCallback(
+      const base::RepeatingCallback<void(const brillo::VariantDictionary&)>& callback) override {
+    on_capabilities_changed_ = callback;
+  }
+
  private:
   void OnPropertyChanged(const std::string& property_name) {
+    if (property_name == CapabilitiesName() && !on_capabilities_changed_.is_null())
+      on_capabilities_changed_.Run(property_set_->capabilities.value());
     if (!on_property_changed_.is_null())
       on_property_changed_.Run(this, property_name);
   }
@@ -2356,6 +3122,7 @@ class EmptyInterfaceProxy final : public EmptyInterfaceProxyInterface {
   dbus::ObjectPath object_path_;
   PropertySet* property_set_;
   base::RepeatingCallback<void(EmptyInterfaceProxyInterface*, const std::string&)> on_property_changed_;
+  base::RepeatingCallback<void(const brillo::VariantDictionary&)> on_capabilities_changed_;
   dbus::ObjectProxy* dbus_object_proxy_;
 
   friend class test::ObjectManagerProxy;
@@ -2388,6 +3155,56 @@ class ObjectManagerProxy : public dbus::ObjectManager::Interface {
     return dbus_object_manager_;
   }
 
+  // The readable properties of test.EmptyInterface, as returned by
+  // GetManagedObjects().
+  struct EmptyInterfaceProperties {
+    brillo::VariantDictionary capabilities{};
+  };
+
+  // The known interfaces implemented by an object, as returned by
+  // GetManagedObjects(). An interface is set if the object implements it.
+  struct ManagedObject {
+    std::optional<EmptyInterfaceProperties> empty_interface;
+  };
+
+  // Calls org.freedesktop.DBus.ObjectManager.GetManagedObjects and converts
+  // the result into |objects| keyed by the object paths. The interfaces
+  // unknown to this proxy are ignored, and the missing properties are left
+  // default-initialized.
+  bool GetManagedObjects(
+      std::map<dbus::ObjectPath, ManagedObject>* objects,
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) {
+    std::map<dbus::ObjectPath,
+             std::map<std::string, brillo::VariantDictionary>> managed_objects;
+    auto response = brillo::dbus_utils::CallMethodAndBlockWithTimeout(
+        timeout_ms,
+        bus_->GetObjectProxy(service_name_,
+                             dbus::ObjectPath{""}),
+        "org.freedesktop.DBus.ObjectManager",
+        "GetManagedObjects",
+        error);
+    if (!response || !brillo::dbus_utils::ExtractMethodCallResults(
+            response.get(), error, &managed_objects)) {
+      return false;
+    }
+    objects->clear();
+    for (const auto& object : managed_objects) {
+      ManagedObject& managed_object = (*objects)[object.first];
+      for (const auto& itf : object.second) {
+        if (itf.first == "test.EmptyInterface") {
+          EmptyInterfaceProperties& properties =
+              managed_object.empty_interface.emplace();
+          properties.capabilities =
+              brillo::GetVariantValueOrDefault<brillo::VariantDictionary>(
+                  itf.second, "Capabilities");
+          continue;
+        }
+      }
+    }
+    return true;
+  }
+
   test::EmptyInterfaceProxyInterface* GetEmptyInterfaceProxy(
       const dbus::ObjectPath& object_path) {
     auto p = empty_interface_instances_.find(object_path);
@@ -2410,8 +3227,72 @@ class ObjectManagerProxy : public dbus::ObjectManager::Interface {
       const base::RepeatingCallback<void(const dbus::ObjectPath&)>& callback) {
     on_empty_interface_removed_ = callback;
   }
+  // Runs |callback| with the proxy once the object at |object_path| is
+  // added, or with nullptr if it is not added within |timeout|. |callback|
+  // is run immediately if the object already exists.
+  void WaitForEmptyInterfaceProxy(
+      const dbus::ObjectPath& object_path,
+      base::TimeDelta timeout,
+      base::OnceCallback<void(test::EmptyInterfaceProxyInterface*)> callback) {
+    auto* proxy = GetEmptyInterfaceProxy(object_path);
+    if (proxy) {
+      std::move(callback).Run(proxy);
+      return;
+    }
+    int id = next_waiter_id_++;
+    empty_interface_waiters_.push_back(
+        {id, object_path, std::move(callback)});
+    base::SequencedTaskRunner::GetCurrentDefault()->PostDelayedTask(
+        FROM_HERE,
+        base::BindOnce(&ObjectManagerProxy::OnEmptyInterfaceWaitTimeout,
+                       weak_ptr_factory_.GetWeakPtr(), id),
+        timeout);
+  }
 
  private:
+  // A pending WaitFor...Proxy() call.
+  template <typename T>
+  struct Waiter {
+    int id;
+    dbus::ObjectPath object_path;
+    base::OnceCallback<void(T*)> callback;
+  };
+
+  // Removes the waiter |id| from |waiters| and runs its callback with
+  // nullptr, unless it has been run already.
+  template <typename T>
+  static void TimeOutWaiter(std::vector<Waiter<T>>* waiters, int id) {
+    for (auto it = waiters->begin(); it != waiters->end(); ++it) {
+      if (it->id == id) {
+        auto callback = std::move(it->callback);
+        waiters->erase(it);
+        std::move(callback).Run(nullptr);
+        return;
+      }
+    }
+  }
+
+  // Runs the callbacks of the waiters in |waiters| for the object at
+  // |object_path|, or of all of them if |any_path| is true, with |proxy|.
+  template <typename T>
+  static void NotifyWaiters(std::vector<Waiter<T>>* waiters,
+                            const dbus::ObjectPath& object_path,
+                            bool any_path,
+                            T* proxy) {
+    std::vector<Waiter<T>> pending;
+    pending.swap(*waiters);
+    for (auto& waiter : pending) {
+      if (any_path || waiter.object_path == object_path)
+        std::move(waiter.callback).Run(proxy);
+      else
+        waiters->push_back(std::move(waiter));
+    }
+  }
+
+  void OnEmptyInterfaceWaitTimeout(int id) {
+    TimeOutWaiter(&empty_interface_waiters_, id);
+  }
+
   void OnPropertyChanged(const dbus::ObjectPath& object_path,
                          const std::string& interface_name,
                          const std::string& property_name) {
@@ -2437,6 +3318,9 @@ class ObjectManagerProxy : public dbus::ObjectManager::Interface {
       auto p = empty_interface_instances_.emplace(object_path, std::move(empty_interface_proxy));
       if (!on_empty_interface_added_.is_null())
         on_empty_interface_added_.Run(p.first->second.get());
+      NotifyWaiters<test::EmptyInterfaceProxyInterface>(
+          &empty_interface_waiters_, object_path, false,
+          p.first->second.get());
       return;
     }
   }
@@ -2480,6 +3364,8 @@ class ObjectManagerProxy : public dbus::ObjectManager::Interface {
            std::unique_ptr<test::EmptyInterfaceProxy>> empty_interface_instances_;
   base::RepeatingCallback<void(test::EmptyInterfaceProxyInterface*)> on_empty_interface_added_;
   base::RepeatingCallback<void(const dbus::ObjectPath&)> on_empty_interface_removed_;
+  std::vector<Waiter<test::EmptyInterfaceProxyInterface>> empty_interface_waiters_;
+  int next_waiter_id_ = 0;
   base::WeakPtrFactory<ObjectManagerProxy> weak_ptr_factory_{this};
 };
 
@@ -2492,3 +3378,8871 @@ class ObjectManagerProxy : public dbus::ObjectManager::Interface {
 		t.Errorf("Generate failed (-got +want):\n%s", diff)
 	}
 }
+
+func TestGenerateProxiesWithCamelCaseNames(t *testing.T) {
+	itf := introspect.Interface{
+		Name: "test.Interface",
+		Methods: []introspect.Method{
+			{
+				Name: "GetValue",
+				Args: []introspect.MethodArg{
+					{Name: "key_name", Type: "s", Direction: "in"},
+					{Name: "value", Type: "i", Direction: "out"},
+				},
+			},
+		},
+		Properties: []introspect.Property{
+			{
+				Name:   "ScanInterval",
+				Type:   "i",
+				Access: "readwrite",
+			},
+		},
+	}
+
+	introspections := []introspect.Introspection{{
+		Interfaces: []introspect.Interface{itf},
+	}}
+
+	sc := serviceconfig.Config{
+		NamingStyle: serviceconfig.NamingStyleCamelCase,
+	}
+	out := new(bytes.Buffer)
+	if err := Generate(introspections, out, "/tmp/proxy.h", sc); err != nil {
+		t.Fatalf("Generate got error, want nil: %v", err)
+	}
+
+	const want = `// Automatic generation of D-Bus interfaces:
+//  - test.Interface
+#ifndef ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+#define ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+#include <memory>
+#include <string>
+#include <vector>
+
+#include <base/functional/bind.h>
+#include <base/functional/callback.h>
+#include <base/logging.h>
+#include <base/memory/ref_counted.h>
+#include <brillo/dbus/dbus_method_invoker.h>
+#include <brillo/dbus/dbus_property.h>
+#include <brillo/dbus/dbus_signal_handler.h>
+#include <brillo/errors/error.h>
+#include <dbus/bus.h>
+#include <dbus/message.h>
+#include <dbus/object_manager.h>
+#include <dbus/object_path.h>
+#include <dbus/object_proxy.h>
+
+namespace test {
+
+// Abstract interface proxy for test::Interface.
+class InterfaceProxyInterface {
+ public:
+  static constexpr char kInterfaceName[] = "test.Interface";
+  static constexpr char kGetValueMethod[] = "GetValue";
+  static constexpr char kGetValueMethodInSignature[] = "s";
+  static constexpr char kGetValueMethodOutSignature[] = "i";
+  static constexpr char kScanIntervalProperty[] = "ScanInterval";
+  static constexpr char kScanIntervalPropertySignature[] = "i";
+
+  virtual ~InterfaceProxyInterface() = default;
+
+  virtual bool GetValue(
+      const std::string& inKeyName,
+      int32_t* outValue,
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
+
+  virtual void GetValueAsync(
+      const std::string& inKeyName,
+      base::OnceCallback<void(int32_t /*value*/)> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
+
+  static const char* ScanIntervalName() { return "ScanInterval"; }
+  virtual int32_t ScanInterval() const = 0;
+  virtual bool IsScanIntervalValid() const = 0;
+  virtual void SetScanInterval(int32_t value,
+                               base::OnceCallback<void(bool)> callback) = 0;
+  virtual void SetScanIntervalChangedCallback(
+      const base::RepeatingCallback<void(int32_t)>& callback) = 0;
+
+  virtual const dbus::ObjectPath& GetObjectPath() const = 0;
+  virtual dbus::ObjectProxy* GetObjectProxy() const = 0;
+
+  virtual void InitializeProperties(
+      const base::RepeatingCallback<void(InterfaceProxyInterface*, const std::string&)>& callback) = 0;
+};
+
+}  // namespace test
+
+namespace test {
+
+// Interface proxy for test::Interface.
+class InterfaceProxy final : public InterfaceProxyInterface {
+ public:
+  class PropertySet : public dbus::PropertySet {
+   public:
+    PropertySet(dbus::ObjectProxy* object_proxy,
+                const PropertyChangedCallback& callback)
+        : dbus::PropertySet{object_proxy,
+                            "test.Interface",
+                            callback} {
+      RegisterProperty(ScanIntervalName(), &scan_interval);
+    }
+    PropertySet(const PropertySet&) = delete;
+    PropertySet& operator=(const PropertySet&) = delete;
+
+    brillo::dbus_utils::Property<int32_t> scan_interval;
+
+  };
+
+  InterfaceProxy(
+      const scoped_refptr<dbus::Bus>& bus,
+      const std::string& service_name,
+      const dbus::ObjectPath& object_path) :
+          bus_{bus},
+          service_name_{service_name},
+          object_path_{object_path},
+          dbus_object_proxy_{
+              bus_->GetObjectProxy(service_name_, object_path_)} {
+  }
+
+  // How the constructor initializes the properties.
+  enum class InitializePropertiesPolicy {
+    // Creates the property set and connects the PropertiesChanged signal,
+    // leaving the values to be fetched on demand.
+    kLazy,
+    // Does what InitializeProperties() does, i.e. also fetches all the values.
+    kEager,
+    // Leaves the properties uninitialized, as the other constructor does.
+    kNone,
+  };
+
+  InterfaceProxy(
+      const scoped_refptr<dbus::Bus>& bus,
+      const std::string& service_name,
+      const dbus::ObjectPath& object_path,
+      InitializePropertiesPolicy policy,
+      const base::RepeatingCallback<void(InterfaceProxyInterface*, const std::string&)>& callback) :
+          InterfaceProxy(bus, service_name, object_path) {
+    switch (policy) {
+      case InitializePropertiesPolicy::kLazy:
+        on_property_changed_ = callback;
+        property_set_.reset(
+            new PropertySet(dbus_object_proxy_,
+                            base::BindRepeating(&InterfaceProxy::OnPropertyChanged,
+                                                base::Unretained(this))));
+        property_set_->ConnectSignals();
+        break;
+      case InitializePropertiesPolicy::kEager:
+        InitializeProperties(callback);
+        break;
+      case InitializePropertiesPolicy::kNone:
+        break;
+    }
+  }
+
+  InterfaceProxy(const InterfaceProxy&) = delete;
+  InterfaceProxy& operator=(const InterfaceProxy&) = delete;
+
+  ~InterfaceProxy() override {
+  }
+
+  void ReleaseObjectProxy(base::OnceClosure callback) {
+    bus_->RemoveObjectProxy(service_name_, object_path_, std::move(callback));
+  }
+
+  const dbus::ObjectPath& GetObjectPath() const override {
+    return object_path_;
+  }
+
+  dbus::ObjectProxy* GetObjectProxy() const override {
+    return dbus_object_proxy_;
+  }
+
+  void InitializeProperties(
+      const base::RepeatingCallback<void(InterfaceProxyInterface*, const std::string&)>& callback) override {
+    on_property_changed_ = callback;
+    property_set_.reset(
+        new PropertySet(dbus_object_proxy_,
+                        base::BindRepeating(&InterfaceProxy::OnPropertyChanged,
+                                            base::Unretained(this))));
+    property_set_->ConnectSignals();
+    property_set_->GetAll();
+  }
+
+  const PropertySet* GetProperties() const { return &(*property_set_); }
+  PropertySet* GetProperties() { return &(*property_set_); }
+
+  bool GetValue(
+      const std::string& inKeyName,
+      int32_t* outValue,
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override {
+    auto response = brillo::dbus_utils::CallMethodAndBlockWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "test.Interface",
+        "GetValue",
+        error,
+        inKeyName);
+    return response && brillo::dbus_utils::ExtractMethodCallResults(
+        response.get(), error, outValue);
+  }
+
+  void GetValueAsync(
+      const std::string& inKeyName,
+      base::OnceCallback<void(int32_t /*value*/)> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override {
+    brillo::dbus_utils::CallMethodWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "test.Interface",
+        "GetValue",
+        std::move(success_callback),
+        std::move(error_callback),
+        inKeyName);
+  }
+
+  int32_t ScanInterval() const override {
+    return property_set_->scan_interval.value();
+  }
+
+  bool IsScanIntervalValid() const override {
+    return property_set_->scan_interval.is_valid();
+  }
+
+  void SetScanInterval(int32_t value,
+                       base::OnceCallback<void(bool)> callback) override {
+    property_set_->scan_interval.Set(value, std::move(callback));
+  }
+
+  void SetScanIntervalChangedCallback(
+      const base::RepeatingCallback<void(int32_t)>& callback) override {
+    on_scan_interval_changed_ = callback;
+  }
+
+ private:
+  void OnPropertyChanged(const std::string& property_name) {
+    if (property_name == ScanIntervalName() && !on_scan_interval_changed_.is_null())
+      on_scan_interval_changed_.Run(property_set_->scan_interval.value());
+    if (!on_property_changed_.is_null())
+      on_property_changed_.Run(this, property_name);
+  }
+
+  scoped_refptr<dbus::Bus> bus_;
+  std::string service_name_;
+  dbus::ObjectPath object_path_;
+  base::RepeatingCallback<void(InterfaceProxyInterface*, const std::string&)> on_property_changed_;
+  base::RepeatingCallback<void(int32_t)> on_scan_interval_changed_;
+  dbus::ObjectProxy* dbus_object_proxy_;
+  std::unique_ptr<PropertySet> property_set_;
+
+};
+
+}  // namespace test
+
+#endif  // ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+`
+
+	if diff := cmp.Diff(out.String(), want); diff != "" {
+		t.Errorf("Generate failed (-got +want):\n%s", diff)
+	}
+}
+
+func TestGenerateProxiesWithNamedStructs(t *testing.T) {
+	itf := introspect.Interface{
+		Name: "test.Interface",
+		Methods: []introspect.Method{
+			{
+				Name: "Scan",
+				Args: []introspect.MethodArg{
+					{
+						Name: "request", Type: "(ssu)", Direction: "in",
+						Annotations: []introspect.Annotation{{
+							Name:  "org.chromium.DBus.Struct.FieldNames",
+							Value: "ScanRequest(name, type, count)",
+						}},
+					},
+					{
+						Name: "results", Type: "a(ssu)", Direction: "out",
+						Annotations: []introspect.Annotation{{
+							Name:  "org.chromium.DBus.Struct.FieldNames",
+							Value: "ScanRequest(name, type, count)",
+						}},
+					},
+				},
+			},
+		},
+	}
+
+	introspections := []introspect.Introspection{{
+		Interfaces: []introspect.Interface{itf},
+	}}
+
+	out := new(bytes.Buffer)
+	if err := Generate(introspections, out, "/tmp/proxy.h", serviceconfig.Config{}); err != nil {
+		t.Fatalf("Generate got error, want nil: %v", err)
+	}
+
+	const want = `// Automatic generation of D-Bus interfaces:
+//  - test.Interface
+#ifndef ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+#define ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+#include <memory>
+#include <string>
+#include <vector>
+
+#include <base/functional/bind.h>
+#include <base/functional/callback.h>
+#include <base/logging.h>
+#include <base/memory/ref_counted.h>
+#include <brillo/dbus/dbus_method_invoker.h>
+#include <brillo/dbus/dbus_property.h>
+#include <brillo/dbus/dbus_signal_handler.h>
+#include <brillo/errors/error.h>
+#include <dbus/bus.h>
+#include <dbus/message.h>
+#include <dbus/object_manager.h>
+#include <dbus/object_path.h>
+#include <dbus/object_proxy.h>
+
+#ifndef ____CHROMEOS_DBUS_BINDING__STRUCT__TEST__SCANREQUEST
+#define ____CHROMEOS_DBUS_BINDING__STRUCT__TEST__SCANREQUEST
+namespace test {
+
+struct ScanRequest {
+  std::string name;
+  std::string type;
+  uint32_t count;
+};
+
+}  // namespace test
+
+namespace brillo {
+namespace dbus_utils {
+
+template <>
+struct DBusType<test::ScanRequest> {
+  using Tuple = std::tuple<std::string, std::string, uint32_t>;
+
+  inline static std::string GetSignature() { return "(ssu)"; }
+  inline static void Write(dbus::MessageWriter* writer,
+                           const test::ScanRequest& value) {
+    DBusType<Tuple>::Write(writer, Tuple(value.name, value.type, value.count));
+  }
+  inline static bool Read(dbus::MessageReader* reader,
+                          test::ScanRequest* value) {
+    Tuple tuple;
+    if (!DBusType<Tuple>::Read(reader, &tuple))
+      return false;
+    std::tie(value->name, value->type, value->count) = std::move(tuple);
+    return true;
+  }
+};
+
+}  // namespace dbus_utils
+}  // namespace brillo
+#endif  // ____CHROMEOS_DBUS_BINDING__STRUCT__TEST__SCANREQUEST
+
+namespace test {
+
+// Abstract interface proxy for test::Interface.
+class InterfaceProxyInterface {
+ public:
+  static constexpr char kInterfaceName[] = "test.Interface";
+  static constexpr char kScanMethod[] = "Scan";
+  static constexpr char kScanMethodInSignature[] = "(ssu)";
+  static constexpr char kScanMethodOutSignature[] = "a(ssu)";
+
+  virtual ~InterfaceProxyInterface() = default;
+
+  virtual bool Scan(
+      const ScanRequest& in_request,
+      std::vector<ScanRequest>* out_results,
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
+
+  virtual void ScanAsync(
+      const ScanRequest& in_request,
+      base::OnceCallback<void(const std::vector<ScanRequest>& /*results*/)> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
+
+  virtual const dbus::ObjectPath& GetObjectPath() const = 0;
+  virtual dbus::ObjectProxy* GetObjectProxy() const = 0;
+};
+
+}  // namespace test
+
+namespace test {
+
+// Interface proxy for test::Interface.
+class InterfaceProxy final : public InterfaceProxyInterface {
+ public:
+  InterfaceProxy(
+      const scoped_refptr<dbus::Bus>& bus,
+      const std::string& service_name,
+      const dbus::ObjectPath& object_path) :
+          bus_{bus},
+          service_name_{service_name},
+          object_path_{object_path},
+          dbus_object_proxy_{
+              bus_->GetObjectProxy(service_name_, object_path_)} {
+  }
+
+  InterfaceProxy(const InterfaceProxy&) = delete;
+  InterfaceProxy& operator=(const InterfaceProxy&) = delete;
+
+  ~InterfaceProxy() override {
+  }
+
+  void ReleaseObjectProxy(base::OnceClosure callback) {
+    bus_->RemoveObjectProxy(service_name_, object_path_, std::move(callback));
+  }
+
+  const dbus::ObjectPath& GetObjectPath() const override {
+    return object_path_;
+  }
+
+  dbus::ObjectProxy* GetObjectProxy() const override {
+    return dbus_object_proxy_;
+  }
+
+  bool Scan(
+      const ScanRequest& in_request,
+      std::vector<ScanRequest>* out_results,
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override {
+    auto response = brillo::dbus_utils::CallMethodAndBlockWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "test.Interface",
+        "Scan",
+        error,
+        in_request);
+    return response && brillo::dbus_utils::ExtractMethodCallResults(
+        response.get(), error, out_results);
+  }
+
+  void ScanAsync(
+      const ScanRequest& in_request,
+      base::OnceCallback<void(const std::vector<ScanRequest>& /*results*/)> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override {
+    brillo::dbus_utils::CallMethodWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "test.Interface",
+        "Scan",
+        std::move(success_callback),
+        std::move(error_callback),
+        in_request);
+  }
+
+ private:
+  scoped_refptr<dbus::Bus> bus_;
+  std::string service_name_;
+  dbus::ObjectPath object_path_;
+  dbus::ObjectProxy* dbus_object_proxy_;
+
+};
+
+}  // namespace test
+
+#endif  // ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+`
+	if diff := cmp.Diff(out.String(), want); diff != "" {
+		t.Errorf("Generate failed (-got +want):\n%s", diff)
+	}
+}
+
+func TestGenerateProxiesWithFDStream(t *testing.T) {
+	itf := introspect.Interface{
+		Name: "org.chromium.debugd",
+		Methods: []introspect.Method{
+			{
+				Name: "GetLogs",
+				Args: []introspect.MethodArg{
+					{Name: "name", Type: "s", Direction: "in"},
+					{Name: "outfd", Type: "h", Direction: "out"},
+				},
+				Annotations: []introspect.Annotation{
+					{Name: "org.chromium.DBus.Method.ReturnsFDStream", Value: "true"},
+				},
+			},
+		},
+	}
+
+	introspections := []introspect.Introspection{{
+		Interfaces: []introspect.Interface{itf},
+	}}
+
+	out := new(bytes.Buffer)
+	if err := Generate(introspections, out, "/tmp/proxy.h", serviceconfig.Config{}); err != nil {
+		t.Fatalf("Generate got error, want nil: %v", err)
+	}
+
+	const want = `// Automatic generation of D-Bus interfaces:
+//  - org.chromium.debugd
+#ifndef ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+#define ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+#include <memory>
+#include <string>
+#include <vector>
+
+#include <base/files/file_util.h>
+#include <base/files/scoped_file.h>
+#include <base/functional/bind.h>
+#include <base/functional/callback.h>
+#include <base/logging.h>
+#include <base/memory/ref_counted.h>
+#include <brillo/dbus/dbus_method_invoker.h>
+#include <brillo/dbus/dbus_property.h>
+#include <brillo/dbus/dbus_signal_handler.h>
+#include <brillo/errors/error.h>
+#include <dbus/bus.h>
+#include <dbus/message.h>
+#include <dbus/object_manager.h>
+#include <dbus/object_path.h>
+#include <dbus/object_proxy.h>
+
+namespace org {
+namespace chromium {
+
+// Abstract interface proxy for org::chromium::debugd.
+class debugdProxyInterface {
+ public:
+  static constexpr char kInterfaceName[] = "org.chromium.debugd";
+  static constexpr char kGetLogsMethod[] = "GetLogs";
+  static constexpr char kGetLogsMethodInSignature[] = "s";
+  static constexpr char kGetLogsMethodOutSignature[] = "h";
+
+  virtual ~debugdProxyInterface() = default;
+
+  virtual bool GetLogs(
+      const std::string& in_name,
+      base::ScopedFD* out_outfd,
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
+
+  virtual void GetLogsAsync(
+      const std::string& in_name,
+      base::OnceCallback<void(const base::ScopedFD& /*outfd*/)> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
+
+  // Calls GetLogs() and returns the file descriptor to read the stream from
+  // with ReadStreamChunk(), or an invalid one on failure.
+  base::ScopedFD GetLogsStream(
+      const std::string& in_name,
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) {
+    base::ScopedFD fd;
+    if (!GetLogs(in_name, &fd, error, timeout_ms))
+      return base::ScopedFD();
+    return fd;
+  }
+
+  // Reads a chunk of the stream returned by the *Stream() methods. Each chunk
+  // is prefixed by its size as a uint32_t in host byte order.
+  // Returns false at the end of the stream or on failure.
+  static bool ReadStreamChunk(int fd, std::string* chunk) {
+    uint32_t size;
+    if (!base::ReadFromFD(fd, reinterpret_cast<char*>(&size), sizeof(size)))
+      return false;
+    chunk->resize(size);
+    return base::ReadFromFD(fd, chunk->data(), size);
+  }
+
+  virtual const dbus::ObjectPath& GetObjectPath() const = 0;
+  virtual dbus::ObjectProxy* GetObjectProxy() const = 0;
+};
+
+}  // namespace chromium
+}  // namespace org
+
+namespace org {
+namespace chromium {
+
+// Interface proxy for org::chromium::debugd.
+class debugdProxy final : public debugdProxyInterface {
+ public:
+  debugdProxy(
+      const scoped_refptr<dbus::Bus>& bus,
+      const std::string& service_name,
+      const dbus::ObjectPath& object_path) :
+          bus_{bus},
+          service_name_{service_name},
+          object_path_{object_path},
+          dbus_object_proxy_{
+              bus_->GetObjectProxy(service_name_, object_path_)} {
+  }
+
+  debugdProxy(const debugdProxy&) = delete;
+  debugdProxy& operator=(const debugdProxy&) = delete;
+
+  ~debugdProxy() override {
+  }
+
+  void ReleaseObjectProxy(base::OnceClosure callback) {
+    bus_->RemoveObjectProxy(service_name_, object_path_, std::move(callback));
+  }
+
+  const dbus::ObjectPath& GetObjectPath() const override {
+    return object_path_;
+  }
+
+  dbus::ObjectProxy* GetObjectProxy() const override {
+    return dbus_object_proxy_;
+  }
+
+  bool GetLogs(
+      const std::string& in_name,
+      base::ScopedFD* out_outfd,
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override {
+    auto response = brillo::dbus_utils::CallMethodAndBlockWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "org.chromium.debugd",
+        "GetLogs",
+        error,
+        in_name);
+    return response && brillo::dbus_utils::ExtractMethodCallResults(
+        response.get(), error, out_outfd);
+  }
+
+  void GetLogsAsync(
+      const std::string& in_name,
+      base::OnceCallback<void(const base::ScopedFD& /*outfd*/)> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override {
+    brillo::dbus_utils::CallMethodWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "org.chromium.debugd",
+        "GetLogs",
+        std::move(success_callback),
+        std::move(error_callback),
+        in_name);
+  }
+
+ private:
+  scoped_refptr<dbus::Bus> bus_;
+  std::string service_name_;
+  dbus::ObjectPath object_path_;
+  dbus::ObjectProxy* dbus_object_proxy_;
+
+};
+
+}  // namespace chromium
+}  // namespace org
+
+#endif  // ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+`
+	if diff := cmp.Diff(out.String(), want); diff != "" {
+		t.Errorf("Generate failed (-got +want):\n%s", diff)
+	}
+}
+
+func TestGenerateProxiesWithProtobufIncludes(t *testing.T) {
+	itf := introspect.Interface{
+		Name: "org.chromium.Test",
+		Methods: []introspect.Method{
+			{
+				Name: "Get",
+				Args: []introspect.MethodArg{
+					{
+						Name: "request", Type: "ay", Direction: "in",
+						Annotations: []introspect.Annotation{{
+							Name:  "org.chromium.DBus.Argument.ProtobufClass",
+							Value: "test::GetRequest",
+						}},
+					},
+				},
+			},
+		},
+		Annotations: []introspect.Annotation{
+			{Name: "org.chromium.DBus.Interface.ProtobufIncludes", Value: "test/proto_bindings/test.pb.h"},
+		},
+	}
+
+	introspections := []introspect.Introspection{{
+		Interfaces: []introspect.Interface{itf},
+	}}
+
+	out := new(bytes.Buffer)
+	if err := Generate(introspections, out, "/tmp/proxy.h", serviceconfig.Config{}); err != nil {
+		t.Fatalf("Generate got error, want nil: %v", err)
+	}
+
+	const want = `// Automatic generation of D-Bus interfaces:
+//  - org.chromium.Test
+#ifndef ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+#define ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+#include <memory>
+#include <string>
+#include <type_traits>
+#include <vector>
+
+#include <base/functional/bind.h>
+#include <base/functional/callback.h>
+#include <base/logging.h>
+#include <base/memory/ref_counted.h>
+#include <brillo/dbus/dbus_method_invoker.h>
+#include <brillo/dbus/dbus_property.h>
+#include <brillo/dbus/dbus_signal_handler.h>
+#include <brillo/errors/error.h>
+#include <dbus/bus.h>
+#include <dbus/message.h>
+#include <dbus/object_manager.h>
+#include <dbus/object_path.h>
+#include <dbus/object_proxy.h>
+#include <google/protobuf/message_lite.h>
+
+#include "test/proto_bindings/test.pb.h"
+
+#ifndef CHROMEOS_DBUS_BINDINGS_DBUS_PROTOBUF_CLASS_CHECK_
+#define CHROMEOS_DBUS_BINDINGS_DBUS_PROTOBUF_CLASS_CHECK_
+namespace brillo {
+namespace dbus_utils {
+
+// Fails to compile unless T, given by an
+// org.chromium.DBus.Argument.ProtobufClass annotation, is a protobuf message.
+template <typename T>
+constexpr bool CheckProtobufClass() {
+  static_assert(std::is_base_of_v<google::protobuf::MessageLite, T>,
+                "The class given by org.chromium.DBus.Argument.ProtobufClass "
+                "is not a protobuf message; check the annotation and the "
+                "headers in org.chromium.DBus.Interface.ProtobufIncludes");
+  return true;
+}
+
+}  // namespace dbus_utils
+}  // namespace brillo
+#endif  // CHROMEOS_DBUS_BINDINGS_DBUS_PROTOBUF_CLASS_CHECK_
+
+namespace org {
+namespace chromium {
+
+static_assert(
+    brillo::dbus_utils::CheckProtobufClass<test::GetRequest>());
+
+// Abstract interface proxy for org::chromium::Test.
+class TestProxyInterface {
+ public:
+  static constexpr char kInterfaceName[] = "org.chromium.Test";
+  static constexpr char kGetMethod[] = "Get";
+  static constexpr char kGetMethodInSignature[] = "ay";
+  static constexpr char kGetMethodOutSignature[] = "";
+
+  virtual ~TestProxyInterface() = default;
+
+  virtual bool Get(
+      const test::GetRequest& in_request,
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
+
+  virtual void GetAsync(
+      const test::GetRequest& in_request,
+      base::OnceCallback<void()> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
+
+  virtual const dbus::ObjectPath& GetObjectPath() const = 0;
+  virtual dbus::ObjectProxy* GetObjectProxy() const = 0;
+};
+
+}  // namespace chromium
+}  // namespace org
+
+namespace org {
+namespace chromium {
+
+// Interface proxy for org::chromium::Test.
+class TestProxy final : public TestProxyInterface {
+ public:
+  TestProxy(
+      const scoped_refptr<dbus::Bus>& bus,
+      const std::string& service_name,
+      const dbus::ObjectPath& object_path) :
+          bus_{bus},
+          service_name_{service_name},
+          object_path_{object_path},
+          dbus_object_proxy_{
+              bus_->GetObjectProxy(service_name_, object_path_)} {
+  }
+
+  TestProxy(const TestProxy&) = delete;
+  TestProxy& operator=(const TestProxy&) = delete;
+
+  ~TestProxy() override {
+  }
+
+  void ReleaseObjectProxy(base::OnceClosure callback) {
+    bus_->RemoveObjectProxy(service_name_, object_path_, std::move(callback));
+  }
+
+  const dbus::ObjectPath& GetObjectPath() const override {
+    return object_path_;
+  }
+
+  dbus::ObjectProxy* GetObjectProxy() const override {
+    return dbus_object_proxy_;
+  }
+
+  bool Get(
+      const test::GetRequest& in_request,
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override {
+    auto response = brillo::dbus_utils::CallMethodAndBlockWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "org.chromium.Test",
+        "Get",
+        error,
+        in_request);
+    return response && brillo::dbus_utils::ExtractMethodCallResults(
+        response.get(), error);
+  }
+
+  void GetAsync(
+      const test::GetRequest& in_request,
+      base::OnceCallback<void()> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override {
+    brillo::dbus_utils::CallMethodWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "org.chromium.Test",
+        "Get",
+        std::move(success_callback),
+        std::move(error_callback),
+        in_request);
+  }
+
+ private:
+  scoped_refptr<dbus::Bus> bus_;
+  std::string service_name_;
+  dbus::ObjectPath object_path_;
+  dbus::ObjectProxy* dbus_object_proxy_;
+
+};
+
+}  // namespace chromium
+}  // namespace org
+
+#endif  // ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+`
+	if diff := cmp.Diff(out.String(), want); diff != "" {
+		t.Errorf("Generate failed (-got +want):\n%s", diff)
+	}
+}
+
+func TestGenerateProxiesWithCoroutines(t *testing.T) {
+	itf := introspect.Interface{
+		Name: "org.chromium.Test",
+		Methods: []introspect.Method{
+			{
+				Name: "Scan",
+				Args: []introspect.MethodArg{
+					{Name: "name", Type: "s"},
+					{Name: "count", Type: "i", Direction: "out"},
+					{Name: "results", Type: "as", Direction: "out"},
+				},
+			}, {
+				Name: "Stop",
+			},
+		},
+	}
+
+	introspections := []introspect.Introspection{{
+		Interfaces: []introspect.Interface{itf},
+	}}
+
+	out := new(bytes.Buffer)
+	if err := Generate(introspections, out, "/tmp/proxy.h", serviceconfig.Config{UseCoroutines: true}); err != nil {
+		t.Fatalf("Generate got error, want nil: %v", err)
+	}
+
+	const want = `// Automatic generation of D-Bus interfaces:
+//  - org.chromium.Test
+#ifndef ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+#define ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+#include <coroutine>
+#include <memory>
+#include <string>
+#include <tuple>
+#include <type_traits>
+#include <utility>
+#include <vector>
+
+#include <base/functional/bind.h>
+#include <base/functional/callback.h>
+#include <base/logging.h>
+#include <base/memory/ref_counted.h>
+#include <brillo/dbus/dbus_method_invoker.h>
+#include <brillo/dbus/dbus_property.h>
+#include <brillo/dbus/dbus_signal_handler.h>
+#include <brillo/errors/error.h>
+#include <dbus/bus.h>
+#include <dbus/message.h>
+#include <dbus/object_manager.h>
+#include <dbus/object_path.h>
+#include <dbus/object_proxy.h>
+
+#ifndef CHROMEOS_DBUS_BINDINGS_DBUS_AWAITABLE_
+#define CHROMEOS_DBUS_BINDINGS_DBUS_AWAITABLE_
+namespace chromeos_dbus_bindings {
+
+// Result of an awaited D-Bus method call. |error| is set if the call failed,
+// otherwise |value| holds the output arguments.
+template <typename... Ts>
+struct DBusResult {
+  brillo::ErrorPtr error;
+  std::tuple<std::decay_t<Ts>...> value;
+};
+
+// Awaitable returned by the *Await() methods of the proxies. The method call
+// is started before the awaitable is returned, and the awaiting coroutine is
+// resumed when the call completes.
+template <typename... Ts>
+class DBusAwaitable {
+ public:
+  DBusAwaitable() : state_(std::make_shared<State>()) {}
+
+  base::OnceCallback<void(Ts...)> GetSuccessCallback() {
+    return base::BindOnce(&DBusAwaitable::OnSuccess, state_);
+  }
+  base::OnceCallback<void(brillo::Error*)> GetErrorCallback() {
+    return base::BindOnce(&DBusAwaitable::OnError, state_);
+  }
+
+  bool await_ready() const noexcept { return state_->done; }
+  void await_suspend(std::coroutine_handle<> handle) {
+    state_->handle = handle;
+  }
+  DBusResult<Ts...> await_resume() { return std::move(state_->result); }
+
+ private:
+  struct State {
+    bool done = false;
+    std::coroutine_handle<> handle;
+    DBusResult<Ts...> result;
+  };
+
+  static void OnSuccess(const std::shared_ptr<State>& state, Ts... values) {
+    state->result.value =
+        std::tuple<std::decay_t<Ts>...>(std::forward<Ts>(values)...);
+    Complete(state);
+  }
+  static void OnError(const std::shared_ptr<State>& state,
+                      brillo::Error* error) {
+    state->result.error = error->Clone();
+    Complete(state);
+  }
+  static void Complete(const std::shared_ptr<State>& state) {
+    state->done = true;
+    if (state->handle)
+      state->handle.resume();
+  }
+
+  std::shared_ptr<State> state_;
+};
+
+}  // namespace chromeos_dbus_bindings
+#endif  // CHROMEOS_DBUS_BINDINGS_DBUS_AWAITABLE_
+
+namespace org {
+namespace chromium {
+
+// Abstract interface proxy for org::chromium::Test.
+class TestProxyInterface {
+ public:
+  static constexpr char kInterfaceName[] = "org.chromium.Test";
+  static constexpr char kScanMethod[] = "Scan";
+  static constexpr char kScanMethodInSignature[] = "s";
+  static constexpr char kScanMethodOutSignature[] = "ias";
+  static constexpr char kStopMethod[] = "Stop";
+  static constexpr char kStopMethodInSignature[] = "";
+  static constexpr char kStopMethodOutSignature[] = "";
+
+  virtual ~TestProxyInterface() = default;
+
+  virtual bool Scan(
+      const std::string& in_name,
+      int32_t* out_count,
+      std::vector<std::string>* out_results,
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
+
+  virtual void ScanAsync(
+      const std::string& in_name,
+      base::OnceCallback<void(int32_t /*count*/, const std::vector<std::string>& /*results*/)> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
+
+  // Calls ScanAsync() and resumes the awaiting coroutine on completion.
+  // The result holds either the output arguments or the error.
+  chromeos_dbus_bindings::DBusAwaitable<int32_t, const std::vector<std::string>&> ScanAwait(
+      const std::string& in_name,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) {
+    chromeos_dbus_bindings::DBusAwaitable<int32_t, const std::vector<std::string>&> awaitable;
+    ScanAsync(in_name, awaitable.GetSuccessCallback(),
+              awaitable.GetErrorCallback(), timeout_ms);
+    return awaitable;
+  }
+
+  virtual bool Stop(
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
+
+  virtual void StopAsync(
+      base::OnceCallback<void()> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
+
+  // Calls StopAsync() and resumes the awaiting coroutine on completion.
+  // The result holds either the output arguments or the error.
+  chromeos_dbus_bindings::DBusAwaitable<> StopAwait(
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) {
+    chromeos_dbus_bindings::DBusAwaitable<> awaitable;
+    StopAsync(awaitable.GetSuccessCallback(),
+              awaitable.GetErrorCallback(), timeout_ms);
+    return awaitable;
+  }
+
+  virtual const dbus::ObjectPath& GetObjectPath() const = 0;
+  virtual dbus::ObjectProxy* GetObjectProxy() const = 0;
+};
+
+}  // namespace chromium
+}  // namespace org
+
+namespace org {
+namespace chromium {
+
+// Interface proxy for org::chromium::Test.
+class TestProxy final : public TestProxyInterface {
+ public:
+  TestProxy(
+      const scoped_refptr<dbus::Bus>& bus,
+      const std::string& service_name,
+      const dbus::ObjectPath& object_path) :
+          bus_{bus},
+          service_name_{service_name},
+          object_path_{object_path},
+          dbus_object_proxy_{
+              bus_->GetObjectProxy(service_name_, object_path_)} {
+  }
+
+  TestProxy(const TestProxy&) = delete;
+  TestProxy& operator=(const TestProxy&) = delete;
+
+  ~TestProxy() override {
+  }
+
+  void ReleaseObjectProxy(base::OnceClosure callback) {
+    bus_->RemoveObjectProxy(service_name_, object_path_, std::move(callback));
+  }
+
+  const dbus::ObjectPath& GetObjectPath() const override {
+    return object_path_;
+  }
+
+  dbus::ObjectProxy* GetObjectProxy() const override {
+    return dbus_object_proxy_;
+  }
+
+  bool Scan(
+      const std::string& in_name,
+      int32_t* out_count,
+      std::vector<std::string>* out_results,
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override {
+    auto response = brillo::dbus_utils::CallMethodAndBlockWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "org.chromium.Test",
+        "Scan",
+        error,
+        in_name);
+    return response && brillo::dbus_utils::ExtractMethodCallResults(
+        response.get(), error, out_count, out_results);
+  }
+
+  void ScanAsync(
+      const std::string& in_name,
+      base::OnceCallback<void(int32_t /*count*/, const std::vector<std::string>& /*results*/)> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override {
+    brillo::dbus_utils::CallMethodWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "org.chromium.Test",
+        "Scan",
+        std::move(success_callback),
+        std::move(error_callback),
+        in_name);
+  }
+
+  bool Stop(
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override {
+    auto response = brillo::dbus_utils::CallMethodAndBlockWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "org.chromium.Test",
+        "Stop",
+        error);
+    return response && brillo::dbus_utils::ExtractMethodCallResults(
+        response.get(), error);
+  }
+
+  void StopAsync(
+      base::OnceCallback<void()> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override {
+    brillo::dbus_utils::CallMethodWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "org.chromium.Test",
+        "Stop",
+        std::move(success_callback),
+        std::move(error_callback));
+  }
+
+ private:
+  scoped_refptr<dbus::Bus> bus_;
+  std::string service_name_;
+  dbus::ObjectPath object_path_;
+  dbus::ObjectProxy* dbus_object_proxy_;
+
+};
+
+}  // namespace chromium
+}  // namespace org
+
+#endif  // ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+`
+
+	if diff := cmp.Diff(out.String(), want); diff != "" {
+		t.Errorf("Generate failed (-got +want):\n%s", diff)
+	}
+}
+
+func TestGenerateProxiesWithMethodErrors(t *testing.T) {
+	itf := introspect.Interface{
+		Name: "org.chromium.Test",
+		Methods: []introspect.Method{
+			{
+				Name: "Scan",
+				Annotations: []introspect.Annotation{
+					{
+						Name:  "org.chromium.DBus.Method.Errors",
+						Value: "org.chromium.Test.Error.Busy org.chromium.Test.Error.Failed",
+					},
+				},
+			},
+		},
+	}
+
+	introspections := []introspect.Introspection{{
+		Interfaces: []introspect.Interface{itf},
+	}}
+
+	out := new(bytes.Buffer)
+	if err := Generate(introspections, out, "/tmp/proxy.h", serviceconfig.Config{}); err != nil {
+		t.Fatalf("Generate got error, want nil: %v", err)
+	}
+
+	const want = `// Automatic generation of D-Bus interfaces:
+//  - org.chromium.Test
+#ifndef ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+#define ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+#include <memory>
+#include <string>
+#include <vector>
+
+#include <base/functional/bind.h>
+#include <base/functional/callback.h>
+#include <base/logging.h>
+#include <base/memory/ref_counted.h>
+#include <brillo/dbus/dbus_method_invoker.h>
+#include <brillo/dbus/dbus_property.h>
+#include <brillo/dbus/dbus_signal_handler.h>
+#include <brillo/errors/error.h>
+#include <brillo/errors/error_codes.h>
+#include <dbus/bus.h>
+#include <dbus/message.h>
+#include <dbus/object_manager.h>
+#include <dbus/object_path.h>
+#include <dbus/object_proxy.h>
+
+namespace org {
+namespace chromium {
+
+// Abstract interface proxy for org::chromium::Test.
+class TestProxyInterface {
+ public:
+  static constexpr char kInterfaceName[] = "org.chromium.Test";
+  static constexpr char kScanMethod[] = "Scan";
+  static constexpr char kScanMethodInSignature[] = "";
+  static constexpr char kScanMethodOutSignature[] = "";
+
+  virtual ~TestProxyInterface() = default;
+
+  virtual bool Scan(
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
+
+  virtual void ScanAsync(
+      base::OnceCallback<void()> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
+
+  // Errors which org.chromium.Test.Scan may fail with.
+  enum class ScanError {
+    kUnknown,
+    kBusy,
+    kFailed,
+  };
+
+  // Returns the ScanError corresponding to |error|, or kUnknown if
+  // |error| is not a D-Bus error listed above.
+  static ScanError ParseScanError(const brillo::Error* error) {
+    if (!error || error->GetDomain() != brillo::errors::dbus::kDomain)
+      return ScanError::kUnknown;
+    if (error->GetCode() == "org.chromium.Test.Error.Busy")
+      return ScanError::kBusy;
+    if (error->GetCode() == "org.chromium.Test.Error.Failed")
+      return ScanError::kFailed;
+    return ScanError::kUnknown;
+  }
+
+  virtual const dbus::ObjectPath& GetObjectPath() const = 0;
+  virtual dbus::ObjectProxy* GetObjectProxy() const = 0;
+};
+
+}  // namespace chromium
+}  // namespace org
+
+namespace org {
+namespace chromium {
+
+// Interface proxy for org::chromium::Test.
+class TestProxy final : public TestProxyInterface {
+ public:
+  TestProxy(
+      const scoped_refptr<dbus::Bus>& bus,
+      const std::string& service_name,
+      const dbus::ObjectPath& object_path) :
+          bus_{bus},
+          service_name_{service_name},
+          object_path_{object_path},
+          dbus_object_proxy_{
+              bus_->GetObjectProxy(service_name_, object_path_)} {
+  }
+
+  TestProxy(const TestProxy&) = delete;
+  TestProxy& operator=(const TestProxy&) = delete;
+
+  ~TestProxy() override {
+  }
+
+  void ReleaseObjectProxy(base::OnceClosure callback) {
+    bus_->RemoveObjectProxy(service_name_, object_path_, std::move(callback));
+  }
+
+  const dbus::ObjectPath& GetObjectPath() const override {
+    return object_path_;
+  }
+
+  dbus::ObjectProxy* GetObjectProxy() const override {
+    return dbus_object_proxy_;
+  }
+
+  bool Scan(
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override {
+    auto response = brillo::dbus_utils::CallMethodAndBlockWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "org.chromium.Test",
+        "Scan",
+        error);
+    return response && brillo::dbus_utils::ExtractMethodCallResults(
+        response.get(), error);
+  }
+
+  void ScanAsync(
+      base::OnceCallback<void()> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override {
+    brillo::dbus_utils::CallMethodWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "org.chromium.Test",
+        "Scan",
+        std::move(success_callback),
+        std::move(error_callback));
+  }
+
+ private:
+  scoped_refptr<dbus::Bus> bus_;
+  std::string service_name_;
+  dbus::ObjectPath object_path_;
+  dbus::ObjectProxy* dbus_object_proxy_;
+
+};
+
+}  // namespace chromium
+}  // namespace org
+
+#endif  // ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+`
+
+	if diff := cmp.Diff(out.String(), want); diff != "" {
+		t.Errorf("Generate failed (-got +want):\n%s", diff)
+	}
+}
+
+func TestGenerateProxiesWithDefaultValues(t *testing.T) {
+	defaultValue := func(v string) introspect.Annotation {
+		return introspect.Annotation{Name: "org.chromium.DBus.Argument.DefaultValue", Value: v}
+	}
+	itf := introspect.Interface{
+		Name: "org.chromium.Test",
+		Methods: []introspect.Method{
+			{
+				Name: "Scan",
+				Args: []introspect.MethodArg{
+					{Name: "name", Type: "s"},
+					{Name: "flags", Type: "i", Annotations: []introspect.Annotation{defaultValue("0")}},
+					{Name: "mode", Type: "s", Annotations: []introspect.Annotation{defaultValue(`"fast"`)}},
+					{Name: "count", Type: "i", Direction: "out"},
+				},
+			},
+		},
+	}
+
+	introspections := []introspect.Introspection{{
+		Name:       "/org/chromium/Test",
+		Interfaces: []introspect.Interface{itf},
+	}}
+
+	out := new(bytes.Buffer)
+	sc := serviceconfig.Config{ServiceName: "org.chromium.TestService"}
+	if err := Generate(introspections, out, "/tmp/proxy.h", sc); err != nil {
+		t.Fatalf("Generate got error, want nil: %v", err)
+	}
+
+	const want = `// Automatic generation of D-Bus interfaces:
+//  - org.chromium.Test
+#ifndef ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+#define ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+#include <memory>
+#include <string>
+#include <vector>
+
+#include <base/functional/bind.h>
+#include <base/functional/callback.h>
+#include <base/logging.h>
+#include <base/memory/ref_counted.h>
+#include <brillo/dbus/dbus_method_invoker.h>
+#include <brillo/dbus/dbus_property.h>
+#include <brillo/dbus/dbus_signal_handler.h>
+#include <brillo/errors/error.h>
+#include <dbus/bus.h>
+#include <dbus/message.h>
+#include <dbus/object_manager.h>
+#include <dbus/object_path.h>
+#include <dbus/object_proxy.h>
+
+namespace org {
+namespace chromium {
+
+// Abstract interface proxy for org::chromium::Test.
+class TestProxyInterface {
+ public:
+  static constexpr char kInterfaceName[] = "org.chromium.Test";
+  static constexpr char kScanMethod[] = "Scan";
+  static constexpr char kScanMethodInSignature[] = "sis";
+  static constexpr char kScanMethodOutSignature[] = "i";
+
+  virtual ~TestProxyInterface() = default;
+
+  virtual bool Scan(
+      const std::string& in_name,
+      int32_t in_flags,
+      const std::string& in_mode,
+      int32_t* out_count,
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
+
+  virtual void ScanAsync(
+      const std::string& in_name,
+      int32_t in_flags,
+      const std::string& in_mode,
+      base::OnceCallback<void(int32_t /*count*/)> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
+
+  // Calls Scan() with the default values of the omitted arguments.
+  bool Scan(
+      const std::string& in_name,
+      int32_t in_flags,
+      int32_t* out_count,
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) {
+    return Scan(in_name, in_flags, "fast", out_count, error, timeout_ms);
+  }
+
+  // Calls ScanAsync() with the default values of the omitted arguments.
+  void ScanAsync(
+      const std::string& in_name,
+      int32_t in_flags,
+      base::OnceCallback<void(int32_t /*count*/)> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) {
+    ScanAsync(in_name, in_flags, "fast", std::move(success_callback),
+              std::move(error_callback), timeout_ms);
+  }
+
+  // Calls Scan() with the default values of the omitted arguments.
+  bool Scan(
+      const std::string& in_name,
+      int32_t* out_count,
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) {
+    return Scan(in_name, 0, "fast", out_count, error, timeout_ms);
+  }
+
+  // Calls ScanAsync() with the default values of the omitted arguments.
+  void ScanAsync(
+      const std::string& in_name,
+      base::OnceCallback<void(int32_t /*count*/)> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) {
+    ScanAsync(in_name, 0, "fast", std::move(success_callback),
+              std::move(error_callback), timeout_ms);
+  }
+
+  virtual const dbus::ObjectPath& GetObjectPath() const = 0;
+  virtual dbus::ObjectProxy* GetObjectProxy() const = 0;
+};
+
+}  // namespace chromium
+}  // namespace org
+
+namespace org {
+namespace chromium {
+
+// Interface proxy for org::chromium::Test.
+class TestProxy final : public TestProxyInterface {
+ public:
+  TestProxy(const scoped_refptr<dbus::Bus>& bus) :
+      bus_{bus},
+      dbus_object_proxy_{
+          bus_->GetObjectProxy(service_name_, object_path_)} {
+  }
+
+  TestProxy(const TestProxy&) = delete;
+  TestProxy& operator=(const TestProxy&) = delete;
+
+  ~TestProxy() override {
+  }
+
+  void ReleaseObjectProxy(base::OnceClosure callback) {
+    bus_->RemoveObjectProxy(service_name_, object_path_, std::move(callback));
+  }
+
+  const dbus::ObjectPath& GetObjectPath() const override {
+    return object_path_;
+  }
+
+  dbus::ObjectProxy* GetObjectProxy() const override {
+    return dbus_object_proxy_;
+  }
+
+  bool Scan(
+      const std::string& in_name,
+      int32_t in_flags,
+      const std::string& in_mode,
+      int32_t* out_count,
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override {
+    auto response = brillo::dbus_utils::CallMethodAndBlockWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "org.chromium.Test",
+        "Scan",
+        error,
+        in_name,
+        in_flags,
+        in_mode);
+    return response && brillo::dbus_utils::ExtractMethodCallResults(
+        response.get(), error, out_count);
+  }
+
+  void ScanAsync(
+      const std::string& in_name,
+      int32_t in_flags,
+      const std::string& in_mode,
+      base::OnceCallback<void(int32_t /*count*/)> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override {
+    brillo::dbus_utils::CallMethodWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "org.chromium.Test",
+        "Scan",
+        std::move(success_callback),
+        std::move(error_callback),
+        in_name,
+        in_flags,
+        in_mode);
+  }
+
+  // Unhide the overloads omitting the arguments with default values.
+  using TestProxyInterface::Scan;
+  using TestProxyInterface::ScanAsync;
+
+ private:
+  scoped_refptr<dbus::Bus> bus_;
+  const std::string service_name_{"org.chromium.TestService"};
+  const dbus::ObjectPath object_path_{"/org/chromium/Test"};
+  dbus::ObjectProxy* dbus_object_proxy_;
+
+};
+
+}  // namespace chromium
+}  // namespace org
+
+#endif  // ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+`
+
+	if diff := cmp.Diff(out.String(), want); diff != "" {
+		t.Errorf("Generate failed (-got +want):\n%s", diff)
+	}
+}
+
+func TestGenerateProxiesWithMoveProtobufResponses(t *testing.T) {
+	itf := introspect.Interface{
+		Name: "org.chromium.Test",
+		Methods: []introspect.Method{
+			{
+				Name: "Get",
+				Args: []introspect.MethodArg{
+					{
+						Name: "request", Type: "ay", Direction: "in",
+						Annotations: []introspect.Annotation{{
+							Name:  "org.chromium.DBus.Argument.ProtobufClass",
+							Value: "test::GetRequest",
+						}},
+					},
+					{
+						Name: "response", Type: "ay", Direction: "out",
+						Annotations: []introspect.Annotation{{
+							Name:  "org.chromium.DBus.Argument.ProtobufClass",
+							Value: "test::GetResponse",
+						}},
+					},
+				},
+			},
+		},
+	}
+
+	introspections := []introspect.Introspection{{
+		Interfaces: []introspect.Interface{itf},
+	}}
+
+	out := new(bytes.Buffer)
+	if err := Generate(introspections, out, "/tmp/proxy.h", serviceconfig.Config{MoveProtobufResponses: true}); err != nil {
+		t.Fatalf("Generate got error, want nil: %v", err)
+	}
+
+	const want = `// Automatic generation of D-Bus interfaces:
+//  - org.chromium.Test
+#ifndef ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+#define ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+#include <memory>
+#include <string>
+#include <type_traits>
+#include <vector>
+
+#include <base/functional/bind.h>
+#include <base/functional/callback.h>
+#include <base/logging.h>
+#include <base/memory/ref_counted.h>
+#include <brillo/dbus/dbus_method_invoker.h>
+#include <brillo/dbus/dbus_property.h>
+#include <brillo/dbus/dbus_signal_handler.h>
+#include <brillo/errors/error.h>
+#include <dbus/bus.h>
+#include <dbus/message.h>
+#include <dbus/object_manager.h>
+#include <dbus/object_path.h>
+#include <dbus/object_proxy.h>
+#include <google/protobuf/message_lite.h>
+
+#ifndef CHROMEOS_DBUS_BINDINGS_DBUS_PROTOBUF_CLASS_CHECK_
+#define CHROMEOS_DBUS_BINDINGS_DBUS_PROTOBUF_CLASS_CHECK_
+namespace brillo {
+namespace dbus_utils {
+
+// Fails to compile unless T, given by an
+// org.chromium.DBus.Argument.ProtobufClass annotation, is a protobuf message.
+template <typename T>
+constexpr bool CheckProtobufClass() {
+  static_assert(std::is_base_of_v<google::protobuf::MessageLite, T>,
+                "The class given by org.chromium.DBus.Argument.ProtobufClass "
+                "is not a protobuf message; check the annotation and the "
+                "headers in org.chromium.DBus.Interface.ProtobufIncludes");
+  return true;
+}
+
+}  // namespace dbus_utils
+}  // namespace brillo
+#endif  // CHROMEOS_DBUS_BINDINGS_DBUS_PROTOBUF_CLASS_CHECK_
+
+namespace org {
+namespace chromium {
+
+static_assert(
+    brillo::dbus_utils::CheckProtobufClass<test::GetRequest>());
+static_assert(
+    brillo::dbus_utils::CheckProtobufClass<test::GetResponse>());
+
+// Abstract interface proxy for org::chromium::Test.
+class TestProxyInterface {
+ public:
+  static constexpr char kInterfaceName[] = "org.chromium.Test";
+  static constexpr char kGetMethod[] = "Get";
+  static constexpr char kGetMethodInSignature[] = "ay";
+  static constexpr char kGetMethodOutSignature[] = "ay";
+
+  virtual ~TestProxyInterface() = default;
+
+  virtual bool Get(
+      const test::GetRequest& in_request,
+      test::GetResponse* out_response,
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
+
+  virtual void GetAsync(
+      const test::GetRequest& in_request,
+      base::OnceCallback<void(test::GetResponse&& /*response*/)> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
+
+  virtual const dbus::ObjectPath& GetObjectPath() const = 0;
+  virtual dbus::ObjectProxy* GetObjectProxy() const = 0;
+};
+
+}  // namespace chromium
+}  // namespace org
+
+namespace org {
+namespace chromium {
+
+// Interface proxy for org::chromium::Test.
+class TestProxy final : public TestProxyInterface {
+ public:
+  TestProxy(
+      const scoped_refptr<dbus::Bus>& bus,
+      const std::string& service_name,
+      const dbus::ObjectPath& object_path) :
+          bus_{bus},
+          service_name_{service_name},
+          object_path_{object_path},
+          dbus_object_proxy_{
+              bus_->GetObjectProxy(service_name_, object_path_)} {
+  }
+
+  TestProxy(const TestProxy&) = delete;
+  TestProxy& operator=(const TestProxy&) = delete;
+
+  ~TestProxy() override {
+  }
+
+  void ReleaseObjectProxy(base::OnceClosure callback) {
+    bus_->RemoveObjectProxy(service_name_, object_path_, std::move(callback));
+  }
+
+  const dbus::ObjectPath& GetObjectPath() const override {
+    return object_path_;
+  }
+
+  dbus::ObjectProxy* GetObjectProxy() const override {
+    return dbus_object_proxy_;
+  }
+
+  bool Get(
+      const test::GetRequest& in_request,
+      test::GetResponse* out_response,
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override {
+    auto response = brillo::dbus_utils::CallMethodAndBlockWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "org.chromium.Test",
+        "Get",
+        error,
+        in_request);
+    return response && brillo::dbus_utils::ExtractMethodCallResults(
+        response.get(), error, out_response);
+  }
+
+  void GetAsync(
+      const test::GetRequest& in_request,
+      base::OnceCallback<void(test::GetResponse&& /*response*/)> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override {
+    brillo::dbus_utils::CallMethodWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "org.chromium.Test",
+        "Get",
+        std::move(success_callback),
+        std::move(error_callback),
+        in_request);
+  }
+
+ private:
+  scoped_refptr<dbus::Bus> bus_;
+  std::string service_name_;
+  dbus::ObjectPath object_path_;
+  dbus::ObjectProxy* dbus_object_proxy_;
+
+};
+
+}  // namespace chromium
+}  // namespace org
+
+#endif  // ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+`
+	if diff := cmp.Diff(out.String(), want); diff != "" {
+		t.Errorf("Generate failed (-got +want):\n%s", diff)
+	}
+}
+
+func TestGenerateProxiesWithNamespaceOverrides(t *testing.T) {
+	itf := introspect.Interface{
+		Name: "fi.w1.wpa_supplicant1.Interface",
+		Methods: []introspect.Method{
+			{
+				Name: "Scan",
+				Args: []introspect.MethodArg{
+					{Name: "args", Type: "a{sv}"},
+				},
+			},
+		},
+	}
+
+	introspections := []introspect.Introspection{{
+		Interfaces: []introspect.Interface{itf},
+	}}
+
+	out := new(bytes.Buffer)
+	sc := serviceconfig.Config{
+		NamespaceOverrides: map[string]string{"fi.w1.wpa_supplicant1.Interface": "wpa::supplicant"},
+	}
+	if err := Generate(introspections, out, "/tmp/proxy.h", sc); err != nil {
+		t.Fatalf("Generate got error, want nil: %v", err)
+	}
+
+	const want = `// Automatic generation of D-Bus interfaces:
+//  - fi.w1.wpa_supplicant1.Interface
+#ifndef ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+#define ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+#include <memory>
+#include <string>
+#include <vector>
+
+#include <base/functional/bind.h>
+#include <base/functional/callback.h>
+#include <base/logging.h>
+#include <base/memory/ref_counted.h>
+#include <brillo/dbus/dbus_method_invoker.h>
+#include <brillo/dbus/dbus_property.h>
+#include <brillo/dbus/dbus_signal_handler.h>
+#include <brillo/errors/error.h>
+#include <brillo/variant_dictionary.h>
+#include <dbus/bus.h>
+#include <dbus/message.h>
+#include <dbus/object_manager.h>
+#include <dbus/object_path.h>
+#include <dbus/object_proxy.h>
+
+namespace wpa {
+namespace supplicant {
+
+// Abstract interface proxy for wpa::supplicant::Interface.
+class InterfaceProxyInterface {
+ public:
+  static constexpr char kInterfaceName[] = "fi.w1.wpa_supplicant1.Interface";
+  static constexpr char kScanMethod[] = "Scan";
+  static constexpr char kScanMethodInSignature[] = "a{sv}";
+  static constexpr char kScanMethodOutSignature[] = "";
+
+  virtual ~InterfaceProxyInterface() = default;
+
+  virtual bool Scan(
+      const brillo::VariantDictionary& in_args,
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
+
+  virtual void ScanAsync(
+      const brillo::VariantDictionary& in_args,
+      base::OnceCallback<void()> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
+
+  virtual const dbus::ObjectPath& GetObjectPath() const = 0;
+  virtual dbus::ObjectProxy* GetObjectProxy() const = 0;
+};
+
+}  // namespace supplicant
+}  // namespace wpa
+
+namespace wpa {
+namespace supplicant {
+
+// Interface proxy for wpa::supplicant::Interface.
+class InterfaceProxy final : public InterfaceProxyInterface {
+ public:
+  InterfaceProxy(
+      const scoped_refptr<dbus::Bus>& bus,
+      const std::string& service_name,
+      const dbus::ObjectPath& object_path) :
+          bus_{bus},
+          service_name_{service_name},
+          object_path_{object_path},
+          dbus_object_proxy_{
+              bus_->GetObjectProxy(service_name_, object_path_)} {
+  }
+
+  InterfaceProxy(const InterfaceProxy&) = delete;
+  InterfaceProxy& operator=(const InterfaceProxy&) = delete;
+
+  ~InterfaceProxy() override {
+  }
+
+  void ReleaseObjectProxy(base::OnceClosure callback) {
+    bus_->RemoveObjectProxy(service_name_, object_path_, std::move(callback));
+  }
+
+  const dbus::ObjectPath& GetObjectPath() const override {
+    return object_path_;
+  }
+
+  dbus::ObjectProxy* GetObjectProxy() const override {
+    return dbus_object_proxy_;
+  }
+
+  bool Scan(
+      const brillo::VariantDictionary& in_args,
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override {
+    auto response = brillo::dbus_utils::CallMethodAndBlockWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "fi.w1.wpa_supplicant1.Interface",
+        "Scan",
+        error,
+        in_args);
+    return response && brillo::dbus_utils::ExtractMethodCallResults(
+        response.get(), error);
+  }
+
+  void ScanAsync(
+      const brillo::VariantDictionary& in_args,
+      base::OnceCallback<void()> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override {
+    brillo::dbus_utils::CallMethodWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "fi.w1.wpa_supplicant1.Interface",
+        "Scan",
+        std::move(success_callback),
+        std::move(error_callback),
+        in_args);
+  }
+
+ private:
+  scoped_refptr<dbus::Bus> bus_;
+  std::string service_name_;
+  dbus::ObjectPath object_path_;
+  dbus::ObjectProxy* dbus_object_proxy_;
+
+};
+
+}  // namespace supplicant
+}  // namespace wpa
+
+#endif  // ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+`
+	if diff := cmp.Diff(out.String(), want); diff != "" {
+		t.Errorf("Generate failed (-got +want):\n%s", diff)
+	}
+}
+
+func TestGenerateProxiesWithIncludeDBusMessage(t *testing.T) {
+	itf := introspect.Interface{
+		Name: "org.chromium.Test",
+		Methods: []introspect.Method{
+			{
+				Name: "Authorize",
+				Args: []introspect.MethodArg{
+					{Name: "token", Type: "s"},
+					{Name: "granted", Type: "b", Direction: "out"},
+				},
+				Annotations: []introspect.Annotation{
+					{Name: "org.chromium.DBus.Method.IncludeDBusMessage", Value: "true"},
+				},
+			},
+		},
+	}
+
+	introspections := []introspect.Introspection{{
+		Interfaces: []introspect.Interface{itf},
+	}}
+
+	out := new(bytes.Buffer)
+	if err := Generate(introspections, out, "/tmp/proxy.h", serviceconfig.Config{}); err != nil {
+		t.Fatalf("Generate got error, want nil: %v", err)
+	}
+
+	const want = `// Automatic generation of D-Bus interfaces:
+//  - org.chromium.Test
+#ifndef ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+#define ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+#include <memory>
+#include <string>
+#include <vector>
+
+#include <base/functional/bind.h>
+#include <base/functional/callback.h>
+#include <base/logging.h>
+#include <base/memory/ref_counted.h>
+#include <brillo/dbus/dbus_method_invoker.h>
+#include <brillo/dbus/dbus_property.h>
+#include <brillo/dbus/dbus_signal_handler.h>
+#include <brillo/errors/error.h>
+#include <dbus/bus.h>
+#include <dbus/message.h>
+#include <dbus/object_manager.h>
+#include <dbus/object_path.h>
+#include <dbus/object_proxy.h>
+
+namespace org {
+namespace chromium {
+
+// Abstract interface proxy for org::chromium::Test.
+class TestProxyInterface {
+ public:
+  static constexpr char kInterfaceName[] = "org.chromium.Test";
+  static constexpr char kAuthorizeMethod[] = "Authorize";
+  static constexpr char kAuthorizeMethodInSignature[] = "s";
+  static constexpr char kAuthorizeMethodOutSignature[] = "b";
+
+  virtual ~TestProxyInterface() = default;
+
+  virtual bool Authorize(
+      const std::string& in_token,
+      bool* out_granted,
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
+
+  virtual void AuthorizeAsync(
+      const std::string& in_token,
+      base::OnceCallback<void(bool /*granted*/)> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
+
+  virtual const dbus::ObjectPath& GetObjectPath() const = 0;
+  virtual dbus::ObjectProxy* GetObjectProxy() const = 0;
+};
+
+}  // namespace chromium
+}  // namespace org
+
+namespace org {
+namespace chromium {
+
+// Interface proxy for org::chromium::Test.
+class TestProxy final : public TestProxyInterface {
+ public:
+  TestProxy(
+      const scoped_refptr<dbus::Bus>& bus,
+      const std::string& service_name,
+      const dbus::ObjectPath& object_path) :
+          bus_{bus},
+          service_name_{service_name},
+          object_path_{object_path},
+          dbus_object_proxy_{
+              bus_->GetObjectProxy(service_name_, object_path_)} {
+  }
+
+  TestProxy(const TestProxy&) = delete;
+  TestProxy& operator=(const TestProxy&) = delete;
+
+  ~TestProxy() override {
+  }
+
+  void ReleaseObjectProxy(base::OnceClosure callback) {
+    bus_->RemoveObjectProxy(service_name_, object_path_, std::move(callback));
+  }
+
+  const dbus::ObjectPath& GetObjectPath() const override {
+    return object_path_;
+  }
+
+  dbus::ObjectProxy* GetObjectProxy() const override {
+    return dbus_object_proxy_;
+  }
+
+  bool Authorize(
+      const std::string& in_token,
+      bool* out_granted,
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override {
+    auto response = brillo::dbus_utils::CallMethodAndBlockWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "org.chromium.Test",
+        "Authorize",
+        error,
+        in_token);
+    return response && brillo::dbus_utils::ExtractMethodCallResults(
+        response.get(), error, out_granted);
+  }
+
+  void AuthorizeAsync(
+      const std::string& in_token,
+      base::OnceCallback<void(bool /*granted*/)> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override {
+    brillo::dbus_utils::CallMethodWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "org.chromium.Test",
+        "Authorize",
+        std::move(success_callback),
+        std::move(error_callback),
+        in_token);
+  }
+
+  // Calls Authorize() and returns the response message, e.g. to inspect its
+  // sender, or nullptr on failure. The output arguments can be extracted with
+  // brillo::dbus_utils::ExtractMethodCallResults().
+  std::unique_ptr<dbus::Response> AuthorizeWithMessage(
+      const std::string& in_token,
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) {
+    return brillo::dbus_utils::CallMethodAndBlockWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "org.chromium.Test",
+        "Authorize",
+        error,
+        in_token);
+  }
+
+ private:
+  scoped_refptr<dbus::Bus> bus_;
+  std::string service_name_;
+  dbus::ObjectPath object_path_;
+  dbus::ObjectProxy* dbus_object_proxy_;
+
+};
+
+}  // namespace chromium
+}  // namespace org
+
+#endif  // ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+`
+	if diff := cmp.Diff(out.String(), want); diff != "" {
+		t.Errorf("Generate failed (-got +want):\n%s", diff)
+	}
+}
+
+// makeLargeIntrospections returns an interface with n methods and n signals,
+// resembling the generated test XML files.
+func makeLargeIntrospections(n int) []introspect.Introspection {
+	itf := introspect.Interface{Name: "org.chromium.Large"}
+	for i := 0; i < n; i++ {
+		itf.Methods = append(itf.Methods, introspect.Method{
+			Name: fmt.Sprintf("Method%d", i),
+			Args: []introspect.MethodArg{
+				{Name: "name", Type: "s"},
+				{Name: "options", Type: "a{sv}"},
+				{Name: "count", Type: "i", Direction: "out"},
+				{Name: "results", Type: "a(so)", Direction: "out"},
+			},
+		})
+		itf.Signals = append(itf.Signals, introspect.Signal{
+			Name: fmt.Sprintf("Signal%d", i),
+			Args: []introspect.SignalArg{
+				{Name: "path", Type: "o"},
+				{Name: "properties", Type: "a{sv}"},
+			},
+		})
+	}
+	return []introspect.Introspection{{Interfaces: []introspect.Interface{itf}}}
+}
+
+func BenchmarkGenerateProxies(b *testing.B) {
+	introspections := makeLargeIntrospections(500)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := Generate(introspections, ioutil.Discard, "/tmp/proxy.h", serviceconfig.Config{}); err != nil {
+			b.Fatalf("Generate got error, want nil: %v", err)
+		}
+	}
+}
+
+func TestGenerateProxiesWithLightweightProperties(t *testing.T) {
+	itf := introspect.Interface{
+		Name: "org.chromium.Test",
+		Properties: []introspect.Property{
+			{Name: "Level", Type: "i", Access: "read"},
+			{Name: "Label", Type: "s", Access: "readwrite"},
+		},
+		Annotations: []introspect.Annotation{
+			{Name: "org.chromium.DBus.Interface.LightweightProperties", Value: "true"},
+		},
+	}
+
+	introspections := []introspect.Introspection{{
+		Name:       "/org/chromium/Test",
+		Interfaces: []introspect.Interface{itf},
+	}}
+
+	out := new(bytes.Buffer)
+	if err := Generate(introspections, out, "/tmp/proxy.h", serviceconfig.Config{}); err != nil {
+		t.Fatalf("Generate got error, want nil: %v", err)
+	}
+
+	const want = `// Automatic generation of D-Bus interfaces:
+//  - org.chromium.Test
+#ifndef ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+#define ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+#include <memory>
+#include <string>
+#include <vector>
+
+#include <base/functional/bind.h>
+#include <base/functional/callback.h>
+#include <base/logging.h>
+#include <base/memory/ref_counted.h>
+#include <brillo/dbus/dbus_method_invoker.h>
+#include <brillo/dbus/dbus_property.h>
+#include <brillo/dbus/dbus_signal_handler.h>
+#include <brillo/errors/error.h>
+#include <brillo/variant_dictionary.h>
+#include <dbus/bus.h>
+#include <dbus/message.h>
+#include <dbus/object_manager.h>
+#include <dbus/object_path.h>
+#include <dbus/object_proxy.h>
+
+namespace org {
+namespace chromium {
+
+// Abstract interface proxy for org::chromium::Test.
+class TestProxyInterface {
+ public:
+  static constexpr char kInterfaceName[] = "org.chromium.Test";
+  static constexpr char kLevelProperty[] = "Level";
+  static constexpr char kLevelPropertySignature[] = "i";
+  static constexpr char kLabelProperty[] = "Label";
+  static constexpr char kLabelPropertySignature[] = "s";
+
+  virtual ~TestProxyInterface() = default;
+
+  static const char* LevelName() { return "Level"; }
+  virtual void SetLevelChangedCallback(
+      const base::RepeatingCallback<void(int32_t)>& callback) = 0;
+  static const char* LabelName() { return "Label"; }
+  virtual void SetLabelChangedCallback(
+      const base::RepeatingCallback<void(const std::string&)>& callback) = 0;
+
+  virtual const dbus::ObjectPath& GetObjectPath() const = 0;
+  virtual dbus::ObjectProxy* GetObjectProxy() const = 0;
+
+  // Connects to the PropertiesChanged signal to run the property changed
+  // callbacks. The property values are not cached.
+  virtual void ConnectPropertiesChangedSignal(
+      dbus::ObjectProxy::OnConnectedCallback on_connected_callback) = 0;
+};
+
+}  // namespace chromium
+}  // namespace org
+
+namespace org {
+namespace chromium {
+
+// Interface proxy for org::chromium::Test.
+class TestProxy final : public TestProxyInterface {
+ public:
+  TestProxy(
+      const scoped_refptr<dbus::Bus>& bus,
+      const std::string& service_name) :
+          bus_{bus},
+          service_name_{service_name},
+          dbus_object_proxy_{
+              bus_->GetObjectProxy(service_name_, object_path_)} {
+  }
+
+  TestProxy(const TestProxy&) = delete;
+  TestProxy& operator=(const TestProxy&) = delete;
+
+  ~TestProxy() override {
+  }
+
+  void ReleaseObjectProxy(base::OnceClosure callback) {
+    bus_->RemoveObjectProxy(service_name_, object_path_, std::move(callback));
+  }
+
+  const dbus::ObjectPath& GetObjectPath() const override {
+    return object_path_;
+  }
+
+  dbus::ObjectProxy* GetObjectProxy() const override {
+    return dbus_object_proxy_;
+  }
+
+  void ConnectPropertiesChangedSignal(
+      dbus::ObjectProxy::OnConnectedCallback on_connected_callback) override {
+    brillo::dbus_utils::ConnectToSignal(
+        dbus_object_proxy_,
+        "org.freedesktop.DBus.Properties",
+        "PropertiesChanged",
+        base::BindRepeating(&TestProxy::OnPropertiesChanged,
+                            base::Unretained(this)),
+        std::move(on_connected_callback));
+  }
+
+  void SetLevelChangedCallback(
+      const base::RepeatingCallback<void(int32_t)>& callback) override {
+    on_level_changed_ = callback;
+  }
+
+  void SetLabelChangedCallback(
+      const base::RepeatingCallback<void(const std::string&)>& callback) override {
+    on_label_changed_ = callback;
+  }
+
+ private:
+  void OnPropertiesChanged(
+      const std::string& interface_name,
+      const brillo::VariantDictionary& changed_properties,
+      const std::vector<std::string>& /* invalidated_properties */) {
+    if (interface_name != kInterfaceName)
+      return;
+    for (const auto& [name, value] : changed_properties) {
+      if (name == LevelName() && !on_level_changed_.is_null() &&
+          value.IsTypeCompatible<int32_t>())
+        on_level_changed_.Run(value.Get<int32_t>());
+      if (name == LabelName() && !on_label_changed_.is_null() &&
+          value.IsTypeCompatible<std::string>())
+        on_label_changed_.Run(value.Get<std::string>());
+    }
+  }
+
+  scoped_refptr<dbus::Bus> bus_;
+  std::string service_name_;
+  const dbus::ObjectPath object_path_{"/org/chromium/Test"};
+  base::RepeatingCallback<void(int32_t)> on_level_changed_;
+  base::RepeatingCallback<void(const std::string&)> on_label_changed_;
+  dbus::ObjectProxy* dbus_object_proxy_;
+
+};
+
+}  // namespace chromium
+}  // namespace org
+
+#endif  // ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+`
+	if diff := cmp.Diff(out.String(), want); diff != "" {
+		t.Errorf("Generate failed (-got +want):\n%s", diff)
+	}
+
+	// The properties are managed by dbus::ObjectManager in the object manager mode.
+	sc := serviceconfig.Config{
+		ObjectManager: &serviceconfig.ObjectManagerConfig{Name: "foo.ObjectManager"},
+	}
+	if err := Generate(introspections, new(bytes.Buffer), "/tmp/proxy.h", sc); err == nil {
+		t.Error("Generate with object manager succeeded unexpectedly")
+	}
+}
+
+func TestGenerateProxiesWithEnumClass(t *testing.T) {
+	enumClass := introspect.Annotation{Name: "org.chromium.DBus.Argument.EnumClass", Value: "test::Mode"}
+	itf := introspect.Interface{
+		Name: "org.chromium.Test",
+		Methods: []introspect.Method{
+			{
+				Name: "SwapMode",
+				Args: []introspect.MethodArg{
+					{Name: "mode", Type: "u", Annotations: []introspect.Annotation{enumClass}},
+					{Name: "old_mode", Type: "u", Direction: "out", Annotations: []introspect.Annotation{enumClass}},
+				},
+			},
+		},
+	}
+
+	introspections := []introspect.Introspection{{
+		Interfaces: []introspect.Interface{itf},
+	}}
+
+	out := new(bytes.Buffer)
+	if err := Generate(introspections, out, "/tmp/proxy.h", serviceconfig.Config{}); err != nil {
+		t.Fatalf("Generate got error, want nil: %v", err)
+	}
+
+	const want = `// Automatic generation of D-Bus interfaces:
+//  - org.chromium.Test
+#ifndef ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+#define ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+#include <memory>
+#include <string>
+#include <vector>
+
+#include <base/functional/bind.h>
+#include <base/functional/callback.h>
+#include <base/logging.h>
+#include <base/memory/ref_counted.h>
+#include <brillo/dbus/dbus_method_invoker.h>
+#include <brillo/dbus/dbus_property.h>
+#include <brillo/dbus/dbus_signal_handler.h>
+#include <brillo/errors/error.h>
+#include <dbus/bus.h>
+#include <dbus/message.h>
+#include <dbus/object_manager.h>
+#include <dbus/object_path.h>
+#include <dbus/object_proxy.h>
+
+#ifndef ____CHROMEOS_DBUS_BINDING__ENUM__TEST__MODE
+#define ____CHROMEOS_DBUS_BINDING__ENUM__TEST__MODE
+namespace brillo {
+namespace dbus_utils {
+
+template <>
+struct DBusType<test::Mode> {
+  inline static std::string GetSignature() { return "u"; }
+  inline static void Write(dbus::MessageWriter* writer, test::Mode value) {
+    DBusType<uint32_t>::Write(writer, static_cast<uint32_t>(value));
+  }
+  inline static bool Read(dbus::MessageReader* reader, test::Mode* value) {
+    uint32_t raw;
+    if (!DBusType<uint32_t>::Read(reader, &raw))
+      return false;
+    *value = static_cast<test::Mode>(raw);
+    return true;
+  }
+};
+
+}  // namespace dbus_utils
+}  // namespace brillo
+#endif  // ____CHROMEOS_DBUS_BINDING__ENUM__TEST__MODE
+
+namespace org {
+namespace chromium {
+
+// Abstract interface proxy for org::chromium::Test.
+class TestProxyInterface {
+ public:
+  static constexpr char kInterfaceName[] = "org.chromium.Test";
+  static constexpr char kSwapModeMethod[] = "SwapMode";
+  static constexpr char kSwapModeMethodInSignature[] = "u";
+  static constexpr char kSwapModeMethodOutSignature[] = "u";
+
+  virtual ~TestProxyInterface() = default;
+
+  virtual bool SwapMode(
+      test::Mode in_mode,
+      test::Mode* out_old_mode,
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
+
+  virtual void SwapModeAsync(
+      test::Mode in_mode,
+      base::OnceCallback<void(test::Mode /*old_mode*/)> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
+
+  virtual const dbus::ObjectPath& GetObjectPath() const = 0;
+  virtual dbus::ObjectProxy* GetObjectProxy() const = 0;
+};
+
+}  // namespace chromium
+}  // namespace org
+
+namespace org {
+namespace chromium {
+
+// Interface proxy for org::chromium::Test.
+class TestProxy final : public TestProxyInterface {
+ public:
+  TestProxy(
+      const scoped_refptr<dbus::Bus>& bus,
+      const std::string& service_name,
+      const dbus::ObjectPath& object_path) :
+          bus_{bus},
+          service_name_{service_name},
+          object_path_{object_path},
+          dbus_object_proxy_{
+              bus_->GetObjectProxy(service_name_, object_path_)} {
+  }
+
+  TestProxy(const TestProxy&) = delete;
+  TestProxy& operator=(const TestProxy&) = delete;
+
+  ~TestProxy() override {
+  }
+
+  void ReleaseObjectProxy(base::OnceClosure callback) {
+    bus_->RemoveObjectProxy(service_name_, object_path_, std::move(callback));
+  }
+
+  const dbus::ObjectPath& GetObjectPath() const override {
+    return object_path_;
+  }
+
+  dbus::ObjectProxy* GetObjectProxy() const override {
+    return dbus_object_proxy_;
+  }
+
+  bool SwapMode(
+      test::Mode in_mode,
+      test::Mode* out_old_mode,
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override {
+    auto response = brillo::dbus_utils::CallMethodAndBlockWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "org.chromium.Test",
+        "SwapMode",
+        error,
+        in_mode);
+    return response && brillo::dbus_utils::ExtractMethodCallResults(
+        response.get(), error, out_old_mode);
+  }
+
+  void SwapModeAsync(
+      test::Mode in_mode,
+      base::OnceCallback<void(test::Mode /*old_mode*/)> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override {
+    brillo::dbus_utils::CallMethodWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "org.chromium.Test",
+        "SwapMode",
+        std::move(success_callback),
+        std::move(error_callback),
+        in_mode);
+  }
+
+ private:
+  scoped_refptr<dbus::Bus> bus_;
+  std::string service_name_;
+  dbus::ObjectPath object_path_;
+  dbus::ObjectProxy* dbus_object_proxy_;
+
+};
+
+}  // namespace chromium
+}  // namespace org
+
+#endif  // ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+`
+	if diff := cmp.Diff(out.String(), want); diff != "" {
+		t.Errorf("Generate failed (-got +want):\n%s", diff)
+	}
+}
+
+func TestGenerateProxiesWithFlagsClass(t *testing.T) {
+	flagsClass := introspect.Annotation{Name: "org.chromium.DBus.Argument.FlagsClass", Value: "ScanOptions(kActive, kPassive, kHidden=0x10)"}
+	itf := introspect.Interface{
+		Name: "org.chromium.Test",
+		Methods: []introspect.Method{
+			{
+				Name: "Scan",
+				Args: []introspect.MethodArg{
+					{Name: "options", Type: "u", Annotations: []introspect.Annotation{flagsClass}},
+					{Name: "applied", Type: "u", Direction: "out", Annotations: []introspect.Annotation{flagsClass}},
+				},
+			},
+		},
+	}
+
+	introspections := []introspect.Introspection{{
+		Interfaces: []introspect.Interface{itf},
+	}}
+
+	out := new(bytes.Buffer)
+	if err := Generate(introspections, out, "/tmp/proxy.h", serviceconfig.Config{}); err != nil {
+		t.Fatalf("Generate got error, want nil: %v", err)
+	}
+
+	const want = `// Automatic generation of D-Bus interfaces:
+//  - org.chromium.Test
+#ifndef ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+#define ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+#include <memory>
+#include <string>
+#include <vector>
+
+#include <base/functional/bind.h>
+#include <base/functional/callback.h>
+#include <base/logging.h>
+#include <base/memory/ref_counted.h>
+#include <brillo/dbus/dbus_method_invoker.h>
+#include <brillo/dbus/dbus_property.h>
+#include <brillo/dbus/dbus_signal_handler.h>
+#include <brillo/errors/error.h>
+#include <dbus/bus.h>
+#include <dbus/message.h>
+#include <dbus/object_manager.h>
+#include <dbus/object_path.h>
+#include <dbus/object_proxy.h>
+
+#ifndef ____CHROMEOS_DBUS_BINDING__FLAGS__ORG__CHROMIUM__SCANOPTIONS
+#define ____CHROMEOS_DBUS_BINDING__FLAGS__ORG__CHROMIUM__SCANOPTIONS
+namespace org {
+namespace chromium {
+
+enum class ScanOptions : uint32_t {
+  kActive = 0x1,
+  kPassive = 0x2,
+  kHidden = 0x10,
+};
+
+inline constexpr ScanOptions operator|(ScanOptions a, ScanOptions b) {
+  return static_cast<ScanOptions>(static_cast<uint32_t>(a) | static_cast<uint32_t>(b));
+}
+inline constexpr ScanOptions operator&(ScanOptions a, ScanOptions b) {
+  return static_cast<ScanOptions>(static_cast<uint32_t>(a) & static_cast<uint32_t>(b));
+}
+inline constexpr ScanOptions operator^(ScanOptions a, ScanOptions b) {
+  return static_cast<ScanOptions>(static_cast<uint32_t>(a) ^ static_cast<uint32_t>(b));
+}
+inline constexpr ScanOptions operator~(ScanOptions a) {
+  return static_cast<ScanOptions>(~static_cast<uint32_t>(a));
+}
+inline ScanOptions& operator|=(ScanOptions& a, ScanOptions b) {
+  return a = a | b;
+}
+inline ScanOptions& operator&=(ScanOptions& a, ScanOptions b) {
+  return a = a & b;
+}
+inline ScanOptions& operator^=(ScanOptions& a, ScanOptions b) {
+  return a = a ^ b;
+}
+
+}  // namespace chromium
+}  // namespace org
+
+namespace brillo {
+namespace dbus_utils {
+
+template <>
+struct DBusType<org::chromium::ScanOptions> {
+  inline static std::string GetSignature() { return "u"; }
+  inline static void Write(dbus::MessageWriter* writer, org::chromium::ScanOptions value) {
+    DBusType<uint32_t>::Write(writer, static_cast<uint32_t>(value));
+  }
+  inline static bool Read(dbus::MessageReader* reader, org::chromium::ScanOptions* value) {
+    uint32_t raw;
+    if (!DBusType<uint32_t>::Read(reader, &raw))
+      return false;
+    *value = static_cast<org::chromium::ScanOptions>(raw);
+    return true;
+  }
+};
+
+}  // namespace dbus_utils
+}  // namespace brillo
+#endif  // ____CHROMEOS_DBUS_BINDING__FLAGS__ORG__CHROMIUM__SCANOPTIONS
+
+namespace org {
+namespace chromium {
+
+// Abstract interface proxy for org::chromium::Test.
+class TestProxyInterface {
+ public:
+  static constexpr char kInterfaceName[] = "org.chromium.Test";
+  static constexpr char kScanMethod[] = "Scan";
+  static constexpr char kScanMethodInSignature[] = "u";
+  static constexpr char kScanMethodOutSignature[] = "u";
+
+  virtual ~TestProxyInterface() = default;
+
+  virtual bool Scan(
+      ScanOptions in_options,
+      ScanOptions* out_applied,
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
+
+  virtual void ScanAsync(
+      ScanOptions in_options,
+      base::OnceCallback<void(ScanOptions /*applied*/)> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
+
+  virtual const dbus::ObjectPath& GetObjectPath() const = 0;
+  virtual dbus::ObjectProxy* GetObjectProxy() const = 0;
+};
+
+}  // namespace chromium
+}  // namespace org
+
+namespace org {
+namespace chromium {
+
+// Interface proxy for org::chromium::Test.
+class TestProxy final : public TestProxyInterface {
+ public:
+  TestProxy(
+      const scoped_refptr<dbus::Bus>& bus,
+      const std::string& service_name,
+      const dbus::ObjectPath& object_path) :
+          bus_{bus},
+          service_name_{service_name},
+          object_path_{object_path},
+          dbus_object_proxy_{
+              bus_->GetObjectProxy(service_name_, object_path_)} {
+  }
+
+  TestProxy(const TestProxy&) = delete;
+  TestProxy& operator=(const TestProxy&) = delete;
+
+  ~TestProxy() override {
+  }
+
+  void ReleaseObjectProxy(base::OnceClosure callback) {
+    bus_->RemoveObjectProxy(service_name_, object_path_, std::move(callback));
+  }
+
+  const dbus::ObjectPath& GetObjectPath() const override {
+    return object_path_;
+  }
+
+  dbus::ObjectProxy* GetObjectProxy() const override {
+    return dbus_object_proxy_;
+  }
+
+  bool Scan(
+      ScanOptions in_options,
+      ScanOptions* out_applied,
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override {
+    auto response = brillo::dbus_utils::CallMethodAndBlockWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "org.chromium.Test",
+        "Scan",
+        error,
+        in_options);
+    return response && brillo::dbus_utils::ExtractMethodCallResults(
+        response.get(), error, out_applied);
+  }
+
+  void ScanAsync(
+      ScanOptions in_options,
+      base::OnceCallback<void(ScanOptions /*applied*/)> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override {
+    brillo::dbus_utils::CallMethodWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "org.chromium.Test",
+        "Scan",
+        std::move(success_callback),
+        std::move(error_callback),
+        in_options);
+  }
+
+ private:
+  scoped_refptr<dbus::Bus> bus_;
+  std::string service_name_;
+  dbus::ObjectPath object_path_;
+  dbus::ObjectProxy* dbus_object_proxy_;
+
+};
+
+}  // namespace chromium
+}  // namespace org
+
+#endif  // ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+`
+	if diff := cmp.Diff(out.String(), want); diff != "" {
+		t.Errorf("Generate failed (-got +want):\n%s", diff)
+	}
+}
+
+func TestGenerateProxiesWithOptional(t *testing.T) {
+	itf := introspect.Interface{
+		Name: "org.chromium.Test",
+		Methods: []introspect.Method{
+			{
+				Name: "FindDevice",
+				Args: []introspect.MethodArg{
+					{Name: "name", Type: "s"},
+					{
+						Name:        "path",
+						Type:        "(bo)",
+						Direction:   "out",
+						Annotations: []introspect.Annotation{{Name: "org.chromium.DBus.Argument.Optional", Value: "true"}},
+					},
+				},
+			},
+		},
+	}
+
+	introspections := []introspect.Introspection{{
+		Interfaces: []introspect.Interface{itf},
+	}}
+
+	out := new(bytes.Buffer)
+	if err := Generate(introspections, out, "/tmp/proxy.h", serviceconfig.Config{}); err != nil {
+		t.Fatalf("Generate got error, want nil: %v", err)
+	}
+
+	const want = `// Automatic generation of D-Bus interfaces:
+//  - org.chromium.Test
+#ifndef ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+#define ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+#include <memory>
+#include <optional>
+#include <string>
+#include <vector>
+
+#include <base/functional/bind.h>
+#include <base/functional/callback.h>
+#include <base/logging.h>
+#include <base/memory/ref_counted.h>
+#include <brillo/dbus/dbus_method_invoker.h>
+#include <brillo/dbus/dbus_property.h>
+#include <brillo/dbus/dbus_signal_handler.h>
+#include <brillo/errors/error.h>
+#include <dbus/bus.h>
+#include <dbus/message.h>
+#include <dbus/object_manager.h>
+#include <dbus/object_path.h>
+#include <dbus/object_proxy.h>
+
+#ifndef CHROMEOS_DBUS_BINDINGS_DBUS_OPTIONAL_
+#define CHROMEOS_DBUS_BINDINGS_DBUS_OPTIONAL_
+namespace brillo {
+namespace dbus_utils {
+
+template <typename T>
+struct DBusType<std::optional<T>> {
+  using Tuple = std::tuple<bool, T>;
+
+  inline static std::string GetSignature() {
+    return DBusType<Tuple>::GetSignature();
+  }
+  inline static void Write(dbus::MessageWriter* writer,
+                           const std::optional<T>& value) {
+    DBusType<Tuple>::Write(writer, Tuple(value.has_value(), value.value_or(T())));
+  }
+  inline static bool Read(dbus::MessageReader* reader,
+                          std::optional<T>* value) {
+    Tuple tuple;
+    if (!DBusType<Tuple>::Read(reader, &tuple))
+      return false;
+    if (std::get<0>(tuple))
+      *value = std::move(std::get<1>(tuple));
+    else
+      value->reset();
+    return true;
+  }
+};
+
+}  // namespace dbus_utils
+}  // namespace brillo
+#endif  // CHROMEOS_DBUS_BINDINGS_DBUS_OPTIONAL_
+
+namespace org {
+namespace chromium {
+
+// Abstract interface proxy for org::chromium::Test.
+class TestProxyInterface {
+ public:
+  static constexpr char kInterfaceName[] = "org.chromium.Test";
+  static constexpr char kFindDeviceMethod[] = "FindDevice";
+  static constexpr char kFindDeviceMethodInSignature[] = "s";
+  static constexpr char kFindDeviceMethodOutSignature[] = "(bo)";
+
+  virtual ~TestProxyInterface() = default;
+
+  virtual bool FindDevice(
+      const std::string& in_name,
+      std::optional<dbus::ObjectPath>* out_path,
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
+
+  virtual void FindDeviceAsync(
+      const std::string& in_name,
+      base::OnceCallback<void(const std::optional<dbus::ObjectPath>& /*path*/)> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
+
+  virtual const dbus::ObjectPath& GetObjectPath() const = 0;
+  virtual dbus::ObjectProxy* GetObjectProxy() const = 0;
+};
+
+}  // namespace chromium
+}  // namespace org
+
+namespace org {
+namespace chromium {
+
+// Interface proxy for org::chromium::Test.
+class TestProxy final : public TestProxyInterface {
+ public:
+  TestProxy(
+      const scoped_refptr<dbus::Bus>& bus,
+      const std::string& service_name,
+      const dbus::ObjectPath& object_path) :
+          bus_{bus},
+          service_name_{service_name},
+          object_path_{object_path},
+          dbus_object_proxy_{
+              bus_->GetObjectProxy(service_name_, object_path_)} {
+  }
+
+  TestProxy(const TestProxy&) = delete;
+  TestProxy& operator=(const TestProxy&) = delete;
+
+  ~TestProxy() override {
+  }
+
+  void ReleaseObjectProxy(base::OnceClosure callback) {
+    bus_->RemoveObjectProxy(service_name_, object_path_, std::move(callback));
+  }
+
+  const dbus::ObjectPath& GetObjectPath() const override {
+    return object_path_;
+  }
+
+  dbus::ObjectProxy* GetObjectProxy() const override {
+    return dbus_object_proxy_;
+  }
+
+  bool FindDevice(
+      const std::string& in_name,
+      std::optional<dbus::ObjectPath>* out_path,
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override {
+    auto response = brillo::dbus_utils::CallMethodAndBlockWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "org.chromium.Test",
+        "FindDevice",
+        error,
+        in_name);
+    return response && brillo::dbus_utils::ExtractMethodCallResults(
+        response.get(), error, out_path);
+  }
+
+  void FindDeviceAsync(
+      const std::string& in_name,
+      base::OnceCallback<void(const std::optional<dbus::ObjectPath>& /*path*/)> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override {
+    brillo::dbus_utils::CallMethodWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "org.chromium.Test",
+        "FindDevice",
+        std::move(success_callback),
+        std::move(error_callback),
+        in_name);
+  }
+
+ private:
+  scoped_refptr<dbus::Bus> bus_;
+  std::string service_name_;
+  dbus::ObjectPath object_path_;
+  dbus::ObjectProxy* dbus_object_proxy_;
+
+};
+
+}  // namespace chromium
+}  // namespace org
+
+#endif  // ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+`
+	if diff := cmp.Diff(out.String(), want); diff != "" {
+		t.Errorf("Generate failed (-got +want):\n%s", diff)
+	}
+}
+
+func TestGenerateProxiesWithInstrumentation(t *testing.T) {
+	introspections := []introspect.Introspection{{
+		Name: "/org/chromium/Test",
+		Interfaces: []introspect.Interface{{
+			Name: "org.chromium.Test",
+			Methods: []introspect.Method{
+				{
+					Name: "GetStatus",
+					Args: []introspect.MethodArg{
+						{Name: "verbose", Type: "b"},
+						{Name: "status", Type: "s", Direction: "out"},
+					},
+				}, {
+					Name: "Ping",
+					Annotations: []introspect.Annotation{
+						{Name: "org.chromium.DBus.Method.IncludeDBusMessage", Value: "true"},
+					},
+				},
+			},
+		}},
+	}}
+
+	sc := serviceconfig.Config{
+		ServiceName:       "org.chromium.TestService",
+		InstrumentProxies: true,
+	}
+	out := new(bytes.Buffer)
+	if err := Generate(introspections, out, "/tmp/proxy.h", sc); err != nil {
+		t.Fatalf("Generate got error, want nil: %v", err)
+	}
+
+	const want = `// Automatic generation of D-Bus interfaces:
+//  - org.chromium.Test
+#ifndef ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+#define ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+#include <memory>
+#include <string>
+#include <vector>
+
+#include <base/functional/bind.h>
+#include <base/functional/callback.h>
+#include <base/logging.h>
+#include <base/memory/ref_counted.h>
+#include <base/time/time.h>
+#include <base/trace_event/trace_event.h>
+#include <brillo/dbus/dbus_method_invoker.h>
+#include <brillo/dbus/dbus_property.h>
+#include <brillo/dbus/dbus_signal_handler.h>
+#include <brillo/errors/error.h>
+#include <dbus/bus.h>
+#include <dbus/message.h>
+#include <dbus/object_manager.h>
+#include <dbus/object_path.h>
+#include <dbus/object_proxy.h>
+
+#ifndef CHROMEOS_DBUS_BINDINGS_DBUS_INSTRUMENTATION_
+#define CHROMEOS_DBUS_BINDINGS_DBUS_INSTRUMENTATION_
+namespace chromeos_dbus_bindings {
+
+// Logs the result of the proxy method call |method_name| started at
+// |start_time|, together with its duration.
+inline void LogMethodCall(const char* method_name,
+                          base::TimeTicks start_time,
+                          bool success) {
+  VLOG(1) << "D-Bus method call " << method_name
+          << (success ? " succeeded" : " failed") << " in "
+          << (base::TimeTicks::Now() - start_time);
+}
+
+}  // namespace chromeos_dbus_bindings
+#endif  // CHROMEOS_DBUS_BINDINGS_DBUS_INSTRUMENTATION_
+
+namespace org {
+namespace chromium {
+
+// Abstract interface proxy for org::chromium::Test.
+class TestProxyInterface {
+ public:
+  static constexpr char kInterfaceName[] = "org.chromium.Test";
+  static constexpr char kGetStatusMethod[] = "GetStatus";
+  static constexpr char kGetStatusMethodInSignature[] = "b";
+  static constexpr char kGetStatusMethodOutSignature[] = "s";
+  static constexpr char kPingMethod[] = "Ping";
+  static constexpr char kPingMethodInSignature[] = "";
+  static constexpr char kPingMethodOutSignature[] = "";
+
+  virtual ~TestProxyInterface() = default;
+
+  virtual bool GetStatus(
+      bool in_verbose,
+      std::string* out_status,
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
+
+  virtual void GetStatusAsync(
+      bool in_verbose,
+      base::OnceCallback<void(const std::string& /*status*/)> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
+
+  virtual bool Ping(
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
+
+  virtual void PingAsync(
+      base::OnceCallback<void()> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
+
+  virtual const dbus::ObjectPath& GetObjectPath() const = 0;
+  virtual dbus::ObjectProxy* GetObjectProxy() const = 0;
+};
+
+}  // namespace chromium
+}  // namespace org
+
+namespace org {
+namespace chromium {
+
+// Interface proxy for org::chromium::Test.
+class TestProxy final : public TestProxyInterface {
+ public:
+  TestProxy(const scoped_refptr<dbus::Bus>& bus) :
+      bus_{bus},
+      dbus_object_proxy_{
+          bus_->GetObjectProxy(service_name_, object_path_)} {
+  }
+
+  TestProxy(const TestProxy&) = delete;
+  TestProxy& operator=(const TestProxy&) = delete;
+
+  ~TestProxy() override {
+  }
+
+  void ReleaseObjectProxy(base::OnceClosure callback) {
+    bus_->RemoveObjectProxy(service_name_, object_path_, std::move(callback));
+  }
+
+  const dbus::ObjectPath& GetObjectPath() const override {
+    return object_path_;
+  }
+
+  dbus::ObjectProxy* GetObjectProxy() const override {
+    return dbus_object_proxy_;
+  }
+
+  bool GetStatus(
+      bool in_verbose,
+      std::string* out_status,
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override {
+    TRACE_EVENT0("dbus", "org.chromium.Test.GetStatus");
+    const base::TimeTicks start_time = base::TimeTicks::Now();
+    auto response = brillo::dbus_utils::CallMethodAndBlockWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "org.chromium.Test",
+        "GetStatus",
+        error,
+        in_verbose);
+    const bool success = response && brillo::dbus_utils::ExtractMethodCallResults(
+        response.get(), error, out_status);
+    chromeos_dbus_bindings::LogMethodCall("org.chromium.Test.GetStatus", start_time, success);
+    return success;
+  }
+
+  void GetStatusAsync(
+      bool in_verbose,
+      base::OnceCallback<void(const std::string& /*status*/)> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override {
+    TRACE_EVENT0("dbus", "org.chromium.Test.GetStatusAsync");
+    const base::TimeTicks start_time = base::TimeTicks::Now();
+    brillo::dbus_utils::CallMethodWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "org.chromium.Test",
+        "GetStatus",
+        std::move(success_callback).Then(base::BindOnce(
+            &chromeos_dbus_bindings::LogMethodCall, "org.chromium.Test.GetStatusAsync", start_time, true)),
+        std::move(error_callback).Then(base::BindOnce(
+            &chromeos_dbus_bindings::LogMethodCall, "org.chromium.Test.GetStatusAsync", start_time, false)),
+        in_verbose);
+  }
+
+  bool Ping(
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override {
+    TRACE_EVENT0("dbus", "org.chromium.Test.Ping");
+    const base::TimeTicks start_time = base::TimeTicks::Now();
+    auto response = brillo::dbus_utils::CallMethodAndBlockWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "org.chromium.Test",
+        "Ping",
+        error);
+    const bool success = response && brillo::dbus_utils::ExtractMethodCallResults(
+        response.get(), error);
+    chromeos_dbus_bindings::LogMethodCall("org.chromium.Test.Ping", start_time, success);
+    return success;
+  }
+
+  void PingAsync(
+      base::OnceCallback<void()> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override {
+    TRACE_EVENT0("dbus", "org.chromium.Test.PingAsync");
+    const base::TimeTicks start_time = base::TimeTicks::Now();
+    brillo::dbus_utils::CallMethodWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "org.chromium.Test",
+        "Ping",
+        std::move(success_callback).Then(base::BindOnce(
+            &chromeos_dbus_bindings::LogMethodCall, "org.chromium.Test.PingAsync", start_time, true)),
+        std::move(error_callback).Then(base::BindOnce(
+            &chromeos_dbus_bindings::LogMethodCall, "org.chromium.Test.PingAsync", start_time, false)));
+  }
+
+  // Calls Ping() and returns the response message, e.g. to inspect its
+  // sender, or nullptr on failure. The output arguments can be extracted with
+  // brillo::dbus_utils::ExtractMethodCallResults().
+  std::unique_ptr<dbus::Response> PingWithMessage(
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) {
+    TRACE_EVENT0("dbus", "org.chromium.Test.PingWithMessage");
+    const base::TimeTicks start_time = base::TimeTicks::Now();
+    auto response = brillo::dbus_utils::CallMethodAndBlockWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "org.chromium.Test",
+        "Ping",
+        error);
+    chromeos_dbus_bindings::LogMethodCall("org.chromium.Test.PingWithMessage", start_time, response != nullptr);
+    return response;
+  }
+
+ private:
+  scoped_refptr<dbus::Bus> bus_;
+  const std::string service_name_{"org.chromium.TestService"};
+  const dbus::ObjectPath object_path_{"/org/chromium/Test"};
+  dbus::ObjectProxy* dbus_object_proxy_;
+
+};
+
+}  // namespace chromium
+}  // namespace org
+
+#endif  // ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+`
+	if diff := cmp.Diff(out.String(), want); diff != "" {
+		t.Errorf("Generate failed (-got +want):\n%s", diff)
+	}
+}
+
+func TestGenerateProxiesWithTypeMappings(t *testing.T) {
+	sc := serviceconfig.Config{
+		ServiceName: "org.chromium.TestService",
+		TypeMappings: []serviceconfig.TypeMapping{{
+			Signature: "au",
+			ArgName:   "ip_addresses",
+			CppType:   "std::vector<net_base::IPAddress>",
+			Header:    "<net-base/ip_address.h>",
+		}},
+	}
+	introspections := genutil.ApplyTypeMappings([]introspect.Introspection{{
+		Name: "/org/chromium/Test",
+		Interfaces: []introspect.Interface{{
+			Name: "org.chromium.Test",
+			Methods: []introspect.Method{{
+				Name: "GetAddresses",
+				Args: []introspect.MethodArg{
+					{Name: "ip_addresses", Type: "au", Direction: "out"},
+				},
+			}},
+			Signals: []introspect.Signal{{
+				Name: "AddressesChanged",
+				Args: []introspect.SignalArg{{Name: "ip_addresses", Type: "au"}},
+			}},
+		}},
+	}}, sc.TypeMappings)
+
+	out := new(bytes.Buffer)
+	if err := Generate(introspections, out, "/tmp/proxy.h", sc); err != nil {
+		t.Fatalf("Generate got error, want nil: %v", err)
+	}
+
+	const want = `// Automatic generation of D-Bus interfaces:
+//  - org.chromium.Test
+#ifndef ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+#define ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+#include <memory>
+#include <string>
+#include <vector>
+
+#include <base/functional/bind.h>
+#include <base/functional/callback.h>
+#include <base/logging.h>
+#include <base/memory/ref_counted.h>
+#include <base/memory/weak_ptr.h>
+#include <brillo/dbus/dbus_method_invoker.h>
+#include <brillo/dbus/dbus_property.h>
+#include <brillo/dbus/dbus_signal_handler.h>
+#include <brillo/errors/error.h>
+#include <dbus/bus.h>
+#include <dbus/message.h>
+#include <dbus/object_manager.h>
+#include <dbus/object_path.h>
+#include <dbus/object_proxy.h>
+
+#include <net-base/ip_address.h>
+
+namespace org {
+namespace chromium {
+
+// Abstract interface proxy for org::chromium::Test.
+class TestProxyInterface {
+ public:
+  static constexpr char kInterfaceName[] = "org.chromium.Test";
+  static constexpr char kGetAddressesMethod[] = "GetAddresses";
+  static constexpr char kGetAddressesMethodInSignature[] = "";
+  static constexpr char kGetAddressesMethodOutSignature[] = "au";
+  static constexpr char kAddressesChangedSignal[] = "AddressesChanged";
+  static constexpr char kAddressesChangedSignalSignature[] = "au";
+
+  using AddressesChangedSignalCallback =
+      base::RepeatingCallback<void(const std::vector<net_base::IPAddress>& /*ip_addresses*/)>;
+
+  virtual ~TestProxyInterface() = default;
+
+  virtual bool GetAddresses(
+      std::vector<net_base::IPAddress>* out_ip_addresses,
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
+
+  virtual void GetAddressesAsync(
+      base::OnceCallback<void(const std::vector<net_base::IPAddress>& /*ip_addresses*/)> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
+
+  virtual void RegisterAddressesChangedSignalHandler(
+      const base::RepeatingCallback<void(const std::vector<net_base::IPAddress>&)>& signal_callback,
+      dbus::ObjectProxy::OnConnectedCallback on_connected_callback) = 0;
+
+  // Registers |method| of |target| as the handler of the AddressesChanged signal.
+  // The signals delivered after |target| is invalidated are dropped.
+  template <typename T>
+  void RegisterAddressesChangedSignalHandlerWeak(
+      base::WeakPtr<T> target,
+      void (T::*method)(const std::vector<net_base::IPAddress>&),
+      dbus::ObjectProxy::OnConnectedCallback on_connected_callback) {
+    RegisterAddressesChangedSignalHandler(
+        base::BindRepeating(method, std::move(target)),
+        std::move(on_connected_callback));
+  }
+
+  virtual const dbus::ObjectPath& GetObjectPath() const = 0;
+  virtual dbus::ObjectProxy* GetObjectProxy() const = 0;
+};
+
+}  // namespace chromium
+}  // namespace org
+
+namespace org {
+namespace chromium {
+
+// Interface proxy for org::chromium::Test.
+class TestProxy final : public TestProxyInterface {
+ public:
+  TestProxy(const scoped_refptr<dbus::Bus>& bus) :
+      bus_{bus},
+      dbus_object_proxy_{
+          bus_->GetObjectProxy(service_name_, object_path_)} {
+  }
+
+  TestProxy(const TestProxy&) = delete;
+  TestProxy& operator=(const TestProxy&) = delete;
+
+  ~TestProxy() override {
+  }
+
+  void RegisterAddressesChangedSignalHandler(
+      const base::RepeatingCallback<void(const std::vector<net_base::IPAddress>&)>& signal_callback,
+      dbus::ObjectProxy::OnConnectedCallback on_connected_callback) override {
+    brillo::dbus_utils::ConnectToSignal(
+        dbus_object_proxy_,
+        "org.chromium.Test",
+        "AddressesChanged",
+        signal_callback,
+        std::move(on_connected_callback));
+  }
+
+  void ReleaseObjectProxy(base::OnceClosure callback) {
+    bus_->RemoveObjectProxy(service_name_, object_path_, std::move(callback));
+  }
+
+  const dbus::ObjectPath& GetObjectPath() const override {
+    return object_path_;
+  }
+
+  dbus::ObjectProxy* GetObjectProxy() const override {
+    return dbus_object_proxy_;
+  }
+
+  bool GetAddresses(
+      std::vector<net_base::IPAddress>* out_ip_addresses,
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override {
+    auto response = brillo::dbus_utils::CallMethodAndBlockWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "org.chromium.Test",
+        "GetAddresses",
+        error);
+    return response && brillo::dbus_utils::ExtractMethodCallResults(
+        response.get(), error, out_ip_addresses);
+  }
+
+  void GetAddressesAsync(
+      base::OnceCallback<void(const std::vector<net_base::IPAddress>& /*ip_addresses*/)> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override {
+    brillo::dbus_utils::CallMethodWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "org.chromium.Test",
+        "GetAddresses",
+        std::move(success_callback),
+        std::move(error_callback));
+  }
+
+ private:
+  scoped_refptr<dbus::Bus> bus_;
+  const std::string service_name_{"org.chromium.TestService"};
+  const dbus::ObjectPath object_path_{"/org/chromium/Test"};
+  dbus::ObjectProxy* dbus_object_proxy_;
+
+};
+
+}  // namespace chromium
+}  // namespace org
+
+#endif  // ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+`
+	if diff := cmp.Diff(out.String(), want); diff != "" {
+		t.Errorf("Generate failed (-got +want):\n%s", diff)
+	}
+}
+
+func TestGenerateProxiesWithMetrics(t *testing.T) {
+	introspections := []introspect.Introspection{{
+		Name: "/org/chromium/Test",
+		Interfaces: []introspect.Interface{{
+			Name: "org.chromium.Test",
+			Methods: []introspect.Method{
+				{
+					Name: "GetStatus",
+					Args: []introspect.MethodArg{
+						{Name: "verbose", Type: "b"},
+						{Name: "status", Type: "s", Direction: "out"},
+					},
+				}, {
+					Name: "Ping",
+					Annotations: []introspect.Annotation{
+						{Name: "org.chromium.DBus.Method.IncludeDBusMessage", Value: "true"},
+					},
+				},
+			},
+		}},
+	}}
+
+	sc := serviceconfig.Config{
+		ServiceName:   "org.chromium.TestService",
+		ReportMetrics: true,
+	}
+	out := new(bytes.Buffer)
+	if err := Generate(introspections, out, "/tmp/proxy.h", sc); err != nil {
+		t.Fatalf("Generate got error, want nil: %v", err)
+	}
+
+	const want = `// Automatic generation of D-Bus interfaces:
+//  - org.chromium.Test
+#ifndef ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+#define ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+#include <memory>
+#include <string>
+#include <vector>
+
+#include <base/functional/bind.h>
+#include <base/functional/callback.h>
+#include <base/logging.h>
+#include <base/memory/ref_counted.h>
+#include <base/time/time.h>
+#include <brillo/dbus/dbus_method_invoker.h>
+#include <brillo/dbus/dbus_property.h>
+#include <brillo/dbus/dbus_signal_handler.h>
+#include <brillo/errors/error.h>
+#include <dbus/bus.h>
+#include <dbus/message.h>
+#include <dbus/object_manager.h>
+#include <dbus/object_path.h>
+#include <dbus/object_proxy.h>
+
+#ifndef CHROMEOS_DBUS_BINDINGS_DBUS_METRICS_
+#define CHROMEOS_DBUS_BINDINGS_DBUS_METRICS_
+namespace chromeos_dbus_bindings {
+
+// Receives the result of each proxy method call, e.g. to report it to UMA.
+class MetricsRecorder {
+ public:
+  virtual ~MetricsRecorder() = default;
+
+  // Called when the proxy method call |method_name| completes.
+  virtual void RecordMethodCall(const char* method_name,
+                                bool success,
+                                base::TimeDelta latency) = 0;
+};
+
+// Records the result of the proxy method call |method_name| started at
+// |start_time| into |recorder|, if any.
+inline void RecordMethodCall(MetricsRecorder* recorder,
+                             const char* method_name,
+                             base::TimeTicks start_time,
+                             bool success) {
+  if (recorder) {
+    recorder->RecordMethodCall(method_name, success,
+                               base::TimeTicks::Now() - start_time);
+  }
+}
+
+}  // namespace chromeos_dbus_bindings
+#endif  // CHROMEOS_DBUS_BINDINGS_DBUS_METRICS_
+
+namespace org {
+namespace chromium {
+
+// Abstract interface proxy for org::chromium::Test.
+class TestProxyInterface {
+ public:
+  static constexpr char kInterfaceName[] = "org.chromium.Test";
+  static constexpr char kGetStatusMethod[] = "GetStatus";
+  static constexpr char kGetStatusMethodInSignature[] = "b";
+  static constexpr char kGetStatusMethodOutSignature[] = "s";
+  static constexpr char kPingMethod[] = "Ping";
+  static constexpr char kPingMethodInSignature[] = "";
+  static constexpr char kPingMethodOutSignature[] = "";
+
+  virtual ~TestProxyInterface() = default;
+
+  virtual bool GetStatus(
+      bool in_verbose,
+      std::string* out_status,
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
+
+  virtual void GetStatusAsync(
+      bool in_verbose,
+      base::OnceCallback<void(const std::string& /*status*/)> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
+
+  virtual bool Ping(
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
+
+  virtual void PingAsync(
+      base::OnceCallback<void()> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
+
+  virtual const dbus::ObjectPath& GetObjectPath() const = 0;
+  virtual dbus::ObjectProxy* GetObjectProxy() const = 0;
+};
+
+}  // namespace chromium
+}  // namespace org
+
+namespace org {
+namespace chromium {
+
+// Interface proxy for org::chromium::Test.
+class TestProxy final : public TestProxyInterface {
+ public:
+  TestProxy(
+      const scoped_refptr<dbus::Bus>& bus,
+      chromeos_dbus_bindings::MetricsRecorder* metrics_recorder = nullptr) :
+          bus_{bus},
+          metrics_recorder_{metrics_recorder},
+          dbus_object_proxy_{
+              bus_->GetObjectProxy(service_name_, object_path_)} {
+  }
+
+  TestProxy(const TestProxy&) = delete;
+  TestProxy& operator=(const TestProxy&) = delete;
+
+  ~TestProxy() override {
+  }
+
+  void ReleaseObjectProxy(base::OnceClosure callback) {
+    bus_->RemoveObjectProxy(service_name_, object_path_, std::move(callback));
+  }
+
+  const dbus::ObjectPath& GetObjectPath() const override {
+    return object_path_;
+  }
+
+  dbus::ObjectProxy* GetObjectProxy() const override {
+    return dbus_object_proxy_;
+  }
+
+  bool GetStatus(
+      bool in_verbose,
+      std::string* out_status,
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override {
+    const base::TimeTicks start_time = base::TimeTicks::Now();
+    auto response = brillo::dbus_utils::CallMethodAndBlockWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "org.chromium.Test",
+        "GetStatus",
+        error,
+        in_verbose);
+    const bool success = response && brillo::dbus_utils::ExtractMethodCallResults(
+        response.get(), error, out_status);
+    chromeos_dbus_bindings::RecordMethodCall(metrics_recorder_, "org.chromium.Test.GetStatus", start_time, success);
+    return success;
+  }
+
+  void GetStatusAsync(
+      bool in_verbose,
+      base::OnceCallback<void(const std::string& /*status*/)> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override {
+    const base::TimeTicks start_time = base::TimeTicks::Now();
+    brillo::dbus_utils::CallMethodWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "org.chromium.Test",
+        "GetStatus",
+        std::move(success_callback).Then(base::BindOnce(
+            &chromeos_dbus_bindings::RecordMethodCall, base::Unretained(metrics_recorder_), "org.chromium.Test.GetStatusAsync", start_time, true)),
+        std::move(error_callback).Then(base::BindOnce(
+            &chromeos_dbus_bindings::RecordMethodCall, base::Unretained(metrics_recorder_), "org.chromium.Test.GetStatusAsync", start_time, false)),
+        in_verbose);
+  }
+
+  bool Ping(
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override {
+    const base::TimeTicks start_time = base::TimeTicks::Now();
+    auto response = brillo::dbus_utils::CallMethodAndBlockWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "org.chromium.Test",
+        "Ping",
+        error);
+    const bool success = response && brillo::dbus_utils::ExtractMethodCallResults(
+        response.get(), error);
+    chromeos_dbus_bindings::RecordMethodCall(metrics_recorder_, "org.chromium.Test.Ping", start_time, success);
+    return success;
+  }
+
+  void PingAsync(
+      base::OnceCallback<void()> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override {
+    const base::TimeTicks start_time = base::TimeTicks::Now();
+    brillo::dbus_utils::CallMethodWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "org.chromium.Test",
+        "Ping",
+        std::move(success_callback).Then(base::BindOnce(
+            &chromeos_dbus_bindings::RecordMethodCall, base::Unretained(metrics_recorder_), "org.chromium.Test.PingAsync", start_time, true)),
+        std::move(error_callback).Then(base::BindOnce(
+            &chromeos_dbus_bindings::RecordMethodCall, base::Unretained(metrics_recorder_), "org.chromium.Test.PingAsync", start_time, false)));
+  }
+
+  // Calls Ping() and returns the response message, e.g. to inspect its
+  // sender, or nullptr on failure. The output arguments can be extracted with
+  // brillo::dbus_utils::ExtractMethodCallResults().
+  std::unique_ptr<dbus::Response> PingWithMessage(
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) {
+    const base::TimeTicks start_time = base::TimeTicks::Now();
+    auto response = brillo::dbus_utils::CallMethodAndBlockWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "org.chromium.Test",
+        "Ping",
+        error);
+    chromeos_dbus_bindings::RecordMethodCall(metrics_recorder_, "org.chromium.Test.PingWithMessage", start_time, response != nullptr);
+    return response;
+  }
+
+ private:
+  scoped_refptr<dbus::Bus> bus_;
+  const std::string service_name_{"org.chromium.TestService"};
+  const dbus::ObjectPath object_path_{"/org/chromium/Test"};
+  chromeos_dbus_bindings::MetricsRecorder* metrics_recorder_;
+  dbus::ObjectProxy* dbus_object_proxy_;
+
+};
+
+}  // namespace chromium
+}  // namespace org
+
+#endif  // ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+`
+	if diff := cmp.Diff(out.String(), want); diff != "" {
+		t.Errorf("Generate failed (-got +want):\n%s", diff)
+	}
+}
+
+func TestGenerateProxiesWithSequenceCheckers(t *testing.T) {
+	introspections := []introspect.Introspection{{
+		Name: "/org/chromium/Test",
+		Interfaces: []introspect.Interface{{
+			Name: "org.chromium.Test",
+			Methods: []introspect.Method{
+				{
+					Name: "GetStatus",
+					Args: []introspect.MethodArg{
+						{Name: "status", Type: "s", Direction: "out"},
+					},
+				},
+			},
+			Signals: []introspect.Signal{
+				{
+					Name: "Changed",
+					Args: []introspect.SignalArg{
+						{Name: "status", Type: "s"},
+					},
+				},
+			},
+			Properties: []introspect.Property{
+				{
+					Name:   "Level",
+					Type:   "i",
+					Access: "read",
+				},
+			},
+		}},
+	}}
+
+	sc := serviceconfig.Config{
+		ServiceName:      "org.chromium.TestService",
+		SequenceCheckers: true,
+	}
+	out := new(bytes.Buffer)
+	if err := Generate(introspections, out, "/tmp/proxy.h", sc); err != nil {
+		t.Fatalf("Generate got error, want nil: %v", err)
+	}
+
+	const want = `// Automatic generation of D-Bus interfaces:
+//  - org.chromium.Test
+#ifndef ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+#define ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+#include <memory>
+#include <string>
+#include <vector>
+
+#include <base/functional/bind.h>
+#include <base/functional/callback.h>
+#include <base/logging.h>
+#include <base/memory/ref_counted.h>
+#include <base/memory/weak_ptr.h>
+#include <base/sequence_checker.h>
+#include <brillo/dbus/dbus_method_invoker.h>
+#include <brillo/dbus/dbus_property.h>
+#include <brillo/dbus/dbus_signal_handler.h>
+#include <brillo/errors/error.h>
+#include <dbus/bus.h>
+#include <dbus/message.h>
+#include <dbus/object_manager.h>
+#include <dbus/object_path.h>
+#include <dbus/object_proxy.h>
+
+namespace org {
+namespace chromium {
+
+// Abstract interface proxy for org::chromium::Test.
+class TestProxyInterface {
+ public:
+  static constexpr char kInterfaceName[] = "org.chromium.Test";
+  static constexpr char kGetStatusMethod[] = "GetStatus";
+  static constexpr char kGetStatusMethodInSignature[] = "";
+  static constexpr char kGetStatusMethodOutSignature[] = "s";
+  static constexpr char kChangedSignal[] = "Changed";
+  static constexpr char kChangedSignalSignature[] = "s";
+  static constexpr char kLevelProperty[] = "Level";
+  static constexpr char kLevelPropertySignature[] = "i";
+
+  using ChangedSignalCallback =
+      base::RepeatingCallback<void(const std::string& /*status*/)>;
+
+  virtual ~TestProxyInterface() = default;
+
+  virtual bool GetStatus(
+      std::string* out_status,
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
+
+  virtual void GetStatusAsync(
+      base::OnceCallback<void(const std::string& /*status*/)> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
+
+  virtual void RegisterChangedSignalHandler(
+      const base::RepeatingCallback<void(const std::string&)>& signal_callback,
+      dbus::ObjectProxy::OnConnectedCallback on_connected_callback) = 0;
+
+  // Registers |method| of |target| as the handler of the Changed signal.
+  // The signals delivered after |target| is invalidated are dropped.
+  template <typename T>
+  void RegisterChangedSignalHandlerWeak(
+      base::WeakPtr<T> target,
+      void (T::*method)(const std::string&),
+      dbus::ObjectProxy::OnConnectedCallback on_connected_callback) {
+    RegisterChangedSignalHandler(
+        base::BindRepeating(method, std::move(target)),
+        std::move(on_connected_callback));
+  }
+
+  static const char* LevelName() { return "Level"; }
+  virtual int32_t level() const = 0;
+  virtual bool is_level_valid() const = 0;
+  virtual void SetLevelChangedCallback(
+      const base::RepeatingCallback<void(int32_t)>& callback) = 0;
+
+  virtual const dbus::ObjectPath& GetObjectPath() const = 0;
+  virtual dbus::ObjectProxy* GetObjectProxy() const = 0;
+
+  virtual void InitializeProperties(
+      const base::RepeatingCallback<void(TestProxyInterface*, const std::string&)>& callback) = 0;
+};
+
+}  // namespace chromium
+}  // namespace org
+
+namespace org {
+namespace chromium {
+
+// Interface proxy for org::chromium::Test.
+class TestProxy final : public TestProxyInterface {
+ public:
+  class PropertySet : public dbus::PropertySet {
+   public:
+    PropertySet(dbus::ObjectProxy* object_proxy,
+                const PropertyChangedCallback& callback)
+        : dbus::PropertySet{object_proxy,
+                            "org.chromium.Test",
+                            callback} {
+      RegisterProperty(LevelName(), &level);
+    }
+    PropertySet(const PropertySet&) = delete;
+    PropertySet& operator=(const PropertySet&) = delete;
+
+    brillo::dbus_utils::Property<int32_t> level;
+
+  };
+
+  TestProxy(const scoped_refptr<dbus::Bus>& bus) :
+      bus_{bus},
+      dbus_object_proxy_{
+          bus_->GetObjectProxy(service_name_, object_path_)} {
+  }
+
+  // How the constructor initializes the properties.
+  enum class InitializePropertiesPolicy {
+    // Creates the property set and connects the PropertiesChanged signal,
+    // leaving the values to be fetched on demand.
+    kLazy,
+    // Does what InitializeProperties() does, i.e. also fetches all the values.
+    kEager,
+    // Leaves the properties uninitialized, as the other constructor does.
+    kNone,
+  };
+
+  TestProxy(
+      const scoped_refptr<dbus::Bus>& bus,
+      InitializePropertiesPolicy policy,
+      const base::RepeatingCallback<void(TestProxyInterface*, const std::string&)>& callback) :
+          TestProxy(bus) {
+    switch (policy) {
+      case InitializePropertiesPolicy::kLazy:
+        on_property_changed_ = callback;
+        property_set_.reset(
+            new PropertySet(dbus_object_proxy_,
+                            base::BindRepeating(&TestProxy::OnPropertyChanged,
+                                                base::Unretained(this))));
+        property_set_->ConnectSignals();
+        break;
+      case InitializePropertiesPolicy::kEager:
+        InitializeProperties(callback);
+        break;
+      case InitializePropertiesPolicy::kNone:
+        break;
+    }
+  }
+
+  TestProxy(const TestProxy&) = delete;
+  TestProxy& operator=(const TestProxy&) = delete;
+
+  ~TestProxy() override {
+  }
+
+  void RegisterChangedSignalHandler(
+      const base::RepeatingCallback<void(const std::string&)>& signal_callback,
+      dbus::ObjectProxy::OnConnectedCallback on_connected_callback) override {
+    DCHECK_CALLED_ON_VALID_SEQUENCE(sequence_checker_);
+    brillo::dbus_utils::ConnectToSignal(
+        dbus_object_proxy_,
+        "org.chromium.Test",
+        "Changed",
+        signal_callback,
+        std::move(on_connected_callback));
+  }
+
+  void ReleaseObjectProxy(base::OnceClosure callback) {
+    bus_->RemoveObjectProxy(service_name_, object_path_, std::move(callback));
+  }
+
+  const dbus::ObjectPath& GetObjectPath() const override {
+    return object_path_;
+  }
+
+  dbus::ObjectProxy* GetObjectProxy() const override {
+    return dbus_object_proxy_;
+  }
+
+  void InitializeProperties(
+      const base::RepeatingCallback<void(TestProxyInterface*, const std::string&)>& callback) override {
+    DCHECK_CALLED_ON_VALID_SEQUENCE(sequence_checker_);
+    on_property_changed_ = callback;
+    property_set_.reset(
+        new PropertySet(dbus_object_proxy_,
+                        base::BindRepeating(&TestProxy::OnPropertyChanged,
+                                            base::Unretained(this))));
+    property_set_->ConnectSignals();
+    property_set_->GetAll();
+  }
+
+  const PropertySet* GetProperties() const { return &(*property_set_); }
+  PropertySet* GetProperties() { return &(*property_set_); }
+
+  bool GetStatus(
+      std::string* out_status,
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override {
+    DCHECK_CALLED_ON_VALID_SEQUENCE(sequence_checker_);
+    auto response = brillo::dbus_utils::CallMethodAndBlockWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "org.chromium.Test",
+        "GetStatus",
+        error);
+    return response && brillo::dbus_utils::ExtractMethodCallResults(
+        response.get(), error, out_status);
+  }
+
+  void GetStatusAsync(
+      base::OnceCallback<void(const std::string& /*status*/)> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override {
+    DCHECK_CALLED_ON_VALID_SEQUENCE(sequence_checker_);
+    brillo::dbus_utils::CallMethodWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "org.chromium.Test",
+        "GetStatus",
+        std::move(success_callback),
+        std::move(error_callback));
+  }
+
+  int32_t level() const override {
+    return property_set_->level.value();
+  }
+
+  bool is_level_valid() const override {
+    return property_set_->level.is_valid();
+  }
+
+  void SetLevelChangedCallback(
+      const base::RepeatingCallback<void(int32_t)>& callback) override {
+    DCHECK_CALLED_ON_VALID_SEQUENCE(sequence_checker_);
+    on_level_changed_ = callback;
+  }
+
+ private:
+  void OnPropertyChanged(const std::string& property_name) {
+    if (property_name == LevelName() && !on_level_changed_.is_null())
+      on_level_changed_.Run(property_set_->level.value());
+    if (!on_property_changed_.is_null())
+      on_property_changed_.Run(this, property_name);
+  }
+
+  scoped_refptr<dbus::Bus> bus_;
+  const std::string service_name_{"org.chromium.TestService"};
+  const dbus::ObjectPath object_path_{"/org/chromium/Test"};
+  // Guarded by sequence_checker_.
+  base::RepeatingCallback<void(TestProxyInterface*, const std::string&)> on_property_changed_;
+  // Guarded by sequence_checker_.
+  base::RepeatingCallback<void(int32_t)> on_level_changed_;
+  dbus::ObjectProxy* dbus_object_proxy_;
+  // Guarded by sequence_checker_.
+  std::unique_ptr<PropertySet> property_set_;
+  // The proxy must be used on the sequence it is created on, as brillo D-Bus
+  // calls and signal handlers are not thread-safe.
+  SEQUENCE_CHECKER(sequence_checker_);
+
+};
+
+}  // namespace chromium
+}  // namespace org
+
+#endif  // ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+`
+	if diff := cmp.Diff(out.String(), want); diff != "" {
+		t.Errorf("Generate failed (-got +want):\n%s", diff)
+	}
+}
+
+func TestGenerateProxiesWithNoReply(t *testing.T) {
+	introspections := []introspect.Introspection{{
+		Name: "/org/chromium/Test",
+		Interfaces: []introspect.Interface{{
+			Name: "org.chromium.Test",
+			Methods: []introspect.Method{
+				{
+					Name: "Notify",
+					Args: []introspect.MethodArg{
+						{Name: "message", Type: "s"},
+					},
+					Annotations: []introspect.Annotation{
+						{Name: "org.freedesktop.DBus.Method.NoReply", Value: "true"},
+					},
+				},
+			},
+		}},
+	}}
+
+	sc := serviceconfig.Config{
+		ServiceName: "org.chromium.TestService",
+	}
+	out := new(bytes.Buffer)
+	if err := Generate(introspections, out, "/tmp/proxy.h", sc); err != nil {
+		t.Fatalf("Generate got error, want nil: %v", err)
+	}
+
+	const want = `// Automatic generation of D-Bus interfaces:
+//  - org.chromium.Test
+#ifndef ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+#define ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+#include <memory>
+#include <string>
+#include <vector>
+
+#include <base/functional/bind.h>
+#include <base/functional/callback.h>
+#include <base/functional/callback_helpers.h>
+#include <base/logging.h>
+#include <base/memory/ref_counted.h>
+#include <brillo/dbus/dbus_method_invoker.h>
+#include <brillo/dbus/dbus_property.h>
+#include <brillo/dbus/dbus_signal_handler.h>
+#include <brillo/errors/error.h>
+#include <dbus/bus.h>
+#include <dbus/message.h>
+#include <dbus/object_manager.h>
+#include <dbus/object_path.h>
+#include <dbus/object_proxy.h>
+
+namespace org {
+namespace chromium {
+
+// Abstract interface proxy for org::chromium::Test.
+class TestProxyInterface {
+ public:
+  static constexpr char kInterfaceName[] = "org.chromium.Test";
+  static constexpr char kNotifyMethod[] = "Notify";
+  static constexpr char kNotifyMethodInSignature[] = "s";
+  static constexpr char kNotifyMethodOutSignature[] = "";
+
+  virtual ~TestProxyInterface() = default;
+
+  virtual bool Notify(
+      const std::string& in_message,
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
+
+  virtual void NotifyAsync(
+      const std::string& in_message,
+      base::OnceCallback<void()> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
+
+  virtual const dbus::ObjectPath& GetObjectPath() const = 0;
+  virtual dbus::ObjectProxy* GetObjectProxy() const = 0;
+};
+
+}  // namespace chromium
+}  // namespace org
+
+namespace org {
+namespace chromium {
+
+// Interface proxy for org::chromium::Test.
+class TestProxy final : public TestProxyInterface {
+ public:
+  TestProxy(const scoped_refptr<dbus::Bus>& bus) :
+      bus_{bus},
+      dbus_object_proxy_{
+          bus_->GetObjectProxy(service_name_, object_path_)} {
+  }
+
+  TestProxy(const TestProxy&) = delete;
+  TestProxy& operator=(const TestProxy&) = delete;
+
+  ~TestProxy() override {
+  }
+
+  void ReleaseObjectProxy(base::OnceClosure callback) {
+    bus_->RemoveObjectProxy(service_name_, object_path_, std::move(callback));
+  }
+
+  const dbus::ObjectPath& GetObjectPath() const override {
+    return object_path_;
+  }
+
+  dbus::ObjectProxy* GetObjectProxy() const override {
+    return dbus_object_proxy_;
+  }
+
+  bool Notify(
+      const std::string& in_message,
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override {
+    brillo::dbus_utils::CallMethodWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "org.chromium.Test",
+        "Notify",
+        base::OnceCallback<void()>(base::DoNothing()),
+        base::DoNothing(),
+        in_message);
+    return true;
+  }
+
+  void NotifyAsync(
+      const std::string& in_message,
+      base::OnceCallback<void()> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override {
+    brillo::dbus_utils::CallMethodWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "org.chromium.Test",
+        "Notify",
+        base::OnceCallback<void()>(base::DoNothing()),
+        base::DoNothing(),
+        in_message);
+    std::move(success_callback).Run();
+  }
+
+ private:
+  scoped_refptr<dbus::Bus> bus_;
+  const std::string service_name_{"org.chromium.TestService"};
+  const dbus::ObjectPath object_path_{"/org/chromium/Test"};
+  dbus::ObjectProxy* dbus_object_proxy_;
+
+};
+
+}  // namespace chromium
+}  // namespace org
+
+#endif  // ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+`
+	if diff := cmp.Diff(out.String(), want); diff != "" {
+		t.Errorf("Generate failed (-got +want):\n%s", diff)
+	}
+}
+
+func TestGenerateProxiesWithRestartCallbacks(t *testing.T) {
+	introspections := []introspect.Introspection{{
+		Name: "/org/chromium/Test",
+		Interfaces: []introspect.Interface{{
+			Name: "org.chromium.Test",
+			Signals: []introspect.Signal{
+				{
+					Name: "Changed",
+					Args: []introspect.SignalArg{
+						{Name: "status", Type: "s"},
+					},
+				},
+			},
+			Properties: []introspect.Property{
+				{
+					Name:   "Level",
+					Type:   "i",
+					Access: "read",
+				},
+			},
+		}},
+	}}
+
+	sc := serviceconfig.Config{
+		ServiceName:      "org.chromium.TestService",
+		RestartCallbacks: true,
+	}
+	out := new(bytes.Buffer)
+	if err := Generate(introspections, out, "/tmp/proxy.h", sc); err != nil {
+		t.Fatalf("Generate got error, want nil: %v", err)
+	}
+
+	const want = `// Automatic generation of D-Bus interfaces:
+//  - org.chromium.Test
+#ifndef ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+#define ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+#include <memory>
+#include <string>
+#include <vector>
+
+#include <base/functional/bind.h>
+#include <base/functional/callback.h>
+#include <base/logging.h>
+#include <base/memory/ref_counted.h>
+#include <base/memory/weak_ptr.h>
+#include <brillo/dbus/dbus_method_invoker.h>
+#include <brillo/dbus/dbus_property.h>
+#include <brillo/dbus/dbus_signal_handler.h>
+#include <brillo/errors/error.h>
+#include <dbus/bus.h>
+#include <dbus/message.h>
+#include <dbus/object_manager.h>
+#include <dbus/object_path.h>
+#include <dbus/object_proxy.h>
+
+namespace org {
+namespace chromium {
+
+// Abstract interface proxy for org::chromium::Test.
+class TestProxyInterface {
+ public:
+  static constexpr char kInterfaceName[] = "org.chromium.Test";
+  static constexpr char kChangedSignal[] = "Changed";
+  static constexpr char kChangedSignalSignature[] = "s";
+  static constexpr char kLevelProperty[] = "Level";
+  static constexpr char kLevelPropertySignature[] = "i";
+
+  using ChangedSignalCallback =
+      base::RepeatingCallback<void(const std::string& /*status*/)>;
+
+  virtual ~TestProxyInterface() = default;
+
+  virtual void RegisterChangedSignalHandler(
+      const base::RepeatingCallback<void(const std::string&)>& signal_callback,
+      dbus::ObjectProxy::OnConnectedCallback on_connected_callback) = 0;
+
+  // Registers |method| of |target| as the handler of the Changed signal.
+  // The signals delivered after |target| is invalidated are dropped.
+  template <typename T>
+  void RegisterChangedSignalHandlerWeak(
+      base::WeakPtr<T> target,
+      void (T::*method)(const std::string&),
+      dbus::ObjectProxy::OnConnectedCallback on_connected_callback) {
+    RegisterChangedSignalHandler(
+        base::BindRepeating(method, std::move(target)),
+        std::move(on_connected_callback));
+  }
+
+  static const char* LevelName() { return "Level"; }
+  virtual int32_t level() const = 0;
+  virtual bool is_level_valid() const = 0;
+  virtual void SetLevelChangedCallback(
+      const base::RepeatingCallback<void(int32_t)>& callback) = 0;
+
+  virtual const dbus::ObjectPath& GetObjectPath() const = 0;
+  virtual dbus::ObjectProxy* GetObjectProxy() const = 0;
+
+  virtual void InitializeProperties(
+      const base::RepeatingCallback<void(TestProxyInterface*, const std::string&)>& callback) = 0;
+};
+
+}  // namespace chromium
+}  // namespace org
+
+namespace org {
+namespace chromium {
+
+// Interface proxy for org::chromium::Test.
+class TestProxy final : public TestProxyInterface {
+ public:
+  class PropertySet : public dbus::PropertySet {
+   public:
+    PropertySet(dbus::ObjectProxy* object_proxy,
+                const PropertyChangedCallback& callback)
+        : dbus::PropertySet{object_proxy,
+                            "org.chromium.Test",
+                            callback} {
+      RegisterProperty(LevelName(), &level);
+    }
+    PropertySet(const PropertySet&) = delete;
+    PropertySet& operator=(const PropertySet&) = delete;
+
+    brillo::dbus_utils::Property<int32_t> level;
+
+  };
+
+  TestProxy(const scoped_refptr<dbus::Bus>& bus) :
+      bus_{bus},
+      dbus_object_proxy_{
+          bus_->GetObjectProxy(service_name_, object_path_)} {
+  }
+
+  // How the constructor initializes the properties.
+  enum class InitializePropertiesPolicy {
+    // Creates the property set and connects the PropertiesChanged signal,
+    // leaving the values to be fetched on demand.
+    kLazy,
+    // Does what InitializeProperties() does, i.e. also fetches all the values.
+    kEager,
+    // Leaves the properties uninitialized, as the other constructor does.
+    kNone,
+  };
+
+  TestProxy(
+      const scoped_refptr<dbus::Bus>& bus,
+      InitializePropertiesPolicy policy,
+      const base::RepeatingCallback<void(TestProxyInterface*, const std::string&)>& callback) :
+          TestProxy(bus) {
+    switch (policy) {
+      case InitializePropertiesPolicy::kLazy:
+        on_property_changed_ = callback;
+        property_set_.reset(
+            new PropertySet(dbus_object_proxy_,
+                            base::BindRepeating(&TestProxy::OnPropertyChanged,
+                                                base::Unretained(this))));
+        property_set_->ConnectSignals();
+        break;
+      case InitializePropertiesPolicy::kEager:
+        InitializeProperties(callback);
+        break;
+      case InitializePropertiesPolicy::kNone:
+        break;
+    }
+  }
+
+  TestProxy(const TestProxy&) = delete;
+  TestProxy& operator=(const TestProxy&) = delete;
+
+  ~TestProxy() override {
+  }
+
+  void RegisterChangedSignalHandler(
+      const base::RepeatingCallback<void(const std::string&)>& signal_callback,
+      dbus::ObjectProxy::OnConnectedCallback on_connected_callback) override {
+    brillo::dbus_utils::ConnectToSignal(
+        dbus_object_proxy_,
+        "org.chromium.Test",
+        "Changed",
+        signal_callback,
+        std::move(on_connected_callback));
+  }
+
+  void ReleaseObjectProxy(base::OnceClosure callback) {
+    bus_->RemoveObjectProxy(service_name_, object_path_, std::move(callback));
+  }
+
+  const dbus::ObjectPath& GetObjectPath() const override {
+    return object_path_;
+  }
+
+  dbus::ObjectProxy* GetObjectProxy() const override {
+    return dbus_object_proxy_;
+  }
+
+  // Runs |callback| once the service becomes available, with false if
+  // waiting for it times out.
+  void SetServiceAvailableCallback(
+      dbus::ObjectProxy::WaitForServiceToBeAvailableCallback callback) {
+    dbus_object_proxy_->WaitForServiceToBeAvailable(std::move(callback));
+  }
+
+  // Runs |callback| with the old and the new owners of the service name
+  // whenever it changes, i.e. when the service stops or restarts. The signal
+  // handlers stay connected across the restarts.
+  // The properties are fetched again when the service restarts.
+  void SetNameOwnerChangedCallback(
+      const base::RepeatingCallback<void(const std::string&, const std::string&)>& callback) {
+    dbus_object_proxy_->SetNameOwnerChangedCallback(
+        base::BindRepeating(&TestProxy::OnNameOwnerChanged,
+                            weak_ptr_factory_.GetWeakPtr(), callback));
+  }
+
+  void InitializeProperties(
+      const base::RepeatingCallback<void(TestProxyInterface*, const std::string&)>& callback) override {
+    on_property_changed_ = callback;
+    property_set_.reset(
+        new PropertySet(dbus_object_proxy_,
+                        base::BindRepeating(&TestProxy::OnPropertyChanged,
+                                            base::Unretained(this))));
+    property_set_->ConnectSignals();
+    property_set_->GetAll();
+  }
+
+  const PropertySet* GetProperties() const { return &(*property_set_); }
+  PropertySet* GetProperties() { return &(*property_set_); }
+
+  int32_t level() const override {
+    return property_set_->level.value();
+  }
+
+  bool is_level_valid() const override {
+    return property_set_->level.is_valid();
+  }
+
+  void SetLevelChangedCallback(
+      const base::RepeatingCallback<void(int32_t)>& callback) override {
+    on_level_changed_ = callback;
+  }
+
+ private:
+  void OnPropertyChanged(const std::string& property_name) {
+    if (property_name == LevelName() && !on_level_changed_.is_null())
+      on_level_changed_.Run(property_set_->level.value());
+    if (!on_property_changed_.is_null())
+      on_property_changed_.Run(this, property_name);
+  }
+
+  void OnNameOwnerChanged(
+      const base::RepeatingCallback<void(const std::string&, const std::string&)>& callback,
+      const std::string& old_owner,
+      const std::string& new_owner) {
+    // The restarted service does not notify the changes from the values
+    // cached before.
+    if (!new_owner.empty() && property_set_)
+      property_set_->GetAll();
+    callback.Run(old_owner, new_owner);
+  }
+
+  scoped_refptr<dbus::Bus> bus_;
+  const std::string service_name_{"org.chromium.TestService"};
+  const dbus::ObjectPath object_path_{"/org/chromium/Test"};
+  base::RepeatingCallback<void(TestProxyInterface*, const std::string&)> on_property_changed_;
+  base::RepeatingCallback<void(int32_t)> on_level_changed_;
+  dbus::ObjectProxy* dbus_object_proxy_;
+  std::unique_ptr<PropertySet> property_set_;
+  base::WeakPtrFactory<TestProxy> weak_ptr_factory_{this};
+
+};
+
+}  // namespace chromium
+}  // namespace org
+
+#endif  // ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+`
+	if diff := cmp.Diff(out.String(), want); diff != "" {
+		t.Errorf("Generate failed (-got +want):\n%s", diff)
+	}
+}
+
+func TestGenerateProxiesWithCredentialQueries(t *testing.T) {
+	introspections := []introspect.Introspection{{
+		Name: "/org/chromium/Test",
+		Interfaces: []introspect.Interface{{
+			Name: "org.chromium.Test",
+			Methods: []introspect.Method{
+				{
+					Name: "Ping",
+				},
+			},
+		}},
+	}}
+
+	sc := serviceconfig.Config{
+		ServiceName:       "org.chromium.TestService",
+		CredentialQueries: true,
+	}
+	out := new(bytes.Buffer)
+	if err := Generate(introspections, out, "/tmp/proxy.h", sc); err != nil {
+		t.Fatalf("Generate got error, want nil: %v", err)
+	}
+
+	const want = `// Automatic generation of D-Bus interfaces:
+//  - org.chromium.Test
+#ifndef ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+#define ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+#include <memory>
+#include <optional>
+#include <string>
+#include <vector>
+
+#include <base/functional/bind.h>
+#include <base/functional/callback.h>
+#include <base/logging.h>
+#include <base/memory/ref_counted.h>
+#include <brillo/dbus/dbus_method_invoker.h>
+#include <brillo/dbus/dbus_property.h>
+#include <brillo/dbus/dbus_signal_handler.h>
+#include <brillo/errors/error.h>
+#include <dbus/bus.h>
+#include <dbus/message.h>
+#include <dbus/object_manager.h>
+#include <dbus/object_path.h>
+#include <dbus/object_proxy.h>
+
+#ifndef CHROMEOS_DBUS_BINDINGS_DBUS_CONNECTION_CREDENTIALS_
+#define CHROMEOS_DBUS_BINDINGS_DBUS_CONNECTION_CREDENTIALS_
+namespace chromeos_dbus_bindings {
+
+// Credentials of the connection owning a service name, as reported by the
+// bus.
+struct ConnectionCredentials {
+  uint32_t unix_user_id;
+  uint32_t process_id;
+};
+
+// Queries the bus for the credentials of the owner of a service name with
+// GetConnectionUnixUser and GetConnectionUnixProcessID. The callback is run
+// once, with std::nullopt if either query fails.
+class ConnectionCredentialsQuery {
+ public:
+  using Callback =
+      base::OnceCallback<void(std::optional<ConnectionCredentials>)>;
+
+  static void Start(const scoped_refptr<dbus::Bus>& bus,
+                    const std::string& service_name,
+                    Callback callback) {
+    auto state = std::make_shared<State>(State{
+        bus->GetObjectProxy("org.freedesktop.DBus",
+                            dbus::ObjectPath("/org/freedesktop/DBus")),
+        service_name, std::move(callback), 0});
+    brillo::dbus_utils::CallMethod(
+        state->bus_proxy, "org.freedesktop.DBus", "GetConnectionUnixUser",
+        base::BindOnce(&ConnectionCredentialsQuery::OnUnixUser, state),
+        base::BindOnce(&ConnectionCredentialsQuery::OnError, state),
+        state->service_name);
+  }
+
+ private:
+  struct State {
+    dbus::ObjectProxy* bus_proxy;
+    std::string service_name;
+    Callback callback;
+    uint32_t unix_user_id;
+  };
+
+  static void OnUnixUser(const std::shared_ptr<State>& state,
+                         uint32_t unix_user_id) {
+    state->unix_user_id = unix_user_id;
+    brillo::dbus_utils::CallMethod(
+        state->bus_proxy, "org.freedesktop.DBus", "GetConnectionUnixProcessID",
+        base::BindOnce(&ConnectionCredentialsQuery::OnProcessID, state),
+        base::BindOnce(&ConnectionCredentialsQuery::OnError, state),
+        state->service_name);
+  }
+  static void OnProcessID(const std::shared_ptr<State>& state,
+                          uint32_t process_id) {
+    std::move(state->callback)
+        .Run(ConnectionCredentials{state->unix_user_id, process_id});
+  }
+  static void OnError(const std::shared_ptr<State>& state,
+                      brillo::Error* error) {
+    LOG(ERROR) << "Failed to get the credentials of " << state->service_name
+               << ": " << (error ? error->GetMessage() : "unknown error");
+    std::move(state->callback).Run(std::nullopt);
+  }
+};
+
+inline void GetConnectionCredentials(
+    const scoped_refptr<dbus::Bus>& bus,
+    const std::string& service_name,
+    ConnectionCredentialsQuery::Callback callback) {
+  ConnectionCredentialsQuery::Start(bus, service_name, std::move(callback));
+}
+
+}  // namespace chromeos_dbus_bindings
+#endif  // CHROMEOS_DBUS_BINDINGS_DBUS_CONNECTION_CREDENTIALS_
+
+namespace org {
+namespace chromium {
+
+// Abstract interface proxy for org::chromium::Test.
+class TestProxyInterface {
+ public:
+  static constexpr char kInterfaceName[] = "org.chromium.Test";
+  static constexpr char kPingMethod[] = "Ping";
+  static constexpr char kPingMethodInSignature[] = "";
+  static constexpr char kPingMethodOutSignature[] = "";
+
+  virtual ~TestProxyInterface() = default;
+
+  virtual bool Ping(
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
+
+  virtual void PingAsync(
+      base::OnceCallback<void()> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
+
+  virtual const dbus::ObjectPath& GetObjectPath() const = 0;
+  virtual dbus::ObjectProxy* GetObjectProxy() const = 0;
+};
+
+}  // namespace chromium
+}  // namespace org
+
+namespace org {
+namespace chromium {
+
+// Interface proxy for org::chromium::Test.
+class TestProxy final : public TestProxyInterface {
+ public:
+  TestProxy(const scoped_refptr<dbus::Bus>& bus) :
+      bus_{bus},
+      dbus_object_proxy_{
+          bus_->GetObjectProxy(service_name_, object_path_)} {
+  }
+
+  TestProxy(const TestProxy&) = delete;
+  TestProxy& operator=(const TestProxy&) = delete;
+
+  ~TestProxy() override {
+  }
+
+  void ReleaseObjectProxy(base::OnceClosure callback) {
+    bus_->RemoveObjectProxy(service_name_, object_path_, std::move(callback));
+  }
+
+  const dbus::ObjectPath& GetObjectPath() const override {
+    return object_path_;
+  }
+
+  dbus::ObjectProxy* GetObjectProxy() const override {
+    return dbus_object_proxy_;
+  }
+
+  // Runs |callback| with the Unix user ID and the process ID of the current
+  // owner of the service name, or with std::nullopt if the bus cannot tell,
+  // e.g. when the service is not running.
+  void GetConnectionCredentials(
+      base::OnceCallback<void(std::optional<chromeos_dbus_bindings::ConnectionCredentials>)> callback) {
+    chromeos_dbus_bindings::GetConnectionCredentials(bus_, service_name_,
+                                                     std::move(callback));
+  }
+
+  bool Ping(
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override {
+    auto response = brillo::dbus_utils::CallMethodAndBlockWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "org.chromium.Test",
+        "Ping",
+        error);
+    return response && brillo::dbus_utils::ExtractMethodCallResults(
+        response.get(), error);
+  }
+
+  void PingAsync(
+      base::OnceCallback<void()> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override {
+    brillo::dbus_utils::CallMethodWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "org.chromium.Test",
+        "Ping",
+        std::move(success_callback),
+        std::move(error_callback));
+  }
+
+ private:
+  scoped_refptr<dbus::Bus> bus_;
+  const std::string service_name_{"org.chromium.TestService"};
+  const dbus::ObjectPath object_path_{"/org/chromium/Test"};
+  dbus::ObjectProxy* dbus_object_proxy_;
+
+};
+
+}  // namespace chromium
+}  // namespace org
+
+#endif  // ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+`
+	if diff := cmp.Diff(out.String(), want); diff != "" {
+		t.Errorf("Generate failed (-got +want):\n%s", diff)
+	}
+}
+
+func TestGenerateProxiesWithProxyFactories(t *testing.T) {
+	introspections := []introspect.Introspection{{
+		Interfaces: []introspect.Interface{{
+			Name: "org.chromium.Test",
+			Methods: []introspect.Method{
+				{
+					Name: "Ping",
+				},
+			},
+		}},
+	}}
+
+	sc := serviceconfig.Config{
+		ServiceName:    "org.chromium.TestService",
+		ProxyFactories: true,
+	}
+	out := new(bytes.Buffer)
+	if err := Generate(introspections, out, "/tmp/proxy.h", sc); err != nil {
+		t.Fatalf("Generate got error, want nil: %v", err)
+	}
+
+	const want = `// Automatic generation of D-Bus interfaces:
+//  - org.chromium.Test
+#ifndef ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+#define ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+#include <memory>
+#include <string>
+#include <vector>
+
+#include <base/functional/bind.h>
+#include <base/functional/callback.h>
+#include <base/logging.h>
+#include <base/memory/ref_counted.h>
+#include <base/no_destructor.h>
+#include <brillo/dbus/dbus_method_invoker.h>
+#include <brillo/dbus/dbus_property.h>
+#include <brillo/dbus/dbus_signal_handler.h>
+#include <brillo/errors/error.h>
+#include <dbus/bus.h>
+#include <dbus/message.h>
+#include <dbus/object_manager.h>
+#include <dbus/object_path.h>
+#include <dbus/object_proxy.h>
+
+namespace org {
+namespace chromium {
+
+// Abstract interface proxy for org::chromium::Test.
+class TestProxyInterface {
+ public:
+  static constexpr char kInterfaceName[] = "org.chromium.Test";
+  static constexpr char kPingMethod[] = "Ping";
+  static constexpr char kPingMethodInSignature[] = "";
+  static constexpr char kPingMethodOutSignature[] = "";
+
+  virtual ~TestProxyInterface() = default;
+
+  using Factory = base::RepeatingCallback<std::unique_ptr<TestProxyInterface>(
+      const scoped_refptr<dbus::Bus>&, const dbus::ObjectPath&)>;
+
+  // Creates the proxy, or runs the factory set by SetFactoryForTesting() if
+  // any, so that the callers do not depend on the concrete proxy class.
+  static std::unique_ptr<TestProxyInterface> Create(
+      const scoped_refptr<dbus::Bus>& bus,
+      const dbus::ObjectPath& object_path);
+
+  // Makes Create() return the result of |factory|, e.g. a mock, until it is
+  // reset with a null callback.
+  static void SetFactoryForTesting(Factory factory) {
+    GetFactoryForTesting() = std::move(factory);
+  }
+
+  virtual bool Ping(
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
+
+  virtual void PingAsync(
+      base::OnceCallback<void()> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
+
+  virtual const dbus::ObjectPath& GetObjectPath() const = 0;
+  virtual dbus::ObjectProxy* GetObjectProxy() const = 0;
+
+ private:
+  static Factory& GetFactoryForTesting() {
+    static base::NoDestructor<Factory> factory;
+    return *factory;
+  }
+};
+
+}  // namespace chromium
+}  // namespace org
+
+namespace org {
+namespace chromium {
+
+// Interface proxy for org::chromium::Test.
+class TestProxy final : public TestProxyInterface {
+ public:
+  TestProxy(
+      const scoped_refptr<dbus::Bus>& bus,
+      const dbus::ObjectPath& object_path) :
+          bus_{bus},
+          object_path_{object_path},
+          dbus_object_proxy_{
+              bus_->GetObjectProxy(service_name_, object_path_)} {
+  }
+
+  TestProxy(const TestProxy&) = delete;
+  TestProxy& operator=(const TestProxy&) = delete;
+
+  ~TestProxy() override {
+  }
+
+  void ReleaseObjectProxy(base::OnceClosure callback) {
+    bus_->RemoveObjectProxy(service_name_, object_path_, std::move(callback));
+  }
+
+  const dbus::ObjectPath& GetObjectPath() const override {
+    return object_path_;
+  }
+
+  dbus::ObjectProxy* GetObjectProxy() const override {
+    return dbus_object_proxy_;
+  }
+
+  bool Ping(
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override {
+    auto response = brillo::dbus_utils::CallMethodAndBlockWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "org.chromium.Test",
+        "Ping",
+        error);
+    return response && brillo::dbus_utils::ExtractMethodCallResults(
+        response.get(), error);
+  }
+
+  void PingAsync(
+      base::OnceCallback<void()> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override {
+    brillo::dbus_utils::CallMethodWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "org.chromium.Test",
+        "Ping",
+        std::move(success_callback),
+        std::move(error_callback));
+  }
+
+ private:
+  scoped_refptr<dbus::Bus> bus_;
+  const std::string service_name_{"org.chromium.TestService"};
+  dbus::ObjectPath object_path_;
+  dbus::ObjectProxy* dbus_object_proxy_;
+
+};
+
+inline std::unique_ptr<TestProxyInterface> TestProxyInterface::Create(
+    const scoped_refptr<dbus::Bus>& bus,
+    const dbus::ObjectPath& object_path) {
+  if (GetFactoryForTesting())
+    return GetFactoryForTesting().Run(bus, object_path);
+  return std::make_unique<TestProxy>(bus, object_path);
+}
+
+}  // namespace chromium
+}  // namespace org
+
+#endif  // ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+`
+	if diff := cmp.Diff(out.String(), want); diff != "" {
+		t.Errorf("Generate failed (-got +want):\n%s", diff)
+	}
+}
+
+func TestGenerateProxiesWithMethodTimeouts(t *testing.T) {
+	introspections := []introspect.Introspection{{
+		Name: "/org/chromium/Test",
+		Interfaces: []introspect.Interface{{
+			Name: "org.chromium.Test",
+			Methods: []introspect.Method{
+				{
+					Name: "Scan",
+					Args: []introspect.MethodArg{
+						{Name: "count", Type: "i", Direction: "out"},
+					},
+				},
+				{
+					Name: "Ping",
+				},
+			},
+		}},
+	}}
+
+	sc := serviceconfig.Config{
+		ServiceName:    "org.chromium.TestService",
+		MethodTimeouts: map[string]int{"org.chromium.Test.Scan": 60000},
+	}
+	out := new(bytes.Buffer)
+	if err := Generate(introspections, out, "/tmp/proxy.h", sc); err != nil {
+		t.Fatalf("Generate got error, want nil: %v", err)
+	}
+
+	const want = `// Automatic generation of D-Bus interfaces:
+//  - org.chromium.Test
+#ifndef ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+#define ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+#include <memory>
+#include <string>
+#include <vector>
+
+#include <base/functional/bind.h>
+#include <base/functional/callback.h>
+#include <base/logging.h>
+#include <base/memory/ref_counted.h>
+#include <brillo/dbus/dbus_method_invoker.h>
+#include <brillo/dbus/dbus_property.h>
+#include <brillo/dbus/dbus_signal_handler.h>
+#include <brillo/errors/error.h>
+#include <dbus/bus.h>
+#include <dbus/message.h>
+#include <dbus/object_manager.h>
+#include <dbus/object_path.h>
+#include <dbus/object_proxy.h>
+
+namespace org {
+namespace chromium {
+
+// Abstract interface proxy for org::chromium::Test.
+class TestProxyInterface {
+ public:
+  static constexpr char kInterfaceName[] = "org.chromium.Test";
+  static constexpr char kScanMethod[] = "Scan";
+  static constexpr char kScanMethodInSignature[] = "";
+  static constexpr char kScanMethodOutSignature[] = "i";
+  static constexpr char kPingMethod[] = "Ping";
+  static constexpr char kPingMethodInSignature[] = "";
+  static constexpr char kPingMethodOutSignature[] = "";
+
+  virtual ~TestProxyInterface() = default;
+
+  virtual bool Scan(
+      int32_t* out_count,
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
+
+  virtual void ScanAsync(
+      base::OnceCallback<void(int32_t /*count*/)> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
+
+  virtual bool Ping(
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
+
+  virtual void PingAsync(
+      base::OnceCallback<void()> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
+
+  virtual const dbus::ObjectPath& GetObjectPath() const = 0;
+  virtual dbus::ObjectProxy* GetObjectProxy() const = 0;
+};
+
+}  // namespace chromium
+}  // namespace org
+
+namespace org {
+namespace chromium {
+
+// Interface proxy for org::chromium::Test.
+class TestProxy final : public TestProxyInterface {
+ public:
+  TestProxy(const scoped_refptr<dbus::Bus>& bus) :
+      bus_{bus},
+      dbus_object_proxy_{
+          bus_->GetObjectProxy(service_name_, object_path_)} {
+  }
+
+  TestProxy(const TestProxy&) = delete;
+  TestProxy& operator=(const TestProxy&) = delete;
+
+  ~TestProxy() override {
+  }
+
+  void ReleaseObjectProxy(base::OnceClosure callback) {
+    bus_->RemoveObjectProxy(service_name_, object_path_, std::move(callback));
+  }
+
+  const dbus::ObjectPath& GetObjectPath() const override {
+    return object_path_;
+  }
+
+  dbus::ObjectProxy* GetObjectProxy() const override {
+    return dbus_object_proxy_;
+  }
+
+  bool Scan(
+      int32_t* out_count,
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override {
+    if (timeout_ms == dbus::ObjectProxy::TIMEOUT_USE_DEFAULT)
+      timeout_ms = 60000;
+    auto response = brillo::dbus_utils::CallMethodAndBlockWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "org.chromium.Test",
+        "Scan",
+        error);
+    return response && brillo::dbus_utils::ExtractMethodCallResults(
+        response.get(), error, out_count);
+  }
+
+  void ScanAsync(
+      base::OnceCallback<void(int32_t /*count*/)> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override {
+    if (timeout_ms == dbus::ObjectProxy::TIMEOUT_USE_DEFAULT)
+      timeout_ms = 60000;
+    brillo::dbus_utils::CallMethodWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "org.chromium.Test",
+        "Scan",
+        std::move(success_callback),
+        std::move(error_callback));
+  }
+
+  bool Ping(
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override {
+    auto response = brillo::dbus_utils::CallMethodAndBlockWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "org.chromium.Test",
+        "Ping",
+        error);
+    return response && brillo::dbus_utils::ExtractMethodCallResults(
+        response.get(), error);
+  }
+
+  void PingAsync(
+      base::OnceCallback<void()> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override {
+    brillo::dbus_utils::CallMethodWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "org.chromium.Test",
+        "Ping",
+        std::move(success_callback),
+        std::move(error_callback));
+  }
+
+ private:
+  scoped_refptr<dbus::Bus> bus_;
+  const std::string service_name_{"org.chromium.TestService"};
+  const dbus::ObjectPath object_path_{"/org/chromium/Test"};
+  dbus::ObjectProxy* dbus_object_proxy_;
+
+};
+
+}  // namespace chromium
+}  // namespace org
+
+#endif  // ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+`
+	if diff := cmp.Diff(out.String(), want); diff != "" {
+		t.Errorf("Generate failed (-got +want):\n%s", diff)
+	}
+}
+
+func TestGenerateProxiesWithRawSignals(t *testing.T) {
+	itf := introspect.Interface{
+		Name: "org.chromium.Test",
+		Signals: []introspect.Signal{
+			{
+				Name: "Changed",
+				Args: []introspect.SignalArg{
+					{Name: "value", Type: "i"},
+				},
+				Annotations: []introspect.Annotation{
+					{Name: "org.chromium.DBus.Signal.Kind", Value: "raw"},
+				},
+			},
+			{
+				Name: "Closed",
+				Args: []introspect.SignalArg{
+					{Name: "reason", Type: "s"},
+				},
+			},
+		},
+	}
+
+	introspections := []introspect.Introspection{{
+		Interfaces: []introspect.Interface{itf},
+	}}
+
+	out := new(bytes.Buffer)
+	if err := Generate(introspections, out, "/tmp/proxy.h", serviceconfig.Config{}); err != nil {
+		t.Fatalf("Generate got error, want nil: %v", err)
+	}
+
+	const want = `// Automatic generation of D-Bus interfaces:
+//  - org.chromium.Test
+#ifndef ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+#define ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+#include <memory>
+#include <string>
+#include <vector>
+
+#include <base/functional/bind.h>
+#include <base/functional/callback.h>
+#include <base/logging.h>
+#include <base/memory/ref_counted.h>
+#include <base/memory/weak_ptr.h>
+#include <brillo/dbus/dbus_method_invoker.h>
+#include <brillo/dbus/dbus_property.h>
+#include <brillo/dbus/dbus_signal_handler.h>
+#include <brillo/errors/error.h>
+#include <dbus/bus.h>
+#include <dbus/message.h>
+#include <dbus/object_manager.h>
+#include <dbus/object_path.h>
+#include <dbus/object_proxy.h>
+
+namespace org {
+namespace chromium {
+
+// Abstract interface proxy for org::chromium::Test.
+class TestProxyInterface {
+ public:
+  static constexpr char kInterfaceName[] = "org.chromium.Test";
+  static constexpr char kChangedSignal[] = "Changed";
+  static constexpr char kChangedSignalSignature[] = "i";
+  static constexpr char kClosedSignal[] = "Closed";
+  static constexpr char kClosedSignalSignature[] = "s";
+
+  using ChangedSignalCallback =
+      base::RepeatingCallback<void(dbus::Signal* /*signal*/)>;
+  using ClosedSignalCallback =
+      base::RepeatingCallback<void(const std::string& /*reason*/)>;
+
+  virtual ~TestProxyInterface() = default;
+
+  virtual void RegisterChangedSignalHandler(
+      const base::RepeatingCallback<void(dbus::Signal*)>& signal_callback,
+      dbus::ObjectProxy::OnConnectedCallback on_connected_callback) = 0;
+
+  // Registers |method| of |target| as the handler of the Changed signal.
+  // The signals delivered after |target| is invalidated are dropped.
+  template <typename T>
+  void RegisterChangedSignalHandlerWeak(
+      base::WeakPtr<T> target,
+      void (T::*method)(dbus::Signal*),
+      dbus::ObjectProxy::OnConnectedCallback on_connected_callback) {
+    RegisterChangedSignalHandler(
+        base::BindRepeating(method, std::move(target)),
+        std::move(on_connected_callback));
+  }
+
+  virtual void RegisterClosedSignalHandler(
+      const base::RepeatingCallback<void(const std::string&)>& signal_callback,
+      dbus::ObjectProxy::OnConnectedCallback on_connected_callback) = 0;
+
+  // Registers |method| of |target| as the handler of the Closed signal.
+  // The signals delivered after |target| is invalidated are dropped.
+  template <typename T>
+  void RegisterClosedSignalHandlerWeak(
+      base::WeakPtr<T> target,
+      void (T::*method)(const std::string&),
+      dbus::ObjectProxy::OnConnectedCallback on_connected_callback) {
+    RegisterClosedSignalHandler(
+        base::BindRepeating(method, std::move(target)),
+        std::move(on_connected_callback));
+  }
+
+  virtual const dbus::ObjectPath& GetObjectPath() const = 0;
+  virtual dbus::ObjectProxy* GetObjectProxy() const = 0;
+};
+
+}  // namespace chromium
+}  // namespace org
+
+namespace org {
+namespace chromium {
+
+// Interface proxy for org::chromium::Test.
+class TestProxy final : public TestProxyInterface {
+ public:
+  TestProxy(
+      const scoped_refptr<dbus::Bus>& bus,
+      const std::string& service_name,
+      const dbus::ObjectPath& object_path) :
+          bus_{bus},
+          service_name_{service_name},
+          object_path_{object_path},
+          dbus_object_proxy_{
+              bus_->GetObjectProxy(service_name_, object_path_)} {
+  }
+
+  TestProxy(const TestProxy&) = delete;
+  TestProxy& operator=(const TestProxy&) = delete;
+
+  ~TestProxy() override {
+  }
+
+  void RegisterChangedSignalHandler(
+      const base::RepeatingCallback<void(dbus::Signal*)>& signal_callback,
+      dbus::ObjectProxy::OnConnectedCallback on_connected_callback) override {
+    dbus_object_proxy_->ConnectToSignal(
+        "org.chromium.Test",
+        "Changed",
+        signal_callback,
+        std::move(on_connected_callback));
+  }
+
+  void RegisterClosedSignalHandler(
+      const base::RepeatingCallback<void(const std::string&)>& signal_callback,
+      dbus::ObjectProxy::OnConnectedCallback on_connected_callback) override {
+    brillo::dbus_utils::ConnectToSignal(
+        dbus_object_proxy_,
+        "org.chromium.Test",
+        "Closed",
+        signal_callback,
+        std::move(on_connected_callback));
+  }
+
+  void ReleaseObjectProxy(base::OnceClosure callback) {
+    bus_->RemoveObjectProxy(service_name_, object_path_, std::move(callback));
+  }
+
+  const dbus::ObjectPath& GetObjectPath() const override {
+    return object_path_;
+  }
+
+  dbus::ObjectProxy* GetObjectProxy() const override {
+    return dbus_object_proxy_;
+  }
+
+ private:
+  scoped_refptr<dbus::Bus> bus_;
+  std::string service_name_;
+  dbus::ObjectPath object_path_;
+  dbus::ObjectProxy* dbus_object_proxy_;
+
+};
+
+}  // namespace chromium
+}  // namespace org
+
+#endif  // ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+`
+	if diff := cmp.Diff(out.String(), want); diff != "" {
+		t.Errorf("Generate failed (-got +want):\n%s", diff)
+	}
+}
+
+func TestGenerateProxiesWithVariantTypes(t *testing.T) {
+	itf := introspect.Interface{
+		Name: "org.chromium.Test",
+		Methods: []introspect.Method{
+			{
+				Name: "Convert",
+				Args: []introspect.MethodArg{
+					{
+						Name: "value", Type: "v", Direction: "in",
+						Annotations: []introspect.Annotation{{Name: "org.chromium.DBus.Argument.VariantTypes", Value: "s i"}},
+					},
+					{
+						Name: "result", Type: "v", Direction: "out",
+						Annotations: []introspect.Annotation{{Name: "org.chromium.DBus.Argument.VariantTypes", Value: "as b"}},
+					},
+				},
+			},
+			{
+				Name: "Store",
+				Args: []introspect.MethodArg{
+					{
+						Name: "value", Type: "v", Direction: "in",
+						Annotations: []introspect.Annotation{{Name: "org.chromium.DBus.Argument.VariantTypes", Value: "s ..."}},
+					},
+				},
+			},
+		},
+	}
+
+	introspections := []introspect.Introspection{{
+		Interfaces: []introspect.Interface{itf},
+	}}
+
+	out := new(bytes.Buffer)
+	if err := Generate(introspections, out, "/tmp/proxy.h", serviceconfig.Config{
+		ValidateVariantTypes: true,
+	}); err != nil {
+		t.Fatalf("Generate got error, want nil: %v", err)
+	}
+
+	const want = `// Automatic generation of D-Bus interfaces:
+//  - org.chromium.Test
+#ifndef ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+#define ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+#include <memory>
+#include <string>
+#include <variant>
+#include <vector>
+
+#include <base/functional/bind.h>
+#include <base/functional/callback.h>
+#include <base/location.h>
+#include <base/logging.h>
+#include <base/memory/ref_counted.h>
+#include <brillo/any.h>
+#include <brillo/dbus/dbus_method_invoker.h>
+#include <brillo/dbus/dbus_property.h>
+#include <brillo/dbus/dbus_signal_handler.h>
+#include <brillo/errors/error.h>
+#include <brillo/errors/error_codes.h>
+#include <dbus/bus.h>
+#include <dbus/message.h>
+#include <dbus/object_manager.h>
+#include <dbus/object_path.h>
+#include <dbus/object_proxy.h>
+
+#ifndef CHROMEOS_DBUS_BINDINGS_DBUS_VARIANT_TYPES_
+#define CHROMEOS_DBUS_BINDINGS_DBUS_VARIANT_TYPES_
+namespace chromeos_dbus_bindings {
+
+// Returns true if |value| holds one of Ts.
+template <typename... Ts>
+bool AnyHoldsOneOf(const brillo::Any& value) {
+  return (value.IsTypeCompatible<Ts>() || ...);
+}
+
+// Returns the brillo::Any holding the alternative held by |value|.
+template <typename... Ts>
+brillo::Any VariantToAny(const std::variant<Ts...>& value) {
+  return std::visit([](const auto& v) { return brillo::Any(v); }, value);
+}
+
+// Stores the value held by |value| into |out|. Fails with |error| if |value|
+// holds none of Ts.
+template <typename... Ts>
+bool AnyToVariant(const brillo::Any& value,
+                  std::variant<Ts...>* out,
+                  brillo::ErrorPtr* error) {
+  if (((value.IsTypeCompatible<Ts>() && (*out = value.Get<Ts>(), true)) ||
+       ...)) {
+    return true;
+  }
+  brillo::Error::AddToPrintf(error, FROM_HERE, brillo::errors::dbus::kDomain,
+                             "org.freedesktop.DBus.Error.InvalidArgs",
+                             "Unexpected variant type %s",
+                             value.GetUndecoratedTypeName().c_str());
+  return false;
+}
+
+}  // namespace chromeos_dbus_bindings
+#endif  // CHROMEOS_DBUS_BINDINGS_DBUS_VARIANT_TYPES_
+
+namespace org {
+namespace chromium {
+
+// Abstract interface proxy for org::chromium::Test.
+class TestProxyInterface {
+ public:
+  static constexpr char kInterfaceName[] = "org.chromium.Test";
+  static constexpr char kConvertMethod[] = "Convert";
+  static constexpr char kConvertMethodInSignature[] = "v";
+  static constexpr char kConvertMethodOutSignature[] = "v";
+  static constexpr char kStoreMethod[] = "Store";
+  static constexpr char kStoreMethodInSignature[] = "v";
+  static constexpr char kStoreMethodOutSignature[] = "";
+
+  virtual ~TestProxyInterface() = default;
+
+  // in_value: variant of string, int32
+  // out_result: variant of array of string, boolean
+  virtual bool Convert(
+      const brillo::Any& in_value,
+      brillo::Any* out_result,
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
+
+  // in_value: variant of string, int32
+  // result: variant of array of string, boolean
+  virtual void ConvertAsync(
+      const brillo::Any& in_value,
+      base::OnceCallback<void(const brillo::Any& /*result*/)> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
+
+  // Calls Convert() with the variant arguments held in std::variant.
+  // Fails if an output variant argument holds none of the annotated types.
+  bool Convert(
+      const std::variant<std::string, int32_t>& in_value,
+      std::variant<std::vector<std::string>, bool>* out_result,
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) {
+    brillo::Any out_result_any;
+    if (!Convert(chromeos_dbus_bindings::VariantToAny(in_value), &out_result_any, error, timeout_ms))
+      return false;
+    return chromeos_dbus_bindings::AnyToVariant(out_result_any, out_result, error);
+  }
+
+  // in_value: variant of string, ...
+  virtual bool Store(
+      const brillo::Any& in_value,
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
+
+  // in_value: variant of string, ...
+  virtual void StoreAsync(
+      const brillo::Any& in_value,
+      base::OnceCallback<void()> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
+
+  virtual const dbus::ObjectPath& GetObjectPath() const = 0;
+  virtual dbus::ObjectProxy* GetObjectProxy() const = 0;
+};
+
+}  // namespace chromium
+}  // namespace org
+
+namespace org {
+namespace chromium {
+
+// Interface proxy for org::chromium::Test.
+class TestProxy final : public TestProxyInterface {
+ public:
+  TestProxy(
+      const scoped_refptr<dbus::Bus>& bus,
+      const std::string& service_name,
+      const dbus::ObjectPath& object_path) :
+          bus_{bus},
+          service_name_{service_name},
+          object_path_{object_path},
+          dbus_object_proxy_{
+              bus_->GetObjectProxy(service_name_, object_path_)} {
+  }
+
+  TestProxy(const TestProxy&) = delete;
+  TestProxy& operator=(const TestProxy&) = delete;
+
+  ~TestProxy() override {
+  }
+
+  void ReleaseObjectProxy(base::OnceClosure callback) {
+    bus_->RemoveObjectProxy(service_name_, object_path_, std::move(callback));
+  }
+
+  const dbus::ObjectPath& GetObjectPath() const override {
+    return object_path_;
+  }
+
+  dbus::ObjectProxy* GetObjectProxy() const override {
+    return dbus_object_proxy_;
+  }
+
+  bool Convert(
+      const brillo::Any& in_value,
+      brillo::Any* out_result,
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override {
+    if (!chromeos_dbus_bindings::AnyHoldsOneOf<std::string, int32_t>(in_value)) {
+      brillo::Error::AddTo(error, FROM_HERE, brillo::errors::dbus::kDomain,
+                           "org.freedesktop.DBus.Error.InvalidArgs",
+                           "Unexpected variant type of in_value");
+      return false;
+    }
+    auto response = brillo::dbus_utils::CallMethodAndBlockWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "org.chromium.Test",
+        "Convert",
+        error,
+        in_value);
+    return response && brillo::dbus_utils::ExtractMethodCallResults(
+        response.get(), error, out_result);
+  }
+
+  void ConvertAsync(
+      const brillo::Any& in_value,
+      base::OnceCallback<void(const brillo::Any& /*result*/)> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override {
+    if (!chromeos_dbus_bindings::AnyHoldsOneOf<std::string, int32_t>(in_value)) {
+      auto error = brillo::Error::Create(
+          FROM_HERE, brillo::errors::dbus::kDomain,
+          "org.freedesktop.DBus.Error.InvalidArgs",
+          "Unexpected variant type of in_value");
+      std::move(error_callback).Run(error.get());
+      return;
+    }
+    brillo::dbus_utils::CallMethodWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "org.chromium.Test",
+        "Convert",
+        std::move(success_callback),
+        std::move(error_callback),
+        in_value);
+  }
+
+  bool Store(
+      const brillo::Any& in_value,
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override {
+    auto response = brillo::dbus_utils::CallMethodAndBlockWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "org.chromium.Test",
+        "Store",
+        error,
+        in_value);
+    return response && brillo::dbus_utils::ExtractMethodCallResults(
+        response.get(), error);
+  }
+
+  void StoreAsync(
+      const brillo::Any& in_value,
+      base::OnceCallback<void()> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override {
+    brillo::dbus_utils::CallMethodWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "org.chromium.Test",
+        "Store",
+        std::move(success_callback),
+        std::move(error_callback),
+        in_value);
+  }
+
+  // Unhide the overloads defined by the interface.
+  using TestProxyInterface::Convert;
+
+ private:
+  scoped_refptr<dbus::Bus> bus_;
+  std::string service_name_;
+  dbus::ObjectPath object_path_;
+  dbus::ObjectProxy* dbus_object_proxy_;
+
+};
+
+}  // namespace chromium
+}  // namespace org
+
+#endif  // ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+`
+	if diff := cmp.Diff(out.String(), want); diff != "" {
+		t.Errorf("Generate failed (-got +want):\n%s", diff)
+	}
+}
+
+func TestGenerateProxiesWithExpectedResults(t *testing.T) {
+	itf := introspect.Interface{
+		Name: "org.chromium.Test",
+		Methods: []introspect.Method{
+			{
+				Name: "GetName",
+				Args: []introspect.MethodArg{
+					{Name: "id", Type: "i", Direction: "in"},
+					{Name: "name", Type: "s", Direction: "out"},
+				},
+			},
+			{
+				Name: "GetSize",
+				Args: []introspect.MethodArg{
+					{Name: "width", Type: "u", Direction: "out"},
+					{Name: "height", Type: "u", Direction: "out"},
+				},
+			},
+		},
+	}
+
+	introspections := []introspect.Introspection{{
+		Interfaces: []introspect.Interface{itf},
+	}}
+
+	out := new(bytes.Buffer)
+	if err := Generate(introspections, out, "/tmp/proxy.h", serviceconfig.Config{
+		ExpectedResults: true,
+	}); err != nil {
+		t.Fatalf("Generate got error, want nil: %v", err)
+	}
+
+	const want = `// Automatic generation of D-Bus interfaces:
+//  - org.chromium.Test
+#ifndef ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+#define ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+#include <memory>
+#include <string>
+#include <vector>
+
+#include <base/functional/bind.h>
+#include <base/functional/callback.h>
+#include <base/logging.h>
+#include <base/memory/ref_counted.h>
+#include <base/types/expected.h>
+#include <brillo/dbus/dbus_method_invoker.h>
+#include <brillo/dbus/dbus_property.h>
+#include <brillo/dbus/dbus_signal_handler.h>
+#include <brillo/errors/error.h>
+#include <dbus/bus.h>
+#include <dbus/message.h>
+#include <dbus/object_manager.h>
+#include <dbus/object_path.h>
+#include <dbus/object_proxy.h>
+
+namespace org {
+namespace chromium {
+
+// Abstract interface proxy for org::chromium::Test.
+class TestProxyInterface {
+ public:
+  static constexpr char kInterfaceName[] = "org.chromium.Test";
+  static constexpr char kGetNameMethod[] = "GetName";
+  static constexpr char kGetNameMethodInSignature[] = "i";
+  static constexpr char kGetNameMethodOutSignature[] = "s";
+  static constexpr char kGetSizeMethod[] = "GetSize";
+  static constexpr char kGetSizeMethodInSignature[] = "";
+  static constexpr char kGetSizeMethodOutSignature[] = "uu";
+
+  virtual ~TestProxyInterface() = default;
+
+  virtual bool GetName(
+      int32_t in_id,
+      std::string* out_name,
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
+
+  virtual void GetNameAsync(
+      int32_t in_id,
+      base::OnceCallback<void(const std::string& /*name*/)> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
+
+  // Calls GetName() and returns its output argument or the error.
+  base::expected<std::string, brillo::ErrorPtr> GetName(
+      int32_t in_id,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) {
+    std::string result;
+    brillo::ErrorPtr error;
+    if (!GetName(in_id, &result, &error, timeout_ms))
+      return base::unexpected(std::move(error));
+    return result;
+  }
+
+  virtual bool GetSize(
+      uint32_t* out_width,
+      uint32_t* out_height,
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
+
+  virtual void GetSizeAsync(
+      base::OnceCallback<void(uint32_t /*width*/, uint32_t /*height*/)> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
+
+  virtual const dbus::ObjectPath& GetObjectPath() const = 0;
+  virtual dbus::ObjectProxy* GetObjectProxy() const = 0;
+};
+
+}  // namespace chromium
+}  // namespace org
+
+namespace org {
+namespace chromium {
+
+// Interface proxy for org::chromium::Test.
+class TestProxy final : public TestProxyInterface {
+ public:
+  TestProxy(
+      const scoped_refptr<dbus::Bus>& bus,
+      const std::string& service_name,
+      const dbus::ObjectPath& object_path) :
+          bus_{bus},
+          service_name_{service_name},
+          object_path_{object_path},
+          dbus_object_proxy_{
+              bus_->GetObjectProxy(service_name_, object_path_)} {
+  }
+
+  TestProxy(const TestProxy&) = delete;
+  TestProxy& operator=(const TestProxy&) = delete;
+
+  ~TestProxy() override {
+  }
+
+  void ReleaseObjectProxy(base::OnceClosure callback) {
+    bus_->RemoveObjectProxy(service_name_, object_path_, std::move(callback));
+  }
+
+  const dbus::ObjectPath& GetObjectPath() const override {
+    return object_path_;
+  }
+
+  dbus::ObjectProxy* GetObjectProxy() const override {
+    return dbus_object_proxy_;
+  }
+
+  bool GetName(
+      int32_t in_id,
+      std::string* out_name,
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override {
+    auto response = brillo::dbus_utils::CallMethodAndBlockWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "org.chromium.Test",
+        "GetName",
+        error,
+        in_id);
+    return response && brillo::dbus_utils::ExtractMethodCallResults(
+        response.get(), error, out_name);
+  }
+
+  void GetNameAsync(
+      int32_t in_id,
+      base::OnceCallback<void(const std::string& /*name*/)> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override {
+    brillo::dbus_utils::CallMethodWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "org.chromium.Test",
+        "GetName",
+        std::move(success_callback),
+        std::move(error_callback),
+        in_id);
+  }
+
+  bool GetSize(
+      uint32_t* out_width,
+      uint32_t* out_height,
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override {
+    auto response = brillo::dbus_utils::CallMethodAndBlockWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "org.chromium.Test",
+        "GetSize",
+        error);
+    return response && brillo::dbus_utils::ExtractMethodCallResults(
+        response.get(), error, out_width, out_height);
+  }
+
+  void GetSizeAsync(
+      base::OnceCallback<void(uint32_t /*width*/, uint32_t /*height*/)> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override {
+    brillo::dbus_utils::CallMethodWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "org.chromium.Test",
+        "GetSize",
+        std::move(success_callback),
+        std::move(error_callback));
+  }
+
+  // Unhide the overloads defined by the interface.
+  using TestProxyInterface::GetName;
+
+ private:
+  scoped_refptr<dbus::Bus> bus_;
+  std::string service_name_;
+  dbus::ObjectPath object_path_;
+  dbus::ObjectProxy* dbus_object_proxy_;
+
+};
+
+}  // namespace chromium
+}  // namespace org
+
+#endif  // ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+`
+	if diff := cmp.Diff(out.String(), want); diff != "" {
+		t.Errorf("Generate failed (-got +want):\n%s", diff)
+	}
+}
+
+func TestGenerateProxiesWithObjectManagerCollidingNames(t *testing.T) {
+	introspections := []introspect.Introspection{{
+		Interfaces: []introspect.Interface{
+			{Name: "test.foo.Device"},
+			{Name: "test.bar.Device"},
+		},
+	}}
+
+	sc := serviceconfig.Config{
+		ServiceName: "test.Service",
+		ObjectManager: &serviceconfig.ObjectManagerConfig{
+			Name: "test.ObjectManager",
+		},
+	}
+	out := new(bytes.Buffer)
+	if err := Generate(introspections, out, "/tmp/proxy.h", sc); err != nil {
+		t.Fatalf("Generate got error, want nil: %v", err)
+	}
+
+	const want = `// Automatic generation of D-Bus interfaces:
+//  - test.foo.Device
+//  - test.bar.Device
+#ifndef ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+#define ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+#include <memory>
+#include <optional>
+#include <string>
+#include <vector>
+
+#include <base/functional/bind.h>
+#include <base/functional/callback.h>
+#include <base/location.h>
+#include <base/logging.h>
+#include <base/memory/ref_counted.h>
+#include <base/task/sequenced_task_runner.h>
+#include <base/time/time.h>
+#include <brillo/dbus/dbus_method_invoker.h>
+#include <brillo/dbus/dbus_property.h>
+#include <brillo/dbus/dbus_signal_handler.h>
+#include <brillo/errors/error.h>
+#include <brillo/variant_dictionary.h>
+#include <dbus/bus.h>
+#include <dbus/message.h>
+#include <dbus/object_manager.h>
+#include <dbus/object_path.h>
+#include <dbus/object_proxy.h>
+
+namespace test {
+class ObjectManagerProxy;
+}  // namespace test
+
+namespace test {
+namespace foo {
+
+// Abstract interface proxy for test::foo::Device.
+class DeviceProxyInterface {
+ public:
+  static constexpr char kInterfaceName[] = "test.foo.Device";
+
+  virtual ~DeviceProxyInterface() = default;
+
+  virtual const dbus::ObjectPath& GetObjectPath() const = 0;
+  virtual dbus::ObjectProxy* GetObjectProxy() const = 0;
+};
+
+}  // namespace foo
+}  // namespace test
+
+namespace test {
+namespace foo {
+
+// Interface proxy for test::foo::Device.
+class DeviceProxy final : public DeviceProxyInterface {
+ public:
+  class PropertySet : public dbus::PropertySet {
+   public:
+    PropertySet(dbus::ObjectProxy* object_proxy,
+                const PropertyChangedCallback& callback)
+        : dbus::PropertySet{object_proxy,
+                            "test.foo.Device",
+                            callback} {
+    }
+    PropertySet(const PropertySet&) = delete;
+    PropertySet& operator=(const PropertySet&) = delete;
+
+
+  };
+
+  DeviceProxy(
+      const scoped_refptr<dbus::Bus>& bus,
+      const dbus::ObjectPath& object_path) :
+          bus_{bus},
+          object_path_{object_path},
+          dbus_object_proxy_{
+              bus_->GetObjectProxy(service_name_, object_path_)} {
+  }
+
+  DeviceProxy(const DeviceProxy&) = delete;
+  DeviceProxy& operator=(const DeviceProxy&) = delete;
+
+  ~DeviceProxy() override {
+  }
+
+  void ReleaseObjectProxy(base::OnceClosure callback) {
+    bus_->RemoveObjectProxy(service_name_, object_path_, std::move(callback));
+  }
+
+  const dbus::ObjectPath& GetObjectPath() const override {
+    return object_path_;
+  }
+
+  dbus::ObjectProxy* GetObjectProxy() const override {
+    return dbus_object_proxy_;
+  }
+
+ private:
+  scoped_refptr<dbus::Bus> bus_;
+  const std::string service_name_{"test.Service"};
+  dbus::ObjectPath object_path_;
+  dbus::ObjectProxy* dbus_object_proxy_;
+
+};
+
+}  // namespace foo
+}  // namespace test
+
+namespace test {
+namespace bar {
+
+// Abstract interface proxy for test::bar::Device.
+class DeviceProxyInterface {
+ public:
+  static constexpr char kInterfaceName[] = "test.bar.Device";
+
+  virtual ~DeviceProxyInterface() = default;
+
+  virtual const dbus::ObjectPath& GetObjectPath() const = 0;
+  virtual dbus::ObjectProxy* GetObjectProxy() const = 0;
+};
+
+}  // namespace bar
+}  // namespace test
+
+namespace test {
+namespace bar {
+
+// Interface proxy for test::bar::Device.
+class DeviceProxy final : public DeviceProxyInterface {
+ public:
+  class PropertySet : public dbus::PropertySet {
+   public:
+    PropertySet(dbus::ObjectProxy* object_proxy,
+                const PropertyChangedCallback& callback)
+        : dbus::PropertySet{object_proxy,
+                            "test.bar.Device",
+                            callback} {
+    }
+    PropertySet(const PropertySet&) = delete;
+    PropertySet& operator=(const PropertySet&) = delete;
+
+
+  };
+
+  DeviceProxy(
+      const scoped_refptr<dbus::Bus>& bus,
+      const dbus::ObjectPath& object_path) :
+          bus_{bus},
+          object_path_{object_path},
+          dbus_object_proxy_{
+              bus_->GetObjectProxy(service_name_, object_path_)} {
+  }
+
+  DeviceProxy(const DeviceProxy&) = delete;
+  DeviceProxy& operator=(const DeviceProxy&) = delete;
+
+  ~DeviceProxy() override {
+  }
+
+  void ReleaseObjectProxy(base::OnceClosure callback) {
+    bus_->RemoveObjectProxy(service_name_, object_path_, std::move(callback));
+  }
+
+  const dbus::ObjectPath& GetObjectPath() const override {
+    return object_path_;
+  }
+
+  dbus::ObjectProxy* GetObjectProxy() const override {
+    return dbus_object_proxy_;
+  }
+
+ private:
+  scoped_refptr<dbus::Bus> bus_;
+  const std::string service_name_{"test.Service"};
+  dbus::ObjectPath object_path_;
+  dbus::ObjectProxy* dbus_object_proxy_;
+
+};
+
+}  // namespace bar
+}  // namespace test
+
+namespace test {
+
+class ObjectManagerProxy : public dbus::ObjectManager::Interface {
+ public:
+  ObjectManagerProxy(const scoped_refptr<dbus::Bus>& bus)
+      : bus_{bus},
+        dbus_object_manager_{bus->GetObjectManager(
+            "test.Service",
+            dbus::ObjectPath{""})} {
+    dbus_object_manager_->RegisterInterface("test.foo.Device", this);
+    dbus_object_manager_->RegisterInterface("test.bar.Device", this);
+  }
+
+  ObjectManagerProxy(const ObjectManagerProxy&) = delete;
+  ObjectManagerProxy& operator=(const ObjectManagerProxy&) = delete;
+
+  ~ObjectManagerProxy() override {
+    dbus_object_manager_->UnregisterInterface("test.foo.Device");
+    dbus_object_manager_->UnregisterInterface("test.bar.Device");
+  }
+
+  dbus::ObjectManager* GetObjectManagerProxy() const {
+    return dbus_object_manager_;
+  }
+
+  // The readable properties of test.foo.Device, as returned by
+  // GetManagedObjects().
+  struct TestFooDeviceProperties {
+  };
+
+  // The readable properties of test.bar.Device, as returned by
+  // GetManagedObjects().
+  struct TestBarDeviceProperties {
+  };
+
+  // The known interfaces implemented by an object, as returned by
+  // GetManagedObjects(). An interface is set if the object implements it.
+  struct ManagedObject {
+    std::optional<TestFooDeviceProperties> test_foo_device;
+    std::optional<TestBarDeviceProperties> test_bar_device;
+  };
+
+  // Calls org.freedesktop.DBus.ObjectManager.GetManagedObjects and converts
+  // the result into |objects| keyed by the object paths. The interfaces
+  // unknown to this proxy are ignored, and the missing properties are left
+  // default-initialized.
+  bool GetManagedObjects(
+      std::map<dbus::ObjectPath, ManagedObject>* objects,
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) {
+    std::map<dbus::ObjectPath,
+             std::map<std::string, brillo::VariantDictionary>> managed_objects;
+    auto response = brillo::dbus_utils::CallMethodAndBlockWithTimeout(
+        timeout_ms,
+        bus_->GetObjectProxy("test.Service",
+                             dbus::ObjectPath{""}),
+        "org.freedesktop.DBus.ObjectManager",
+        "GetManagedObjects",
+        error);
+    if (!response || !brillo::dbus_utils::ExtractMethodCallResults(
+            response.get(), error, &managed_objects)) {
+      return false;
+    }
+    objects->clear();
+    for (const auto& object : managed_objects) {
+      ManagedObject& managed_object = (*objects)[object.first];
+      for (const auto& itf : object.second) {
+        if (itf.first == "test.foo.Device") {
+          managed_object.test_foo_device.emplace();
+          continue;
+        }
+        if (itf.first == "test.bar.Device") {
+          managed_object.test_bar_device.emplace();
+          continue;
+        }
+      }
+    }
+    return true;
+  }
+
+  test::foo::DeviceProxyInterface* GetTestFooDeviceProxy(
+      const dbus::ObjectPath& object_path) {
+    auto p = test_foo_device_instances_.find(object_path);
+    if (p != test_foo_device_instances_.end())
+      return p->second.get();
+    return nullptr;
+  }
+  std::vector<test::foo::DeviceProxyInterface*> GetTestFooDeviceInstances() const {
+    std::vector<test::foo::DeviceProxyInterface*> values;
+    values.reserve(test_foo_device_instances_.size());
+    for (const auto& pair : test_foo_device_instances_)
+      values.push_back(pair.second.get());
+    return values;
+  }
+  void SetTestFooDeviceAddedCallback(
+      const base::RepeatingCallback<void(test::foo::DeviceProxyInterface*)>& callback) {
+    on_test_foo_device_added_ = callback;
+  }
+  void SetTestFooDeviceRemovedCallback(
+      const base::RepeatingCallback<void(const dbus::ObjectPath&)>& callback) {
+    on_test_foo_device_removed_ = callback;
+  }
+  // Runs |callback| with the proxy once the object at |object_path| is
+  // added, or with nullptr if it is not added within |timeout|. |callback|
+  // is run immediately if the object already exists.
+  void WaitForTestFooDeviceProxy(
+      const dbus::ObjectPath& object_path,
+      base::TimeDelta timeout,
+      base::OnceCallback<void(test::foo::DeviceProxyInterface*)> callback) {
+    auto* proxy = GetTestFooDeviceProxy(object_path);
+    if (proxy) {
+      std::move(callback).Run(proxy);
+      return;
+    }
+    int id = next_waiter_id_++;
+    test_foo_device_waiters_.push_back(
+        {id, object_path, std::move(callback)});
+    base::SequencedTaskRunner::GetCurrentDefault()->PostDelayedTask(
+        FROM_HERE,
+        base::BindOnce(&ObjectManagerProxy::OnTestFooDeviceWaitTimeout,
+                       weak_ptr_factory_.GetWeakPtr(), id),
+        timeout);
+  }
+
+  test::bar::DeviceProxyInterface* GetTestBarDeviceProxy(
+      const dbus::ObjectPath& object_path) {
+    auto p = test_bar_device_instances_.find(object_path);
+    if (p != test_bar_device_instances_.end())
+      return p->second.get();
+    return nullptr;
+  }
+  std::vector<test::bar::DeviceProxyInterface*> GetTestBarDeviceInstances() const {
+    std::vector<test::bar::DeviceProxyInterface*> values;
+    values.reserve(test_bar_device_instances_.size());
+    for (const auto& pair : test_bar_device_instances_)
+      values.push_back(pair.second.get());
+    return values;
+  }
+  void SetTestBarDeviceAddedCallback(
+      const base::RepeatingCallback<void(test::bar::DeviceProxyInterface*)>& callback) {
+    on_test_bar_device_added_ = callback;
+  }
+  void SetTestBarDeviceRemovedCallback(
+      const base::RepeatingCallback<void(const dbus::ObjectPath&)>& callback) {
+    on_test_bar_device_removed_ = callback;
+  }
+  // Runs |callback| with the proxy once the object at |object_path| is
+  // added, or with nullptr if it is not added within |timeout|. |callback|
+  // is run immediately if the object already exists.
+  void WaitForTestBarDeviceProxy(
+      const dbus::ObjectPath& object_path,
+      base::TimeDelta timeout,
+      base::OnceCallback<void(test::bar::DeviceProxyInterface*)> callback) {
+    auto* proxy = GetTestBarDeviceProxy(object_path);
+    if (proxy) {
+      std::move(callback).Run(proxy);
+      return;
+    }
+    int id = next_waiter_id_++;
+    test_bar_device_waiters_.push_back(
+        {id, object_path, std::move(callback)});
+    base::SequencedTaskRunner::GetCurrentDefault()->PostDelayedTask(
+        FROM_HERE,
+        base::BindOnce(&ObjectManagerProxy::OnTestBarDeviceWaitTimeout,
+                       weak_ptr_factory_.GetWeakPtr(), id),
+        timeout);
+  }
+
+ private:
+  // A pending WaitFor...Proxy() call.
+  template <typename T>
+  struct Waiter {
+    int id;
+    dbus::ObjectPath object_path;
+    base::OnceCallback<void(T*)> callback;
+  };
+
+  // Removes the waiter |id| from |waiters| and runs its callback with
+  // nullptr, unless it has been run already.
+  template <typename T>
+  static void TimeOutWaiter(std::vector<Waiter<T>>* waiters, int id) {
+    for (auto it = waiters->begin(); it != waiters->end(); ++it) {
+      if (it->id == id) {
+        auto callback = std::move(it->callback);
+        waiters->erase(it);
+        std::move(callback).Run(nullptr);
+        return;
+      }
+    }
+  }
+
+  // Runs the callbacks of the waiters in |waiters| for the object at
+  // |object_path|, or of all of them if |any_path| is true, with |proxy|.
+  template <typename T>
+  static void NotifyWaiters(std::vector<Waiter<T>>* waiters,
+                            const dbus::ObjectPath& object_path,
+                            bool any_path,
+                            T* proxy) {
+    std::vector<Waiter<T>> pending;
+    pending.swap(*waiters);
+    for (auto& waiter : pending) {
+      if (any_path || waiter.object_path == object_path)
+        std::move(waiter.callback).Run(proxy);
+      else
+        waiters->push_back(std::move(waiter));
+    }
+  }
+
+  void OnTestFooDeviceWaitTimeout(int id) {
+    TimeOutWaiter(&test_foo_device_waiters_, id);
+  }
+
+  void OnTestBarDeviceWaitTimeout(int id) {
+    TimeOutWaiter(&test_bar_device_waiters_, id);
+  }
+
+  void OnPropertyChanged(const dbus::ObjectPath& /* object_path */,
+                         const std::string& /* interface_name */,
+                         const std::string& /* property_name */) {}
+
+  void ObjectAdded(
+      const dbus::ObjectPath& object_path,
+      const std::string& interface_name) override {
+    if (interface_name == "test.foo.Device") {
+      std::unique_ptr<test::foo::DeviceProxy> test_foo_device_proxy{
+        new test::foo::DeviceProxy{bus_, object_path}
+      };
+      auto p = test_foo_device_instances_.emplace(object_path, std::move(test_foo_device_proxy));
+      if (!on_test_foo_device_added_.is_null())
+        on_test_foo_device_added_.Run(p.first->second.get());
+      NotifyWaiters<test::foo::DeviceProxyInterface>(
+          &test_foo_device_waiters_, object_path, false,
+          p.first->second.get());
+      return;
+    }
+    if (interface_name == "test.bar.Device") {
+      std::unique_ptr<test::bar::DeviceProxy> test_bar_device_proxy{
+        new test::bar::DeviceProxy{bus_, object_path}
+      };
+      auto p = test_bar_device_instances_.emplace(object_path, std::move(test_bar_device_proxy));
+      if (!on_test_bar_device_added_.is_null())
+        on_test_bar_device_added_.Run(p.first->second.get());
+      NotifyWaiters<test::bar::DeviceProxyInterface>(
+          &test_bar_device_waiters_, object_path, false,
+          p.first->second.get());
+      return;
+    }
+  }
+
+  void ObjectRemoved(
+      const dbus::ObjectPath& object_path,
+      const std::string& interface_name) override {
+    if (interface_name == "test.foo.Device") {
+      auto p = test_foo_device_instances_.find(object_path);
+      if (p != test_foo_device_instances_.end()) {
+        if (!on_test_foo_device_removed_.is_null())
+          on_test_foo_device_removed_.Run(object_path);
+        test_foo_device_instances_.erase(p);
+      }
+      return;
+    }
+    if (interface_name == "test.bar.Device") {
+      auto p = test_bar_device_instances_.find(object_path);
+      if (p != test_bar_device_instances_.end()) {
+        if (!on_test_bar_device_removed_.is_null())
+          on_test_bar_device_removed_.Run(object_path);
+        test_bar_device_instances_.erase(p);
+      }
+      return;
+    }
+  }
+
+  dbus::PropertySet* CreateProperties(
+      dbus::ObjectProxy* object_proxy,
+      const dbus::ObjectPath& object_path,
+      const std::string& interface_name) override {
+    if (interface_name == "test.foo.Device") {
+      return new test::foo::DeviceProxy::PropertySet{
+          object_proxy,
+          base::BindRepeating(&ObjectManagerProxy::OnPropertyChanged,
+                              weak_ptr_factory_.GetWeakPtr(),
+                              object_path,
+                              interface_name)
+      };
+    }
+    if (interface_name == "test.bar.Device") {
+      return new test::bar::DeviceProxy::PropertySet{
+          object_proxy,
+          base::BindRepeating(&ObjectManagerProxy::OnPropertyChanged,
+                              weak_ptr_factory_.GetWeakPtr(),
+                              object_path,
+                              interface_name)
+      };
+    }
+    LOG(FATAL) << "Creating properties for unsupported interface "
+               << interface_name;
+    return nullptr;
+  }
+
+  scoped_refptr<dbus::Bus> bus_;
+  dbus::ObjectManager* dbus_object_manager_;
+  std::map<dbus::ObjectPath,
+           std::unique_ptr<test::foo::DeviceProxy>> test_foo_device_instances_;
+  base::RepeatingCallback<void(test::foo::DeviceProxyInterface*)> on_test_foo_device_added_;
+  base::RepeatingCallback<void(const dbus::ObjectPath&)> on_test_foo_device_removed_;
+  std::vector<Waiter<test::foo::DeviceProxyInterface>> test_foo_device_waiters_;
+  std::map<dbus::ObjectPath,
+           std::unique_ptr<test::bar::DeviceProxy>> test_bar_device_instances_;
+  base::RepeatingCallback<void(test::bar::DeviceProxyInterface*)> on_test_bar_device_added_;
+  base::RepeatingCallback<void(const dbus::ObjectPath&)> on_test_bar_device_removed_;
+  std::vector<Waiter<test::bar::DeviceProxyInterface>> test_bar_device_waiters_;
+  int next_waiter_id_ = 0;
+  base::WeakPtrFactory<ObjectManagerProxy> weak_ptr_factory_{this};
+};
+
+}  // namespace test
+
+#endif  // ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+`
+
+	if diff := cmp.Diff(out.String(), want); diff != "" {
+		t.Errorf("Generate failed (-got +want):\n%s", diff)
+	}
+}
+
+func TestGenerateProxiesWithPropertyCachePolicy(t *testing.T) {
+	introspections := []introspect.Introspection{{
+		Name: "/test/Object",
+		Interfaces: []introspect.Interface{{
+			Name: "test.Itf",
+			Properties: []introspect.Property{
+				{Name: "Cached", Type: "i", Access: "read"},
+				{
+					Name: "FetchedOnce", Type: "s", Access: "read",
+					Annotations: []introspect.Annotation{{Name: "org.chromium.DBus.Property.CachePolicy", Value: "fetch_once"}},
+				}, {
+					Name: "FetchedAlways", Type: "u", Access: "readwrite",
+					Annotations: []introspect.Annotation{{Name: "org.chromium.DBus.Property.CachePolicy", Value: "always"}},
+				},
+			},
+		}},
+	}}
+
+	sc := serviceconfig.Config{ServiceName: "test.Service"}
+	out := new(bytes.Buffer)
+	if err := Generate(introspections, out, "/tmp/proxy.h", sc); err != nil {
+		t.Fatalf("Generate got error, want nil: %v", err)
+	}
+
+	const want = `// Automatic generation of D-Bus interfaces:
+//  - test.Itf
+#ifndef ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+#define ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+#include <memory>
+#include <string>
+#include <vector>
+
+#include <base/functional/bind.h>
+#include <base/functional/callback.h>
+#include <base/logging.h>
+#include <base/memory/ref_counted.h>
+#include <brillo/dbus/dbus_method_invoker.h>
+#include <brillo/dbus/dbus_property.h>
+#include <brillo/dbus/dbus_signal_handler.h>
+#include <brillo/errors/error.h>
+#include <dbus/bus.h>
+#include <dbus/message.h>
+#include <dbus/object_manager.h>
+#include <dbus/object_path.h>
+#include <dbus/object_proxy.h>
+
+namespace test {
+
+// Abstract interface proxy for test::Itf.
+class ItfProxyInterface {
+ public:
+  static constexpr char kInterfaceName[] = "test.Itf";
+  static constexpr char kCachedProperty[] = "Cached";
+  static constexpr char kCachedPropertySignature[] = "i";
+  static constexpr char kFetchedOnceProperty[] = "FetchedOnce";
+  static constexpr char kFetchedOncePropertySignature[] = "s";
+  static constexpr char kFetchedAlwaysProperty[] = "FetchedAlways";
+  static constexpr char kFetchedAlwaysPropertySignature[] = "u";
+
+  virtual ~ItfProxyInterface() = default;
+
+  static const char* CachedName() { return "Cached"; }
+  virtual int32_t cached() const = 0;
+  virtual bool is_cached_valid() const = 0;
+  virtual void SetCachedChangedCallback(
+      const base::RepeatingCallback<void(int32_t)>& callback) = 0;
+  static const char* FetchedOnceName() { return "FetchedOnce"; }
+  virtual const std::string& fetched_once() const = 0;
+  virtual bool is_fetched_once_valid() const = 0;
+  virtual void SetFetchedOnceChangedCallback(
+      const base::RepeatingCallback<void(const std::string&)>& callback) = 0;
+  static const char* FetchedAlwaysName() { return "FetchedAlways"; }
+  virtual uint32_t fetched_always() const = 0;
+  virtual bool is_fetched_always_valid() const = 0;
+  virtual void set_fetched_always(uint32_t value,
+                                  base::OnceCallback<void(bool)> callback) = 0;
+  virtual void SetFetchedAlwaysChangedCallback(
+      const base::RepeatingCallback<void(uint32_t)>& callback) = 0;
+
+  virtual const dbus::ObjectPath& GetObjectPath() const = 0;
+  virtual dbus::ObjectProxy* GetObjectProxy() const = 0;
+
+  virtual void InitializeProperties(
+      const base::RepeatingCallback<void(ItfProxyInterface*, const std::string&)>& callback) = 0;
+};
+
+}  // namespace test
+
+namespace test {
+
+// Interface proxy for test::Itf.
+class ItfProxy final : public ItfProxyInterface {
+ public:
+  class PropertySet : public dbus::PropertySet {
+   public:
+    PropertySet(dbus::ObjectProxy* object_proxy,
+                const PropertyChangedCallback& callback)
+        : dbus::PropertySet{object_proxy,
+                            "test.Itf",
+                            callback} {
+      RegisterProperty(CachedName(), &cached);
+      RegisterProperty(FetchedOnceName(), &fetched_once);
+      RegisterProperty(FetchedAlwaysName(), &fetched_always);
+    }
+    PropertySet(const PropertySet&) = delete;
+    PropertySet& operator=(const PropertySet&) = delete;
+
+    brillo::dbus_utils::Property<int32_t> cached;
+    brillo::dbus_utils::Property<std::string> fetched_once;
+    brillo::dbus_utils::Property<uint32_t> fetched_always;
+
+  };
+
+  ItfProxy(const scoped_refptr<dbus::Bus>& bus) :
+      bus_{bus},
+      dbus_object_proxy_{
+          bus_->GetObjectProxy(service_name_, object_path_)} {
+  }
+
+  // How the constructor initializes the properties.
+  enum class InitializePropertiesPolicy {
+    // Creates the property set and connects the PropertiesChanged signal,
+    // leaving the values to be fetched on demand.
+    kLazy,
+    // Does what InitializeProperties() does, i.e. also fetches all the values.
+    kEager,
+    // Leaves the properties uninitialized, as the other constructor does.
+    kNone,
+  };
+
+  ItfProxy(
+      const scoped_refptr<dbus::Bus>& bus,
+      InitializePropertiesPolicy policy,
+      const base::RepeatingCallback<void(ItfProxyInterface*, const std::string&)>& callback) :
+          ItfProxy(bus) {
+    switch (policy) {
+      case InitializePropertiesPolicy::kLazy:
+        on_property_changed_ = callback;
+        property_set_.reset(
+            new PropertySet(dbus_object_proxy_,
+                            base::BindRepeating(&ItfProxy::OnPropertyChanged,
+                                                base::Unretained(this))));
+        property_set_->ConnectSignals();
+        break;
+      case InitializePropertiesPolicy::kEager:
+        InitializeProperties(callback);
+        break;
+      case InitializePropertiesPolicy::kNone:
+        break;
+    }
+  }
+
+  ItfProxy(const ItfProxy&) = delete;
+  ItfProxy& operator=(const ItfProxy&) = delete;
+
+  ~ItfProxy() override {
+  }
+
+  void ReleaseObjectProxy(base::OnceClosure callback) {
+    bus_->RemoveObjectProxy(service_name_, object_path_, std::move(callback));
+  }
+
+  const dbus::ObjectPath& GetObjectPath() const override {
+    return object_path_;
+  }
+
+  dbus::ObjectProxy* GetObjectProxy() const override {
+    return dbus_object_proxy_;
+  }
+
+  void InitializeProperties(
+      const base::RepeatingCallback<void(ItfProxyInterface*, const std::string&)>& callback) override {
+    on_property_changed_ = callback;
+    property_set_.reset(
+        new PropertySet(dbus_object_proxy_,
+                        base::BindRepeating(&ItfProxy::OnPropertyChanged,
+                                            base::Unretained(this))));
+    property_set_->ConnectSignals();
+    property_set_->GetAll();
+  }
+
+  const PropertySet* GetProperties() const { return &(*property_set_); }
+  PropertySet* GetProperties() { return &(*property_set_); }
+
+  int32_t cached() const override {
+    return property_set_->cached.value();
+  }
+
+  bool is_cached_valid() const override {
+    return property_set_->cached.is_valid();
+  }
+
+  void SetCachedChangedCallback(
+      const base::RepeatingCallback<void(int32_t)>& callback) override {
+    on_cached_changed_ = callback;
+  }
+
+  const std::string& fetched_once() const override {
+    if (!property_set_->fetched_once.is_valid())
+      property_set_->fetched_once.GetAndBlock();
+    return property_set_->fetched_once.value();
+  }
+
+  bool is_fetched_once_valid() const override {
+    return property_set_->fetched_once.is_valid();
+  }
+
+  void SetFetchedOnceChangedCallback(
+      const base::RepeatingCallback<void(const std::string&)>& callback) override {
+    on_fetched_once_changed_ = callback;
+  }
+
+  uint32_t fetched_always() const override {
+    property_set_->fetched_always.GetAndBlock();
+    return property_set_->fetched_always.value();
+  }
+
+  bool is_fetched_always_valid() const override {
+    return property_set_->fetched_always.is_valid();
+  }
+
+  void set_fetched_always(uint32_t value,
+                          base::OnceCallback<void(bool)> callback) override {
+    property_set_->fetched_always.Set(value, std::move(callback));
+  }
+
+  void SetFetchedAlwaysChangedCallback(
+      const base::RepeatingCallback<void(uint32_t)>& callback) override {
+    on_fetched_always_changed_ = callback;
+  }
+
+ private:
+  void OnPropertyChanged(const std::string& property_name) {
+    if (property_name == CachedName() && !on_cached_changed_.is_null())
+      on_cached_changed_.Run(property_set_->cached.value());
+    if (property_name == FetchedOnceName() && !on_fetched_once_changed_.is_null())
+      on_fetched_once_changed_.Run(property_set_->fetched_once.value());
+    if (property_name == FetchedAlwaysName() && !on_fetched_always_changed_.is_null())
+      on_fetched_always_changed_.Run(property_set_->fetched_always.value());
+    if (!on_property_changed_.is_null())
+      on_property_changed_.Run(this, property_name);
+  }
+
+  scoped_refptr<dbus::Bus> bus_;
+  const std::string service_name_{"test.Service"};
+  const dbus::ObjectPath object_path_{"/test/Object"};
+  base::RepeatingCallback<void(ItfProxyInterface*, const std::string&)> on_property_changed_;
+  base::RepeatingCallback<void(int32_t)> on_cached_changed_;
+  base::RepeatingCallback<void(const std::string&)> on_fetched_once_changed_;
+  base::RepeatingCallback<void(uint32_t)> on_fetched_always_changed_;
+  dbus::ObjectProxy* dbus_object_proxy_;
+  std::unique_ptr<PropertySet> property_set_;
+
+};
+
+}  // namespace test
+
+#endif  // ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+`
+
+	if diff := cmp.Diff(out.String(), want); diff != "" {
+		t.Errorf("Generate failed (-got +want):\n%s", diff)
+	}
+}
+
+func TestGenerateProxiesWithWriteOnlyProperty(t *testing.T) {
+	introspections := []introspect.Introspection{{
+		Name: "/test/Object",
+		Interfaces: []introspect.Interface{{
+			Name: "test.Itf",
+			Properties: []introspect.Property{
+				{Name: "Level", Type: "i", Access: "read"},
+				{Name: "Secret", Type: "s", Access: "write"},
+			},
+		}},
+	}}
+
+	sc := serviceconfig.Config{ServiceName: "test.Service"}
+	out := new(bytes.Buffer)
+	if err := Generate(introspections, out, "/tmp/proxy.h", sc); err != nil {
+		t.Fatalf("Generate got error, want nil: %v", err)
+	}
+
+	const want = `// Automatic generation of D-Bus interfaces:
+//  - test.Itf
+#ifndef ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+#define ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+#include <memory>
+#include <string>
+#include <vector>
+
+#include <base/functional/bind.h>
+#include <base/functional/callback.h>
+#include <base/logging.h>
+#include <base/memory/ref_counted.h>
+#include <brillo/dbus/dbus_method_invoker.h>
+#include <brillo/dbus/dbus_property.h>
+#include <brillo/dbus/dbus_signal_handler.h>
+#include <brillo/errors/error.h>
+#include <dbus/bus.h>
+#include <dbus/message.h>
+#include <dbus/object_manager.h>
+#include <dbus/object_path.h>
+#include <dbus/object_proxy.h>
+
+namespace test {
+
+// Abstract interface proxy for test::Itf.
+class ItfProxyInterface {
+ public:
+  static constexpr char kInterfaceName[] = "test.Itf";
+  static constexpr char kLevelProperty[] = "Level";
+  static constexpr char kLevelPropertySignature[] = "i";
+  static constexpr char kSecretProperty[] = "Secret";
+  static constexpr char kSecretPropertySignature[] = "s";
+
+  virtual ~ItfProxyInterface() = default;
+
+  static const char* LevelName() { return "Level"; }
+  virtual int32_t level() const = 0;
+  virtual bool is_level_valid() const = 0;
+  virtual void SetLevelChangedCallback(
+      const base::RepeatingCallback<void(int32_t)>& callback) = 0;
+  static const char* SecretName() { return "Secret"; }
+  virtual void set_secret(const std::string& value,
+                          base::OnceCallback<void(bool)> callback) = 0;
+
+  virtual const dbus::ObjectPath& GetObjectPath() const = 0;
+  virtual dbus::ObjectProxy* GetObjectProxy() const = 0;
+
+  virtual void InitializeProperties(
+      const base::RepeatingCallback<void(ItfProxyInterface*, const std::string&)>& callback) = 0;
+};
+
+}  // namespace test
+
+namespace test {
+
+// Interface proxy for test::Itf.
+class ItfProxy final : public ItfProxyInterface {
+ public:
+  class PropertySet : public dbus::PropertySet {
+   public:
+    PropertySet(dbus::ObjectProxy* object_proxy,
+                const PropertyChangedCallback& callback)
+        : dbus::PropertySet{object_proxy,
+                            "test.Itf",
+                            callback} {
+      RegisterProperty(LevelName(), &level);
+      RegisterProperty(SecretName(), &secret);
+    }
+    PropertySet(const PropertySet&) = delete;
+    PropertySet& operator=(const PropertySet&) = delete;
+
+    brillo::dbus_utils::Property<int32_t> level;
+    brillo::dbus_utils::Property<std::string> secret;
+
+  };
+
+  ItfProxy(const scoped_refptr<dbus::Bus>& bus) :
+      bus_{bus},
+      dbus_object_proxy_{
+          bus_->GetObjectProxy(service_name_, object_path_)} {
+  }
+
+  // How the constructor initializes the properties.
+  enum class InitializePropertiesPolicy {
+    // Creates the property set and connects the PropertiesChanged signal,
+    // leaving the values to be fetched on demand.
+    kLazy,
+    // Does what InitializeProperties() does, i.e. also fetches all the values.
+    kEager,
+    // Leaves the properties uninitialized, as the other constructor does.
+    kNone,
+  };
+
+  ItfProxy(
+      const scoped_refptr<dbus::Bus>& bus,
+      InitializePropertiesPolicy policy,
+      const base::RepeatingCallback<void(ItfProxyInterface*, const std::string&)>& callback) :
+          ItfProxy(bus) {
+    switch (policy) {
+      case InitializePropertiesPolicy::kLazy:
+        on_property_changed_ = callback;
+        property_set_.reset(
+            new PropertySet(dbus_object_proxy_,
+                            base::BindRepeating(&ItfProxy::OnPropertyChanged,
+                                                base::Unretained(this))));
+        property_set_->ConnectSignals();
+        break;
+      case InitializePropertiesPolicy::kEager:
+        InitializeProperties(callback);
+        break;
+      case InitializePropertiesPolicy::kNone:
+        break;
+    }
+  }
+
+  ItfProxy(const ItfProxy&) = delete;
+  ItfProxy& operator=(const ItfProxy&) = delete;
+
+  ~ItfProxy() override {
+  }
+
+  void ReleaseObjectProxy(base::OnceClosure callback) {
+    bus_->RemoveObjectProxy(service_name_, object_path_, std::move(callback));
+  }
+
+  const dbus::ObjectPath& GetObjectPath() const override {
+    return object_path_;
+  }
+
+  dbus::ObjectProxy* GetObjectProxy() const override {
+    return dbus_object_proxy_;
+  }
+
+  void InitializeProperties(
+      const base::RepeatingCallback<void(ItfProxyInterface*, const std::string&)>& callback) override {
+    on_property_changed_ = callback;
+    property_set_.reset(
+        new PropertySet(dbus_object_proxy_,
+                        base::BindRepeating(&ItfProxy::OnPropertyChanged,
+                                            base::Unretained(this))));
+    property_set_->ConnectSignals();
+    property_set_->GetAll();
+  }
+
+  const PropertySet* GetProperties() const { return &(*property_set_); }
+  PropertySet* GetProperties() { return &(*property_set_); }
+
+  int32_t level() const override {
+    return property_set_->level.value();
+  }
+
+  bool is_level_valid() const override {
+    return property_set_->level.is_valid();
+  }
+
+  void SetLevelChangedCallback(
+      const base::RepeatingCallback<void(int32_t)>& callback) override {
+    on_level_changed_ = callback;
+  }
+
+  void set_secret(const std::string& value,
+                  base::OnceCallback<void(bool)> callback) override {
+    property_set_->secret.Set(value, std::move(callback));
+  }
+
+ private:
+  void OnPropertyChanged(const std::string& property_name) {
+    if (property_name == LevelName() && !on_level_changed_.is_null())
+      on_level_changed_.Run(property_set_->level.value());
+    if (!on_property_changed_.is_null())
+      on_property_changed_.Run(this, property_name);
+  }
+
+  scoped_refptr<dbus::Bus> bus_;
+  const std::string service_name_{"test.Service"};
+  const dbus::ObjectPath object_path_{"/test/Object"};
+  base::RepeatingCallback<void(ItfProxyInterface*, const std::string&)> on_property_changed_;
+  base::RepeatingCallback<void(int32_t)> on_level_changed_;
+  dbus::ObjectProxy* dbus_object_proxy_;
+  std::unique_ptr<PropertySet> property_set_;
+
+};
+
+}  // namespace test
+
+#endif  // ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+`
+
+	if diff := cmp.Diff(out.String(), want); diff != "" {
+		t.Errorf("Generate failed (-got +want):\n%s", diff)
+	}
+}
+
+func TestGenerateProxiesWithEmitsChangedSignal(t *testing.T) {
+	emits := func(v string) introspect.Annotation {
+		return introspect.Annotation{Name: "org.freedesktop.DBus.Property.EmitsChangedSignal", Value: v}
+	}
+	introspections := []introspect.Introspection{{
+		Name: "/test/Object",
+		Interfaces: []introspect.Interface{{
+			Name: "test.Itf",
+			Properties: []introspect.Property{
+				{Name: "Level", Type: "i", Access: "read", Annotations: []introspect.Annotation{emits("true")}},
+				{Name: "Blob", Type: "ay", Access: "readwrite", Annotations: []introspect.Annotation{emits("invalidates")}},
+				{Name: "Version", Type: "s", Access: "read", Annotations: []introspect.Annotation{emits("const")}},
+				{Name: "Load", Type: "d", Access: "read", Annotations: []introspect.Annotation{emits("false")}},
+			},
+		}},
+	}}
+
+	sc := serviceconfig.Config{ServiceName: "test.Service"}
+	out := new(bytes.Buffer)
+	if err := Generate(introspections, out, "/tmp/proxy.h", sc); err != nil {
+		t.Fatalf("Generate got error, want nil: %v", err)
+	}
+
+	const want = `// Automatic generation of D-Bus interfaces:
+//  - test.Itf
+#ifndef ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+#define ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+#include <memory>
+#include <string>
+#include <vector>
+
+#include <base/functional/bind.h>
+#include <base/functional/callback.h>
+#include <base/functional/callback_helpers.h>
+#include <base/logging.h>
+#include <base/memory/ref_counted.h>
+#include <brillo/any.h>
+#include <brillo/dbus/dbus_method_invoker.h>
+#include <brillo/dbus/dbus_property.h>
+#include <brillo/dbus/dbus_signal_handler.h>
+#include <brillo/errors/error.h>
+#include <dbus/bus.h>
+#include <dbus/message.h>
+#include <dbus/object_manager.h>
+#include <dbus/object_path.h>
+#include <dbus/object_proxy.h>
+#include <dbus/property.h>
+
+namespace test {
+
+// Abstract interface proxy for test::Itf.
+class ItfProxyInterface {
+ public:
+  static constexpr char kInterfaceName[] = "test.Itf";
+  static constexpr char kLevelProperty[] = "Level";
+  static constexpr char kLevelPropertySignature[] = "i";
+  static constexpr char kBlobProperty[] = "Blob";
+  static constexpr char kBlobPropertySignature[] = "ay";
+  static constexpr char kVersionProperty[] = "Version";
+  static constexpr char kVersionPropertySignature[] = "s";
+  static constexpr char kLoadProperty[] = "Load";
+  static constexpr char kLoadPropertySignature[] = "d";
+
+  virtual ~ItfProxyInterface() = default;
+
+  static const char* LevelName() { return "Level"; }
+  virtual int32_t level() const = 0;
+  virtual bool is_level_valid() const = 0;
+  virtual void SetLevelChangedCallback(
+      const base::RepeatingCallback<void(int32_t)>& callback) = 0;
+  static const char* BlobName() { return "Blob"; }
+  virtual const std::vector<uint8_t>& blob() const = 0;
+  virtual bool is_blob_valid() const = 0;
+  virtual void set_blob(const std::vector<uint8_t>& value,
+                        base::OnceCallback<void(bool)> callback) = 0;
+  virtual void SetBlobChangedCallback(
+      const base::RepeatingCallback<void(const std::vector<uint8_t>&)>& callback) = 0;
+  static const char* VersionName() { return "Version"; }
+  virtual const std::string& version() const = 0;
+  virtual bool is_version_valid() const = 0;
+  // Polling only: Load does not emit PropertiesChanged, so the changes of
+  // its value are not notified.
+  static const char* LoadName() { return "Load"; }
+  virtual double load() const = 0;
+  virtual bool is_load_valid() const = 0;
+  virtual void SetLoadChangedCallback(
+      const base::RepeatingCallback<void(double)>& callback) = 0;
+
+  virtual const dbus::ObjectPath& GetObjectPath() const = 0;
+  virtual dbus::ObjectProxy* GetObjectProxy() const = 0;
+
+  virtual void InitializeProperties(
+      const base::RepeatingCallback<void(ItfProxyInterface*, const std::string&)>& callback) = 0;
+};
+
+}  // namespace test
+
+namespace test {
+
+// Interface proxy for test::Itf.
+class ItfProxy final : public ItfProxyInterface {
+ public:
+  class PropertySet : public dbus::PropertySet {
+   public:
+    PropertySet(dbus::ObjectProxy* object_proxy,
+                const PropertyChangedCallback& callback)
+        : dbus::PropertySet{object_proxy,
+                            "test.Itf",
+                            callback} {
+      RegisterProperty(LevelName(), &level);
+      RegisterProperty(BlobName(), &blob);
+      RegisterProperty(LoadName(), &load);
+    }
+    PropertySet(const PropertySet&) = delete;
+    PropertySet& operator=(const PropertySet&) = delete;
+
+    brillo::dbus_utils::Property<int32_t> level;
+    brillo::dbus_utils::Property<std::vector<uint8_t>> blob;
+    brillo::dbus_utils::Property<double> load;
+
+  };
+
+  ItfProxy(const scoped_refptr<dbus::Bus>& bus) :
+      bus_{bus},
+      dbus_object_proxy_{
+          bus_->GetObjectProxy(service_name_, object_path_)} {
+  }
+
+  // How the constructor initializes the properties.
+  enum class InitializePropertiesPolicy {
+    // Creates the property set and connects the PropertiesChanged signal,
+    // leaving the values to be fetched on demand.
+    kLazy,
+    // Does what InitializeProperties() does, i.e. also fetches all the values.
+    kEager,
+    // Leaves the properties uninitialized, as the other constructor does.
+    kNone,
+  };
+
+  ItfProxy(
+      const scoped_refptr<dbus::Bus>& bus,
+      InitializePropertiesPolicy policy,
+      const base::RepeatingCallback<void(ItfProxyInterface*, const std::string&)>& callback) :
+          ItfProxy(bus) {
+    switch (policy) {
+      case InitializePropertiesPolicy::kLazy:
+        on_property_changed_ = callback;
+        property_set_.reset(
+            new PropertySet(dbus_object_proxy_,
+                            base::BindRepeating(&ItfProxy::OnPropertyChanged,
+                                                base::Unretained(this))));
+        property_set_->ConnectSignals();
+        break;
+      case InitializePropertiesPolicy::kEager:
+        InitializeProperties(callback);
+        break;
+      case InitializePropertiesPolicy::kNone:
+        break;
+    }
+  }
+
+  ItfProxy(const ItfProxy&) = delete;
+  ItfProxy& operator=(const ItfProxy&) = delete;
+
+  ~ItfProxy() override {
+  }
+
+  void ReleaseObjectProxy(base::OnceClosure callback) {
+    bus_->RemoveObjectProxy(service_name_, object_path_, std::move(callback));
+  }
+
+  const dbus::ObjectPath& GetObjectPath() const override {
+    return object_path_;
+  }
+
+  dbus::ObjectProxy* GetObjectProxy() const override {
+    return dbus_object_proxy_;
+  }
+
+  void InitializeProperties(
+      const base::RepeatingCallback<void(ItfProxyInterface*, const std::string&)>& callback) override {
+    on_property_changed_ = callback;
+    property_set_.reset(
+        new PropertySet(dbus_object_proxy_,
+                        base::BindRepeating(&ItfProxy::OnPropertyChanged,
+                                            base::Unretained(this))));
+    property_set_->ConnectSignals();
+    property_set_->GetAll();
+  }
+
+  const PropertySet* GetProperties() const { return &(*property_set_); }
+  PropertySet* GetProperties() { return &(*property_set_); }
+
+  int32_t level() const override {
+    return property_set_->level.value();
+  }
+
+  bool is_level_valid() const override {
+    return property_set_->level.is_valid();
+  }
+
+  void SetLevelChangedCallback(
+      const base::RepeatingCallback<void(int32_t)>& callback) override {
+    on_level_changed_ = callback;
+  }
+
+  const std::vector<uint8_t>& blob() const override {
+    return property_set_->blob.value();
+  }
+
+  bool is_blob_valid() const override {
+    return property_set_->blob.is_valid();
+  }
+
+  void set_blob(const std::vector<uint8_t>& value,
+                base::OnceCallback<void(bool)> callback) override {
+    property_set_->blob.Set(value, std::move(callback));
+  }
+
+  void SetBlobChangedCallback(
+      const base::RepeatingCallback<void(const std::vector<uint8_t>&)>& callback) override {
+    on_blob_changed_ = callback;
+  }
+
+  const std::string& version() const override {
+    // Version never changes, so it is fetched once instead of being
+    // tracked by the property set.
+    if (!version_fetched_) {
+      brillo::ErrorPtr error;
+      auto response = brillo::dbus_utils::CallMethodAndBlock(
+          dbus_object_proxy_, dbus::kPropertiesInterface, dbus::kPropertiesGet,
+          &error, std::string(kInterfaceName), std::string(VersionName()));
+      brillo::Any value;
+      if (response &&
+          brillo::dbus_utils::ExtractMethodCallResults(response.get(), &error, &value) &&
+          value.IsTypeCompatible<std::string>()) {
+        version_value_ = value.Get<std::string>();
+        version_fetched_ = true;
+      }
+    }
+    return version_value_;
+  }
+
+  bool is_version_valid() const override {
+    return version_fetched_;
+  }
+
+  double load() const override {
+    return property_set_->load.value();
+  }
+
+  bool is_load_valid() const override {
+    return property_set_->load.is_valid();
+  }
+
+  void SetLoadChangedCallback(
+      const base::RepeatingCallback<void(double)>& callback) override {
+    on_load_changed_ = callback;
+  }
+
+ private:
+  void OnPropertyChanged(const std::string& property_name) {
+    if (property_name == LevelName() && !on_level_changed_.is_null())
+      on_level_changed_.Run(property_set_->level.value());
+    if (property_name == BlobName()) {
+      if (!property_set_->blob.is_valid()) {
+        // The change was notified without the value. Fetching it notifies
+        // the change again with the value.
+        property_set_->blob.Get(base::DoNothing());
+      } else if (!on_blob_changed_.is_null()) {
+        on_blob_changed_.Run(property_set_->blob.value());
+      }
+    }
+    if (property_name == LoadName() && !on_load_changed_.is_null())
+      on_load_changed_.Run(property_set_->load.value());
+    if (!on_property_changed_.is_null())
+      on_property_changed_.Run(this, property_name);
+  }
+
+  scoped_refptr<dbus::Bus> bus_;
+  const std::string service_name_{"test.Service"};
+  const dbus::ObjectPath object_path_{"/test/Object"};
+  base::RepeatingCallback<void(ItfProxyInterface*, const std::string&)> on_property_changed_;
+  base::RepeatingCallback<void(int32_t)> on_level_changed_;
+  base::RepeatingCallback<void(const std::vector<uint8_t>&)> on_blob_changed_;
+  mutable std::string version_value_{};
+  mutable bool version_fetched_ = false;
+  base::RepeatingCallback<void(double)> on_load_changed_;
+  dbus::ObjectProxy* dbus_object_proxy_;
+  std::unique_ptr<PropertySet> property_set_;
+
+};
+
+}  // namespace test
+
+#endif  // ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+`
+
+	if diff := cmp.Diff(out.String(), want); diff != "" {
+		t.Errorf("Generate failed (-got +want):\n%s", diff)
+	}
+}
+
+func TestGenerateProxiesWithSharedProxies(t *testing.T) {
+	introspections := []introspect.Introspection{{
+		Interfaces: []introspect.Interface{{
+			Name: "test.Itf",
+		}},
+	}}
+
+	sc := serviceconfig.Config{ServiceName: "test.Service"}
+	out := new(bytes.Buffer)
+	if err := GenerateWithSharedProxies(introspections, out, "/tmp/proxy.h", "../shared/proxy.h", sc); err != nil {
+		t.Fatalf("GenerateWithSharedProxies got error, want nil: %v", err)
+	}
+
+	const want = `// Automatic generation of D-Bus interfaces:
+//  - test.Itf
+#ifndef ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+#define ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+#include <memory>
+#include <string>
+#include <vector>
+
+#include <base/functional/bind.h>
+#include <base/functional/callback.h>
+#include <base/logging.h>
+#include <base/memory/ref_counted.h>
+#include <brillo/dbus/dbus_method_invoker.h>
+#include <brillo/dbus/dbus_property.h>
+#include <brillo/dbus/dbus_signal_handler.h>
+#include <brillo/errors/error.h>
+#include <dbus/bus.h>
+#include <dbus/message.h>
+#include <dbus/object_manager.h>
+#include <dbus/object_path.h>
+#include <dbus/object_proxy.h>
+
+#include "../shared/proxy.h"
+
+namespace test {
+
+// Abstract interface proxy for test::Itf.
+class ItfProxyInterface {
+ public:
+  static constexpr char kInterfaceName[] = "test.Itf";
+
+  virtual ~ItfProxyInterface() = default;
+
+  virtual const dbus::ObjectPath& GetObjectPath() const = 0;
+  virtual dbus::ObjectProxy* GetObjectProxy() const = 0;
+};
+
+}  // namespace test
+
+namespace test {
+
+// Interface proxy for test::Itf.
+class ItfProxy final : public ItfProxyInterface {
+ public:
+  ItfProxy(
+      const scoped_refptr<dbus::Bus>& bus,
+      const dbus::ObjectPath& object_path) :
+          bus_{bus},
+          object_path_{object_path},
+          dbus_object_proxy_{
+              bus_->GetObjectProxy(service_name_, object_path_)} {
+  }
+
+  ItfProxy(const ItfProxy&) = delete;
+  ItfProxy& operator=(const ItfProxy&) = delete;
+
+  ~ItfProxy() override {
+  }
+
+  void ReleaseObjectProxy(base::OnceClosure callback) {
+    bus_->RemoveObjectProxy(service_name_, object_path_, std::move(callback));
+  }
+
+  const dbus::ObjectPath& GetObjectPath() const override {
+    return object_path_;
+  }
+
+  dbus::ObjectProxy* GetObjectProxy() const override {
+    return dbus_object_proxy_;
+  }
+
+ private:
+  scoped_refptr<dbus::Bus> bus_;
+  const std::string service_name_{"test.Service"};
+  dbus::ObjectPath object_path_;
+  dbus::ObjectProxy* dbus_object_proxy_;
+
+};
+
+}  // namespace test
+
+#endif  // ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+`
+
+	if diff := cmp.Diff(out.String(), want); diff != "" {
+		t.Errorf("GenerateWithSharedProxies failed (-got +want):\n%s", diff)
+	}
+
+	if err := GenerateWithSharedProxies(introspections, out, "/tmp/proxy.h", "", sc); err == nil {
+		t.Error("GenerateWithSharedProxies without shared proxy file path succeeded unexpectedly")
+	}
+}
+
+func TestGenerateProxiesWithSignalSenders(t *testing.T) {
+	introspections := []introspect.Introspection{{
+		Name: "/org/chromium/Test",
+		Interfaces: []introspect.Interface{{
+			Name: "org.chromium.Test",
+			Signals: []introspect.Signal{
+				{
+					Name: "Changed",
+					Args: []introspect.SignalArg{
+						{Name: "name", Type: "s"},
+						{Name: "value", Type: "i"},
+					},
+				}, {
+					Name: "Reset",
+				},
+			},
+		}},
+	}}
+
+	sc := serviceconfig.Config{ServiceName: "org.chromium.TestService"}
+	out := new(bytes.Buffer)
+	if err := GenerateWithSignalSenders(introspections, out, "/tmp/proxy.h", sc); err != nil {
+		t.Fatalf("GenerateWithSignalSenders got error, want nil: %v", err)
+	}
+
+	const want = `// Automatic generation of D-Bus interfaces:
+//  - org.chromium.Test
+#ifndef ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+#define ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+#include <memory>
+#include <string>
+#include <vector>
+
+#include <base/functional/bind.h>
+#include <base/functional/callback.h>
+#include <base/logging.h>
+#include <base/memory/ref_counted.h>
+#include <base/memory/weak_ptr.h>
+#include <brillo/dbus/dbus_method_invoker.h>
+#include <brillo/dbus/dbus_param_writer.h>
+#include <brillo/dbus/dbus_property.h>
+#include <brillo/dbus/dbus_signal_handler.h>
+#include <brillo/errors/error.h>
+#include <dbus/bus.h>
+#include <dbus/message.h>
+#include <dbus/object_manager.h>
+#include <dbus/object_path.h>
+#include <dbus/object_proxy.h>
+
+namespace org {
+namespace chromium {
+
+// Abstract interface proxy for org::chromium::Test.
+class TestProxyInterface {
+ public:
+  static constexpr char kInterfaceName[] = "org.chromium.Test";
+  static constexpr char kChangedSignal[] = "Changed";
+  static constexpr char kChangedSignalSignature[] = "si";
+  static constexpr char kResetSignal[] = "Reset";
+  static constexpr char kResetSignalSignature[] = "";
+
+  using ChangedSignalCallback =
+      base::RepeatingCallback<void(const std::string& /*name*/,
+                                   int32_t /*value*/)>;
+  using ResetSignalCallback =
+      base::RepeatingClosure;
+
+  virtual ~TestProxyInterface() = default;
+
+  virtual void RegisterChangedSignalHandler(
+      const base::RepeatingCallback<void(const std::string&,
+                                         int32_t)>& signal_callback,
+      dbus::ObjectProxy::OnConnectedCallback on_connected_callback) = 0;
+
+  // Registers |method| of |target| as the handler of the Changed signal.
+  // The signals delivered after |target| is invalidated are dropped.
+  template <typename T>
+  void RegisterChangedSignalHandlerWeak(
+      base::WeakPtr<T> target,
+      void (T::*method)(const std::string&, int32_t),
+      dbus::ObjectProxy::OnConnectedCallback on_connected_callback) {
+    RegisterChangedSignalHandler(
+        base::BindRepeating(method, std::move(target)),
+        std::move(on_connected_callback));
+  }
+
+  virtual void RegisterResetSignalHandler(
+      base::RepeatingClosure signal_callback,
+      dbus::ObjectProxy::OnConnectedCallback on_connected_callback) = 0;
+
+  // Registers |method| of |target| as the handler of the Reset signal.
+  // The signals delivered after |target| is invalidated are dropped.
+  template <typename T>
+  void RegisterResetSignalHandlerWeak(
+      base::WeakPtr<T> target,
+      void (T::*method)(),
+      dbus::ObjectProxy::OnConnectedCallback on_connected_callback) {
+    RegisterResetSignalHandler(
+        base::BindRepeating(method, std::move(target)),
+        std::move(on_connected_callback));
+  }
+
+  virtual const dbus::ObjectPath& GetObjectPath() const = 0;
+  virtual dbus::ObjectProxy* GetObjectProxy() const = 0;
+};
+
+}  // namespace chromium
+}  // namespace org
+
+namespace org {
+namespace chromium {
+
+// Interface proxy for org::chromium::Test.
+class TestProxy final : public TestProxyInterface {
+ public:
+  TestProxy(const scoped_refptr<dbus::Bus>& bus) :
+      bus_{bus},
+      dbus_object_proxy_{
+          bus_->GetObjectProxy(service_name_, object_path_)} {
+  }
+
+  TestProxy(const TestProxy&) = delete;
+  TestProxy& operator=(const TestProxy&) = delete;
+
+  ~TestProxy() override {
+  }
+
+  void RegisterChangedSignalHandler(
+      const base::RepeatingCallback<void(const std::string&,
+                                         int32_t)>& signal_callback,
+      dbus::ObjectProxy::OnConnectedCallback on_connected_callback) override {
+    brillo::dbus_utils::ConnectToSignal(
+        dbus_object_proxy_,
+        "org.chromium.Test",
+        "Changed",
+        signal_callback,
+        std::move(on_connected_callback));
+  }
+
+  void RegisterResetSignalHandler(
+      base::RepeatingClosure signal_callback,
+      dbus::ObjectProxy::OnConnectedCallback on_connected_callback) override {
+    brillo::dbus_utils::ConnectToSignal(
+        dbus_object_proxy_,
+        "org.chromium.Test",
+        "Reset",
+        signal_callback,
+        std::move(on_connected_callback));
+  }
+
+  void ReleaseObjectProxy(base::OnceClosure callback) {
+    bus_->RemoveObjectProxy(service_name_, object_path_, std::move(callback));
+  }
+
+  const dbus::ObjectPath& GetObjectPath() const override {
+    return object_path_;
+  }
+
+  dbus::ObjectProxy* GetObjectProxy() const override {
+    return dbus_object_proxy_;
+  }
+
+ private:
+  scoped_refptr<dbus::Bus> bus_;
+  const std::string service_name_{"org.chromium.TestService"};
+  const dbus::ObjectPath object_path_{"/org/chromium/Test"};
+  dbus::ObjectProxy* dbus_object_proxy_;
+
+};
+
+// Runs |signal_callback| with the Changed signal carrying the arguments, as
+// if it were sent by the service. For testing only, e.g. with the callback
+// captured from dbus::MockObjectProxy::DoConnectToSignal.
+inline void SendChangedSignalForTesting(
+    const dbus::ObjectProxy::SignalCallback& signal_callback,
+    const std::string& in_name,
+    int32_t in_value) {
+  dbus::Signal signal(TestProxyInterface::kInterfaceName,
+                      TestProxyInterface::kChangedSignal);
+  dbus::MessageWriter writer(&signal);
+  brillo::dbus_utils::DBusParamWriter::Append(
+      &writer, in_name, in_value);
+  signal_callback.Run(&signal);
+}
+
+// Runs |signal_callback| with the Reset signal carrying the arguments, as
+// if it were sent by the service. For testing only, e.g. with the callback
+// captured from dbus::MockObjectProxy::DoConnectToSignal.
+inline void SendResetSignalForTesting(
+    const dbus::ObjectProxy::SignalCallback& signal_callback) {
+  dbus::Signal signal(TestProxyInterface::kInterfaceName,
+                      TestProxyInterface::kResetSignal);
+  signal_callback.Run(&signal);
+}
+
+}  // namespace chromium
+}  // namespace org
+
+#endif  // ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+`
+
+	if diff := cmp.Diff(out.String(), want); diff != "" {
+		t.Errorf("GenerateWithSignalSenders failed (-got +want):\n%s", diff)
+	}
+
+	// The senders of the signals with the same name in the same namespace
+	// would collide.
+	introspections[0].Interfaces = append(introspections[0].Interfaces, introspect.Interface{
+		Name:    "org.chromium.Other",
+		Signals: []introspect.Signal{{Name: "Reset"}},
+	})
+	if err := GenerateWithSignalSenders(introspections, out, "/tmp/proxy.h", sc); err == nil {
+		t.Error("GenerateWithSignalSenders with colliding signals succeeded unexpectedly")
+	}
+}
+
+func TestGenerateModule(t *testing.T) {
+	introspections := []introspect.Introspection{{
+		Name: "/org/chromium/Test",
+		Interfaces: []introspect.Interface{{
+			Name: "org.chromium.Test",
+			Methods: []introspect.Method{{
+				Name: "Ping",
+				Args: []introspect.MethodArg{
+					{Name: "count", Direction: "out", Type: "i"},
+				},
+			}},
+		}},
+	}}
+
+	sc := serviceconfig.Config{ServiceName: "org.chromium.TestService"}
+	out := new(bytes.Buffer)
+	if err := GenerateModule(introspections, out, "/tmp/dbus-proxies.ixx", sc); err != nil {
+		t.Fatalf("GenerateModule got error, want nil: %v", err)
+	}
+
+	const want = `// Automatic generation of D-Bus interfaces:
+//  - org.chromium.Test
+module;
+#include <memory>
+#include <string>
+#include <vector>
+
+#include <base/functional/bind.h>
+#include <base/functional/callback.h>
+#include <base/logging.h>
+#include <base/memory/ref_counted.h>
+#include <brillo/dbus/dbus_method_invoker.h>
+#include <brillo/dbus/dbus_property.h>
+#include <brillo/dbus/dbus_signal_handler.h>
+#include <brillo/errors/error.h>
+#include <dbus/bus.h>
+#include <dbus/message.h>
+#include <dbus/object_manager.h>
+#include <dbus/object_path.h>
+#include <dbus/object_proxy.h>
+
+export module chromeos_dbus_binding.tmp.dbus_proxies;
+
+export {
+
+namespace org {
+namespace chromium {
+
+// Abstract interface proxy for org::chromium::Test.
+class TestProxyInterface {
+ public:
+  static constexpr char kInterfaceName[] = "org.chromium.Test";
+  static constexpr char kPingMethod[] = "Ping";
+  static constexpr char kPingMethodInSignature[] = "";
+  static constexpr char kPingMethodOutSignature[] = "i";
+
+  virtual ~TestProxyInterface() = default;
+
+  virtual bool Ping(
+      int32_t* out_count,
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
+
+  virtual void PingAsync(
+      base::OnceCallback<void(int32_t /*count*/)> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
+
+  virtual const dbus::ObjectPath& GetObjectPath() const = 0;
+  virtual dbus::ObjectProxy* GetObjectProxy() const = 0;
+};
+
+}  // namespace chromium
+}  // namespace org
+
+namespace org {
+namespace chromium {
+
+// Interface proxy for org::chromium::Test.
+class TestProxy final : public TestProxyInterface {
+ public:
+  TestProxy(const scoped_refptr<dbus::Bus>& bus) :
+      bus_{bus},
+      dbus_object_proxy_{
+          bus_->GetObjectProxy(service_name_, object_path_)} {
+  }
+
+  TestProxy(const TestProxy&) = delete;
+  TestProxy& operator=(const TestProxy&) = delete;
+
+  ~TestProxy() override {
+  }
+
+  void ReleaseObjectProxy(base::OnceClosure callback) {
+    bus_->RemoveObjectProxy(service_name_, object_path_, std::move(callback));
+  }
+
+  const dbus::ObjectPath& GetObjectPath() const override {
+    return object_path_;
+  }
+
+  dbus::ObjectProxy* GetObjectProxy() const override {
+    return dbus_object_proxy_;
+  }
+
+  bool Ping(
+      int32_t* out_count,
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override {
+    auto response = brillo::dbus_utils::CallMethodAndBlockWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "org.chromium.Test",
+        "Ping",
+        error);
+    return response && brillo::dbus_utils::ExtractMethodCallResults(
+        response.get(), error, out_count);
+  }
+
+  void PingAsync(
+      base::OnceCallback<void(int32_t /*count*/)> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override {
+    brillo::dbus_utils::CallMethodWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "org.chromium.Test",
+        "Ping",
+        std::move(success_callback),
+        std::move(error_callback));
+  }
+
+ private:
+  scoped_refptr<dbus::Bus> bus_;
+  const std::string service_name_{"org.chromium.TestService"};
+  const dbus::ObjectPath object_path_{"/org/chromium/Test"};
+  dbus::ObjectProxy* dbus_object_proxy_;
+
+};
+
+}  // namespace chromium
+}  // namespace org
+
+}  // export
+`
+
+	if diff := cmp.Diff(out.String(), want); diff != "" {
+		t.Errorf("GenerateModule failed (-got +want):\n%s", diff)
+	}
+}
+
+func TestGenerateProxiesWithoutBlockingCalls(t *testing.T) {
+	introspections := []introspect.Introspection{{
+		Name: "/org/chromium/Test",
+		Interfaces: []introspect.Interface{{
+			Name: "org.chromium.Test",
+			Methods: []introspect.Method{
+				{
+					Name: "Scan",
+					Args: []introspect.MethodArg{
+						{Name: "name", Type: "s"},
+						{
+							Name: "flags", Type: "i",
+							Annotations: []introspect.Annotation{{Name: "org.chromium.DBus.Argument.DefaultValue", Value: "0"}},
+						},
+						{Name: "count", Type: "i", Direction: "out"},
+					},
+				}, {
+					Name: "Ping",
+					Annotations: []introspect.Annotation{
+						{Name: "org.chromium.DBus.Method.IncludeDBusMessage", Value: "true"},
+					},
+				},
+			},
+		}},
+	}}
+
+	sc := serviceconfig.Config{
+		ServiceName:          "org.chromium.TestService",
+		DisableBlockingCalls: true,
+	}
+	out := new(bytes.Buffer)
+	if err := Generate(introspections, out, "/tmp/proxy.h", sc); err != nil {
+		t.Fatalf("Generate got error, want nil: %v", err)
+	}
+
+	const want = `// Automatic generation of D-Bus interfaces:
+//  - org.chromium.Test
+#ifndef ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+#define ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+#include <memory>
+#include <string>
+#include <vector>
+
+#include <base/functional/bind.h>
+#include <base/functional/callback.h>
+#include <base/logging.h>
+#include <base/memory/ref_counted.h>
+#include <brillo/dbus/dbus_method_invoker.h>
+#include <brillo/dbus/dbus_property.h>
+#include <brillo/dbus/dbus_signal_handler.h>
+#include <brillo/errors/error.h>
+#include <dbus/bus.h>
+#include <dbus/message.h>
+#include <dbus/object_manager.h>
+#include <dbus/object_path.h>
+#include <dbus/object_proxy.h>
+
+namespace org {
+namespace chromium {
+
+// Abstract interface proxy for org::chromium::Test.
+class TestProxyInterface {
+ public:
+  static constexpr char kInterfaceName[] = "org.chromium.Test";
+  static constexpr char kScanMethod[] = "Scan";
+  static constexpr char kScanMethodInSignature[] = "si";
+  static constexpr char kScanMethodOutSignature[] = "i";
+  static constexpr char kPingMethod[] = "Ping";
+  static constexpr char kPingMethodInSignature[] = "";
+  static constexpr char kPingMethodOutSignature[] = "";
+
+  virtual ~TestProxyInterface() = default;
+
+  virtual void ScanAsync(
+      const std::string& in_name,
+      int32_t in_flags,
+      base::OnceCallback<void(int32_t /*count*/)> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
+
+  // Calls ScanAsync() with the default values of the omitted arguments.
+  void ScanAsync(
+      const std::string& in_name,
+      base::OnceCallback<void(int32_t /*count*/)> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) {
+    ScanAsync(in_name, 0, std::move(success_callback),
+              std::move(error_callback), timeout_ms);
+  }
+
+  virtual void PingAsync(
+      base::OnceCallback<void()> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
+
+  virtual const dbus::ObjectPath& GetObjectPath() const = 0;
+  virtual dbus::ObjectProxy* GetObjectProxy() const = 0;
+};
+
+}  // namespace chromium
+}  // namespace org
+
+namespace org {
+namespace chromium {
+
+// Interface proxy for org::chromium::Test.
+class TestProxy final : public TestProxyInterface {
+ public:
+  TestProxy(const scoped_refptr<dbus::Bus>& bus) :
+      bus_{bus},
+      dbus_object_proxy_{
+          bus_->GetObjectProxy(service_name_, object_path_)} {
+  }
+
+  TestProxy(const TestProxy&) = delete;
+  TestProxy& operator=(const TestProxy&) = delete;
+
+  ~TestProxy() override {
+  }
+
+  void ReleaseObjectProxy(base::OnceClosure callback) {
+    bus_->RemoveObjectProxy(service_name_, object_path_, std::move(callback));
+  }
+
+  const dbus::ObjectPath& GetObjectPath() const override {
+    return object_path_;
+  }
+
+  dbus::ObjectProxy* GetObjectProxy() const override {
+    return dbus_object_proxy_;
+  }
+
+  void ScanAsync(
+      const std::string& in_name,
+      int32_t in_flags,
+      base::OnceCallback<void(int32_t /*count*/)> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override {
+    brillo::dbus_utils::CallMethodWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "org.chromium.Test",
+        "Scan",
+        std::move(success_callback),
+        std::move(error_callback),
+        in_name,
+        in_flags);
+  }
+
+  void PingAsync(
+      base::OnceCallback<void()> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override {
+    brillo::dbus_utils::CallMethodWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "org.chromium.Test",
+        "Ping",
+        std::move(success_callback),
+        std::move(error_callback));
+  }
+
+  // Unhide the overloads omitting the arguments with default values.
+  using TestProxyInterface::ScanAsync;
+
+ private:
+  scoped_refptr<dbus::Bus> bus_;
+  const std::string service_name_{"org.chromium.TestService"};
+  const dbus::ObjectPath object_path_{"/org/chromium/Test"};
+  dbus::ObjectProxy* dbus_object_proxy_;
+
+};
+
+}  // namespace chromium
+}  // namespace org
+
+#endif  // ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+`
+
+	if diff := cmp.Diff(out.String(), want); diff != "" {
+		t.Errorf("Generate failed (-got +want):\n%s", diff)
+	}
+}
+
+func TestGenerateProxiesWithInterfaceOverrides(t *testing.T) {
+	level := introspect.Property{
+		Name:   "Level",
+		Type:   "i",
+		Access: "read",
+	}
+	ping := introspect.Method{
+		Name: "Ping",
+	}
+	introspections := []introspect.Introspection{{
+		Name: "/org/chromium/Test",
+		Interfaces: []introspect.Interface{
+			{
+				Name:       "org.chromium.Managed",
+				Methods:    []introspect.Method{ping},
+				Properties: []introspect.Property{level},
+			}, {
+				Name:       "org.chromium.Standalone",
+				Methods:    []introspect.Method{ping},
+				Properties: []introspect.Property{level},
+			},
+		},
+	}}
+
+	disabled := true
+	sc := serviceconfig.Config{
+		ServiceName: "org.chromium.TestService",
+		ObjectManager: &serviceconfig.ObjectManagerConfig{
+			Name: "org.chromium.TestService.ObjectManager",
+		},
+		Interfaces: map[string]serviceconfig.InterfaceConfig{
+			"org.chromium.Standalone": {
+				Properties:           serviceconfig.PropertyModePropertySet,
+				DisableBlockingCalls: &disabled,
+			},
+		},
+	}
+	out := new(bytes.Buffer)
+	if err := Generate(introspections, out, "/tmp/proxy.h", sc); err != nil {
+		t.Fatalf("Generate got error, want nil: %v", err)
+	}
+
+	const want = `// Automatic generation of D-Bus interfaces:
+//  - org.chromium.Managed
+//  - org.chromium.Standalone
+#ifndef ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+#define ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+#include <memory>
+#include <optional>
+#include <string>
+#include <vector>
+
+#include <base/functional/bind.h>
+#include <base/functional/callback.h>
+#include <base/location.h>
+#include <base/logging.h>
+#include <base/memory/ref_counted.h>
+#include <base/task/sequenced_task_runner.h>
+#include <base/time/time.h>
+#include <brillo/dbus/dbus_method_invoker.h>
+#include <brillo/dbus/dbus_property.h>
+#include <brillo/dbus/dbus_signal_handler.h>
+#include <brillo/errors/error.h>
+#include <brillo/variant_dictionary.h>
+#include <dbus/bus.h>
+#include <dbus/message.h>
+#include <dbus/object_manager.h>
+#include <dbus/object_path.h>
+#include <dbus/object_proxy.h>
+
+namespace org {
+namespace chromium {
+namespace TestService {
+class ObjectManagerProxy;
+}  // namespace TestService
+}  // namespace chromium
+}  // namespace org
+
+namespace org {
+namespace chromium {
+
+// Abstract interface proxy for org::chromium::Managed.
+class ManagedProxyInterface {
+ public:
+  static constexpr char kInterfaceName[] = "org.chromium.Managed";
+  static constexpr char kPingMethod[] = "Ping";
+  static constexpr char kPingMethodInSignature[] = "";
+  static constexpr char kPingMethodOutSignature[] = "";
+  static constexpr char kLevelProperty[] = "Level";
+  static constexpr char kLevelPropertySignature[] = "i";
+
+  virtual ~ManagedProxyInterface() = default;
+
+  virtual bool Ping(
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
+
+  virtual void PingAsync(
+      base::OnceCallback<void()> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
+
+  static const char* LevelName() { return "Level"; }
+  virtual int32_t level() const = 0;
+  virtual bool is_level_valid() const = 0;
+  virtual void SetLevelChangedCallback(
+      const base::RepeatingCallback<void(int32_t)>& callback) = 0;
+
+  virtual const dbus::ObjectPath& GetObjectPath() const = 0;
+  virtual dbus::ObjectProxy* GetObjectProxy() const = 0;
+
+  virtual void SetPropertyChangedCallback(
+      const base::RepeatingCallback<void(ManagedProxyInterface*, const std::string&)>& callback) = 0;
+};
+
+}  // namespace chromium
+}  // namespace org
+
+namespace org {
+namespace chromium {
+
+// Interface proxy for org::chromium::Managed.
+class ManagedProxy final : public ManagedProxyInterface {
+ public:
+  class PropertySet : public dbus::PropertySet {
+   public:
+    PropertySet(dbus::ObjectProxy* object_proxy,
+                const PropertyChangedCallback& callback)
+        : dbus::PropertySet{object_proxy,
+                            "org.chromium.Managed",
+                            callback} {
+      RegisterProperty(LevelName(), &level);
+    }
+    PropertySet(const PropertySet&) = delete;
+    PropertySet& operator=(const PropertySet&) = delete;
+
+    brillo::dbus_utils::Property<int32_t> level;
+
+  };
+
+  ManagedProxy(
+      const scoped_refptr<dbus::Bus>& bus,
+      PropertySet* property_set) :
+          bus_{bus},
+          property_set_{property_set},
+          dbus_object_proxy_{
+              bus_->GetObjectProxy(service_name_, object_path_)} {
+  }
+
+  ManagedProxy(const ManagedProxy&) = delete;
+  ManagedProxy& operator=(const ManagedProxy&) = delete;
+
+  ~ManagedProxy() override {
+  }
+
+  void ReleaseObjectProxy(base::OnceClosure callback) {
+    bus_->RemoveObjectProxy(service_name_, object_path_, std::move(callback));
+  }
+
+  const dbus::ObjectPath& GetObjectPath() const override {
+    return object_path_;
+  }
+
+  dbus::ObjectProxy* GetObjectProxy() const override {
+    return dbus_object_proxy_;
+  }
+
+  void SetPropertyChangedCallback(
+      const base::RepeatingCallback<void(ManagedProxyInterface*, const std::string&)>& callback) override {
+    on_property_changed_ = callback;
+  }
+
+  const PropertySet* GetProperties() const { return &(*property_set_); }
+  PropertySet* GetProperties() { return &(*property_set_); }
+
+  bool Ping(
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override {
+    auto response = brillo::dbus_utils::CallMethodAndBlockWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "org.chromium.Managed",
+        "Ping",
+        error);
+    return response && brillo::dbus_utils::ExtractMethodCallResults(
+        response.get(), error);
+  }
+
+  void PingAsync(
+      base::OnceCallback<void()> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override {
+    brillo::dbus_utils::CallMethodWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "org.chromium.Managed",
+        "Ping",
+        std::move(success_callback),
+        std::move(error_callback));
+  }
+
+  int32_t level() const override {
+    return property_set_->level.value();
+  }
+
+  bool is_level_valid() const override {
+    return property_set_->level.is_valid();
+  }
+
+  void SetLevelChangedCallback(
+      const base::RepeatingCallback<void(int32_t)>& callback) override {
+    on_level_changed_ = callback;
+  }
+
+ private:
+  void OnPropertyChanged(const std::string& property_name) {
+    if (property_name == LevelName() && !on_level_changed_.is_null())
+      on_level_changed_.Run(property_set_->level.value());
+    if (!on_property_changed_.is_null())
+      on_property_changed_.Run(this, property_name);
+  }
+
+  scoped_refptr<dbus::Bus> bus_;
+  const std::string service_name_{"org.chromium.TestService"};
+  const dbus::ObjectPath object_path_{"/org/chromium/Test"};
+  PropertySet* property_set_;
+  base::RepeatingCallback<void(ManagedProxyInterface*, const std::string&)> on_property_changed_;
+  base::RepeatingCallback<void(int32_t)> on_level_changed_;
+  dbus::ObjectProxy* dbus_object_proxy_;
+
+  friend class org::chromium::TestService::ObjectManagerProxy;
+};
+
+}  // namespace chromium
+}  // namespace org
+
+namespace org {
+namespace chromium {
+
+// Abstract interface proxy for org::chromium::Standalone.
+class StandaloneProxyInterface {
+ public:
+  static constexpr char kInterfaceName[] = "org.chromium.Standalone";
+  static constexpr char kPingMethod[] = "Ping";
+  static constexpr char kPingMethodInSignature[] = "";
+  static constexpr char kPingMethodOutSignature[] = "";
+  static constexpr char kLevelProperty[] = "Level";
+  static constexpr char kLevelPropertySignature[] = "i";
+
+  virtual ~StandaloneProxyInterface() = default;
+
+  virtual void PingAsync(
+      base::OnceCallback<void()> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
+
+  static const char* LevelName() { return "Level"; }
+  virtual int32_t level() const = 0;
+  virtual bool is_level_valid() const = 0;
+  virtual void SetLevelChangedCallback(
+      const base::RepeatingCallback<void(int32_t)>& callback) = 0;
+
+  virtual const dbus::ObjectPath& GetObjectPath() const = 0;
+  virtual dbus::ObjectProxy* GetObjectProxy() const = 0;
+
+  virtual void InitializeProperties(
+      const base::RepeatingCallback<void(StandaloneProxyInterface*, const std::string&)>& callback) = 0;
+};
+
+}  // namespace chromium
+}  // namespace org
+
+namespace org {
+namespace chromium {
+
+// Interface proxy for org::chromium::Standalone.
+class StandaloneProxy final : public StandaloneProxyInterface {
+ public:
+  class PropertySet : public dbus::PropertySet {
+   public:
+    PropertySet(dbus::ObjectProxy* object_proxy,
+                const PropertyChangedCallback& callback)
+        : dbus::PropertySet{object_proxy,
+                            "org.chromium.Standalone",
+                            callback} {
+      RegisterProperty(LevelName(), &level);
+    }
+    PropertySet(const PropertySet&) = delete;
+    PropertySet& operator=(const PropertySet&) = delete;
+
+    brillo::dbus_utils::Property<int32_t> level;
+
+  };
+
+  StandaloneProxy(const scoped_refptr<dbus::Bus>& bus) :
+      bus_{bus},
+      dbus_object_proxy_{
+          bus_->GetObjectProxy(service_name_, object_path_)} {
+  }
+
+  // How the constructor initializes the properties.
+  enum class InitializePropertiesPolicy {
+    // Creates the property set and connects the PropertiesChanged signal,
+    // leaving the values to be fetched on demand.
+    kLazy,
+    // Does what InitializeProperties() does, i.e. also fetches all the values.
+    kEager,
+    // Leaves the properties uninitialized, as the other constructor does.
+    kNone,
+  };
+
+  StandaloneProxy(
+      const scoped_refptr<dbus::Bus>& bus,
+      InitializePropertiesPolicy policy,
+      const base::RepeatingCallback<void(StandaloneProxyInterface*, const std::string&)>& callback) :
+          StandaloneProxy(bus) {
+    switch (policy) {
+      case InitializePropertiesPolicy::kLazy:
+        on_property_changed_ = callback;
+        property_set_.reset(
+            new PropertySet(dbus_object_proxy_,
+                            base::BindRepeating(&StandaloneProxy::OnPropertyChanged,
+                                                base::Unretained(this))));
+        property_set_->ConnectSignals();
+        break;
+      case InitializePropertiesPolicy::kEager:
+        InitializeProperties(callback);
+        break;
+      case InitializePropertiesPolicy::kNone:
+        break;
+    }
+  }
+
+  StandaloneProxy(const StandaloneProxy&) = delete;
+  StandaloneProxy& operator=(const StandaloneProxy&) = delete;
+
+  ~StandaloneProxy() override {
+  }
+
+  void ReleaseObjectProxy(base::OnceClosure callback) {
+    bus_->RemoveObjectProxy(service_name_, object_path_, std::move(callback));
+  }
+
+  const dbus::ObjectPath& GetObjectPath() const override {
+    return object_path_;
+  }
+
+  dbus::ObjectProxy* GetObjectProxy() const override {
+    return dbus_object_proxy_;
+  }
+
+  void InitializeProperties(
+      const base::RepeatingCallback<void(StandaloneProxyInterface*, const std::string&)>& callback) override {
+    on_property_changed_ = callback;
+    property_set_.reset(
+        new PropertySet(dbus_object_proxy_,
+                        base::BindRepeating(&StandaloneProxy::OnPropertyChanged,
+                                            base::Unretained(this))));
+    property_set_->ConnectSignals();
+    property_set_->GetAll();
+  }
+
+  const PropertySet* GetProperties() const { return &(*property_set_); }
+  PropertySet* GetProperties() { return &(*property_set_); }
+
+  void PingAsync(
+      base::OnceCallback<void()> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override {
+    brillo::dbus_utils::CallMethodWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "org.chromium.Standalone",
+        "Ping",
+        std::move(success_callback),
+        std::move(error_callback));
+  }
+
+  int32_t level() const override {
+    return property_set_->level.value();
+  }
+
+  bool is_level_valid() const override {
+    return property_set_->level.is_valid();
+  }
+
+  void SetLevelChangedCallback(
+      const base::RepeatingCallback<void(int32_t)>& callback) override {
+    on_level_changed_ = callback;
+  }
+
+ private:
+  void OnPropertyChanged(const std::string& property_name) {
+    if (property_name == LevelName() && !on_level_changed_.is_null())
+      on_level_changed_.Run(property_set_->level.value());
+    if (!on_property_changed_.is_null())
+      on_property_changed_.Run(this, property_name);
+  }
+
+  scoped_refptr<dbus::Bus> bus_;
+  const std::string service_name_{"org.chromium.TestService"};
+  const dbus::ObjectPath object_path_{"/org/chromium/Test"};
+  base::RepeatingCallback<void(StandaloneProxyInterface*, const std::string&)> on_property_changed_;
+  base::RepeatingCallback<void(int32_t)> on_level_changed_;
+  dbus::ObjectProxy* dbus_object_proxy_;
+  std::unique_ptr<PropertySet> property_set_;
+
+};
+
+}  // namespace chromium
+}  // namespace org
+
+namespace org {
+namespace chromium {
+namespace TestService {
+
+class ObjectManagerProxy : public dbus::ObjectManager::Interface {
+ public:
+  ObjectManagerProxy(const scoped_refptr<dbus::Bus>& bus)
+      : bus_{bus},
+        dbus_object_manager_{bus->GetObjectManager(
+            "org.chromium.TestService",
+            dbus::ObjectPath{""})} {
+    dbus_object_manager_->RegisterInterface("org.chromium.Managed", this);
+  }
+
+  ObjectManagerProxy(const ObjectManagerProxy&) = delete;
+  ObjectManagerProxy& operator=(const ObjectManagerProxy&) = delete;
+
+  ~ObjectManagerProxy() override {
+    dbus_object_manager_->UnregisterInterface("org.chromium.Managed");
+  }
+
+  dbus::ObjectManager* GetObjectManagerProxy() const {
+    return dbus_object_manager_;
+  }
+
+  // The readable properties of org.chromium.Managed, as returned by
+  // GetManagedObjects().
+  struct ManagedProperties {
+    int32_t level{};
+  };
+
+  // The known interfaces implemented by an object, as returned by
+  // GetManagedObjects(). An interface is set if the object implements it.
+  struct ManagedObject {
+    std::optional<ManagedProperties> managed;
+  };
+
+  // Calls org.freedesktop.DBus.ObjectManager.GetManagedObjects and converts
+  // the result into |objects| keyed by the object paths. The interfaces
+  // unknown to this proxy are ignored, and the missing properties are left
+  // default-initialized.
+  bool GetManagedObjects(
+      std::map<dbus::ObjectPath, ManagedObject>* objects,
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) {
+    std::map<dbus::ObjectPath,
+             std::map<std::string, brillo::VariantDictionary>> managed_objects;
+    auto response = brillo::dbus_utils::CallMethodAndBlockWithTimeout(
+        timeout_ms,
+        bus_->GetObjectProxy("org.chromium.TestService",
+                             dbus::ObjectPath{""}),
+        "org.freedesktop.DBus.ObjectManager",
+        "GetManagedObjects",
+        error);
+    if (!response || !brillo::dbus_utils::ExtractMethodCallResults(
+            response.get(), error, &managed_objects)) {
+      return false;
+    }
+    objects->clear();
+    for (const auto& object : managed_objects) {
+      ManagedObject& managed_object = (*objects)[object.first];
+      for (const auto& itf : object.second) {
+        if (itf.first == "org.chromium.Managed") {
+          ManagedProperties& properties =
+              managed_object.managed.emplace();
+          properties.level =
+              brillo::GetVariantValueOrDefault<int32_t>(
+                  itf.second, "Level");
+          continue;
+        }
+      }
+    }
+    return true;
+  }
+
+  org::chromium::ManagedProxyInterface* GetManagedProxy() {
+    if (managed_instances_.empty())
+      return nullptr;
+    return managed_instances_.begin()->second.get();
+  }
+  std::vector<org::chromium::ManagedProxyInterface*> GetManagedInstances() const {
+    std::vector<org::chromium::ManagedProxyInterface*> values;
+    values.reserve(managed_instances_.size());
+    for (const auto& pair : managed_instances_)
+      values.push_back(pair.second.get());
+    return values;
+  }
+  void SetManagedAddedCallback(
+      const base::RepeatingCallback<void(org::chromium::ManagedProxyInterface*)>& callback) {
+    on_managed_added_ = callback;
+  }
+  void SetManagedRemovedCallback(
+      const base::RepeatingCallback<void(const dbus::ObjectPath&)>& callback) {
+    on_managed_removed_ = callback;
+  }
+  // Runs |callback| with the proxy once the object is added, or with nullptr
+  // if it is not added within |timeout|. |callback| is run immediately if
+  // the object already exists.
+  void WaitForManagedProxy(
+      base::TimeDelta timeout,
+      base::OnceCallback<void(org::chromium::ManagedProxyInterface*)> callback) {
+    auto* proxy = GetManagedProxy();
+    if (proxy) {
+      std::move(callback).Run(proxy);
+      return;
+    }
+    int id = next_waiter_id_++;
+    managed_waiters_.push_back(
+        {id, dbus::ObjectPath(), std::move(callback)});
+    base::SequencedTaskRunner::GetCurrentDefault()->PostDelayedTask(
+        FROM_HERE,
+        base::BindOnce(&ObjectManagerProxy::OnManagedWaitTimeout,
+                       weak_ptr_factory_.GetWeakPtr(), id),
+        timeout);
+  }
+
+ private:
+  // A pending WaitFor...Proxy() call.
+  template <typename T>
+  struct Waiter {
+    int id;
+    dbus::ObjectPath object_path;
+    base::OnceCallback<void(T*)> callback;
+  };
+
+  // Removes the waiter |id| from |waiters| and runs its callback with
+  // nullptr, unless it has been run already.
+  template <typename T>
+  static void TimeOutWaiter(std::vector<Waiter<T>>* waiters, int id) {
+    for (auto it = waiters->begin(); it != waiters->end(); ++it) {
+      if (it->id == id) {
+        auto callback = std::move(it->callback);
+        waiters->erase(it);
+        std::move(callback).Run(nullptr);
+        return;
+      }
+    }
+  }
+
+  // Runs the callbacks of the waiters in |waiters| for the object at
+  // |object_path|, or of all of them if |any_path| is true, with |proxy|.
+  template <typename T>
+  static void NotifyWaiters(std::vector<Waiter<T>>* waiters,
+                            const dbus::ObjectPath& object_path,
+                            bool any_path,
+                            T* proxy) {
+    std::vector<Waiter<T>> pending;
+    pending.swap(*waiters);
+    for (auto& waiter : pending) {
+      if (any_path || waiter.object_path == object_path)
+        std::move(waiter.callback).Run(proxy);
+      else
+        waiters->push_back(std::move(waiter));
+    }
+  }
+
+  void OnManagedWaitTimeout(int id) {
+    TimeOutWaiter(&managed_waiters_, id);
+  }
+
+  void OnPropertyChanged(const dbus::ObjectPath& object_path,
+                         const std::string& interface_name,
+                         const std::string& property_name) {
+    if (interface_name == "org.chromium.Managed") {
+      auto p = managed_instances_.find(object_path);
+      if (p == managed_instances_.end())
+        return;
+      p->second->OnPropertyChanged(property_name);
+      return;
+    }
+  }
+
+  void ObjectAdded(
+      const dbus::ObjectPath& object_path,
+      const std::string& interface_name) override {
+    if (interface_name == "org.chromium.Managed") {
+      auto property_set =
+          static_cast<org::chromium::ManagedProxy::PropertySet*>(
+              dbus_object_manager_->GetProperties(object_path, interface_name));
+      std::unique_ptr<org::chromium::ManagedProxy> managed_proxy{
+        new org::chromium::ManagedProxy{bus_, property_set}
+      };
+      auto p = managed_instances_.emplace(object_path, std::move(managed_proxy));
+      if (!on_managed_added_.is_null())
+        on_managed_added_.Run(p.first->second.get());
+      NotifyWaiters<org::chromium::ManagedProxyInterface>(
+          &managed_waiters_, object_path, true,
+          p.first->second.get());
+      return;
+    }
+  }
+
+  void ObjectRemoved(
+      const dbus::ObjectPath& object_path,
+      const std::string& interface_name) override {
+    if (interface_name == "org.chromium.Managed") {
+      auto p = managed_instances_.find(object_path);
+      if (p != managed_instances_.end()) {
+        if (!on_managed_removed_.is_null())
+          on_managed_removed_.Run(object_path);
+        managed_instances_.erase(p);
+      }
+      return;
+    }
+  }
+
+  dbus::PropertySet* CreateProperties(
+      dbus::ObjectProxy* object_proxy,
+      const dbus::ObjectPath& object_path,
+      const std::string& interface_name) override {
+    if (interface_name == "org.chromium.Managed") {
+      return new org::chromium::ManagedProxy::PropertySet{
+          object_proxy,
+          base::BindRepeating(&ObjectManagerProxy::OnPropertyChanged,
+                              weak_ptr_factory_.GetWeakPtr(),
+                              object_path,
+                              interface_name)
+      };
+    }
+    LOG(FATAL) << "Creating properties for unsupported interface "
+               << interface_name;
+    return nullptr;
+  }
+
+  scoped_refptr<dbus::Bus> bus_;
+  dbus::ObjectManager* dbus_object_manager_;
+  std::map<dbus::ObjectPath,
+           std::unique_ptr<org::chromium::ManagedProxy>> managed_instances_;
+  base::RepeatingCallback<void(org::chromium::ManagedProxyInterface*)> on_managed_added_;
+  base::RepeatingCallback<void(const dbus::ObjectPath&)> on_managed_removed_;
+  std::vector<Waiter<org::chromium::ManagedProxyInterface>> managed_waiters_;
+  int next_waiter_id_ = 0;
+  base::WeakPtrFactory<ObjectManagerProxy> weak_ptr_factory_{this};
+};
+
+}  // namespace TestService
+}  // namespace chromium
+}  // namespace org
+
+#endif  // ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+`
+	if diff := cmp.Diff(out.String(), want); diff != "" {
+		t.Errorf("Generate failed (-got +want):\n%s", diff)
+	}
+}
+
+func TestGenerateProxiesWithPassByMove(t *testing.T) {
+	passByMove := introspect.Annotation{Name: "org.chromium.DBus.Argument.PassByMove", Value: "true"}
+	itf := introspect.Interface{
+		Name: "org.chromium.Test",
+		Methods: []introspect.Method{
+			{
+				Name: "Upload",
+				Args: []introspect.MethodArg{
+					{Name: "name", Type: "s"},
+					{Name: "data", Type: "ay", Annotations: []introspect.Annotation{passByMove}},
+					{Name: "options", Type: "a{sv}", Annotations: []introspect.Annotation{passByMove}},
+					{Name: "id", Type: "i", Direction: "out"},
+				},
+			},
+		},
+	}
+
+	introspections := []introspect.Introspection{{
+		Name:       "/org/chromium/Test",
+		Interfaces: []introspect.Interface{itf},
+	}}
+
+	out := new(bytes.Buffer)
+	sc := serviceconfig.Config{ServiceName: "org.chromium.TestService"}
+	if err := Generate(introspections, out, "/tmp/proxy.h", sc); err != nil {
+		t.Fatalf("Generate got error, want nil: %v", err)
+	}
+
+	const want = `// Automatic generation of D-Bus interfaces:
+//  - org.chromium.Test
+#ifndef ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+#define ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+#include <memory>
+#include <string>
+#include <vector>
+
+#include <base/functional/bind.h>
+#include <base/functional/callback.h>
+#include <base/logging.h>
+#include <base/memory/ref_counted.h>
+#include <brillo/dbus/dbus_method_invoker.h>
+#include <brillo/dbus/dbus_property.h>
+#include <brillo/dbus/dbus_signal_handler.h>
+#include <brillo/errors/error.h>
+#include <brillo/variant_dictionary.h>
+#include <dbus/bus.h>
+#include <dbus/message.h>
+#include <dbus/object_manager.h>
+#include <dbus/object_path.h>
+#include <dbus/object_proxy.h>
+
+namespace org {
+namespace chromium {
+
+// Abstract interface proxy for org::chromium::Test.
+class TestProxyInterface {
+ public:
+  static constexpr char kInterfaceName[] = "org.chromium.Test";
+  static constexpr char kUploadMethod[] = "Upload";
+  static constexpr char kUploadMethodInSignature[] = "saya{sv}";
+  static constexpr char kUploadMethodOutSignature[] = "i";
+
+  virtual ~TestProxyInterface() = default;
+
+  virtual bool Upload(
+      const std::string& in_name,
+      const std::vector<uint8_t>& in_data,
+      const brillo::VariantDictionary& in_options,
+      int32_t* out_id,
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
+
+  virtual void UploadAsync(
+      const std::string& in_name,
+      const std::vector<uint8_t>& in_data,
+      const brillo::VariantDictionary& in_options,
+      base::OnceCallback<void(int32_t /*id*/)> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
+
+  // Calls Upload() with the containers moved from, so that they are
+  // released once the call is sent.
+  bool Upload(
+      const std::string& in_name,
+      std::vector<uint8_t>&& in_data,
+      brillo::VariantDictionary&& in_options,
+      int32_t* out_id,
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) {
+    std::vector<uint8_t> in_data_moved = std::move(in_data);
+    brillo::VariantDictionary in_options_moved = std::move(in_options);
+    return Upload(in_name, in_data_moved, in_options_moved, out_id, error, timeout_ms);
+  }
+
+  // Calls UploadAsync() with the containers moved from, so that they
+  // are released once the call is sent.
+  void UploadAsync(
+      const std::string& in_name,
+      std::vector<uint8_t>&& in_data,
+      brillo::VariantDictionary&& in_options,
+      base::OnceCallback<void(int32_t /*id*/)> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) {
+    std::vector<uint8_t> in_data_moved = std::move(in_data);
+    brillo::VariantDictionary in_options_moved = std::move(in_options);
+    UploadAsync(in_name, in_data_moved, in_options_moved, std::move(success_callback),
+                std::move(error_callback), timeout_ms);
+  }
+
+  virtual const dbus::ObjectPath& GetObjectPath() const = 0;
+  virtual dbus::ObjectProxy* GetObjectProxy() const = 0;
+};
+
+}  // namespace chromium
+}  // namespace org
+
+namespace org {
+namespace chromium {
+
+// Interface proxy for org::chromium::Test.
+class TestProxy final : public TestProxyInterface {
+ public:
+  TestProxy(const scoped_refptr<dbus::Bus>& bus) :
+      bus_{bus},
+      dbus_object_proxy_{
+          bus_->GetObjectProxy(service_name_, object_path_)} {
+  }
+
+  TestProxy(const TestProxy&) = delete;
+  TestProxy& operator=(const TestProxy&) = delete;
+
+  ~TestProxy() override {
+  }
+
+  void ReleaseObjectProxy(base::OnceClosure callback) {
+    bus_->RemoveObjectProxy(service_name_, object_path_, std::move(callback));
+  }
+
+  const dbus::ObjectPath& GetObjectPath() const override {
+    return object_path_;
+  }
+
+  dbus::ObjectProxy* GetObjectProxy() const override {
+    return dbus_object_proxy_;
+  }
+
+  bool Upload(
+      const std::string& in_name,
+      const std::vector<uint8_t>& in_data,
+      const brillo::VariantDictionary& in_options,
+      int32_t* out_id,
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override {
+    auto response = brillo::dbus_utils::CallMethodAndBlockWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "org.chromium.Test",
+        "Upload",
+        error,
+        in_name,
+        in_data,
+        in_options);
+    return response && brillo::dbus_utils::ExtractMethodCallResults(
+        response.get(), error, out_id);
+  }
+
+  void UploadAsync(
+      const std::string& in_name,
+      const std::vector<uint8_t>& in_data,
+      const brillo::VariantDictionary& in_options,
+      base::OnceCallback<void(int32_t /*id*/)> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override {
+    brillo::dbus_utils::CallMethodWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "org.chromium.Test",
+        "Upload",
+        std::move(success_callback),
+        std::move(error_callback),
+        in_name,
+        in_data,
+        in_options);
+  }
+
+  // Unhide the overloads taking the arguments by move.
+  using TestProxyInterface::Upload;
+  using TestProxyInterface::UploadAsync;
+
+ private:
+  scoped_refptr<dbus::Bus> bus_;
+  const std::string service_name_{"org.chromium.TestService"};
+  const dbus::ObjectPath object_path_{"/org/chromium/Test"};
+  dbus::ObjectProxy* dbus_object_proxy_;
+
+};
+
+}  // namespace chromium
+}  // namespace org
+
+#endif  // ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+`
+	if diff := cmp.Diff(out.String(), want); diff != "" {
+		t.Errorf("Generate failed (-got +want):\n%s", diff)
+	}
+}
+
+func TestGenerateProxiesWithUnnamedArgs(t *testing.T) {
+	introspections := []introspect.Introspection{{
+		Name: "/org/chromium/Test",
+		Interfaces: []introspect.Interface{{
+			Name: "org.chromium.Test",
+			Methods: []introspect.Method{{
+				Name: "Scan",
+				Args: []introspect.MethodArg{
+					{Type: "i", Direction: "out"},
+					{Type: "s"},
+					{Name: "flags", Type: "u"},
+				},
+			}},
+		}},
+	}}
+
+	out := new(bytes.Buffer)
+	sc := serviceconfig.Config{ServiceName: "org.chromium.TestService"}
+	if err := Generate(introspections, out, "/tmp/proxy.h", sc); err != nil {
+		t.Fatalf("Generate got error, want nil: %v", err)
+	}
+
+	const want = `// Automatic generation of D-Bus interfaces:
+//  - org.chromium.Test
+#ifndef ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+#define ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+#include <memory>
+#include <string>
+#include <vector>
+
+#include <base/functional/bind.h>
+#include <base/functional/callback.h>
+#include <base/logging.h>
+#include <base/memory/ref_counted.h>
+#include <brillo/dbus/dbus_method_invoker.h>
+#include <brillo/dbus/dbus_property.h>
+#include <brillo/dbus/dbus_signal_handler.h>
+#include <brillo/errors/error.h>
+#include <dbus/bus.h>
+#include <dbus/message.h>
+#include <dbus/object_manager.h>
+#include <dbus/object_path.h>
+#include <dbus/object_proxy.h>
+
+namespace org {
+namespace chromium {
+
+// Abstract interface proxy for org::chromium::Test.
+class TestProxyInterface {
+ public:
+  static constexpr char kInterfaceName[] = "org.chromium.Test";
+  static constexpr char kScanMethod[] = "Scan";
+  static constexpr char kScanMethodInSignature[] = "su";
+  static constexpr char kScanMethodOutSignature[] = "i";
+
+  virtual ~TestProxyInterface() = default;
+
+  // The unnamed arguments are named in_1, out_3.
+  virtual bool Scan(
+      const std::string& in_1,
+      uint32_t in_flags,
+      int32_t* out_3,
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
+
+  virtual void ScanAsync(
+      const std::string& in_1,
+      uint32_t in_flags,
+      base::OnceCallback<void(int32_t)> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
+
+  virtual const dbus::ObjectPath& GetObjectPath() const = 0;
+  virtual dbus::ObjectProxy* GetObjectProxy() const = 0;
+};
+
+}  // namespace chromium
+}  // namespace org
+
+namespace org {
+namespace chromium {
+
+// Interface proxy for org::chromium::Test.
+class TestProxy final : public TestProxyInterface {
+ public:
+  TestProxy(const scoped_refptr<dbus::Bus>& bus) :
+      bus_{bus},
+      dbus_object_proxy_{
+          bus_->GetObjectProxy(service_name_, object_path_)} {
+  }
+
+  TestProxy(const TestProxy&) = delete;
+  TestProxy& operator=(const TestProxy&) = delete;
+
+  ~TestProxy() override {
+  }
+
+  void ReleaseObjectProxy(base::OnceClosure callback) {
+    bus_->RemoveObjectProxy(service_name_, object_path_, std::move(callback));
+  }
+
+  const dbus::ObjectPath& GetObjectPath() const override {
+    return object_path_;
+  }
+
+  dbus::ObjectProxy* GetObjectProxy() const override {
+    return dbus_object_proxy_;
+  }
+
+  bool Scan(
+      const std::string& in_1,
+      uint32_t in_flags,
+      int32_t* out_3,
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override {
+    auto response = brillo::dbus_utils::CallMethodAndBlockWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "org.chromium.Test",
+        "Scan",
+        error,
+        in_1,
+        in_flags);
+    return response && brillo::dbus_utils::ExtractMethodCallResults(
+        response.get(), error, out_3);
+  }
+
+  void ScanAsync(
+      const std::string& in_1,
+      uint32_t in_flags,
+      base::OnceCallback<void(int32_t)> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override {
+    brillo::dbus_utils::CallMethodWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "org.chromium.Test",
+        "Scan",
+        std::move(success_callback),
+        std::move(error_callback),
+        in_1,
+        in_flags);
+  }
+
+ private:
+  scoped_refptr<dbus::Bus> bus_;
+  const std::string service_name_{"org.chromium.TestService"};
+  const dbus::ObjectPath object_path_{"/org/chromium/Test"};
+  dbus::ObjectProxy* dbus_object_proxy_;
+
+};
+
+}  // namespace chromium
+}  // namespace org
+
+#endif  // ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+`
+	if diff := cmp.Diff(out.String(), want); diff != "" {
+		t.Errorf("Generate failed (-got +want):\n%s", diff)
+	}
+}
+
+func TestGenerateProxiesWithSnakeCaseMemberNames(t *testing.T) {
+	introspections := []introspect.Introspection{{
+		Interfaces: []introspect.Interface{{
+			Name:    "test.Itf",
+			Methods: []introspect.Method{{Name: "get_status"}},
+			Signals: []introspect.Signal{{Name: "burn_finished"}},
+		}},
+	}}
+
+	out := new(bytes.Buffer)
+	if err := Generate(introspections, out, "/tmp/proxy.h", serviceconfig.Config{}); err != nil {
+		t.Fatalf("Generate got error, want nil: %v", err)
+	}
+
+	const want = `// Automatic generation of D-Bus interfaces:
+//  - test.Itf
+#ifndef ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+#define ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+#include <memory>
+#include <string>
+#include <vector>
+
+#include <base/functional/bind.h>
+#include <base/functional/callback.h>
+#include <base/logging.h>
+#include <base/memory/ref_counted.h>
+#include <base/memory/weak_ptr.h>
+#include <brillo/dbus/dbus_method_invoker.h>
+#include <brillo/dbus/dbus_property.h>
+#include <brillo/dbus/dbus_signal_handler.h>
+#include <brillo/errors/error.h>
+#include <dbus/bus.h>
+#include <dbus/message.h>
+#include <dbus/object_manager.h>
+#include <dbus/object_path.h>
+#include <dbus/object_proxy.h>
+
+namespace test {
+
+// Abstract interface proxy for test::Itf.
+class ItfProxyInterface {
+ public:
+  static constexpr char kInterfaceName[] = "test.Itf";
+  static constexpr char kGetStatusMethod[] = "get_status";
+  static constexpr char kGetStatusMethodInSignature[] = "";
+  static constexpr char kGetStatusMethodOutSignature[] = "";
+  static constexpr char kBurnFinishedSignal[] = "burn_finished";
+  static constexpr char kBurnFinishedSignalSignature[] = "";
+
+  using burn_finishedSignalCallback =
+      base::RepeatingClosure;
+
+  virtual ~ItfProxyInterface() = default;
+
+  virtual bool get_status(
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
+
+  virtual void get_statusAsync(
+      base::OnceCallback<void()> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
+
+  virtual void Registerburn_finishedSignalHandler(
+      base::RepeatingClosure signal_callback,
+      dbus::ObjectProxy::OnConnectedCallback on_connected_callback) = 0;
+
+  // Registers |method| of |target| as the handler of the burn_finished signal.
+  // The signals delivered after |target| is invalidated are dropped.
+  template <typename T>
+  void Registerburn_finishedSignalHandlerWeak(
+      base::WeakPtr<T> target,
+      void (T::*method)(),
+      dbus::ObjectProxy::OnConnectedCallback on_connected_callback) {
+    Registerburn_finishedSignalHandler(
+        base::BindRepeating(method, std::move(target)),
+        std::move(on_connected_callback));
+  }
+
+  virtual const dbus::ObjectPath& GetObjectPath() const = 0;
+  virtual dbus::ObjectProxy* GetObjectProxy() const = 0;
+};
+
+}  // namespace test
+
+namespace test {
+
+// Interface proxy for test::Itf.
+class ItfProxy final : public ItfProxyInterface {
+ public:
+  ItfProxy(
+      const scoped_refptr<dbus::Bus>& bus,
+      const std::string& service_name,
+      const dbus::ObjectPath& object_path) :
+          bus_{bus},
+          service_name_{service_name},
+          object_path_{object_path},
+          dbus_object_proxy_{
+              bus_->GetObjectProxy(service_name_, object_path_)} {
+  }
+
+  ItfProxy(const ItfProxy&) = delete;
+  ItfProxy& operator=(const ItfProxy&) = delete;
+
+  ~ItfProxy() override {
+  }
+
+  void Registerburn_finishedSignalHandler(
+      base::RepeatingClosure signal_callback,
+      dbus::ObjectProxy::OnConnectedCallback on_connected_callback) override {
+    brillo::dbus_utils::ConnectToSignal(
+        dbus_object_proxy_,
+        "test.Itf",
+        "burn_finished",
+        signal_callback,
+        std::move(on_connected_callback));
+  }
+
+  void ReleaseObjectProxy(base::OnceClosure callback) {
+    bus_->RemoveObjectProxy(service_name_, object_path_, std::move(callback));
+  }
+
+  const dbus::ObjectPath& GetObjectPath() const override {
+    return object_path_;
+  }
+
+  dbus::ObjectProxy* GetObjectProxy() const override {
+    return dbus_object_proxy_;
+  }
+
+  bool get_status(
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override {
+    auto response = brillo::dbus_utils::CallMethodAndBlockWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "test.Itf",
+        "get_status",
+        error);
+    return response && brillo::dbus_utils::ExtractMethodCallResults(
+        response.get(), error);
+  }
+
+  void get_statusAsync(
+      base::OnceCallback<void()> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override {
+    brillo::dbus_utils::CallMethodWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "test.Itf",
+        "get_status",
+        std::move(success_callback),
+        std::move(error_callback));
+  }
+
+ private:
+  scoped_refptr<dbus::Bus> bus_;
+  std::string service_name_;
+  dbus::ObjectPath object_path_;
+  dbus::ObjectProxy* dbus_object_proxy_;
+
+};
+
+}  // namespace test
+
+#endif  // ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+`
+
+	if diff := cmp.Diff(out.String(), want); diff != "" {
+		t.Errorf("Generate failed (-got +want):\n%s", diff)
+	}
+}
+
+func TestGenerateProxiesWithCollidingConstantNames(t *testing.T) {
+	introspections := []introspect.Introspection{{
+		Interfaces: []introspect.Interface{{
+			Name:    "test.Itf",
+			Methods: []introspect.Method{{Name: "scan"}, {Name: "Scan"}},
+		}},
+	}}
+
+	out := new(bytes.Buffer)
+	if err := Generate(introspections, out, "/tmp/proxy.h", serviceconfig.Config{}); err == nil {
+		t.Error("Generate with methods mapped to the same constant succeeded unexpectedly")
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright 2022 The ChromiumOS Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package proxy
+
+import (
+	"go.chromium.org/chromiumos/dbusbindings/introspect"
+	"go.chromium.org/chromiumos/dbusbindings/serviceconfig"
+)
+
+const queueingProxyTemplate = `{{define "queueingProxy"}}{{with $itf := .Itf -}}
+{{- $itfName := makeProxyInterfaceName .Name}}
+{{- $className := printf "%sQueueingProxy" (makeTypeName .Name)}}
+{{range extractNameSpaces .Name -}}
+namespace {{.}} {
+{{end}}
+// Wraps a proxy for {{makeFullItfName .Name}}, queueing the asynchronous
+// method calls made before the service is available and sending them once it
+// is. The wrapped proxy must outlive the wrapper.
+class {{$className}} {
+ public:
+  explicit {{$className}}({{$itfName}}* proxy) : proxy_{proxy} {
+    proxy_->GetObjectProxy()->WaitForServiceToBeAvailable(
+        base::BindOnce(&{{$className}}::OnServiceAvailable,
+                       weak_ptr_factory_.GetWeakPtr()));
+  }
+
+  {{$className}}(const {{$className}}&) = delete;
+  {{$className}}& operator=(const {{$className}}&) = delete;
+
+  {{$itfName}}* proxy() const { return proxy_; }
+
+  // Returns true while the calls are queued, i.e. until the service is
+  // available or waiting for it fails.
+  bool is_waiting() const { return waiting_; }
+{{- range .Methods}}
+{{- $inParams := makeMethodParams $.NamingStyle 0 .InputArguments}}
+{{- $callbackType := makeMethodCallbackType $.NamingStyle $.MoveProtobufResponses .OutputArguments}}
+
+  void {{.Name}}Async(
+{{- range $inParams}}
+      {{.Type}} {{.Name}},
+{{- end}}
+      {{$callbackType}} success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) {
+{{- if hasFileDescriptorInput .}}
+    // File descriptors are move-only and cannot be kept in the queue.
+{{- else}}
+    if (waiting_) {
+      pending_calls_.push_back(base::BindOnce(
+          []({{$itfName}}* proxy,
+{{- range $inParams}}
+             {{.Type}} {{.Name}},
+{{- end}}
+             int timeout_ms,
+             {{$callbackType}} success_callback,
+             base::OnceCallback<void(brillo::Error*)> error_callback) {
+            proxy->{{.Name}}Async({{range $inParams}}{{.Name}}, {{end}}std::move(success_callback),
+                   {{repeat " " (len .Name)}}      std::move(error_callback), timeout_ms);
+          },
+          base::Unretained(proxy_), {{range $inParams}}{{.Name}}, {{end}}timeout_ms,
+          std::move(success_callback), std::move(error_callback)));
+      return;
+    }
+{{- end}}
+    proxy_->{{.Name}}Async({{range $inParams}}{{.Name}}, {{end}}std::move(success_callback),
+                  {{repeat " " (len .Name)}}std::move(error_callback), timeout_ms);
+  }
+{{- end}}
+
+ private:
+  // Sends the queued calls. If waiting for the service failed, they are sent
+  // anyway and fail with the D-Bus errors of the unavailable service.
+  void OnServiceAvailable(bool available) {
+    LOG_IF(WARNING, !available)
+        << "Failed to wait for the service of {{.Name}}; sending "
+        << pending_calls_.size() << " queued calls anyway";
+    waiting_ = false;
+    std::vector<base::OnceClosure> calls = std::move(pending_calls_);
+    for (auto& call : calls)
+      std::move(call).Run();
+  }
+
+  {{$itfName}}* proxy_;
+  bool waiting_ = true;
+  std::vector<base::OnceClosure> pending_calls_;
+  base::WeakPtrFactory<{{$className}}> weak_ptr_factory_{this};
+};
+
+{{range extractNameSpaces .Name | reverse -}}
+}  // namespace {{.}}
+{{end}}
+{{- end}}{{end}}`
+
+// queueingProxyArgs is the data passed to the "queueingProxy" template,
+// which generates the queueing wrapper for a single interface.
+type queueingProxyArgs struct {
+	Itf                   introspect.Interface
+	NamingStyle           serviceconfig.NamingStyle
+	MoveProtobufResponses bool
+}
@@ -0,0 +1,312 @@
+// Copyright 2022 The ChromiumOS Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package proxy
+
+import (
+	"bytes"
+	"testing"
+
+	"go.chromium.org/chromiumos/dbusbindings/introspect"
+	"go.chromium.org/chromiumos/dbusbindings/serviceconfig"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGenerateProxiesWithQueueingProxy(t *testing.T) {
+	introspections := []introspect.Introspection{{
+		Name: "/org/chromium/Test",
+		Interfaces: []introspect.Interface{{
+			Name: "org.chromium.Test",
+			Methods: []introspect.Method{
+				{
+					Name: "GetStatus",
+					Args: []introspect.MethodArg{
+						{Name: "verbose", Type: "b"},
+						{Name: "name", Type: "s"},
+						{Name: "status", Type: "s", Direction: "out"},
+					},
+				}, {
+					Name: "SendFd",
+					Args: []introspect.MethodArg{
+						{Name: "fd", Type: "h"},
+					},
+				},
+			},
+		}},
+	}}
+
+	sc := serviceconfig.Config{
+		ServiceName:   "org.chromium.TestService",
+		QueueingProxy: true,
+	}
+	out := new(bytes.Buffer)
+	if err := Generate(introspections, out, "/tmp/proxy.h", sc); err != nil {
+		t.Fatalf("Generate got error, want nil: %v", err)
+	}
+
+	const want = `// Automatic generation of D-Bus interfaces:
+//  - org.chromium.Test
+#ifndef ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+#define ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+#include <memory>
+#include <string>
+#include <vector>
+
+#include <base/files/scoped_file.h>
+#include <base/functional/bind.h>
+#include <base/functional/callback.h>
+#include <base/logging.h>
+#include <base/memory/ref_counted.h>
+#include <base/memory/weak_ptr.h>
+#include <brillo/dbus/dbus_method_invoker.h>
+#include <brillo/dbus/dbus_property.h>
+#include <brillo/dbus/dbus_signal_handler.h>
+#include <brillo/errors/error.h>
+#include <dbus/bus.h>
+#include <dbus/message.h>
+#include <dbus/object_manager.h>
+#include <dbus/object_path.h>
+#include <dbus/object_proxy.h>
+
+namespace org {
+namespace chromium {
+
+// Abstract interface proxy for org::chromium::Test.
+class TestProxyInterface {
+ public:
+  static constexpr char kInterfaceName[] = "org.chromium.Test";
+  static constexpr char kGetStatusMethod[] = "GetStatus";
+  static constexpr char kGetStatusMethodInSignature[] = "bs";
+  static constexpr char kGetStatusMethodOutSignature[] = "s";
+  static constexpr char kSendFdMethod[] = "SendFd";
+  static constexpr char kSendFdMethodInSignature[] = "h";
+  static constexpr char kSendFdMethodOutSignature[] = "";
+
+  virtual ~TestProxyInterface() = default;
+
+  virtual bool GetStatus(
+      bool in_verbose,
+      const std::string& in_name,
+      std::string* out_status,
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
+
+  virtual void GetStatusAsync(
+      bool in_verbose,
+      const std::string& in_name,
+      base::OnceCallback<void(const std::string& /*status*/)> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
+
+  virtual bool SendFd(
+      const base::ScopedFD& in_fd,
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
+
+  virtual void SendFdAsync(
+      const base::ScopedFD& in_fd,
+      base::OnceCallback<void()> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
+
+  virtual const dbus::ObjectPath& GetObjectPath() const = 0;
+  virtual dbus::ObjectProxy* GetObjectProxy() const = 0;
+};
+
+}  // namespace chromium
+}  // namespace org
+
+namespace org {
+namespace chromium {
+
+// Interface proxy for org::chromium::Test.
+class TestProxy final : public TestProxyInterface {
+ public:
+  TestProxy(const scoped_refptr<dbus::Bus>& bus) :
+      bus_{bus},
+      dbus_object_proxy_{
+          bus_->GetObjectProxy(service_name_, object_path_)} {
+  }
+
+  TestProxy(const TestProxy&) = delete;
+  TestProxy& operator=(const TestProxy&) = delete;
+
+  ~TestProxy() override {
+  }
+
+  void ReleaseObjectProxy(base::OnceClosure callback) {
+    bus_->RemoveObjectProxy(service_name_, object_path_, std::move(callback));
+  }
+
+  const dbus::ObjectPath& GetObjectPath() const override {
+    return object_path_;
+  }
+
+  dbus::ObjectProxy* GetObjectProxy() const override {
+    return dbus_object_proxy_;
+  }
+
+  bool GetStatus(
+      bool in_verbose,
+      const std::string& in_name,
+      std::string* out_status,
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override {
+    auto response = brillo::dbus_utils::CallMethodAndBlockWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "org.chromium.Test",
+        "GetStatus",
+        error,
+        in_verbose,
+        in_name);
+    return response && brillo::dbus_utils::ExtractMethodCallResults(
+        response.get(), error, out_status);
+  }
+
+  void GetStatusAsync(
+      bool in_verbose,
+      const std::string& in_name,
+      base::OnceCallback<void(const std::string& /*status*/)> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override {
+    brillo::dbus_utils::CallMethodWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "org.chromium.Test",
+        "GetStatus",
+        std::move(success_callback),
+        std::move(error_callback),
+        in_verbose,
+        in_name);
+  }
+
+  bool SendFd(
+      const base::ScopedFD& in_fd,
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override {
+    auto response = brillo::dbus_utils::CallMethodAndBlockWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "org.chromium.Test",
+        "SendFd",
+        error,
+        in_fd);
+    return response && brillo::dbus_utils::ExtractMethodCallResults(
+        response.get(), error);
+  }
+
+  void SendFdAsync(
+      const base::ScopedFD& in_fd,
+      base::OnceCallback<void()> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override {
+    brillo::dbus_utils::CallMethodWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "org.chromium.Test",
+        "SendFd",
+        std::move(success_callback),
+        std::move(error_callback),
+        in_fd);
+  }
+
+ private:
+  scoped_refptr<dbus::Bus> bus_;
+  const std::string service_name_{"org.chromium.TestService"};
+  const dbus::ObjectPath object_path_{"/org/chromium/Test"};
+  dbus::ObjectProxy* dbus_object_proxy_;
+
+};
+
+}  // namespace chromium
+}  // namespace org
+
+namespace org {
+namespace chromium {
+
+// Wraps a proxy for org::chromium::Test, queueing the asynchronous
+// method calls made before the service is available and sending them once it
+// is. The wrapped proxy must outlive the wrapper.
+class TestQueueingProxy {
+ public:
+  explicit TestQueueingProxy(TestProxyInterface* proxy) : proxy_{proxy} {
+    proxy_->GetObjectProxy()->WaitForServiceToBeAvailable(
+        base::BindOnce(&TestQueueingProxy::OnServiceAvailable,
+                       weak_ptr_factory_.GetWeakPtr()));
+  }
+
+  TestQueueingProxy(const TestQueueingProxy&) = delete;
+  TestQueueingProxy& operator=(const TestQueueingProxy&) = delete;
+
+  TestProxyInterface* proxy() const { return proxy_; }
+
+  // Returns true while the calls are queued, i.e. until the service is
+  // available or waiting for it fails.
+  bool is_waiting() const { return waiting_; }
+
+  void GetStatusAsync(
+      bool in_verbose,
+      const std::string& in_name,
+      base::OnceCallback<void(const std::string& /*status*/)> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) {
+    if (waiting_) {
+      pending_calls_.push_back(base::BindOnce(
+          [](TestProxyInterface* proxy,
+             bool in_verbose,
+             const std::string& in_name,
+             int timeout_ms,
+             base::OnceCallback<void(const std::string& /*status*/)> success_callback,
+             base::OnceCallback<void(brillo::Error*)> error_callback) {
+            proxy->GetStatusAsync(in_verbose, in_name, std::move(success_callback),
+                                  std::move(error_callback), timeout_ms);
+          },
+          base::Unretained(proxy_), in_verbose, in_name, timeout_ms,
+          std::move(success_callback), std::move(error_callback)));
+      return;
+    }
+    proxy_->GetStatusAsync(in_verbose, in_name, std::move(success_callback),
+                           std::move(error_callback), timeout_ms);
+  }
+
+  void SendFdAsync(
+      const base::ScopedFD& in_fd,
+      base::OnceCallback<void()> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) {
+    // File descriptors are move-only and cannot be kept in the queue.
+    proxy_->SendFdAsync(in_fd, std::move(success_callback),
+                        std::move(error_callback), timeout_ms);
+  }
+
+ private:
+  // Sends the queued calls. If waiting for the service failed, they are sent
+  // anyway and fail with the D-Bus errors of the unavailable service.
+  void OnServiceAvailable(bool available) {
+    LOG_IF(WARNING, !available)
+        << "Failed to wait for the service of org.chromium.Test; sending "
+        << pending_calls_.size() << " queued calls anyway";
+    waiting_ = false;
+    std::vector<base::OnceClosure> calls = std::move(pending_calls_);
+    for (auto& call : calls)
+      std::move(call).Run();
+  }
+
+  TestProxyInterface* proxy_;
+  bool waiting_ = true;
+  std::vector<base::OnceClosure> pending_calls_;
+  base::WeakPtrFactory<TestQueueingProxy> weak_ptr_factory_{this};
+};
+
+}  // namespace chromium
+}  // namespace org
+
+#endif  // ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+`
+	if diff := cmp.Diff(out.String(), want); diff != "" {
+		t.Errorf("Generate failed (-got +want):\n%s", diff)
+	}
+}
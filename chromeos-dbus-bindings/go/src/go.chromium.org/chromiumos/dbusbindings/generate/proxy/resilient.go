@@ -0,0 +1,228 @@
+// Copyright 2022 The ChromiumOS Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package proxy
+
+import (
+	"strings"
+
+	"go.chromium.org/chromiumos/dbusbindings/introspect"
+	"go.chromium.org/chromiumos/dbusbindings/serviceconfig"
+)
+
+// retryTemplate defines the helpers retrying the method calls of the
+// ...ResilientProxy classes. It is guarded so that multiple generated headers
+// can define it.
+const retryTemplate = `{{define "retry" -}}
+#ifndef CHROMEOS_DBUS_BINDINGS_DBUS_RETRY_
+#define CHROMEOS_DBUS_BINDINGS_DBUS_RETRY_
+namespace chromeos_dbus_bindings {
+
+// Backoff of the retried method calls. The delay between attempts starts at
+// |initial_backoff| and is doubled after each retry up to |max_backoff|.
+struct RetryPolicy {
+  int max_attempts;
+  base::TimeDelta initial_backoff;
+  base::TimeDelta max_backoff;
+};
+
+// Returns true if |error| is a D-Bus failure after which the call may
+// succeed, i.e. the service did not reply or is being restarted.
+inline bool IsTransientDBusError(const brillo::Error* error) {
+  return error && error->GetDomain() == brillo::errors::dbus::kDomain &&
+         (error->GetCode() == "org.freedesktop.DBus.Error.NoReply" ||
+          error->GetCode() == "org.freedesktop.DBus.Error.ServiceUnknown");
+}
+
+// Runs |call| until it succeeds, fails with a non-transient error or
+// |policy.max_attempts| is reached, blocking for the backoff between
+// attempts. |error| is set to the error of the last attempt on failure.
+inline bool CallAndBlockWithRetry(
+    const RetryPolicy& policy,
+    brillo::ErrorPtr* error,
+    base::FunctionRef<bool(brillo::ErrorPtr*)> call) {
+  base::TimeDelta backoff = policy.initial_backoff;
+  for (int attempt = 1;; ++attempt) {
+    brillo::ErrorPtr attempt_error;
+    if (call(&attempt_error))
+      return true;
+    if (attempt >= policy.max_attempts ||
+        !IsTransientDBusError(attempt_error.get())) {
+      if (error)
+        *error = std::move(attempt_error);
+      return false;
+    }
+    base::PlatformThread::Sleep(backoff);
+    backoff = std::min(backoff * 2, policy.max_backoff);
+  }
+}
+
+// Asynchronous counterpart of CallAndBlockWithRetry(). The retries are posted
+// to the current sequence, and only one of the callbacks is run at the end.
+template <typename... Ts>
+class AsyncCallWithRetry {
+ public:
+  using Call = base::RepeatingCallback<void(
+      base::OnceCallback<void(Ts...)>,
+      base::OnceCallback<void(brillo::Error*)>)>;
+
+  static void Start(const RetryPolicy& policy,
+                    Call call,
+                    base::OnceCallback<void(Ts...)> success_callback,
+                    base::OnceCallback<void(brillo::Error*)> error_callback) {
+    auto state = std::make_shared<State>(State{
+        policy, std::move(call), std::move(success_callback),
+        std::move(error_callback), 0, policy.initial_backoff});
+    Attempt(state);
+  }
+
+ private:
+  struct State {
+    RetryPolicy policy;
+    Call call;
+    base::OnceCallback<void(Ts...)> success_callback;
+    base::OnceCallback<void(brillo::Error*)> error_callback;
+    int attempt;
+    base::TimeDelta backoff;
+  };
+
+  static void Attempt(const std::shared_ptr<State>& state) {
+    ++state->attempt;
+    state->call.Run(base::BindOnce(&AsyncCallWithRetry::OnSuccess, state),
+                    base::BindOnce(&AsyncCallWithRetry::OnError, state));
+  }
+  static void OnSuccess(const std::shared_ptr<State>& state, Ts... values) {
+    std::move(state->success_callback).Run(std::forward<Ts>(values)...);
+  }
+  static void OnError(const std::shared_ptr<State>& state,
+                      brillo::Error* error) {
+    if (state->attempt >= state->policy.max_attempts ||
+        !IsTransientDBusError(error)) {
+      std::move(state->error_callback).Run(error);
+      return;
+    }
+    base::SequencedTaskRunner::GetCurrentDefault()->PostDelayedTask(
+        FROM_HERE, base::BindOnce(&AsyncCallWithRetry::Attempt, state),
+        state->backoff);
+    state->backoff = std::min(state->backoff * 2, state->policy.max_backoff);
+  }
+};
+
+template <typename... Ts>
+void CallWithRetry(
+    const RetryPolicy& policy,
+    typename AsyncCallWithRetry<Ts...>::Call call,
+    base::OnceCallback<void(Ts...)> success_callback,
+    base::OnceCallback<void(brillo::Error*)> error_callback) {
+  AsyncCallWithRetry<Ts...>::Start(policy, std::move(call),
+                                   std::move(success_callback),
+                                   std::move(error_callback));
+}
+
+}  // namespace chromeos_dbus_bindings
+#endif  // CHROMEOS_DBUS_BINDINGS_DBUS_RETRY_
+{{- end}}`
+
+const resilientProxyTemplate = `{{define "resilientProxy"}}{{with $itf := .Itf -}}
+{{- $itfName := makeProxyInterfaceName .Name}}
+{{- $className := printf "%sResilientProxy" (makeTypeName .Name)}}
+{{range extractNameSpaces .Name -}}
+namespace {{.}} {
+{{end}}
+// Wraps a proxy for {{makeFullItfName .Name}}, retrying the method calls which
+// fail with transient D-Bus errors. The wrapped proxy must outlive the pending
+// asynchronous calls.
+class {{$className}} {
+ public:
+  static constexpr chromeos_dbus_bindings::RetryPolicy kDefaultRetryPolicy{
+      {{$.Policy.MaxAttempts}}, base::Milliseconds({{$.Policy.InitialBackoffMs}}), base::Milliseconds({{$.Policy.MaxBackoffMs}})};
+
+  explicit {{$className}}(
+      {{$itfName}}* proxy,
+      const chromeos_dbus_bindings::RetryPolicy& policy = kDefaultRetryPolicy)
+      : proxy_{proxy}, policy_{policy} {}
+
+  {{$className}}(const {{$className}}&) = delete;
+  {{$className}}& operator=(const {{$className}}&) = delete;
+
+  {{$itfName}}* proxy() const { return proxy_; }
+{{- range .Methods}}
+{{- $inParams := makeMethodParams $.NamingStyle 0 .InputArguments -}}
+{{- $outParams := makeMethodParams $.NamingStyle (len .InputArguments) .OutputArguments}}
+{{- $callbackType := makeMethodCallbackType $.NamingStyle $.MoveProtobufResponses .OutputArguments}}
+{{- if not $.DisableBlockingCalls}}
+
+  bool {{.Name}}(
+{{- range $inParams }}
+      {{.Type}} {{.Name}},
+{{- end}}
+{{- range $outParams }}
+      {{.Type}} {{.Name}},
+{{- end}}
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) {
+    return chromeos_dbus_bindings::CallAndBlockWithRetry(
+        policy_, error, [&](brillo::ErrorPtr* attempt_error) {
+          return proxy_->{{.Name}}({{range $inParams}}{{.Name}}, {{end}}{{range $outParams}}{{.Name}}, {{end}}attempt_error, timeout_ms);
+        });
+  }
+{{- end}}
+
+  void {{.Name}}Async(
+{{- range $inParams}}
+      {{.Type}} {{.Name}},
+{{- end}}
+      {{$callbackType}} success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) {
+{{- if hasFileDescriptorInput .}}
+    // File descriptors are move-only and cannot be kept for the retries.
+    proxy_->{{.Name}}Async({{range $inParams}}{{.Name}}, {{end}}std::move(success_callback),
+                  {{repeat " " (len .Name)}}std::move(error_callback), timeout_ms);
+{{- else}}
+    chromeos_dbus_bindings::CallWithRetry(
+        policy_,
+        base::BindRepeating(
+            []({{$itfName}}* proxy,
+{{- range $inParams}}
+               {{.Type}} {{.Name}},
+{{- end}}
+               int timeout_ms,
+               {{$callbackType}} success_callback,
+               base::OnceCallback<void(brillo::Error*)> error_callback) {
+              proxy->{{.Name}}Async({{range $inParams}}{{.Name}}, {{end}}std::move(success_callback),
+                     {{repeat " " (len .Name)}}      std::move(error_callback), timeout_ms);
+            },
+            base::Unretained(proxy_), {{range $inParams}}{{.Name}}, {{end}}timeout_ms),
+        std::move(success_callback), std::move(error_callback));
+{{- end}}
+  }
+{{- end}}
+
+ private:
+  {{$itfName}}* proxy_;
+  chromeos_dbus_bindings::RetryPolicy policy_;
+};
+
+{{range extractNameSpaces .Name | reverse -}}
+}  // namespace {{.}}
+{{end}}
+{{- end}}{{end}}`
+
+// resilientProxyArgs is the data passed to the "resilientProxy" template,
+// which generates the retrying wrapper for a single interface.
+type resilientProxyArgs struct {
+	Itf                   introspect.Interface
+	NamingStyle           serviceconfig.NamingStyle
+	MoveProtobufResponses bool
+	DisableBlockingCalls  bool
+	Policy                *serviceconfig.ResilientProxyConfig
+}
+
+// hasFileDescriptorInput returns true if any input argument of m contains a
+// file descriptor, whose C++ type is move-only, so that the asynchronous call
+// cannot be retried.
+func hasFileDescriptorInput(m introspect.Method) bool {
+	return strings.Contains(m.InputSignature(), "h")
+}
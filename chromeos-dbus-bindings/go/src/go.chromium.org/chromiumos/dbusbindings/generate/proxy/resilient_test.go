@@ -0,0 +1,423 @@
+// Copyright 2022 The ChromiumOS Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package proxy
+
+import (
+	"bytes"
+	"testing"
+
+	"go.chromium.org/chromiumos/dbusbindings/introspect"
+	"go.chromium.org/chromiumos/dbusbindings/serviceconfig"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGenerateProxiesWithResilientProxy(t *testing.T) {
+	introspections := []introspect.Introspection{{
+		Name: "/org/chromium/Test",
+		Interfaces: []introspect.Interface{{
+			Name: "org.chromium.Test",
+			Methods: []introspect.Method{
+				{
+					Name: "GetStatus",
+					Args: []introspect.MethodArg{
+						{Name: "verbose", Type: "b"},
+						{Name: "status", Type: "s", Direction: "out"},
+					},
+				}, {
+					Name: "SendFd",
+					Args: []introspect.MethodArg{
+						{Name: "fd", Type: "h"},
+					},
+				},
+			},
+		}},
+	}}
+
+	sc := serviceconfig.Config{
+		ServiceName: "org.chromium.TestService",
+		ResilientProxy: &serviceconfig.ResilientProxyConfig{
+			MaxAttempts:      3,
+			InitialBackoffMs: 100,
+			MaxBackoffMs:     5000,
+		},
+	}
+	out := new(bytes.Buffer)
+	if err := Generate(introspections, out, "/tmp/proxy.h", sc); err != nil {
+		t.Fatalf("Generate got error, want nil: %v", err)
+	}
+
+	const want = `// Automatic generation of D-Bus interfaces:
+//  - org.chromium.Test
+#ifndef ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+#define ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+#include <algorithm>
+#include <memory>
+#include <string>
+#include <vector>
+
+#include <base/files/scoped_file.h>
+#include <base/functional/bind.h>
+#include <base/functional/callback.h>
+#include <base/functional/function_ref.h>
+#include <base/location.h>
+#include <base/logging.h>
+#include <base/memory/ref_counted.h>
+#include <base/task/sequenced_task_runner.h>
+#include <base/threading/platform_thread.h>
+#include <base/time/time.h>
+#include <brillo/dbus/dbus_method_invoker.h>
+#include <brillo/dbus/dbus_property.h>
+#include <brillo/dbus/dbus_signal_handler.h>
+#include <brillo/errors/error.h>
+#include <brillo/errors/error_codes.h>
+#include <dbus/bus.h>
+#include <dbus/message.h>
+#include <dbus/object_manager.h>
+#include <dbus/object_path.h>
+#include <dbus/object_proxy.h>
+
+#ifndef CHROMEOS_DBUS_BINDINGS_DBUS_RETRY_
+#define CHROMEOS_DBUS_BINDINGS_DBUS_RETRY_
+namespace chromeos_dbus_bindings {
+
+// Backoff of the retried method calls. The delay between attempts starts at
+// |initial_backoff| and is doubled after each retry up to |max_backoff|.
+struct RetryPolicy {
+  int max_attempts;
+  base::TimeDelta initial_backoff;
+  base::TimeDelta max_backoff;
+};
+
+// Returns true if |error| is a D-Bus failure after which the call may
+// succeed, i.e. the service did not reply or is being restarted.
+inline bool IsTransientDBusError(const brillo::Error* error) {
+  return error && error->GetDomain() == brillo::errors::dbus::kDomain &&
+         (error->GetCode() == "org.freedesktop.DBus.Error.NoReply" ||
+          error->GetCode() == "org.freedesktop.DBus.Error.ServiceUnknown");
+}
+
+// Runs |call| until it succeeds, fails with a non-transient error or
+// |policy.max_attempts| is reached, blocking for the backoff between
+// attempts. |error| is set to the error of the last attempt on failure.
+inline bool CallAndBlockWithRetry(
+    const RetryPolicy& policy,
+    brillo::ErrorPtr* error,
+    base::FunctionRef<bool(brillo::ErrorPtr*)> call) {
+  base::TimeDelta backoff = policy.initial_backoff;
+  for (int attempt = 1;; ++attempt) {
+    brillo::ErrorPtr attempt_error;
+    if (call(&attempt_error))
+      return true;
+    if (attempt >= policy.max_attempts ||
+        !IsTransientDBusError(attempt_error.get())) {
+      if (error)
+        *error = std::move(attempt_error);
+      return false;
+    }
+    base::PlatformThread::Sleep(backoff);
+    backoff = std::min(backoff * 2, policy.max_backoff);
+  }
+}
+
+// Asynchronous counterpart of CallAndBlockWithRetry(). The retries are posted
+// to the current sequence, and only one of the callbacks is run at the end.
+template <typename... Ts>
+class AsyncCallWithRetry {
+ public:
+  using Call = base::RepeatingCallback<void(
+      base::OnceCallback<void(Ts...)>,
+      base::OnceCallback<void(brillo::Error*)>)>;
+
+  static void Start(const RetryPolicy& policy,
+                    Call call,
+                    base::OnceCallback<void(Ts...)> success_callback,
+                    base::OnceCallback<void(brillo::Error*)> error_callback) {
+    auto state = std::make_shared<State>(State{
+        policy, std::move(call), std::move(success_callback),
+        std::move(error_callback), 0, policy.initial_backoff});
+    Attempt(state);
+  }
+
+ private:
+  struct State {
+    RetryPolicy policy;
+    Call call;
+    base::OnceCallback<void(Ts...)> success_callback;
+    base::OnceCallback<void(brillo::Error*)> error_callback;
+    int attempt;
+    base::TimeDelta backoff;
+  };
+
+  static void Attempt(const std::shared_ptr<State>& state) {
+    ++state->attempt;
+    state->call.Run(base::BindOnce(&AsyncCallWithRetry::OnSuccess, state),
+                    base::BindOnce(&AsyncCallWithRetry::OnError, state));
+  }
+  static void OnSuccess(const std::shared_ptr<State>& state, Ts... values) {
+    std::move(state->success_callback).Run(std::forward<Ts>(values)...);
+  }
+  static void OnError(const std::shared_ptr<State>& state,
+                      brillo::Error* error) {
+    if (state->attempt >= state->policy.max_attempts ||
+        !IsTransientDBusError(error)) {
+      std::move(state->error_callback).Run(error);
+      return;
+    }
+    base::SequencedTaskRunner::GetCurrentDefault()->PostDelayedTask(
+        FROM_HERE, base::BindOnce(&AsyncCallWithRetry::Attempt, state),
+        state->backoff);
+    state->backoff = std::min(state->backoff * 2, state->policy.max_backoff);
+  }
+};
+
+template <typename... Ts>
+void CallWithRetry(
+    const RetryPolicy& policy,
+    typename AsyncCallWithRetry<Ts...>::Call call,
+    base::OnceCallback<void(Ts...)> success_callback,
+    base::OnceCallback<void(brillo::Error*)> error_callback) {
+  AsyncCallWithRetry<Ts...>::Start(policy, std::move(call),
+                                   std::move(success_callback),
+                                   std::move(error_callback));
+}
+
+}  // namespace chromeos_dbus_bindings
+#endif  // CHROMEOS_DBUS_BINDINGS_DBUS_RETRY_
+
+namespace org {
+namespace chromium {
+
+// Abstract interface proxy for org::chromium::Test.
+class TestProxyInterface {
+ public:
+  static constexpr char kInterfaceName[] = "org.chromium.Test";
+  static constexpr char kGetStatusMethod[] = "GetStatus";
+  static constexpr char kGetStatusMethodInSignature[] = "b";
+  static constexpr char kGetStatusMethodOutSignature[] = "s";
+  static constexpr char kSendFdMethod[] = "SendFd";
+  static constexpr char kSendFdMethodInSignature[] = "h";
+  static constexpr char kSendFdMethodOutSignature[] = "";
+
+  virtual ~TestProxyInterface() = default;
+
+  virtual bool GetStatus(
+      bool in_verbose,
+      std::string* out_status,
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
+
+  virtual void GetStatusAsync(
+      bool in_verbose,
+      base::OnceCallback<void(const std::string& /*status*/)> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
+
+  virtual bool SendFd(
+      const base::ScopedFD& in_fd,
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
+
+  virtual void SendFdAsync(
+      const base::ScopedFD& in_fd,
+      base::OnceCallback<void()> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
+
+  virtual const dbus::ObjectPath& GetObjectPath() const = 0;
+  virtual dbus::ObjectProxy* GetObjectProxy() const = 0;
+};
+
+}  // namespace chromium
+}  // namespace org
+
+namespace org {
+namespace chromium {
+
+// Interface proxy for org::chromium::Test.
+class TestProxy final : public TestProxyInterface {
+ public:
+  TestProxy(const scoped_refptr<dbus::Bus>& bus) :
+      bus_{bus},
+      dbus_object_proxy_{
+          bus_->GetObjectProxy(service_name_, object_path_)} {
+  }
+
+  TestProxy(const TestProxy&) = delete;
+  TestProxy& operator=(const TestProxy&) = delete;
+
+  ~TestProxy() override {
+  }
+
+  void ReleaseObjectProxy(base::OnceClosure callback) {
+    bus_->RemoveObjectProxy(service_name_, object_path_, std::move(callback));
+  }
+
+  const dbus::ObjectPath& GetObjectPath() const override {
+    return object_path_;
+  }
+
+  dbus::ObjectProxy* GetObjectProxy() const override {
+    return dbus_object_proxy_;
+  }
+
+  bool GetStatus(
+      bool in_verbose,
+      std::string* out_status,
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override {
+    auto response = brillo::dbus_utils::CallMethodAndBlockWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "org.chromium.Test",
+        "GetStatus",
+        error,
+        in_verbose);
+    return response && brillo::dbus_utils::ExtractMethodCallResults(
+        response.get(), error, out_status);
+  }
+
+  void GetStatusAsync(
+      bool in_verbose,
+      base::OnceCallback<void(const std::string& /*status*/)> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override {
+    brillo::dbus_utils::CallMethodWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "org.chromium.Test",
+        "GetStatus",
+        std::move(success_callback),
+        std::move(error_callback),
+        in_verbose);
+  }
+
+  bool SendFd(
+      const base::ScopedFD& in_fd,
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override {
+    auto response = brillo::dbus_utils::CallMethodAndBlockWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "org.chromium.Test",
+        "SendFd",
+        error,
+        in_fd);
+    return response && brillo::dbus_utils::ExtractMethodCallResults(
+        response.get(), error);
+  }
+
+  void SendFdAsync(
+      const base::ScopedFD& in_fd,
+      base::OnceCallback<void()> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override {
+    brillo::dbus_utils::CallMethodWithTimeout(
+        timeout_ms,
+        dbus_object_proxy_,
+        "org.chromium.Test",
+        "SendFd",
+        std::move(success_callback),
+        std::move(error_callback),
+        in_fd);
+  }
+
+ private:
+  scoped_refptr<dbus::Bus> bus_;
+  const std::string service_name_{"org.chromium.TestService"};
+  const dbus::ObjectPath object_path_{"/org/chromium/Test"};
+  dbus::ObjectProxy* dbus_object_proxy_;
+
+};
+
+}  // namespace chromium
+}  // namespace org
+
+namespace org {
+namespace chromium {
+
+// Wraps a proxy for org::chromium::Test, retrying the method calls which
+// fail with transient D-Bus errors. The wrapped proxy must outlive the pending
+// asynchronous calls.
+class TestResilientProxy {
+ public:
+  static constexpr chromeos_dbus_bindings::RetryPolicy kDefaultRetryPolicy{
+      3, base::Milliseconds(100), base::Milliseconds(5000)};
+
+  explicit TestResilientProxy(
+      TestProxyInterface* proxy,
+      const chromeos_dbus_bindings::RetryPolicy& policy = kDefaultRetryPolicy)
+      : proxy_{proxy}, policy_{policy} {}
+
+  TestResilientProxy(const TestResilientProxy&) = delete;
+  TestResilientProxy& operator=(const TestResilientProxy&) = delete;
+
+  TestProxyInterface* proxy() const { return proxy_; }
+
+  bool GetStatus(
+      bool in_verbose,
+      std::string* out_status,
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) {
+    return chromeos_dbus_bindings::CallAndBlockWithRetry(
+        policy_, error, [&](brillo::ErrorPtr* attempt_error) {
+          return proxy_->GetStatus(in_verbose, out_status, attempt_error, timeout_ms);
+        });
+  }
+
+  void GetStatusAsync(
+      bool in_verbose,
+      base::OnceCallback<void(const std::string& /*status*/)> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) {
+    chromeos_dbus_bindings::CallWithRetry(
+        policy_,
+        base::BindRepeating(
+            [](TestProxyInterface* proxy,
+               bool in_verbose,
+               int timeout_ms,
+               base::OnceCallback<void(const std::string& /*status*/)> success_callback,
+               base::OnceCallback<void(brillo::Error*)> error_callback) {
+              proxy->GetStatusAsync(in_verbose, std::move(success_callback),
+                                    std::move(error_callback), timeout_ms);
+            },
+            base::Unretained(proxy_), in_verbose, timeout_ms),
+        std::move(success_callback), std::move(error_callback));
+  }
+
+  bool SendFd(
+      const base::ScopedFD& in_fd,
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) {
+    return chromeos_dbus_bindings::CallAndBlockWithRetry(
+        policy_, error, [&](brillo::ErrorPtr* attempt_error) {
+          return proxy_->SendFd(in_fd, attempt_error, timeout_ms);
+        });
+  }
+
+  void SendFdAsync(
+      const base::ScopedFD& in_fd,
+      base::OnceCallback<void()> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) {
+    // File descriptors are move-only and cannot be kept for the retries.
+    proxy_->SendFdAsync(in_fd, std::move(success_callback),
+                        std::move(error_callback), timeout_ms);
+  }
+
+ private:
+  TestProxyInterface* proxy_;
+  chromeos_dbus_bindings::RetryPolicy policy_;
+};
+
+}  // namespace chromium
+}  // namespace org
+
+#endif  // ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+`
+	if diff := cmp.Diff(out.String(), want); diff != "" {
+		t.Errorf("Generate failed (-got +want):\n%s", diff)
+	}
+}
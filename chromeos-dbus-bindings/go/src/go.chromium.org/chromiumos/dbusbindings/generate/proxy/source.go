@@ -0,0 +1,172 @@
+// Copyright 2022 The ChromiumOS Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package proxy
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"text/template"
+
+	"go.chromium.org/chromiumos/dbusbindings/generate/genutil"
+	"go.chromium.org/chromiumos/dbusbindings/introspect"
+	"go.chromium.org/chromiumos/dbusbindings/serviceconfig"
+)
+
+// proxySourceTemplateText generates the definitions of the methods of the
+// ...Proxy classes declared by GenerateSplit.
+const proxySourceTemplateText = `{{define "proxySourceHeader" -}}
+// Automatic generation of D-Bus proxy definitions for:
+{{range .Introspects}}{{range .Interfaces -}}
+//  - {{.Name}}
+{{end}}{{end}}
+#include "{{.HeaderFilePath}}"
+{{- end}}
+
+{{- define "proxySource"}}{{with $itf := .Itf}}
+{{- $proxyName := makeProxyName .Name}}
+{{range extractNameSpaces .Name}}
+namespace {{.}} {
+{{- end}}
+{{- range .Methods}}
+{{- $inParams := makeMethodParams $.NamingStyle 0 .InputArguments -}}
+{{- $outParams := makeMethodParams $.NamingStyle (len .InputArguments) .OutputArguments}}
+{{- $body := makeMethodBodyArgs $ . $inParams $outParams}}
+{{- if not $.DisableBlockingCalls}}
+
+bool {{$proxyName}}::{{.Name}}(
+{{- range $inParams}}
+    {{.Type}} {{.Name}},
+{{- end}}
+{{- range $outParams}}
+    {{.Type}} {{.Name}},
+{{- end}}
+    brillo::ErrorPtr* error,
+    int timeout_ms) {
+{{- include "blockingMethodBody" $body | outdent 2}}
+}
+{{- end}}
+
+void {{$proxyName}}::{{.Name}}Async(
+{{- range $inParams}}
+    {{.Type}} {{.Name}},
+{{- end}}
+    {{makeMethodCallbackType $.NamingStyle $.MoveProtobufResponses .OutputArguments}} success_callback,
+    base::OnceCallback<void(brillo::Error*)> error_callback,
+    int timeout_ms) {
+{{- include "asyncMethodBody" $body | outdent 2}}
+}
+{{- if and .IncludeDBusMessage (not .NoReply) (not $.DisableBlockingCalls)}}
+
+std::unique_ptr<dbus::Response> {{$proxyName}}::{{.Name}}WithMessage(
+{{- range $inParams}}
+    {{.Type}} {{.Name}},
+{{- end}}
+    brillo::ErrorPtr* error,
+    int timeout_ms) {
+{{- include "withMessageMethodBody" $body | outdent 2}}
+}
+{{- end}}
+{{- end}}
+
+{{range extractNameSpaces .Name | reverse -}}
+}  // namespace {{.}}
+{{end}}
+{{- end}}{{end}}`
+
+// proxySourceTemplates is parsed once, and cloned by every GenerateSource
+// call.
+var proxySourceTemplates = mustParseTemplates("proxySource", funcMap,
+	proxySourceTemplateText,
+	blockingMethodBodyTemplate,
+	asyncMethodBodyTemplate,
+	withMessageMethodBodyTemplate,
+	methodTimeoutTemplate,
+)
+
+// methodBodyArgs is the data passed to the templates generating the bodies of
+// the proxy methods.
+type methodBodyArgs struct {
+	proxyArgs
+	Method              *introspect.Method
+	InParams, OutParams []param
+}
+
+func makeMethodBodyArgs(a proxyArgs, m introspect.Method, inParams, outParams []param) methodBodyArgs {
+	return methodBodyArgs{
+		proxyArgs: a,
+		Method:    &m,
+		InParams:  inParams,
+		OutParams: outParams,
+	}
+}
+
+// outdent removes up to n leading spaces from each line of s.
+func outdent(n int, s string) string {
+	lines := strings.Split(s, "\n")
+	for i, l := range lines {
+		trimmed := strings.TrimLeft(l, " ")
+		if len(l)-len(trimmed) > n {
+			trimmed = l[n:]
+		}
+		lines[i] = trimmed
+	}
+	return strings.Join(lines, "\n")
+}
+
+// bindInclude binds the include function of tmpl, which returns the output of
+// the named template of tmpl as a string, so that it can be piped.
+func bindInclude(tmpl *template.Template) *template.Template {
+	return tmpl.Funcs(template.FuncMap{
+		"include": func(name string, data interface{}) (string, error) {
+			var b strings.Builder
+			if err := tmpl.ExecuteTemplate(&b, name, data); err != nil {
+				return "", err
+			}
+			return b.String(), nil
+		},
+	})
+}
+
+// GenerateSplit is Generate which only declares the methods of the ...Proxy
+// classes, for them to be defined in the source file output by
+// GenerateSource. It shrinks the header parsed by every consumer, and the
+// methods are compiled once instead of being inlined into each of them.
+func GenerateSplit(introspects []introspect.Introspection, f io.Writer, outputFilePath string, config serviceconfig.Config) error {
+	return generate(introspects, f, outputFilePath, "", false, false, true, config)
+}
+
+// GenerateSource outputs the source file defining the methods of the
+// ...Proxy classes declared in the header at headerFilePath, output by
+// GenerateSplit, into f.
+func GenerateSource(introspects []introspect.Introspection, f io.Writer, headerFilePath string, config serviceconfig.Config) error {
+	f = genutil.NewAPILevelWriter(f, config.TargetAPILevel)
+	if headerFilePath == "" {
+		return errors.New("proxy file path is not specified")
+	}
+	tmpl, err := cloneTemplates(proxySourceTemplates, introspects, config)
+	if err != nil {
+		return err
+	}
+	tmpl = bindInclude(tmpl)
+
+	if err := tmpl.ExecuteTemplate(f, "proxySourceHeader", struct {
+		Introspects    []introspect.Introspection
+		HeaderFilePath string
+	}{
+		Introspects:    introspects,
+		HeaderFilePath: headerFilePath,
+	}); err != nil {
+		return err
+	}
+	for _, is := range introspects {
+		for _, itf := range is.Interfaces {
+			if err := tmpl.ExecuteTemplate(f, "proxySource", makeProxyArgs(is, itf, config, false, true)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
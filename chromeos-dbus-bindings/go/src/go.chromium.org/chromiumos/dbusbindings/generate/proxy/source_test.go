@@ -0,0 +1,304 @@
+// Copyright 2022 The ChromiumOS Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package proxy
+
+import (
+	"bytes"
+	"testing"
+
+	"go.chromium.org/chromiumos/dbusbindings/introspect"
+	"go.chromium.org/chromiumos/dbusbindings/serviceconfig"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var splitIntrospections = []introspect.Introspection{{
+	Name: "/org/chromium/Test",
+	Interfaces: []introspect.Interface{{
+		Name: "org.chromium.Test",
+		Methods: []introspect.Method{
+			{
+				Name: "Scan",
+				Args: []introspect.MethodArg{
+					{Name: "query", Type: "s", Direction: "in"},
+					{Name: "count", Type: "i", Direction: "out"},
+				},
+				Annotations: []introspect.Annotation{
+					{Name: "org.chromium.DBus.Method.IncludeDBusMessage", Value: "true"},
+				},
+			},
+			{
+				Name: "Ping",
+			},
+		},
+	}},
+}}
+
+var splitConfig = serviceconfig.Config{
+	ServiceName:      "org.chromium.TestService",
+	SequenceCheckers: true,
+	MethodTimeouts:   map[string]int{"org.chromium.Test.Scan": 60000},
+}
+
+func TestGenerateSplit(t *testing.T) {
+	out := new(bytes.Buffer)
+	if err := GenerateSplit(splitIntrospections, out, "/tmp/proxy.h", splitConfig); err != nil {
+		t.Fatalf("GenerateSplit got error, want nil: %v", err)
+	}
+
+	const want = `// Automatic generation of D-Bus interfaces:
+//  - org.chromium.Test
+#ifndef ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+#define ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+#include <memory>
+#include <string>
+#include <vector>
+
+#include <base/functional/bind.h>
+#include <base/functional/callback.h>
+#include <base/logging.h>
+#include <base/memory/ref_counted.h>
+#include <base/sequence_checker.h>
+#include <brillo/dbus/dbus_method_invoker.h>
+#include <brillo/dbus/dbus_property.h>
+#include <brillo/dbus/dbus_signal_handler.h>
+#include <brillo/errors/error.h>
+#include <dbus/bus.h>
+#include <dbus/message.h>
+#include <dbus/object_manager.h>
+#include <dbus/object_path.h>
+#include <dbus/object_proxy.h>
+
+namespace org {
+namespace chromium {
+
+// Abstract interface proxy for org::chromium::Test.
+class TestProxyInterface {
+ public:
+  static constexpr char kInterfaceName[] = "org.chromium.Test";
+  static constexpr char kScanMethod[] = "Scan";
+  static constexpr char kScanMethodInSignature[] = "s";
+  static constexpr char kScanMethodOutSignature[] = "i";
+  static constexpr char kPingMethod[] = "Ping";
+  static constexpr char kPingMethodInSignature[] = "";
+  static constexpr char kPingMethodOutSignature[] = "";
+
+  virtual ~TestProxyInterface() = default;
+
+  virtual bool Scan(
+      const std::string& in_query,
+      int32_t* out_count,
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
+
+  virtual void ScanAsync(
+      const std::string& in_query,
+      base::OnceCallback<void(int32_t /*count*/)> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
+
+  virtual bool Ping(
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
+
+  virtual void PingAsync(
+      base::OnceCallback<void()> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) = 0;
+
+  virtual const dbus::ObjectPath& GetObjectPath() const = 0;
+  virtual dbus::ObjectProxy* GetObjectProxy() const = 0;
+};
+
+}  // namespace chromium
+}  // namespace org
+
+namespace org {
+namespace chromium {
+
+// Interface proxy for org::chromium::Test.
+class TestProxy final : public TestProxyInterface {
+ public:
+  TestProxy(const scoped_refptr<dbus::Bus>& bus) :
+      bus_{bus},
+      dbus_object_proxy_{
+          bus_->GetObjectProxy(service_name_, object_path_)} {
+  }
+
+  TestProxy(const TestProxy&) = delete;
+  TestProxy& operator=(const TestProxy&) = delete;
+
+  ~TestProxy() override {
+  }
+
+  void ReleaseObjectProxy(base::OnceClosure callback) {
+    bus_->RemoveObjectProxy(service_name_, object_path_, std::move(callback));
+  }
+
+  const dbus::ObjectPath& GetObjectPath() const override {
+    return object_path_;
+  }
+
+  dbus::ObjectProxy* GetObjectProxy() const override {
+    return dbus_object_proxy_;
+  }
+
+  bool Scan(
+      const std::string& in_query,
+      int32_t* out_count,
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override;
+
+  void ScanAsync(
+      const std::string& in_query,
+      base::OnceCallback<void(int32_t /*count*/)> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override;
+
+  // Calls Scan() and returns the response message, e.g. to inspect its
+  // sender, or nullptr on failure. The output arguments can be extracted with
+  // brillo::dbus_utils::ExtractMethodCallResults().
+  std::unique_ptr<dbus::Response> ScanWithMessage(
+      const std::string& in_query,
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT);
+
+  bool Ping(
+      brillo::ErrorPtr* error,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override;
+
+  void PingAsync(
+      base::OnceCallback<void()> success_callback,
+      base::OnceCallback<void(brillo::Error*)> error_callback,
+      int timeout_ms = dbus::ObjectProxy::TIMEOUT_USE_DEFAULT) override;
+
+ private:
+  scoped_refptr<dbus::Bus> bus_;
+  const std::string service_name_{"org.chromium.TestService"};
+  const dbus::ObjectPath object_path_{"/org/chromium/Test"};
+  dbus::ObjectProxy* dbus_object_proxy_;
+  // The proxy must be used on the sequence it is created on, as brillo D-Bus
+  // calls and signal handlers are not thread-safe.
+  SEQUENCE_CHECKER(sequence_checker_);
+
+};
+
+}  // namespace chromium
+}  // namespace org
+
+#endif  // ____CHROMEOS_DBUS_BINDING___TMP_PROXY_H
+`
+	if diff := cmp.Diff(out.String(), want); diff != "" {
+		t.Errorf("GenerateSplit failed (-got +want):\n%s", diff)
+	}
+}
+
+func TestGenerateSource(t *testing.T) {
+	out := new(bytes.Buffer)
+	if err := GenerateSource(splitIntrospections, out, "proxy.h", splitConfig); err != nil {
+		t.Fatalf("GenerateSource got error, want nil: %v", err)
+	}
+
+	const want = `// Automatic generation of D-Bus proxy definitions for:
+//  - org.chromium.Test
+
+#include "proxy.h"
+
+namespace org {
+namespace chromium {
+
+bool TestProxy::Scan(
+    const std::string& in_query,
+    int32_t* out_count,
+    brillo::ErrorPtr* error,
+    int timeout_ms) {
+  DCHECK_CALLED_ON_VALID_SEQUENCE(sequence_checker_);
+  if (timeout_ms == dbus::ObjectProxy::TIMEOUT_USE_DEFAULT)
+    timeout_ms = 60000;
+  auto response = brillo::dbus_utils::CallMethodAndBlockWithTimeout(
+      timeout_ms,
+      dbus_object_proxy_,
+      "org.chromium.Test",
+      "Scan",
+      error,
+      in_query);
+  return response && brillo::dbus_utils::ExtractMethodCallResults(
+      response.get(), error, out_count);
+}
+
+void TestProxy::ScanAsync(
+    const std::string& in_query,
+    base::OnceCallback<void(int32_t /*count*/)> success_callback,
+    base::OnceCallback<void(brillo::Error*)> error_callback,
+    int timeout_ms) {
+  DCHECK_CALLED_ON_VALID_SEQUENCE(sequence_checker_);
+  if (timeout_ms == dbus::ObjectProxy::TIMEOUT_USE_DEFAULT)
+    timeout_ms = 60000;
+  brillo::dbus_utils::CallMethodWithTimeout(
+      timeout_ms,
+      dbus_object_proxy_,
+      "org.chromium.Test",
+      "Scan",
+      std::move(success_callback),
+      std::move(error_callback),
+      in_query);
+}
+
+std::unique_ptr<dbus::Response> TestProxy::ScanWithMessage(
+    const std::string& in_query,
+    brillo::ErrorPtr* error,
+    int timeout_ms) {
+  if (timeout_ms == dbus::ObjectProxy::TIMEOUT_USE_DEFAULT)
+    timeout_ms = 60000;
+  return brillo::dbus_utils::CallMethodAndBlockWithTimeout(
+      timeout_ms,
+      dbus_object_proxy_,
+      "org.chromium.Test",
+      "Scan",
+      error,
+      in_query);
+}
+
+bool TestProxy::Ping(
+    brillo::ErrorPtr* error,
+    int timeout_ms) {
+  DCHECK_CALLED_ON_VALID_SEQUENCE(sequence_checker_);
+  auto response = brillo::dbus_utils::CallMethodAndBlockWithTimeout(
+      timeout_ms,
+      dbus_object_proxy_,
+      "org.chromium.Test",
+      "Ping",
+      error);
+  return response && brillo::dbus_utils::ExtractMethodCallResults(
+      response.get(), error);
+}
+
+void TestProxy::PingAsync(
+    base::OnceCallback<void()> success_callback,
+    base::OnceCallback<void(brillo::Error*)> error_callback,
+    int timeout_ms) {
+  DCHECK_CALLED_ON_VALID_SEQUENCE(sequence_checker_);
+  brillo::dbus_utils::CallMethodWithTimeout(
+      timeout_ms,
+      dbus_object_proxy_,
+      "org.chromium.Test",
+      "Ping",
+      std::move(success_callback),
+      std::move(error_callback));
+}
+
+}  // namespace chromium
+}  // namespace org
+`
+	if diff := cmp.Diff(out.String(), want); diff != "" {
+		t.Errorf("GenerateSource failed (-got +want):\n%s", diff)
+	}
+}
+
+func TestGenerateSourceWithoutHeader(t *testing.T) {
+	if err := GenerateSource(splitIntrospections, new(bytes.Buffer), "", splitConfig); err == nil {
+		t.Error("GenerateSource got nil error, want error")
+	}
+}
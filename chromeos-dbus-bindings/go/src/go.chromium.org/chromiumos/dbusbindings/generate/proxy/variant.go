@@ -0,0 +1,193 @@
+// Copyright 2022 The ChromiumOS Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package proxy
+
+import (
+	"fmt"
+	"strings"
+
+	"go.chromium.org/chromiumos/dbusbindings/dbustype"
+	"go.chromium.org/chromiumos/dbusbindings/generate/genutil"
+	"go.chromium.org/chromiumos/dbusbindings/introspect"
+	"go.chromium.org/chromiumos/dbusbindings/serviceconfig"
+)
+
+// variantTypesTemplate defines the helpers converting the variant arguments
+// annotated with org.chromium.DBus.Argument.VariantTypes from and to
+// std::variant. It is guarded so that multiple generated headers can define
+// it.
+const variantTypesTemplate = `{{define "variantTypes" -}}
+#ifndef CHROMEOS_DBUS_BINDINGS_DBUS_VARIANT_TYPES_
+#define CHROMEOS_DBUS_BINDINGS_DBUS_VARIANT_TYPES_
+namespace chromeos_dbus_bindings {
+
+// Returns true if |value| holds one of Ts.
+template <typename... Ts>
+bool AnyHoldsOneOf(const brillo::Any& value) {
+  return (value.IsTypeCompatible<Ts>() || ...);
+}
+
+// Returns the brillo::Any holding the alternative held by |value|.
+template <typename... Ts>
+brillo::Any VariantToAny(const std::variant<Ts...>& value) {
+  return std::visit([](const auto& v) { return brillo::Any(v); }, value);
+}
+
+// Stores the value held by |value| into |out|. Fails with |error| if |value|
+// holds none of Ts.
+template <typename... Ts>
+bool AnyToVariant(const brillo::Any& value,
+                  std::variant<Ts...>* out,
+                  brillo::ErrorPtr* error) {
+  if (((value.IsTypeCompatible<Ts>() && (*out = value.Get<Ts>(), true)) ||
+       ...)) {
+    return true;
+  }
+  brillo::Error::AddToPrintf(error, FROM_HERE, brillo::errors::dbus::kDomain,
+                             "org.freedesktop.DBus.Error.InvalidArgs",
+                             "Unexpected variant type %s",
+                             value.GetUndecoratedTypeName().c_str());
+  return false;
+}
+
+}  // namespace chromeos_dbus_bindings
+#endif  // CHROMEOS_DBUS_BINDINGS_DBUS_VARIANT_TYPES_
+{{- end}}`
+
+// closedVariantTypes returns the C++ types which the variant argument a may
+// carry, or nil if a is not annotated with a closed list of types.
+func closedVariantTypes(a introspect.MethodArg) ([]string, error) {
+	types, closed := a.VariantTypes()
+	if !closed {
+		return nil, nil
+	}
+	var ret []string
+	for _, t := range types {
+		d, err := dbustype.Parse(t)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, d.BaseType())
+	}
+	return ret, nil
+}
+
+// hasVariantTypes returns true if any argument in introspects is annotated
+// with a closed list of variant types.
+func hasVariantTypes(introspects []introspect.Introspection) bool {
+	for _, i := range introspects {
+		for _, itf := range i.Interfaces {
+			for _, m := range itf.Methods {
+				if hasVariantOverload(m) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// hasVariantOverload returns true if the method m has an overload taking
+// std::variant arguments, i.e. any of its arguments is annotated with a
+// closed list of variant types.
+func hasVariantOverload(m introspect.Method) bool {
+	for _, a := range m.Args {
+		if types, closed := a.VariantTypes(); closed && len(types) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// variantCheck is the validation of an input variant argument.
+type variantCheck struct {
+	// Name is the name of the parameter.
+	Name string
+	// Types are the C++ types the argument may carry, joined by commas.
+	Types string
+}
+
+// makeVariantChecks returns the validations of the input arguments of m
+// annotated with closed lists of variant types.
+func makeVariantChecks(style serviceconfig.NamingStyle, m introspect.Method) ([]variantCheck, error) {
+	var ret []variantCheck
+	for i, a := range m.InputArguments() {
+		types, err := closedVariantTypes(a)
+		if err != nil {
+			return nil, err
+		}
+		if types == nil {
+			continue
+		}
+		ret = append(ret, variantCheck{
+			Name:  genutil.ArgNameWithStyle(style, "in", a.Name, i+1),
+			Types: strings.Join(types, ", "),
+		})
+	}
+	return ret, nil
+}
+
+// variantOverload is an overload of a proxy method taking the variant
+// arguments annotated with closed lists of types as std::variant.
+type variantOverload struct {
+	// Params are the input and output parameters the overload takes.
+	Params []param
+	// Locals are the names of the brillo::Any variables receiving the output
+	// variant arguments.
+	Locals []string
+	// Forwards are the arguments passed to the method.
+	Forwards []string
+	// Conversions are the output variant arguments to be converted from the
+	// locals.
+	Conversions []variantConversion
+}
+
+// variantConversion is the conversion of an output variant argument.
+type variantConversion struct {
+	Local, Out string
+}
+
+// makeVariantOverload returns the overload of m taking std::variant
+// arguments, or nil if m has no variant argument with a closed list of types.
+func makeVariantOverload(style serviceconfig.NamingStyle, m introspect.Method) (*variantOverload, error) {
+	if !hasVariantOverload(m) {
+		return nil, nil
+	}
+	in := m.InputArguments()
+	params, err := makeMethodParams(style, 0, in)
+	if err != nil {
+		return nil, err
+	}
+	outParams, err := makeMethodParams(style, len(in), m.OutputArguments())
+	if err != nil {
+		return nil, err
+	}
+	params = append(params, outParams...)
+	ret := &variantOverload{}
+	for i, a := range append(in, m.OutputArguments()...) {
+		p := params[i]
+		types, err := closedVariantTypes(a)
+		if err != nil {
+			return nil, err
+		}
+		if types == nil {
+			ret.Params = append(ret.Params, p)
+			ret.Forwards = append(ret.Forwards, p.Name)
+			continue
+		}
+		v := fmt.Sprintf("std::variant<%s>", strings.Join(types, ", "))
+		if a.Direction == "out" {
+			local := p.Name + "_any"
+			ret.Params = append(ret.Params, param{Type: v + "*", Name: p.Name})
+			ret.Locals = append(ret.Locals, local)
+			ret.Forwards = append(ret.Forwards, "&"+local)
+			ret.Conversions = append(ret.Conversions, variantConversion{Local: local, Out: p.Name})
+		} else {
+			ret.Params = append(ret.Params, param{Type: "const " + v + "&", Name: p.Name})
+			ret.Forwards = append(ret.Forwards, fmt.Sprintf("chromeos_dbus_bindings::VariantToAny(%s)", p.Name))
+		}
+	}
+	return ret, nil
+}
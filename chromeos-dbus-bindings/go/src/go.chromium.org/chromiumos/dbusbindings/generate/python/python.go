@@ -0,0 +1,432 @@
+// Copyright 2022 The ChromiumOS Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+// Package python outputs Python client stubs of the interfaces based on
+// introspects, using dbus-python, so that the test scripts do not hand-write
+// bindings which drift from the XML.
+package python
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/template"
+
+	"go.chromium.org/chromiumos/dbusbindings/dbustype"
+	"go.chromium.org/chromiumos/dbusbindings/generate/genutil"
+	"go.chromium.org/chromiumos/dbusbindings/introspect"
+	"go.chromium.org/chromiumos/dbusbindings/serviceconfig"
+)
+
+const templateText = `# Automatic generation of Python D-Bus client stubs for:
+{{range .Introspects}}{{range .Interfaces -}}
+#  - {{.Name}}
+{{end}}{{end}}
+"""D-Bus client stubs based on dbus-python."""
+
+from typing import Any, Callable, Dict, List, Tuple
+
+import dbus
+
+PROPERTIES_INTERFACE = 'org.freedesktop.DBus.Properties'
+{{- if .ServiceName}}
+SERVICE_NAME = '{{.ServiceName}}'
+{{- end}}
+{{- range .Interfaces}}
+
+
+class {{.ClassName}}:
+    """Client for {{.Name}}.
+{{- with .DocLines}}
+{{range .}}
+{{if .}}    {{.}}{{end}}
+{{- end}}
+{{- end}}
+    """
+
+    INTERFACE_NAME = '{{.Name}}'
+{{- if .ObjectPath}}
+    OBJECT_PATH = '{{.ObjectPath}}'
+{{- end}}
+
+    def __init__(self, bus: dbus.Bus{{range .CtorParams}}, {{.}}{{end}}):
+        self._proxy = bus.get_object(service, object_path)
+        self._interface = dbus.Interface(self._proxy, self.INTERFACE_NAME)
+{{- range .Methods}}
+
+    def {{.PyName}}(self{{range .In}}, {{.Name}}: {{.Type}}{{end}},
+            {{- " "}}timeout: float = -1) -> {{.ReturnType}}:
+        """Calls {{.Name}}.
+{{- template "docLines" .DocLines}}
+{{- if .In}}
+
+        Args:
+{{- range .In}}
+            {{.Name}} ({{.Type}}): D-Bus type '{{.Signature}}'.
+{{- end}}
+            timeout: The timeout in seconds, or -1 for the default.
+{{- end}}
+{{- if .Out}}
+
+        Returns:
+{{- if eq (len .Out) 1}}
+{{- with index .Out 0}}
+            {{.Name}} ({{.Type}}): D-Bus type '{{.Signature}}'.
+{{- end}}
+{{- else}}
+            A tuple of:
+{{- range .Out}}
+            {{.Name}} ({{.Type}}): D-Bus type '{{.Signature}}'.
+{{- end}}
+{{- end}}
+{{- end}}
+        """
+        {{if .Out}}return {{end}}self._interface.{{.Name}}(
+            {{- range .In}}{{.Name}}, {{end -}}
+            {{- if .In}}signature='{{.InSignature}}', {{end}}timeout=timeout)
+{{- end}}
+{{- range .Properties}}
+{{- if .Readable}}
+
+    @property
+    def {{.PyName}}(self) -> {{.Type}}:
+        """The {{.Name}} property of D-Bus type '{{.Signature}}'.
+{{- template "docLines" .DocLines}}
+        """
+        return self._proxy.Get(self.INTERFACE_NAME, '{{.Name}}',
+                               dbus_interface=PROPERTIES_INTERFACE)
+{{- end}}
+{{- if and .Writable .Readable}}
+
+    @{{.PyName}}.setter
+    def {{.PyName}}(self, value: {{.Type}}) -> None:
+{{- else if .Writable}}
+
+    def set_{{.PyName}}(self, value: {{.Type}}) -> None:
+        """Sets the {{.Name}} property of D-Bus type '{{.Signature}}'.
+{{- template "docLines" .DocLines}}
+        """
+{{- end}}
+{{- if .Writable}}
+        self._proxy.Set(self.INTERFACE_NAME, '{{.Name}}', {{.Wrapped}},
+                        dbus_interface=PROPERTIES_INTERFACE)
+{{- end}}
+{{- end}}
+{{- range .Signals}}
+
+    def connect_to_{{.PyName}}(
+            self, handler: Callable[[{{range $i, $a := .Args}}{{if $i}}, {{end}}{{$a.Type}}{{end}}], None]) -> Any:
+        """Connects handler to the {{.Name}} signal.
+{{- template "docLines" .DocLines}}
+{{- if .Args}}
+
+        The handler is called with:
+{{- range .Args}}
+            {{.Name}} ({{.Type}}): D-Bus type '{{.Signature}}'.
+{{- end}}
+{{- end}}
+        """
+        return self._interface.connect_to_signal('{{.Name}}', handler)
+{{- end}}
+{{- end}}
+{{define "docLines"}}
+{{- with .}}
+{{range .}}
+{{if .}}        {{.}}{{end}}
+{{- end}}
+{{- end}}
+{{- end}}`
+
+// pyArg is a parameter or a return value of a Python method.
+type pyArg struct {
+	Name      string
+	Type      string
+	Signature string
+}
+
+// pyMethod is a D-Bus method with its Python method.
+type pyMethod struct {
+	Name        string
+	PyName      string
+	DocLines    []string
+	In, Out     []pyArg
+	InSignature string
+	ReturnType  string
+}
+
+// pyProperty is a D-Bus property with its Python accessors.
+type pyProperty struct {
+	Name               string
+	PyName             string
+	DocLines           []string
+	Type               string
+	Signature          string
+	Readable, Writable bool
+	// Wrapped is the expression converting the value to the dbus-python
+	// type of the property, so that it is not sent as a guessed type.
+	Wrapped string
+}
+
+// pySignal is a D-Bus signal with its Python connector.
+type pySignal struct {
+	Name     string
+	PyName   string
+	DocLines []string
+	Args     []pyArg
+}
+
+// pyInterface is a D-Bus interface with its Python client class.
+type pyInterface struct {
+	Name       string
+	ClassName  string
+	DocLines   []string
+	ObjectPath string
+	CtorParams []string
+	Methods    []pyMethod
+	Properties []pyProperty
+	Signals    []pySignal
+}
+
+// keywords are the Python keywords, which cannot be parameter names.
+var keywords = map[string]bool{
+	"False": true, "None": true, "True": true, "and": true, "as": true,
+	"assert": true, "async": true, "await": true, "break": true,
+	"class": true, "continue": true, "def": true, "del": true, "elif": true,
+	"else": true, "except": true, "finally": true, "for": true, "from": true,
+	"global": true, "if": true, "import": true, "in": true, "is": true,
+	"lambda": true, "nonlocal": true, "not": true, "or": true, "pass": true,
+	"raise": true, "return": true, "try": true, "while": true, "with": true,
+	"yield": true,
+}
+
+// basicWrappers maps the D-Bus basic types to the dbus-python types.
+var basicWrappers = map[string]string{
+	"b": "dbus.Boolean",
+	"y": "dbus.Byte",
+	"n": "dbus.Int16",
+	"q": "dbus.UInt16",
+	"i": "dbus.Int32",
+	"u": "dbus.UInt32",
+	"x": "dbus.Int64",
+	"t": "dbus.UInt64",
+	"d": "dbus.Double",
+	"h": "dbus.types.UnixFd",
+	"s": "dbus.String",
+	"o": "dbus.ObjectPath",
+}
+
+// makePyName converts a D-Bus name, e.g. "GetStatus", into a snake_case
+// Python name, e.g. "get_status", which is suffixed with "_" if it is a
+// keyword.
+func makePyName(name string) string {
+	ret := genutil.MakeVariableName(name)
+	if keywords[ret] {
+		ret += "_"
+	}
+	return ret
+}
+
+// makeWrapped returns the expression converting the Python value v to the
+// dbus-python type of the D-Bus type typ.
+func makeWrapped(typ, v string) string {
+	switch {
+	case basicWrappers[typ] != "":
+		return fmt.Sprintf("%s(%s)", basicWrappers[typ], v)
+	case strings.HasPrefix(typ, "a{"):
+		return fmt.Sprintf("dbus.Dictionary(%s, signature='%s')", v, typ[2:len(typ)-1])
+	case strings.HasPrefix(typ, "a"):
+		return fmt.Sprintf("dbus.Array(%s, signature='%s')", v, typ[1:])
+	case strings.HasPrefix(typ, "("):
+		return fmt.Sprintf("dbus.Struct(%s, signature='%s')", v, typ[1:len(typ)-1])
+	}
+	// Variants are sent as the type guessed from the value.
+	return v
+}
+
+// makeArgs returns the Python arguments of the n D-Bus arguments whose names
+// and types are given by name and typ. Unnamed arguments are named after
+// prefix and their indices.
+func makeArgs(n int, prefix string, name, typ func(i int) string) ([]pyArg, error) {
+	var ret []pyArg
+	for i := 0; i < n; i++ {
+		t, err := dbustype.PythonType(typ(i))
+		if err != nil {
+			return nil, fmt.Errorf("%s argument: %v", name(i), err)
+		}
+		argName := fmt.Sprintf("%s%d", prefix, i)
+		if name(i) != "" {
+			argName = makePyName(name(i))
+		}
+		ret = append(ret, pyArg{Name: argName, Type: t, Signature: typ(i)})
+	}
+	return ret, nil
+}
+
+// makeReturnType returns the Python type returned by a method with outs.
+func makeReturnType(outs []pyArg) string {
+	switch len(outs) {
+	case 0:
+		return "None"
+	case 1:
+		return outs[0].Type
+	}
+	var types []string
+	for _, a := range outs {
+		types = append(types, a.Type)
+	}
+	return fmt.Sprintf("Tuple[%s]", strings.Join(types, ", "))
+}
+
+// makeDocLines returns the lines of docString, escaped for a Python doc string.
+func makeDocLines(docString introspect.DocString) []string {
+	var ret []string
+	for _, l := range genutil.DocStringLines(docString) {
+		l = strings.ReplaceAll(l, `\`, `\\`)
+		ret = append(ret, strings.ReplaceAll(l, `"""`, `\"\"\"`))
+	}
+	return ret
+}
+
+// makeMethod returns the Python method of m.
+func makeMethod(m introspect.Method) (pyMethod, error) {
+	ins, outs := m.InputArguments(), m.OutputArguments()
+	in, err := makeArgs(len(ins), "arg",
+		func(i int) string { return ins[i].Name },
+		func(i int) string { return string(ins[i].Type) })
+	if err != nil {
+		return pyMethod{}, err
+	}
+	out, err := makeArgs(len(outs), "out",
+		func(i int) string { return outs[i].Name },
+		func(i int) string { return string(outs[i].Type) })
+	if err != nil {
+		return pyMethod{}, err
+	}
+	return pyMethod{
+		Name:        m.Name,
+		PyName:      makePyName(m.Name),
+		DocLines:    makeDocLines(m.DocString),
+		In:          in,
+		Out:         out,
+		InSignature: m.InputSignature(),
+		ReturnType:  makeReturnType(out),
+	}, nil
+}
+
+// makeCtorParams returns the parameters of the constructor following the
+// bus. The service name and the object path default to the configured ones,
+// and the parameters without defaults come first.
+func makeCtorParams(serviceName, objectPath string) []string {
+	service := "service: str"
+	if serviceName != "" {
+		service += " = SERVICE_NAME"
+	}
+	path := "object_path: str"
+	if objectPath != "" {
+		path += " = OBJECT_PATH"
+	}
+	ret := []string{service, path}
+	sort.SliceStable(ret, func(i, j int) bool {
+		return !strings.Contains(ret[i], "=") && strings.Contains(ret[j], "=")
+	})
+	return ret
+}
+
+// makeInterface returns the Python client class of itf.
+func makeInterface(itf introspect.Interface, objectPath, serviceName string) (pyInterface, error) {
+	ret := pyInterface{
+		Name:       itf.Name,
+		ClassName:  genutil.MakeTypeName(itf.Name) + "Client",
+		DocLines:   makeDocLines(itf.DocString),
+		ObjectPath: objectPath,
+		CtorParams: makeCtorParams(serviceName, objectPath),
+	}
+	// The names of the methods and the properties share the namespace of
+	// the class.
+	seen := make(map[string]string)
+	use := func(pyName, name string) error {
+		if prev, ok := seen[pyName]; ok {
+			return fmt.Errorf("%s and %s are both named %s in Python", prev, name, pyName)
+		}
+		seen[pyName] = name
+		return nil
+	}
+	for _, m := range itf.Methods {
+		pm, err := makeMethod(m)
+		if err != nil {
+			return pyInterface{}, fmt.Errorf("%s method: %v", m.Name, err)
+		}
+		if err := use(pm.PyName, m.Name); err != nil {
+			return pyInterface{}, err
+		}
+		ret.Methods = append(ret.Methods, pm)
+	}
+	for _, p := range itf.Properties {
+		t, err := dbustype.PythonType(p.Type)
+		if err != nil {
+			return pyInterface{}, fmt.Errorf("%s property: %v", p.Name, err)
+		}
+		pp := pyProperty{
+			Name:      p.Name,
+			PyName:    makePyName(p.Name),
+			DocLines:  makeDocLines(p.DocString),
+			Type:      t,
+			Signature: p.Type,
+			Readable:  p.Readable(),
+			Writable:  p.Writable(),
+			Wrapped:   makeWrapped(p.Type, "value"),
+		}
+		if err := use(pp.PyName, p.Name); err != nil {
+			return pyInterface{}, err
+		}
+		ret.Properties = append(ret.Properties, pp)
+	}
+	for _, s := range itf.Signals {
+		args, err := makeArgs(len(s.Args), "arg",
+			func(i int) string { return s.Args[i].Name },
+			func(i int) string { return s.Args[i].Type })
+		if err != nil {
+			return pyInterface{}, fmt.Errorf("%s signal: %v", s.Name, err)
+		}
+		ret.Signals = append(ret.Signals, pySignal{
+			Name:     s.Name,
+			PyName:   makePyName(s.Name),
+			DocLines: makeDocLines(s.DocString),
+			Args:     args,
+		})
+	}
+	return ret, nil
+}
+
+// Generate outputs the Python module with, for each interface in introspects,
+// a client class calling its methods with the arguments sent as their D-Bus
+// types, accessing its properties and connecting handlers to its signals.
+// The doc strings describe the Python and the D-Bus types of the arguments.
+func Generate(introspects []introspect.Introspection, f io.Writer, config serviceconfig.Config) error {
+	var itfs []pyInterface
+	for _, is := range introspects {
+		for _, itf := range is.Interfaces {
+			pi, err := makeInterface(itf, is.Name, config.ServiceName)
+			if err != nil {
+				return fmt.Errorf("%s interface: %v", itf.Name, err)
+			}
+			itfs = append(itfs, pi)
+		}
+	}
+
+	tmpl, err := template.New("python").Parse(templateText)
+	if err != nil {
+		return err
+	}
+	return tmpl.Execute(f, struct {
+		Introspects []introspect.Introspection
+		Interfaces  []pyInterface
+		ServiceName string
+	}{
+		Introspects: introspects,
+		Interfaces:  itfs,
+		ServiceName: config.ServiceName,
+	})
+}
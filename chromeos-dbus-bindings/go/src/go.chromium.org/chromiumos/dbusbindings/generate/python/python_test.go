@@ -0,0 +1,230 @@
+// Copyright 2022 The ChromiumOS Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package python
+
+import (
+	"bytes"
+	"testing"
+
+	"go.chromium.org/chromiumos/dbusbindings/introspect"
+	"go.chromium.org/chromiumos/dbusbindings/serviceconfig"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGenerate(t *testing.T) {
+	introspections := []introspect.Introspection{{
+		Name: "/org/chromium/Test",
+		Interfaces: []introspect.Interface{{
+			Name:      "org.chromium.Test",
+			DocString: "Test interface.",
+			Methods: []introspect.Method{
+				{
+					Name:      "Scan",
+					DocString: "Scans the devices.",
+					Args: []introspect.MethodArg{
+						{Name: "device_name", Type: "s"},
+						{Type: "a{sv}"},
+						{Name: "count", Type: "x", Direction: "out"},
+						{Name: "results", Type: "a(ob)", Direction: "out"},
+					},
+				}, {
+					Name: "GetLevel",
+					Args: []introspect.MethodArg{
+						{Name: "from", Type: "u"},
+						{Name: "level", Type: "d", Direction: "out"},
+					},
+				}, {
+					Name: "Stop",
+				},
+			},
+			Properties: []introspect.Property{
+				{Name: "Mode", Type: "u", Access: "readwrite"},
+				{Name: "Devices", Type: "ao", Access: "read"},
+				{Name: "Secret", Type: "s", Access: "write"},
+			},
+			Signals: []introspect.Signal{
+				{Name: "ScanDone", Args: []introspect.SignalArg{
+					{Name: "count", Type: "x"},
+				}},
+				{Name: "Stopped"},
+			},
+		}},
+	}}
+
+	out := new(bytes.Buffer)
+	sc := serviceconfig.Config{ServiceName: "org.chromium.TestService"}
+	if err := Generate(introspections, out, sc); err != nil {
+		t.Fatalf("Generate got error, want nil: %v", err)
+	}
+
+	const want = `# Automatic generation of Python D-Bus client stubs for:
+#  - org.chromium.Test
+
+"""D-Bus client stubs based on dbus-python."""
+
+from typing import Any, Callable, Dict, List, Tuple
+
+import dbus
+
+PROPERTIES_INTERFACE = 'org.freedesktop.DBus.Properties'
+SERVICE_NAME = 'org.chromium.TestService'
+
+
+class TestClient:
+    """Client for org.chromium.Test.
+
+    Test interface.
+    """
+
+    INTERFACE_NAME = 'org.chromium.Test'
+    OBJECT_PATH = '/org/chromium/Test'
+
+    def __init__(self, bus: dbus.Bus, service: str = SERVICE_NAME, object_path: str = OBJECT_PATH):
+        self._proxy = bus.get_object(service, object_path)
+        self._interface = dbus.Interface(self._proxy, self.INTERFACE_NAME)
+
+    def scan(self, device_name: str, arg1: Dict[str, Any], timeout: float = -1) -> Tuple[int, List[Tuple[str, bool]]]:
+        """Calls Scan.
+
+        Scans the devices.
+
+        Args:
+            device_name (str): D-Bus type 's'.
+            arg1 (Dict[str, Any]): D-Bus type 'a{sv}'.
+            timeout: The timeout in seconds, or -1 for the default.
+
+        Returns:
+            A tuple of:
+            count (int): D-Bus type 'x'.
+            results (List[Tuple[str, bool]]): D-Bus type 'a(ob)'.
+        """
+        return self._interface.Scan(device_name, arg1, signature='sa{sv}', timeout=timeout)
+
+    def get_level(self, from_: int, timeout: float = -1) -> float:
+        """Calls GetLevel.
+
+        Args:
+            from_ (int): D-Bus type 'u'.
+            timeout: The timeout in seconds, or -1 for the default.
+
+        Returns:
+            level (float): D-Bus type 'd'.
+        """
+        return self._interface.GetLevel(from_, signature='u', timeout=timeout)
+
+    def stop(self, timeout: float = -1) -> None:
+        """Calls Stop.
+        """
+        self._interface.Stop(timeout=timeout)
+
+    @property
+    def mode(self) -> int:
+        """The Mode property of D-Bus type 'u'.
+        """
+        return self._proxy.Get(self.INTERFACE_NAME, 'Mode',
+                               dbus_interface=PROPERTIES_INTERFACE)
+
+    @mode.setter
+    def mode(self, value: int) -> None:
+        self._proxy.Set(self.INTERFACE_NAME, 'Mode', dbus.UInt32(value),
+                        dbus_interface=PROPERTIES_INTERFACE)
+
+    @property
+    def devices(self) -> List[str]:
+        """The Devices property of D-Bus type 'ao'.
+        """
+        return self._proxy.Get(self.INTERFACE_NAME, 'Devices',
+                               dbus_interface=PROPERTIES_INTERFACE)
+
+    def set_secret(self, value: str) -> None:
+        """Sets the Secret property of D-Bus type 's'.
+        """
+        self._proxy.Set(self.INTERFACE_NAME, 'Secret', dbus.String(value),
+                        dbus_interface=PROPERTIES_INTERFACE)
+
+    def connect_to_scan_done(
+            self, handler: Callable[[int], None]) -> Any:
+        """Connects handler to the ScanDone signal.
+
+        The handler is called with:
+            count (int): D-Bus type 'x'.
+        """
+        return self._interface.connect_to_signal('ScanDone', handler)
+
+    def connect_to_stopped(
+            self, handler: Callable[[], None]) -> Any:
+        """Connects handler to the Stopped signal.
+        """
+        return self._interface.connect_to_signal('Stopped', handler)
+`
+	if diff := cmp.Diff(out.String(), want); diff != "" {
+		t.Errorf("Generate failed (-got +want):\n%s", diff)
+	}
+}
+
+func TestGenerateWithoutServiceName(t *testing.T) {
+	introspections := []introspect.Introspection{{
+		Interfaces: []introspect.Interface{{
+			Name:    "org.chromium.Test",
+			Methods: []introspect.Method{{Name: "Stop"}},
+		}},
+	}}
+
+	out := new(bytes.Buffer)
+	if err := Generate(introspections, out, serviceconfig.Config{}); err != nil {
+		t.Fatalf("Generate got error, want nil: %v", err)
+	}
+
+	const want = `# Automatic generation of Python D-Bus client stubs for:
+#  - org.chromium.Test
+
+"""D-Bus client stubs based on dbus-python."""
+
+from typing import Any, Callable, Dict, List, Tuple
+
+import dbus
+
+PROPERTIES_INTERFACE = 'org.freedesktop.DBus.Properties'
+
+
+class TestClient:
+    """Client for org.chromium.Test.
+    """
+
+    INTERFACE_NAME = 'org.chromium.Test'
+
+    def __init__(self, bus: dbus.Bus, service: str, object_path: str):
+        self._proxy = bus.get_object(service, object_path)
+        self._interface = dbus.Interface(self._proxy, self.INTERFACE_NAME)
+
+    def stop(self, timeout: float = -1) -> None:
+        """Calls Stop.
+        """
+        self._interface.Stop(timeout=timeout)
+`
+	if diff := cmp.Diff(out.String(), want); diff != "" {
+		t.Errorf("Generate failed (-got +want):\n%s", diff)
+	}
+}
+
+func TestGenerateNameCollision(t *testing.T) {
+	introspections := []introspect.Introspection{{
+		Interfaces: []introspect.Interface{{
+			Name:       "org.chromium.Test",
+			Methods:    []introspect.Method{{Name: "Status"}},
+			Properties: []introspect.Property{{Name: "Status", Type: "s", Access: "read"}},
+		}},
+	}}
+
+	err := Generate(introspections, new(bytes.Buffer), serviceconfig.Config{})
+	if err == nil {
+		t.Fatal("Generate unexpectedly succeeded")
+	}
+	const want = "org.chromium.Test interface: Status and Status are both named status in Python"
+	if err.Error() != want {
+		t.Errorf("Generate err mismatch: got %q, want %q", err, want)
+	}
+}
@@ -0,0 +1,276 @@
+// Copyright 2022 The ChromiumOS Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+// Package testfixture outputs gtest fixtures for the proxies based on
+// introspects. The fixtures run the proxies on a dbus::MockBus, so that
+// the tests of the proxy users only need to inject the responses.
+package testfixture
+
+import (
+	"errors"
+	"io"
+	"text/template"
+
+	"go.chromium.org/chromiumos/dbusbindings/generate/genutil"
+	"go.chromium.org/chromiumos/dbusbindings/introspect"
+	"go.chromium.org/chromiumos/dbusbindings/serviceconfig"
+)
+
+// Service name and object path used by the fixtures when the proxies take
+// them as constructor arguments.
+const (
+	defaultServiceName = "org.chromium.TestService"
+	defaultObjectPath  = "/org/chromium/TestObject"
+)
+
+var funcMap = template.FuncMap{
+	"makeConstantName":       genutil.MakeConstantName,
+	"makeProxyName":          genutil.MakeProxyName,
+	"makeProxyInterfaceName": genutil.MakeProxyInterfaceName,
+	"makeResponseParams":     makeResponseParams,
+	"reverse":                genutil.Reverse,
+}
+
+const templateText = `// Automatic generation of D-Bus proxy test fixtures for:
+{{range .Introspects}}{{range .Interfaces -}}
+//  - {{.Name}}
+{{end}}{{end -}}
+
+#ifndef {{.HeaderGuard}}
+#define {{.HeaderGuard}}
+#include <memory>
+#include <string>
+#include <utility>
+
+#include <base/memory/scoped_refptr.h>
+#include <base/types/expected.h>
+#include <brillo/dbus/dbus_param_writer.h>
+#include <dbus/bus.h>
+#include <dbus/message.h>
+#include <dbus/mock_bus.h>
+#include <dbus/mock_object_proxy.h>
+#include <dbus/object_path.h>
+#include <gmock/gmock.h>
+#include <gtest/gtest.h>
+
+#include "{{.ProxyFilePath}}"
+
+#ifndef CHROMEOS_DBUS_BINDINGS_DBUS_TEST_MATCHERS_
+#define CHROMEOS_DBUS_BINDINGS_DBUS_TEST_MATCHERS_
+namespace chromeos_dbus_bindings {
+
+// Matches the dbus::MethodCall* of the method of the interface.
+MATCHER_P2(IsDBusMethodCall, interface_name, method_name, "") {
+  return arg->GetInterface() == interface_name &&
+         arg->GetMember() == method_name;
+}
+
+}  // namespace chromeos_dbus_bindings
+#endif  // CHROMEOS_DBUS_BINDINGS_DBUS_TEST_MATCHERS_
+{{range $introspect := .Introspects}}{{range $itf := .Interfaces}}
+{{- $config := interfaceConfig .Name}}
+{{- if not (and $config.ObjectManager .Properties)}}
+{{- $itfName := makeProxyInterfaceName .Name}}
+{{- $proxyName := makeProxyName .Name}}
+{{- $fixtureName := printf "%sTest" $proxyName}}
+{{range extractNameSpaces .Name -}}
+namespace {{.}} {
+{{end}}
+// Test fixture for {{$proxyName}}, whose method calls are served by a
+// dbus::MockObjectProxy. Use the Expect*() methods to inject the responses.
+class {{$fixtureName}} : public ::testing::Test {
+ protected:
+  {{$fixtureName}}()
+      : bus_{new dbus::MockBus(dbus::Bus::Options())},
+        object_proxy_{new dbus::MockObjectProxy(
+            bus_.get(), "{{or $.ServiceName $.DefaultServiceName}}",
+            dbus::ObjectPath("{{or $introspect.Name $.DefaultObjectPath}}"))} {
+    EXPECT_CALL(*bus_, GetObjectProxy(::testing::_, ::testing::_))
+        .WillRepeatedly(::testing::Return(object_proxy_.get()));
+    proxy_ = std::make_unique<{{$proxyName}}>(
+        bus_
+{{- if not $.ServiceName}},
+        "{{$.DefaultServiceName}}"
+{{- end}}
+{{- if not $introspect.Name}},
+        dbus::ObjectPath("{{$.DefaultObjectPath}}")
+{{- end}});
+  }
+
+  // Expects a blocking call of |method_name| and replies with |response|.
+  void ExpectCall(const char* method_name,
+                  std::unique_ptr<dbus::Response> response) {
+    EXPECT_CALL(*object_proxy_,
+                CallMethodAndBlock(
+                    chromeos_dbus_bindings::IsDBusMethodCall(
+                        {{$itfName}}::kInterfaceName, method_name),
+                    ::testing::_))
+        .WillOnce(::testing::Return(
+            ::testing::ByMove(base::ok(std::move(response)))));
+  }
+
+  // Expects a blocking call of |method_name| and fails it with the D-Bus
+  // error.
+  void ExpectCallError(const char* method_name,
+                       const std::string& error_name,
+                       const std::string& error_message) {
+    EXPECT_CALL(*object_proxy_,
+                CallMethodAndBlock(
+                    chromeos_dbus_bindings::IsDBusMethodCall(
+                        {{$itfName}}::kInterfaceName, method_name),
+                    ::testing::_))
+        .WillOnce(::testing::Return(::testing::ByMove(
+            base::unexpected(dbus::Error(error_name, error_message)))));
+  }
+
+  // Expects an asynchronous call of |method_name| and replies with
+  // |response|.
+  void ExpectAsyncCall(const char* method_name,
+                       std::unique_ptr<dbus::Response> response) {
+    EXPECT_CALL(*object_proxy_,
+                DoCallMethodWithErrorCallback(
+                    chromeos_dbus_bindings::IsDBusMethodCall(
+                        {{$itfName}}::kInterfaceName, method_name),
+                    ::testing::_, ::testing::_, ::testing::_))
+        .WillOnce([response = std::move(response)](
+                      dbus::MethodCall* method_call, int timeout_ms,
+                      dbus::ObjectProxy::ResponseCallback* callback,
+                      dbus::ObjectProxy::ErrorCallback* error_callback) {
+          std::move(*callback).Run(response.get());
+        });
+  }
+
+  // Expects an asynchronous call of |method_name| and fails it with the
+  // D-Bus error.
+  void ExpectAsyncCallError(const char* method_name,
+                            const std::string& error_name,
+                            const std::string& error_message) {
+    EXPECT_CALL(*object_proxy_,
+                DoCallMethodWithErrorCallback(
+                    chromeos_dbus_bindings::IsDBusMethodCall(
+                        {{$itfName}}::kInterfaceName, method_name),
+                    ::testing::_, ::testing::_, ::testing::_))
+        .WillOnce([error_name, error_message](
+                      dbus::MethodCall* method_call, int timeout_ms,
+                      dbus::ObjectProxy::ResponseCallback* callback,
+                      dbus::ObjectProxy::ErrorCallback* error_callback) {
+          method_call->SetSerial(1);
+          std::unique_ptr<dbus::ErrorResponse> error =
+              dbus::ErrorResponse::FromMethodCall(method_call, error_name,
+                                                  error_message);
+          std::move(*error_callback).Run(error.get());
+        });
+  }
+{{- range .Methods}}
+{{- $params := makeResponseParams $.NamingStyle (len .InputArguments) .OutputArguments}}
+
+  // Returns a response of {{.Name}}() carrying the output arguments.
+  static std::unique_ptr<dbus::Response> Make{{.Name}}Response(
+{{- range $i, $p := $params}}{{if $i}},{{end}}
+      {{$p.Type}} {{$p.Name}}
+{{- end}}) {
+    std::unique_ptr<dbus::Response> response = dbus::Response::CreateEmpty();
+{{- if $params}}
+    dbus::MessageWriter writer(response.get());
+    brillo::dbus_utils::DBusParamWriter::Append(
+        &writer{{range $params}}, {{.Name}}{{end}});
+{{- end}}
+    return response;
+  }
+{{- if not $config.DisableBlockingCalls}}
+
+  // Expects a blocking call of {{.Name}}() and replies with the output
+  // arguments.
+  void Expect{{.Name}}(
+{{- range $i, $p := $params}}{{if $i}},{{end}}
+      {{$p.Type}} {{$p.Name}}
+{{- end}}) {
+    ExpectCall({{$itfName}}::k{{makeConstantName .Name}}Method,
+               Make{{.Name}}Response({{range $i, $p := $params}}{{if $i}}, {{end}}{{.Name}}{{end}}));
+  }
+{{- end}}
+
+  // Expects an asynchronous call of {{.Name}}Async() and replies with the
+  // output arguments.
+  void Expect{{.Name}}Async(
+{{- range $i, $p := $params}}{{if $i}},{{end}}
+      {{$p.Type}} {{$p.Name}}
+{{- end}}) {
+    ExpectAsyncCall({{$itfName}}::k{{makeConstantName .Name}}Method,
+                    Make{{.Name}}Response({{range $i, $p := $params}}{{if $i}}, {{end}}{{.Name}}{{end}}));
+  }
+{{- end}}
+
+  scoped_refptr<dbus::MockBus> bus_;
+  scoped_refptr<dbus::MockObjectProxy> object_proxy_;
+  std::unique_ptr<{{$proxyName}}> proxy_;
+};
+
+{{range extractNameSpaces .Name | reverse -}}
+}  // namespace {{.}}
+{{end}}
+{{- end}}
+{{- end}}{{end}}
+#endif  // {{.HeaderGuard}}
+`
+
+// param is a parameter of the generated helpers.
+type param struct {
+	Type string
+	Name string
+}
+
+// makeResponseParams returns the parameters of the helpers injecting the
+// response carrying the output arguments args of a method taking numIn input
+// arguments.
+func makeResponseParams(style serviceconfig.NamingStyle, numIn int, args []introspect.MethodArg) ([]param, error) {
+	var ret []param
+	for i, a := range args {
+		t, err := a.InArgType()
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, param{Type: t, Name: genutil.ArgNameWithStyle(style, "out", a.Name, genutil.MethodArgIndex(true, i, numIn))})
+	}
+	return ret, nil
+}
+
+// Generate outputs the header file containing the gtest fixtures of the
+// proxies into f. outputFilePath is used to make a unique header guard, and
+// proxyFilePath is the path of the proxy header to be included.
+// The interfaces whose proxies are created by the object manager are skipped.
+func Generate(introspects []introspect.Introspection, f io.Writer, outputFilePath, proxyFilePath string, config serviceconfig.Config) error {
+	f = genutil.NewAPILevelWriter(f, config.TargetAPILevel)
+	if proxyFilePath == "" {
+		return errors.New("proxy file path is not specified")
+	}
+	nsFuncs, err := genutil.MakeNameSpaceFuncs(introspects, config.NamespaceOverrides)
+	if err != nil {
+		return err
+	}
+	// The proxies of the interfaces are generated with their own settings.
+	cfgFuncs := template.FuncMap{"interfaceConfig": config.ForInterface}
+	tmpl, err := template.New("testfixture").Funcs(funcMap).Funcs(nsFuncs).Funcs(cfgFuncs).Parse(templateText)
+	if err != nil {
+		return err
+	}
+
+	return tmpl.Execute(f, struct {
+		Introspects        []introspect.Introspection
+		HeaderGuard        string
+		ProxyFilePath      string
+		ServiceName        string
+		NamingStyle        serviceconfig.NamingStyle
+		DefaultServiceName string
+		DefaultObjectPath  string
+	}{
+		Introspects:        introspects,
+		HeaderGuard:        genutil.GenerateHeaderGuard(outputFilePath),
+		ProxyFilePath:      proxyFilePath,
+		ServiceName:        config.ServiceName,
+		NamingStyle:        config.NamingStyle,
+		DefaultServiceName: defaultServiceName,
+		DefaultObjectPath:  defaultObjectPath,
+	})
+}
@@ -0,0 +1,229 @@
+// Copyright 2022 The ChromiumOS Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package testfixture
+
+import (
+	"bytes"
+	"testing"
+
+	"go.chromium.org/chromiumos/dbusbindings/introspect"
+	"go.chromium.org/chromiumos/dbusbindings/serviceconfig"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGenerate(t *testing.T) {
+	introspections := []introspect.Introspection{{
+		Interfaces: []introspect.Interface{{
+			Name: "org.chromium.Test",
+			Methods: []introspect.Method{
+				{
+					Name: "Scan",
+					Args: []introspect.MethodArg{
+						{Name: "name", Type: "s"},
+						{Name: "count", Type: "i", Direction: "out"},
+						{Name: "results", Type: "as", Direction: "out"},
+					},
+				}, {
+					Name: "Stop",
+				},
+			},
+		}},
+	}}
+
+	out := new(bytes.Buffer)
+	if err := Generate(introspections, out, "/tmp/fixture.h", "proxy.h", serviceconfig.Config{}); err != nil {
+		t.Fatalf("Generate got error, want nil: %v", err)
+	}
+
+	const want = `// Automatic generation of D-Bus proxy test fixtures for:
+//  - org.chromium.Test
+#ifndef ____CHROMEOS_DBUS_BINDING___TMP_FIXTURE_H
+#define ____CHROMEOS_DBUS_BINDING___TMP_FIXTURE_H
+#include <memory>
+#include <string>
+#include <utility>
+
+#include <base/memory/scoped_refptr.h>
+#include <base/types/expected.h>
+#include <brillo/dbus/dbus_param_writer.h>
+#include <dbus/bus.h>
+#include <dbus/message.h>
+#include <dbus/mock_bus.h>
+#include <dbus/mock_object_proxy.h>
+#include <dbus/object_path.h>
+#include <gmock/gmock.h>
+#include <gtest/gtest.h>
+
+#include "proxy.h"
+
+#ifndef CHROMEOS_DBUS_BINDINGS_DBUS_TEST_MATCHERS_
+#define CHROMEOS_DBUS_BINDINGS_DBUS_TEST_MATCHERS_
+namespace chromeos_dbus_bindings {
+
+// Matches the dbus::MethodCall* of the method of the interface.
+MATCHER_P2(IsDBusMethodCall, interface_name, method_name, "") {
+  return arg->GetInterface() == interface_name &&
+         arg->GetMember() == method_name;
+}
+
+}  // namespace chromeos_dbus_bindings
+#endif  // CHROMEOS_DBUS_BINDINGS_DBUS_TEST_MATCHERS_
+
+namespace org {
+namespace chromium {
+
+// Test fixture for TestProxy, whose method calls are served by a
+// dbus::MockObjectProxy. Use the Expect*() methods to inject the responses.
+class TestProxyTest : public ::testing::Test {
+ protected:
+  TestProxyTest()
+      : bus_{new dbus::MockBus(dbus::Bus::Options())},
+        object_proxy_{new dbus::MockObjectProxy(
+            bus_.get(), "org.chromium.TestService",
+            dbus::ObjectPath("/org/chromium/TestObject"))} {
+    EXPECT_CALL(*bus_, GetObjectProxy(::testing::_, ::testing::_))
+        .WillRepeatedly(::testing::Return(object_proxy_.get()));
+    proxy_ = std::make_unique<TestProxy>(
+        bus_,
+        "org.chromium.TestService",
+        dbus::ObjectPath("/org/chromium/TestObject"));
+  }
+
+  // Expects a blocking call of |method_name| and replies with |response|.
+  void ExpectCall(const char* method_name,
+                  std::unique_ptr<dbus::Response> response) {
+    EXPECT_CALL(*object_proxy_,
+                CallMethodAndBlock(
+                    chromeos_dbus_bindings::IsDBusMethodCall(
+                        TestProxyInterface::kInterfaceName, method_name),
+                    ::testing::_))
+        .WillOnce(::testing::Return(
+            ::testing::ByMove(base::ok(std::move(response)))));
+  }
+
+  // Expects a blocking call of |method_name| and fails it with the D-Bus
+  // error.
+  void ExpectCallError(const char* method_name,
+                       const std::string& error_name,
+                       const std::string& error_message) {
+    EXPECT_CALL(*object_proxy_,
+                CallMethodAndBlock(
+                    chromeos_dbus_bindings::IsDBusMethodCall(
+                        TestProxyInterface::kInterfaceName, method_name),
+                    ::testing::_))
+        .WillOnce(::testing::Return(::testing::ByMove(
+            base::unexpected(dbus::Error(error_name, error_message)))));
+  }
+
+  // Expects an asynchronous call of |method_name| and replies with
+  // |response|.
+  void ExpectAsyncCall(const char* method_name,
+                       std::unique_ptr<dbus::Response> response) {
+    EXPECT_CALL(*object_proxy_,
+                DoCallMethodWithErrorCallback(
+                    chromeos_dbus_bindings::IsDBusMethodCall(
+                        TestProxyInterface::kInterfaceName, method_name),
+                    ::testing::_, ::testing::_, ::testing::_))
+        .WillOnce([response = std::move(response)](
+                      dbus::MethodCall* method_call, int timeout_ms,
+                      dbus::ObjectProxy::ResponseCallback* callback,
+                      dbus::ObjectProxy::ErrorCallback* error_callback) {
+          std::move(*callback).Run(response.get());
+        });
+  }
+
+  // Expects an asynchronous call of |method_name| and fails it with the
+  // D-Bus error.
+  void ExpectAsyncCallError(const char* method_name,
+                            const std::string& error_name,
+                            const std::string& error_message) {
+    EXPECT_CALL(*object_proxy_,
+                DoCallMethodWithErrorCallback(
+                    chromeos_dbus_bindings::IsDBusMethodCall(
+                        TestProxyInterface::kInterfaceName, method_name),
+                    ::testing::_, ::testing::_, ::testing::_))
+        .WillOnce([error_name, error_message](
+                      dbus::MethodCall* method_call, int timeout_ms,
+                      dbus::ObjectProxy::ResponseCallback* callback,
+                      dbus::ObjectProxy::ErrorCallback* error_callback) {
+          method_call->SetSerial(1);
+          std::unique_ptr<dbus::ErrorResponse> error =
+              dbus::ErrorResponse::FromMethodCall(method_call, error_name,
+                                                  error_message);
+          std::move(*error_callback).Run(error.get());
+        });
+  }
+
+  // Returns a response of Scan() carrying the output arguments.
+  static std::unique_ptr<dbus::Response> MakeScanResponse(
+      int32_t out_count,
+      const std::vector<std::string>& out_results) {
+    std::unique_ptr<dbus::Response> response = dbus::Response::CreateEmpty();
+    dbus::MessageWriter writer(response.get());
+    brillo::dbus_utils::DBusParamWriter::Append(
+        &writer, out_count, out_results);
+    return response;
+  }
+
+  // Expects a blocking call of Scan() and replies with the output
+  // arguments.
+  void ExpectScan(
+      int32_t out_count,
+      const std::vector<std::string>& out_results) {
+    ExpectCall(TestProxyInterface::kScanMethod,
+               MakeScanResponse(out_count, out_results));
+  }
+
+  // Expects an asynchronous call of ScanAsync() and replies with the
+  // output arguments.
+  void ExpectScanAsync(
+      int32_t out_count,
+      const std::vector<std::string>& out_results) {
+    ExpectAsyncCall(TestProxyInterface::kScanMethod,
+                    MakeScanResponse(out_count, out_results));
+  }
+
+  // Returns a response of Stop() carrying the output arguments.
+  static std::unique_ptr<dbus::Response> MakeStopResponse() {
+    std::unique_ptr<dbus::Response> response = dbus::Response::CreateEmpty();
+    return response;
+  }
+
+  // Expects a blocking call of Stop() and replies with the output
+  // arguments.
+  void ExpectStop() {
+    ExpectCall(TestProxyInterface::kStopMethod,
+               MakeStopResponse());
+  }
+
+  // Expects an asynchronous call of StopAsync() and replies with the
+  // output arguments.
+  void ExpectStopAsync() {
+    ExpectAsyncCall(TestProxyInterface::kStopMethod,
+                    MakeStopResponse());
+  }
+
+  scoped_refptr<dbus::MockBus> bus_;
+  scoped_refptr<dbus::MockObjectProxy> object_proxy_;
+  std::unique_ptr<TestProxy> proxy_;
+};
+
+}  // namespace chromium
+}  // namespace org
+
+#endif  // ____CHROMEOS_DBUS_BINDING___TMP_FIXTURE_H
+`
+	if diff := cmp.Diff(out.String(), want); diff != "" {
+		t.Errorf("Generate failed (-got +want):\n%s", diff)
+	}
+}
+
+func TestGenerateWithoutProxyFilePath(t *testing.T) {
+	out := new(bytes.Buffer)
+	if err := Generate(nil, out, "/tmp/fixture.h", "", serviceconfig.Config{}); err == nil {
+		t.Error("Generate unexpectedly succeeded without the proxy file path")
+	}
+}
@@ -0,0 +1,161 @@
+// Copyright 2022 The ChromiumOS Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+// Package ts outputs TypeScript client stubs based on introspects, for web UIs
+// calling D-Bus methods through a bridge.
+package ts
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+
+	"go.chromium.org/chromiumos/dbusbindings/dbustype"
+	"go.chromium.org/chromiumos/dbusbindings/generate/genutil"
+	"go.chromium.org/chromiumos/dbusbindings/introspect"
+	"go.chromium.org/chromiumos/dbusbindings/serviceconfig"
+)
+
+var funcMap = template.FuncMap{
+	"makeFields":     makeFields,
+	"makeLowerCamel": makeLowerCamel,
+	"makeTypeName":   genutil.MakeTypeName,
+}
+
+const templateText = `// Automatic generation of TypeScript D-Bus client stubs for:
+{{range .Introspects}}{{range .Interfaces -}}
+//  - {{.Name}}
+{{end}}{{end}}
+/** Bridge forwarding D-Bus method calls, implemented by the embedder. */
+export interface DBusBridge {
+  callMethod(
+      service: string, objectPath: string, interfaceName: string,
+      method: string, args: unknown[]): Promise<unknown[]>;
+}
+{{- if .ServiceName}}
+
+export const SERVICE_NAME = '{{.ServiceName}}';
+{{- end}}
+{{range $introspect := .Introspects}}{{range $itf := .Interfaces}}
+{{- $typeName := makeTypeName .Name}}
+// {{.Name}}
+
+export const {{$typeName}}InterfaceName = '{{.Name}}';
+{{- if $introspect.Name}}
+export const {{$typeName}}ObjectPath = '{{$introspect.Name}}';
+{{- end}}
+{{- if .Methods}}
+
+export const {{$typeName}}Methods = {
+{{- range .Methods}}
+  {{.Name}}: '{{.Name}}',
+{{- end}}
+} as const;
+{{- end}}
+{{- if .Signals}}
+
+export const {{$typeName}}Signals = {
+{{- range .Signals}}
+  {{.Name}}: '{{.Name}}',
+{{- end}}
+} as const;
+{{- end}}
+{{- range $method := .Methods}}
+{{- with makeFields .InputArguments}}
+
+export interface {{$typeName}}{{$method.Name}}Args {
+{{- range .}}
+  {{.Name}}: {{.Type}};
+{{- end}}
+}
+{{- end}}
+{{- with makeFields .OutputArguments}}
+
+export interface {{$typeName}}{{$method.Name}}Result {
+{{- range .}}
+  {{.Name}}: {{.Type}};
+{{- end}}
+}
+{{- end}}
+{{- end}}
+
+/** Client for {{.Name}}. */
+export class {{$typeName}}Client {
+  constructor(
+      private readonly bridge: DBusBridge, private readonly service: string,
+      private readonly objectPath: string) {}
+{{- range $method := .Methods}}
+{{- $in := makeFields .InputArguments}}
+{{- $out := makeFields .OutputArguments}}
+
+  async {{makeLowerCamel .Name}}(
+      {{- if $in}}args: {{$typeName}}{{.Name}}Args{{end -}}
+  ): Promise<{{if $out}}{{$typeName}}{{.Name}}Result{{else}}void{{end}}> {
+    {{if $out}}const out = {{end}}await this.bridge.callMethod(
+        this.service, this.objectPath, {{$typeName}}InterfaceName,
+        {{$typeName}}Methods.{{.Name}},
+        [{{range $i, $f := $in}}{{if $i}}, {{end}}args.{{$f.Name}}{{end}}]);
+{{- if $out}}
+    return {
+{{- range $i, $f := $out}}
+      {{$f.Name}}: out[{{$i}}] as {{$f.Type}},
+{{- end}}
+    };
+{{- end}}
+  }
+{{- end}}
+}
+{{end}}{{end -}}
+`
+
+// field is a member of the TypeScript interfaces of method arguments.
+type field struct {
+	Name string
+	Type string
+}
+
+// makeFields returns the fields corresponding to args. Unnamed arguments are
+// named after their indices.
+func makeFields(args []introspect.MethodArg) ([]field, error) {
+	var ret []field
+	for i, a := range args {
+		t, err := dbustype.TSType(string(a.Type))
+		if err != nil {
+			return nil, err
+		}
+		name := fmt.Sprintf("arg%d", i)
+		if a.Name != "" {
+			name = makeLowerCamel(a.Name)
+		}
+		ret = append(ret, field{Name: name, Type: t})
+	}
+	return ret, nil
+}
+
+// makeLowerCamel converts the name to a lowerCamelCase name, e.g. "GetPath"
+// and "get_path" to "getPath".
+func makeLowerCamel(s string) string {
+	c := genutil.MakeCamelCaseName(s)
+	if c == "" {
+		return c
+	}
+	return strings.ToLower(c[:1]) + c[1:]
+}
+
+// Generate outputs the TypeScript client stubs for the interfaces included in
+// introspects into f.
+func Generate(introspects []introspect.Introspection, f io.Writer, config serviceconfig.Config) error {
+	tmpl, err := template.New("ts").Funcs(funcMap).Parse(templateText)
+	if err != nil {
+		return err
+	}
+	return tmpl.Execute(f, struct {
+		Introspects []introspect.Introspection
+		ServiceName string
+	}{
+		Introspects: introspects,
+		ServiceName: config.ServiceName,
+	})
+}
@@ -0,0 +1,112 @@
+// Copyright 2022 The ChromiumOS Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package ts
+
+import (
+	"bytes"
+	"testing"
+
+	"go.chromium.org/chromiumos/dbusbindings/introspect"
+	"go.chromium.org/chromiumos/dbusbindings/serviceconfig"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGenerate(t *testing.T) {
+	introspections := []introspect.Introspection{{
+		Name: "/org/chromium/Test",
+		Interfaces: []introspect.Interface{{
+			Name: "org.chromium.Test",
+			Methods: []introspect.Method{
+				{
+					Name: "Scan",
+					Args: []introspect.MethodArg{
+						{Name: "device_name", Type: "s"},
+						{Type: "a{sv}"},
+						{Name: "count", Type: "x", Direction: "out"},
+						{Name: "results", Type: "a(ob)", Direction: "out"},
+					},
+				}, {
+					Name: "Stop",
+				},
+			},
+			Signals: []introspect.Signal{
+				{Name: "ScanDone"},
+			},
+		}},
+	}}
+
+	out := new(bytes.Buffer)
+	sc := serviceconfig.Config{ServiceName: "org.chromium.TestService"}
+	if err := Generate(introspections, out, sc); err != nil {
+		t.Fatalf("Generate got error, want nil: %v", err)
+	}
+
+	const want = `// Automatic generation of TypeScript D-Bus client stubs for:
+//  - org.chromium.Test
+
+/** Bridge forwarding D-Bus method calls, implemented by the embedder. */
+export interface DBusBridge {
+  callMethod(
+      service: string, objectPath: string, interfaceName: string,
+      method: string, args: unknown[]): Promise<unknown[]>;
+}
+
+export const SERVICE_NAME = 'org.chromium.TestService';
+
+// org.chromium.Test
+
+export const TestInterfaceName = 'org.chromium.Test';
+export const TestObjectPath = '/org/chromium/Test';
+
+export const TestMethods = {
+  Scan: 'Scan',
+  Stop: 'Stop',
+} as const;
+
+export const TestSignals = {
+  ScanDone: 'ScanDone',
+} as const;
+
+export interface TestScanArgs {
+  deviceName: string;
+  arg1: Record<string, unknown>;
+}
+
+export interface TestScanResult {
+  count: bigint;
+  results: [string, boolean][];
+}
+
+/** Client for org.chromium.Test. */
+export class TestClient {
+  constructor(
+      private readonly bridge: DBusBridge, private readonly service: string,
+      private readonly objectPath: string) {}
+
+  async scan(args: TestScanArgs): Promise<TestScanResult> {
+    const out = await this.bridge.callMethod(
+        this.service, this.objectPath, TestInterfaceName,
+        TestMethods.Scan,
+        [args.deviceName, args.arg1]);
+    return {
+      count: out[0] as bigint,
+      results: out[1] as [string, boolean][],
+    };
+  }
+
+  async stop(): Promise<void> {
+    await this.bridge.callMethod(
+        this.service, this.objectPath, TestInterfaceName,
+        TestMethods.Stop,
+        []);
+  }
+}
+`
+
+	if diff := cmp.Diff(out.String(), want); diff != "" {
+		t.Errorf("Generate failed (-got +want):\n%s", diff)
+	}
+}
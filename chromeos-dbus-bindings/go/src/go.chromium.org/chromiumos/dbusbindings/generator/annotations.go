@@ -0,0 +1,136 @@
+// Copyright 2022 The ChromiumOS Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package generator
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"go.chromium.org/chromiumos/dbusbindings/describe"
+	"go.chromium.org/chromiumos/dbusbindings/introspect"
+)
+
+// annotationNamespace is the namespace of the annotations interpreted by the
+// generator. The annotations in other namespaces are left to other tools, and
+// not checked.
+const annotationNamespace = "org.chromium.DBus."
+
+// maxSuggestionDistance is the largest edit distance between an unknown
+// annotation and a supported one suggested for it.
+const maxSuggestionDistance = 3
+
+// checkAnnotations reports the unknown annotations in introspects, parsed
+// from path, as warnings, or as an error if strict is true.
+func checkAnnotations(path string, introspects []introspect.Introspection, strict bool) error {
+	issues := unknownAnnotations(introspects)
+	if len(issues) == 0 {
+		return nil
+	}
+	if strict {
+		return fmt.Errorf("%s: %s", path, strings.Join(issues, "; "))
+	}
+	for _, i := range issues {
+		log.Printf("Warning: %s: %s", path, i)
+	}
+	return nil
+}
+
+// unknownAnnotations returns the issues of the annotations in the
+// org.chromium.DBus namespace which are not supported, e.g. misspelled ones
+// which would be silently ignored, with the supported names close to them.
+func unknownAnnotations(introspects []introspect.Introspection) []string {
+	known := make(map[string]bool)
+	var names []string
+	for _, a := range describe.Describe().Annotations {
+		known[a.Name] = true
+		names = append(names, a.Name)
+	}
+
+	var ret []string
+	check := func(location string, a introspect.Annotation) {
+		if a.Name == "" || known[a.Name] || !strings.HasPrefix(a.Name, annotationNamespace) {
+			return
+		}
+		issue := fmt.Sprintf("%s: unknown annotation %s", location, a.Name)
+		if s := suggestAnnotation(a.Name, names); s != "" {
+			issue += fmt.Sprintf("; did you mean %s?", s)
+		}
+		ret = append(ret, issue)
+	}
+	for _, is := range introspects {
+		for _, itf := range is.Interfaces {
+			for _, a := range itf.Annotations {
+				check(itf.Name, a)
+			}
+			for _, m := range itf.Methods {
+				loc := itf.Name + "." + m.Name
+				for _, a := range m.Annotations {
+					check(loc, a)
+				}
+				for _, arg := range m.Args {
+					for _, a := range arg.Annotations {
+						check(fmt.Sprintf("%s argument %s", loc, arg.Name), a)
+					}
+				}
+			}
+			for _, s := range itf.Signals {
+				loc := itf.Name + "." + s.Name
+				for _, a := range s.Annotations {
+					check(loc, a)
+				}
+				for _, arg := range s.Args {
+					for _, a := range arg.Annotations {
+						check(fmt.Sprintf("%s argument %s", loc, arg.Name), a)
+					}
+				}
+			}
+			for _, p := range itf.Properties {
+				for _, a := range p.Annotations {
+					check(itf.Name+"."+p.Name, a)
+				}
+			}
+		}
+	}
+	return ret
+}
+
+// suggestAnnotation returns the name in names closest to name, or "" if none
+// is within maxSuggestionDistance. The names are compared case-insensitively.
+func suggestAnnotation(name string, names []string) string {
+	best, bestDistance := "", maxSuggestionDistance+1
+	for _, n := range names {
+		if d := editDistance(strings.ToLower(name), strings.ToLower(n)); d < bestDistance {
+			best, bestDistance = n, d
+		}
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cur[j] = prev[j-1]
+			if a[i-1] != b[j-1] {
+				cur[j]++
+			}
+			if prev[j]+1 < cur[j] {
+				cur[j] = prev[j] + 1
+			}
+			if cur[j-1]+1 < cur[j] {
+				cur[j] = cur[j-1] + 1
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
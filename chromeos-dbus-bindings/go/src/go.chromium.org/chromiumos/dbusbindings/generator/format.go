@@ -0,0 +1,60 @@
+// Copyright 2022 The ChromiumOS Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// defaultFormatStyle is the clang-format style applied to the generated C++
+// files unless a style file is given. The includes are kept in the order
+// emitted by the templates, as they are grouped deliberately.
+const defaultFormatStyle = "{BasedOnStyle: Chromium, SortIncludes: false}"
+
+// formatter formats the generated C++ files with clang-format.
+type formatter struct {
+	// binary is the path to the clang-format executable.
+	binary string
+	// styleFile is the path to the .clang-format file to use instead of
+	// defaultFormatStyle, if not empty.
+	styleFile string
+}
+
+// isCppFile returns true if the file at path is a C++ header or source file.
+func isCppFile(path string) bool {
+	switch filepath.Ext(path) {
+	case ".h", ".cc":
+		return true
+	}
+	return false
+}
+
+// format returns src, the contents of the file at path, formatted by
+// clang-format. The files other than C++ ones are returned as is.
+func (f *formatter) format(path string, src []byte) ([]byte, error) {
+	if f == nil || !isCppFile(path) {
+		return src, nil
+	}
+	style := defaultFormatStyle
+	if f.styleFile != "" {
+		style = "file:" + f.styleFile
+	}
+	cmd := exec.Command(f.binary, "--style="+style, "--assume-filename="+path)
+	cmd.Stdin = bytes.NewReader(src)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			err = fmt.Errorf("%v: %s", err, msg)
+		}
+		return nil, fmt.Errorf("failed to format %s with %s: %v", path, f.binary, err)
+	}
+	return out, nil
+}
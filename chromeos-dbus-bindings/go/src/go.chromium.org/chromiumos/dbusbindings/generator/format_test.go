@@ -0,0 +1,141 @@
+// Copyright 2022 The ChromiumOS Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package generator
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestIsCppFile(t *testing.T) {
+	cases := []struct {
+		path string
+		want bool
+	}{
+		{path: "proxies.h", want: true},
+		{path: "out/adaptor.cc", want: true},
+		{path: "service.h.in", want: false},
+		{path: "proxies.hpp", want: false},
+		{path: "docs.md", want: false},
+		{path: "manifest.json", want: false},
+		{path: "h", want: false},
+	}
+	for _, tc := range cases {
+		if got := isCppFile(tc.path); got != tc.want {
+			t.Errorf("isCppFile(%q) = %t, want %t", tc.path, got, tc.want)
+		}
+	}
+}
+
+// writeFakeClangFormat writes into dir a fake clang-format executable, which
+// records its arguments into dir/args and runs script on its input.
+func writeFakeClangFormat(t *testing.T, dir, script string) string {
+	path := filepath.Join(dir, "clang-format")
+	contents := "#!/bin/sh\necho \"$@\" > " + filepath.Join(dir, "args") + "\n" + script + "\n"
+	if err := ioutil.WriteFile(path, []byte(contents), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// readFakeArgs returns the arguments the fake clang-format in dir was run
+// with, or an empty string if it was not run.
+func readFakeArgs(t *testing.T, dir string) string {
+	b, err := ioutil.ReadFile(filepath.Join(dir, "args"))
+	if os.IsNotExist(err) {
+		return ""
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.TrimSpace(string(b))
+}
+
+func TestFormat(t *testing.T) {
+	dir, err := ioutil.TempDir("", "format_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	f := &formatter{binary: writeFakeClangFormat(t, dir, "tr a-z A-Z")}
+	got, err := f.format("out/proxies.h", []byte("int foo;\n"))
+	if err != nil {
+		t.Fatalf("format got error, want nil: %v", err)
+	}
+	if diff := cmp.Diff(string(got), "INT FOO;\n"); diff != "" {
+		t.Errorf("format failed (-got +want):\n%s", diff)
+	}
+	wantArgs := "--style=" + defaultFormatStyle + " --assume-filename=out/proxies.h"
+	if diff := cmp.Diff(readFakeArgs(t, dir), wantArgs); diff != "" {
+		t.Errorf("format ran clang-format with unexpected arguments (-got +want):\n%s", diff)
+	}
+}
+
+func TestFormatStyleFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "format_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	f := &formatter{binary: writeFakeClangFormat(t, dir, "cat"), styleFile: "/src/.clang-format"}
+	if _, err := f.format("adaptor.cc", []byte("int foo;\n")); err != nil {
+		t.Fatalf("format got error, want nil: %v", err)
+	}
+	wantArgs := "--style=file:/src/.clang-format --assume-filename=adaptor.cc"
+	if diff := cmp.Diff(readFakeArgs(t, dir), wantArgs); diff != "" {
+		t.Errorf("format ran clang-format with unexpected arguments (-got +want):\n%s", diff)
+	}
+}
+
+func TestFormatNonCppFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "format_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	f := &formatter{binary: writeFakeClangFormat(t, dir, "tr a-z A-Z")}
+	got, err := f.format("docs.md", []byte("# foo\n"))
+	if err != nil {
+		t.Fatalf("format got error, want nil: %v", err)
+	}
+	if diff := cmp.Diff(string(got), "# foo\n"); diff != "" {
+		t.Errorf("format changed a non C++ file (-got +want):\n%s", diff)
+	}
+	if args := readFakeArgs(t, dir); args != "" {
+		t.Errorf("format ran clang-format on a non C++ file with %q", args)
+	}
+}
+
+func TestFormatFailure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "format_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	f := &formatter{binary: writeFakeClangFormat(t, dir, "echo 'invalid style' >&2; exit 1")}
+	_, err = f.format("proxies.h", []byte("int foo;\n"))
+	if err == nil {
+		t.Fatal("format succeeded, want error")
+	}
+	for _, want := range []string{"failed to format proxies.h", "invalid style"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("format returned error %q, want it to contain %q", err, want)
+		}
+	}
+
+	f = &formatter{binary: filepath.Join(dir, "nonexistent")}
+	if _, err := f.format("proxies.h", []byte("int foo;\n")); err == nil {
+		t.Error("format with a missing binary succeeded, want error")
+	}
+}
@@ -0,0 +1,907 @@
+// Copyright 2022 The ChromiumOS Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+// Package generator generates the D-Bus bindings from the introspection XML
+// files. It is the library behind generate-chromeos-dbus-bindings, so that
+// other Go tools can generate the bindings without running the binary.
+package generator
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"go.chromium.org/chromiumos/dbusbindings/generate/adaptor"
+	"go.chromium.org/chromiumos/dbusbindings/generate/cliexamples"
+	"go.chromium.org/chromiumos/dbusbindings/generate/constants"
+	"go.chromium.org/chromiumos/dbusbindings/generate/docs"
+	"go.chromium.org/chromiumos/dbusbindings/generate/fakeargs"
+	"go.chromium.org/chromiumos/dbusbindings/generate/fuzzer"
+	"go.chromium.org/chromiumos/dbusbindings/generate/gdbus"
+	"go.chromium.org/chromiumos/dbusbindings/generate/genutil"
+	"go.chromium.org/chromiumos/dbusbindings/generate/idl"
+	"go.chromium.org/chromiumos/dbusbindings/generate/metadata"
+	"go.chromium.org/chromiumos/dbusbindings/generate/methodnames"
+	"go.chromium.org/chromiumos/dbusbindings/generate/policy"
+	"go.chromium.org/chromiumos/dbusbindings/generate/proxy"
+	"go.chromium.org/chromiumos/dbusbindings/generate/python"
+	"go.chromium.org/chromiumos/dbusbindings/generate/testfixture"
+	"go.chromium.org/chromiumos/dbusbindings/generate/ts"
+	"go.chromium.org/chromiumos/dbusbindings/introspect"
+	"go.chromium.org/chromiumos/dbusbindings/serviceconfig"
+)
+
+// Options specifies the inputs and the outputs of Run. Each field corresponds
+// to the command line flag of generate-chromeos-dbus-bindings with the same
+// name, and an output is generated only if its path is not empty.
+type Options struct {
+	// ServiceConfigPath is the path to the service config JSON file.
+	ServiceConfigPath string
+	// ServicesPath is the path to the JSON manifest listing several services
+	// whose proxies are generated together. It cannot be combined with
+	// ServiceConfigPath or Inputs.
+	ServicesPath string
+
+	MethodNamesPath string
+	ConstantsPath   string
+	MetadataPath    string
+	FakeArgsPath    string
+	AdaptorPath     string
+	// AdaptorDir is the directory where the adaptors are split into the
+	// output files listed in the service config.
+	AdaptorDir string
+	FuzzerPath string
+	ProxyPath  string
+	// ProxySourcePath is the output source file defining the methods of the
+	// proxy classes, which ProxyPath then only declares.
+	ProxySourcePath string
+	MockPath        string
+	TestFixturePath string
+	LoopbackPath    string
+	PimplProxyPath  string
+	PimplSourcePath string
+	TSPath          string
+	// GDBusPath is the output C header with the GDBusProxy based client
+	// functions.
+	GDBusPath string
+	// PythonPath is the output Python module with the dbus-python based
+	// client classes.
+	PythonPath    string
+	GRPCProtoPath string
+	// DocsPath is the output API reference of the interfaces. It is HTML if
+	// the extension is .html, and Markdown otherwise.
+	DocsPath string
+	// CLIExamplesPath is the output gdbus call and dbus-send command lines
+	// calling the methods.
+	CLIExamplesPath string
+	PolicyPath      string
+	ServiceFilePath string
+	// ManifestPath is the output JSON listing the other outputs with the
+	// interfaces they contain, and the inputs with their hashes, for the
+	// build rules.
+	ManifestPath string
+	// ProxyPathForMocks is the path to the proxy header included by the mock.
+	// If empty, it is derived from ProxyPath.
+	ProxyPathForMocks string
+	// CompileTestsDir is the directory where a source file calling the
+	// methods of the proxies is generated per interface, to be compiled in
+	// the build.
+	CompileTestsDir string
+	// AbstractOnly generates only the abstract proxy interfaces into ProxyPath.
+	AbstractOnly bool
+	// SignalSendersForTesting also generates into ProxyPath the functions
+	// sending the signals to the proxies for testing.
+	SignalSendersForTesting bool
+	// CppModules generates into ProxyPath a C++20 module interface unit
+	// exporting the proxy classes instead of a header. Experimental.
+	CppModules bool
+	// NoBrillo generates into ProxyPath the proxies depending only on
+	// libchrome, for the consumers inside Chromium which cannot depend on
+	// brillo.
+	NoBrillo bool
+	// StrictDirections makes the method arguments without directions and
+	// the signal arguments with directions errors instead of warnings.
+	StrictDirections bool
+	// FixDirections rewrites the inputs to fix the directions reported by
+	// StrictDirections. The rewritten inputs are returned as artifacts.
+	FixDirections bool
+	// StrictAnnotations makes the unknown annotations in the org.chromium.DBus
+	// namespace, e.g. misspelled ones, errors instead of warnings.
+	StrictAnnotations bool
+	// Incremental embeds the hash of the inputs into the outputs. It implies
+	// Reproducible, as the outputs must not change unless the inputs do.
+	Incremental bool
+	// Reproducible omits the generation time from the trailers of the
+	// outputs, so that they only depend on the inputs.
+	Reproducible bool
+	// CommandLine is the command line recorded in the trailers of the
+	// outputs, if not empty. It is omitted by default, as it usually has
+	// paths depending on the build environment.
+	CommandLine []string
+	// InputRoot is the directory which the paths of the inputs recorded in
+	// the trailers of the outputs are relative to. If empty, they are
+	// relative to the current directory.
+	InputRoot string
+	// Stream makes Run return the outputs without their contents, which
+	// Artifacts.Write then generates straight into the files, so that they
+	// are not held in memory. The outputs which are formatted, hashed or
+	// listed in the manifest are still returned with their contents.
+	Stream bool
+
+	// ClangFormatPath is the path to the clang-format executable formatting
+	// the C++ outputs, and ClangFormatStyle the path to its style file.
+	// The outputs are not formatted if ClangFormatPath is empty.
+	ClangFormatPath  string
+	ClangFormatStyle string
+
+	// Inputs are the paths to the introspection XML files.
+	Inputs []string
+}
+
+// Artifact is a generated output file.
+type Artifact struct {
+	Path string
+	// Contents is nil if the artifact is streamed; see Options.Stream.
+	Contents []byte
+	// Interfaces are the names of the D-Bus interfaces whose code the
+	// artifact contains.
+	Interfaces []string
+	// write generates the contents into w if the artifact is streamed.
+	write func(w io.Writer) error
+}
+
+// Artifacts is the list of the outputs generated by Run, in the order of
+// generation.
+type Artifacts []Artifact
+
+// Write writes the artifacts into the file system, creating the parent
+// directories as needed. If keepUnchanged is true, the files whose contents
+// are unchanged are not rewritten so that their mtime is preserved and the
+// files depending on them are not rebuilt. The streamed artifacts are
+// generated here, and always rewritten.
+func (a Artifacts) Write(keepUnchanged bool) error {
+	for _, art := range a {
+		if err := os.MkdirAll(filepath.Dir(art.Path), 0755); err != nil {
+			return fmt.Errorf("failed to create directory for %s: %v", art.Path, err)
+		}
+		if art.write != nil {
+			if err := writeStreamed(art.Path, art.write); err != nil {
+				return fmt.Errorf("failed to write file %s: %v", art.Path, err)
+			}
+			continue
+		}
+		if keepUnchanged {
+			if old, err := ioutil.ReadFile(art.Path); err == nil && bytes.Equal(old, art.Contents) {
+				continue
+			}
+		}
+		if err := ioutil.WriteFile(art.Path, art.Contents, 0644); err != nil {
+			return fmt.Errorf("failed to write file %s: %v", art.Path, err)
+		}
+	}
+	return nil
+}
+
+// writeStreamed writes the output of write into the file at path. The output
+// is written into a temporary file renamed to path on success, so that a
+// failure does not leave a truncated file behind.
+func writeStreamed(path string, write func(w io.Writer) error) (err error) {
+	f, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+	w := bufio.NewWriter(f)
+	if err := write(w); err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if err := f.Chmod(0644); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// emitter collects the outputs of the generators as artifacts.
+type emitter struct {
+	// hash is embedded into the outputs as the hash of the inputs, if not empty.
+	hash string
+	// fm formats the C++ outputs, if not nil.
+	fm        *formatter
+	artifacts Artifacts
+	// inputs are the paths to the files read to generate the artifacts.
+	inputs []string
+	// info is recorded in the trailers of the outputs.
+	info trailerInfo
+	// trailer is the lines of the trailers, made from info and inputs on the
+	// first output.
+	trailer []string
+	// commentExts maps the paths of the outputs whose syntax is not told by
+	// their extensions to the extensions telling it.
+	commentExts map[string]string
+	// stream makes the outputs which are neither hashed nor formatted
+	// streamed into the files by Artifacts.Write.
+	stream bool
+}
+
+// commentExt returns the extension telling the comment syntax of the output
+// at path.
+func (e *emitter) commentExt(path string) string {
+	if ext, ok := e.commentExts[path]; ok {
+		return ext
+	}
+	return filepath.Ext(path)
+}
+
+// emit adds the output of gen as the artifact at path, containing the
+// interfaces in introspects. The output is buffered only if it is hashed or
+// formatted, or the artifacts are not streamed.
+func (e *emitter) emit(path string, introspects []introspect.Introspection, gen func(f io.Writer) error) error {
+	var itfs []string
+	for _, is := range introspects {
+		for _, itf := range is.Interfaces {
+			itfs = append(itfs, itf.Name)
+		}
+	}
+	if e.trailer == nil {
+		var err error
+		if e.trailer, err = makeTrailer(e.info, e.inputs); err != nil {
+			return err
+		}
+	}
+	trailer := "\n" + commentLines(e.commentExt(path), e.trailer)
+
+	if e.stream && e.hash == "" && (e.fm == nil || !isCppFile(path)) {
+		e.artifacts = append(e.artifacts, Artifact{Path: path, Interfaces: itfs, write: func(w io.Writer) error {
+			if err := gen(w); err != nil {
+				return err
+			}
+			_, err := io.WriteString(w, trailer)
+			return err
+		}})
+		return nil
+	}
+
+	var b bytes.Buffer
+	if err := gen(&b); err != nil {
+		return err
+	}
+	out, err := e.fm.format(path, b.Bytes())
+	if err != nil {
+		return err
+	}
+	if e.hash != "" {
+		out = append([]byte(hashComment(e.commentExt(path), e.hash)), out...)
+	}
+	out = append(out, trailer...)
+	e.artifacts = append(e.artifacts, Artifact{Path: path, Contents: out, Interfaces: itfs})
+	return nil
+}
+
+// emitManifest adds the manifest of the artifacts emitted so far as the
+// artifact at path.
+func (e *emitter) emitManifest(path string) error {
+	b, err := makeManifest(e.inputs, e.artifacts)
+	if err != nil {
+		return err
+	}
+	e.artifacts = append(e.artifacts, Artifact{Path: path, Contents: b})
+	return nil
+}
+
+// hashComment returns the line embedding the hash of the inputs into the
+// files with the extension ext, commented out in their syntax.
+func hashComment(ext, hash string) string {
+	return commentLines(ext, []string{"Input hash: sha256:" + hash})
+}
+
+// checkDirections reports the method arguments without directions and the
+// signal arguments with directions in the interface file at path as
+// warnings, or as an error if strict is true. If fix is true, the rewritten
+// file is returned instead.
+func checkDirections(path string, strict, fix bool) (*Artifact, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read interface file %s: %v", path, err)
+	}
+	issues, err := introspect.CheckDirections(b)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse interface file %s: %v", path, err)
+	}
+	if len(issues) == 0 {
+		return nil, nil
+	}
+	if fix {
+		fixed, err := introspect.FixDirections(b)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fix interface file %s: %v", path, err)
+		}
+		return &Artifact{Path: path, Contents: fixed}, nil
+	}
+	if strict {
+		return nil, fmt.Errorf("%s: %s", path, strings.Join(issues, "; "))
+	}
+	for _, i := range issues {
+		log.Printf("Warning: %s: %s", path, i)
+	}
+	return nil, nil
+}
+
+// parseInput parses the interface file at path, checking the directions of
+// its arguments and its annotations as requested by o. The file rewritten by
+// -fix-directions is returned if any.
+func parseInput(path string, o Options) (introspect.Introspection, *Artifact, error) {
+	fixed, err := checkDirections(path, o.StrictDirections, o.FixDirections)
+	if err != nil {
+		return introspect.Introspection{}, nil, err
+	}
+	introspection, err := introspect.ParseFile(path)
+	if err != nil {
+		return introspect.Introspection{}, nil, fmt.Errorf("failed to parse interface file %s: %v", path, err)
+	}
+	if err := checkAnnotations(path, introspection.Flatten(), o.StrictAnnotations); err != nil {
+		return introspect.Introspection{}, nil, err
+	}
+	return introspection, fixed, nil
+}
+
+// unknownMethodTimeouts returns the methods in timeouts which the interfaces
+// in introspects do not have, sorted, so that typos in the service config are
+// reported.
+func unknownMethodTimeouts(introspects []introspect.Introspection, timeouts map[string]int) []string {
+	known := make(map[string]bool)
+	for _, is := range introspects {
+		for _, itf := range is.Interfaces {
+			for _, m := range itf.Methods {
+				known[itf.Name+"."+m.Name] = true
+			}
+		}
+	}
+	var ret []string
+	for name := range timeouts {
+		if !known[name] {
+			ret = append(ret, name)
+		}
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// applyServiceConfig prepares introspections for generation with sc, logging
+// the issues found as warnings, and resolves the namespace overrides into sc.
+// Run and -services share it, so that a service generates the same proxies in
+// both modes.
+func applyServiceConfig(introspections []introspect.Introspection, sc *serviceconfig.Config) ([]introspect.Introspection, error) {
+	introspections, warnings, err := genutil.RenameReservedIdentifiers(introspections)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check identifiers: %v", err)
+	}
+	for _, w := range warnings {
+		log.Printf("Warning: %s", w)
+	}
+	for _, d := range genutil.FindDuplicateMembers(introspections) {
+		log.Printf("Warning: %s", d)
+	}
+	for _, name := range unknownMethodTimeouts(introspections, sc.MethodTimeouts) {
+		log.Printf("Warning: method_timeouts: %s is not a method of the interfaces", name)
+	}
+	introspections = genutil.OmitNewerMembers(introspections, sc.TargetVersion)
+	introspections = genutil.ApplyTypeMappings(introspections, sc.TypeMappings)
+	overrides, err := genutil.ApplyDefaultNameSpace(introspections, sc.NamespaceOverrides, sc.DefaultNamespace)
+	if err != nil {
+		return nil, err
+	}
+	sc.NamespaceOverrides = overrides
+	return introspections, nil
+}
+
+// makeProxyIntrospections returns introspections without the members skipped
+// from the proxies, and with the interfaces whose object paths are overridden
+// in sc moved to them, so that their proxies are created at the paths.
+func makeProxyIntrospections(introspections []introspect.Introspection, sc *serviceconfig.Config) []introspect.Introspection {
+	return genutil.ApplyObjectPaths(genutil.OmitSkippedMembers(introspections, introspect.SkipTargetProxy), sc.Interfaces)
+}
+
+// makeTrailerInfo returns how the outputs of o are generated.
+func makeTrailerInfo(o Options) trailerInfo {
+	info := trailerInfo{commandLine: o.CommandLine, inputRoot: o.InputRoot}
+	if !o.Reproducible && !o.Incremental {
+		info.generatedAt = time.Now()
+	}
+	return info
+}
+
+// Inputs returns the paths of the files read by Run with o, i.e. the services
+// manifest, the service configs, and the interface files followed by the files
+// they include, each once. They are the inputs listed in the manifest and the
+// trailers.
+func Inputs(o Options) ([]string, error) {
+	var paths []string
+	seen := make(map[string]bool)
+	add := func(path string) {
+		if !seen[path] {
+			seen[path] = true
+			paths = append(paths, path)
+		}
+	}
+	addInterfaces := func(inputs []string) error {
+		for _, in := range inputs {
+			_, files, err := introspect.ParseFileWithIncludes(in)
+			if err != nil {
+				return fmt.Errorf("failed to parse interface file %s: %v", in, err)
+			}
+			for _, f := range files {
+				add(f)
+			}
+		}
+		return nil
+	}
+
+	if o.ServicesPath != "" {
+		m, err := loadServicesManifest(o.ServicesPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read services manifest %s: %v", o.ServicesPath, err)
+		}
+		add(o.ServicesPath)
+		for _, s := range m.Services {
+			if s.ServiceConfig != "" {
+				add(s.ServiceConfig)
+			}
+			if err := addInterfaces(s.Inputs); err != nil {
+				return nil, err
+			}
+		}
+		return paths, nil
+	}
+
+	if o.ServiceConfigPath != "" {
+		add(o.ServiceConfigPath)
+	}
+	if err := addInterfaces(o.Inputs); err != nil {
+		return nil, err
+	}
+	return paths, nil
+}
+
+// Run parses the inputs, and generates all the outputs requested by o.
+// Nothing is written to the file system; see Artifacts.Write.
+func Run(o Options) (Artifacts, error) {
+	var fm *formatter
+	if o.ClangFormatPath != "" {
+		fm = &formatter{binary: o.ClangFormatPath, styleFile: o.ClangFormatStyle}
+	} else if o.ClangFormatStyle != "" {
+		return nil, errors.New("-clang-format-style requires -clang-format")
+	}
+
+	if o.CppModules {
+		// The other outputs include the proxy header, which is not generated.
+		if o.ProxyPath == "" || o.AbstractOnly || o.SignalSendersForTesting {
+			return nil, errors.New("-cpp-modules requires -proxy, and cannot be combined with -abstract-only or -signal-senders-for-testing")
+		}
+		if o.MockPath != "" || o.TestFixturePath != "" || o.LoopbackPath != "" || o.PimplProxyPath != "" || o.CompileTestsDir != "" || o.ServicesPath != "" {
+			return nil, errors.New("-cpp-modules cannot be combined with -mock, -test-fixture, -loopback, -pimpl-proxy, -compile-tests or -services")
+		}
+	}
+	if o.NoBrillo {
+		// The other outputs depend on the brillo proxies.
+		if o.ProxyPath == "" || o.AbstractOnly || o.SignalSendersForTesting || o.CppModules {
+			return nil, errors.New("-no-brillo requires -proxy, and cannot be combined with -abstract-only, -signal-senders-for-testing or -cpp-modules")
+		}
+		if o.MockPath != "" || o.TestFixturePath != "" || o.LoopbackPath != "" || o.PimplProxyPath != "" || o.CompileTestsDir != "" || o.ServicesPath != "" {
+			return nil, errors.New("-no-brillo cannot be combined with -mock, -test-fixture, -loopback, -pimpl-proxy, -compile-tests or -services")
+		}
+	}
+
+	if o.StrictDirections && o.FixDirections {
+		return nil, errors.New("-strict-directions cannot be combined with -fix-directions")
+	}
+
+	if o.ServicesPath != "" {
+		if len(o.Inputs) > 0 || o.ServiceConfigPath != "" {
+			return nil, errors.New("-services cannot be combined with interface files or -service-config")
+		}
+		// The outputs and the modes other than the proxies are not ignored
+		// silently.
+		if o.MethodNamesPath != "" || o.ConstantsPath != "" || o.MetadataPath != "" || o.FakeArgsPath != "" ||
+			o.AdaptorPath != "" || o.AdaptorDir != "" || o.FuzzerPath != "" || o.ProxyPath != "" ||
+			o.ProxySourcePath != "" || o.MockPath != "" || o.TestFixturePath != "" || o.LoopbackPath != "" ||
+			o.PimplProxyPath != "" || o.PimplSourcePath != "" || o.TSPath != "" || o.GDBusPath != "" ||
+			o.PythonPath != "" || o.GRPCProtoPath != "" || o.DocsPath != "" || o.CLIExamplesPath != "" ||
+			o.PolicyPath != "" || o.ServiceFilePath != "" || o.ProxyPathForMocks != "" || o.CompileTestsDir != "" ||
+			o.AbstractOnly || o.SignalSendersForTesting || o.Incremental {
+			return nil, errors.New("-services generates only the proxies listed in the manifest and -manifest, " +
+				"and cannot be combined with the other outputs, -abstract-only, -signal-senders-for-testing or -incremental")
+		}
+		e := &emitter{fm: fm, info: makeTrailerInfo(o), stream: o.Stream && o.ManifestPath == ""}
+		if err := generateServices(o, e); err != nil {
+			return nil, err
+		}
+		if o.ManifestPath != "" {
+			if err := e.emitManifest(o.ManifestPath); err != nil {
+				return nil, fmt.Errorf("failed to generate manifest: %v", err)
+			}
+		}
+		return e.artifacts, nil
+	}
+
+	var sc serviceconfig.Config
+	if o.ServiceConfigPath != "" {
+		c, err := serviceconfig.Load(o.ServiceConfigPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file %s: %v", o.ServiceConfigPath, err)
+		}
+		sc = *c
+	}
+
+	// The hash is computed only in the incremental mode.
+	var h hash.Hash
+	if o.Incremental {
+		h = sha256.New()
+		if o.ServiceConfigPath != "" {
+			b, err := ioutil.ReadFile(o.ServiceConfigPath)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read config file %s: %v", o.ServiceConfigPath, err)
+			}
+			h.Write(b)
+		}
+	}
+
+	var introspections []introspect.Introspection
+	var fixedInputs Artifacts
+	for _, path := range o.Inputs {
+		introspection, fixed, err := parseInput(path, o)
+		if err != nil {
+			return nil, err
+		}
+		if fixed != nil {
+			fixedInputs = append(fixedInputs, *fixed)
+		}
+		if h != nil {
+			// Hash the parsed result rather than the file, so that changes in
+			// included files are taken into account.
+			b, err := json.Marshal(introspection)
+			if err != nil {
+				return nil, fmt.Errorf("failed to hash interface file %s: %v", path, err)
+			}
+			h.Write(b)
+		}
+
+		introspections = append(introspections, introspection.Flatten()...)
+	}
+
+	introspections, err := applyServiceConfig(introspections, &sc)
+	if err != nil {
+		return nil, err
+	}
+
+	// The members annotated with org.chromium.DBus.Skip* are omitted from the
+	// C++ outputs, while the policy and the TypeScript stubs cover all of them.
+	cppIntrospections := genutil.OmitSkippedMembers(introspections, "")
+	adaptorIntrospections := genutil.OmitSkippedMembers(introspections, introspect.SkipTargetAdaptor)
+	proxyIntrospections := makeProxyIntrospections(introspections, &sc)
+
+	var inputHash string
+	if h != nil {
+		inputHash = fmt.Sprintf("%x", h.Sum(nil))
+	}
+	e := &emitter{hash: inputHash, fm: fm, artifacts: fixedInputs, info: makeTrailerInfo(o), stream: o.Stream && o.ManifestPath == ""}
+	// The docs and the command line examples are Markdown and shell scripts
+	// whatever their extensions.
+	e.commentExts = make(map[string]string)
+	if o.DocsPath != "" && filepath.Ext(o.DocsPath) != ".html" {
+		e.commentExts[o.DocsPath] = ".md"
+	}
+	if o.CLIExamplesPath != "" {
+		e.commentExts[o.CLIExamplesPath] = ".sh"
+	}
+	if e.inputs, err = Inputs(o); err != nil {
+		return nil, err
+	}
+
+	if o.MethodNamesPath != "" {
+		if err := e.emit(o.MethodNamesPath, cppIntrospections, func(f io.Writer) error {
+			return methodnames.Generate(cppIntrospections, f)
+		}); err != nil {
+			return nil, fmt.Errorf("failed to generate methodnames: %v", err)
+		}
+	}
+
+	if o.ConstantsPath != "" {
+		if err := e.emit(o.ConstantsPath, cppIntrospections, func(f io.Writer) error {
+			return constants.Generate(cppIntrospections, f, o.ConstantsPath, sc)
+		}); err != nil {
+			return nil, fmt.Errorf("failed to generate constants: %v", err)
+		}
+	}
+
+	if o.MetadataPath != "" {
+		if err := e.emit(o.MetadataPath, cppIntrospections, func(f io.Writer) error {
+			return metadata.Generate(cppIntrospections, f, o.MetadataPath)
+		}); err != nil {
+			return nil, fmt.Errorf("failed to generate metadata: %v", err)
+		}
+	}
+
+	if o.FakeArgsPath != "" {
+		if err := e.emit(o.FakeArgsPath, cppIntrospections, func(f io.Writer) error {
+			return fakeargs.Generate(cppIntrospections, f, o.FakeArgsPath)
+		}); err != nil {
+			return nil, fmt.Errorf("failed to generate fake arguments: %v", err)
+		}
+	}
+
+	if o.AdaptorPath != "" {
+		if err := e.emit(o.AdaptorPath, adaptorIntrospections, func(f io.Writer) error {
+			return adaptor.Generate(adaptorIntrospections, f, o.AdaptorPath, sc)
+		}); err != nil {
+			return nil, fmt.Errorf("failed to generate adaptor: %v", err)
+		}
+	}
+
+	if o.AdaptorDir != "" {
+		for _, out := range genutil.SplitOutputFiles(adaptorIntrospections, sc.OutputFiles) {
+			out := out
+			path := filepath.Join(o.AdaptorDir, out.Name)
+			if err := e.emit(path, out.Introspects, func(f io.Writer) error {
+				return adaptor.Generate(out.Introspects, f, path, sc)
+			}); err != nil {
+				return nil, fmt.Errorf("failed to generate adaptor %s: %v", path, err)
+			}
+		}
+	}
+
+	if o.FuzzerPath != "" {
+		if o.AdaptorPath == "" {
+			return nil, errors.New("-fuzzer requires -adaptor")
+		}
+		p, err := filepath.Rel(filepath.Dir(o.FuzzerPath), o.AdaptorPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compute the relpath from fuzzer to adaptor: %v", err)
+		}
+		if err := e.emit(o.FuzzerPath, adaptorIntrospections, func(f io.Writer) error {
+			return fuzzer.Generate(adaptorIntrospections, f, o.FuzzerPath, p, sc)
+		}); err != nil {
+			return nil, fmt.Errorf("failed to generate fuzzer: %v", err)
+		}
+	}
+
+	if o.SignalSendersForTesting && (o.ProxyPath == "" || o.AbstractOnly) {
+		return nil, errors.New("-signal-senders-for-testing requires -proxy, and cannot be combined with -abstract-only")
+	}
+	if o.ProxySourcePath != "" && (o.ProxyPath == "" || o.AbstractOnly || o.SignalSendersForTesting || o.CppModules || o.NoBrillo) {
+		return nil, errors.New("-proxy-source requires -proxy, and cannot be combined with -abstract-only, -signal-senders-for-testing, -cpp-modules or -no-brillo")
+	}
+	if o.ProxyPath != "" {
+		if err := e.emit(o.ProxyPath, proxyIntrospections, func(f io.Writer) error {
+			if o.AbstractOnly {
+				return proxy.GenerateAbstract(proxyIntrospections, f, o.ProxyPath, sc)
+			}
+			if o.SignalSendersForTesting {
+				return proxy.GenerateWithSignalSenders(proxyIntrospections, f, o.ProxyPath, sc)
+			}
+			if o.CppModules {
+				return proxy.GenerateModule(proxyIntrospections, f, o.ProxyPath, sc)
+			}
+			if o.NoBrillo {
+				return proxy.GenerateNoBrillo(proxyIntrospections, f, o.ProxyPath, sc)
+			}
+			if o.ProxySourcePath != "" {
+				return proxy.GenerateSplit(proxyIntrospections, f, o.ProxyPath, sc)
+			}
+			return proxy.Generate(proxyIntrospections, f, o.ProxyPath, sc)
+		}); err != nil {
+			return nil, fmt.Errorf("failed to generate proxy: %v", err)
+		}
+	}
+
+	if o.ProxySourcePath != "" {
+		h, err := filepath.Rel(filepath.Dir(o.ProxySourcePath), o.ProxyPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compute the relpath from proxy source to header: %v", err)
+		}
+		if err := e.emit(o.ProxySourcePath, proxyIntrospections, func(f io.Writer) error {
+			return proxy.GenerateSource(proxyIntrospections, f, h, sc)
+		}); err != nil {
+			return nil, fmt.Errorf("failed to generate proxy source: %v", err)
+		}
+	}
+
+	if o.CompileTestsDir != "" {
+		if o.ProxyPath == "" || o.AbstractOnly {
+			return nil, errors.New("-compile-tests requires -proxy, and cannot be combined with -abstract-only")
+		}
+		p, err := filepath.Rel(o.CompileTestsDir, o.ProxyPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compute the relpath from compile tests to proxy: %v", err)
+		}
+		for _, i := range proxyIntrospections {
+			for _, itf := range i.Interfaces {
+				is := []introspect.Introspection{{Name: i.Name, Interfaces: []introspect.Interface{itf}}}
+				path := filepath.Join(o.CompileTestsDir, itf.Name+"_compile_test.cc")
+				if err := e.emit(path, is, func(f io.Writer) error {
+					return proxy.GenerateCompileTest(is, f, p, sc)
+				}); err != nil {
+					return nil, fmt.Errorf("failed to generate compile test %s: %v", path, err)
+				}
+			}
+		}
+	}
+
+	if o.TestFixturePath != "" {
+		if o.ProxyPath == "" {
+			return nil, errors.New("-test-fixture requires -proxy")
+		}
+		p, err := filepath.Rel(filepath.Dir(o.TestFixturePath), o.ProxyPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compute the relpath from test fixture to proxy: %v", err)
+		}
+		if err := e.emit(o.TestFixturePath, proxyIntrospections, func(f io.Writer) error {
+			return testfixture.Generate(proxyIntrospections, f, o.TestFixturePath, p, sc)
+		}); err != nil {
+			return nil, fmt.Errorf("failed to generate test fixture: %v", err)
+		}
+	}
+
+	if o.LoopbackPath != "" {
+		if o.AdaptorPath == "" || o.ProxyPath == "" {
+			return nil, errors.New("-loopback requires -adaptor and -proxy")
+		}
+		d := filepath.Dir(o.LoopbackPath)
+		a, err := filepath.Rel(d, o.AdaptorPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compute the relpath from loopback to adaptor: %v", err)
+		}
+		p, err := filepath.Rel(d, o.ProxyPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compute the relpath from loopback to proxy: %v", err)
+		}
+		if err := e.emit(o.LoopbackPath, proxyIntrospections, func(f io.Writer) error {
+			return proxy.GenerateLoopback(proxyIntrospections, f, o.LoopbackPath, a, p, sc)
+		}); err != nil {
+			return nil, fmt.Errorf("failed to generate loopback: %v", err)
+		}
+	}
+
+	if o.PimplProxyPath != "" {
+		if err := e.emit(o.PimplProxyPath, proxyIntrospections, func(f io.Writer) error {
+			return proxy.GeneratePimplHeader(proxyIntrospections, f, o.PimplProxyPath, sc)
+		}); err != nil {
+			return nil, fmt.Errorf("failed to generate pimpl proxy: %v", err)
+		}
+	}
+
+	if o.PimplSourcePath != "" {
+		if o.ProxyPath == "" || o.PimplProxyPath == "" {
+			return nil, errors.New("-pimpl-proxy-source requires -proxy and -pimpl-proxy")
+		}
+		d := filepath.Dir(o.PimplSourcePath)
+		h, err := filepath.Rel(d, o.PimplProxyPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compute the relpath from pimpl proxy source to header: %v", err)
+		}
+		p, err := filepath.Rel(d, o.ProxyPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compute the relpath from pimpl proxy source to proxy: %v", err)
+		}
+		if err := e.emit(o.PimplSourcePath, proxyIntrospections, func(f io.Writer) error {
+			return proxy.GeneratePimplSource(proxyIntrospections, f, h, p, sc)
+		}); err != nil {
+			return nil, fmt.Errorf("failed to generate pimpl proxy source: %v", err)
+		}
+	}
+
+	if o.TSPath != "" {
+		if err := e.emit(o.TSPath, introspections, func(f io.Writer) error {
+			return ts.Generate(introspections, f, sc)
+		}); err != nil {
+			return nil, fmt.Errorf("failed to generate TypeScript stubs: %v", err)
+		}
+	}
+
+	if o.GDBusPath != "" {
+		if err := e.emit(o.GDBusPath, proxyIntrospections, func(f io.Writer) error {
+			return gdbus.Generate(proxyIntrospections, f, o.GDBusPath)
+		}); err != nil {
+			return nil, fmt.Errorf("failed to generate GDBus C bindings: %v", err)
+		}
+	}
+
+	if o.PythonPath != "" {
+		if err := e.emit(o.PythonPath, proxyIntrospections, func(f io.Writer) error {
+			return python.Generate(proxyIntrospections, f, sc)
+		}); err != nil {
+			return nil, fmt.Errorf("failed to generate Python stubs: %v", err)
+		}
+	}
+
+	if o.GRPCProtoPath != "" {
+		if err := e.emit(o.GRPCProtoPath, introspections, func(f io.Writer) error {
+			return idl.Generate(introspections, f)
+		}); err != nil {
+			return nil, fmt.Errorf("failed to generate gRPC service definitions: %v", err)
+		}
+	}
+
+	if o.DocsPath != "" {
+		if err := e.emit(o.DocsPath, introspections, func(f io.Writer) error {
+			if filepath.Ext(o.DocsPath) == ".html" {
+				return docs.GenerateHTML(introspections, f)
+			}
+			return docs.Generate(introspections, f)
+		}); err != nil {
+			return nil, fmt.Errorf("failed to generate API reference: %v", err)
+		}
+	}
+
+	if o.CLIExamplesPath != "" {
+		if err := e.emit(o.CLIExamplesPath, introspections, func(f io.Writer) error {
+			return cliexamples.Generate(introspections, f, sc)
+		}); err != nil {
+			return nil, fmt.Errorf("failed to generate command line examples: %v", err)
+		}
+	}
+
+	if o.PolicyPath != "" {
+		if err := e.emit(o.PolicyPath, introspections, func(f io.Writer) error {
+			return policy.Generate(introspections, f, sc)
+		}); err != nil {
+			return nil, fmt.Errorf("failed to generate policy: %v", err)
+		}
+	}
+
+	if o.ServiceFilePath != "" {
+		if err := e.emit(o.ServiceFilePath, nil, func(f io.Writer) error {
+			return policy.GenerateService(f, sc)
+		}); err != nil {
+			return nil, fmt.Errorf("failed to generate service file: %v", err)
+		}
+	}
+
+	if o.MockPath != "" {
+		p := o.ProxyPathForMocks
+		if p == "" && o.ProxyPath != "" {
+			// -proxy-path-for-mock is not specified. Derive it from proxyPath.
+			d := filepath.Dir(o.MockPath)
+			var err error
+			p, err = filepath.Rel(d, o.ProxyPath)
+			if err != nil {
+				return nil, fmt.Errorf("failed to compute the relpath from mock to proxy: %v", err)
+			}
+		}
+
+		if err := e.emit(o.MockPath, proxyIntrospections, func(f io.Writer) error {
+			return proxy.GenerateMock(proxyIntrospections, f, o.MockPath, p, sc)
+		}); err != nil {
+			return nil, fmt.Errorf("failed to generate proxy mock: %v", err)
+		}
+	}
+	if o.ManifestPath != "" {
+		if err := e.emitManifest(o.ManifestPath); err != nil {
+			return nil, fmt.Errorf("failed to generate manifest: %v", err)
+		}
+	}
+	return e.artifacts, nil
+}
@@ -0,0 +1,695 @@
+// Copyright 2022 The ChromiumOS Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package generator_test
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.chromium.org/chromiumos/dbusbindings/generator"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const testInterface = `<node>
+  <interface name="org.chromium.Test">
+    <method name="Ping">
+      <arg name="value" type="i" direction="in"/>
+    </method>
+  </interface>
+</node>
+`
+
+func TestRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "generator_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	input := filepath.Join(dir, "test.xml")
+	if err := ioutil.WriteFile(input, []byte(testInterface), 0644); err != nil {
+		t.Fatal(err)
+	}
+	o := generator.Options{
+		MethodNamesPath: filepath.Join(dir, "out", "methods.h"),
+		ProxyPath:       filepath.Join(dir, "out", "proxies.h"),
+		Inputs:          []string{input},
+	}
+	a, err := generator.Run(o)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	var paths []string
+	for _, art := range a {
+		paths = append(paths, art.Path)
+	}
+	if diff := cmp.Diff(paths, []string{o.MethodNamesPath, o.ProxyPath}); diff != "" {
+		t.Errorf("Run returned unexpected artifacts (-got +want):\n%s", diff)
+	}
+	if !strings.Contains(string(a[0].Contents), `const char kPingMethod[] = "Ping";`) {
+		t.Errorf("Method names do not contain Ping:\n%s", a[0].Contents)
+	}
+
+	// Run must not touch the file system.
+	if _, err := os.Stat(filepath.Join(dir, "out")); !os.IsNotExist(err) {
+		t.Errorf("Output directory exists before Write: %v", err)
+	}
+	if err := a.Write(false); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	for _, art := range a {
+		b, err := ioutil.ReadFile(art.Path)
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", art.Path, err)
+		}
+		if diff := cmp.Diff(string(b), string(art.Contents)); diff != "" {
+			t.Errorf("Write wrote unexpected contents into %s (-got +want):\n%s", art.Path, diff)
+		}
+	}
+}
+
+func TestRunIncremental(t *testing.T) {
+	dir, err := ioutil.TempDir("", "generator_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	input := filepath.Join(dir, "test.xml")
+	if err := ioutil.WriteFile(input, []byte(testInterface), 0644); err != nil {
+		t.Fatal(err)
+	}
+	a, err := generator.Run(generator.Options{
+		MethodNamesPath: filepath.Join(dir, "methods.h"),
+		Incremental:     true,
+		Inputs:          []string{input},
+	})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if len(a) != 1 || !strings.HasPrefix(string(a[0].Contents), "// Input hash: sha256:") {
+		t.Fatalf("Run did not embed the input hash: %v", a)
+	}
+
+	// Unchanged outputs must keep their mtime.
+	if err := a.Write(true); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	old := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(a[0].Path, old, old); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Write(true); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if fi, err := os.Stat(a[0].Path); err != nil {
+		t.Fatal(err)
+	} else if !fi.ModTime().Equal(old) {
+		t.Errorf("Write rewrote the unchanged output: mtime %v, want %v", fi.ModTime(), old)
+	}
+}
+
+func TestRunStream(t *testing.T) {
+	dir, err := ioutil.TempDir("", "generator_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	input := filepath.Join(dir, "test.xml")
+	if err := ioutil.WriteFile(input, []byte(testInterface), 0644); err != nil {
+		t.Fatal(err)
+	}
+	o := generator.Options{
+		MethodNamesPath: filepath.Join(dir, "methods.h"),
+		ProxyPath:       filepath.Join(dir, "out", "proxies.h"),
+		Reproducible:    true,
+		Inputs:          []string{input},
+	}
+	want, err := generator.Run(o)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	o.Stream = true
+	a, err := generator.Run(o)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	for _, art := range a {
+		if art.Contents != nil {
+			t.Errorf("Run with Stream returned the contents of %s", art.Path)
+		}
+	}
+	if err := a.Write(false); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	for _, art := range want {
+		b, err := ioutil.ReadFile(art.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(string(b), string(art.Contents)); diff != "" {
+			t.Errorf("Streamed %s differs (-got +want):\n%s", art.Path, diff)
+		}
+	}
+
+	// The outputs listed in the manifest need their contents to be hashed.
+	o.ManifestPath = filepath.Join(dir, "manifest.json")
+	a, err = generator.Run(o)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	for _, art := range a {
+		if art.Contents == nil {
+			t.Errorf("Run with Stream and ManifestPath streamed %s", art.Path)
+		}
+	}
+}
+
+func TestRunTrailer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "generator_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	input := filepath.Join(dir, "test.xml")
+	if err := ioutil.WriteFile(input, []byte(testInterface), 0644); err != nil {
+		t.Fatal(err)
+	}
+	o := generator.Options{
+		MethodNamesPath: filepath.Join(dir, "methods.h"),
+		PythonPath:      filepath.Join(dir, "client.py"),
+		CommandLine:     []string{"/usr/bin/generate-chromeos-dbus-bindings", "-method-names=methods.h", "test.xml"},
+		InputRoot:       dir,
+		Inputs:          []string{input},
+	}
+	a, err := generator.Run(o)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if len(a) != 2 {
+		t.Fatalf("Run got %d artifacts, want 2", len(a))
+	}
+	inputLine := fmt.Sprintf("Input: test.xml sha256:%x\n", sha256.Sum256([]byte(testInterface)))
+	for _, want := range []string{
+		"\n// Generated by generate-chromeos-dbus-bindings ",
+		"\n// Command line: generate-chromeos-dbus-bindings -method-names=methods.h test.xml\n",
+		"\n// " + inputLine,
+		"\n// Generated at: ",
+	} {
+		if !strings.Contains(string(a[0].Contents), want) {
+			t.Errorf("Method names do not contain %q:\n%s", want, a[0].Contents)
+		}
+	}
+	if !strings.Contains(string(a[1].Contents), "\n# "+inputLine) {
+		t.Errorf("Python trailer is not commented out with #:\n%s", a[1].Contents)
+	}
+
+	o.Reproducible = true
+	o.CommandLine = nil
+	a, err = generator.Run(o)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if strings.Contains(string(a[0].Contents), "Generated at:") {
+		t.Errorf("Reproducible output contains the generation time:\n%s", a[0].Contents)
+	}
+	if strings.Contains(string(a[0].Contents), "Command line:") {
+		t.Errorf("Output without CommandLine contains the command line:\n%s", a[0].Contents)
+	}
+	if strings.Contains(string(a[0].Contents), dir) {
+		t.Errorf("Output contains the absolute path of the input:\n%s", a[0].Contents)
+	}
+}
+
+func TestRunManifest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "generator_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	input := filepath.Join(dir, "test.xml")
+	if err := ioutil.WriteFile(input, []byte(testInterface), 0644); err != nil {
+		t.Fatal(err)
+	}
+	o := generator.Options{
+		MethodNamesPath: filepath.Join(dir, "methods.h"),
+		ProxyPath:       filepath.Join(dir, "proxies.h"),
+		ManifestPath:    filepath.Join(dir, "manifest.json"),
+		Inputs:          []string{input},
+	}
+	a, err := generator.Run(o)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if len(a) != 3 || a[2].Path != o.ManifestPath {
+		t.Fatalf("Run did not generate the manifest last: %v", a)
+	}
+
+	var got struct {
+		Inputs []struct {
+			Path   string `json:"path"`
+			SHA256 string `json:"sha256"`
+		} `json:"inputs"`
+		Outputs []struct {
+			Path       string   `json:"path"`
+			Interfaces []string `json:"interfaces"`
+			SHA256     string   `json:"sha256"`
+		} `json:"outputs"`
+	}
+	if err := json.Unmarshal(a[2].Contents, &got); err != nil {
+		t.Fatalf("Failed to parse the manifest: %v\n%s", err, a[2].Contents)
+	}
+	if len(got.Inputs) != 1 || got.Inputs[0].Path != input || got.Inputs[0].SHA256 != fmt.Sprintf("%x", sha256.Sum256([]byte(testInterface))) {
+		t.Errorf("Manifest has unexpected inputs: %+v", got.Inputs)
+	}
+	if len(got.Outputs) != 2 {
+		t.Fatalf("Manifest has unexpected outputs: %+v", got.Outputs)
+	}
+	for i, out := range got.Outputs {
+		if out.Path != a[i].Path {
+			t.Errorf("Manifest output %d path mismatch: got %s, want %s", i, out.Path, a[i].Path)
+		}
+		if diff := cmp.Diff(out.Interfaces, []string{"org.chromium.Test"}); diff != "" {
+			t.Errorf("Manifest output %s has unexpected interfaces (-got +want):\n%s", out.Path, diff)
+		}
+		if out.SHA256 != fmt.Sprintf("%x", sha256.Sum256(a[i].Contents)) {
+			t.Errorf("Manifest output %s has unexpected hash %s", out.Path, out.SHA256)
+		}
+	}
+}
+
+func TestInputs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "generator_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"main.xml":    `<node><include href="test.xml"/></node>`,
+		"test.xml":    testInterface,
+		"config.json": `{}`,
+	}
+	for name, contents := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	o := generator.Options{
+		ServiceConfigPath: filepath.Join(dir, "config.json"),
+		Inputs:            []string{filepath.Join(dir, "main.xml"), filepath.Join(dir, "test.xml")},
+	}
+	got, err := generator.Inputs(o)
+	if err != nil {
+		t.Fatalf("Inputs got error, want nil: %v", err)
+	}
+	want := []string{filepath.Join(dir, "config.json"), filepath.Join(dir, "main.xml"), filepath.Join(dir, "test.xml")}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("Inputs failed (-got +want):\n%s", diff)
+	}
+
+	path := writeServices(t, dir, testInterface, `{}`, `{}`)
+	got, err = generator.Inputs(generator.Options{ServicesPath: path})
+	if err != nil {
+		t.Fatalf("Inputs got error, want nil: %v", err)
+	}
+	want = []string{
+		path,
+		filepath.Join(dir, "foo.json"),
+		filepath.Join(dir, "foo.xml"),
+		filepath.Join(dir, "common.xml"),
+		filepath.Join(dir, "bar.json"),
+	}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("Inputs with services failed (-got +want):\n%s", diff)
+	}
+}
+
+func TestRunCompileTests(t *testing.T) {
+	dir, err := ioutil.TempDir("", "generator_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	input := filepath.Join(dir, "test.xml")
+	if err := ioutil.WriteFile(input, []byte(testInterface), 0644); err != nil {
+		t.Fatal(err)
+	}
+	a, err := generator.Run(generator.Options{
+		ProxyPath:       filepath.Join(dir, "proxies.h"),
+		CompileTestsDir: filepath.Join(dir, "compile_tests"),
+		Inputs:          []string{input},
+	})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if len(a) != 2 {
+		t.Fatalf("Run returned %d artifacts, want 2", len(a))
+	}
+	if got, want := a[1].Path, filepath.Join(dir, "compile_tests", "org.chromium.Test_compile_test.cc"); got != want {
+		t.Errorf("Run generated the compile test into %s, want %s", got, want)
+	}
+	if !strings.Contains(string(a[1].Contents), `#include "../proxies.h"`) {
+		t.Errorf("Compile test does not include the proxy header:\n%s", a[1].Contents)
+	}
+}
+
+func TestRunDefaultNamespace(t *testing.T) {
+	dir, err := ioutil.TempDir("", "generator_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	input := filepath.Join(dir, "test.xml")
+	if err := ioutil.WriteFile(input, []byte(`<node><interface name="Manager"/></node>`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	o := generator.Options{
+		ProxyPath: filepath.Join(dir, "proxies.h"),
+		Inputs:    []string{input},
+	}
+	if _, err := generator.Run(o); err == nil {
+		t.Error("Run unexpectedly succeeded with an interface without namespace")
+	}
+
+	o.ServiceConfigPath = filepath.Join(dir, "config.json")
+	if err := ioutil.WriteFile(o.ServiceConfigPath, []byte(`{"default_namespace": "shill"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	a, err := generator.Run(o)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if !strings.Contains(string(a[0].Contents), "namespace shill {\n\n// Abstract interface proxy for shill::Manager.") {
+		t.Errorf("Proxy is not in the default namespace:\n%s", a[0].Contents)
+	}
+}
+
+func TestRunDirections(t *testing.T) {
+	dir, err := ioutil.TempDir("", "generator_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	input := filepath.Join(dir, "test.xml")
+	if err := ioutil.WriteFile(input, []byte(strings.Replace(testInterface, ` direction="in"`, "", 1)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	o := generator.Options{
+		MethodNamesPath: filepath.Join(dir, "methods.h"),
+		Inputs:          []string{input},
+	}
+	if _, err := generator.Run(o); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	o.StrictDirections = true
+	if _, err := generator.Run(o); err == nil {
+		t.Error("Run unexpectedly succeeded with StrictDirections and an argument without direction")
+	}
+
+	o.StrictDirections = false
+	o.FixDirections = true
+	a, err := generator.Run(o)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if len(a) != 2 || a[0].Path != input {
+		t.Fatalf("Run did not return the fixed input: %v", a)
+	}
+	if diff := cmp.Diff(string(a[0].Contents), testInterface); diff != "" {
+		t.Errorf("Run fixed the input unexpectedly (-got +want):\n%s", diff)
+	}
+}
+
+func TestRunAnnotations(t *testing.T) {
+	dir, err := ioutil.TempDir("", "generator_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	input := filepath.Join(dir, "test.xml")
+	const annotations = `<method name="Ping">
+      <annotation name="org.chromium.DBus.Method.Knd" value="async"/>
+      <annotation name="org.freedesktop.DBus.Deprecated" value="true"/>
+      <annotation name="org.chromium.DBus.Frobinate" value="true"/>`
+	if err := ioutil.WriteFile(input, []byte(strings.Replace(testInterface, `<method name="Ping">`, annotations, 1)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	o := generator.Options{
+		MethodNamesPath: filepath.Join(dir, "methods.h"),
+		Inputs:          []string{input},
+	}
+	if _, err := generator.Run(o); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	o.StrictAnnotations = true
+	_, err = generator.Run(o)
+	if err == nil {
+		t.Fatal("Run unexpectedly succeeded with StrictAnnotations and unknown annotations")
+	}
+	want := input + ": org.chromium.Test.Ping: unknown annotation org.chromium.DBus.Method.Knd; " +
+		"did you mean org.chromium.DBus.Method.Kind?; " +
+		"org.chromium.Test.Ping: unknown annotation org.chromium.DBus.Frobinate"
+	if err.Error() != want {
+		t.Errorf("Run err mismatch: got %q, want %q", err, want)
+	}
+}
+
+const commonInterface = `<node name="/org/chromium/Common">
+  <interface name="org.chromium.Common">
+    <method name="GetAddresses">
+      <arg name="addresses" type="au" direction="out"/>
+    </method>
+  </interface>
+</node>
+`
+
+// writeServices writes into dir a -services manifest of the services foo and
+// bar, which share org.chromium.Common, and returns its path.
+func writeServices(t *testing.T, dir, fooInterface, fooConfig, barConfig string) string {
+	files := map[string]string{
+		"common.xml": commonInterface,
+		"foo.xml":    fooInterface,
+		"foo.json":   fooConfig,
+		"bar.json":   barConfig,
+		"services.json": `{
+  "shared_proxy": "common-proxies.h",
+  "services": [
+    {"service_config": "foo.json", "inputs": ["foo.xml", "common.xml"], "proxy": "foo-proxies.h"},
+    {"service_config": "bar.json", "inputs": ["common.xml"], "proxy": "bar-proxies.h"}
+  ]
+}`,
+	}
+	for name, contents := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return filepath.Join(dir, "services.json")
+}
+
+// runServices runs the generator with the -services manifest at path, and
+// returns the contents of the outputs by their base names.
+func runServices(t *testing.T, path string) map[string]string {
+	a, err := generator.Run(generator.Options{ServicesPath: path})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	ret := make(map[string]string)
+	for _, art := range a {
+		ret[filepath.Base(art.Path)] = string(art.Contents)
+	}
+	return ret
+}
+
+func TestRunServicesTypeMappings(t *testing.T) {
+	dir, err := ioutil.TempDir("", "generator_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	const config = `{"type_mappings": [{"signature": "au", "cpp_type": "std::vector<net_base::IPAddress>", "header": "<net-base/ip_address.h>"}]}`
+	const fooInterface = `<node name="/org/chromium/Foo">
+  <interface name="org.chromium.Foo">
+    <method name="SetAddresses">
+      <arg name="addresses" type="au" direction="in"/>
+    </method>
+  </interface>
+</node>
+`
+	out := runServices(t, writeServices(t, dir, fooInterface, config, config))
+	for _, name := range []string{"common-proxies.h", "foo-proxies.h"} {
+		if !strings.Contains(out[name], "std::vector<net_base::IPAddress>") || !strings.Contains(out[name], "#include <net-base/ip_address.h>") {
+			t.Errorf("%s does not use the mapped type:\n%s", name, out[name])
+		}
+	}
+}
+
+func TestRunServicesTargetVersion(t *testing.T) {
+	dir, err := ioutil.TempDir("", "generator_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	const fooInterface = `<node name="/org/chromium/Foo">
+  <interface name="org.chromium.Foo">
+    <method name="Ping"/>
+    <method name="PingTwice">
+      <annotation name="org.chromium.DBus.MinVersion" value="2"/>
+    </method>
+  </interface>
+</node>
+`
+	out := runServices(t, writeServices(t, dir, fooInterface, `{"target_version": 1}`, `{}`))
+	if p := out["foo-proxies.h"]; !strings.Contains(p, "Ping(") || strings.Contains(p, "PingTwice") {
+		t.Errorf("foo-proxies.h does not omit the method newer than target_version:\n%s", p)
+	}
+}
+
+func TestRunServicesObjectPaths(t *testing.T) {
+	dir, err := ioutil.TempDir("", "generator_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	const fooInterface = `<node>
+  <interface name="org.chromium.Foo">
+    <method name="Ping"/>
+  </interface>
+</node>
+`
+	const fooConfig = `{"interfaces": {"org.chromium.Foo": {"object_path": "/org/chromium/Foo"}}}`
+	out := runServices(t, writeServices(t, dir, fooInterface, fooConfig, `{}`))
+	if p := out["foo-proxies.h"]; !strings.Contains(p, `dbus::ObjectPath object_path_{"/org/chromium/Foo"};`) {
+		t.Errorf("foo-proxies.h does not create the proxy at the object_path:\n%s", p)
+	}
+}
+
+func TestRunServicesDirections(t *testing.T) {
+	dir, err := ioutil.TempDir("", "generator_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := writeServices(t, dir, strings.Replace(testInterface, ` direction="in"`, "", 1), `{}`, `{}`)
+	if _, err := generator.Run(generator.Options{ServicesPath: path, StrictDirections: true}); err == nil {
+		t.Error("Run unexpectedly succeeded with StrictDirections and an argument without direction")
+	}
+	a, err := generator.Run(generator.Options{ServicesPath: path, FixDirections: true})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if a[0].Path != filepath.Join(dir, "foo.xml") || string(a[0].Contents) != testInterface {
+		t.Errorf("Run did not return the fixed input first: %v", a[0])
+	}
+}
+
+func TestRunServicesAnnotations(t *testing.T) {
+	dir, err := ioutil.TempDir("", "generator_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	const annotation = `<method name="Ping">
+      <annotation name="org.chromium.DBus.Method.Knd" value="async"/>`
+	path := writeServices(t, dir, strings.Replace(testInterface, `<method name="Ping">`, annotation, 1), `{}`, `{}`)
+	if _, err := generator.Run(generator.Options{ServicesPath: path}); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	_, err = generator.Run(generator.Options{ServicesPath: path, StrictAnnotations: true})
+	want := filepath.Join(dir, "foo.xml") + ": org.chromium.Test.Ping: unknown annotation org.chromium.DBus.Method.Knd; " +
+		"did you mean org.chromium.DBus.Method.Kind?"
+	if err == nil || err.Error() != want {
+		t.Errorf("Run with StrictAnnotations got error %v, want %q", err, want)
+	}
+}
+
+func TestRunInvalidOptions(t *testing.T) {
+	if _, err := generator.Run(generator.Options{
+		ServicesPath: "services.json",
+		Inputs:       []string{"test.xml"},
+	}); err == nil {
+		t.Error("Run unexpectedly succeeded with both ServicesPath and Inputs")
+	}
+	for _, o := range []generator.Options{
+		{ServicesPath: "services.json", AdaptorPath: "adaptor.h"},
+		{ServicesPath: "services.json", MockPath: "mock.h"},
+		{ServicesPath: "services.json", DocsPath: "docs.md"},
+		{ServicesPath: "services.json", AbstractOnly: true},
+		{ServicesPath: "services.json", Incremental: true},
+	} {
+		if _, err := generator.Run(o); err == nil || !strings.HasPrefix(err.Error(), "-services generates only") {
+			t.Errorf("Run with %+v got error %v, want the other outputs rejected", o, err)
+		}
+	}
+	if _, err := generator.Run(generator.Options{
+		ProxyPath:        "proxy.h",
+		ClangFormatStyle: ".clang-format",
+	}); err == nil {
+		t.Error("Run unexpectedly succeeded with ClangFormatStyle but without ClangFormatPath")
+	}
+	if _, err := generator.Run(generator.Options{
+		LoopbackPath: "loopback.h",
+		ProxyPath:    "proxy.h",
+	}); err == nil {
+		t.Error("Run unexpectedly succeeded with LoopbackPath but without AdaptorPath")
+	}
+	if _, err := generator.Run(generator.Options{
+		ProxyPath:               "proxy.h",
+		AbstractOnly:            true,
+		SignalSendersForTesting: true,
+	}); err == nil {
+		t.Error("Run unexpectedly succeeded with SignalSendersForTesting and AbstractOnly")
+	}
+	if _, err := generator.Run(generator.Options{
+		ProxyPath:  "proxy.ixx",
+		MockPath:   "mock.h",
+		CppModules: true,
+	}); err == nil {
+		t.Error("Run unexpectedly succeeded with CppModules and MockPath")
+	}
+	if _, err := generator.Run(generator.Options{
+		ProxyPath:    "proxy.h",
+		AbstractOnly: true,
+		NoBrillo:     true,
+	}); err == nil {
+		t.Error("Run unexpectedly succeeded with NoBrillo and AbstractOnly")
+	}
+	if _, err := generator.Run(generator.Options{
+		StrictDirections: true,
+		FixDirections:    true,
+	}); err == nil {
+		t.Error("Run unexpectedly succeeded with StrictDirections and FixDirections")
+	}
+	if _, err := generator.Run(generator.Options{
+		CompileTestsDir: "compile_tests",
+	}); err == nil {
+		t.Error("Run unexpectedly succeeded with CompileTestsDir but without ProxyPath")
+	}
+}
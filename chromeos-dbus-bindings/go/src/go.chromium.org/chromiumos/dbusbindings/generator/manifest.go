@@ -0,0 +1,66 @@
+// Copyright 2022 The ChromiumOS Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package generator
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+)
+
+// manifest is the file given by -manifest, listing the outputs of a run and
+// the inputs used, so that the build rules can declare the outputs precisely
+// and detect the stale ones.
+type manifest struct {
+	Inputs  []manifestInput  `json:"inputs"`
+	Outputs []manifestOutput `json:"outputs"`
+}
+
+// manifestInput is an input file with the hash of its contents.
+type manifestInput struct {
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256"`
+}
+
+// manifestOutput is an output file with the interfaces it contains and the
+// hash of its contents.
+type manifestOutput struct {
+	Path       string   `json:"path"`
+	Interfaces []string `json:"interfaces"`
+	SHA256     string   `json:"sha256"`
+}
+
+// makeManifest returns the manifest of the artifacts generated from the
+// files at inputs, as an indented JSON.
+func makeManifest(inputs []string, artifacts Artifacts) ([]byte, error) {
+	m := manifest{
+		Inputs:  []manifestInput{},
+		Outputs: []manifestOutput{},
+	}
+	for _, path := range inputs {
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read input file %s: %v", path, err)
+		}
+		m.Inputs = append(m.Inputs, manifestInput{Path: path, SHA256: fmt.Sprintf("%x", sha256.Sum256(b))})
+	}
+	for _, a := range artifacts {
+		itfs := a.Interfaces
+		if itfs == nil {
+			itfs = []string{}
+		}
+		m.Outputs = append(m.Outputs, manifestOutput{
+			Path:       a.Path,
+			Interfaces: itfs,
+			SHA256:     fmt.Sprintf("%x", sha256.Sum256(a.Contents)),
+		})
+	}
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
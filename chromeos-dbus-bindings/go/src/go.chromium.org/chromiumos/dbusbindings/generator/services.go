@@ -0,0 +1,187 @@
+// Copyright 2022 The ChromiumOS Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package generator
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+
+	"go.chromium.org/chromiumos/dbusbindings/generate/genutil"
+	"go.chromium.org/chromiumos/dbusbindings/generate/proxy"
+	"go.chromium.org/chromiumos/dbusbindings/introspect"
+	"go.chromium.org/chromiumos/dbusbindings/serviceconfig"
+)
+
+// servicesManifest is the file given by -services, listing the services whose
+// proxies are generated together.
+type servicesManifest struct {
+	// SharedProxy is the output header of the proxies of the interfaces used
+	// by more than one service.
+	SharedProxy string           `json:"shared_proxy"`
+	Services    []serviceOutputs `json:"services"`
+}
+
+// serviceOutputs is the inputs and the output of a service in the manifest.
+type serviceOutputs struct {
+	ServiceConfig string   `json:"service_config"`
+	Inputs        []string `json:"inputs"`
+	Proxy         string   `json:"proxy"`
+}
+
+// loadServicesManifest reads the manifest at path. The relative paths in it
+// are resolved against the directory of the manifest.
+func loadServicesManifest(path string) (*servicesManifest, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.DisallowUnknownFields()
+	var m servicesManifest
+	if err := d.Decode(&m); err != nil {
+		return nil, err
+	}
+	if m.SharedProxy == "" {
+		return nil, errors.New("shared_proxy is not specified")
+	}
+	if len(m.Services) == 0 {
+		return nil, errors.New("no services are specified")
+	}
+
+	dir := filepath.Dir(path)
+	resolve := func(p string) string {
+		if p == "" || filepath.IsAbs(p) {
+			return p
+		}
+		return filepath.Join(dir, p)
+	}
+	m.SharedProxy = resolve(m.SharedProxy)
+	for i := range m.Services {
+		s := &m.Services[i]
+		if s.Proxy == "" {
+			return nil, fmt.Errorf("service %d: proxy is not specified", i)
+		}
+		s.ServiceConfig = resolve(s.ServiceConfig)
+		s.Proxy = resolve(s.Proxy)
+		for j, in := range s.Inputs {
+			s.Inputs[j] = resolve(in)
+		}
+	}
+	return &m, nil
+}
+
+// generateServices writes the proxies of the services listed in the manifest
+// at o.ServicesPath. The interfaces used by more than one service are
+// generated once into the shared header, which is included by the proxies of
+// the services, so that consumers linking several of them do not violate the
+// ODR. The interface files are checked as requested by o.
+// The shared proxies are generated with the options of the first service,
+// but without its service name, object manager and client factory.
+func generateServices(o Options, e *emitter) error {
+	path := o.ServicesPath
+	m, err := loadServicesManifest(path)
+	if err != nil {
+		return fmt.Errorf("failed to read services manifest %s: %v", path, err)
+	}
+
+	if e.inputs, err = Inputs(o); err != nil {
+		return err
+	}
+
+	// The interface files shared by the services are fixed once.
+	fixedPaths := make(map[string]bool)
+	configs := make([]serviceconfig.Config, len(m.Services))
+	services := make([][]introspect.Introspection, len(m.Services))
+	for i, s := range m.Services {
+		if s.ServiceConfig != "" {
+			c, err := serviceconfig.Load(s.ServiceConfig)
+			if err != nil {
+				return fmt.Errorf("failed to read config file %s: %v", s.ServiceConfig, err)
+			}
+			configs[i] = *c
+		}
+		var introspections []introspect.Introspection
+		for _, in := range s.Inputs {
+			introspection, fixed, err := parseInput(in, o)
+			if err != nil {
+				return err
+			}
+			if fixed != nil && !fixedPaths[fixed.Path] {
+				fixedPaths[fixed.Path] = true
+				e.artifacts = append(e.artifacts, *fixed)
+			}
+			introspections = append(introspections, introspection.Flatten()...)
+		}
+		introspections, err := applyServiceConfig(introspections, &configs[i])
+		if err != nil {
+			return fmt.Errorf("service %s: %v", s.Proxy, err)
+		}
+		services[i] = makeProxyIntrospections(introspections, &configs[i])
+	}
+
+	shared, rest, err := genutil.SplitSharedInterfaces(services)
+	if err != nil {
+		return err
+	}
+	// The object manager of a service creates the proxies of its interfaces
+	// with its service name, which the shared proxies do not have.
+	sharedNames := make(map[string]bool)
+	for _, is := range shared {
+		sharedNames[is.Interfaces[0].Name] = true
+	}
+	for i, s := range m.Services {
+		if configs[i].ObjectManager == nil {
+			continue
+		}
+		for _, is := range services[i] {
+			for _, itf := range is.Interfaces {
+				if sharedNames[itf.Name] {
+					return fmt.Errorf("service %s: shared interface %s cannot be managed by the object manager", s.Proxy, itf.Name)
+				}
+			}
+		}
+	}
+
+	sharedConfig := configs[0]
+	sharedConfig.ServiceName = ""
+	sharedConfig.ObjectManager = nil
+	sharedConfig.ClientFactory = nil
+	// The shared interfaces may use the types mapped by any service, whose
+	// headers are then included.
+	sharedConfig.TypeMappings = nil
+	for _, c := range configs {
+		sharedConfig.TypeMappings = append(sharedConfig.TypeMappings, c.TypeMappings...)
+	}
+	// The shared interfaces may be missing from the first service.
+	sharedOverrides, err := genutil.ApplyDefaultNameSpace(shared, sharedConfig.NamespaceOverrides, sharedConfig.DefaultNamespace)
+	if err != nil {
+		return fmt.Errorf("shared proxy %s: %v", m.SharedProxy, err)
+	}
+	sharedConfig.NamespaceOverrides = sharedOverrides
+	if err := e.emit(m.SharedProxy, shared, func(f io.Writer) error {
+		return proxy.Generate(shared, f, m.SharedProxy, sharedConfig)
+	}); err != nil {
+		return fmt.Errorf("failed to generate shared proxy: %v", err)
+	}
+
+	for i, s := range m.Services {
+		i, s := i, s
+		p, err := filepath.Rel(filepath.Dir(s.Proxy), m.SharedProxy)
+		if err != nil {
+			return fmt.Errorf("failed to compute the relpath from proxy to shared proxy: %v", err)
+		}
+		if err := e.emit(s.Proxy, rest[i], func(f io.Writer) error {
+			return proxy.GenerateWithSharedProxies(rest[i], f, s.Proxy, p, configs[i])
+		}); err != nil {
+			return fmt.Errorf("failed to generate proxy %s: %v", s.Proxy, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+// Copyright 2022 The ChromiumOS Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package generator
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.chromium.org/chromiumos/dbusbindings/describe"
+)
+
+// generatorName is the name of the generator binary recorded in the
+// trailers.
+const generatorName = "generate-chromeos-dbus-bindings"
+
+// trailerInfo is how the outputs of a run are generated, recorded in the
+// trailers appended to them.
+type trailerInfo struct {
+	// commandLine is the command line of the run, or nil to omit it.
+	commandLine []string
+	// inputRoot is the directory which the input paths are recorded
+	// relative to, or "" for the current directory.
+	inputRoot string
+	// generatedAt is the time of the run, or the zero time to omit it for
+	// the outputs to be reproducible.
+	generatedAt time.Time
+}
+
+// makeTrailer returns the lines of the trailer of the outputs generated from
+// the files at inputs, recording the version of the generator, the command
+// line, the relative paths and the hashes of the inputs and the generation
+// time, so that the builds can verify that the artifacts match their sources.
+func makeTrailer(info trailerInfo, inputs []string) ([]string, error) {
+	ret := []string{fmt.Sprintf("Generated by %s %s.", generatorName, describe.Describe().Version)}
+	if len(info.commandLine) > 0 {
+		// The binary is named without its directory, which depends on the
+		// build environment.
+		args := append([]string{filepath.Base(info.commandLine[0])}, info.commandLine[1:]...)
+		ret = append(ret, "Command line: "+strings.Join(args, " "))
+	}
+	for _, path := range inputs {
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read input file %s: %v", path, err)
+		}
+		ret = append(ret, fmt.Sprintf("Input: %s sha256:%x", trailerInputPath(info.inputRoot, path), sha256.Sum256(b)))
+	}
+	if !info.generatedAt.IsZero() {
+		ret = append(ret, "Generated at: "+info.generatedAt.UTC().Format(time.RFC3339))
+	}
+	return ret, nil
+}
+
+// trailerInputPath returns path relative to root, or to the current directory
+// if root is empty, so that the trailers do not depend on where the checkout
+// is. The base name is returned if path cannot be made relative.
+func trailerInputPath(root, path string) string {
+	if root == "" {
+		root = "."
+	}
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return filepath.Base(path)
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return filepath.Base(path)
+	}
+	rel, err := filepath.Rel(absRoot, absPath)
+	if err != nil {
+		return filepath.Base(path)
+	}
+	return filepath.ToSlash(rel)
+}
+
+// commentLines returns lines commented out in the syntax of the files with
+// the extension ext, e.g. ".h".
+func commentLines(ext string, lines []string) string {
+	var b strings.Builder
+	for _, l := range lines {
+		switch ext {
+		case ".conf", ".html", ".md":
+			fmt.Fprintf(&b, "<!-- %s -->\n", l)
+		case ".service", ".sh", ".py":
+			fmt.Fprintf(&b, "# %s\n", l)
+		default:
+			fmt.Fprintf(&b, "// %s\n", l)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,208 @@
+// Copyright 2022 The ChromiumOS Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package introspect
+
+import (
+	"fmt"
+)
+
+// InterfaceBuilder builds an Interface without writing the introspection XML,
+// e.g.
+//
+//	itf, err := introspect.NewInterface("org.chromium.Foo").
+//		Method("Bar").In("x", "i").Out("y", "s").
+//		Signal("Changed").Arg("value", "i").
+//		Build()
+//
+// The builders returned by Method, Signal and Property keep building the same
+// interface, so the calls can be chained. The annotations and the doc string
+// of the interface itself must be given before them.
+type InterfaceBuilder struct {
+	itf Interface
+	// err is the first error found while building, returned by Build.
+	err error
+}
+
+// NewInterface returns a builder of the interface named name.
+func NewInterface(name string) *InterfaceBuilder {
+	return &InterfaceBuilder{itf: Interface{Name: name}}
+}
+
+// setErr records err unless an error is already recorded.
+func (b *InterfaceBuilder) setErr(err error) {
+	if b.err == nil {
+		b.err = err
+	}
+}
+
+// Annotate adds the annotation name with value to the interface.
+func (b *InterfaceBuilder) Annotate(name, value string) *InterfaceBuilder {
+	b.itf.Annotations = append(b.itf.Annotations, Annotation{Name: name, Value: value})
+	return b
+}
+
+// Doc sets the doc string of the interface.
+func (b *InterfaceBuilder) Doc(doc string) *InterfaceBuilder {
+	b.itf.DocString = DocString(doc)
+	return b
+}
+
+// Method adds the method named name to the interface, and returns the
+// builder of its arguments and annotations.
+func (b *InterfaceBuilder) Method(name string) *MethodBuilder {
+	b.itf.Methods = append(b.itf.Methods, Method{Name: name})
+	return &MethodBuilder{InterfaceBuilder: b, index: len(b.itf.Methods) - 1}
+}
+
+// Signal adds the signal named name to the interface, and returns the builder
+// of its arguments and annotations.
+func (b *InterfaceBuilder) Signal(name string) *SignalBuilder {
+	b.itf.Signals = append(b.itf.Signals, Signal{Name: name})
+	return &SignalBuilder{InterfaceBuilder: b, index: len(b.itf.Signals) - 1}
+}
+
+// Property adds the property named name of the D-Bus type typ to the
+// interface, and returns the builder of its annotation. access is one of
+// "read", "write" and "readwrite".
+func (b *InterfaceBuilder) Property(name, typ, access string) *PropertyBuilder {
+	b.itf.Properties = append(b.itf.Properties, Property{Name: name, Type: typ, Access: access})
+	return &PropertyBuilder{InterfaceBuilder: b, index: len(b.itf.Properties) - 1}
+}
+
+// Build returns the interface, verified in the same way as the interfaces
+// parsed from the introspection XML. The builder must not be used afterwards.
+func (b *InterfaceBuilder) Build() (Interface, error) {
+	if b.err != nil {
+		return Interface{}, fmt.Errorf("%s interface: %v", b.itf.Name, b.err)
+	}
+	if err := verifyInterface(&b.itf); err != nil {
+		return Interface{}, fmt.Errorf("%s interface: %v", b.itf.Name, err)
+	}
+	return b.itf, nil
+}
+
+// MethodBuilder builds a method of an interface.
+type MethodBuilder struct {
+	*InterfaceBuilder
+	index int
+}
+
+func (b *MethodBuilder) method() *Method {
+	return &b.itf.Methods[b.index]
+}
+
+// In adds the input argument named name of the D-Bus type typ.
+func (b *MethodBuilder) In(name, typ string) *MethodBuilder {
+	m := b.method()
+	m.Args = append(m.Args, MethodArg{Name: name, Type: NonNamespaceString(typ), Direction: "in"})
+	return b
+}
+
+// Out adds the output argument named name of the D-Bus type typ.
+func (b *MethodBuilder) Out(name, typ string) *MethodBuilder {
+	m := b.method()
+	m.Args = append(m.Args, MethodArg{Name: name, Type: NonNamespaceString(typ), Direction: "out"})
+	return b
+}
+
+// Annotate adds the annotation name with value to the method.
+func (b *MethodBuilder) Annotate(name, value string) *MethodBuilder {
+	m := b.method()
+	m.Annotations = append(m.Annotations, Annotation{Name: name, Value: value})
+	return b
+}
+
+// AnnotateArg adds the annotation name with value to the last argument added
+// to the method. An argument can have each annotation at most once.
+func (b *MethodBuilder) AnnotateArg(name, value string) *MethodBuilder {
+	m := b.method()
+	if len(m.Args) == 0 {
+		b.setErr(fmt.Errorf("%s method: no argument to annotate with %s", m.Name, name))
+		return b
+	}
+	a := &m.Args[len(m.Args)-1]
+	if findAnnotation(a.Annotations, name) != nil {
+		b.setErr(fmt.Errorf("%s method: %s argument already has annotation %s", m.Name, a.Name, name))
+		return b
+	}
+	a.Annotations = append(a.Annotations, Annotation{Name: name, Value: value})
+	return b
+}
+
+// Doc sets the doc string of the method.
+func (b *MethodBuilder) Doc(doc string) *MethodBuilder {
+	b.method().DocString = DocString(doc)
+	return b
+}
+
+// SignalBuilder builds a signal of an interface.
+type SignalBuilder struct {
+	*InterfaceBuilder
+	index int
+}
+
+func (b *SignalBuilder) signal() *Signal {
+	return &b.itf.Signals[b.index]
+}
+
+// Arg adds the argument named name of the D-Bus type typ.
+func (b *SignalBuilder) Arg(name, typ string) *SignalBuilder {
+	s := b.signal()
+	s.Args = append(s.Args, SignalArg{Name: name, Type: typ})
+	return b
+}
+
+// Annotate adds the annotation name with value to the signal.
+func (b *SignalBuilder) Annotate(name, value string) *SignalBuilder {
+	s := b.signal()
+	s.Annotations = append(s.Annotations, Annotation{Name: name, Value: value})
+	return b
+}
+
+// AnnotateArg adds the annotation name with value to the last argument added
+// to the signal. An argument can have each annotation at most once.
+func (b *SignalBuilder) AnnotateArg(name, value string) *SignalBuilder {
+	s := b.signal()
+	if len(s.Args) == 0 {
+		b.setErr(fmt.Errorf("%s signal: no argument to annotate with %s", s.Name, name))
+		return b
+	}
+	a := &s.Args[len(s.Args)-1]
+	if findAnnotation(a.Annotations, name) != nil {
+		b.setErr(fmt.Errorf("%s signal: %s argument already has annotation %s", s.Name, a.Name, name))
+		return b
+	}
+	a.Annotations = append(a.Annotations, Annotation{Name: name, Value: value})
+	return b
+}
+
+// Doc sets the doc string of the signal.
+func (b *SignalBuilder) Doc(doc string) *SignalBuilder {
+	b.signal().DocString = DocString(doc)
+	return b
+}
+
+// PropertyBuilder builds a property of an interface.
+type PropertyBuilder struct {
+	*InterfaceBuilder
+	index int
+}
+
+func (b *PropertyBuilder) property() *Property {
+	return &b.itf.Properties[b.index]
+}
+
+// Annotate adds the annotation name with value to the property.
+func (b *PropertyBuilder) Annotate(name, value string) *PropertyBuilder {
+	p := b.property()
+	p.Annotations = append(p.Annotations, Annotation{Name: name, Value: value})
+	return b
+}
+
+// Doc sets the doc string of the property.
+func (b *PropertyBuilder) Doc(doc string) *PropertyBuilder {
+	b.property().DocString = DocString(doc)
+	return b
+}
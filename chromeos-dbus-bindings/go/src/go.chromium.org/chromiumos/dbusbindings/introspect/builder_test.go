@@ -0,0 +1,105 @@
+// Copyright 2022 The ChromiumOS Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+package introspect_test
+
+import (
+	"strings"
+	"testing"
+
+	"go.chromium.org/chromiumos/dbusbindings/introspect"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestInterfaceBuilder(t *testing.T) {
+	got, err := introspect.NewInterface("org.chromium.Foo").
+		Annotate("org.chromium.DBus.Interface.LightweightProperties", "true").
+		Doc("The foo interface.").
+		Method("Bar").In("x", "i").
+		AnnotateArg("org.chromium.DBus.Argument.DefaultValue", "0").
+		Out("y", "s").
+		Annotate("org.chromium.DBus.Method.Kind", "simple").
+		Doc("Converts x.").
+		Signal("Changed").Arg("value", "i").Doc("Emitted on change.").
+		Property("Size", "u", "read").Annotate("org.chromium.DBus.Property.VariableName", "size").
+		Method("Reset").
+		Build()
+	if err != nil {
+		t.Fatalf("Build got error, want nil: %v", err)
+	}
+
+	want := introspect.Interface{
+		Name: "org.chromium.Foo",
+		Annotations: []introspect.Annotation{
+			{Name: "org.chromium.DBus.Interface.LightweightProperties", Value: "true"},
+		},
+		DocString: "The foo interface.",
+		Methods: []introspect.Method{
+			{
+				Name: "Bar",
+				Args: []introspect.MethodArg{
+					{
+						Name: "x", Type: "i", Direction: "in",
+						Annotations: []introspect.Annotation{{Name: "org.chromium.DBus.Argument.DefaultValue", Value: "0"}},
+					},
+					{Name: "y", Type: "s", Direction: "out"},
+				},
+				Annotations: []introspect.Annotation{
+					{Name: "org.chromium.DBus.Method.Kind", Value: "simple"},
+				},
+				DocString: "Converts x.",
+			},
+			{Name: "Reset"},
+		},
+		Signals: []introspect.Signal{
+			{
+				Name:      "Changed",
+				Args:      []introspect.SignalArg{{Name: "value", Type: "i"}},
+				DocString: "Emitted on change.",
+			},
+		},
+		Properties: []introspect.Property{
+			{
+				Name: "Size", Type: "u", Access: "read",
+				Annotations: []introspect.Annotation{{Name: "org.chromium.DBus.Property.VariableName", Value: "size"}},
+			},
+		},
+	}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("Build got unexpected interface (-got +want):\n%s", diff)
+	}
+}
+
+func TestInterfaceBuilderErrors(t *testing.T) {
+	cases := []struct {
+		name    string
+		builder *introspect.InterfaceBuilder
+		want    string
+	}{
+		{
+			name:    "annotation without argument",
+			builder: introspect.NewInterface("org.chromium.Foo").Method("Bar").AnnotateArg("a", "b").InterfaceBuilder,
+			want:    "Bar method: no argument to annotate",
+		}, {
+			name: "duplicate argument annotation",
+			builder: introspect.NewInterface("org.chromium.Foo").
+				Signal("Changed").Arg("value", "i").AnnotateArg("a", "b").AnnotateArg("c", "d").AnnotateArg("a", "e").InterfaceBuilder,
+			want: "value argument already has annotation a",
+		}, {
+			name:    "invalid interface",
+			builder: introspect.NewInterface("org.chromium.Foo").Method("Bar").In("x", "i").AnnotateArg("org.chromium.DBus.Argument.Optional", "true").InterfaceBuilder,
+			want:    "Bar method",
+		},
+	}
+	for _, tc := range cases {
+		_, err := tc.builder.Build()
+		if err == nil {
+			t.Errorf("%s: Build succeeded unexpectedly", tc.name)
+			continue
+		}
+		if !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: Build got error %q, want it to contain %q", tc.name, err, tc.want)
+		}
+	}
+}
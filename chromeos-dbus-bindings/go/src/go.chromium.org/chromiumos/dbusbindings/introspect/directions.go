@@ -0,0 +1,136 @@
+// Copyright 2022 The ChromiumOS Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package introspect
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+)
+
+// directionAttrRE matches a direction attribute in an arg start tag,
+// including the preceding white spaces.
+var directionAttrRE = regexp.MustCompile(`\s+direction\s*=\s*("[^"]*"|'[^']*')`)
+
+// directionIssue is a method argument without a direction, which silently
+// defaults to "in", or a signal argument with a direction, which is ignored.
+type directionIssue struct {
+	// start and end are the offsets of the start tag of the argument.
+	start, end int
+	signal     bool
+	message    string
+}
+
+// attrValue returns the value of the attribute named name in e, and whether
+// e has the attribute.
+func attrValue(e xml.StartElement, name string) (string, bool) {
+	for _, a := range e.Attr {
+		if a.Name.Local == name {
+			return a.Value, true
+		}
+	}
+	return "", false
+}
+
+// scanDirections returns the direction issues in the introspection XML
+// content, in the order of appearance.
+func scanDirections(content []byte) ([]directionIssue, error) {
+	d := xml.NewDecoder(bytes.NewReader(content))
+	var ret []directionIssue
+	var itf, member, kind string
+	var index int
+	for {
+		start := int(d.InputOffset())
+		tok, err := d.Token()
+		if err == io.EOF {
+			return ret, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "interface":
+				itf, _ = attrValue(t, "name")
+			case "method", "signal":
+				name, _ := attrValue(t, "name")
+				member, kind, index = itf+"."+name, t.Name.Local, 0
+			case "arg":
+				if member == "" {
+					continue
+				}
+				name, ok := attrValue(t, "name")
+				if !ok {
+					name = strconv.Itoa(index)
+				}
+				index++
+				_, hasDir := attrValue(t, "direction")
+				line := bytes.Count(content[:start], []byte("\n")) + 1
+				switch {
+				case kind == "method" && !hasDir:
+					ret = append(ret, directionIssue{start, int(d.InputOffset()), false,
+						fmt.Sprintf("line %d: argument %s of method %s has no direction; it defaults to in", line, name, member)})
+				case kind == "signal" && hasDir:
+					ret = append(ret, directionIssue{start, int(d.InputOffset()), true,
+						fmt.Sprintf("line %d: argument %s of signal %s has a direction, which is ignored", line, name, member)})
+				}
+			}
+		case xml.EndElement:
+			if t.Name.Local == "method" || t.Name.Local == "signal" {
+				member = ""
+			}
+		}
+	}
+}
+
+// CheckDirections returns the messages describing the method arguments
+// without directions, which default to "in", and the signal arguments with
+// directions, which are ignored, in the introspection XML content. They are
+// valid, but often mistakes resulting in silently mis-generated signatures.
+func CheckDirections(content []byte) ([]string, error) {
+	issues, err := scanDirections(content)
+	if err != nil {
+		return nil, err
+	}
+	var ret []string
+	for _, i := range issues {
+		ret = append(ret, i.message)
+	}
+	return ret, nil
+}
+
+// FixDirections returns the introspection XML content where the issues
+// reported by CheckDirections are fixed, i.e. direction="in" is added to the
+// method arguments without directions as their last attributes, and the
+// directions of the signal arguments are removed. The rest of content is kept
+// as is.
+func FixDirections(content []byte) ([]byte, error) {
+	issues, err := scanDirections(content)
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	last := 0
+	for _, i := range issues {
+		tag := content[i.start:i.end]
+		b.Write(content[last:i.start])
+		if i.signal {
+			b.Write(directionAttrRE.ReplaceAll(tag, nil))
+		} else {
+			// Append the attribute after the last one.
+			n := len(bytes.TrimRight(bytes.TrimSuffix(bytes.TrimSuffix(tag, []byte(">")), []byte("/")), " \t\r\n"))
+			b.Write(tag[:n])
+			b.WriteString(` direction="in"`)
+			b.Write(tag[n:])
+		}
+		last = i.end
+	}
+	b.Write(content[last:])
+	return b.Bytes(), nil
+}
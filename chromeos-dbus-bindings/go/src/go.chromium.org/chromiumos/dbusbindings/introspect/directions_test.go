@@ -0,0 +1,79 @@
+// Copyright 2022 The ChromiumOS Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package introspect_test
+
+import (
+	"testing"
+
+	"go.chromium.org/chromiumos/dbusbindings/introspect"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const directionsInput = `<node>
+  <interface name="org.chromium.Test">
+    <method name="Ping">
+      <arg name="request" type="s"/>
+      <arg type="i"
+           direction="out"/>
+      <arg type="i"></arg>
+    </method>
+    <signal name="Changed">
+      <arg name="value" type="i" direction='out' />
+    </signal>
+  </interface>
+</node>
+`
+
+func TestCheckDirections(t *testing.T) {
+	got, err := introspect.CheckDirections([]byte(directionsInput))
+	if err != nil {
+		t.Fatalf("CheckDirections failed: %v", err)
+	}
+	want := []string{
+		"line 4: argument request of method org.chromium.Test.Ping has no direction; it defaults to in",
+		"line 7: argument 2 of method org.chromium.Test.Ping has no direction; it defaults to in",
+		"line 10: argument value of signal org.chromium.Test.Changed has a direction, which is ignored",
+	}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("CheckDirections failed (-got +want):\n%s", diff)
+	}
+
+	if _, err := introspect.CheckDirections([]byte("<node>")); err == nil {
+		t.Error("CheckDirections unexpectedly succeeded with a malformed XML")
+	}
+}
+
+func TestFixDirections(t *testing.T) {
+	got, err := introspect.FixDirections([]byte(directionsInput))
+	if err != nil {
+		t.Fatalf("FixDirections failed: %v", err)
+	}
+	const want = `<node>
+  <interface name="org.chromium.Test">
+    <method name="Ping">
+      <arg name="request" type="s" direction="in"/>
+      <arg type="i"
+           direction="out"/>
+      <arg type="i" direction="in"></arg>
+    </method>
+    <signal name="Changed">
+      <arg name="value" type="i" />
+    </signal>
+  </interface>
+</node>
+`
+	if diff := cmp.Diff(string(got), want); diff != "" {
+		t.Errorf("FixDirections failed (-got +want):\n%s", diff)
+	}
+
+	issues, err := introspect.CheckDirections(got)
+	if err != nil {
+		t.Fatalf("CheckDirections failed: %v", err)
+	}
+	if len(issues) != 0 {
+		t.Errorf("CheckDirections found issues in the fixed XML: %v", issues)
+	}
+}
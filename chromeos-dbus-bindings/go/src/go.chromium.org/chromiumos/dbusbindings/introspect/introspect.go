@@ -9,6 +9,10 @@ package introspect
 import (
 	"encoding/xml"
 	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
 
 	"go.chromium.org/chromiumos/dbusbindings/dbustype"
 )
@@ -34,6 +38,58 @@ const (
 	MethodKindRaw
 )
 
+// SignalKind is an enum to represent the kind of a signal.
+type SignalKind int
+
+const (
+	// SignalKindNormal indicates that the signal handler takes the signal arguments.
+	SignalKindNormal SignalKind = iota
+
+	// SignalKindRaw indicates that the signal handler takes the dbus::Signal object directly,
+	// e.g. to read the sender or the serial of the signal.
+	SignalKindRaw
+)
+
+// PropertyCachePolicy is an enum to represent how the proxy getter of a property
+// obtains its value.
+type PropertyCachePolicy int
+
+const (
+	// PropertyCachePolicyCached indicates that the getter returns the value
+	// cached by the property set.
+	PropertyCachePolicyCached PropertyCachePolicy = iota
+
+	// PropertyCachePolicyFetchOnce indicates that the getter blocks to fetch
+	// the value if it is not cached yet.
+	PropertyCachePolicyFetchOnce
+
+	// PropertyCachePolicyAlways indicates that the getter blocks to fetch the
+	// value on every call.
+	PropertyCachePolicyAlways
+)
+
+// PropertyEmitsChangedSignal is an enum to represent how a property notifies
+// the changes of its value, given by the standard
+// org.freedesktop.DBus.Property.EmitsChangedSignal annotation.
+type PropertyEmitsChangedSignal int
+
+const (
+	// PropertyEmitsChangedSignalTrue indicates that PropertiesChanged is
+	// emitted with the new value. This is the default.
+	PropertyEmitsChangedSignalTrue PropertyEmitsChangedSignal = iota
+
+	// PropertyEmitsChangedSignalInvalidates indicates that PropertiesChanged
+	// is emitted without the new value, which needs to be fetched.
+	PropertyEmitsChangedSignalInvalidates
+
+	// PropertyEmitsChangedSignalConst indicates that the value never changes.
+	PropertyEmitsChangedSignalConst
+
+	// PropertyEmitsChangedSignalFalse indicates that PropertiesChanged is
+	// not emitted, so the value can be observed only by polling.
+	PropertyEmitsChangedSignalFalse
+)
+
 // Annotation adds settings to MethodArg, SignalArg and Method.
 type Annotation struct {
 	Name  string `xml:"name,attr"`
@@ -62,9 +118,20 @@ type MethodArg struct {
 	Name      string             `xml:"name,attr"`
 	Type      NonNamespaceString `xml:"type,attr"`
 	Direction string             `xml:"direction,attr"`
-	// For now, MethodArg supports only ProtobufClass annotation only,
-	// so it can have at most one annotation.
-	Annotation Annotation `xml:"annotation"`
+	// Annotations are org.chromium.DBus.Argument.* or
+	// org.chromium.DBus.Struct.FieldNames annotations. UnmarshalXML rejects
+	// the arguments having an annotation more than once.
+	Annotations []Annotation `xml:"annotation"`
+}
+
+// UnmarshalXML unmarshals the argument, failing if it has an annotation more
+// than once.
+func (a *MethodArg) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type methodArg MethodArg
+	if err := d.DecodeElement((*methodArg)(a), &start); err != nil {
+		return err
+	}
+	return checkArgAnnotations(a.Name, a.Annotations)
 }
 
 // TODO(chromium:983008): Remove the workaround for docstring tags that repeatedly appeared in
@@ -73,10 +140,27 @@ type MethodArg struct {
 // DocString represents a string of a document tag.
 type DocString string
 
-// UnmarshalText unmarshal all text even if it repeatedly appeared.
-func (s *DocString) UnmarshalText(text []byte) error {
-	*s += DocString(text)
-	return nil
+// UnmarshalXML unmarshals all text even if it repeatedly appeared. The tags
+// nested in the document tag, e.g. <tp:member-ref>, are stripped, and their
+// text is retained.
+func (s *DocString) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	for depth := 0; ; {
+		tok, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case xml.CharData:
+			*s += DocString(t)
+		case xml.StartElement:
+			depth++
+		case xml.EndElement:
+			if depth == 0 {
+				return nil
+			}
+			depth--
+		}
+	}
 }
 
 // Method represents method provided by a object through a interface.
@@ -94,9 +178,66 @@ type Method struct {
 type SignalArg struct {
 	Name string `xml:"name,attr"`
 	Type string `xml:"type,attr"`
-	// For now, MethodArg supports only ProtobufClass annotation only,
-	// so it can have at most one annotation.
-	Annotation Annotation `xml:"annotation"`
+	// Annotations are org.chromium.DBus.Argument.* or
+	// org.chromium.DBus.Struct.FieldNames annotations. UnmarshalXML rejects
+	// the arguments having an annotation more than once.
+	Annotations []Annotation `xml:"annotation"`
+}
+
+// UnmarshalXML unmarshals the argument, failing if it has an annotation more
+// than once.
+func (a *SignalArg) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type signalArg SignalArg
+	if err := d.DecodeElement((*signalArg)(a), &start); err != nil {
+		return err
+	}
+	return checkArgAnnotations(a.Name, a.Annotations)
+}
+
+// checkArgAnnotations returns an error if an annotation appears more than
+// once in annotations of the argument name.
+func checkArgAnnotations(name string, annotations []Annotation) error {
+	seen := make(map[string]bool)
+	for _, a := range annotations {
+		if seen[a.Name] {
+			return fmt.Errorf("%s argument: duplicate annotation %s", name, a.Name)
+		}
+		seen[a.Name] = true
+	}
+	return nil
+}
+
+// typeAnnotationNames are the annotations which determine the C++ type of an
+// argument. If an argument has more than one of them, the first one is used.
+var typeAnnotationNames = map[string]bool{
+	"org.chromium.DBus.Argument.ProtobufClass": true,
+	"org.chromium.DBus.Argument.CppType":       true,
+	"org.chromium.DBus.Argument.EnumClass":     true,
+	"org.chromium.DBus.Argument.FlagsClass":    true,
+	"org.chromium.DBus.Argument.Optional":      true,
+	"org.chromium.DBus.Struct.FieldNames":      true,
+}
+
+// findAnnotation returns the annotation named name in annotations, or nil if
+// there is none.
+func findAnnotation(annotations []Annotation, name string) *Annotation {
+	for i := range annotations {
+		if annotations[i].Name == name {
+			return &annotations[i]
+		}
+	}
+	return nil
+}
+
+// findTypeAnnotation returns the first annotation in annotations which
+// determines the C++ type of the argument, or nil if there is none.
+func findTypeAnnotation(annotations []Annotation) *Annotation {
+	for i := range annotations {
+		if typeAnnotationNames[annotations[i].Name] {
+			return &annotations[i]
+		}
+	}
+	return nil
 }
 
 // Signal represents signal provided by a object through a interface.
@@ -104,9 +245,10 @@ type SignalArg struct {
 // "http://telepathy.freedesktop.org/wiki/DbusSpec#extensions-v0" xml tag to DocString after
 // fixing.
 type Signal struct {
-	Name      string      `xml:"name,attr"`
-	Args      []SignalArg `xml:"arg"`
-	DocString DocString   `xml:"docstring"`
+	Name        string       `xml:"name,attr"`
+	Args        []SignalArg  `xml:"arg"`
+	Annotations []Annotation `xml:"annotation"`
+	DocString   DocString    `xml:"docstring"`
 }
 
 // Property represents property provided by a object through a interface.
@@ -114,13 +256,60 @@ type Signal struct {
 // "http://telepathy.freedesktop.org/wiki/DbusSpec#extensions-v0" xml tag to DocString after
 // fixing.
 type Property struct {
-	Name      string    `xml:"name,attr"`
-	Type      string    `xml:"type,attr"`
-	Access    string    `xml:"access,attr"`
-	DocString DocString `xml:"docstring"`
-	// For now, Property supports only VariableName annotation only,
-	// so it can have at most one annotation.
-	Annotation Annotation `xml:"annotation"`
+	Name        string       `xml:"name,attr"`
+	Type        string       `xml:"type,attr"`
+	Access      string       `xml:"access,attr"`
+	DocString   DocString    `xml:"docstring"`
+	Annotations []Annotation `xml:"annotation"`
+}
+
+// StructField represents a field of a StructDef.
+type StructField struct {
+	Name string
+	Type string
+}
+
+// StructDef represents a named C++ struct which a struct-typed argument is rendered as,
+// given by the org.chromium.DBus.Struct.FieldNames annotation.
+type StructDef struct {
+	Name string
+	// Signature is the D-Bus signature of the struct, e.g. "(ssu)".
+	Signature string
+	Fields    []StructField
+}
+
+// Targets of the org.chromium.DBus.Skip<Target> annotations, which omit members
+// from the generated code of the target only.
+const (
+	SkipTargetProxy   = "Proxy"
+	SkipTargetAdaptor = "Adaptor"
+)
+
+// EnumDef represents a C++ enum which an integer argument is rendered as,
+// given by the org.chromium.DBus.Argument.EnumClass annotation.
+type EnumDef struct {
+	Name string
+	// Signature is the D-Bus signature of the underlying integer, e.g. "i".
+	Signature string
+	// UnderlyingType is the C++ type of the underlying integer, e.g. "int32_t".
+	UnderlyingType string
+}
+
+// FlagsValue represents a value of a FlagsDef.
+type FlagsValue struct {
+	Name  string
+	Value uint64
+}
+
+// FlagsDef represents a C++ flags enum which an unsigned integer argument is rendered as,
+// given by the org.chromium.DBus.Argument.FlagsClass annotation.
+type FlagsDef struct {
+	Name string
+	// Signature is the D-Bus signature of the underlying integer, e.g. "u".
+	Signature string
+	// UnderlyingType is the C++ type of the underlying integer, e.g. "uint32_t".
+	UnderlyingType string
+	Values         []FlagsValue
 }
 
 // Interface represents interface provided by a object.
@@ -128,11 +317,19 @@ type Property struct {
 // "http://telepathy.freedesktop.org/wiki/DbusSpec#extensions-v0" xml tag to DocString after
 // fixing.
 type Interface struct {
-	Name       string     `xml:"name,attr"`
-	Methods    []Method   `xml:"method"`
-	Signals    []Signal   `xml:"signal"`
-	Properties []Property `xml:"property"`
-	DocString  DocString  `xml:"docstring"`
+	Name        string       `xml:"name,attr"`
+	Methods     []Method     `xml:"method"`
+	Signals     []Signal     `xml:"signal"`
+	Properties  []Property   `xml:"property"`
+	Annotations []Annotation `xml:"annotation"`
+	DocString   DocString    `xml:"docstring"`
+}
+
+// Include represents a reference to another introspection XML file whose
+// interfaces are merged into the including one. This is an extension to the
+// D-Bus introspection format, resolved by ParseFile.
+type Include struct {
+	Href string `xml:"href,attr"`
 }
 
 // Introspection represents object specification required for generating
@@ -140,6 +337,33 @@ type Interface struct {
 type Introspection struct {
 	Name       string      `xml:"name,attr"`
 	Interfaces []Interface `xml:"interface"`
+	Includes   []Include   `xml:"include"`
+	// Nodes are the child objects, whose names are relative to the object
+	// path of this one unless they start with "/", e.g. as returned by a
+	// recursive introspection of a service. Use Flatten to compose their
+	// object paths.
+	Nodes []Introspection `xml:"node"`
+}
+
+// Flatten returns the introspection followed by its child nodes, recursively,
+// each without child nodes and named with its composed object path, e.g.
+// "/org/chromium/Service/child" for the node "child" of
+// "/org/chromium/Service". The child nodes without interfaces are omitted.
+func (i *Introspection) Flatten() []Introspection {
+	root := *i
+	root.Nodes = nil
+	ret := []Introspection{root}
+	for _, n := range i.Nodes {
+		if !strings.HasPrefix(n.Name, "/") {
+			n.Name = strings.TrimSuffix(i.Name, "/") + "/" + n.Name
+		}
+		for _, f := range n.Flatten() {
+			if len(f.Interfaces) > 0 {
+				ret = append(ret, f)
+			}
+		}
+	}
+	return ret
 }
 
 // InputArguments returns the array of input arguments extracted from method arguments.
@@ -164,6 +388,24 @@ func (m *Method) OutputArguments() []MethodArg {
 	return ret
 }
 
+// InputSignature returns the D-Bus signature made up of the types of the input arguments.
+func (m *Method) InputSignature() string {
+	var ret strings.Builder
+	for _, a := range m.InputArguments() {
+		ret.WriteString(string(a.Type))
+	}
+	return ret.String()
+}
+
+// OutputSignature returns the D-Bus signature made up of the types of the output arguments.
+func (m *Method) OutputSignature() string {
+	var ret strings.Builder
+	for _, a := range m.OutputArguments() {
+		ret.WriteString(string(a.Type))
+	}
+	return ret.String()
+}
+
 // Kind returns the kind of method.
 func (m *Method) Kind() MethodKind {
 	for _, a := range m.Annotations {
@@ -189,6 +431,16 @@ func (m *Method) Kind() MethodKind {
 	return MethodKindNormal
 }
 
+// Kind returns the kind of signal.
+func (s *Signal) Kind() SignalKind {
+	for _, a := range s.Annotations {
+		if a.Name == "org.chromium.DBus.Signal.Kind" && a.Value == "raw" {
+			return SignalKindRaw
+		}
+	}
+	return SignalKindNormal
+}
+
 // IncludeDBusMessage returns true if the method needs a message argument added.
 func (m *Method) IncludeDBusMessage() bool {
 	for _, a := range m.Annotations {
@@ -199,6 +451,48 @@ func (m *Method) IncludeDBusMessage() bool {
 	return false
 }
 
+// Errors returns the D-Bus error names the method may reply with, listed in
+// the org.chromium.DBus.Method.Errors annotation separated by white spaces.
+func (m *Method) Errors() []string {
+	for _, a := range m.Annotations {
+		if a.Name == "org.chromium.DBus.Method.Errors" {
+			return strings.Fields(a.Value)
+		}
+	}
+	return nil
+}
+
+// DefaultValue returns the C++ expression given by the
+// org.chromium.DBus.Argument.DefaultValue annotation, or an empty string if
+// the argument has no default value.
+func (a *MethodArg) DefaultValue() string {
+	v, _ := a.Annotation("org.chromium.DBus.Argument.DefaultValue")
+	return v
+}
+
+// ReturnsFDStream returns true if the only output argument of the method is
+// a file descriptor to read a length-prefixed stream from.
+func (m *Method) ReturnsFDStream() bool {
+	for _, a := range m.Annotations {
+		if a.Name == "org.chromium.DBus.Method.ReturnsFDStream" {
+			return a.Value == "true"
+		}
+	}
+	return false
+}
+
+// NoReply returns true if the method does not reply, as annotated with
+// org.freedesktop.DBus.Method.NoReply, so that its callers do not wait for
+// the reply.
+func (m *Method) NoReply() bool {
+	for _, a := range m.Annotations {
+		if a.Name == "org.freedesktop.DBus.Method.NoReply" {
+			return a.Value == "true"
+		}
+	}
+	return false
+}
+
 // Const returns true if the method is a const member function.
 func (m *Method) Const() bool {
 	for _, a := range m.Annotations {
@@ -209,21 +503,191 @@ func (m *Method) Const() bool {
 	return false
 }
 
+// Skipped returns true if the method is omitted from the generated code of
+// target by the org.chromium.DBus.Skip or org.chromium.DBus.Skip<target>
+// annotation.
+func (m *Method) Skipped(target string) bool {
+	return skippedInternal(m.Annotations, target)
+}
+
+// Skipped returns true if the signal is omitted from the generated code of
+// target by the org.chromium.DBus.Skip or org.chromium.DBus.Skip<target>
+// annotation.
+func (s *Signal) Skipped(target string) bool {
+	return skippedInternal(s.Annotations, target)
+}
+
+// Skipped returns true if the property is omitted from the generated code of
+// target by the org.chromium.DBus.Skip or org.chromium.DBus.Skip<target>
+// annotation.
+func (p *Property) Skipped(target string) bool {
+	return skippedInternal(p.Annotations, target)
+}
+
+// MinVersion returns the version of the service API which introduced the
+// method, given by the org.chromium.DBus.MinVersion annotation, or 0 if the
+// method is not annotated.
+func (m *Method) MinVersion() int {
+	return minVersionInternal(m.Annotations)
+}
+
+// MinVersion returns the version of the service API which introduced the
+// signal, given by the org.chromium.DBus.MinVersion annotation, or 0 if the
+// signal is not annotated.
+func (s *Signal) MinVersion() int {
+	return minVersionInternal(s.Annotations)
+}
+
+// MinVersion returns the version of the service API which introduced the
+// property, given by the org.chromium.DBus.MinVersion annotation, or 0 if the
+// property is not annotated.
+func (p *Property) MinVersion() int {
+	return minVersionInternal(p.Annotations)
+}
+
+// ProtobufIncludes returns the headers defining the protobuf classes used by the interface,
+// listed in the org.chromium.DBus.Interface.ProtobufIncludes annotation separated by
+// white spaces or commas.
+func (itf *Interface) ProtobufIncludes() []string {
+	for _, a := range itf.Annotations {
+		if a.Name == "org.chromium.DBus.Interface.ProtobufIncludes" {
+			return strings.FieldsFunc(a.Value, func(r rune) bool {
+				return r == ',' || unicode.IsSpace(r)
+			})
+		}
+	}
+	return nil
+}
+
+// LightweightProperties returns true if the interface has the
+// org.chromium.DBus.Interface.LightweightProperties annotation set to "true".
+// The proxies of such interfaces track the property changes by connecting to
+// the PropertiesChanged signal directly, instead of using dbus::PropertySet.
+func (itf *Interface) LightweightProperties() bool {
+	for _, a := range itf.Annotations {
+		if a.Name == "org.chromium.DBus.Interface.LightweightProperties" {
+			return a.Value == "true"
+		}
+	}
+	return false
+}
+
+// BatchPropertyChanges returns true if the interface has the
+// org.chromium.DBus.Interface.BatchPropertyChanges annotation set to "true".
+// The adaptors of such interfaces can set many properties at once, emitting a
+// single PropertiesChanged signal.
+func (itf *Interface) BatchPropertyChanges() bool {
+	for _, a := range itf.Annotations {
+		if a.Name == "org.chromium.DBus.Interface.BatchPropertyChanges" {
+			return a.Value == "true"
+		}
+	}
+	return false
+}
+
+// UsesProtobuf returns true if any method or signal argument of the interface
+// has the org.chromium.DBus.Argument.ProtobufClass annotation.
+func (itf *Interface) UsesProtobuf() bool {
+	for _, m := range itf.Methods {
+		for _, a := range m.Args {
+			if _, ok := a.Annotation("org.chromium.DBus.Argument.ProtobufClass"); ok {
+				return true
+			}
+		}
+	}
+	for _, s := range itf.Signals {
+		for _, a := range s.Args {
+			if _, ok := a.Annotation("org.chromium.DBus.Argument.ProtobufClass"); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Annotation returns the value of the annotation named name of the argument,
+// and whether the argument has it.
+func (a *MethodArg) Annotation(name string) (string, bool) {
+	if an := findAnnotation(a.Annotations, name); an != nil {
+		return an.Value, true
+	}
+	return "", false
+}
+
+// TypeAnnotation returns the annotation which determines the C++ type of the
+// argument, and whether the argument has one.
+func (a *MethodArg) TypeAnnotation() (Annotation, bool) {
+	if an := findTypeAnnotation(a.Annotations); an != nil {
+		return *an, true
+	}
+	return Annotation{}, false
+}
+
 // BaseType returns the C++ type corresponding to the type that the argument describes.
 func (a *MethodArg) BaseType() (string, error) {
-	return baseTypeInternal(string(a.Type), &a.Annotation)
+	return baseTypeInternal(string(a.Type), findTypeAnnotation(a.Annotations))
 }
 
 // InArgType returns the C++ type corresponding to the type that the argument describes
 // for an in argument.
 func (a *MethodArg) InArgType() (string, error) {
-	return inArgTypeInternal(string(a.Type), &a.Annotation)
+	return inArgTypeInternal(string(a.Type), findTypeAnnotation(a.Annotations))
 }
 
 // OutArgType returns the C++ type corresponding to the type that the argument describes
 // for an out argument.
 func (a *MethodArg) OutArgType() (string, error) {
-	return outArgTypeInternal(string(a.Type), &a.Annotation)
+	return outArgTypeInternal(string(a.Type), findTypeAnnotation(a.Annotations))
+}
+
+// StructDef returns the named struct the argument is rendered as, or nil if the argument
+// does not have the org.chromium.DBus.Struct.FieldNames annotation.
+func (a *MethodArg) StructDef() (*StructDef, error) {
+	return structDefInternal(string(a.Type), findTypeAnnotation(a.Annotations))
+}
+
+// EnumDef returns the definition of the enum that the argument is rendered as, or nil if
+// the argument does not have the org.chromium.DBus.Argument.EnumClass annotation.
+func (a *MethodArg) EnumDef() (*EnumDef, error) {
+	return enumDefInternal(string(a.Type), findTypeAnnotation(a.Annotations))
+}
+
+// FlagsDef returns the definition of the flags enum that the argument is rendered as, or
+// nil if the argument does not have the org.chromium.DBus.Argument.FlagsClass annotation.
+func (a *MethodArg) FlagsDef() (*FlagsDef, error) {
+	return flagsDefInternal(string(a.Type), findTypeAnnotation(a.Annotations))
+}
+
+// Optional returns true if the argument is rendered as std::optional by the
+// org.chromium.DBus.Argument.Optional annotation.
+func (a *MethodArg) Optional() bool {
+	v, _ := a.Annotation("org.chromium.DBus.Argument.Optional")
+	return v == "true"
+}
+
+// PassByMove returns true if the proxy methods get overloads taking the
+// argument by rvalue reference, as annotated with
+// org.chromium.DBus.Argument.PassByMove, so that large containers can be
+// moved into the calls.
+func (a *MethodArg) PassByMove() bool {
+	v, _ := a.Annotation("org.chromium.DBus.Argument.PassByMove")
+	return v == "true"
+}
+
+// VariantTypes returns the D-Bus signatures of the values which the variant
+// argument may carry, listed in the org.chromium.DBus.Argument.VariantTypes
+// annotation separated by white spaces, and whether the list is closed, i.e.
+// not terminated by "...".
+func (a *MethodArg) VariantTypes() ([]string, bool) {
+	v, ok := a.Annotation("org.chromium.DBus.Argument.VariantTypes")
+	if !ok {
+		return nil, false
+	}
+	types := strings.Fields(v)
+	if n := len(types); n > 0 && types[n-1] == "..." {
+		return types[:n-1], false
+	}
+	return types, true
 }
 
 // CallbackType returns the C++ type to be used as a callback's argument.
@@ -234,21 +698,66 @@ func (a *MethodArg) CallbackType() (string, error) {
 	return a.InArgType()
 }
 
+// Signature returns the D-Bus signature made up of the types of the signal arguments.
+func (s *Signal) Signature() string {
+	var ret strings.Builder
+	for _, a := range s.Args {
+		ret.WriteString(a.Type)
+	}
+	return ret.String()
+}
+
+// Annotation returns the value of the annotation named name of the argument,
+// and whether the argument has it.
+func (a *SignalArg) Annotation(name string) (string, bool) {
+	if an := findAnnotation(a.Annotations, name); an != nil {
+		return an.Value, true
+	}
+	return "", false
+}
+
+// TypeAnnotation returns the annotation which determines the C++ type of the
+// argument, and whether the argument has one.
+func (a *SignalArg) TypeAnnotation() (Annotation, bool) {
+	if an := findTypeAnnotation(a.Annotations); an != nil {
+		return *an, true
+	}
+	return Annotation{}, false
+}
+
 // BaseType returns the C++ type corresponding to the type that the argument describes.
 func (a *SignalArg) BaseType() (string, error) {
-	return baseTypeInternal(a.Type, &a.Annotation)
+	return baseTypeInternal(a.Type, findTypeAnnotation(a.Annotations))
 }
 
 // InArgType returns the C++ type corresponding to the type that the argument describes
 // for an in argument.
 func (a *SignalArg) InArgType() (string, error) {
-	return inArgTypeInternal(a.Type, &a.Annotation)
+	return inArgTypeInternal(a.Type, findTypeAnnotation(a.Annotations))
 }
 
 // OutArgType returns the C++ type corresponding to the type that the argument describes
 // for an out argument.
 func (a *SignalArg) OutArgType() (string, error) {
-	return outArgTypeInternal(a.Type, &a.Annotation)
+	return outArgTypeInternal(a.Type, findTypeAnnotation(a.Annotations))
+}
+
+// StructDef returns the named struct the argument is rendered as, or nil if the argument
+// does not have the org.chromium.DBus.Struct.FieldNames annotation.
+func (a *SignalArg) StructDef() (*StructDef, error) {
+	return structDefInternal(a.Type, findTypeAnnotation(a.Annotations))
+}
+
+// EnumDef returns the definition of the enum that the argument is rendered as, or nil if
+// the argument does not have the org.chromium.DBus.Argument.EnumClass annotation.
+func (a *SignalArg) EnumDef() (*EnumDef, error) {
+	return enumDefInternal(a.Type, findTypeAnnotation(a.Annotations))
+}
+
+// FlagsDef returns the definition of the flags enum that the argument is rendered as, or
+// nil if the argument does not have the org.chromium.DBus.Argument.FlagsClass annotation.
+func (a *SignalArg) FlagsDef() (*FlagsDef, error) {
+	return flagsDefInternal(a.Type, findTypeAnnotation(a.Annotations))
 }
 
 // CallbackType returns the C++ type to be used as a callback's argument.
@@ -276,11 +785,60 @@ func (p *Property) OutArgType() (string, error) {
 	return outArgTypeInternal(p.Type, nil)
 }
 
+// CachePolicy returns the cache policy of the property given by the
+// org.chromium.DBus.Property.CachePolicy annotation.
+func (p *Property) CachePolicy() PropertyCachePolicy {
+	for _, a := range p.Annotations {
+		if a.Name == "org.chromium.DBus.Property.CachePolicy" {
+			switch a.Value {
+			case "fetch_once":
+				return PropertyCachePolicyFetchOnce
+			case "always":
+				return PropertyCachePolicyAlways
+			}
+		}
+	}
+	return PropertyCachePolicyCached
+}
+
+// EmitsChangedSignal returns how the property notifies the changes of its
+// value, given by the org.freedesktop.DBus.Property.EmitsChangedSignal
+// annotation.
+func (p *Property) EmitsChangedSignal() PropertyEmitsChangedSignal {
+	for _, a := range p.Annotations {
+		if a.Name == "org.freedesktop.DBus.Property.EmitsChangedSignal" {
+			switch a.Value {
+			case "invalidates":
+				return PropertyEmitsChangedSignalInvalidates
+			case "const":
+				return PropertyEmitsChangedSignalConst
+			case "false":
+				return PropertyEmitsChangedSignalFalse
+			}
+		}
+	}
+	return PropertyEmitsChangedSignalTrue
+}
+
+// Readable returns true if the value of the property can be read, i.e. its
+// access is "read" or "readwrite".
+func (p *Property) Readable() bool {
+	return p.Access == "read" || p.Access == "readwrite"
+}
+
+// Writable returns true if the value of the property can be set, i.e. its
+// access is "write" or "readwrite".
+func (p *Property) Writable() bool {
+	return p.Access == "write" || p.Access == "readwrite"
+}
+
 // VariableName returns annotation value as variable name if the property has
 // annotation of VariableName. Otherwise returns property name.
 func (p *Property) VariableName() string {
-	if p.Annotation.Name == "org.chromium.DBus.Argument.VariableName" {
-		return p.Annotation.Value
+	for _, a := range p.Annotations {
+		if a.Name == "org.chromium.DBus.Argument.VariableName" {
+			return a.Value
+		}
 	}
 	return p.Name
 }
@@ -291,10 +849,48 @@ func baseTypeInternal(s string, a *Annotation) (string, error) {
 		return a.Value, nil
 	}
 
+	// Custom types are (de)serialized by the DBusType specializations
+	// provided with them.
+	if a != nil && a.Name == "org.chromium.DBus.Argument.CppType" {
+		return a.Value, nil
+	}
+
+	// Enums are (de)serialized as their underlying integers.
+	e, err := enumDefInternal(s, a)
+	if err != nil {
+		return "", err
+	}
+	if e != nil {
+		return e.Name, nil
+	}
+	f, err := flagsDefInternal(s, a)
+	if err != nil {
+		return "", err
+	}
+	if f != nil {
+		return f.Name, nil
+	}
+
+	// Optional values are (de)serialized as a presence flag followed by the value.
+	v, err := optionalValueTypeInternal(s, a)
+	if err != nil {
+		return "", err
+	}
+	if v != "" {
+		t, err := baseTypeInternal(v, nil)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("std::optional<%s>", t), nil
+	}
+
 	typ, err := dbustype.Parse(s)
 	if err != nil {
 		return "", err
 	}
+	if err := setStructName(&typ, a); err != nil {
+		return "", err
+	}
 	return typ.BaseType(), nil
 }
 
@@ -304,10 +900,48 @@ func inArgTypeInternal(s string, a *Annotation) (string, error) {
 		return fmt.Sprintf("const %s&", a.Value), nil
 	}
 
+	// Custom types are (de)serialized by the DBusType specializations
+	// provided with them.
+	if a != nil && a.Name == "org.chromium.DBus.Argument.CppType" {
+		return fmt.Sprintf("const %s&", a.Value), nil
+	}
+
+	// Enums are (de)serialized as their underlying integers.
+	e, err := enumDefInternal(s, a)
+	if err != nil {
+		return "", err
+	}
+	if e != nil {
+		return e.Name, nil
+	}
+	f, err := flagsDefInternal(s, a)
+	if err != nil {
+		return "", err
+	}
+	if f != nil {
+		return f.Name, nil
+	}
+
+	// Optional values are (de)serialized as a presence flag followed by the value.
+	v, err := optionalValueTypeInternal(s, a)
+	if err != nil {
+		return "", err
+	}
+	if v != "" {
+		t, err := baseTypeInternal(v, nil)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("const std::optional<%s>&", t), nil
+	}
+
 	typ, err := dbustype.Parse(s)
 	if err != nil {
 		return "", err
 	}
+	if err := setStructName(&typ, a); err != nil {
+		return "", err
+	}
 	return typ.InArgType(), nil
 }
 
@@ -317,9 +951,254 @@ func outArgTypeInternal(s string, a *Annotation) (string, error) {
 		return fmt.Sprintf("%s*", a.Value), nil
 	}
 
+	// Custom types are (de)serialized by the DBusType specializations
+	// provided with them.
+	if a != nil && a.Name == "org.chromium.DBus.Argument.CppType" {
+		return fmt.Sprintf("%s*", a.Value), nil
+	}
+
+	// Enums are (de)serialized as their underlying integers.
+	e, err := enumDefInternal(s, a)
+	if err != nil {
+		return "", err
+	}
+	if e != nil {
+		return e.Name + "*", nil
+	}
+	f, err := flagsDefInternal(s, a)
+	if err != nil {
+		return "", err
+	}
+	if f != nil {
+		return f.Name + "*", nil
+	}
+
+	// Optional values are (de)serialized as a presence flag followed by the value.
+	v, err := optionalValueTypeInternal(s, a)
+	if err != nil {
+		return "", err
+	}
+	if v != "" {
+		t, err := baseTypeInternal(v, nil)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("std::optional<%s>*", t), nil
+	}
+
 	typ, err := dbustype.Parse(s)
 	if err != nil {
 		return "", err
 	}
+	if err := setStructName(&typ, a); err != nil {
+		return "", err
+	}
 	return typ.OutArgType(), nil
 }
+
+// enumClassRE matches the value of the org.chromium.DBus.Argument.EnumClass annotation,
+// e.g. "my::Enum".
+var enumClassRE = regexp.MustCompile(`^(::)?[A-Za-z_][A-Za-z0-9_]*(::[A-Za-z_][A-Za-z0-9_]*)*$`)
+
+// enumDefInternal returns the definition of the enum given by the
+// org.chromium.DBus.Argument.EnumClass annotation a for the D-Bus type s, or nil if
+// a is not the annotation.
+func enumDefInternal(s string, a *Annotation) (*EnumDef, error) {
+	if a == nil || a.Name != "org.chromium.DBus.Argument.EnumClass" {
+		return nil, nil
+	}
+	if !enumClassRE.MatchString(a.Value) {
+		return nil, fmt.Errorf("invalid enum class %q", a.Value)
+	}
+	switch s {
+	case "y", "n", "q", "i", "u", "x", "t":
+	default:
+		return nil, fmt.Errorf("enum class %s requires an integer type, got %q", a.Value, s)
+	}
+	typ, err := dbustype.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	return &EnumDef{Name: a.Value, Signature: s, UnderlyingType: typ.BaseType()}, nil
+}
+
+// flagsClassRE matches the value of the org.chromium.DBus.Argument.FlagsClass annotation,
+// e.g. "ScanOptions(kActive, kPassive, kHidden=0x10)".
+var flagsClassRE = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_]*)\((.*)\)$`)
+
+// flagsDefInternal returns the definition of the flags enum given by the
+// org.chromium.DBus.Argument.FlagsClass annotation a for the D-Bus type s, or nil if
+// a is not the annotation. A value without an explicit "=N" is the bit at its position
+// in the list.
+func flagsDefInternal(s string, a *Annotation) (*FlagsDef, error) {
+	if a == nil || a.Name != "org.chromium.DBus.Argument.FlagsClass" {
+		return nil, nil
+	}
+	m := flagsClassRE.FindStringSubmatch(strings.TrimSpace(a.Value))
+	if m == nil {
+		return nil, fmt.Errorf("invalid flags class %q; want \"Name(kValue1, kValue2=N, ...)\"", a.Value)
+	}
+	var bits int
+	switch s {
+	case "y":
+		bits = 8
+	case "q":
+		bits = 16
+	case "u":
+		bits = 32
+	case "t":
+		bits = 64
+	default:
+		return nil, fmt.Errorf("flags class %s requires an unsigned integer type, got %q", m[1], s)
+	}
+	typ, err := dbustype.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := &FlagsDef{Name: m[1], Signature: s, UnderlyingType: typ.BaseType()}
+	seen := make(map[string]bool)
+	for i, v := range strings.Split(m[2], ",") {
+		kv := strings.SplitN(v, "=", 2)
+		name := strings.TrimSpace(kv[0])
+		if !identifierRE.MatchString(name) {
+			return nil, fmt.Errorf("invalid flags value name %q in %q", name, a.Value)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate flags value %s in %q", name, a.Value)
+		}
+		seen[name] = true
+		var n uint64
+		if len(kv) == 2 {
+			n, err = strconv.ParseUint(strings.TrimSpace(kv[1]), 0, bits)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value of flags value %s in %q: %v", name, a.Value, err)
+			}
+		} else {
+			if i >= bits {
+				return nil, fmt.Errorf("flags value %s in %q does not fit in %q", name, a.Value, s)
+			}
+			n = 1 << uint(i)
+		}
+		ret.Values = append(ret.Values, FlagsValue{Name: name, Value: n})
+	}
+	return ret, nil
+}
+
+// optionalValueTypeInternal returns the D-Bus type of the value of the argument of
+// type s, which the org.chromium.DBus.Argument.Optional annotation a renders as
+// std::optional, or "" if a is not the annotation set to "true".
+// The argument type must be a struct of a presence flag and the value, i.e. "(bT)".
+func optionalValueTypeInternal(s string, a *Annotation) (string, error) {
+	if a == nil || a.Name != "org.chromium.DBus.Argument.Optional" || a.Value != "true" {
+		return "", nil
+	}
+	if !strings.HasPrefix(s, "(b") || !strings.HasSuffix(s, ")") {
+		return "", fmt.Errorf("optional argument requires a type (bT), got %q", s)
+	}
+	v := s[len("(b") : len(s)-len(")")]
+	if _, err := dbustype.Parse(v); err != nil {
+		return "", fmt.Errorf("optional argument requires a type (bT), got %q: %v", s, err)
+	}
+	return v, nil
+}
+
+// structFieldNamesRE matches the value of the org.chromium.DBus.Struct.FieldNames annotation,
+// e.g. "ScanRequest(name, type, count)".
+var structFieldNamesRE = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_]*)\((.*)\)$`)
+
+var identifierRE = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// parseStructFieldNames parses the value of the org.chromium.DBus.Struct.FieldNames
+// annotation into the struct name and the field names.
+func parseStructFieldNames(v string) (string, []string, error) {
+	m := structFieldNamesRE.FindStringSubmatch(strings.TrimSpace(v))
+	if m == nil {
+		return "", nil, fmt.Errorf("invalid struct field names %q; want \"Name(field1, field2, ...)\"", v)
+	}
+	var fields []string
+	for _, f := range strings.Split(m[2], ",") {
+		f = strings.TrimSpace(f)
+		if !identifierRE.MatchString(f) {
+			return "", nil, fmt.Errorf("invalid struct field name %q in %q", f, v)
+		}
+		fields = append(fields, f)
+	}
+	return m[1], fields, nil
+}
+
+// skippedInternal returns true if annotations contain
+// org.chromium.DBus.Skip or, if target is not empty,
+// org.chromium.DBus.Skip<target> set to "true".
+func skippedInternal(annotations []Annotation, target string) bool {
+	for _, a := range annotations {
+		if a.Name == "org.chromium.DBus.Skip" || (target != "" && a.Name == "org.chromium.DBus.Skip"+target) {
+			if a.Value == "true" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// minVersionInternal returns the value of the org.chromium.DBus.MinVersion
+// annotation in annotations, or 0 if there is no such annotation or its value
+// is not a version.
+func minVersionInternal(annotations []Annotation) int {
+	for _, a := range annotations {
+		if a.Name == "org.chromium.DBus.MinVersion" {
+			v, err := strconv.Atoi(a.Value)
+			if err != nil || v < 0 {
+				return 0
+			}
+			return v
+		}
+	}
+	return 0
+}
+
+// structNamer is implemented by the D-Bus type returned by dbustype.Parse.
+type structNamer interface {
+	SetStructName(name string) error
+}
+
+// setStructName makes the struct in typ be rendered as the named struct if a is
+// the org.chromium.DBus.Struct.FieldNames annotation.
+func setStructName(typ structNamer, a *Annotation) error {
+	if a == nil || a.Name != "org.chromium.DBus.Struct.FieldNames" {
+		return nil
+	}
+	name, _, err := parseStructFieldNames(a.Value)
+	if err != nil {
+		return err
+	}
+	return typ.SetStructName(name)
+}
+
+func structDefInternal(s string, a *Annotation) (*StructDef, error) {
+	if a == nil || a.Name != "org.chromium.DBus.Struct.FieldNames" {
+		return nil, nil
+	}
+	name, fields, err := parseStructFieldNames(a.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	typ, err := dbustype.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	types, err := typ.StructMemberTypes()
+	if err != nil {
+		return nil, fmt.Errorf("type %s cannot have struct field names: %v", s, err)
+	}
+	if len(types) != len(fields) {
+		return nil, fmt.Errorf("struct %s has %d fields, but type %s has %d members", name, len(fields), s, len(types))
+	}
+
+	ret := &StructDef{Name: name, Signature: strings.TrimPrefix(s, "a")}
+	for i, f := range fields {
+		ret.Fields = append(ret.Fields, StructField{Name: f, Type: types[i]})
+	}
+	return ret, nil
+}
@@ -46,6 +46,33 @@ func TestOutputArguments(t *testing.T) {
 		t.Errorf("OutputArguments failed (-got +want):\n%s", diff)
 	}
 }
+func TestSignatures(t *testing.T) {
+	m := introspect.Method{
+		Name: "f",
+		Args: []introspect.MethodArg{
+			{Name: "x1", Direction: "in", Type: "i"},
+			{Name: "x2", Direction: "out", Type: "a{sv}"},
+			{Name: "x3", Direction: "", Type: "(ih)"},
+		},
+	}
+	if got, want := m.InputSignature(), "i(ih)"; got != want {
+		t.Errorf("InputSignature failed: got %q, want %q", got, want)
+	}
+	if got, want := m.OutputSignature(), "a{sv}"; got != want {
+		t.Errorf("OutputSignature failed: got %q, want %q", got, want)
+	}
+
+	s := introspect.Signal{
+		Name: "s",
+		Args: []introspect.SignalArg{
+			{Name: "x1", Type: "ay"},
+			{Name: "x2", Type: "u"},
+		},
+	}
+	if got, want := s.Signature(), "ayu"; got != want {
+		t.Errorf("Signature failed: got %q, want %q", got, want)
+	}
+}
 func TestKind(t *testing.T) {
 	cases := []struct {
 		input introspect.Method
@@ -89,6 +116,77 @@ func TestKind(t *testing.T) {
 		}
 	}
 }
+func TestSignalKind(t *testing.T) {
+	cases := []struct {
+		input introspect.Signal
+		want  introspect.SignalKind
+	}{
+		{
+			input: introspect.Signal{
+				Name: "s1",
+				Annotations: []introspect.Annotation{
+					{Name: "org.chromium.DBus.Signal.Kind", Value: "raw"},
+				},
+			},
+			want: introspect.SignalKindRaw,
+		}, {
+			input: introspect.Signal{
+				Name: "s2",
+				Annotations: []introspect.Annotation{
+					{Name: "org.chromium.DBus.Signal.Kind", Value: "normal"},
+				},
+			},
+			want: introspect.SignalKindNormal,
+		}, {
+			input: introspect.Signal{
+				Name: "s3",
+			},
+			want: introspect.SignalKindNormal,
+		},
+	}
+	for _, tc := range cases {
+		got := tc.input.Kind()
+		if got != tc.want {
+			t.Errorf("Kind failed, signal name is %s\n got %v, want %v", tc.input.Name, got, tc.want)
+		}
+	}
+}
+
+func TestVariantTypes(t *testing.T) {
+	cases := []struct {
+		input      introspect.MethodArg
+		wantTypes  []string
+		wantClosed bool
+	}{{
+		input: introspect.MethodArg{
+			Name: "value", Type: "v",
+			Annotations: []introspect.Annotation{{Name: "org.chromium.DBus.Argument.VariantTypes", Value: "s  as"}},
+		},
+		wantTypes:  []string{"s", "as"},
+		wantClosed: true,
+	}, {
+		input: introspect.MethodArg{
+			Name: "value", Type: "v",
+			Annotations: []introspect.Annotation{{Name: "org.chromium.DBus.Argument.VariantTypes", Value: "s i ..."}},
+		},
+		wantTypes:  []string{"s", "i"},
+		wantClosed: false,
+	}, {
+		input: introspect.MethodArg{
+			Name: "value", Type: "v",
+		},
+	}}
+	for _, tc := range cases {
+		types, closed := tc.input.VariantTypes()
+		if diff := cmp.Diff(types, tc.wantTypes); diff != "" {
+			t.Errorf("VariantTypes(%v) types mismatch (-got +want):\n%s", tc.input.Annotations, diff)
+		}
+		if closed != tc.wantClosed {
+			t.Errorf("VariantTypes(%v) closed mismatch: got %t, want %t", tc.input.Annotations, closed, tc.wantClosed)
+		}
+	}
+}
+
 func TestIncludeDBusMessage(t *testing.T) {
 	cases := []struct {
 		input introspect.Method
@@ -124,6 +222,59 @@ func TestIncludeDBusMessage(t *testing.T) {
 		}
 	}
 }
+func TestErrors(t *testing.T) {
+	m := introspect.Method{
+		Name: "f",
+		Annotations: []introspect.Annotation{
+			{Name: "org.chromium.DBus.Method.Errors", Value: " org.chromium.Error.Failed\n  org.chromium.Error.Busy "},
+		},
+	}
+	want := []string{"org.chromium.Error.Failed", "org.chromium.Error.Busy"}
+	if diff := cmp.Diff(m.Errors(), want); diff != "" {
+		t.Errorf("Errors failed (-got +want):\n%s", diff)
+	}
+
+	if got := (&introspect.Method{Name: "g"}).Errors(); got != nil {
+		t.Errorf("Errors failed: got %v, want nil", got)
+	}
+}
+
+func TestReturnsFDStream(t *testing.T) {
+	cases := []struct {
+		input introspect.Method
+		want  bool
+	}{
+		{
+			input: introspect.Method{
+				Name: "f1",
+				Annotations: []introspect.Annotation{
+					{Name: "org.chromium.DBus.Method.ReturnsFDStream", Value: "true"},
+				},
+			},
+			want: true,
+		}, {
+			input: introspect.Method{
+				Name: "f2",
+				Annotations: []introspect.Annotation{
+					{Name: "org.chromium.DBus.Method.ReturnsFDStream", Value: "false"},
+				},
+			},
+			want: false,
+		}, {
+			input: introspect.Method{
+				Name: "f3",
+			},
+			want: false,
+		},
+	}
+	for _, tc := range cases {
+		got := tc.input.ReturnsFDStream()
+		if got != tc.want {
+			t.Errorf("ReturnsFDStream failed, method name is %s\n got %t, want %t", tc.input.Name, got, tc.want)
+		}
+	}
+}
+
 func TestConst(t *testing.T) {
 	cases := []struct {
 		input introspect.Method
@@ -160,6 +311,122 @@ func TestConst(t *testing.T) {
 	}
 }
 
+func TestSkipped(t *testing.T) {
+	skip := introspect.Annotation{Name: "org.chromium.DBus.Skip", Value: "true"}
+	skipProxy := introspect.Annotation{Name: "org.chromium.DBus.SkipProxy", Value: "true"}
+	cases := []struct {
+		annotation introspect.Annotation
+		target     string
+		want       bool
+	}{
+		{annotation: introspect.Annotation{}, target: introspect.SkipTargetProxy, want: false},
+		{annotation: skip, target: "", want: true},
+		{annotation: skip, target: introspect.SkipTargetAdaptor, want: true},
+		{annotation: skipProxy, target: introspect.SkipTargetProxy, want: true},
+		{annotation: skipProxy, target: introspect.SkipTargetAdaptor, want: false},
+		{annotation: skipProxy, target: "", want: false},
+		{annotation: introspect.Annotation{Name: "org.chromium.DBus.Skip", Value: "false"}, target: "", want: false},
+	}
+	for _, tc := range cases {
+		m := introspect.Method{Name: "f", Annotations: []introspect.Annotation{tc.annotation}}
+		if got := m.Skipped(tc.target); got != tc.want {
+			t.Errorf("Method.Skipped(%q) with %v got %t, want %t", tc.target, tc.annotation, got, tc.want)
+		}
+		s := introspect.Signal{Name: "s", Annotations: []introspect.Annotation{tc.annotation}}
+		if got := s.Skipped(tc.target); got != tc.want {
+			t.Errorf("Signal.Skipped(%q) with %v got %t, want %t", tc.target, tc.annotation, got, tc.want)
+		}
+		p := introspect.Property{Name: "p", Type: "i", Annotations: []introspect.Annotation{tc.annotation}}
+		if got := p.Skipped(tc.target); got != tc.want {
+			t.Errorf("Property.Skipped(%q) with %v got %t, want %t", tc.target, tc.annotation, got, tc.want)
+		}
+	}
+}
+
+func TestMinVersion(t *testing.T) {
+	cases := []struct {
+		annotation introspect.Annotation
+		want       int
+	}{
+		{annotation: introspect.Annotation{}, want: 0},
+		{annotation: introspect.Annotation{Name: "org.chromium.DBus.MinVersion", Value: "3"}, want: 3},
+		{annotation: introspect.Annotation{Name: "org.chromium.DBus.MinVersion", Value: "three"}, want: 0},
+	}
+	for _, tc := range cases {
+		m := introspect.Method{Name: "f", Annotations: []introspect.Annotation{tc.annotation}}
+		if got := m.MinVersion(); got != tc.want {
+			t.Errorf("Method.MinVersion() with %v got %d, want %d", tc.annotation, got, tc.want)
+		}
+		s := introspect.Signal{Name: "s", Annotations: []introspect.Annotation{tc.annotation}}
+		if got := s.MinVersion(); got != tc.want {
+			t.Errorf("Signal.MinVersion() with %v got %d, want %d", tc.annotation, got, tc.want)
+		}
+		p := introspect.Property{Name: "p", Type: "i", Annotations: []introspect.Annotation{tc.annotation}}
+		if got := p.MinVersion(); got != tc.want {
+			t.Errorf("Property.MinVersion() with %v got %d, want %d", tc.annotation, got, tc.want)
+		}
+	}
+}
+
+func TestProtobufIncludes(t *testing.T) {
+	itf := introspect.Interface{
+		Name: "itf",
+		Methods: []introspect.Method{
+			{
+				Name: "f",
+				Args: []introspect.MethodArg{{Name: "a", Type: "i"}},
+			},
+		},
+		Annotations: []introspect.Annotation{
+			{Name: "org.chromium.DBus.Interface.ProtobufIncludes", Value: "a/b.pb.h,\n  <c/d.pb.h> e.pb.h"},
+		},
+	}
+	want := []string{"a/b.pb.h", "<c/d.pb.h>", "e.pb.h"}
+	if diff := cmp.Diff(itf.ProtobufIncludes(), want); diff != "" {
+		t.Errorf("ProtobufIncludes failed (-got +want):\n%s", diff)
+	}
+
+	if itf.UsesProtobuf() {
+		t.Error("UsesProtobuf unexpectedly true")
+	}
+	itf.Signals = []introspect.Signal{{
+		Name: "s",
+		Args: []introspect.SignalArg{{
+			Type:        "ay",
+			Annotations: []introspect.Annotation{{Name: "org.chromium.DBus.Argument.ProtobufClass", Value: "Proto"}},
+		}},
+	}}
+	if !itf.UsesProtobuf() {
+		t.Error("UsesProtobuf unexpectedly false")
+	}
+}
+
+func TestLightweightProperties(t *testing.T) {
+	itf := introspect.Interface{Name: "itf"}
+	if itf.LightweightProperties() {
+		t.Error("LightweightProperties unexpectedly true")
+	}
+	itf.Annotations = []introspect.Annotation{
+		{Name: "org.chromium.DBus.Interface.LightweightProperties", Value: "true"},
+	}
+	if !itf.LightweightProperties() {
+		t.Error("LightweightProperties unexpectedly false")
+	}
+}
+
+func TestBatchPropertyChanges(t *testing.T) {
+	itf := introspect.Interface{Name: "itf"}
+	if itf.BatchPropertyChanges() {
+		t.Error("BatchPropertyChanges unexpectedly true")
+	}
+	itf.Annotations = []introspect.Annotation{
+		{Name: "org.chromium.DBus.Interface.BatchPropertyChanges", Value: "true"},
+	}
+	if !itf.BatchPropertyChanges() {
+		t.Error("BatchPropertyChanges unexpectedly false")
+	}
+}
+
 func TestMethodArgMethods(t *testing.T) {
 	cases := []struct {
 		receiver   introspect.MethodArg
@@ -171,14 +438,26 @@ func TestMethodArgMethods(t *testing.T) {
 			receiver: introspect.MethodArg{
 				Name: "arg1",
 				Type: "ay",
-				Annotation: introspect.Annotation{
+				Annotations: []introspect.Annotation{{
 					Name:  "org.chromium.DBus.Argument.ProtobufClass",
 					Value: "MyProtobufClass",
-				},
+				}},
 			},
 			BaseType:   "MyProtobufClass",
 			InArgType:  "const MyProtobufClass&",
 			OutArgType: "MyProtobufClass*",
+		}, {
+			receiver: introspect.MethodArg{
+				Name: "ip_addresses",
+				Type: "au",
+				Annotations: []introspect.Annotation{{
+					Name:  "org.chromium.DBus.Argument.CppType",
+					Value: "std::vector<net_base::IPAddress>",
+				}},
+			},
+			BaseType:   "std::vector<net_base::IPAddress>",
+			InArgType:  "const std::vector<net_base::IPAddress>&",
+			OutArgType: "std::vector<net_base::IPAddress>*",
 		}, {
 			receiver: introspect.MethodArg{
 				Name: "arg2",
@@ -187,6 +466,43 @@ func TestMethodArgMethods(t *testing.T) {
 			BaseType:   "base::ScopedFD",
 			InArgType:  "const base::ScopedFD&",
 			OutArgType: "base::ScopedFD*",
+		}, {
+			receiver: introspect.MethodArg{
+				Name: "arg5",
+				Type: "a(ssu)",
+				Annotations: []introspect.Annotation{{
+					Name:  "org.chromium.DBus.Struct.FieldNames",
+					Value: "ScanRequest(name, type, count)",
+				}},
+			},
+			BaseType:   "std::vector<ScanRequest>",
+			InArgType:  "const std::vector<ScanRequest>&",
+			OutArgType: "std::vector<ScanRequest>*",
+		}, {
+			receiver: introspect.MethodArg{
+				Name: "arg6",
+				Type: "u",
+				Annotations: []introspect.Annotation{{
+					Name:  "org.chromium.DBus.Argument.EnumClass",
+					Value: "my::Mode",
+				}},
+			},
+			BaseType:   "my::Mode",
+			InArgType:  "my::Mode",
+			OutArgType: "my::Mode*",
+		}, {
+			receiver: introspect.MethodArg{
+				Name:      "arg7",
+				Type:      "(bas)",
+				Direction: "out",
+				Annotations: []introspect.Annotation{{
+					Name:  "org.chromium.DBus.Argument.Optional",
+					Value: "true",
+				}},
+			},
+			BaseType:   "std::optional<std::vector<std::string>>",
+			InArgType:  "const std::optional<std::vector<std::string>>&",
+			OutArgType: "std::optional<std::vector<std::string>>*",
 		},
 	}
 
@@ -226,10 +542,10 @@ func TestSignalArgMethods(t *testing.T) {
 			receiver: introspect.SignalArg{
 				Name: "arg3",
 				Type: "ay",
-				Annotation: introspect.Annotation{
+				Annotations: []introspect.Annotation{{
 					Name:  "org.chromium.DBus.Argument.ProtobufClass",
 					Value: "MyProtobufClass",
-				},
+				}},
 			},
 			BaseType:   "MyProtobufClass",
 			InArgType:  "const MyProtobufClass&",
@@ -270,6 +586,141 @@ func TestSignalArgMethods(t *testing.T) {
 	}
 }
 
+func TestStructDef(t *testing.T) {
+	cases := []struct {
+		receiver introspect.MethodArg
+		want     *introspect.StructDef
+	}{
+		{
+			receiver: introspect.MethodArg{Name: "arg1", Type: "(ssu)"},
+			want:     nil,
+		}, {
+			receiver: introspect.MethodArg{
+				Name: "arg2",
+				Type: "(sa{sv})",
+				Annotations: []introspect.Annotation{{
+					Name:  "org.chromium.DBus.Struct.FieldNames",
+					Value: "Entry(key, properties)",
+				}},
+			},
+			want: &introspect.StructDef{
+				Name:      "Entry",
+				Signature: "(sa{sv})",
+				Fields: []introspect.StructField{
+					{Name: "key", Type: "std::string"},
+					{Name: "properties", Type: "brillo::VariantDictionary"},
+				},
+			},
+		}, {
+			receiver: introspect.MethodArg{
+				Name: "arg3",
+				Type: "a(ib)",
+				Annotations: []introspect.Annotation{{
+					Name:  "org.chromium.DBus.Struct.FieldNames",
+					Value: " Flag( id,enabled ) ",
+				}},
+			},
+			want: &introspect.StructDef{
+				Name:      "Flag",
+				Signature: "(ib)",
+				Fields: []introspect.StructField{
+					{Name: "id", Type: "int32_t"},
+					{Name: "enabled", Type: "bool"},
+				},
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		got, err := tc.receiver.StructDef()
+		if err != nil {
+			t.Fatalf("Failed to get the struct definition of %q: %v", tc.receiver.Name, err)
+		}
+		if diff := cmp.Diff(got, tc.want); diff != "" {
+			t.Errorf("Unexpected struct definition of %q (-got +want):\n%s", tc.receiver.Name, diff)
+		}
+	}
+}
+
+func TestEnumDef(t *testing.T) {
+	a := introspect.SignalArg{
+		Name:        "mode",
+		Type:        "q",
+		Annotations: []introspect.Annotation{{Name: "org.chromium.DBus.Argument.EnumClass", Value: "my::Mode"}},
+	}
+	got, err := a.EnumDef()
+	if err != nil {
+		t.Fatalf("EnumDef got error, want nil: %v", err)
+	}
+	want := &introspect.EnumDef{Name: "my::Mode", Signature: "q", UnderlyingType: "uint16_t"}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("EnumDef failed (-got +want):\n%s", diff)
+	}
+
+	if got, err := (&introspect.SignalArg{Name: "n", Type: "q"}).EnumDef(); got != nil || err != nil {
+		t.Errorf("EnumDef got (%v, %v), want (nil, nil)", got, err)
+	}
+}
+
+func TestFlagsDef(t *testing.T) {
+	a := introspect.MethodArg{
+		Name:        "options",
+		Type:        "u",
+		Annotations: []introspect.Annotation{{Name: "org.chromium.DBus.Argument.FlagsClass", Value: "ScanOptions(kActive, kPassive, kNone=0, kHidden=0x10)"}},
+	}
+	got, err := a.FlagsDef()
+	if err != nil {
+		t.Fatalf("FlagsDef got error, want nil: %v", err)
+	}
+	want := &introspect.FlagsDef{
+		Name:           "ScanOptions",
+		Signature:      "u",
+		UnderlyingType: "uint32_t",
+		Values: []introspect.FlagsValue{
+			{Name: "kActive", Value: 1},
+			{Name: "kPassive", Value: 2},
+			{Name: "kNone", Value: 0},
+			{Name: "kHidden", Value: 16},
+		},
+	}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("FlagsDef failed (-got +want):\n%s", diff)
+	}
+
+	typ, err := a.InArgType()
+	if err != nil {
+		t.Fatalf("InArgType got error, want nil: %v", err)
+	}
+	if typ != "ScanOptions" {
+		t.Errorf("InArgType got %q, want %q", typ, "ScanOptions")
+	}
+	typ, err = a.OutArgType()
+	if err != nil {
+		t.Fatalf("OutArgType got error, want nil: %v", err)
+	}
+	if typ != "ScanOptions*" {
+		t.Errorf("OutArgType got %q, want %q", typ, "ScanOptions*")
+	}
+
+	if got, err := (&introspect.SignalArg{Name: "n", Type: "u"}).FlagsDef(); got != nil || err != nil {
+		t.Errorf("FlagsDef got (%v, %v), want (nil, nil)", got, err)
+	}
+}
+
+func TestStructDefFailures(t *testing.T) {
+	cases := []introspect.SignalArg{
+		{Type: "s", Annotations: []introspect.Annotation{{Name: "org.chromium.DBus.Struct.FieldNames", Value: "A(x)"}}},
+		{Type: "(ss)", Annotations: []introspect.Annotation{{Name: "org.chromium.DBus.Struct.FieldNames", Value: "A(x)"}}},
+		{Type: "(ss)", Annotations: []introspect.Annotation{{Name: "org.chromium.DBus.Struct.FieldNames", Value: "x, y"}}},
+		{Type: "(ss)", Annotations: []introspect.Annotation{{Name: "org.chromium.DBus.Struct.FieldNames", Value: "A(x, 1y)"}}},
+	}
+	for _, tc := range cases {
+		if _, err := tc.StructDef(); err == nil {
+			t.Errorf("StructDef of type %q with %q unexpectedly succeeded", tc.Type, tc.Annotations[0].Value)
+		}
+	}
+}
+
 func TestPropertyMethods(t *testing.T) {
 	cases := []struct {
 		receiver        introspect.Property
@@ -291,10 +742,10 @@ func TestPropertyMethods(t *testing.T) {
 			receiver: introspect.Property{
 				Name: "property1",
 				Type: "h",
-				Annotation: introspect.Annotation{
+				Annotations: []introspect.Annotation{{
 					Name:  "org.chromium.DBus.Argument.VariableName",
 					Value: "property1_var",
-				},
+				}},
 			},
 			BaseType:        "base::ScopedFD",
 			InArgType:       "const base::ScopedFD&",
@@ -332,3 +783,89 @@ func TestPropertyMethods(t *testing.T) {
 		}
 	}
 }
+
+func TestPropertyCachePolicy(t *testing.T) {
+	cases := []struct {
+		annotation introspect.Annotation
+		want       introspect.PropertyCachePolicy
+	}{
+		{want: introspect.PropertyCachePolicyCached},
+		{
+			annotation: introspect.Annotation{Name: "org.chromium.DBus.Property.CachePolicy", Value: "cached"},
+			want:       introspect.PropertyCachePolicyCached,
+		}, {
+			annotation: introspect.Annotation{Name: "org.chromium.DBus.Property.CachePolicy", Value: "fetch_once"},
+			want:       introspect.PropertyCachePolicyFetchOnce,
+		}, {
+			annotation: introspect.Annotation{Name: "org.chromium.DBus.Property.CachePolicy", Value: "always"},
+			want:       introspect.PropertyCachePolicyAlways,
+		},
+	}
+	for _, tc := range cases {
+		p := introspect.Property{Name: "Count", Type: "i", Annotations: []introspect.Annotation{tc.annotation}}
+		if got := p.CachePolicy(); got != tc.want {
+			t.Errorf("CachePolicy with %q got %v, want %v", tc.annotation.Value, got, tc.want)
+		}
+	}
+}
+
+func TestPropertyEmitsChangedSignal(t *testing.T) {
+	cases := []struct {
+		annotation introspect.Annotation
+		want       introspect.PropertyEmitsChangedSignal
+	}{
+		{want: introspect.PropertyEmitsChangedSignalTrue},
+		{
+			annotation: introspect.Annotation{Name: "org.freedesktop.DBus.Property.EmitsChangedSignal", Value: "true"},
+			want:       introspect.PropertyEmitsChangedSignalTrue,
+		}, {
+			annotation: introspect.Annotation{Name: "org.freedesktop.DBus.Property.EmitsChangedSignal", Value: "invalidates"},
+			want:       introspect.PropertyEmitsChangedSignalInvalidates,
+		}, {
+			annotation: introspect.Annotation{Name: "org.freedesktop.DBus.Property.EmitsChangedSignal", Value: "const"},
+			want:       introspect.PropertyEmitsChangedSignalConst,
+		}, {
+			annotation: introspect.Annotation{Name: "org.freedesktop.DBus.Property.EmitsChangedSignal", Value: "false"},
+			want:       introspect.PropertyEmitsChangedSignalFalse,
+		},
+	}
+	for _, tc := range cases {
+		p := introspect.Property{Name: "Count", Type: "i", Annotations: []introspect.Annotation{tc.annotation}}
+		if got := p.EmitsChangedSignal(); got != tc.want {
+			t.Errorf("EmitsChangedSignal with %q got %v, want %v", tc.annotation.Value, got, tc.want)
+		}
+	}
+}
+
+func TestPropertyAnnotations(t *testing.T) {
+	const content = `<node>
+  <interface name="org.chromium.Test">
+    <property name="Count" type="i" access="read">
+      <annotation name="org.chromium.DBus.SkipProxy" value="true"/>
+      <annotation name="org.chromium.DBus.MinVersion" value="5"/>
+      <annotation name="org.freedesktop.DBus.Property.EmitsChangedSignal" value="invalidates"/>
+      <annotation name="org.chromium.DBus.Property.CachePolicy" value="always"/>
+    </property>
+  </interface>
+</node>`
+	is, err := introspect.Parse([]byte(content))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	p := is.Interfaces[0].Properties[0]
+	if len(p.Annotations) != 4 {
+		t.Fatalf("Parse got %d property annotations, want 4: %v", len(p.Annotations), p.Annotations)
+	}
+	if !p.Skipped(introspect.SkipTargetProxy) {
+		t.Error("Skipped(Proxy) got false, want true")
+	}
+	if got := p.MinVersion(); got != 5 {
+		t.Errorf("MinVersion got %d, want 5", got)
+	}
+	if got := p.EmitsChangedSignal(); got != introspect.PropertyEmitsChangedSignalInvalidates {
+		t.Errorf("EmitsChangedSignal got %v, want %v", got, introspect.PropertyEmitsChangedSignalInvalidates)
+	}
+	if got := p.CachePolicy(); got != introspect.PropertyCachePolicyAlways {
+		t.Errorf("CachePolicy got %v, want %v", got, introspect.PropertyCachePolicyAlways)
+	}
+}
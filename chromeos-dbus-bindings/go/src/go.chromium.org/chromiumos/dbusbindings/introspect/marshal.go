@@ -0,0 +1,201 @@
+// Copyright 2022 The ChromiumOS Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package introspect
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+const (
+	introspectDocType = `<!DOCTYPE node PUBLIC "-//freedesktop//DTD D-BUS Object Introspection 1.0//EN"
+ "http://www.freedesktop.org/standards/dbus/1.0/introspect.dtd">
+`
+	telepathyNamespace = "http://telepathy.freedesktop.org/wiki/DbusSpec#extensions-v0"
+)
+
+// Marshal converts the introspection structure to the D-Bus introspection XML.
+// The output is canonical: elements without children are self-closed, empty
+// attributes and annotations are omitted, and doc strings are trimmed and
+// written as tp:docstring elements. Parse(Marshal(i)) reproduces i except for
+// the white spaces around doc strings.
+func Marshal(i Introspection) ([]byte, error) {
+	if err := verifyIntrospection(&i); err != nil {
+		return nil, err
+	}
+
+	w := &xmlWriter{}
+	w.buf.WriteString(introspectDocType)
+	nodeAttrs := []string{"name", i.Name}
+	if hasDocString(&i) {
+		nodeAttrs = append(nodeAttrs, "xmlns:tp", telepathyNamespace)
+	}
+	w.node(0, &i, nodeAttrs...)
+	return w.buf.Bytes(), nil
+}
+
+// node writes the node i with attrs, and its child nodes, at depth. Child
+// nodes without children are self-closed.
+func (w *xmlWriter) node(depth int, i *Introspection, attrs ...string) {
+	if depth > 0 && len(i.Includes) == 0 && len(i.Interfaces) == 0 && len(i.Nodes) == 0 {
+		w.empty(depth, "node", attrs...)
+		return
+	}
+	w.open(depth, "node", attrs...)
+	for _, inc := range i.Includes {
+		w.empty(depth+1, "include", "href", inc.Href)
+	}
+	for _, itf := range i.Interfaces {
+		w.open(depth+1, "interface", "name", itf.Name)
+		for _, m := range itf.Methods {
+			if len(m.Args) == 0 && len(m.Annotations) == 0 && m.DocString == "" {
+				w.empty(depth+2, "method", "name", m.Name)
+				continue
+			}
+			w.open(depth+2, "method", "name", m.Name)
+			for _, a := range m.Args {
+				w.element(depth+3, "arg", a.Annotations, "name", a.Name, "type", string(a.Type), "direction", a.Direction)
+			}
+			for _, a := range m.Annotations {
+				w.empty(depth+3, "annotation", "name", a.Name, "value", a.Value)
+			}
+			w.docString(depth+3, m.DocString)
+			w.close(depth+2, "method")
+		}
+		for _, s := range itf.Signals {
+			if len(s.Args) == 0 && s.DocString == "" {
+				w.empty(depth+2, "signal", "name", s.Name)
+				continue
+			}
+			w.open(depth+2, "signal", "name", s.Name)
+			for _, a := range s.Args {
+				w.element(depth+3, "arg", a.Annotations, "name", a.Name, "type", a.Type)
+			}
+			w.docString(depth+3, s.DocString)
+			w.close(depth+2, "signal")
+		}
+		for _, p := range itf.Properties {
+			attrs := []string{"name", p.Name, "type", p.Type, "access", p.Access}
+			if len(p.Annotations) == 0 && p.DocString == "" {
+				w.empty(depth+2, "property", attrs...)
+				continue
+			}
+			w.open(depth+2, "property", attrs...)
+			for _, a := range p.Annotations {
+				w.empty(depth+3, "annotation", "name", a.Name, "value", a.Value)
+			}
+			w.docString(depth+3, p.DocString)
+			w.close(depth+2, "property")
+		}
+		for _, a := range itf.Annotations {
+			w.empty(depth+2, "annotation", "name", a.Name, "value", a.Value)
+		}
+		w.docString(depth+2, itf.DocString)
+		w.close(depth+1, "interface")
+	}
+	for _, n := range i.Nodes {
+		w.node(depth+1, &n, "name", n.Name)
+	}
+	w.close(depth, "node")
+}
+
+// hasDocString returns true if any element of i has a doc string, in which
+// case the tp namespace needs to be declared.
+func hasDocString(i *Introspection) bool {
+	for _, n := range i.Nodes {
+		if hasDocString(&n) {
+			return true
+		}
+	}
+	for _, itf := range i.Interfaces {
+		if strings.TrimSpace(string(itf.DocString)) != "" {
+			return true
+		}
+		for _, m := range itf.Methods {
+			if strings.TrimSpace(string(m.DocString)) != "" {
+				return true
+			}
+		}
+		for _, s := range itf.Signals {
+			if strings.TrimSpace(string(s.DocString)) != "" {
+				return true
+			}
+		}
+		for _, p := range itf.Properties {
+			if strings.TrimSpace(string(p.DocString)) != "" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// xmlWriter writes indented XML elements into buf.
+type xmlWriter struct {
+	buf bytes.Buffer
+}
+
+// startTag writes the start of a tag with attrs given as name-value pairs.
+// Attributes with empty values are omitted.
+func (w *xmlWriter) startTag(depth int, name string, attrs ...string) {
+	w.buf.WriteString(strings.Repeat("  ", depth))
+	w.buf.WriteString("<" + name)
+	for i := 0; i+1 < len(attrs); i += 2 {
+		if attrs[i+1] == "" {
+			continue
+		}
+		fmt.Fprintf(&w.buf, " %s=\"%s\"", attrs[i], escapeXML(attrs[i+1]))
+	}
+}
+
+func (w *xmlWriter) open(depth int, name string, attrs ...string) {
+	w.startTag(depth, name, attrs...)
+	w.buf.WriteString(">\n")
+}
+
+func (w *xmlWriter) empty(depth int, name string, attrs ...string) {
+	w.startTag(depth, name, attrs...)
+	w.buf.WriteString("/>\n")
+}
+
+func (w *xmlWriter) close(depth int, name string) {
+	fmt.Fprintf(&w.buf, "%s</%s>\n", strings.Repeat("  ", depth), name)
+}
+
+// element writes an element which has annotations as its children.
+func (w *xmlWriter) element(depth int, name string, annotations []Annotation, attrs ...string) {
+	if len(annotations) == 0 {
+		w.empty(depth, name, attrs...)
+		return
+	}
+	w.open(depth, name, attrs...)
+	for _, a := range annotations {
+		w.empty(depth+1, "annotation", "name", a.Name, "value", a.Value)
+	}
+	w.close(depth, name)
+}
+
+func (w *xmlWriter) docString(depth int, s DocString) {
+	text := strings.TrimSpace(string(s))
+	if text == "" {
+		return
+	}
+	fmt.Fprintf(&w.buf, "%s<tp:docstring>%s</tp:docstring>\n", strings.Repeat("  ", depth), textEscaper.Replace(text))
+}
+
+// textEscaper escapes the character data of an element. Unlike
+// xml.EscapeText, it keeps new lines so that multi-line doc strings stay
+// readable.
+var textEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// escapeXML escapes s to be used as an attribute value.
+func escapeXML(s string) string {
+	var b strings.Builder
+	// EscapeText never fails when writing into a strings.Builder.
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
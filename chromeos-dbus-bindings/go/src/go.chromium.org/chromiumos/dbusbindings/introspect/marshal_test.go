@@ -0,0 +1,119 @@
+// Copyright 2022 The ChromiumOS Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package introspect_test
+
+import (
+	"testing"
+
+	"go.chromium.org/chromiumos/dbusbindings/introspect"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMarshal(t *testing.T) {
+	in := introspect.Introspection{
+		Name: "/org/chromium/Test",
+		Interfaces: []introspect.Interface{
+			{
+				Name: "org.chromium.Test",
+				Methods: []introspect.Method{
+					{
+						Name: "Scan",
+						Args: []introspect.MethodArg{
+							{Name: "options", Type: "a{sv}", Direction: "in"},
+							{
+								Name: "result", Type: "ay", Direction: "out",
+								Annotations: []introspect.Annotation{{
+									Name:  "org.chromium.DBus.Argument.ProtobufClass",
+									Value: "ScanResult",
+								}},
+							},
+						},
+						Annotations: []introspect.Annotation{
+							{Name: "org.chromium.DBus.Method.Kind", Value: "async"},
+						},
+						DocString: "Scans for <devices> & networks.",
+					}, {
+						Name: "Stop",
+					},
+				},
+				Signals: []introspect.Signal{
+					{
+						Name: "ScanDone",
+						Args: []introspect.SignalArg{{Name: "count", Type: "i"}},
+					},
+				},
+				Properties: []introspect.Property{
+					{Name: "Scanning", Type: "b", Access: "read"},
+				},
+				Annotations: []introspect.Annotation{
+					{Name: "org.chromium.DBus.Interface.ProtobufIncludes", Value: "test/proto.pb.h"},
+				},
+			},
+		},
+		Includes: []introspect.Include{{Href: "common.xml"}},
+		Nodes: []introspect.Introspection{
+			{
+				Name:       "child",
+				Interfaces: []introspect.Interface{{Name: "org.chromium.Child"}},
+				Nodes:      []introspect.Introspection{{Name: "grandchild"}},
+			},
+		},
+	}
+
+	got, err := introspect.Marshal(in)
+	if err != nil {
+		t.Fatalf("Marshal got error, want nil: %v", err)
+	}
+
+	const want = `<!DOCTYPE node PUBLIC "-//freedesktop//DTD D-BUS Object Introspection 1.0//EN"
+ "http://www.freedesktop.org/standards/dbus/1.0/introspect.dtd">
+<node name="/org/chromium/Test" xmlns:tp="http://telepathy.freedesktop.org/wiki/DbusSpec#extensions-v0">
+  <include href="common.xml"/>
+  <interface name="org.chromium.Test">
+    <method name="Scan">
+      <arg name="options" type="a{sv}" direction="in"/>
+      <arg name="result" type="ay" direction="out">
+        <annotation name="org.chromium.DBus.Argument.ProtobufClass" value="ScanResult"/>
+      </arg>
+      <annotation name="org.chromium.DBus.Method.Kind" value="async"/>
+      <tp:docstring>Scans for &lt;devices&gt; &amp; networks.</tp:docstring>
+    </method>
+    <method name="Stop"/>
+    <signal name="ScanDone">
+      <arg name="count" type="i"/>
+    </signal>
+    <property name="Scanning" type="b" access="read"/>
+    <annotation name="org.chromium.DBus.Interface.ProtobufIncludes" value="test/proto.pb.h"/>
+  </interface>
+  <node name="child">
+    <interface name="org.chromium.Child">
+    </interface>
+    <node name="grandchild"/>
+  </node>
+</node>
+`
+	if diff := cmp.Diff(string(got), want); diff != "" {
+		t.Errorf("Marshal failed (-got +want):\n%s", diff)
+	}
+
+	// The output must be parsed back to the same structure.
+	parsed, err := introspect.Parse(got)
+	if err != nil {
+		t.Fatalf("Parse got error, want nil: %v", err)
+	}
+	if diff := cmp.Diff(parsed, in); diff != "" {
+		t.Errorf("Parse(Marshal()) failed (-got +want):\n%s", diff)
+	}
+}
+
+func TestMarshalInvalid(t *testing.T) {
+	in := introspect.Introspection{
+		Interfaces: []introspect.Interface{{Name: ""}},
+	}
+	if _, err := introspect.Marshal(in); err == nil {
+		t.Error("Marshal got nil error, want non-nil")
+	}
+}
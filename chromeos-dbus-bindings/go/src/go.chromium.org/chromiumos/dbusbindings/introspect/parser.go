@@ -6,9 +6,13 @@ package introspect
 
 import (
 	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
 )
 
 // Parse converts introspection from the XML to a structure.
+// Includes are left unresolved; use ParseFile to merge them.
 func Parse(content []byte) (Introspection, error) {
 	var i Introspection
 	if err := xml.Unmarshal(content, &i); err != nil {
@@ -19,3 +23,80 @@ func Parse(content []byte) (Introspection, error) {
 	}
 	return i, nil
 }
+
+// ParseFile reads the XML file at path and converts it to a structure.
+// Interfaces of the files referenced by <include href="..."/> are appended
+// to the result, recursively. Relative hrefs are resolved against the directory
+// of the including file. An interface already defined by the including file
+// takes precedence over an included one with the same name.
+func ParseFile(path string) (Introspection, error) {
+	i, _, err := ParseFileWithIncludes(path)
+	return i, err
+}
+
+// ParseFileWithIncludes is ParseFile which also returns the paths of the files
+// read, i.e. path followed by the files it includes, recursively, each once.
+func ParseFileWithIncludes(path string) (Introspection, []string, error) {
+	var files []string
+	i, err := parseFile(path, make(map[string]bool), &files)
+	if err != nil {
+		return Introspection{}, nil, err
+	}
+	return i, files, nil
+}
+
+// parseFile implements ParseFile. visiting holds the absolute paths of the
+// files being parsed up the include chain, to detect include cycles. The
+// paths of the files read are appended to files.
+func parseFile(path string, visiting map[string]bool, files *[]string) (Introspection, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return Introspection{}, err
+	}
+	if visiting[abs] {
+		return Introspection{}, fmt.Errorf("include cycle detected at %s", path)
+	}
+	visiting[abs] = true
+	defer delete(visiting, abs)
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Introspection{}, err
+	}
+	seen := false
+	for _, f := range *files {
+		if f == path {
+			seen = true
+		}
+	}
+	if !seen {
+		*files = append(*files, path)
+	}
+	i, err := Parse(b)
+	if err != nil {
+		return Introspection{}, fmt.Errorf("%s: %v", path, err)
+	}
+
+	defined := make(map[string]bool)
+	for _, itf := range i.Interfaces {
+		defined[itf.Name] = true
+	}
+	for _, inc := range i.Includes {
+		incPath := inc.Href
+		if !filepath.IsAbs(incPath) {
+			incPath = filepath.Join(filepath.Dir(path), incPath)
+		}
+		included, err := parseFile(incPath, visiting, files)
+		if err != nil {
+			return Introspection{}, err
+		}
+		for _, itf := range included.Interfaces {
+			if defined[itf.Name] {
+				continue
+			}
+			defined[itf.Name] = true
+			i.Interfaces = append(i.Interfaces, itf)
+		}
+	}
+	return i, nil
+}
@@ -5,6 +5,10 @@
 package introspect_test
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"go.chromium.org/chromiumos/dbusbindings/introspect"
@@ -40,7 +44,7 @@ const (
         <annotation name="org.chromium.DBus.Argument.ProtobufClass" value="YetAnotherProto" />
       </arg>
       <tp:docstring>
-        doc2
+        doc2 calls <tp:member-ref>Scan</tp:member-ref>
       </tp:docstring>
     </signal>
     <property name="Capabilities" type="a{sv}" access="read">
@@ -48,6 +52,7 @@ const (
         doc3
       </tp:docstring>
     </property>
+    <annotation name="org.chromium.DBus.Interface.ProtobufIncludes" value="wpa/proto.pb.h" />
     <tp:docstring>
       doc4
     </tp:docstring>
@@ -69,6 +74,74 @@ func TestUngrammaticalXMLContents(t *testing.T) {
 	}
 }
 
+func TestMultipleArgAnnotations(t *testing.T) {
+	const content = `<node><interface name="org.chromium.Test"><method name="Set">
+  <arg name="request" type="ay" direction="in">
+    <annotation name="org.chromium.DBus.Argument.ProtobufClass" value="test::Request"/>
+    <annotation name="org.chromium.DBus.Argument.PassByMove" value="true"/>
+  </arg>
+  <arg name="values" type="ai" direction="in">
+    <annotation name="org.chromium.DBus.Argument.PassByMove" value="true"/>
+    <annotation name="org.chromium.DBus.Argument.DefaultValue" value="{}"/>
+  </arg>
+</method></interface></node>`
+	got, err := introspect.Parse([]byte(content))
+	if err != nil {
+		t.Fatalf("Parse got error, want nil: %v", err)
+	}
+	want := []introspect.MethodArg{{
+		Name: "request", Type: "ay", Direction: "in",
+		Annotations: []introspect.Annotation{
+			{Name: "org.chromium.DBus.Argument.ProtobufClass", Value: "test::Request"},
+			{Name: "org.chromium.DBus.Argument.PassByMove", Value: "true"},
+		},
+	}, {
+		Name: "values", Type: "ai", Direction: "in",
+		Annotations: []introspect.Annotation{
+			{Name: "org.chromium.DBus.Argument.PassByMove", Value: "true"},
+			{Name: "org.chromium.DBus.Argument.DefaultValue", Value: "{}"},
+		},
+	}}
+	args := got.Interfaces[0].Methods[0].Args
+	if diff := cmp.Diff(args, want); diff != "" {
+		t.Errorf("Parse args mismatch (-got +want):\n%s", diff)
+	}
+	if typ, err := args[0].InArgType(); err != nil || typ != "const test::Request&" || !args[0].PassByMove() {
+		t.Errorf("request argument: got InArgType (%q, %v) and PassByMove %t, want \"const test::Request&\" and true", typ, err, args[0].PassByMove())
+	}
+	if !args[1].PassByMove() || args[1].DefaultValue() != "{}" {
+		t.Errorf("values argument: got PassByMove %t and DefaultValue %q, want true and \"{}\"", args[1].PassByMove(), args[1].DefaultValue())
+	}
+}
+
+func TestDuplicateArgAnnotations(t *testing.T) {
+	cases := []struct {
+		content, want string
+	}{{
+		content: `<node><interface name="org.chromium.Test"><method name="Set">
+  <arg name="values" type="ai" direction="in">
+    <annotation name="org.chromium.DBus.Argument.DefaultValue" value="{}"/>
+    <annotation name="org.chromium.DBus.Argument.PassByMove" value="true"/>
+    <annotation name="org.chromium.DBus.Argument.DefaultValue" value="{1}"/>
+  </arg>
+</method></interface></node>`,
+		want: "values argument: duplicate annotation org.chromium.DBus.Argument.DefaultValue",
+	}, {
+		content: `<node><interface name="org.chromium.Test"><signal name="Changed">
+  <arg name="value" type="i">
+    <annotation name="org.chromium.DBus.Argument.EnumClass" value="Level"/>
+    <annotation name="org.chromium.DBus.Argument.EnumClass" value="Mode"/>
+  </arg>
+</signal></interface></node>`,
+		want: "value argument: duplicate annotation org.chromium.DBus.Argument.EnumClass",
+	}}
+	for _, tc := range cases {
+		if _, err := introspect.Parse([]byte(tc.content)); err == nil || err.Error() != tc.want {
+			t.Errorf("Parse got error %v, want %q", err, tc.want)
+		}
+	}
+}
+
 func TestGoodXMLContents(t *testing.T) {
 	got, err := introspect.Parse([]byte(goodXMLContents))
 	if err != nil {
@@ -82,10 +155,10 @@ func TestGoodXMLContents(t *testing.T) {
 				Name: "Scan",
 				Args: []introspect.MethodArg{
 					{
-						Name:       "args",
-						Type:       "a{sv}",
-						Direction:  "",
-						Annotation: introspect.Annotation{"", ""},
+						Name:        "args",
+						Type:        "a{sv}",
+						Direction:   "",
+						Annotations: nil,
 					},
 				},
 				Annotations: nil,
@@ -97,10 +170,10 @@ func TestGoodXMLContents(t *testing.T) {
 						Name:      "request",
 						Type:      "ay",
 						Direction: "in",
-						Annotation: introspect.Annotation{
+						Annotations: []introspect.Annotation{{
 							Name:  "org.chromium.DBus.Argument.ProtobufClass",
 							Value: "PassMeProtosRequest",
-						},
+						}},
 					},
 				},
 				Annotations: []introspect.Annotation{
@@ -119,13 +192,13 @@ func TestGoodXMLContents(t *testing.T) {
 					{
 						Name: "BSSDetail",
 						Type: "ay",
-						Annotation: introspect.Annotation{
+						Annotations: []introspect.Annotation{{
 							Name:  "org.chromium.DBus.Argument.ProtobufClass",
 							Value: "YetAnotherProto",
-						},
+						}},
 					},
 				},
-				DocString: "\n        doc2\n      ",
+				DocString: "\n        doc2 calls Scan\n      ",
 			},
 		},
 		Properties: []introspect.Property{
@@ -136,6 +209,12 @@ func TestGoodXMLContents(t *testing.T) {
 				DocString: "\n        doc3\n      ",
 			},
 		},
+		Annotations: []introspect.Annotation{
+			{
+				Name:  "org.chromium.DBus.Interface.ProtobufIncludes",
+				Value: "wpa/proto.pb.h",
+			},
+		},
 		DocString: "\n      doc4\n    ",
 	}
 
@@ -143,7 +222,7 @@ func TestGoodXMLContents(t *testing.T) {
 		Name: "/org/chromium/Test",
 		Interfaces: []introspect.Interface{
 			itf,
-			{"DummyInterface", nil, nil, nil, ""},
+			{Name: "DummyInterface"},
 		},
 	}
 
@@ -151,3 +230,216 @@ func TestGoodXMLContents(t *testing.T) {
 		t.Errorf("Parse failed (-got +want):\n%s", diff)
 	}
 }
+
+func TestParseFileWithIncludes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "introspect")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"service.xml": `
+<node name="/org/chromium/Test">
+  <include href="common/properties.xml"/>
+  <interface name="org.chromium.Test"/>
+</node>`,
+		"common/properties.xml": `
+<node>
+  <include href="../test.xml"/>
+  <interface name="org.freedesktop.DBus.Properties"/>
+</node>`,
+		"test.xml": `
+<node>
+  <interface name="org.chromium.Test">
+    <method name="Ignored"/>
+  </interface>
+  <interface name="org.chromium.Shared"/>
+</node>`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := introspect.ParseFile(filepath.Join(dir, "service.xml"))
+	if err != nil {
+		t.Fatalf("ParseFile got error, want nil: %v", err)
+	}
+	want := introspect.Introspection{
+		Name: "/org/chromium/Test",
+		Interfaces: []introspect.Interface{
+			{Name: "org.chromium.Test"},
+			{Name: "org.freedesktop.DBus.Properties"},
+			{Name: "org.chromium.Shared"},
+		},
+		Includes: []introspect.Include{{Href: "common/properties.xml"}},
+	}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("ParseFile failed (-got +want):\n%s", diff)
+	}
+
+	_, paths, err := introspect.ParseFileWithIncludes(filepath.Join(dir, "service.xml"))
+	if err != nil {
+		t.Fatalf("ParseFileWithIncludes got error, want nil: %v", err)
+	}
+	wantPaths := []string{
+		filepath.Join(dir, "service.xml"),
+		filepath.Join(dir, "common/properties.xml"),
+		filepath.Join(dir, "test.xml"),
+	}
+	if diff := cmp.Diff(paths, wantPaths); diff != "" {
+		t.Errorf("ParseFileWithIncludes returned unexpected paths (-got +want):\n%s", diff)
+	}
+}
+
+func TestParseFileIncludeErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "introspect")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"a.xml":       `<node><include href="b.xml"/></node>`,
+		"b.xml":       `<node><include href="a.xml"/></node>`,
+		"missing.xml": `<node><include href="nonexistent.xml"/></node>`,
+		"empty.xml":   `<node><include/></node>`,
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases := []struct {
+		file string
+		want string
+	}{
+		{file: "a.xml", want: "include cycle"},
+		{file: "missing.xml", want: "nonexistent.xml"},
+		{file: "empty.xml", want: "include without href"},
+	}
+	for _, tc := range cases {
+		_, err := introspect.ParseFile(filepath.Join(dir, tc.file))
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("ParseFile(%q) got error %v, want error containing %q", tc.file, err, tc.want)
+		}
+	}
+}
+
+func TestParseNestedNodes(t *testing.T) {
+	const contents = `
+<node name="/org/chromium/Test">
+  <interface name="org.chromium.Test"/>
+  <node name="child">
+    <interface name="org.chromium.Child"/>
+    <node name="grandchild">
+      <interface name="org.chromium.Grandchild"/>
+    </node>
+  </node>
+  <node name="empty"/>
+  <node name="/org/chromium/Other">
+    <interface name="org.chromium.Other"/>
+  </node>
+</node>`
+	got, err := introspect.Parse([]byte(contents))
+	if err != nil {
+		t.Fatalf("Parse got error, want nil: %v", err)
+	}
+	want := introspect.Introspection{
+		Name:       "/org/chromium/Test",
+		Interfaces: []introspect.Interface{{Name: "org.chromium.Test"}},
+		Nodes: []introspect.Introspection{
+			{
+				Name:       "child",
+				Interfaces: []introspect.Interface{{Name: "org.chromium.Child"}},
+				Nodes: []introspect.Introspection{
+					{
+						Name:       "grandchild",
+						Interfaces: []introspect.Interface{{Name: "org.chromium.Grandchild"}},
+					},
+				},
+			},
+			{Name: "empty"},
+			{
+				Name:       "/org/chromium/Other",
+				Interfaces: []introspect.Interface{{Name: "org.chromium.Other"}},
+			},
+		},
+	}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("Parse failed (-got +want):\n%s", diff)
+	}
+
+	wantFlatten := []introspect.Introspection{
+		{
+			Name:       "/org/chromium/Test",
+			Interfaces: []introspect.Interface{{Name: "org.chromium.Test"}},
+		}, {
+			Name:       "/org/chromium/Test/child",
+			Interfaces: []introspect.Interface{{Name: "org.chromium.Child"}},
+		}, {
+			Name:       "/org/chromium/Test/child/grandchild",
+			Interfaces: []introspect.Interface{{Name: "org.chromium.Grandchild"}},
+		}, {
+			Name:       "/org/chromium/Other",
+			Interfaces: []introspect.Interface{{Name: "org.chromium.Other"}},
+		},
+	}
+	if diff := cmp.Diff(got.Flatten(), wantFlatten); diff != "" {
+		t.Errorf("Flatten failed (-got +want):\n%s", diff)
+	}
+}
+
+func TestFlattenRootNode(t *testing.T) {
+	in := introspect.Introspection{
+		Name: "/",
+		Nodes: []introspect.Introspection{
+			{Name: "org", Interfaces: []introspect.Interface{{Name: "org.chromium.Test"}}},
+		},
+	}
+	got := in.Flatten()
+	want := []introspect.Introspection{
+		{Name: "/"},
+		{Name: "/org", Interfaces: []introspect.Interface{{Name: "org.chromium.Test"}}},
+	}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("Flatten failed (-got +want):\n%s", diff)
+	}
+}
+
+func TestParseNestedNodeErrors(t *testing.T) {
+	cases := []struct {
+		contents string
+		want     string
+	}{
+		{
+			contents: `<node name="/a"><node/></node>`,
+			want:     `invalid child node name ""`,
+		}, {
+			contents: `<node name="/a"><node name="b/"/></node>`,
+			want:     `invalid child node name "b/"`,
+		}, {
+			contents: `<node><node name="b"/></node>`,
+			want:     "b node: relative child node requires the parent node to have a name",
+		}, {
+			contents: `<node name="/a"><node name="b"><include href="c.xml"/></node></node>`,
+			want:     "b node: include is allowed only in the root node",
+		}, {
+			contents: `<node name="/a"><node name="b"><interface/></node></node>`,
+			want:     "b node:  interface: empty interface name specified",
+		},
+	}
+	for _, tc := range cases {
+		_, err := introspect.Parse([]byte(tc.contents))
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("Parse(%q) got error %v, want error containing %q", tc.contents, err, tc.want)
+		}
+	}
+}
@@ -7,17 +7,48 @@ package introspect
 import (
 	"errors"
 	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"go.chromium.org/chromiumos/dbusbindings/dbustype"
 )
 
-// TODO(chromium:983008): Add validations for the type signatures.
+// errorNameRE matches a D-Bus error name, which follows the same rules as an interface name.
+var errorNameRE = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)+$`)
+
+// nodeNameRE matches the name of a child node, which is either a relative
+// object path, e.g. "child" or "child/grandchild", or an absolute one.
+var nodeNameRE = regexp.MustCompile(`^/?[A-Za-z0-9_]+(/[A-Za-z0-9_]+)*$`)
 
 // verifyIntrospection verifies that introspection does not contain invalid values.
 func verifyIntrospection(i *Introspection) error {
+	for _, inc := range i.Includes {
+		if inc.Href == "" {
+			return errors.New("include without href specified")
+		}
+	}
 	for _, itf := range i.Interfaces {
 		if err := verifyInterface(&itf); err != nil {
 			return fmt.Errorf("%s interface: %v", itf.Name, err)
 		}
 	}
+	for _, n := range i.Nodes {
+		if !nodeNameRE.MatchString(n.Name) {
+			return fmt.Errorf("invalid child node name %q", n.Name)
+		}
+		// The object path of a relative child is composed from its parent's.
+		if i.Name == "" && !strings.HasPrefix(n.Name, "/") {
+			return fmt.Errorf("%s node: relative child node requires the parent node to have a name", n.Name)
+		}
+		// Includes are resolved by ParseFile into the root node only.
+		if len(n.Includes) > 0 {
+			return fmt.Errorf("%s node: include is allowed only in the root node", n.Name)
+		}
+		if err := verifyIntrospection(&n); err != nil {
+			return fmt.Errorf("%s node: %v", n.Name, err)
+		}
+	}
 	return nil
 }
 
@@ -31,7 +62,95 @@ func verifyInterface(itf *Interface) error {
 			return fmt.Errorf("%s method: %v", m.Name, err)
 		}
 	}
-	// TODO(chromium:983008): Add validations for signals and properties.
+	for _, p := range itf.Properties {
+		if err := verifyProperty(&p); err != nil {
+			return fmt.Errorf("%s property: %v", p.Name, err)
+		}
+	}
+	for _, s := range itf.Signals {
+		if err := verifySignal(&s); err != nil {
+			return fmt.Errorf("%s signal: %v", s.Name, err)
+		}
+	}
+	return nil
+}
+
+// verifyMinVersion verifies that the value of the org.chromium.DBus.MinVersion
+// annotation a is a non-negative integer.
+func verifyMinVersion(a Annotation) error {
+	if v, err := strconv.Atoi(a.Value); err != nil || v < 0 {
+		return fmt.Errorf("invalid annotation value for %s; want a non-negative integer", a.Name)
+	}
+	return nil
+}
+
+func verifySignal(s *Signal) error {
+	// TODO(chromium:983008): Add validations for signal arguments.
+	for _, arg := range s.Args {
+		if err := dbustype.Validate(arg.Type); err != nil {
+			return fmt.Errorf("%s argument: %v", arg.Name, err)
+		}
+	}
+	for _, annotation := range s.Annotations {
+		switch annotation.Name {
+		case "org.chromium.DBus.Signal.Kind":
+			switch annotation.Value {
+			case "normal", "raw":
+			default:
+				return fmt.Errorf("invalid annotation value for %s", annotation.Name)
+			}
+		case "org.chromium.DBus.MinVersion":
+			if err := verifyMinVersion(annotation); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func verifyProperty(p *Property) error {
+	if p.Name == "" {
+		return errors.New("empty property name specified")
+	}
+	switch p.Access {
+	case "read", "write", "readwrite":
+	default:
+		return fmt.Errorf("invalid access %q; want read, write or readwrite", p.Access)
+	}
+	if err := dbustype.Validate(p.Type); err != nil {
+		return err
+	}
+	// Property values are cached and copied by brillo::dbus_utils, but
+	// base::ScopedFD is move-only, so file descriptors can be passed only
+	// through method arguments and signals.
+	if strings.ContainsRune(p.Type, 'h') {
+		return fmt.Errorf("file descriptors cannot be used in property type %s", p.Type)
+	}
+	for _, a := range p.Annotations {
+		switch a.Name {
+		case "org.chromium.DBus.MinVersion":
+			if err := verifyMinVersion(a); err != nil {
+				return err
+			}
+		case "org.chromium.DBus.Property.CachePolicy":
+			switch a.Value {
+			case "cached", "fetch_once", "always":
+			default:
+				return fmt.Errorf("invalid annotation value for %s", a.Name)
+			}
+		case "org.freedesktop.DBus.Property.EmitsChangedSignal":
+			switch a.Value {
+			case "true", "invalidates", "false":
+			case "const":
+				// A constant cannot be set.
+				if p.Access != "read" {
+					return fmt.Errorf("%s is const but its access is %s", p.Name, p.Access)
+				}
+			default:
+				return fmt.Errorf("invalid annotation value for %s", a.Name)
+			}
+		}
+	}
 	return nil
 }
 
@@ -46,6 +165,17 @@ func verifyMethod(method *Method) error {
 		}
 	}
 
+	// Like C++ default arguments, default values are allowed only for
+	// trailing input arguments.
+	hasDefault := false
+	for _, arg := range method.InputArguments() {
+		if arg.DefaultValue() != "" {
+			hasDefault = true
+		} else if hasDefault {
+			return fmt.Errorf("%s argument: default value is missing after an argument with a default value", arg.Name)
+		}
+	}
+
 	// Verify that method annotation name is not duplicated.
 	m := make(map[string]bool)
 	for _, a := range method.Annotations {
@@ -76,6 +206,48 @@ func verifyMethod(method *Method) error {
 			default:
 				return fmt.Errorf("invalid annotation value for %s", annotation.Name)
 			}
+		case "org.chromium.DBus.Method.ReturnsFDStream":
+			switch annotation.Value {
+			case "true":
+				if out := method.OutputArguments(); len(out) != 1 || out[0].Type != "h" {
+					return fmt.Errorf("when using the %s annotation, the method must have exactly one output argument of type h", annotation.Name)
+				}
+			case "false":
+			default:
+				return fmt.Errorf("invalid annotation value for %s", annotation.Name)
+			}
+		case "org.freedesktop.DBus.Method.NoReply":
+			switch annotation.Value {
+			case "true":
+				if len(method.OutputArguments()) > 0 {
+					return fmt.Errorf("when using the %s annotation, the method must have no output arguments", annotation.Name)
+				}
+			case "false":
+			default:
+				return fmt.Errorf("invalid annotation value for %s", annotation.Name)
+			}
+		case "org.chromium.DBus.Method.Errors":
+			errs := strings.Fields(annotation.Value)
+			if len(errs) == 0 {
+				return fmt.Errorf("empty annotation value for %s", annotation.Name)
+			}
+			// The last components of the error names are used as the enumerators
+			// of the generated error enum, so they must be unique.
+			seen := make(map[string]string)
+			for _, e := range errs {
+				if !errorNameRE.MatchString(e) {
+					return fmt.Errorf("invalid error name %q in %s", e, annotation.Name)
+				}
+				last := e[strings.LastIndex(e, ".")+1:]
+				if prev, ok := seen[last]; ok {
+					return fmt.Errorf("error names %q and %q in %s have the same last component", prev, e, annotation.Name)
+				}
+				seen[last] = e
+			}
+		case "org.chromium.DBus.MinVersion":
+			if err := verifyMinVersion(annotation); err != nil {
+				return err
+			}
 		case "org.freedesktop.DBus.GLib.Async":
 		}
 	}
@@ -95,13 +267,89 @@ func verifyMethodArg(arg *MethodArg) error {
 		return fmt.Errorf("unknown method argument direction %s", arg.Direction)
 	}
 
-	switch arg.Annotation.Name {
-	case "org.chromium.DBus.Argument.ProtobufClass":
-		if arg.Type != "ay" {
-			return fmt.Errorf("when using the %s annotation, the argument type must be %s", arg.Annotation.Name, "ay")
+	for _, an := range arg.Annotations {
+		switch an.Name {
+		case "org.chromium.DBus.Argument.ProtobufClass":
+			if arg.Type != "ay" {
+				return fmt.Errorf("when using the %s annotation, the argument type must be %s", an.Name, "ay")
+			}
+		case "org.chromium.DBus.Struct.FieldNames":
+			if _, err := arg.StructDef(); err != nil {
+				return err
+			}
+		case "org.chromium.DBus.Argument.EnumClass":
+			if _, err := arg.EnumDef(); err != nil {
+				return err
+			}
+		case "org.chromium.DBus.Argument.FlagsClass":
+			if _, err := arg.FlagsDef(); err != nil {
+				return err
+			}
+		case "org.chromium.DBus.Argument.CppType":
+			if strings.TrimSpace(an.Value) == "" {
+				return fmt.Errorf("empty annotation value for %s", an.Name)
+			}
+		case "org.chromium.DBus.Argument.DefaultValue":
+			if arg.Direction == "out" {
+				return fmt.Errorf("%s annotation is allowed only for input arguments", an.Name)
+			}
+			if strings.TrimSpace(an.Value) == "" {
+				return fmt.Errorf("empty annotation value for %s", an.Name)
+			}
+		case "org.chromium.DBus.Argument.VariantTypes":
+			if arg.Type != "v" {
+				return fmt.Errorf("when using the %s annotation, the argument type must be %s", an.Name, "v")
+			}
+			types, _ := arg.VariantTypes()
+			if len(types) == 0 {
+				return fmt.Errorf("empty annotation value for %s", an.Name)
+			}
+			seen := make(map[string]bool)
+			for _, t := range types {
+				if t == "..." {
+					return fmt.Errorf("\"...\" must be the last item of %s", an.Name)
+				}
+				if _, err := dbustype.Parse(t); err != nil {
+					return fmt.Errorf("invalid type %q in %s: %v", t, an.Name, err)
+				}
+				// brillo::Any cannot hold move-only base::ScopedFD.
+				if strings.ContainsRune(t, 'h') {
+					return fmt.Errorf("file descriptors cannot be used in type %q in %s", t, an.Name)
+				}
+				if seen[t] {
+					return fmt.Errorf("duplicate type %q in %s", t, an.Name)
+				}
+				seen[t] = true
+			}
+		case "org.chromium.DBus.Argument.Optional":
+			switch an.Value {
+			case "true":
+				if arg.Direction != "out" {
+					return fmt.Errorf("%s annotation is allowed only for output arguments", an.Name)
+				}
+				if _, err := optionalValueTypeInternal(string(arg.Type), &an); err != nil {
+					return err
+				}
+			case "false":
+			default:
+				return fmt.Errorf("invalid annotation value for %s", an.Name)
+			}
+		case "org.chromium.DBus.Argument.PassByMove":
+			switch an.Value {
+			case "true":
+				if arg.Direction == "out" {
+					return fmt.Errorf("%s annotation is allowed only for input arguments", an.Name)
+				}
+				if !strings.HasPrefix(string(arg.Type), "a") {
+					return fmt.Errorf("when using the %s annotation, the argument type must be an array or a dict", an.Name)
+				}
+			case "false":
+			default:
+				return fmt.Errorf("invalid annotation value for %s", an.Name)
+			}
+		case "":
 		}
-	case "":
 	}
 
-	return nil
+	return dbustype.Validate(string(arg.Type))
 }
@@ -4,7 +4,10 @@
 
 package introspect
 
-import "testing"
+import (
+	"fmt"
+	"testing"
+)
 
 func TestInvalidInterfaceIntrospection(t *testing.T) {
 	i := Introspection{
@@ -91,6 +94,101 @@ func TestValidInterface(t *testing.T) {
 	}
 }
 
+func TestInvalidPropertyInterface(t *testing.T) {
+	itf := Interface{
+		Name: "itf",
+		Properties: []Property{
+			{Name: "Fds", Type: "a{sh}", Access: "read"},
+		},
+	}
+	err := verifyInterface(&itf)
+	if err == nil {
+		t.Fatal("verifyInterface unexpectedly succeeded")
+	}
+	const want = "Fds property: file descriptors cannot be used in property type a{sh}"
+	if err.Error() != want {
+		t.Errorf("verifyInterface err mismatch: got %q, want %q", err, want)
+	}
+}
+
+func TestInvalidPropertyCachePolicy(t *testing.T) {
+	itf := Interface{
+		Name: "itf",
+		Properties: []Property{
+			{
+				Name: "Count", Type: "i", Access: "read",
+				Annotations: []Annotation{{Name: "org.chromium.DBus.Property.CachePolicy", Value: "never"}},
+			},
+		},
+	}
+	err := verifyInterface(&itf)
+	if err == nil {
+		t.Fatal("verifyInterface unexpectedly succeeded")
+	}
+	const want = "Count property: invalid annotation value for org.chromium.DBus.Property.CachePolicy"
+	if err.Error() != want {
+		t.Errorf("verifyInterface err mismatch: got %q, want %q", err, want)
+	}
+}
+
+func TestInvalidPropertyAccess(t *testing.T) {
+	for _, access := range []string{"", "readonly", "Read"} {
+		p := Property{Name: "Count", Type: "i", Access: access}
+		err := verifyProperty(&p)
+		if err == nil {
+			t.Errorf("verifyProperty unexpectedly succeeded with access %q", access)
+			continue
+		}
+		want := fmt.Sprintf("invalid access %q; want read, write or readwrite", access)
+		if err.Error() != want {
+			t.Errorf("verifyProperty err mismatch: got %q, want %q", err, want)
+		}
+	}
+}
+
+func TestInvalidPropertyEmitsChangedSignal(t *testing.T) {
+	cases := []struct {
+		property Property
+		want     string
+	}{
+		{
+			property: Property{
+				Name: "Count", Type: "i", Access: "read",
+				Annotations: []Annotation{{Name: "org.freedesktop.DBus.Property.EmitsChangedSignal", Value: "sometimes"}},
+			},
+			want: "invalid annotation value for org.freedesktop.DBus.Property.EmitsChangedSignal",
+		}, {
+			property: Property{
+				Name: "Count", Type: "i", Access: "readwrite",
+				Annotations: []Annotation{{Name: "org.freedesktop.DBus.Property.EmitsChangedSignal", Value: "const"}},
+			},
+			want: "Count is const but its access is readwrite",
+		},
+	}
+	for _, tc := range cases {
+		err := verifyProperty(&tc.property)
+		if err == nil {
+			t.Errorf("verifyProperty unexpectedly succeeded with %q", tc.property.Annotations[0].Value)
+			continue
+		}
+		if err.Error() != tc.want {
+			t.Errorf("verifyProperty err mismatch: got %q, want %q", err, tc.want)
+		}
+	}
+}
+
+func TestEmptyNameProperty(t *testing.T) {
+	p := Property{Type: "s"}
+	err := verifyProperty(&p)
+	if err == nil {
+		t.Fatal("verifyProperty unexpectedly succeeded")
+	}
+	const want = "empty property name specified"
+	if err.Error() != want {
+		t.Errorf("verifyProperty err mismatch: got %q, want %q", err, want)
+	}
+}
+
 func TestEmptyNameMethod(t *testing.T) {
 	m := Method{Name: ""}
 	err := verifyMethod(&m)
@@ -155,6 +253,42 @@ func TestInvalidKindAnnotationMethod(t *testing.T) {
 	}
 }
 
+func TestInvalidKindAnnotationSignal(t *testing.T) {
+	s := Signal{
+		Name: "s",
+		Annotations: []Annotation{
+			{Name: "org.chromium.DBus.Signal.Kind", Value: "async"},
+		},
+	}
+	err := verifySignal(&s)
+	if err == nil {
+		t.Fatal("verifySignal unexpectedly succeeded")
+	}
+	const want = "invalid annotation value for org.chromium.DBus.Signal.Kind"
+	if err.Error() != want {
+		t.Errorf("verifySignal err mismatch: got %q, want %q", err, want)
+	}
+}
+
+func TestInvalidMinVersionAnnotation(t *testing.T) {
+	const want = "invalid annotation value for org.chromium.DBus.MinVersion; want a non-negative integer"
+	for _, v := range []string{"", "1.2", "-1"} {
+		a := Annotation{Name: "org.chromium.DBus.MinVersion", Value: v}
+		m := Method{Name: "f", Annotations: []Annotation{a}}
+		if err := verifyMethod(&m); err == nil || err.Error() != want {
+			t.Errorf("verifyMethod with %q got %v, want %q", v, err, want)
+		}
+		s := Signal{Name: "s", Annotations: []Annotation{a}}
+		if err := verifySignal(&s); err == nil || err.Error() != want {
+			t.Errorf("verifySignal with %q got %v, want %q", v, err, want)
+		}
+		p := Property{Name: "p", Type: "i", Access: "read", Annotations: []Annotation{a}}
+		if err := verifyProperty(&p); err == nil || err.Error() != want {
+			t.Errorf("verifyProperty with %q got %v, want %q", v, err, want)
+		}
+	}
+}
+
 func TestInvalidConstAnnotationMethod(t *testing.T) {
 	m := Method{
 		Name: "f",
@@ -189,6 +323,102 @@ func TestInvalidMessageAnnotationMethod(t *testing.T) {
 	}
 }
 
+func TestInvalidReturnsFDStreamAnnotationMethod(t *testing.T) {
+	cases := []struct {
+		value string
+		args  []MethodArg
+		want  string
+	}{
+		{
+			value: "yes",
+			args:  []MethodArg{{Type: "h", Direction: "out"}},
+			want:  "invalid annotation value for org.chromium.DBus.Method.ReturnsFDStream",
+		}, {
+			value: "true",
+			args:  []MethodArg{{Type: "s", Direction: "out"}},
+			want:  "when using the org.chromium.DBus.Method.ReturnsFDStream annotation, the method must have exactly one output argument of type h",
+		}, {
+			value: "true",
+			args:  []MethodArg{{Type: "h", Direction: "out"}, {Type: "h", Direction: "out"}},
+			want:  "when using the org.chromium.DBus.Method.ReturnsFDStream annotation, the method must have exactly one output argument of type h",
+		},
+	}
+	for _, tc := range cases {
+		m := Method{
+			Name: "f",
+			Args: tc.args,
+			Annotations: []Annotation{
+				{Name: "org.chromium.DBus.Method.ReturnsFDStream", Value: tc.value},
+			},
+		}
+		err := verifyMethod(&m)
+		if err == nil {
+			t.Fatalf("verifyMethod unexpectedly succeeded for %q", tc.value)
+		}
+		if err.Error() != tc.want {
+			t.Errorf("verifyMethod err mismatch: got %q, want %q", err, tc.want)
+		}
+	}
+}
+
+func TestInvalidNoReplyAnnotationMethod(t *testing.T) {
+	cases := []struct {
+		value string
+		args  []MethodArg
+		want  string
+	}{
+		{
+			value: "yes",
+			want:  "invalid annotation value for org.freedesktop.DBus.Method.NoReply",
+		}, {
+			value: "true",
+			args:  []MethodArg{{Type: "s", Direction: "in"}, {Type: "i", Direction: "out"}},
+			want:  "when using the org.freedesktop.DBus.Method.NoReply annotation, the method must have no output arguments",
+		},
+	}
+	for _, tc := range cases {
+		m := Method{
+			Name: "f",
+			Args: tc.args,
+			Annotations: []Annotation{
+				{Name: "org.freedesktop.DBus.Method.NoReply", Value: tc.value},
+			},
+		}
+		err := verifyMethod(&m)
+		if err == nil {
+			t.Fatalf("verifyMethod unexpectedly succeeded for %q", tc.value)
+		}
+		if err.Error() != tc.want {
+			t.Errorf("verifyMethod err mismatch: got %q, want %q", err, tc.want)
+		}
+	}
+}
+
+func TestInvalidErrorsAnnotationMethod(t *testing.T) {
+	cases := []struct {
+		value, want string
+	}{
+		{"", "empty annotation value for org.chromium.DBus.Method.Errors"},
+		{"org.chromium.Error.Failed NoDots", `invalid error name "NoDots" in org.chromium.DBus.Method.Errors`},
+		{"org.chromium.Error.Failed org.chromium.Other.Failed", `error names "org.chromium.Error.Failed" and "org.chromium.Other.Failed" in org.chromium.DBus.Method.Errors have the same last component`},
+	}
+	for _, tc := range cases {
+		m := Method{
+			Name: "f",
+			Annotations: []Annotation{
+				{Name: "org.chromium.DBus.Method.Errors", Value: tc.value},
+			},
+		}
+		err := verifyMethod(&m)
+		if err == nil {
+			t.Fatalf("verifyMethod unexpectedly succeeded for %q", tc.value)
+		}
+		if err.Error() != tc.want {
+			t.Errorf("verifyMethod err mismatch: got %q, want %q", err, tc.want)
+		}
+	}
+}
+
 func TestValidMethod(t *testing.T) {
 	m := Method{
 		Name: "f",
@@ -199,6 +429,8 @@ func TestValidMethod(t *testing.T) {
 			{Name: "org.chromium.DBus.Method.Kind", Value: "simple"},
 			{Name: "org.chromium.DBus.Method.Const", Value: "true"},
 			{Name: "org.chromium.DBus.Method.IncludeDBusMessage", Value: "true"},
+			{Name: "org.chromium.DBus.Method.Errors", Value: "org.chromium.Error.Failed\n org.chromium.Error.Busy"},
+			{Name: "org.freedesktop.DBus.Method.NoReply", Value: "false"},
 			{Name: "org.freedesktop.DBus.GLib.Async"},
 			{Name: "ignored"},
 		},
@@ -237,8 +469,8 @@ func TestInvalidDirectionArg(t *testing.T) {
 
 func TestInvalidTypeArg(t *testing.T) {
 	arg := MethodArg{
-		Annotation: Annotation{Name: "org.chromium.DBus.Argument.ProtobufClass"},
-		Type:       "TypeOtherThanAy",
+		Annotations: []Annotation{{Name: "org.chromium.DBus.Argument.ProtobufClass"}},
+		Type:        "TypeOtherThanAy",
 	}
 	err := verifyMethodArg(&arg)
 	if err == nil {
@@ -250,6 +482,280 @@ func TestInvalidTypeArg(t *testing.T) {
 	}
 }
 
+func TestInvalidSignatures(t *testing.T) {
+	const want = `invalid signature "a{vs}" at offset 2: dict key type 'v' is not a basic type`
+	arg := MethodArg{Name: "map", Type: "a{vs}"}
+	if err := verifyMethodArg(&arg); err == nil || err.Error() != want {
+		t.Errorf("verifyMethodArg err mismatch: got %v, want %q", err, want)
+	}
+	s := Signal{Name: "Changed", Args: []SignalArg{{Name: "map", Type: "a{vs}"}}}
+	if err := verifySignal(&s); err == nil || err.Error() != "map argument: "+want {
+		t.Errorf("verifySignal err mismatch: got %v, want %q", err, "map argument: "+want)
+	}
+	p := Property{Name: "Map", Type: "a{vs}", Access: "read"}
+	if err := verifyProperty(&p); err == nil || err.Error() != want {
+		t.Errorf("verifyProperty err mismatch: got %v, want %q", err, want)
+	}
+}
+
+func TestInvalidStructFieldNamesArg(t *testing.T) {
+	arg := MethodArg{
+		Annotations: []Annotation{{Name: "org.chromium.DBus.Struct.FieldNames", Value: "Pair(first, second)"}},
+		Type:        "(sss)",
+	}
+	err := verifyMethodArg(&arg)
+	if err == nil {
+		t.Fatal("verifyMethodArg unexpectedly succeeded")
+	}
+	const want = "struct Pair has 2 fields, but type (sss) has 3 members"
+	if err.Error() != want {
+		t.Errorf("verifyMethodArg err mismatch: got %q, want %q", err, want)
+	}
+}
+
+func TestInvalidEnumClassArg(t *testing.T) {
+	cases := []struct {
+		arg  MethodArg
+		want string
+	}{{
+		arg: MethodArg{
+			Type:        "s",
+			Annotations: []Annotation{{Name: "org.chromium.DBus.Argument.EnumClass", Value: "my::Enum"}},
+		},
+		want: `enum class my::Enum requires an integer type, got "s"`,
+	}, {
+		arg: MethodArg{
+			Type:        "i",
+			Annotations: []Annotation{{Name: "org.chromium.DBus.Argument.EnumClass", Value: "my::Enum<int>"}},
+		},
+		want: `invalid enum class "my::Enum<int>"`,
+	}}
+	for _, tc := range cases {
+		err := verifyMethodArg(&tc.arg)
+		if err == nil {
+			t.Errorf("verifyMethodArg(%v) unexpectedly succeeded", tc.arg)
+		} else if err.Error() != tc.want {
+			t.Errorf("verifyMethodArg err mismatch: got %q, want %q", err, tc.want)
+		}
+	}
+}
+
+func TestInvalidFlagsClassArg(t *testing.T) {
+	cases := []struct {
+		arg  MethodArg
+		want string
+	}{{
+		arg: MethodArg{
+			Type:        "i",
+			Annotations: []Annotation{{Name: "org.chromium.DBus.Argument.FlagsClass", Value: "Options(kA, kB)"}},
+		},
+		want: `flags class Options requires an unsigned integer type, got "i"`,
+	}, {
+		arg: MethodArg{
+			Type:        "u",
+			Annotations: []Annotation{{Name: "org.chromium.DBus.Argument.FlagsClass", Value: "my::Options(kA)"}},
+		},
+		want: `invalid flags class "my::Options(kA)"; want "Name(kValue1, kValue2=N, ...)"`,
+	}, {
+		arg: MethodArg{
+			Type:        "u",
+			Annotations: []Annotation{{Name: "org.chromium.DBus.Argument.FlagsClass", Value: "Options(kA, kA)"}},
+		},
+		want: `duplicate flags value kA in "Options(kA, kA)"`,
+	}, {
+		arg: MethodArg{
+			Type:        "y",
+			Annotations: []Annotation{{Name: "org.chromium.DBus.Argument.FlagsClass", Value: "Options(kA=0x100)"}},
+		},
+		want: `invalid value of flags value kA in "Options(kA=0x100)": strconv.ParseUint: parsing "0x100": value out of range`,
+	}, {
+		arg: MethodArg{
+			Type:        "y",
+			Annotations: []Annotation{{Name: "org.chromium.DBus.Argument.FlagsClass", Value: "Options(k0, k1, k2, k3, k4, k5, k6, k7, k8)"}},
+		},
+		want: `flags value k8 in "Options(k0, k1, k2, k3, k4, k5, k6, k7, k8)" does not fit in "y"`,
+	}}
+	for _, tc := range cases {
+		err := verifyMethodArg(&tc.arg)
+		if err == nil {
+			t.Errorf("verifyMethodArg(%v) unexpectedly succeeded", tc.arg)
+		} else if err.Error() != tc.want {
+			t.Errorf("verifyMethodArg err mismatch: got %q, want %q", err, tc.want)
+		}
+	}
+}
+
+func TestInvalidOptionalArg(t *testing.T) {
+	cases := []struct {
+		arg  MethodArg
+		want string
+	}{{
+		arg: MethodArg{
+			Type:        "(bs)",
+			Annotations: []Annotation{{Name: "org.chromium.DBus.Argument.Optional", Value: "true"}},
+		},
+		want: "org.chromium.DBus.Argument.Optional annotation is allowed only for output arguments",
+	}, {
+		arg: MethodArg{
+			Type: "s", Direction: "out",
+			Annotations: []Annotation{{Name: "org.chromium.DBus.Argument.Optional", Value: "true"}},
+		},
+		want: `optional argument requires a type (bT), got "s"`,
+	}, {
+		arg: MethodArg{
+			Type: "(bss)", Direction: "out",
+			Annotations: []Annotation{{Name: "org.chromium.DBus.Argument.Optional", Value: "true"}},
+		},
+		want: `optional argument requires a type (bT), got "(bss)": ss is not a signature made up of a single complete type`,
+	}, {
+		arg: MethodArg{
+			Type: "(bs)", Direction: "out",
+			Annotations: []Annotation{{Name: "org.chromium.DBus.Argument.Optional", Value: "yes"}},
+		},
+		want: "invalid annotation value for org.chromium.DBus.Argument.Optional",
+	}}
+	for _, tc := range cases {
+		err := verifyMethodArg(&tc.arg)
+		if err == nil {
+			t.Errorf("verifyMethodArg(%v) unexpectedly succeeded", tc.arg)
+		} else if err.Error() != tc.want {
+			t.Errorf("verifyMethodArg err mismatch: got %q, want %q", err, tc.want)
+		}
+	}
+}
+
+func TestInvalidVariantTypesArg(t *testing.T) {
+	annotation := func(v string) Annotation {
+		return Annotation{Name: "org.chromium.DBus.Argument.VariantTypes", Value: v}
+	}
+	cases := []struct {
+		arg  MethodArg
+		want string
+	}{{
+		arg:  MethodArg{Type: "s", Annotations: []Annotation{annotation("s i")}},
+		want: "when using the org.chromium.DBus.Argument.VariantTypes annotation, the argument type must be v",
+	}, {
+		arg:  MethodArg{Type: "v", Annotations: []Annotation{annotation(" ...")}},
+		want: "empty annotation value for org.chromium.DBus.Argument.VariantTypes",
+	}, {
+		arg:  MethodArg{Type: "v", Annotations: []Annotation{annotation("s ... i")}},
+		want: `"..." must be the last item of org.chromium.DBus.Argument.VariantTypes`,
+	}, {
+		arg:  MethodArg{Type: "v", Annotations: []Annotation{annotation("s a{s}")}},
+		want: `invalid type "a{s}" in org.chromium.DBus.Argument.VariantTypes: parseCompleteType("a{s}", 0, 0, 0) faild: dict entries must have 2 sub-types`,
+	}, {
+		arg:  MethodArg{Type: "v", Annotations: []Annotation{annotation("ah")}},
+		want: `file descriptors cannot be used in type "ah" in org.chromium.DBus.Argument.VariantTypes`,
+	}, {
+		arg:  MethodArg{Type: "v", Annotations: []Annotation{annotation("s i s")}},
+		want: `duplicate type "s" in org.chromium.DBus.Argument.VariantTypes`,
+	}}
+	for _, tc := range cases {
+		err := verifyMethodArg(&tc.arg)
+		if err == nil {
+			t.Errorf("verifyMethodArg(%v) unexpectedly succeeded", tc.arg)
+		} else if err.Error() != tc.want {
+			t.Errorf("verifyMethodArg err mismatch: got %q, want %q", err, tc.want)
+		}
+	}
+}
+
+func TestInvalidDefaultValueArg(t *testing.T) {
+	cases := []struct {
+		arg  MethodArg
+		want string
+	}{{
+		arg: MethodArg{
+			Type: "i", Direction: "out",
+			Annotations: []Annotation{{Name: "org.chromium.DBus.Argument.DefaultValue", Value: "0"}},
+		},
+		want: "org.chromium.DBus.Argument.DefaultValue annotation is allowed only for input arguments",
+	}, {
+		arg: MethodArg{
+			Type:        "i",
+			Annotations: []Annotation{{Name: "org.chromium.DBus.Argument.DefaultValue", Value: " "}},
+		},
+		want: "empty annotation value for org.chromium.DBus.Argument.DefaultValue",
+	}}
+	for _, tc := range cases {
+		err := verifyMethodArg(&tc.arg)
+		if err == nil {
+			t.Errorf("verifyMethodArg(%v) unexpectedly succeeded", tc.arg)
+		} else if err.Error() != tc.want {
+			t.Errorf("verifyMethodArg err mismatch: got %q, want %q", err, tc.want)
+		}
+	}
+}
+
+func TestInvalidPassByMoveArg(t *testing.T) {
+	cases := []struct {
+		arg  MethodArg
+		want string
+	}{{
+		arg: MethodArg{
+			Type: "ay", Direction: "out",
+			Annotations: []Annotation{{Name: "org.chromium.DBus.Argument.PassByMove", Value: "true"}},
+		},
+		want: "org.chromium.DBus.Argument.PassByMove annotation is allowed only for input arguments",
+	}, {
+		arg: MethodArg{
+			Type:        "s",
+			Annotations: []Annotation{{Name: "org.chromium.DBus.Argument.PassByMove", Value: "true"}},
+		},
+		want: "when using the org.chromium.DBus.Argument.PassByMove annotation, the argument type must be an array or a dict",
+	}, {
+		arg: MethodArg{
+			Type:        "ay",
+			Annotations: []Annotation{{Name: "org.chromium.DBus.Argument.PassByMove", Value: "yes"}},
+		},
+		want: "invalid annotation value for org.chromium.DBus.Argument.PassByMove",
+	}}
+	for _, tc := range cases {
+		err := verifyMethodArg(&tc.arg)
+		if err == nil {
+			t.Errorf("verifyMethodArg(%v) unexpectedly succeeded", tc.arg)
+		} else if err.Error() != tc.want {
+			t.Errorf("verifyMethodArg err mismatch: got %q, want %q", err, tc.want)
+		}
+	}
+}
+
+func TestInvalidCppTypeArg(t *testing.T) {
+	arg := MethodArg{
+		Type:        "au",
+		Annotations: []Annotation{{Name: "org.chromium.DBus.Argument.CppType", Value: " "}},
+	}
+	err := verifyMethodArg(&arg)
+	if err == nil {
+		t.Fatal("verifyMethodArg unexpectedly succeeded")
+	}
+	const want = "empty annotation value for org.chromium.DBus.Argument.CppType"
+	if err.Error() != want {
+		t.Errorf("verifyMethodArg err mismatch: got %q, want %q", err, want)
+	}
+}
+
+func TestNonTrailingDefaultValueMethod(t *testing.T) {
+	m := Method{
+		Name: "f",
+		Args: []MethodArg{
+			{
+				Name: "x", Type: "i",
+				Annotations: []Annotation{{Name: "org.chromium.DBus.Argument.DefaultValue", Value: "0"}},
+			},
+			{Name: "y", Type: "i"},
+		},
+	}
+	err := verifyMethod(&m)
+	if err == nil {
+		t.Fatal("verifyMethod unexpectedly succeeded")
+	}
+	const want = "y argument: default value is missing after an argument with a default value"
+	if err.Error() != want {
+		t.Errorf("verifyMethod err mismatch: got %q, want %q", err, want)
+	}
+}
+
 func TestValidArg(t *testing.T) {
 	args := []MethodArg{
 		{
@@ -257,12 +763,18 @@ func TestValidArg(t *testing.T) {
 			Type:      "i",
 			Direction: "in",
 		}, {
-			Type:       "ay",
-			Direction:  "out",
-			Annotation: Annotation{Name: "org.chromium.DBus.Argument.ProtobufClass"},
+			Type:        "ay",
+			Direction:   "out",
+			Annotations: []Annotation{{Name: "org.chromium.DBus.Argument.ProtobufClass"}},
+		}, {
+			Type:        "a(si)",
+			Annotations: []Annotation{{Name: "org.chromium.DBus.Struct.FieldNames", Value: "Entry(name, value)"}},
+		}, {
+			Type:        "u",
+			Annotations: []Annotation{{Name: "org.chromium.DBus.Argument.EnumClass", Value: "my::Enum"}},
 		}, {
-			Type:       "s",
-			Annotation: Annotation{Name: "ignored"},
+			Type:        "s",
+			Annotations: []Annotation{{Name: "ignored"}},
 		},
 	}
 	for _, arg := range args {
@@ -0,0 +1,123 @@
+// Copyright 2022 The ChromiumOS Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+// Package lint checks that introspections follow the ChromeOS conventions
+// which the generator does not enforce, e.g. the presence of doc strings, so
+// that the findings can be reported by presubmit checks.
+package lint
+
+import (
+	"fmt"
+	"regexp"
+
+	"go.chromium.org/chromiumos/dbusbindings/introspect"
+)
+
+// Rules of the findings.
+const (
+	// RuleKind reports the annotations selecting the method kind other than
+	// org.chromium.DBus.Method.Kind.
+	RuleKind = "kind"
+	// RuleProtobufClass reports the protobuf classes not named as
+	// namespace::ClassName.
+	RuleProtobufClass = "protobuf-class"
+	// RuleDocString reports the interfaces and members without doc strings.
+	RuleDocString = "docstring"
+	// RuleArgName reports the arguments which are not named in snake_case.
+	RuleArgName = "arg-name"
+)
+
+// Finding is a violation of a convention.
+type Finding struct {
+	File string `json:"file"`
+	// Location is the interface, or the member of the interface, e.g.
+	// "org.chromium.Test.Ping", violating the convention.
+	Location string `json:"location"`
+	Rule     string `json:"rule"`
+	Message  string `json:"message"`
+}
+
+// protobufClassRE matches a protobuf class name qualified by its package,
+// e.g. "login_manager::PolicyDescriptor".
+var protobufClassRE = regexp.MustCompile(`^([a-z_][a-z0-9_]*::)+[A-Z][A-Za-z0-9]*$`)
+
+// argNameRE matches an argument name in snake_case.
+var argNameRE = regexp.MustCompile(`^[a-z][a-z0-9]*(_[a-z0-9]+)*$`)
+
+// Check returns the findings in introspects, parsed from file.
+func Check(file string, introspects []introspect.Introspection) []Finding {
+	c := &checker{file: file}
+	for _, i := range introspects {
+		for _, itf := range i.Interfaces {
+			c.checkInterface(itf)
+		}
+	}
+	return c.findings
+}
+
+// checker accumulates the findings in a file.
+type checker struct {
+	file     string
+	findings []Finding
+}
+
+func (c *checker) report(location, rule, format string, args ...interface{}) {
+	c.findings = append(c.findings, Finding{
+		File:     c.file,
+		Location: location,
+		Rule:     rule,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+func (c *checker) checkInterface(itf introspect.Interface) {
+	if itf.DocString == "" {
+		c.report(itf.Name, RuleDocString, "interface has no doc string")
+	}
+	for _, m := range itf.Methods {
+		loc := itf.Name + "." + m.Name
+		if m.DocString == "" {
+			c.report(loc, RuleDocString, "method has no doc string")
+		}
+		for _, a := range m.Annotations {
+			if a.Name == "org.freedesktop.DBus.GLib.Async" {
+				c.report(loc, RuleKind, "use org.chromium.DBus.Method.Kind with value async instead of %s", a.Name)
+			}
+		}
+		for i, a := range m.Args {
+			c.checkArgName(loc, i, a.Name)
+			if v, ok := a.Annotation("org.chromium.DBus.Argument.ProtobufClass"); ok && !protobufClassRE.MatchString(v) {
+				c.report(loc, RuleProtobufClass, "protobuf class %q is not named as namespace::ClassName", v)
+			}
+		}
+	}
+	for _, s := range itf.Signals {
+		loc := itf.Name + "." + s.Name
+		if s.DocString == "" {
+			c.report(loc, RuleDocString, "signal has no doc string")
+		}
+		for i, a := range s.Args {
+			c.checkArgName(loc, i, a.Name)
+			if v, ok := a.Annotation("org.chromium.DBus.Argument.ProtobufClass"); ok && !protobufClassRE.MatchString(v) {
+				c.report(loc, RuleProtobufClass, "protobuf class %q is not named as namespace::ClassName", v)
+			}
+		}
+	}
+	for _, p := range itf.Properties {
+		if p.DocString == "" {
+			c.report(itf.Name+"."+p.Name, RuleDocString, "property has no doc string")
+		}
+	}
+}
+
+// checkArgName reports the argument at index i of the member at loc if its
+// name is empty or not in snake_case.
+func (c *checker) checkArgName(loc string, i int, name string) {
+	switch {
+	case name == "":
+		c.report(loc, RuleArgName, "argument %d has no name", i)
+	case !argNameRE.MatchString(name):
+		c.report(loc, RuleArgName, "argument %q is not named in snake_case", name)
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright 2022 The ChromiumOS Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package lint_test
+
+import (
+	"testing"
+
+	"go.chromium.org/chromiumos/dbusbindings/introspect"
+	"go.chromium.org/chromiumos/dbusbindings/lint"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCheck(t *testing.T) {
+	const input = `<node>
+  <interface name="org.chromium.Test">
+    <tp:docstring>Test interface.</tp:docstring>
+    <method name="Ping">
+      <tp:docstring>Pings.</tp:docstring>
+      <arg name="request" type="ay" direction="in">
+        <annotation name="org.chromium.DBus.Argument.ProtobufClass" value="test::PingRequest"/>
+      </arg>
+      <arg name="response" type="ay" direction="out">
+        <annotation name="org.chromium.DBus.Argument.ProtobufClass" value="PingResponse"/>
+      </arg>
+    </method>
+    <method name="Frobinate">
+      <arg name="fooBar" type="i" direction="in"/>
+      <arg type="s" direction="out"/>
+      <annotation name="org.freedesktop.DBus.GLib.Async" value="true"/>
+    </method>
+    <signal name="Changed">
+      <tp:docstring>Sent on changes.</tp:docstring>
+      <arg name="value_2" type="i"/>
+    </signal>
+    <property name="Level" type="i" access="read"/>
+  </interface>
+</node>
+`
+	i, err := introspect.Parse([]byte(input))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	got := lint.Check("test.xml", i.Flatten())
+	want := []lint.Finding{{
+		File:     "test.xml",
+		Location: "org.chromium.Test.Ping",
+		Rule:     lint.RuleProtobufClass,
+		Message:  `protobuf class "PingResponse" is not named as namespace::ClassName`,
+	}, {
+		File:     "test.xml",
+		Location: "org.chromium.Test.Frobinate",
+		Rule:     lint.RuleDocString,
+		Message:  "method has no doc string",
+	}, {
+		File:     "test.xml",
+		Location: "org.chromium.Test.Frobinate",
+		Rule:     lint.RuleKind,
+		Message:  "use org.chromium.DBus.Method.Kind with value async instead of org.freedesktop.DBus.GLib.Async",
+	}, {
+		File:     "test.xml",
+		Location: "org.chromium.Test.Frobinate",
+		Rule:     lint.RuleArgName,
+		Message:  `argument "fooBar" is not named in snake_case`,
+	}, {
+		File:     "test.xml",
+		Location: "org.chromium.Test.Frobinate",
+		Rule:     lint.RuleArgName,
+		Message:  "argument 1 has no name",
+	}, {
+		File:     "test.xml",
+		Location: "org.chromium.Test.Level",
+		Rule:     lint.RuleDocString,
+		Message:  "property has no doc string",
+	}}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("Check failed (-got +want):\n%s", diff)
+	}
+}
@@ -0,0 +1,264 @@
+// Copyright 2022 The ChromiumOS Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+// Package repl implements an interactive shell calling the methods of the
+// introspected interfaces on the bus with gdbus call, with the arguments
+// parsed against their D-Bus types, for the manual testing of the services
+// whose bindings are being developed.
+package repl
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+
+	"go.chromium.org/chromiumos/dbusbindings/dbustype"
+	"go.chromium.org/chromiumos/dbusbindings/generate/genutil"
+	"go.chromium.org/chromiumos/dbusbindings/introspect"
+)
+
+const helpText = `Commands:
+  methods                 list the methods with their arguments
+  dest NAME               call the methods of the service NAME
+  path OBJECT_PATH        call the methods of the nodes without names at OBJECT_PATH
+  METHOD ARG...           call METHOD, i.e. Interface.Method or Method if unique
+  help                    print this help
+  quit                    exit
+The arguments are in the GVariant text format, e.g. "['a', 'b']" for as, where
+strings of one word may be unquoted, and the values of variants are typed with
+@ unless they are booleans, int32, doubles or strings, e.g. <@x 1>.
+`
+
+// Options configures the REPL.
+type Options struct {
+	// ServiceName is the initial destination of the calls.
+	ServiceName string
+	// Session makes the calls on the session bus instead of the system bus.
+	Session bool
+	// Run runs gdbus with args and returns its output. The gdbus executable
+	// is run if nil.
+	Run func(args []string) (string, error)
+}
+
+// method is a method callable in the REPL.
+type method struct {
+	Interface string
+	// ObjectPath is the name of the node, or empty if it has none.
+	ObjectPath string
+	introspect.Method
+}
+
+// REPL is the state of the shell.
+type REPL struct {
+	o          Options
+	methods    []method
+	objectPath string
+}
+
+// New returns the REPL calling the methods of the interfaces in introspects.
+func New(introspects []introspect.Introspection, o Options) *REPL {
+	if o.Run == nil {
+		o.Run = runGDBus
+	}
+	r := &REPL{o: o}
+	for _, is := range introspects {
+		for _, itf := range is.Interfaces {
+			for _, m := range itf.Methods {
+				r.methods = append(r.methods, method{Interface: itf.Name, ObjectPath: is.Name, Method: m})
+			}
+		}
+	}
+	return r
+}
+
+// runGDBus runs the gdbus executable with args, and returns its output.
+func runGDBus(args []string) (string, error) {
+	out, err := exec.Command("gdbus", args...).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("%v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return string(out), nil
+}
+
+// splitArgs splits the command line into the command and its arguments,
+// separated by white spaces outside of quotes and brackets.
+func splitArgs(line string) ([]string, error) {
+	var ret []string
+	var cur strings.Builder
+	depth := 0
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && i+1 < len(line) {
+				cur.WriteByte(c)
+				i++
+				c = line[i]
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case strings.IndexByte("[({<", c) >= 0:
+			depth++
+		case strings.IndexByte("])}>", c) >= 0:
+			depth--
+		case (c == ' ' || c == '\t') && depth == 0:
+			if cur.Len() > 0 {
+				ret = append(ret, cur.String())
+				cur.Reset()
+			}
+			continue
+		}
+		cur.WriteByte(c)
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated string")
+	}
+	if depth != 0 {
+		return nil, fmt.Errorf("unbalanced brackets")
+	}
+	if cur.Len() > 0 {
+		ret = append(ret, cur.String())
+	}
+	return ret, nil
+}
+
+// signature returns the method name followed by its typed "in" arguments.
+func signature(m method) string {
+	var params []string
+	for i, a := range m.InputArguments() {
+		name := a.Name
+		if name == "" {
+			name = genutil.ArgName("arg", "", i+1)
+		}
+		d, err := dbustype.Describe(string(a.Type))
+		if err != nil {
+			d = string(a.Type)
+		}
+		params = append(params, d+" "+name)
+	}
+	return fmt.Sprintf("%s.%s(%s)", m.Interface, m.Name, strings.Join(params, ", "))
+}
+
+// findMethod returns the method named name, i.e. Interface.Method, or Method
+// if no other interface has a method of the same name.
+func (r *REPL) findMethod(name string) (method, error) {
+	var found []method
+	for _, m := range r.methods {
+		if name == m.Interface+"."+m.Name || name == m.Name {
+			found = append(found, m)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return method{}, fmt.Errorf("unknown command or method %s; try help", name)
+	case 1:
+		return found[0], nil
+	}
+	var names []string
+	for _, m := range found {
+		names = append(names, m.Interface+"."+m.Name)
+	}
+	return method{}, fmt.Errorf("ambiguous method %s: %s", name, strings.Join(names, ", "))
+}
+
+// call calls the method name with args, and returns the output of gdbus.
+func (r *REPL) call(name string, args []string) (string, error) {
+	m, err := r.findMethod(name)
+	if err != nil {
+		return "", err
+	}
+	in := m.InputArguments()
+	if len(args) != len(in) {
+		return "", fmt.Errorf("%s takes %d arguments, got %d", signature(m), len(in), len(args))
+	}
+	if r.o.ServiceName == "" {
+		return "", fmt.Errorf("no service name; set it with dest")
+	}
+	objectPath := m.ObjectPath
+	if objectPath == "" {
+		objectPath = r.objectPath
+	}
+	if objectPath == "" {
+		return "", fmt.Errorf("no object path for %s; set it with path", m.Interface)
+	}
+	bus := "--system"
+	if r.o.Session {
+		bus = "--session"
+	}
+	gdbusArgs := []string{"call", bus, "--dest", r.o.ServiceName, "--object-path", objectPath,
+		"--method", m.Interface + "." + m.Name}
+	for i, a := range in {
+		name := a.Name
+		if name == "" {
+			name = genutil.ArgName("arg", "", i+1)
+		}
+		v, err := dbustype.ParseGVariant(string(a.Type), args[i])
+		if err != nil {
+			return "", fmt.Errorf("argument %s: %v", name, err)
+		}
+		gdbusArgs = append(gdbusArgs, v)
+	}
+	return r.o.Run(gdbusArgs)
+}
+
+// Execute runs the command line, and returns its output and whether the
+// REPL should exit.
+func (r *REPL) Execute(line string) (string, bool, error) {
+	args, err := splitArgs(line)
+	if err != nil || len(args) == 0 {
+		return "", false, err
+	}
+	switch args[0] {
+	case "help":
+		return helpText, false, nil
+	case "quit", "exit":
+		return "", true, nil
+	case "methods":
+		var b strings.Builder
+		for _, m := range r.methods {
+			fmt.Fprintln(&b, signature(m))
+		}
+		return b.String(), false, nil
+	case "dest", "path":
+		if len(args) != 2 {
+			return "", false, fmt.Errorf("usage: %s VALUE", args[0])
+		}
+		if args[0] == "dest" {
+			r.o.ServiceName = args[1]
+		} else {
+			r.objectPath = args[1]
+		}
+		return "", false, nil
+	}
+	out, err := r.call(args[0], args[1:])
+	return out, false, err
+}
+
+// Run reads the command lines from in, and writes the prompts, the outputs
+// and the errors to out, until in ends or the quit command. The errors of
+// the commands are reported without ending the REPL.
+func (r *REPL) Run(in io.Reader, out io.Writer) error {
+	s := bufio.NewScanner(in)
+	for {
+		fmt.Fprint(out, "> ")
+		if !s.Scan() {
+			fmt.Fprintln(out)
+			return s.Err()
+		}
+		ret, quit, err := r.Execute(s.Text())
+		if err != nil {
+			fmt.Fprintf(out, "Error: %v\n", err)
+			continue
+		}
+		fmt.Fprint(out, ret)
+		if quit {
+			return nil
+		}
+	}
+}
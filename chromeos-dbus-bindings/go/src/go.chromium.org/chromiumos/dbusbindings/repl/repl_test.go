@@ -0,0 +1,152 @@
+// Copyright 2022 The ChromiumOS Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package repl
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"go.chromium.org/chromiumos/dbusbindings/introspect"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var testIntrospects = []introspect.Introspection{{
+	Name: "/org/chromium/Test",
+	Interfaces: []introspect.Interface{{
+		Name: "org.chromium.Test",
+		Methods: []introspect.Method{{
+			Name: "Scan",
+			Args: []introspect.MethodArg{
+				{Name: "names", Type: "as", Direction: "in"},
+				{Type: "a{sv}", Direction: "in"},
+				{Name: "count", Type: "i", Direction: "out"},
+			},
+		}, {
+			Name: "Ping",
+		}},
+	}},
+}, {
+	Interfaces: []introspect.Interface{{
+		Name: "org.chromium.Other",
+		Methods: []introspect.Method{{
+			Name: "Ping",
+		}},
+	}},
+}}
+
+func TestSplitArgs(t *testing.T) {
+	got, err := splitArgs(` Scan  [a, 'b c'] {'x': <@x 1>}  "it's" `)
+	if err != nil {
+		t.Fatalf("splitArgs got error, want nil: %v", err)
+	}
+	want := []string{"Scan", "[a, 'b c']", "{'x': <@x 1>}", `"it's"`}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("splitArgs failed (-got +want):\n%s", diff)
+	}
+	for _, line := range []string{"Scan [a", "Scan 'a"} {
+		if _, err := splitArgs(line); err == nil {
+			t.Errorf("splitArgs(%q) unexpectedly succeeded", line)
+		}
+	}
+}
+
+func TestExecute(t *testing.T) {
+	var calls [][]string
+	r := New(testIntrospects, Options{
+		ServiceName: "org.chromium.TestService",
+		Run: func(args []string) (string, error) {
+			calls = append(calls, args)
+			return "(1,)\n", nil
+		},
+	})
+
+	out, _, err := r.Execute("methods")
+	if err != nil {
+		t.Fatalf("Execute(methods) got error, want nil: %v", err)
+	}
+	const wantMethods = `org.chromium.Test.Scan(array of string names, dict<string, variant> arg_2)
+org.chromium.Test.Ping()
+org.chromium.Other.Ping()
+`
+	if diff := cmp.Diff(out, wantMethods); diff != "" {
+		t.Errorf("Execute(methods) failed (-got +want):\n%s", diff)
+	}
+
+	if out, _, err := r.Execute("Scan [a, 'b c'] {x: <1>}"); err != nil || out != "(1,)\n" {
+		t.Errorf("Execute(Scan) = %q, %v; want %q", out, err, "(1,)\n")
+	}
+	if _, _, err := r.Execute("path /org/chromium/Other"); err != nil {
+		t.Errorf("Execute(path) got error, want nil: %v", err)
+	}
+	if _, _, err := r.Execute("org.chromium.Other.Ping"); err != nil {
+		t.Errorf("Execute(org.chromium.Other.Ping) got error, want nil: %v", err)
+	}
+	want := [][]string{{
+		"call", "--system", "--dest", "org.chromium.TestService", "--object-path", "/org/chromium/Test",
+		"--method", "org.chromium.Test.Scan", "['a', 'b c']", "{'x': <@i 1>}",
+	}, {
+		"call", "--system", "--dest", "org.chromium.TestService", "--object-path", "/org/chromium/Other",
+		"--method", "org.chromium.Other.Ping",
+	}}
+	if diff := cmp.Diff(calls, want); diff != "" {
+		t.Errorf("Execute made unexpected calls (-got +want):\n%s", diff)
+	}
+
+	if _, quit, err := r.Execute("quit"); err != nil || !quit {
+		t.Errorf("Execute(quit) = %t, %v; want true, nil", quit, err)
+	}
+}
+
+func TestExecuteFailures(t *testing.T) {
+	r := New(testIntrospects, Options{
+		Run: func(args []string) (string, error) {
+			t.Errorf("Unexpected call %v", args)
+			return "", nil
+		},
+	})
+	cases := []struct {
+		line, want string
+	}{
+		{"Frobinate", "unknown command or method Frobinate; try help"},
+		{"Ping", "ambiguous method Ping: org.chromium.Test.Ping, org.chromium.Other.Ping"},
+		{"Scan [a]", "org.chromium.Test.Scan(array of string names, dict<string, variant> arg_2) takes 2 arguments, got 1"},
+		{"Scan [a] {}", "no service name; set it with dest"},
+		{"dest org.chromium.TestService", ""},
+		{"Scan [1 {}", "unbalanced brackets"},
+		{"Scan [a b] {}", `argument names: at offset 3: ',' expected`},
+		{"org.chromium.Other.Ping", "no object path for org.chromium.Other; set it with path"},
+	}
+	for _, tc := range cases {
+		_, _, err := r.Execute(tc.line)
+		if tc.want == "" {
+			if err != nil {
+				t.Errorf("Execute(%q) got error, want nil: %v", tc.line, err)
+			}
+		} else if err == nil || err.Error() != tc.want {
+			t.Errorf("Execute(%q) got error %v, want %q", tc.line, err, tc.want)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	r := New(testIntrospects, Options{
+		ServiceName: "org.chromium.TestService",
+		Run: func(args []string) (string, error) {
+			return "()\n", nil
+		},
+	})
+	out := new(bytes.Buffer)
+	if err := r.Run(strings.NewReader("Frobinate\norg.chromium.Test.Ping\nquit\nPing\n"), out); err != nil {
+		t.Fatalf("Run got error, want nil: %v", err)
+	}
+	const want = `> Error: unknown command or method Frobinate; try help
+> ()
+> `
+	if diff := cmp.Diff(out.String(), want); diff != "" {
+		t.Errorf("Run failed (-got +want):\n%s", diff)
+	}
+}
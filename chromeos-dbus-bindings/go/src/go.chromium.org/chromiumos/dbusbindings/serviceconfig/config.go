@@ -6,9 +6,16 @@
 package serviceconfig
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"go.chromium.org/chromiumos/dbusbindings/dbustype"
 )
 
 // ObjectManagerConfig is a way to configure the object manager class generation.
@@ -24,6 +31,125 @@ type ObjectManagerConfig struct {
 	ObjectPath string `json:"object_path"`
 }
 
+// ClientFactoryConfig is a way to configure the client factory class generation.
+type ClientFactoryConfig struct {
+	// Name is the dotted name of the client factory class, which creates
+	// the proxies of the interfaces at their well-known object paths.
+	// If empty, it is derived from the service name.
+	Name string `json:"name"`
+}
+
+// PolicyConfig is a way to configure the generation of the D-Bus policy and
+// the D-Bus service activation file.
+type PolicyConfig struct {
+	// User is the user the service runs as, which is allowed to own the
+	// service name.
+	User string `json:"user"`
+	// ClientUsers are the users allowed to call the methods of the service.
+	// If empty, only root is allowed.
+	ClientUsers []string `json:"client_users"`
+	// Exec is the command launching the service on D-Bus activation.
+	// If empty, "/bin/false" is used, i.e. the service is not activatable
+	// and must be started by the init system.
+	Exec string `json:"exec"`
+	// SystemdService is the systemd unit D-Bus activation is delegated to.
+	SystemdService string `json:"systemd_service"`
+}
+
+// ResilientProxyConfig is a way to configure the generation of the proxy
+// wrappers retrying the method calls which fail with transient D-Bus errors.
+type ResilientProxyConfig struct {
+	// MaxAttempts is the maximum number of attempts of a method call,
+	// including the first one. If zero, 3 is used.
+	MaxAttempts int `json:"max_attempts"`
+	// InitialBackoffMs is the delay before the first retry in milliseconds,
+	// which is doubled for each following retry. If zero, 100 is used.
+	InitialBackoffMs int `json:"initial_backoff_ms"`
+	// MaxBackoffMs is the upper bound of the delay between retries in
+	// milliseconds. If zero, 5000 is used.
+	MaxBackoffMs int `json:"max_backoff_ms"`
+}
+
+// TypeMapping maps the D-Bus method and signal arguments matching Signature
+// and ArgName to a custom C++ type.
+type TypeMapping struct {
+	// Signature is the D-Bus type of the arguments, e.g. "au". If empty, the
+	// arguments of any type match.
+	Signature string `json:"signature"`
+	// ArgName is the name of the arguments, e.g. "ip_addresses". If empty,
+	// the arguments of any name match.
+	ArgName string `json:"arg_name"`
+	// CppType is the C++ type the arguments are rendered as, e.g.
+	// "std::vector<net_base::IPAddress>".
+	CppType string `json:"cpp_type"`
+	// Header is the header declaring CppType and the
+	// brillo::dbus_utils::DBusType specialization (de)serializing it,
+	// e.g. "<net-base/ip_address_dbus.h>". If empty, no header is included.
+	Header string `json:"header"`
+}
+
+// NamingStyle selects how generated C++ accessors and parameters are named.
+type NamingStyle string
+
+const (
+	// NamingStyleSnakeCase generates snake_case() property accessors and
+	// in_arg/out_arg parameter names. This is the default.
+	NamingStyleSnakeCase NamingStyle = "snake_case"
+
+	// NamingStyleCamelCase generates CamelCase() property accessors and
+	// inArg/outArg parameter names.
+	NamingStyleCamelCase NamingStyle = "camelCase"
+)
+
+// APILevel selects the libchrome and brillo APIs used by the generated code,
+// so that the generator can target older ChromeOS branches.
+type APILevel string
+
+const (
+	// APILevelLatest uses the latest APIs. This is the default.
+	APILevelLatest APILevel = "latest"
+
+	// APILevelLegacyHeaders includes the callback headers from base/ instead
+	// of base/functional/, for the branches predating their move.
+	APILevelLegacyHeaders APILevel = "legacy_headers"
+
+	// APILevelLegacyCallbacks additionally uses base::Bind, base::Callback and
+	// base::Closure instead of their once and repeating variants.
+	APILevelLegacyCallbacks APILevel = "legacy_callbacks"
+)
+
+// PropertyMode selects how the generated proxies of an interface fetch its
+// properties.
+type PropertyMode string
+
+const (
+	// PropertyModeObjectManager fetches the properties through the object
+	// manager. This is the default if object_manager is specified.
+	PropertyModeObjectManager PropertyMode = "object_manager"
+
+	// PropertyModePropertySet makes each proxy fetch the properties with its
+	// own dbus::PropertySet. This is the default otherwise.
+	PropertyModePropertySet PropertyMode = "property_set"
+)
+
+// InterfaceConfig overrides the global settings of Config for a single D-Bus
+// interface.
+type InterfaceConfig struct {
+	// ObjectPath is the object path the proxies of the interface are created
+	// at, instead of the name of the introspection node declaring it.
+	ObjectPath string `json:"object_path"`
+	// Properties is the way the proxies of the interface fetch its
+	// properties. If omitted (empty), the default of the global settings is
+	// used.
+	Properties PropertyMode `json:"properties"`
+	// DisableBlockingCalls overrides the global disable_blocking_calls for
+	// the interface. If omitted (nil), the global setting is used.
+	DisableBlockingCalls *bool `json:"disable_blocking_calls"`
+	// Namespace is the C++ namespace the classes of the interface are put in,
+	// as in namespace_overrides.
+	Namespace string `json:"namespace"`
+}
+
 // Config contains a way to configure header generations.
 type Config struct {
 	// ServiceName is a D-Bus service name to be used when constructing proxy objects.
@@ -32,21 +158,170 @@ type Config struct {
 	ServiceName string `json:"service_name"`
 	// ObjectManger contains the settings of ObjectManager outputs.
 	ObjectManager *ObjectManagerConfig `json:"object_manager"`
+	// ClientFactory contains the settings of the client factory output.
+	// If omitted (nil), no client factory is generated.
+	ClientFactory *ClientFactoryConfig `json:"client_factory"`
+	// NamingStyle is the naming style of generated property accessors,
+	// argument names and callback parameter comments. If omitted (empty),
+	// NamingStyleSnakeCase is used.
+	NamingStyle NamingStyle `json:"naming_style"`
+	// UseCoroutines enables the generation of C++20 coroutine *Await()
+	// wrappers around the asynchronous proxy method calls.
+	UseCoroutines bool `json:"use_coroutines"`
+	// MoveProtobufResponses passes protobuf output arguments to the success
+	// callbacks of asynchronous proxy method calls as rvalue references
+	// instead of const references, so that large responses can be taken
+	// without a copy.
+	MoveProtobufResponses bool `json:"move_protobuf_responses"`
+	// InstrumentProxies adds TRACE_EVENT and VLOG instrumentation to the
+	// generated proxy methods, recording the method name, the duration and
+	// whether the call succeeded.
+	InstrumentProxies bool `json:"instrument_proxies"`
+	// ReportMetrics adds an optional chromeos_dbus_bindings::MetricsRecorder
+	// parameter to the constructors of the generated proxies, which receives
+	// the result and the latency of each proxy method call.
+	ReportMetrics bool `json:"report_metrics"`
+	// SequenceCheckers adds a SEQUENCE_CHECKER to the generated proxies, and
+	// checks that the proxy methods are called on the sequence the proxy is
+	// created on.
+	SequenceCheckers bool `json:"sequence_checkers"`
+	// RestartCallbacks adds SetServiceAvailableCallback and
+	// SetNameOwnerChangedCallback to the generated proxies, so that the
+	// clients can tell when the service starts and restarts. The properties
+	// are fetched again when the service restarts.
+	RestartCallbacks bool `json:"restart_callbacks"`
+	// CredentialQueries adds GetConnectionCredentials to the generated
+	// proxies, which queries the bus for the Unix user ID and the process ID
+	// of the owner of the service name, so that the security-sensitive
+	// clients can check who they talk to.
+	CredentialQueries bool `json:"credential_queries"`
+	// MethodTimeouts maps the methods, as "<interface name>.<method name>",
+	// e.g. "org.chromium.Foo.Frobinate", to their timeouts in milliseconds,
+	// which the generated proxies use instead of the default timeout. It lets
+	// the known slow methods have longer timeouts without changing the
+	// introspection XML.
+	MethodTimeouts map[string]int `json:"method_timeouts"`
+	// ProxyFactories adds a static Create() to the abstract proxy interfaces,
+	// so that the clients create the proxies without naming the concrete
+	// classes, and the tests can substitute mocks with SetFactoryForTesting().
+	ProxyFactories bool `json:"proxy_factories"`
+	// ValidateVariantTypes makes the generated proxy methods check that the
+	// variant input arguments annotated with a closed list of
+	// org.chromium.DBus.Argument.VariantTypes hold one of the listed types
+	// before calling the method.
+	ValidateVariantTypes bool `json:"validate_variant_types"`
+	// ExpectedResults generates, for the proxy methods with a single output
+	// argument, blocking overloads returning the argument or the error as
+	// base::expected<T, brillo::ErrorPtr> instead of taking an output pointer
+	// and a brillo::ErrorPtr*.
+	ExpectedResults bool `json:"expected_results"`
+	// DisableBlockingCalls omits the blocking variants of the proxy methods,
+	// and the helpers built on them, from the generated proxies, leaving only
+	// the asynchronous ones.
+	DisableBlockingCalls bool `json:"disable_blocking_calls"`
+	// TargetAPILevel is the level of the libchrome and brillo APIs used by
+	// the generated C++ code. If omitted (empty), APILevelLatest is used.
+	TargetAPILevel APILevel `json:"target_api_level"`
+	// NamespaceOverrides maps D-Bus interface names to the C++ namespaces the
+	// generated classes are put in, e.g. "wpa::supplicant" for
+	// "fi.w1.wpa_supplicant1.Interface". Interfaces not listed here are put in
+	// the namespaces mirroring their names.
+	NamespaceOverrides map[string]string `json:"namespace_overrides"`
+	// DefaultNamespace is the C++ namespace, e.g. "shill", the classes of the
+	// interfaces without namespace parts in their names, e.g. "Manager", are
+	// put in. The generator fails on such interfaces if this is omitted,
+	// instead of generating them into the global namespace.
+	DefaultNamespace string `json:"default_namespace"`
+	// OutputFiles maps D-Bus interface names to the file names of the
+	// headers they are generated into when the output is split per
+	// interface. Interfaces mapped to the same file share the header, and
+	// interfaces not listed here are generated into "<interface name>.h".
+	OutputFiles map[string]string `json:"output_files"`
+	// Policy contains the settings of the D-Bus policy and the D-Bus service
+	// activation file outputs.
+	Policy *PolicyConfig `json:"policy"`
+	// ResilientProxy contains the settings of the ...ResilientProxy wrappers
+	// generated in the proxy output. If omitted (nil), no wrapper is
+	// generated.
+	ResilientProxy *ResilientProxyConfig `json:"resilient_proxy"`
+	// QueueingProxy generates a ...QueueingProxy wrapper next to each proxy,
+	// which queues the asynchronous method calls until the service is
+	// available, so that the clients started before the service do not need
+	// to wait for it themselves.
+	QueueingProxy bool `json:"queueing_proxy"`
+	// TypeMappings renders the method and signal arguments as custom C++
+	// types. The first mapping matching an argument applies, and the
+	// arguments which have an annotation, e.g. a protobuf class, keep their
+	// type.
+	TypeMappings []TypeMapping `json:"type_mappings"`
+	// TargetVersion is the version of the service API to generate. The
+	// methods, signals and properties whose org.chromium.DBus.MinVersion
+	// annotation is greater than it are omitted from all the outputs.
+	// If omitted (zero), all the members are generated.
+	TargetVersion int `json:"target_version"`
+	// Interfaces maps D-Bus interface names to the settings overriding the
+	// global ones above for the interface. Interfaces not listed here use
+	// the global settings.
+	Interfaces map[string]InterfaceConfig `json:"interfaces"`
+}
+
+// ForInterface returns the config applying to the interface named name, i.e.
+// c with the overrides of c.Interfaces[name] applied. The namespace and the
+// object path overrides are applied to the whole config and to the
+// introspections respectively, so they are not reflected here.
+func (c Config) ForInterface(name string) Config {
+	ic, ok := c.Interfaces[name]
+	if !ok {
+		return c
+	}
+	if ic.Properties == PropertyModePropertySet {
+		c.ObjectManager = nil
+	}
+	if ic.DisableBlockingCalls != nil {
+		c.DisableBlockingCalls = *ic.DisableBlockingCalls
+	}
+	return c
 }
 
 // Load reads and parses a file at path into Config.
+// Files with a .yaml or .yml extension are parsed as the YAML subset described
+// in yaml.go, and any other file is parsed as JSON.
 func Load(path string) (*Config, error) {
 	b, err := ioutil.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return parseYAML(b)
+	}
 	return parse(b)
 }
 
+// parseYAML parses the byte array in the YAML subset, and returns the config
+// data.
+func parseYAML(b []byte) (*Config, error) {
+	j, err := yamlToJSON(b)
+	if err != nil {
+		return nil, fmt.Errorf("invalid YAML subset: %v", err)
+	}
+	return parse(j)
+}
+
 // Parse parses the JSON byte array, and returns the config data.
 func parse(b []byte) (*Config, error) {
 	var c Config
-	if err := json.Unmarshal(b, &c); err != nil {
+	d := json.NewDecoder(bytes.NewReader(b))
+	// Reject unknown keys so that typos in the config are reported instead
+	// of being silently ignored.
+	d.DisallowUnknownFields()
+	if err := d.Decode(&c); err != nil {
+		return nil, err
+	}
+	if d.More() {
+		return nil, errors.New("unexpected data after the top-level object")
+	}
+	if err := validate(&c); err != nil {
 		return nil, err
 	}
 
@@ -59,5 +334,195 @@ func parse(b []byte) (*Config, error) {
 		c.ObjectManager.Name = c.ServiceName + ".ObjectManager"
 	}
 
+	if c.ClientFactory != nil {
+		// The client factory creates the proxies of the service, so the
+		// service name is required.
+		if c.ServiceName == "" {
+			return nil, errors.New("client_factory requires service_name")
+		}
+		if c.ClientFactory.Name == "" {
+			c.ClientFactory.Name = c.ServiceName + ".ClientFactory"
+		}
+	}
+
+	if c.Policy != nil {
+		// The policy is about the ownership of the service name.
+		if c.ServiceName == "" {
+			return nil, errors.New("policy requires service_name")
+		}
+		if c.Policy.User == "" {
+			return nil, errors.New("policy.user is not specified")
+		}
+	}
+
+	// The namespaces of the interfaces are merged into the overrides, so
+	// that every output places the classes consistently.
+	for name, ic := range c.Interfaces {
+		if ic.Namespace == "" {
+			continue
+		}
+		if ns, ok := c.NamespaceOverrides[name]; ok && ns != ic.Namespace {
+			return nil, fmt.Errorf("interfaces: namespace %q of %s conflicts with namespace_overrides %q", ic.Namespace, name, ns)
+		}
+		if c.NamespaceOverrides == nil {
+			c.NamespaceOverrides = make(map[string]string)
+		}
+		c.NamespaceOverrides[name] = ic.Namespace
+	}
+
+	if c.ResilientProxy != nil {
+		if c.ResilientProxy.MaxAttempts == 0 {
+			c.ResilientProxy.MaxAttempts = 3
+		}
+		if c.ResilientProxy.InitialBackoffMs == 0 {
+			c.ResilientProxy.InitialBackoffMs = 100
+		}
+		if c.ResilientProxy.MaxBackoffMs == 0 {
+			c.ResilientProxy.MaxBackoffMs = 5000
+		}
+		if c.ResilientProxy.MaxBackoffMs < c.ResilientProxy.InitialBackoffMs {
+			return nil, errors.New("resilient_proxy.max_backoff_ms is less than initial_backoff_ms")
+		}
+	}
+
 	return &c, nil
 }
+
+// busNameRE matches a well-known D-Bus bus name, e.g. "org.chromium.Service".
+var busNameRE = regexp.MustCompile(`^[A-Za-z_-][A-Za-z0-9_-]*(\.[A-Za-z_-][A-Za-z0-9_-]*)+$`)
+
+// cppNameSpaceRE matches a qualified C++ namespace, e.g. "wpa::supplicant".
+var cppNameSpaceRE = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(::[A-Za-z_][A-Za-z0-9_]*)*$`)
+
+// userNameRE matches a user name, e.g. "debugd".
+var userNameRE = regexp.MustCompile(`^[a-z_][a-z0-9_-]*$`)
+
+// objectPathRE matches a D-Bus object path, e.g. "/org/chromium/Service".
+var objectPathRE = regexp.MustCompile(`^(/|(/[A-Za-z0-9_]+)+)$`)
+
+// validate verifies that the config does not contain invalid values.
+func validate(c *Config) error {
+	if c.ServiceName != "" && !busNameRE.MatchString(c.ServiceName) {
+		return fmt.Errorf("service_name: %q is not a valid D-Bus service name", c.ServiceName)
+	}
+	if c.ObjectManager != nil && c.ObjectManager.Name != "" && !busNameRE.MatchString(c.ObjectManager.Name) {
+		return fmt.Errorf("object_manager.name: %q is not a valid dotted name", c.ObjectManager.Name)
+	}
+	if c.ClientFactory != nil && c.ClientFactory.Name != "" && !busNameRE.MatchString(c.ClientFactory.Name) {
+		return fmt.Errorf("client_factory.name: %q is not a valid dotted name", c.ClientFactory.Name)
+	}
+	for name, ns := range c.NamespaceOverrides {
+		if !busNameRE.MatchString(name) {
+			return fmt.Errorf("namespace_overrides: %q is not a valid dotted name", name)
+		}
+		if !cppNameSpaceRE.MatchString(ns) {
+			return fmt.Errorf("namespace_overrides: %q is not a valid C++ namespace", ns)
+		}
+	}
+	for name, ms := range c.MethodTimeouts {
+		if !busNameRE.MatchString(name) {
+			return fmt.Errorf("method_timeouts: %q is not a valid dotted name", name)
+		}
+		if ms <= 0 {
+			return fmt.Errorf("method_timeouts: %d of %s is not positive", ms, name)
+		}
+	}
+	if c.DefaultNamespace != "" && !cppNameSpaceRE.MatchString(c.DefaultNamespace) {
+		return fmt.Errorf("default_namespace: %q is not a valid C++ namespace", c.DefaultNamespace)
+	}
+	for name, file := range c.OutputFiles {
+		if !busNameRE.MatchString(name) {
+			return fmt.Errorf("output_files: %q is not a valid dotted name", name)
+		}
+		clean := filepath.Clean(file)
+		if file == "" || filepath.IsAbs(file) || clean == ".." || strings.HasPrefix(clean, "../") {
+			return fmt.Errorf("output_files: %q is not a relative file name", file)
+		}
+	}
+	if c.Policy != nil {
+		if c.Policy.User != "" && !userNameRE.MatchString(c.Policy.User) {
+			return fmt.Errorf("policy.user: %q is not a valid user name", c.Policy.User)
+		}
+		for _, u := range c.Policy.ClientUsers {
+			if !userNameRE.MatchString(u) {
+				return fmt.Errorf("policy.client_users: %q is not a valid user name", u)
+			}
+		}
+		if c.Policy.Exec != "" && !filepath.IsAbs(c.Policy.Exec) {
+			return fmt.Errorf("policy.exec: %q is not an absolute path", c.Policy.Exec)
+		}
+	}
+	if c.ResilientProxy != nil {
+		if c.ResilientProxy.MaxAttempts < 0 {
+			return fmt.Errorf("resilient_proxy.max_attempts: %d is negative", c.ResilientProxy.MaxAttempts)
+		}
+		if c.ResilientProxy.InitialBackoffMs < 0 {
+			return fmt.Errorf("resilient_proxy.initial_backoff_ms: %d is negative", c.ResilientProxy.InitialBackoffMs)
+		}
+		if c.ResilientProxy.MaxBackoffMs < 0 {
+			return fmt.Errorf("resilient_proxy.max_backoff_ms: %d is negative", c.ResilientProxy.MaxBackoffMs)
+		}
+	}
+	if c.DisableBlockingCalls && c.ExpectedResults {
+		return errors.New("disable_blocking_calls: expected_results generates blocking calls")
+	}
+	if c.TargetVersion < 0 {
+		return fmt.Errorf("target_version: %d is negative", c.TargetVersion)
+	}
+	for _, m := range c.TypeMappings {
+		if m.Signature == "" && m.ArgName == "" {
+			return errors.New("type_mappings: either signature or arg_name is required")
+		}
+		if m.Signature != "" {
+			if _, err := dbustype.Parse(m.Signature); err != nil {
+				return fmt.Errorf("type_mappings: %q is not a single complete type: %v", m.Signature, err)
+			}
+		}
+		if strings.TrimSpace(m.CppType) == "" {
+			return fmt.Errorf("type_mappings: cpp_type is missing for %q %q", m.Signature, m.ArgName)
+		}
+	}
+	for name, ic := range c.Interfaces {
+		if !busNameRE.MatchString(name) {
+			return fmt.Errorf("interfaces: %q is not a valid dotted name", name)
+		}
+		if ic.ObjectPath != "" && !objectPathRE.MatchString(ic.ObjectPath) {
+			return fmt.Errorf("interfaces: object_path %q of %s is not a valid D-Bus object path", ic.ObjectPath, name)
+		}
+		switch ic.Properties {
+		case "", PropertyModePropertySet:
+		case PropertyModeObjectManager:
+			if c.ObjectManager == nil {
+				return fmt.Errorf("interfaces: properties %q of %s requires object_manager", ic.Properties, name)
+			}
+		default:
+			return fmt.Errorf("interfaces: unknown properties %q of %s, want %q or %q", ic.Properties, name, PropertyModeObjectManager, PropertyModePropertySet)
+		}
+		if ic.DisableBlockingCalls != nil && *ic.DisableBlockingCalls && c.ExpectedResults {
+			return fmt.Errorf("interfaces: disable_blocking_calls of %s: expected_results generates blocking calls", name)
+		}
+		if ic.Namespace != "" && !cppNameSpaceRE.MatchString(ic.Namespace) {
+			return fmt.Errorf("interfaces: namespace %q of %s is not a valid C++ namespace", ic.Namespace, name)
+		}
+	}
+	switch c.NamingStyle {
+	case "", NamingStyleSnakeCase, NamingStyleCamelCase:
+	default:
+		return fmt.Errorf("naming_style: unknown style %q, want %q or %q", c.NamingStyle, NamingStyleSnakeCase, NamingStyleCamelCase)
+	}
+	switch c.TargetAPILevel {
+	case "", APILevelLatest, APILevelLegacyHeaders:
+	case APILevelLegacyCallbacks:
+		// The instrumentation and the metrics chain the callbacks with
+		// Then(), which the legacy callbacks do not have.
+		if c.InstrumentProxies {
+			return fmt.Errorf("target_api_level: instrument_proxies is not supported with %q", c.TargetAPILevel)
+		}
+		if c.ReportMetrics {
+			return fmt.Errorf("target_api_level: report_metrics is not supported with %q", c.TargetAPILevel)
+		}
+	default:
+		return fmt.Errorf("target_api_level: unknown level %q, want %q, %q or %q", c.TargetAPILevel, APILevelLatest, APILevelLegacyHeaders, APILevelLegacyCallbacks)
+	}
+	return nil
+}
@@ -61,3 +61,386 @@ func TestParseFallbackObjectName(t *testing.T) {
 		t.Fatalf("Unexpected object_manager.name: got %q, want test.ServiceName.ObjectManager", c.ObjectManager.Name)
 	}
 }
+
+func TestParseUnknownField(t *testing.T) {
+	if _, err := parse([]byte(`{"service_nmae": "test.ServiceName"}`)); err == nil {
+		t.Fatal("Unexpected success of parse")
+	}
+}
+
+func TestParseInvalidServiceName(t *testing.T) {
+	for _, name := range []string{"NoDots", "test..ServiceName", "test.1Service", "test.Service!"} {
+		if _, err := parse([]byte(`{"service_name": "` + name + `"}`)); err == nil {
+			t.Errorf("Unexpected success of parse for service_name %q", name)
+		}
+	}
+}
+
+func TestParseYAML(t *testing.T) {
+	c, err := parseYAML([]byte(`
+# Service config for tests.
+service_name: test.ServiceName
+object_manager:
+  name: "test.ObjectManagerName"  # quoted
+  object_path: /test/object/Path
+`))
+	if err != nil {
+		t.Fatal("Unexpected failure of parseYAML: ", err)
+	}
+	if c.ServiceName != "test.ServiceName" {
+		t.Errorf("Unexpected service_name: got %q, want test.ServiceName", c.ServiceName)
+	}
+	if c.ObjectManager == nil {
+		t.Fatal("Unexpected object_manager: got nil, want non-nil")
+	}
+	if c.ObjectManager.Name != "test.ObjectManagerName" {
+		t.Errorf("Unexpected object_manager.name: got %q, want test.ObjectManagerName", c.ObjectManager.Name)
+	}
+	if c.ObjectManager.ObjectPath != "/test/object/Path" {
+		t.Errorf("Unexpected object_manager.object_path: got %q, want /test/object/Path", c.ObjectManager.ObjectPath)
+	}
+}
+
+func TestParseYAMLFailures(t *testing.T) {
+	cases := []string{
+		"service_name test.ServiceName",
+		"service_name: test.ServiceName\nservice_name: test.Other",
+		"object_manager:\n  name: a.b\n    object_path: /x",
+		"unknown_key: 1",
+	}
+	for _, tc := range cases {
+		if _, err := parseYAML([]byte(tc)); err == nil {
+			t.Errorf("Unexpected success of parseYAML for %q", tc)
+		}
+	}
+}
+
+func TestParseNamingStyle(t *testing.T) {
+	c, err := parse([]byte(`{"naming_style": "camelCase"}`))
+	if err != nil {
+		t.Fatal("Unexpected failure of parse: ", err)
+	}
+	if c.NamingStyle != NamingStyleCamelCase {
+		t.Errorf("Unexpected naming_style: got %q, want %q", c.NamingStyle, NamingStyleCamelCase)
+	}
+
+	if _, err := parse([]byte(`{"naming_style": "kebab-case"}`)); err == nil {
+		t.Fatal("Unexpected success of parse")
+	}
+}
+
+func TestParseTargetAPILevel(t *testing.T) {
+	c, err := parse([]byte(`{"target_api_level": "legacy_callbacks"}`))
+	if err != nil {
+		t.Fatal("Unexpected failure of parse: ", err)
+	}
+	if c.TargetAPILevel != APILevelLegacyCallbacks {
+		t.Errorf("Unexpected target_api_level: got %q, want %q", c.TargetAPILevel, APILevelLegacyCallbacks)
+	}
+
+	for _, tc := range []string{
+		`{"target_api_level": "ancient"}`,
+		`{"target_api_level": "legacy_callbacks", "instrument_proxies": true}`,
+		`{"target_api_level": "legacy_callbacks", "report_metrics": true}`,
+	} {
+		if _, err := parse([]byte(tc)); err == nil {
+			t.Errorf("Unexpected success of parse for %q", tc)
+		}
+	}
+}
+
+func TestParseUseCoroutines(t *testing.T) {
+	c, err := parseYAML([]byte("use_coroutines: true\n"))
+	if err != nil {
+		t.Fatal("Unexpected failure of parseYAML: ", err)
+	}
+	if !c.UseCoroutines {
+		t.Error("Unexpected use_coroutines: got false, want true")
+	}
+}
+
+func TestParseMoveProtobufResponses(t *testing.T) {
+	c, err := parse([]byte(`{"move_protobuf_responses": true}`))
+	if err != nil {
+		t.Fatal("Unexpected failure of parse: ", err)
+	}
+	if !c.MoveProtobufResponses {
+		t.Error("Unexpected move_protobuf_responses: got false, want true")
+	}
+}
+
+func TestParseDisableBlockingCalls(t *testing.T) {
+	c, err := parse([]byte(`{"disable_blocking_calls": true}`))
+	if err != nil {
+		t.Fatal("Unexpected failure of parse: ", err)
+	}
+	if !c.DisableBlockingCalls {
+		t.Error("Unexpected disable_blocking_calls: got false, want true")
+	}
+
+	if _, err := parse([]byte(`{"disable_blocking_calls": true, "expected_results": true}`)); err == nil {
+		t.Error("Unexpected success of parse with expected_results")
+	}
+}
+
+func TestParseNamespaceOverrides(t *testing.T) {
+	c, err := parse([]byte(`{"namespace_overrides": {"fi.w1.wpa_supplicant1.Interface": "wpa::supplicant"}}`))
+	if err != nil {
+		t.Fatal("Unexpected failure of parse: ", err)
+	}
+	if got := c.NamespaceOverrides["fi.w1.wpa_supplicant1.Interface"]; got != "wpa::supplicant" {
+		t.Errorf("Unexpected namespace_overrides: got %q, want %q", got, "wpa::supplicant")
+	}
+
+	for _, b := range []string{
+		`{"namespace_overrides": {"Interface": "wpa"}}`,
+		`{"namespace_overrides": {"fi.w1.wpa_supplicant1.Interface": ""}}`,
+		`{"namespace_overrides": {"fi.w1.wpa_supplicant1.Interface": "wpa.supplicant"}}`,
+		`{"namespace_overrides": {"fi.w1.wpa_supplicant1.Interface": "::wpa"}}`,
+	} {
+		if _, err := parse([]byte(b)); err == nil {
+			t.Errorf("Unexpected success of parse: %s", b)
+		}
+	}
+}
+
+func TestParseMethodTimeouts(t *testing.T) {
+	c, err := parse([]byte(`{"method_timeouts": {"org.chromium.Foo.Scan": 60000}}`))
+	if err != nil {
+		t.Fatal("Unexpected failure of parse: ", err)
+	}
+	if got := c.MethodTimeouts["org.chromium.Foo.Scan"]; got != 60000 {
+		t.Errorf("Unexpected method_timeouts: got %d, want %d", got, 60000)
+	}
+
+	for _, b := range []string{
+		`{"method_timeouts": {"Scan": 60000}}`,
+		`{"method_timeouts": {"org.chromium.Foo.Scan": 0}}`,
+		`{"method_timeouts": {"org.chromium.Foo.Scan": -1}}`,
+		`{"method_timeouts": {"org.chromium.Foo.Scan": "60000"}}`,
+	} {
+		if _, err := parse([]byte(b)); err == nil {
+			t.Errorf("Unexpected success of parse: %s", b)
+		}
+	}
+}
+
+func TestParseDefaultNamespace(t *testing.T) {
+	c, err := parse([]byte(`{"default_namespace": "shill::client"}`))
+	if err != nil {
+		t.Fatal("Unexpected failure of parse: ", err)
+	}
+	if c.DefaultNamespace != "shill::client" {
+		t.Errorf("Unexpected default_namespace: got %q, want %q", c.DefaultNamespace, "shill::client")
+	}
+
+	for _, b := range []string{
+		`{"default_namespace": "shill.client"}`,
+		`{"default_namespace": "::shill"}`,
+		`{"default_namespace": "1shill"}`,
+	} {
+		if _, err := parse([]byte(b)); err == nil {
+			t.Errorf("Unexpected success of parse: %s", b)
+		}
+	}
+}
+
+func TestParseOutputFiles(t *testing.T) {
+	c, err := parseYAML([]byte("output_files:\n  org.chromium.Foo: foo/dbus_adaptor.h\n"))
+	if err != nil {
+		t.Fatal("Unexpected failure of parseYAML: ", err)
+	}
+	if got := c.OutputFiles["org.chromium.Foo"]; got != "foo/dbus_adaptor.h" {
+		t.Errorf("Unexpected output_files: got %q, want %q", got, "foo/dbus_adaptor.h")
+	}
+
+	for _, b := range []string{
+		`{"output_files": {"Foo": "foo.h"}}`,
+		`{"output_files": {"org.chromium.Foo": ""}}`,
+		`{"output_files": {"org.chromium.Foo": "/tmp/foo.h"}}`,
+		`{"output_files": {"org.chromium.Foo": "../foo.h"}}`,
+	} {
+		if _, err := parse([]byte(b)); err == nil {
+			t.Errorf("Unexpected success of parse: %s", b)
+		}
+	}
+}
+
+func TestParsePolicy(t *testing.T) {
+	c, err := parse([]byte(`{"service_name": "org.chromium.Foo", "policy": {"user": "foo", "client_users": ["chronos"], "exec": "/usr/bin/foo"}}`))
+	if err != nil {
+		t.Fatal("Unexpected failure of parse: ", err)
+	}
+	if c.Policy == nil {
+		t.Fatal("Unexpected policy: got nil, want non-nil")
+	}
+	if c.Policy.User != "foo" || len(c.Policy.ClientUsers) != 1 || c.Policy.ClientUsers[0] != "chronos" || c.Policy.Exec != "/usr/bin/foo" {
+		t.Errorf("Unexpected policy: got %+v", *c.Policy)
+	}
+
+	for _, b := range []string{
+		`{"policy": {"user": "foo"}}`,
+		`{"service_name": "org.chromium.Foo", "policy": {}}`,
+		`{"service_name": "org.chromium.Foo", "policy": {"user": "Foo Bar"}}`,
+		`{"service_name": "org.chromium.Foo", "policy": {"user": "foo", "client_users": [""]}}`,
+		`{"service_name": "org.chromium.Foo", "policy": {"user": "foo", "exec": "foo"}}`,
+	} {
+		if _, err := parse([]byte(b)); err == nil {
+			t.Errorf("Unexpected success of parse: %s", b)
+		}
+	}
+}
+
+func TestParseResilientProxy(t *testing.T) {
+	c, err := parse([]byte(`{"resilient_proxy": {"max_attempts": 5}}`))
+	if err != nil {
+		t.Fatal("Unexpected failure of parse: ", err)
+	}
+	if c.ResilientProxy == nil {
+		t.Fatal("Unexpected resilient_proxy: got nil, want non-nil")
+	}
+	want := ResilientProxyConfig{MaxAttempts: 5, InitialBackoffMs: 100, MaxBackoffMs: 5000}
+	if *c.ResilientProxy != want {
+		t.Errorf("Unexpected resilient_proxy: got %+v, want %+v", *c.ResilientProxy, want)
+	}
+
+	for _, b := range []string{
+		`{"resilient_proxy": {"max_attempts": -1}}`,
+		`{"resilient_proxy": {"initial_backoff_ms": -1}}`,
+		`{"resilient_proxy": {"initial_backoff_ms": 1000, "max_backoff_ms": 500}}`,
+	} {
+		if _, err := parse([]byte(b)); err == nil {
+			t.Errorf("Unexpected success of parse: %s", b)
+		}
+	}
+}
+
+func TestParseClientFactory(t *testing.T) {
+	if _, err := parse([]byte(`{"client_factory": {}}`)); err == nil {
+		t.Fatal("Unexpected success of parse")
+	}
+	if _, err := parse([]byte(`{"service_name": "test.ServiceName", "client_factory": {"name": "Factory"}}`)); err == nil {
+		t.Fatal("Unexpected success of parse")
+	}
+
+	c, err := parse([]byte(`{"service_name": "test.ServiceName", "client_factory": {}}`))
+	if err != nil {
+		t.Fatal("Unexpected failure of parse: ", err)
+	}
+	if c.ClientFactory == nil {
+		t.Fatal("Unexpected client_factory: got nil, want non-nil")
+	}
+	if c.ClientFactory.Name != "test.ServiceName.ClientFactory" {
+		t.Errorf("Unexpected client_factory.name: got %q, want test.ServiceName.ClientFactory", c.ClientFactory.Name)
+	}
+}
+
+func TestParseTypeMappings(t *testing.T) {
+	c, err := parse([]byte(`{"type_mappings": [{"signature": "au", "arg_name": "ip_addresses", "cpp_type": "std::vector<net_base::IPAddress>", "header": "<net-base/ip_address.h>"}]}`))
+	if err != nil {
+		t.Fatal("Unexpected failure of parse: ", err)
+	}
+	want := TypeMapping{Signature: "au", ArgName: "ip_addresses", CppType: "std::vector<net_base::IPAddress>", Header: "<net-base/ip_address.h>"}
+	if len(c.TypeMappings) != 1 || c.TypeMappings[0] != want {
+		t.Errorf("Unexpected type_mappings: got %+v, want [%+v]", c.TypeMappings, want)
+	}
+
+	for _, b := range []string{
+		`{"type_mappings": [{"cpp_type": "Foo"}]}`,
+		`{"type_mappings": [{"signature": "a", "cpp_type": "Foo"}]}`,
+		`{"type_mappings": [{"signature": "ii", "cpp_type": "Foo"}]}`,
+		`{"type_mappings": [{"arg_name": "foo"}]}`,
+	} {
+		if _, err := parse([]byte(b)); err == nil {
+			t.Errorf("Unexpected success of parse: %s", b)
+		}
+	}
+}
+
+func TestParseTargetVersion(t *testing.T) {
+	c, err := parse([]byte(`{"target_version": 2}`))
+	if err != nil {
+		t.Fatal("Unexpected failure of parse: ", err)
+	}
+	if c.TargetVersion != 2 {
+		t.Errorf("Unexpected target_version: got %d, want 2", c.TargetVersion)
+	}
+
+	if _, err := parse([]byte(`{"target_version": -1}`)); err == nil {
+		t.Error("Unexpected success of parse for a negative target_version")
+	}
+}
+
+func TestParseInterfaces(t *testing.T) {
+	c, err := parse([]byte(`{
+	  "service_name": "org.chromium.Foo",
+	  "object_manager": {},
+	  "interfaces": {
+	    "org.chromium.Foo.Device": {
+	      "object_path": "/org/chromium/Foo/Device",
+	      "properties": "property_set",
+	      "disable_blocking_calls": true,
+	      "namespace": "foo"
+	    }
+	  }
+	}`))
+	if err != nil {
+		t.Fatal("Unexpected failure of parse: ", err)
+	}
+	ic := c.Interfaces["org.chromium.Foo.Device"]
+	if ic.ObjectPath != "/org/chromium/Foo/Device" {
+		t.Errorf("Unexpected object_path: got %q, want %q", ic.ObjectPath, "/org/chromium/Foo/Device")
+	}
+	if got := c.NamespaceOverrides["org.chromium.Foo.Device"]; got != "foo" {
+		t.Errorf("Unexpected namespace_overrides: got %q, want %q", got, "foo")
+	}
+
+	for _, b := range []string{
+		`{"interfaces": {"Device": {}}}`,
+		`{"interfaces": {"org.chromium.Foo.Device": {"object_path": "org/chromium/Foo"}}}`,
+		`{"interfaces": {"org.chromium.Foo.Device": {"object_path": "/org/chromium/Foo/"}}}`,
+		`{"interfaces": {"org.chromium.Foo.Device": {"properties": "object_manager"}}}`,
+		`{"interfaces": {"org.chromium.Foo.Device": {"properties": "none"}}}`,
+		`{"interfaces": {"org.chromium.Foo.Device": {"namespace": "foo.bar"}}}`,
+		`{"interfaces": {"org.chromium.Foo.Device": {"unknown": true}}}`,
+		`{"expected_results": true, "interfaces": {"org.chromium.Foo.Device": {"disable_blocking_calls": true}}}`,
+		`{"namespace_overrides": {"org.chromium.Foo.Device": "bar"}, "interfaces": {"org.chromium.Foo.Device": {"namespace": "foo"}}}`,
+	} {
+		if _, err := parse([]byte(b)); err == nil {
+			t.Errorf("Unexpected success of parse: %s", b)
+		}
+	}
+}
+
+func TestForInterface(t *testing.T) {
+	c, err := parse([]byte(`{
+	  "service_name": "org.chromium.Foo",
+	  "object_manager": {},
+	  "disable_blocking_calls": true,
+	  "interfaces": {
+	    "org.chromium.Foo.Device": {
+	      "properties": "property_set",
+	      "disable_blocking_calls": false
+	    }
+	  }
+	}`))
+	if err != nil {
+		t.Fatal("Unexpected failure of parse: ", err)
+	}
+
+	ic := c.ForInterface("org.chromium.Foo.Device")
+	if ic.ObjectManager != nil {
+		t.Errorf("Unexpected object_manager of org.chromium.Foo.Device: got %v, want nil", ic.ObjectManager)
+	}
+	if ic.DisableBlockingCalls {
+		t.Error("Unexpected disable_blocking_calls of org.chromium.Foo.Device: got true, want false")
+	}
+
+	gc := c.ForInterface("org.chromium.Foo.Manager")
+	if gc.ObjectManager == nil {
+		t.Error("Unexpected object_manager of org.chromium.Foo.Manager: got nil, want non-nil")
+	}
+	if !gc.DisableBlockingCalls {
+		t.Error("Unexpected disable_blocking_calls of org.chromium.Foo.Manager: got false, want true")
+	}
+}
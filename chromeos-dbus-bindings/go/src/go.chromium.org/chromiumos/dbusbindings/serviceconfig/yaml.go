@@ -0,0 +1,464 @@
+// Copyright 2022 The ChromiumOS Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package serviceconfig
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// The service config is small, so instead of depending on a full YAML
+// library we support a strict subset of YAML, which is enough to describe it.
+// A document in the subset means the same as in YAML 1.2, and the constructs
+// outside of it are rejected with errors rather than being misread. The
+// parsed document is converted to JSON so that both formats share the same
+// decoding and validation logic.
+//
+// The grammar of the subset is:
+//
+//   - A document is a block mapping or a block sequence, optionally preceded
+//     by a "---" line. Blank lines and comments, starting with "#" at the
+//     beginning of a line or after a space outside quotes, are ignored.
+//   - Lines are indented with spaces only.
+//   - A block mapping is lines "key: value" or "key:" at the same
+//     indentation. A "key:" line is followed by a more indented block, or a
+//     block sequence at the same indentation, or nothing for null.
+//   - A block sequence is lines "- value", "- key: value" starting a nested
+//     block mapping, or "-" followed by a more indented block.
+//   - A key is a plain or a quoted scalar. Keys must be unique in a mapping.
+//   - A value is a single-line scalar, "{}", or a flow sequence of scalars on
+//     one line, e.g. "[a, 'b', 1]".
+//   - A quoted scalar is single quoted with "''" for a quote, or double quoted
+//     with the YAML escapes.
+//   - A plain scalar is not "-", does not start with any of
+//     "[]{}&*!|>'\"%@`#,?:", "- " or "---", and does not contain ": ". It is
+//     resolved with the YAML 1.2 core schema, i.e. to null, a boolean, an
+//     integer, a float, or a string.
+//
+// Flow mappings other than "{}", nested flow collections, scalars spanning
+// several lines, block scalars ("|" and ">"), anchors, aliases, tags,
+// directives, complex keys and multiple documents are rejected.
+
+// yamlLine is a non-empty, non-comment line of a YAML document.
+type yamlLine struct {
+	num    int // 1-indexed line number for error messages.
+	indent int
+	text   string
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+// yamlToJSON converts a YAML document into the equivalent JSON document.
+func yamlToJSON(b []byte) ([]byte, error) {
+	p := &yamlParser{}
+	for i, l := range strings.Split(string(b), "\n") {
+		l = strings.TrimRight(stripYAMLComment(l), " \t\r")
+		if strings.TrimSpace(l) == "" {
+			continue
+		}
+		if l == "---" || l == "..." {
+			if l == "---" && len(p.lines) == 0 {
+				continue
+			}
+			return nil, fmt.Errorf("line %d: multiple documents are not supported", i+1)
+		}
+		if strings.HasPrefix(strings.TrimLeft(l, " "), "\t") {
+			return nil, fmt.Errorf("line %d: tabs are not allowed for indentation", i+1)
+		}
+		text := strings.TrimLeft(l, " ")
+		p.lines = append(p.lines, yamlLine{num: i + 1, indent: len(l) - len(text), text: text})
+	}
+
+	var v interface{} = map[string]interface{}{}
+	if len(p.lines) > 0 {
+		var err error
+		if v, err = p.parseBlock(p.lines[0].indent); err != nil {
+			return nil, err
+		}
+		if p.pos < len(p.lines) {
+			l := p.lines[p.pos]
+			return nil, fmt.Errorf("line %d: unexpected indentation", l.num)
+		}
+	}
+	return json.Marshal(v)
+}
+
+// stripYAMLComment removes a trailing "# ..." comment that is not inside
+// quotes. Quotes only start scalars at the beginning of the line, after ": ",
+// "- ", "[" or ",", so that apostrophes in plain scalars are not taken for
+// quotes.
+func stripYAMLComment(l string) string {
+	var quote byte
+	for i := 0; i < len(l); i++ {
+		c := l[i]
+		switch {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case (c == '"' || c == '\'') && startsYAMLScalar(l[:i]):
+			quote = c
+		case c == '#' && (i == 0 || l[i-1] == ' ' || l[i-1] == '\t'):
+			return l[:i]
+		}
+	}
+	return l
+}
+
+// startsYAMLScalar returns true if a scalar may start right after prefix.
+func startsYAMLScalar(prefix string) bool {
+	t := strings.TrimRight(prefix, " ")
+	if t == "" {
+		return true
+	}
+	switch t[len(t)-1] {
+	case '[', ',':
+		return true
+	case ':', '-':
+		return len(t) < len(prefix)
+	}
+	return false
+}
+
+// isYAMLSequenceEntry returns true if the line text is an entry of a block
+// sequence.
+func isYAMLSequenceEntry(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// parseBlock parses a mapping or a sequence whose entries are at |indent|.
+func (p *yamlParser) parseBlock(indent int) (interface{}, error) {
+	l := p.lines[p.pos]
+	if isYAMLSequenceEntry(l.text) {
+		return p.parseSequence(indent)
+	}
+	return p.parseMapping(indent)
+}
+
+func (p *yamlParser) parseMapping(indent int) (interface{}, error) {
+	m := make(map[string]interface{})
+	for p.pos < len(p.lines) {
+		l := p.lines[p.pos]
+		if l.indent < indent {
+			break
+		}
+		if l.indent > indent {
+			return nil, fmt.Errorf("line %d: unexpected indentation", l.num)
+		}
+		if isYAMLSequenceEntry(l.text) {
+			return nil, fmt.Errorf("line %d: sequence entry found in a mapping", l.num)
+		}
+
+		key, rest, ok, err := splitYAMLKey(l.text, l.num)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected \"key: value\", got %q", l.num, l.text)
+		}
+		if _, ok := m[key]; ok {
+			return nil, fmt.Errorf("line %d: duplicate key %q", l.num, key)
+		}
+		p.pos++
+
+		if rest != "" {
+			v, err := parseYAMLValue(rest, l.num)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = v
+			continue
+		}
+		// The value is either a nested block or null.
+		if p.pos < len(p.lines) && p.lines[p.pos].indent > indent {
+			v, err := p.parseBlock(p.lines[p.pos].indent)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = v
+		} else if p.pos < len(p.lines) && p.lines[p.pos].indent == indent && isYAMLSequenceEntry(p.lines[p.pos].text) {
+			// Sequences are allowed at the same indent as their key.
+			v, err := p.parseSequence(indent)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = v
+		} else {
+			m[key] = nil
+		}
+	}
+	return m, nil
+}
+
+func (p *yamlParser) parseSequence(indent int) (interface{}, error) {
+	s := []interface{}{}
+	for p.pos < len(p.lines) {
+		l := p.lines[p.pos]
+		if l.indent < indent || !isYAMLSequenceEntry(l.text) {
+			break
+		}
+		if l.indent > indent {
+			return nil, fmt.Errorf("line %d: unexpected indentation", l.num)
+		}
+
+		item := strings.TrimLeft(strings.TrimPrefix(l.text, "-"), " ")
+		if item == "" {
+			p.pos++
+			if p.pos < len(p.lines) && p.lines[p.pos].indent > indent {
+				v, err := p.parseBlock(p.lines[p.pos].indent)
+				if err != nil {
+					return nil, err
+				}
+				s = append(s, v)
+			} else {
+				s = append(s, nil)
+			}
+			continue
+		}
+
+		_, _, ok, err := splitYAMLKey(item, l.num)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			// "- key: value" starts a mapping nested in the sequence.
+			// Rewrite the current line so the mapping can be parsed in place.
+			childIndent := l.indent + len(l.text) - len(item)
+			p.lines[p.pos] = yamlLine{num: l.num, indent: childIndent, text: item}
+			v, err := p.parseMapping(childIndent)
+			if err != nil {
+				return nil, err
+			}
+			s = append(s, v)
+			continue
+		}
+
+		v, err := parseYAMLValue(item, l.num)
+		if err != nil {
+			return nil, err
+		}
+		s = append(s, v)
+		p.pos++
+	}
+	return s, nil
+}
+
+// splitYAMLKey splits "key: value" into the key and the (possibly empty)
+// value. It returns false if text is not a mapping entry.
+func splitYAMLKey(text string, num int) (string, string, bool, error) {
+	if text[0] == '"' || text[0] == '\'' {
+		end, ok := yamlQuoteEnd(text)
+		if !ok {
+			return "", "", false, nil
+		}
+		rest := strings.TrimLeft(text[end:], " ")
+		if rest != ":" && !strings.HasPrefix(rest, ": ") {
+			return "", "", false, nil
+		}
+		key, err := unquoteYAML(text[:end], num)
+		if err != nil {
+			return "", "", false, err
+		}
+		return key, strings.TrimSpace(rest[1:]), true, nil
+	}
+
+	i := strings.Index(text, ": ")
+	if i < 0 {
+		if !strings.HasSuffix(text, ":") {
+			return "", "", false, nil
+		}
+		i = len(text) - 1
+	}
+	key := strings.TrimSpace(text[:i])
+	if key == "" || strings.HasPrefix(key, "- ") {
+		return "", "", false, nil
+	}
+	if err := checkYAMLPlain(key, num); err != nil {
+		return "", "", false, err
+	}
+	return key, strings.TrimSpace(text[i+1:]), true, nil
+}
+
+// yamlQuoteEnd returns the index right after the quoted scalar at the
+// beginning of s, and false if the quote is not closed on the line.
+func yamlQuoteEnd(s string) (int, bool) {
+	quote := s[0]
+	for i := 1; i < len(s); i++ {
+		switch {
+		case quote == '"' && s[i] == '\\':
+			i++
+		case quote == '\'' && s[i] == '\'' && i+1 < len(s) && s[i+1] == '\'':
+			i++
+		case s[i] == quote:
+			return i + 1, true
+		}
+	}
+	return 0, false
+}
+
+// yamlEscapes maps the escape sequences of double quoted scalars with a
+// single character after the backslash to their values.
+var yamlEscapes = map[byte]string{
+	'0': "\x00", 'a': "\a", 'b': "\b", 't': "\t", '\t': "\t", 'n': "\n",
+	'v': "\v", 'f': "\f", 'r': "\r", 'e': "\x1b", ' ': " ", '"': "\"",
+	'/': "/", '\\': "\\", 'N': "\u0085", '_': "\u00a0", 'L': "\u2028",
+	'P': "\u2029",
+}
+
+// unquoteYAML returns the value of the quoted scalar s, which must be quoted
+// as a whole.
+func unquoteYAML(s string, num int) (string, error) {
+	if end, ok := yamlQuoteEnd(s); !ok || end != len(s) {
+		return "", fmt.Errorf("line %d: invalid quoted string %s", num, s)
+	}
+	inner := s[1 : len(s)-1]
+	if s[0] == '\'' {
+		return strings.ReplaceAll(inner, "''", "'"), nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(inner); i++ {
+		if inner[i] != '\\' {
+			b.WriteByte(inner[i])
+			continue
+		}
+		i++
+		if e, ok := yamlEscapes[inner[i]]; ok {
+			b.WriteString(e)
+			continue
+		}
+		n := map[byte]int{'x': 2, 'u': 4, 'U': 8}[inner[i]]
+		if n == 0 || i+n >= len(inner) {
+			return "", fmt.Errorf("line %d: invalid escape in quoted string %s", num, s)
+		}
+		r, err := strconv.ParseUint(inner[i+1:i+1+n], 16, 32)
+		if err != nil || !utf8.ValidRune(rune(r)) {
+			return "", fmt.Errorf("line %d: invalid escape in quoted string %s", num, s)
+		}
+		b.WriteRune(rune(r))
+		i += n
+	}
+	return b.String(), nil
+}
+
+// checkYAMLPlain returns an error if s is not a plain scalar of the subset.
+func checkYAMLPlain(s string, num int) error {
+	switch {
+	case strings.ContainsAny(s[:1], "[]{}&*!|>'\"%@`#,?:") || s == "-" || strings.HasPrefix(s, "- ") || strings.HasPrefix(s, "---"):
+		return fmt.Errorf("line %d: unsupported YAML syntax %q", num, s)
+	case strings.Contains(s, ": ") || strings.HasSuffix(s, ":"):
+		return fmt.Errorf("line %d: unexpected mapping in %q", num, s)
+	}
+	return nil
+}
+
+// parseYAMLValue parses a scalar, "{}" or a flow sequence of scalars.
+func parseYAMLValue(s string, num int) (interface{}, error) {
+	switch {
+	case s == "{}":
+		return map[string]interface{}{}, nil
+	case strings.HasPrefix(s, "["):
+		return parseYAMLFlowSequence(s, num)
+	}
+	return parseYAMLScalar(s, num)
+}
+
+// parseYAMLFlowSequence parses a flow sequence of scalars on a single line.
+func parseYAMLFlowSequence(s string, num int) (interface{}, error) {
+	items := []interface{}{}
+	rest := strings.TrimSpace(s[1:])
+	for {
+		if strings.HasPrefix(rest, "]") {
+			if strings.TrimSpace(rest[1:]) != "" {
+				return nil, fmt.Errorf("line %d: unexpected %q after flow sequence", num, rest[1:])
+			}
+			return items, nil
+		}
+		if rest == "" {
+			return nil, fmt.Errorf("line %d: unterminated flow sequence %s", num, s)
+		}
+		// Find the end of the item, skipping over its quotes.
+		end := 0
+		if rest[0] == '"' || rest[0] == '\'' {
+			var ok bool
+			if end, ok = yamlQuoteEnd(rest); !ok {
+				return nil, fmt.Errorf("line %d: invalid quoted string in %s", num, s)
+			}
+		}
+		end += strings.IndexAny(rest[end:]+"]", ",]")
+		item := strings.TrimSpace(rest[:end])
+		if item == "" {
+			return nil, fmt.Errorf("line %d: empty item in flow sequence %s", num, s)
+		}
+		v, err := parseYAMLScalar(item, num)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, v)
+		rest = rest[end:]
+		if strings.HasPrefix(rest, ",") {
+			rest = strings.TrimSpace(rest[1:])
+		}
+	}
+}
+
+var (
+	// yamlIntRE and yamlFloatRE match the integers and the floats of the
+	// YAML 1.2 core schema, except ".inf" and ".nan" which JSON cannot
+	// represent.
+	yamlIntRE   = regexp.MustCompile(`^(?:[-+]?[0-9]+|0o[0-7]+|0x[0-9a-fA-F]+)$`)
+	yamlFloatRE = regexp.MustCompile(`^[-+]?(?:\.[0-9]+|[0-9]+(?:\.[0-9]*)?)(?:[eE][-+]?[0-9]+)?$`)
+	// yamlInfNaNRE matches the infinities and NaN of the core schema.
+	yamlInfNaNRE = regexp.MustCompile(`^(?:[-+]?\.(?:inf|Inf|INF)|\.(?:nan|NaN|NAN))$`)
+)
+
+// parseYAMLScalar parses a quoted or a plain scalar.
+func parseYAMLScalar(s string, num int) (interface{}, error) {
+	if s[0] == '"' || s[0] == '\'' {
+		return unquoteYAML(s, num)
+	}
+	if err := checkYAMLPlain(s, num); err != nil {
+		return nil, err
+	}
+	switch s {
+	case "~", "null", "Null", "NULL":
+		return nil, nil
+	case "true", "True", "TRUE":
+		return true, nil
+	case "false", "False", "FALSE":
+		return false, nil
+	}
+	switch {
+	case yamlIntRE.MatchString(s):
+		base, digits := 10, s
+		if strings.HasPrefix(s, "0o") {
+			base, digits = 8, s[len("0o"):]
+		} else if strings.HasPrefix(s, "0x") {
+			base, digits = 16, s[len("0x"):]
+		}
+		i, err := strconv.ParseInt(digits, base, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid integer %s: %v", num, s, err)
+		}
+		return i, nil
+	case yamlFloatRE.MatchString(s):
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid float %s: %v", num, s, err)
+		}
+		return f, nil
+	case yamlInfNaNRE.MatchString(s):
+		return nil, fmt.Errorf("line %d: infinity and NaN are not supported", num)
+	}
+	return s, nil
+}
@@ -0,0 +1,97 @@
+// Copyright 2022 The ChromiumOS Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package serviceconfig
+
+import "testing"
+
+func TestYAMLToJSON(t *testing.T) {
+	cases := []struct {
+		input string
+		want  string
+	}{
+		{"", `{}`},
+		{"# only a comment\n", `{}`},
+		{"a: b", `{"a":"b"}`},
+		{"a: 'it''s'", `{"a":"it's"}`},
+		{"a: \"x # y\"", `{"a":"x # y"}`},
+		{"a: 1\nb: true\nc: null\nd: 1.5", `{"a":1,"b":true,"c":null,"d":1.5}`},
+		{"a:\n  b:\n    c: d\n  e: f", `{"a":{"b":{"c":"d"},"e":"f"}}`},
+		{"a:\n  - x\n  - y", `{"a":["x","y"]}`},
+		{"a:\n- x\n- y", `{"a":["x","y"]}`},
+		{"a: [x, 2]\nb: []\nc: {}", `{"a":["x",2],"b":[],"c":{}}`},
+		{"a:\n  - name: x\n    value: 1\n  - name: y", `{"a":[{"name":"x","value":1},{"name":"y"}]}`},
+		{"url: http://example.com", `{"url":"http://example.com"}`},
+		{"---\na: b", `{"a":"b"}`},
+		{"a: it's # comment", `{"a":"it's"}`},
+		{"a: \"say \\\"hi\\\" # not a comment\"", `{"a":"say \"hi\" # not a comment"}`},
+		{"a: \"\\x41\\u00e9\\t\"", `{"a":"Aé\t"}`},
+		{"\"a: b\": c\n'd''s': e", `{"a: b":"c","d's":"e"}`},
+		{"a: [x, 'y, z', \"w]\", ]", `{"a":["x","y, z","w]"]}`},
+		{"a: [True, NULL, 0x1F, 0o17, -2, .5, 1e3]", `{"a":[true,null,31,15,-2,0.5,1000]}`},
+		{"a: 0x1p-2\nb: yes", `{"a":"0x1p-2","b":"yes"}`},
+		{"- a\n- b: c\n  d: e", `["a",{"b":"c","d":"e"}]`},
+	}
+	for _, tc := range cases {
+		got, err := yamlToJSON([]byte(tc.input))
+		if err != nil {
+			t.Errorf("yamlToJSON(%q) got error, want nil: %v", tc.input, err)
+			continue
+		}
+		if string(got) != tc.want {
+			t.Errorf("yamlToJSON(%q) mismatch: got %s, want %s", tc.input, got, tc.want)
+		}
+	}
+}
+
+func TestYAMLToJSONFailures(t *testing.T) {
+	cases := []string{
+		"a",
+		"a: b\n  c: d",
+		"a: b\na: c",
+		"a:\n\tb: c",
+		"a:\n  - x\n  b: c",
+		// Flow collections other than "{}" and flat flow sequences.
+		"a: {b: c}",
+		"a: [b, [c]]",
+		"a: [b, {c: d}]",
+		"a: [b, c",
+		"a: [b, c] d",
+		"a: [, b]",
+		"a: [b: c]",
+		// Scalars spanning several lines.
+		"a: b\n  c",
+		"a:\n  - b\n    c",
+		"a: \"b\n  c\"",
+		"a: 'b\n  c'",
+		"a: |",
+		"a: |-\n  b",
+		"a: >\n  b",
+		// Anchors, aliases, tags and merge keys.
+		"a: &anchor b",
+		"&anchor a: b",
+		"a: *alias",
+		"<<: *alias",
+		"a: !!str b",
+		// Directives, complex keys and multiple documents.
+		"%YAML 1.2\n---\na: b",
+		"? a\n: b",
+		"a: b\n---\nc: d",
+		"a: b\n...",
+		"--- a: b",
+		// Broken quoting and mappings in scalars.
+		"a: 'b' c",
+		"a: \"b\" c",
+		"a: \"\\q\"",
+		"\"a: b",
+		"a: b: c",
+		"a: - b",
+		"a: .inf",
+	}
+	for _, tc := range cases {
+		if _, err := yamlToJSON([]byte(tc)); err == nil {
+			t.Errorf("yamlToJSON(%q) unexpectedly succeeded", tc)
+		}
+	}
+}